@@ -10,9 +10,13 @@ import (
 	"github.com/skr1ms/dev2gis/config"
 	"github.com/skr1ms/dev2gis/internal/auth"
 	"github.com/skr1ms/dev2gis/internal/heightmap"
+	"github.com/skr1ms/dev2gis/internal/organization"
+	"github.com/skr1ms/dev2gis/internal/project"
 	"github.com/skr1ms/dev2gis/internal/storage"
 	"github.com/skr1ms/dev2gis/internal/storage/minio"
+	"github.com/skr1ms/dev2gis/internal/webhook"
 	"github.com/skr1ms/dev2gis/pkg/jwt"
+	"github.com/skr1ms/dev2gis/pkg/mailer"
 	"github.com/skr1ms/dev2gis/pkg/metrics"
 	"github.com/skr1ms/dev2gis/pkg/middleware"
 	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
@@ -41,7 +45,9 @@ func main() {
 	logger := middleware.NewLogger(cfg)
 	middleware.SetGlobalLogger(logger)
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	db, err := storage.NewDB(ctx, *cfg, logger)
 	if err != nil {
 		logger.Fatal("Failed to connect to database", err)
@@ -66,6 +72,9 @@ func main() {
 	}
 
 	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatal("Invalid trusted proxies", err)
+	}
 	router.Use(gin.Recovery())
 	router.Use(logger.Middleware())
 	router.Use(cors.Default())
@@ -83,16 +92,87 @@ func main() {
 	router.HEAD("/health", healthHandler)
 
 	jwtService := jwt.NewJWTService(cfg.Auth.AccessTokenSecret, cfg.Auth.RefreshTokenSecret, cfg.Auth.JWTAccessTokenTTL, cfg.Auth.JWTRefreshTokenTTL)
-	authService := auth.NewAuthService(db, jwtService)
-	heightmapService := heightmap.NewService(db, minioClient, rabbitmqClient, cfg)
+	if cfg.Auth.JWTSigningAlgorithm != jwt.AlgorithmHS256 {
+		keySet := jwt.NewKeySet()
+		signingKeys := auth.NewSigningKeyStore(db, keySet, cfg.Auth, logger)
+		if err := signingKeys.Sync(ctx); err != nil {
+			logger.Fatal("Failed to load JWT signing keys", err)
+		}
+		jwtService.UseKeySet(keySet)
+		go signingKeys.Run(ctx)
+	}
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, jwtService.JWKS())
+	})
+
+	revocationStore := auth.NewRevocationStore(db, cfg.Auth, logger)
+	mailSender := mailer.New(&cfg.Mail, logger)
+	authService := auth.NewAuthService(db, jwtService, revocationStore, mailSender, cfg.Auth, logger)
+	if err := authService.BootstrapAdmin(ctx); err != nil {
+		logger.Error("Failed to bootstrap admin", err)
+	}
+	webhookService := webhook.NewService(db, cfg.Webhooks)
+	organizationService := organization.NewService(db, mailSender, cfg.Auth, logger)
+	projectService := project.NewService(db, logger)
+	heightmapService := heightmap.NewService(db, minioClient, webhookService, cfg)
+
+	go authService.Run(ctx)
+	go revocationStore.Run(ctx)
+	go organizationService.Run(ctx)
+
+	webhookDispatcher := webhook.NewDispatcher(db, cfg.Webhooks, logger)
+	go webhookDispatcher.Run(ctx)
+
+	outboxRelay := heightmap.NewOutboxRelay(db, rabbitmqClient, minioClient, cfg, logger)
+	go outboxRelay.Run(ctx)
+
+	storageCleaner := heightmap.NewStorageCleaner(db, minioClient, cfg, logger)
+	go storageCleaner.Run(ctx)
+
+	reaper := heightmap.NewReaper(heightmapService, cfg.Reaper, logger)
+	go reaper.Run(ctx)
+
+	dispatcher := heightmap.NewDispatcher(heightmapService, cfg.Dispatch, logger)
+	go dispatcher.Run(ctx)
+
+	scheduler := heightmap.NewScheduler(heightmapService, cfg.Scheduler, logger)
+	go scheduler.Run(ctx)
+
+	go func() {
+		if err := rabbitmqClient.ConsumeResults(ctx, heightmapService.HandleJobResult); err != nil {
+			logger.Error("Results consumer failed", err)
+		}
+	}()
+
+	eventBroker := heightmap.NewEventBroker(db, cfg.Events, logger)
+	go eventBroker.Run(ctx)
+
+	jobUpdatesHub := heightmap.NewJobUpdatesHub(rabbitmqClient.NewJobUpdatesSubscriber(), logger)
+	go jobUpdatesHub.Run(ctx)
 
-	heightmapHandler := heightmap.NewHandler(heightmapService)
+	heightmapHandler := heightmap.NewHandler(heightmapService, eventBroker, jobUpdatesHub)
+	heightmapAdminHandler := heightmap.NewAdminHandler(heightmapService)
+	shareHandler := heightmap.NewShareHandler(heightmapService)
+	quotaHandler := heightmap.NewQuotaHandler(heightmapService)
+	deadLetterHandler := heightmap.NewDeadLetterHandler(heightmap.NewDeadLetterService(heightmapService, rabbitmqClient, logger))
 	authHandler := auth.NewAuthHandler(authService, logger)
+	webhookHandler := webhook.NewHandler(webhookService)
+	organizationHandler := organization.NewHandler(organizationService)
+	projectHandler := project.NewHandler(projectService)
 
 	// Register routes
 	apiGroup := router.Group("/api")
-	authHandler.RegisterRoutes(apiGroup)
-	heightmapHandler.RegisterRoutes(apiGroup, middleware.NewJWTMiddleware(jwtService, logger))
+	jwtMiddleware := middleware.NewJWTMiddleware(jwtService, revocationStore, authService, logger)
+	authHandler.RegisterRoutes(apiGroup, jwtMiddleware)
+	heightmapHandler.RegisterRoutes(apiGroup, jwtMiddleware)
+	heightmapAdminHandler.RegisterRoutes(apiGroup, jwtMiddleware)
+	shareHandler.RegisterRoutes(apiGroup, jwtMiddleware)
+	quotaHandler.RegisterRoutes(apiGroup, jwtMiddleware)
+	deadLetterHandler.RegisterRoutes(apiGroup, jwtMiddleware)
+	webhookHandler.RegisterRoutes(apiGroup, jwtMiddleware)
+	organizationHandler.RegisterRoutes(apiGroup, jwtMiddleware)
+	projectHandler.RegisterRoutes(apiGroup, jwtMiddleware)
 
 	apiGroup.GET("/metrics", metrics.PrometheusHandler())
 
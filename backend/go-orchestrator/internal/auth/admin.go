@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+var (
+	ErrUserSuspended = errors.New("учетная запись заблокирована")
+	ErrSelfAction    = errors.New("нельзя заблокировать или удалить свою учетную запись")
+	// ErrLastOrganizationOwner keeps an organization with other members from
+	// losing its only owner together with the account.
+	ErrLastOrganizationOwner = errors.New("передайте роль владельца другому участнику организации перед удалением учетной записи")
+)
+
+// UserFilter narrows the admin user listing. Empty fields match every user;
+// Email matches a substring of the address.
+type UserFilter struct {
+	Role      string
+	Suspended *bool
+	Email     string
+}
+
+func (s *AuthService) ListUsers(ctx context.Context, filter UserFilter, limit, offset int32) ([]*AdminUserResponse, error) {
+	params := sqlc.ListUsersParams{
+		Suspended: filter.Suspended,
+		Limit:     limit,
+		Offset:    offset,
+	}
+	if filter.Role != "" {
+		if _, ok := roleRanks[filter.Role]; !ok {
+			return nil, ErrInvalidRole
+		}
+		params.Role = &filter.Role
+	}
+	if filter.Email != "" {
+		params.Email = &filter.Email
+	}
+
+	users, err := s.queries.ListUsers(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список пользователей: %w", err)
+	}
+
+	result := make([]*AdminUserResponse, 0, len(users))
+	for _, user := range users {
+		result = append(result, newAdminUserResponse(user))
+	}
+
+	return result, nil
+}
+
+// SuspendUser blocks sign-in for the user and ends every session, so the
+// account is cut off immediately. Jobs of the user are kept.
+func (s *AuthService) SuspendUser(ctx context.Context, actorID, userID uuid.UUID) (*AdminUserResponse, error) {
+	if actorID == userID {
+		return nil, ErrSelfAction
+	}
+
+	var updated sqlc.User
+	err := s.withTx(ctx, func(q QueriesInterface) error {
+		user, err := s.lockOutCandidate(ctx, q, userID)
+		if err != nil {
+			return err
+		}
+		if user.SuspendedAt != nil {
+			updated = user
+			return nil
+		}
+
+		now := time.Now()
+		updated, err = q.UpdateUserSuspension(ctx, sqlc.UpdateUserSuspensionParams{
+			ID:          userID,
+			SuspendedAt: &now,
+		})
+		if err != nil {
+			return fmt.Errorf("не удалось заблокировать пользователя: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.RevokeUserSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return newAdminUserResponse(updated), nil
+}
+
+// UnsuspendUser lets a suspended user sign in again.
+func (s *AuthService) UnsuspendUser(ctx context.Context, userID uuid.UUID) (*AdminUserResponse, error) {
+	updated, err := s.queries.UpdateUserSuspension(ctx, sqlc.UpdateUserSuspensionParams{
+		ID:          userID,
+		SuspendedAt: nil,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("не удалось разблокировать пользователя: %w", err)
+	}
+
+	return newAdminUserResponse(updated), nil
+}
+
+// DeleteUser removes the user together with their jobs, webhooks, refresh
+// tokens and the organizations nobody else is a member of, and schedules the
+// deletion of their stored files. Access tokens already issued are
+// denylisted until they expire.
+func (s *AuthService) DeleteUser(ctx context.Context, actorID, userID uuid.UUID) error {
+	if actorID == userID {
+		return ErrSelfAction
+	}
+
+	return s.deleteUser(ctx, userID)
+}
+
+func (s *AuthService) deleteUser(ctx context.Context, userID uuid.UUID) error {
+	err := s.withTx(ctx, func(q QueriesInterface) error {
+		if _, err := s.lockOutCandidate(ctx, q, userID); err != nil {
+			return err
+		}
+		owned, err := q.CountOrganizationsLastOwnedByUser(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("не удалось посчитать организации пользователя: %w", err)
+		}
+		if owned > 0 {
+			return ErrLastOrganizationOwner
+		}
+		if err := q.DeleteUserSoleOrganizations(ctx, userID); err != nil {
+			return fmt.Errorf("не удалось удалить организации: %w", err)
+		}
+		// Organization projects stay with the organization.
+		if err := q.DeleteUserPersonalProjects(ctx, &userID); err != nil {
+			return fmt.Errorf("не удалось удалить проекты: %w", err)
+		}
+		// The job IDs are read before the jobs are deleted with the user.
+		if err := q.ScheduleUserStorageDeletion(ctx, userID); err != nil {
+			return fmt.Errorf("не удалось запланировать удаление файлов: %w", err)
+		}
+		if err := q.Delete(ctx, userID); err != nil {
+			return fmt.Errorf("не удалось удалить пользователя: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeUser(ctx, userID)
+}
+
+// lockOutCandidate loads a user about to lose access and refuses when they
+// are the last active admin.
+func (s *AuthService) lockOutCandidate(ctx context.Context, q QueriesInterface, userID uuid.UUID) (sqlc.User, error) {
+	user, err := q.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.User{}, ErrUserNotFound
+		}
+		return sqlc.User{}, fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+
+	if user.Role == RoleAdmin && user.SuspendedAt == nil {
+		admins, err := q.CountUsersByRole(ctx, RoleAdmin)
+		if err != nil {
+			return sqlc.User{}, fmt.Errorf("не удалось посчитать администраторов: %w", err)
+		}
+		if admins <= 1 {
+			return sqlc.User{}, ErrLastAdmin
+		}
+	}
+
+	return user, nil
+}
+
+func newAdminUserResponse(user sqlc.User) *AdminUserResponse {
+	return &AdminUserResponse{
+		ID:          user.ID.String(),
+		Email:       user.Email,
+		Name:        user.Name,
+		Role:        user.Role,
+		SuspendedAt: user.SuspendedAt,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
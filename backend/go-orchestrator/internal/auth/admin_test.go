@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+func TestSuspendUser(t *testing.T) {
+	adminID := uuid.New()
+	suspendedAt := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		targetID      uuid.UUID
+		role          string
+		suspendedAt   *time.Time
+		admins        int64
+		lookupErr     error
+		expectedErr   error
+		expectUpdated bool
+	}{
+		{name: "suspend user", targetID: uuid.New(), role: RoleUser, expectUpdated: true},
+		{name: "suspend one of two admins", targetID: uuid.New(), role: RoleAdmin, admins: 2, expectUpdated: true},
+		{name: "already suspended", targetID: uuid.New(), role: RoleUser, suspendedAt: &suspendedAt},
+		{name: "suspend self", targetID: adminID, role: RoleAdmin, admins: 2, expectedErr: ErrSelfAction},
+		{name: "suspend the last admin", targetID: uuid.New(), role: RoleAdmin, admins: 1, expectedErr: ErrLastAdmin},
+		{name: "unknown user", targetID: uuid.New(), lookupErr: pgx.ErrNoRows, expectedErr: ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := false
+			queries := &mockQueries{
+				getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+					if tt.lookupErr != nil {
+						return sqlc.User{}, tt.lookupErr
+					}
+					return sqlc.User{ID: id, Role: tt.role, SuspendedAt: tt.suspendedAt}, nil
+				},
+				countUsersByRoleFunc: func(ctx context.Context, role string) (int64, error) {
+					return tt.admins, nil
+				},
+				updateSuspensionFunc: func(ctx context.Context, params sqlc.UpdateUserSuspensionParams) (sqlc.User, error) {
+					updated = true
+					return sqlc.User{ID: params.ID, Role: tt.role, SuspendedAt: params.SuspendedAt}, nil
+				},
+			}
+			revoker := &mockRevoker{}
+			service := &AuthService{
+				queries: queries,
+				withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+					return fn(queries)
+				},
+				revocations: revoker,
+			}
+
+			user, err := service.SuspendUser(context.Background(), adminID, tt.targetID)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if updated || len(revoker.revokedUsers) > 0 {
+					t.Error("the user must stay untouched on error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tt.expectUpdated {
+				t.Errorf("expected updated=%v, got %v", tt.expectUpdated, updated)
+			}
+			if user.SuspendedAt == nil {
+				t.Error("expected suspended_at to be set")
+			}
+			if len(revoker.revokedUsers) != 1 || revoker.revokedUsers[0] != tt.targetID {
+				t.Errorf("expected sessions of %s revoked, got %v", tt.targetID, revoker.revokedUsers)
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	adminID := uuid.New()
+	targetID := uuid.New()
+
+	var deleted []uuid.UUID
+	queries := &mockQueries{
+		getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+			return sqlc.User{ID: id, Role: RoleUser}, nil
+		},
+		deleteFunc: func(ctx context.Context, id uuid.UUID) error {
+			deleted = append(deleted, id)
+			return nil
+		},
+	}
+	revoker := &mockRevoker{}
+	service := &AuthService{
+		queries: queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return fn(queries)
+		},
+		revocations: revoker,
+	}
+
+	if err := service.DeleteUser(context.Background(), adminID, adminID); !errors.Is(err, ErrSelfAction) {
+		t.Fatalf("expected %v, got %v", ErrSelfAction, err)
+	}
+	if len(deleted) != 0 {
+		t.Fatal("an admin must not delete their own account")
+	}
+
+	if err := service.DeleteUser(context.Background(), adminID, targetID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != targetID {
+		t.Errorf("expected %s deleted, got %v", targetID, deleted)
+	}
+	// Access tokens outlive the row, so they have to be denylisted.
+	if len(revoker.revokedUsers) != 1 || revoker.revokedUsers[0] != targetID {
+		t.Errorf("expected access tokens of %s revoked, got %v", targetID, revoker.revokedUsers)
+	}
+}
+
+func TestDeleteUserKeepsOrganizationOwner(t *testing.T) {
+	deleted := false
+	queries := &mockQueries{
+		getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+			return sqlc.User{ID: id, Role: RoleUser}, nil
+		},
+		lastOwnedOrgsFunc: func(ctx context.Context, userID uuid.UUID) (int64, error) {
+			return 1, nil
+		},
+		deleteFunc: func(ctx context.Context, id uuid.UUID) error {
+			deleted = true
+			return nil
+		},
+	}
+	service := &AuthService{
+		queries: queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return fn(queries)
+		},
+		revocations: &mockRevoker{},
+	}
+
+	err := service.DeleteUser(context.Background(), uuid.New(), uuid.New())
+	if !errors.Is(err, ErrLastOrganizationOwner) {
+		t.Fatalf("expected %v, got %v", ErrLastOrganizationOwner, err)
+	}
+	if deleted {
+		t.Error("the last owner of a shared organization must not be deleted")
+	}
+}
+
+func TestLoginRejectsSuspendedUser(t *testing.T) {
+	suspendedAt := time.Now()
+	queries := &mockQueries{}
+	queries.getByEmailFunc = func(ctx context.Context, email string) (sqlc.User, error) {
+		return sqlc.User{ID: uuid.New(), Email: email, PasswordHash: hashPassword(t, "password123"), Role: RoleUser, SuspendedAt: &suspendedAt}, nil
+	}
+	service := &AuthService{queries: queries, jwtService: &mockJWTService{}}
+
+	_, err := service.LoginUser(context.Background(), &LoginUserRequest{Email: "user@example.com", Password: "password123"}, "192.0.2.1")
+	if !errors.Is(err, ErrUserSuspended) {
+		t.Fatalf("expected %v, got %v", ErrUserSuspended, err)
+	}
+
+	// A wrong password must not reveal that the account exists and is suspended.
+	_, err = service.LoginUser(context.Background(), &LoginUserRequest{Email: "user@example.com", Password: "wrong-password"}, "192.0.2.1")
+	if errors.Is(err, ErrUserSuspended) {
+		t.Fatal("expected the generic credentials error for a wrong password")
+	}
+}
+
+func TestRefreshRejectsSuspendedUser(t *testing.T) {
+	userID := uuid.New()
+	suspendedAt := time.Now()
+	queries := &mockQueries{}
+	queries.withRefreshTokenStore()
+	queries.getByIDFunc = func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+		return sqlc.User{ID: id, Role: RoleUser, SuspendedAt: &suspendedAt}, nil
+	}
+	service := newTestRefreshService(queries)
+
+	login, err := service.jwtService.GenerateTokenPair(userID, "user@example.com", "User", RoleUser)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := service.storeRefreshToken(context.Background(), queries, userID, login); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := service.RefreshTokens(context.Background(), &RefreshRequest{RefreshToken: login.RefreshToken}); !errors.Is(err, ErrUserSuspended) {
+		t.Fatalf("expected %v, got %v", ErrUserSuspended, err)
+	}
+}
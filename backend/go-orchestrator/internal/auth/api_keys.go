@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+const (
+	// apiKeyPrefix marks the keys of this service, so secret scanners and
+	// users can tell them apart from other credentials.
+	apiKeyPrefix = "d2g_"
+	// apiKeyDisplayLength is how much of the key is stored in clear and
+	// shown in listings to identify it.
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+	maxAPIKeysPerUser   = 20
+	// apiKeyTouchEvery limits last_used_at writes for keys used in a loop.
+	apiKeyTouchEvery = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey      = errors.New("недействительный API ключ")
+	ErrAPIKeyNotFound     = errors.New("API ключ не найден")
+	ErrAPIKeyLimit        = errors.New("достигнуто максимальное количество API ключей")
+	ErrAPIKeyExpiry       = errors.New("срок действия API ключа должен быть в будущем")
+	ErrAdminScopeDenied   = errors.New("разрешение admin доступно только администраторам")
+	ErrUnknownAPIKeyScope = errors.New("неизвестное разрешение API ключа")
+)
+
+var apiKeyScopes = []string{middleware.ScopeRead, middleware.ScopeUpload, middleware.ScopeAdmin}
+
+// API keys let scripts authenticate without a password. Only a SHA-256 hash
+// of the key is stored; the key itself is returned once on creation. A key
+// acts with the current role of its owner, limited by its scopes, and stops
+// working when the owner is suspended or deleted.
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func (s *AuthService) CreateAPIKey(ctx context.Context, userID uuid.UUID, req *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !slices.Contains(apiKeyScopes, scope) {
+			return nil, ErrUnknownAPIKeyScope
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	slices.Sort(scopes)
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrAPIKeyExpiry
+	}
+
+	user, err := s.queries.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	if slices.Contains(scopes, middleware.ScopeAdmin) && user.Role != RoleAdmin {
+		return nil, ErrAdminScopeDenied
+	}
+
+	count, err := s.queries.CountUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось посчитать API ключи: %w", err)
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, ErrAPIKeyLimit
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать API ключ")
+	}
+
+	created, err := s.queries.CreateAPIKey(ctx, sqlc.CreateAPIKeyParams{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyDisplayLength],
+		KeyHash:   hashAPIKey(key),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сохранить API ключ")
+	}
+
+	return &CreateAPIKeyResponse{
+		APIKeyResponse: newAPIKeyResponse(created),
+		Key:            key,
+	}, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]*APIKeyResponse, error) {
+	keys, err := s.queries.ListUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список API ключей: %w", err)
+	}
+
+	result := make([]*APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, newAPIKeyResponse(key))
+	}
+
+	return result, nil
+}
+
+func (s *AuthService) DeleteAPIKey(ctx context.Context, userID, keyID uuid.UUID) error {
+	deleted, err := s.queries.DeleteAPIKey(ctx, sqlc.DeleteAPIKeyParams{
+		ID:     keyID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось удалить API ключ: %w", err)
+	}
+	if deleted == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey implements middleware.APIKeyAuthenticator.
+func (s *AuthService) AuthenticateAPIKey(ctx context.Context, key string) (*jwt.CustomClaims, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	stored, err := s.queries.GetAPIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("не удалось получить API ключ: %w", err)
+	}
+
+	now := time.Now()
+	if stored.ExpiresAt != nil && !stored.ExpiresAt.After(now) {
+		return nil, ErrInvalidAPIKey
+	}
+	if stored.SuspendedAt != nil {
+		return nil, ErrUserSuspended
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= apiKeyTouchEvery {
+		if err := s.queries.TouchAPIKey(ctx, sqlc.TouchAPIKeyParams{
+			ID:         stored.ID,
+			LastUsedAt: &now,
+		}); err != nil {
+			s.logger.Error("Failed to update api key last use", err)
+		}
+	}
+
+	return &jwt.CustomClaims{
+		UserID:   stored.UserID,
+		Email:    stored.Email,
+		Name:     stored.Name,
+		Role:     stored.Role,
+		APIKeyID: stored.ID,
+		Scopes:   stored.Scopes,
+	}, nil
+}
+
+func newAPIKeyResponse(key sqlc.ApiKey) *APIKeyResponse {
+	return &APIKeyResponse{
+		ID:         key.ID.String(),
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
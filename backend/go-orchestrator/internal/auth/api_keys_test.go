@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		role        string
+		scopes      []string
+		expiresAt   *time.Time
+		keys        int64
+		expectedErr error
+	}{
+		{name: "read key", role: RoleUser, scopes: []string{"read"}},
+		{name: "expiring key", role: RoleUser, scopes: []string{"upload", "read", "read"}, expiresAt: &future},
+		{name: "admin key for an admin", role: RoleAdmin, scopes: []string{"admin"}},
+		{name: "admin key for a user", role: RoleUser, scopes: []string{"read", "admin"}, expectedErr: ErrAdminScopeDenied},
+		{name: "unknown scope", role: RoleUser, scopes: []string{"write"}, expectedErr: ErrUnknownAPIKeyScope},
+		{name: "expiry in the past", role: RoleUser, scopes: []string{"read"}, expiresAt: &past, expectedErr: ErrAPIKeyExpiry},
+		{name: "too many keys", role: RoleUser, scopes: []string{"read"}, keys: maxAPIKeysPerUser, expectedErr: ErrAPIKeyLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored *sqlc.CreateAPIKeyParams
+			queries := &mockQueries{
+				getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+					return sqlc.User{ID: id, Role: tt.role}, nil
+				},
+				countAPIKeysFunc: func(ctx context.Context, userID uuid.UUID) (int64, error) {
+					return tt.keys, nil
+				},
+				createAPIKeyFunc: func(ctx context.Context, params sqlc.CreateAPIKeyParams) (sqlc.ApiKey, error) {
+					stored = &params
+					return sqlc.ApiKey{ID: uuid.New(), Prefix: params.Prefix, Scopes: params.Scopes}, nil
+				},
+			}
+			service := &AuthService{queries: queries}
+
+			response, err := service.CreateAPIKey(context.Background(), uuid.New(), &CreateAPIKeyRequest{
+				Name:      "ci",
+				Scopes:    tt.scopes,
+				ExpiresAt: tt.expiresAt,
+			})
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if stored != nil {
+					t.Error("no key must be stored on error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(response.Key, apiKeyPrefix) || !strings.HasPrefix(response.Key, response.Prefix) {
+				t.Errorf("unexpected key %q with prefix %q", response.Key, response.Prefix)
+			}
+			if stored.KeyHash != hashAPIKey(response.Key) || strings.Contains(stored.KeyHash, response.Key) {
+				t.Error("only the hash of the key must be stored")
+			}
+			for i := 1; i < len(stored.Scopes); i++ {
+				if stored.Scopes[i-1] >= stored.Scopes[i] {
+					t.Errorf("expected sorted unique scopes, got %v", stored.Scopes)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	const key = apiKeyPrefix + "secret"
+	userID := uuid.New()
+	keyID := uuid.New()
+	past := time.Now().Add(-time.Hour)
+	recent := time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name        string
+		key         string
+		expiresAt   *time.Time
+		suspendedAt *time.Time
+		lastUsedAt  *time.Time
+		expectedErr error
+		expectTouch bool
+	}{
+		{name: "valid key", key: key, expectTouch: true},
+		{name: "recently used key", key: key, lastUsedAt: &recent},
+		{name: "key used long ago", key: key, lastUsedAt: &past, expectTouch: true},
+		{name: "unknown key", key: apiKeyPrefix + "other", expectedErr: ErrInvalidAPIKey},
+		{name: "foreign key format", key: "secret", expectedErr: ErrInvalidAPIKey},
+		{name: "expired key", key: key, expiresAt: &past, expectedErr: ErrInvalidAPIKey},
+		{name: "suspended owner", key: key, suspendedAt: &past, expectedErr: ErrUserSuspended},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			touched := false
+			queries := &mockQueries{
+				getAPIKeyByHashFunc: func(ctx context.Context, keyHash string) (sqlc.GetAPIKeyByHashRow, error) {
+					if keyHash != hashAPIKey(key) {
+						return sqlc.GetAPIKeyByHashRow{}, pgx.ErrNoRows
+					}
+					return sqlc.GetAPIKeyByHashRow{
+						ID:          keyID,
+						UserID:      userID,
+						Scopes:      []string{"read"},
+						ExpiresAt:   tt.expiresAt,
+						LastUsedAt:  tt.lastUsedAt,
+						Email:       "user@example.com",
+						Role:        RoleUser,
+						SuspendedAt: tt.suspendedAt,
+					}, nil
+				},
+				touchAPIKeyFunc: func(ctx context.Context, params sqlc.TouchAPIKeyParams) error {
+					touched = true
+					return nil
+				},
+			}
+			service := &AuthService{queries: queries}
+
+			claims, err := service.AuthenticateAPIKey(context.Background(), tt.key)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if claims.UserID != userID || claims.APIKeyID != keyID || claims.Role != RoleUser {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+			if touched != tt.expectTouch {
+				t.Errorf("expected touched=%v, got %v", tt.expectTouch, touched)
+			}
+		})
+	}
+}
+
+func TestDeleteAPIKeyOfAnotherUser(t *testing.T) {
+	ownerID := uuid.New()
+	keyID := uuid.New()
+	queries := &mockQueries{
+		deleteAPIKeyFunc: func(ctx context.Context, params sqlc.DeleteAPIKeyParams) (int64, error) {
+			if params.ID == keyID && params.UserID == ownerID {
+				return 1, nil
+			}
+			return 0, nil
+		},
+	}
+	service := &AuthService{queries: queries}
+
+	if err := service.DeleteAPIKey(context.Background(), uuid.New(), keyID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Fatalf("expected %v, got %v", ErrAPIKeyNotFound, err)
+	}
+	if err := service.DeleteAPIKey(context.Background(), ownerID, keyID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
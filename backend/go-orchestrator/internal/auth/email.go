@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	tokenPurposeEmailVerification = "email_verification"
+	tokenPurposePasswordReset     = "password_reset"
+)
+
+// mailTimeout bounds the delivery of a single email.
+const mailTimeout = 30 * time.Second
+
+var (
+	ErrInvalidEmailLink     = errors.New("ссылка недействительна или устарела")
+	ErrEmailAlreadyVerified = errors.New("email уже подтвержден")
+)
+
+// Email verification and password reset send a single-use link to the
+// address of the account. Only the SHA-256 hash of the token in the link is
+// stored; it is deleted on first use, and issuing a new link of the same kind
+// invalidates the previous one.
+
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sendVerificationEmail issues a verification link for the user.
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user sqlc.User) error {
+	token, err := s.issueUserToken(ctx, s.queries, user.ID, tokenPurposeEmailVerification, s.cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Подтверждение email",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы подтвердить email и начать загружать фото, перейдите по ссылке:\n%s\n\nСсылка действует до %s.\n",
+			user.Name, s.emailLink("/verify-email", token), time.Now().Add(s.cfg.EmailVerificationTTL).Format("02.01.2006 15:04 MST")),
+	})
+	return nil
+}
+
+// ResendVerificationEmail sends a new verification link to the user.
+func (s *AuthService) ResendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.queries.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// VerifyEmail confirms the email of the account the link was sent to.
+func (s *AuthService) VerifyEmail(ctx context.Context, rawToken string) error {
+	token, err := s.consumeUserToken(ctx, s.queries, rawToken, tokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.queries.MarkEmailVerified(ctx, sqlc.MarkEmailVerifiedParams{
+		ID:              token.UserID,
+		EmailVerifiedAt: &now,
+	}); err != nil {
+		return fmt.Errorf("не удалось отметить email подтвержденным: %w", err)
+	}
+
+	s.logger.Info("Email verified", map[string]interface{}{
+		"user_id": token.UserID.String(),
+	})
+	return nil
+}
+
+// ForgotPassword sends a password reset link if an active account has the
+// email. The outcome is never reported, so the endpoint cannot be used to
+// find out which emails are registered.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.queries.GetByEmail(ctx, normalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	if user.SuspendedAt != nil {
+		return nil
+	}
+
+	token, err := s.issueUserToken(ctx, s.queries, user.ID, tokenPurposePasswordReset, s.cfg.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы задать новый пароль, перейдите по ссылке:\n%s\n\nСсылка действует до %s. Если вы не запрашивали сброс пароля, просто проигнорируйте это письмо.\n",
+			user.Name, s.emailLink("/reset-password", token), time.Now().Add(s.cfg.PasswordResetTTL).Format("02.01.2006 15:04 MST")),
+	})
+	return nil
+}
+
+// ResetPassword sets a new password with a reset link and ends every session
+// of the account, since whoever knew the old password may still be signed in.
+// The link proves access to the mailbox, so the email counts as verified.
+func (s *AuthService) ResetPassword(ctx context.Context, req *ResetPasswordRequest) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("ошибка обработки пароля")
+	}
+
+	var userID uuid.UUID
+	err = s.withTx(ctx, func(q QueriesInterface) error {
+		token, err := s.consumeUserToken(ctx, q, req.Token, tokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		if err := q.UpdateUserPassword(ctx, sqlc.UpdateUserPasswordParams{
+			ID:           userID,
+			PasswordHash: string(hashedPassword),
+		}); err != nil {
+			return fmt.Errorf("не удалось обновить пароль: %w", err)
+		}
+
+		now := time.Now()
+		if err := q.MarkEmailVerified(ctx, sqlc.MarkEmailVerifiedParams{
+			ID:              userID,
+			EmailVerifiedAt: &now,
+		}); err != nil {
+			return fmt.Errorf("не удалось отметить email подтвержденным: %w", err)
+		}
+
+		if err := q.DeleteUserTokens(ctx, sqlc.DeleteUserTokensParams{
+			UserID:  userID,
+			Purpose: tokenPurposePasswordReset,
+		}); err != nil {
+			return fmt.Errorf("не удалось удалить токены сброса пароля: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	s.logger.Info("Password reset", map[string]interface{}{
+		"user_id": userID.String(),
+	})
+	return nil
+}
+
+func (s *AuthService) issueUserToken(ctx context.Context, q QueriesInterface, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", fmt.Errorf("не удалось сгенерировать токен: %w", err)
+	}
+
+	if err := q.DeleteUserTokens(ctx, sqlc.DeleteUserTokensParams{
+		UserID:  userID,
+		Purpose: purpose,
+	}); err != nil {
+		return "", fmt.Errorf("не удалось удалить предыдущие токены: %w", err)
+	}
+	if err := q.CreateUserToken(ctx, sqlc.CreateUserTokenParams{
+		TokenHash: hashUserToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return "", fmt.Errorf("не удалось сохранить токен: %w", err)
+	}
+
+	return token, nil
+}
+
+func (s *AuthService) consumeUserToken(ctx context.Context, q QueriesInterface, rawToken, purpose string) (sqlc.UserToken, error) {
+	token, err := q.ConsumeUserToken(ctx, sqlc.ConsumeUserTokenParams{
+		TokenHash: hashUserToken(rawToken),
+		Purpose:   purpose,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.UserToken{}, ErrInvalidEmailLink
+		}
+		return sqlc.UserToken{}, fmt.Errorf("не удалось получить токен: %w", err)
+	}
+	if !token.ExpiresAt.After(time.Now()) {
+		return sqlc.UserToken{}, ErrInvalidEmailLink
+	}
+	return token, nil
+}
+
+func (s *AuthService) emailLink(path, token string) string {
+	return s.cfg.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendMail delivers in the background: the response must neither wait for
+// the SMTP server nor reveal by its timing whether an email was sent.
+func (s *AuthService) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			s.logger.Error("Failed to send email", err, map[string]interface{}{
+				"subject": msg.Subject,
+			})
+		}
+	}()
+}
+
+func (s *AuthService) deleteExpiredUserTokens(ctx context.Context) {
+	if err := s.queries.DeleteExpiredUserTokens(ctx, time.Now()); err != nil {
+		s.logger.Error("Failed to delete expired user tokens", err)
+	}
+}
@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/mailer"
+)
+
+type mockMailer struct {
+	sent chan mailer.Message
+}
+
+func newMockMailer() *mockMailer {
+	return &mockMailer{sent: make(chan mailer.Message, 10)}
+}
+
+func (m *mockMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+// link waits for the next message and returns the token of its link.
+func (m *mockMailer) link(t *testing.T) string {
+	t.Helper()
+	select {
+	case msg := <-m.sent:
+		match := regexp.MustCompile(`\?token=(\S+)`).FindStringSubmatch(msg.Body)
+		if match == nil {
+			t.Fatalf("no link in message %q", msg.Body)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatalf("invalid link token: %v", err)
+		}
+		return token
+	case <-time.After(time.Second):
+		t.Fatal("no email sent")
+		return ""
+	}
+}
+
+// withUserTokenStore backs the email link queries with a map, applying the
+// same conditions as the SQL.
+func (m *mockQueries) withUserTokenStore() map[string]sqlc.UserToken {
+	store := make(map[string]sqlc.UserToken)
+
+	m.createUserTokenFunc = func(ctx context.Context, params sqlc.CreateUserTokenParams) error {
+		store[params.TokenHash] = sqlc.UserToken{
+			TokenHash: params.TokenHash,
+			UserID:    params.UserID,
+			Purpose:   params.Purpose,
+			ExpiresAt: params.ExpiresAt,
+		}
+		return nil
+	}
+	m.consumeUserTokenFunc = func(ctx context.Context, params sqlc.ConsumeUserTokenParams) (sqlc.UserToken, error) {
+		token, ok := store[params.TokenHash]
+		if !ok || token.Purpose != params.Purpose {
+			return sqlc.UserToken{}, pgx.ErrNoRows
+		}
+		delete(store, params.TokenHash)
+		return token, nil
+	}
+	m.deleteUserTokensFunc = func(ctx context.Context, params sqlc.DeleteUserTokensParams) error {
+		for hash, token := range store {
+			if token.UserID == params.UserID && token.Purpose == params.Purpose {
+				delete(store, hash)
+			}
+		}
+		return nil
+	}
+	return store
+}
+
+func newTestEmailService(queries *mockQueries) (*AuthService, *mockMailer) {
+	service := newTestRefreshService(queries)
+	service.mailer = newMockMailer()
+	service.logger = nopLogger{}
+	service.cfg = config.AuthConfig{
+		AppURL:               "https://app.example.com",
+		EmailVerificationTTL: time.Hour,
+		PasswordResetTTL:     time.Hour,
+	}
+	return service, service.mailer.(*mockMailer)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	user := sqlc.User{ID: uuid.New(), Email: "user@example.com", Name: "User"}
+	queries := &mockQueries{
+		createFunc: func(ctx context.Context, params sqlc.CreateParams) (sqlc.User, error) {
+			if params.EmailVerifiedAt != nil {
+				t.Error("a registered email must start unverified")
+			}
+			return user, nil
+		},
+		getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+			return user, nil
+		},
+	}
+	queries.withUserTokenStore()
+	var verified []uuid.UUID
+	queries.markEmailVerifiedFunc = func(ctx context.Context, params sqlc.MarkEmailVerifiedParams) error {
+		verified = append(verified, params.ID)
+		return nil
+	}
+	service, mails := newTestEmailService(queries)
+	ctx := context.Background()
+
+	result, err := service.CreateUser(ctx, &CreateUserRequest{Email: user.Email, Password: "password123", Name: user.Name})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.User.EmailVerified {
+		t.Error("expected the new user to be unverified")
+	}
+
+	// Resending replaces the first link.
+	first := mails.link(t)
+	if err := service.ResendVerificationEmail(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second := mails.link(t)
+	if err := service.VerifyEmail(ctx, first); !errors.Is(err, ErrInvalidEmailLink) {
+		t.Errorf("expected %v for a replaced link, got %v", ErrInvalidEmailLink, err)
+	}
+
+	if err := service.VerifyEmail(ctx, second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(verified) != 1 || verified[0] != user.ID {
+		t.Errorf("expected the user to be verified, got %v", verified)
+	}
+	if err := service.VerifyEmail(ctx, second); !errors.Is(err, ErrInvalidEmailLink) {
+		t.Errorf("expected %v for a used link, got %v", ErrInvalidEmailLink, err)
+	}
+
+	now := time.Now()
+	queries.getByIDFunc = func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+		verifiedUser := user
+		verifiedUser.EmailVerifiedAt = &now
+		return verifiedUser, nil
+	}
+	if err := service.ResendVerificationEmail(ctx, user.ID); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Errorf("expected %v, got %v", ErrEmailAlreadyVerified, err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	user := sqlc.User{ID: uuid.New(), Email: "user@example.com", Name: "User"}
+	queries := &mockQueries{
+		getByEmailFunc: func(ctx context.Context, email string) (sqlc.User, error) {
+			if email != user.Email {
+				return sqlc.User{}, pgx.ErrNoRows
+			}
+			return user, nil
+		},
+	}
+	store := queries.withUserTokenStore()
+	var passwordHash string
+	queries.updateUserPasswordFunc = func(ctx context.Context, params sqlc.UpdateUserPasswordParams) error {
+		passwordHash = params.PasswordHash
+		return nil
+	}
+	service, mails := newTestEmailService(queries)
+	ctx := context.Background()
+
+	if err := service.ForgotPassword(ctx, "nobody@example.com"); err != nil {
+		t.Fatalf("unknown emails must not be reported: %v", err)
+	}
+	if len(store) != 0 {
+		t.Error("expected no link for an unknown email")
+	}
+
+	if err := service.ForgotPassword(ctx, user.Email); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	token := mails.link(t)
+
+	if err := service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, Password: "new-password"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if passwordHash == "" || passwordHash == "new-password" {
+		t.Error("expected the new password to be stored hashed")
+	}
+	if revoker := service.revocations.(*mockRevoker); len(revoker.revokedUsers) != 1 || revoker.revokedUsers[0] != user.ID {
+		t.Error("expected the sessions of the user to be revoked")
+	}
+	if err := service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, Password: "other-password"}); !errors.Is(err, ErrInvalidEmailLink) {
+		t.Errorf("expected %v for a used link, got %v", ErrInvalidEmailLink, err)
+	}
+
+	// An expired link is rejected.
+	if err := service.ForgotPassword(ctx, user.Email); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	token = mails.link(t)
+	expired := store[hashUserToken(token)]
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	store[hashUserToken(token)] = expired
+	if err := service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, Password: "other-password"}); !errors.Is(err, ErrInvalidEmailLink) {
+		t.Errorf("expected %v for an expired link, got %v", ErrInvalidEmailLink, err)
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	addr := listener.Addr().(*net.TCPAddr)
+	m := mailer.NewSMTPMailer(&config.MailConfig{
+		SMTPHost: "127.0.0.1",
+		SMTPPort: addr.Port,
+		From:     "no-reply@example.com",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = m.Send(ctx, mailer.Message{
+		To:      "user@example.com",
+		Subject: "Сброс пароля",
+		Body:    "Ссылка:\nhttps://app.example.com/reset-password?token=abc",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	commands := <-received
+	data := strings.Join(commands, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<no-reply@example.com>",
+		"RCPT TO:<user@example.com>",
+		"To: user@example.com",
+		"Subject: =?utf-8?q?",
+		"https://app.example.com/reset-password?token=3Dabc",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("expected %q in the session:\n%s", want, data)
+		}
+	}
+
+	err = m.Send(ctx, mailer.Message{To: "user@example.com\r\nBcc: other@example.com", Subject: "Hi"})
+	if err == nil {
+		t.Error("expected header injection to be rejected")
+	}
+}
+
+// serveSMTP accepts a single SMTP session and reports the lines the client
+// sent, including the message data.
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(code int, text string) {
+		conn.Write([]byte(strconv.Itoa(code) + " " + text + "\r\n"))
+	}
+
+	var lines []string
+	reply(220, "localhost ESMTP")
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			received <- lines
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+
+		if inData {
+			if line == "." {
+				inData = false
+				reply(250, "OK")
+			}
+			continue
+		}
+		switch command := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); command {
+		case "EHLO", "HELO":
+			reply(250, "localhost")
+		case "DATA":
+			inData = true
+			reply(354, "go ahead")
+		case "QUIT":
+			reply(221, "bye")
+			received <- lines
+			return
+		default:
+			reply(250, "OK")
+		}
+	}
+}
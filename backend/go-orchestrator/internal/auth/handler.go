@@ -1,12 +1,23 @@
 package auth
 
 import (
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
 	"github.com/skr1ms/dev2gis/pkg/middleware"
 )
 
+// oidcStateCookie binds a single sign-on login to the browser that started
+// it, so a callback URL crafted by someone else cannot sign the user in to
+// the attacker's account.
+const oidcStateCookie = "oidc_state"
+
 type AuthHandler struct {
 	authService *AuthService
 	logger      middleware.LoggerInterface
@@ -19,13 +30,72 @@ func NewAuthHandler(authService *AuthService, logger middleware.LoggerInterface)
 	}
 }
 
-func (h *AuthHandler) RegisterRoutes(r *gin.RouterGroup) {
+func (h *AuthHandler) RegisterRoutes(r *gin.RouterGroup, jwtMiddleware *middleware.JWTMiddleware) {
 	auth := r.Group("auth")
 	{
 		auth.POST("/register", h.CreateUser)
 		auth.POST("/login", h.LoginUser)
+		auth.POST("/login/2fa", h.CompleteTwoFactorLogin)
+		auth.POST("/login/2fa/setup", h.SetupTwoFactorForLogin)
 		auth.POST("/refresh", h.RefreshTokens)
-		auth.POST("/logout", h.Logout)
+		auth.POST("/logout", jwtMiddleware.OptionalAuth(), h.Logout)
+		auth.POST("/logout-all", jwtMiddleware.RequireAuth(), jwtMiddleware.SessionOnly(), h.LogoutAll)
+		auth.POST("/email/verify", h.VerifyEmail)
+		auth.POST("/email/resend", jwtMiddleware.RequireAuth(), jwtMiddleware.SessionOnly(), h.ResendVerificationEmail)
+		auth.POST("/password/forgot", h.ForgotPassword)
+		auth.POST("/password/reset", h.ResetPassword)
+	}
+
+	sso := r.Group("auth/oidc/:provider")
+	{
+		sso.GET("/login", h.StartOIDCLogin)
+		sso.GET("/callback", h.CompleteOIDCLogin)
+	}
+
+	twoFactor := r.Group("auth/2fa")
+	twoFactor.Use(jwtMiddleware.RequireAuth(), jwtMiddleware.SessionOnly())
+	{
+		twoFactor.POST("/setup", h.SetupTwoFactor)
+		twoFactor.POST("/confirm", h.ConfirmTwoFactor)
+		twoFactor.POST("/disable", h.DisableTwoFactor)
+		twoFactor.POST("/recovery-codes", h.RegenerateRecoveryCodes)
+	}
+
+	apiKeys := r.Group("auth/api-keys")
+	apiKeys.Use(jwtMiddleware.RequireAuth(), jwtMiddleware.SessionOnly())
+	{
+		apiKeys.POST("", h.CreateAPIKey)
+		apiKeys.GET("", h.ListAPIKeys)
+		apiKeys.DELETE("/:id", h.DeleteAPIKey)
+	}
+
+	me := r.Group("users/me")
+	me.Use(jwtMiddleware.RequireAuth(), jwtMiddleware.SessionOnly())
+	{
+		me.GET("", h.GetProfile)
+		me.PATCH("", h.UpdateProfile)
+		me.DELETE("", h.DeleteAccount)
+		me.POST("/password", h.ChangePassword)
+	}
+
+	admin := r.Group("admin/users")
+	admin.Use(jwtMiddleware.RequireAuth(), jwtMiddleware.AdminOnly())
+	{
+		admin.GET("", h.ListUsers)
+		admin.DELETE("/:id", h.DeleteUser)
+		admin.POST("/:id/suspend", h.SuspendUser)
+		admin.POST("/:id/unsuspend", h.UnsuspendUser)
+		admin.PUT("/:id/role", h.SetUserRole)
+		admin.POST("/:id/revoke-sessions", h.RevokeUserSessions)
+		admin.DELETE("/:id/two-factor", h.ResetUserTwoFactor)
+		admin.POST("/:id/unlock", h.UnlockUserLogin)
+	}
+
+	policy := r.Group("admin/two-factor")
+	policy.Use(jwtMiddleware.RequireAuth(), jwtMiddleware.AdminOnly())
+	{
+		policy.GET("", h.GetTwoFactorPolicy)
+		policy.PUT("", h.SetTwoFactorPolicy)
 	}
 }
 
@@ -63,13 +133,15 @@ func (h *AuthHandler) CreateUser(c *gin.Context) {
 }
 
 // @Summary User Login
-// @Description Authenticate user and return JWT tokens
+// @Description Authenticate user and return JWT tokens. If the user has two-factor authentication, or their role requires it, only a challenge is returned in two_factor; complete it at /api/auth/login/2fa
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body LoginUserRequest true "User login request"
 // @Success 200 {object} LoginResponse
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
 // @Router /api/auth/login [post]
 func (h *AuthHandler) LoginUser(c *gin.Context) {
 	var req LoginUserRequest
@@ -84,7 +156,21 @@ func (h *AuthHandler) LoginUser(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.LoginUser(c.Request.Context(), &req)
+	response, err := h.authService.LoginUser(c.Request.Context(), &req, c.ClientIP())
+	var throttled *LoginThrottledError
+	if errors.As(err, &throttled) {
+		retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       err.Error(),
+			"retry_after": retryAfter,
+		})
+		return
+	}
+	if errors.Is(err, ErrUserSuspended) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": err.Error(),
@@ -103,6 +189,8 @@ func (h *AuthHandler) LoginUser(c *gin.Context) {
 // @Param request body RefreshRequest true "Token refresh request"
 // @Success 200 {object} LoginResponse
 // @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
 // @Router /api/auth/refresh [post]
 func (h *AuthHandler) RefreshTokens(c *gin.Context) {
 	var req RefreshRequest
@@ -119,9 +207,7 @@ func (h *AuthHandler) RefreshTokens(c *gin.Context) {
 
 	tokens, err := h.authService.RefreshTokens(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
-		})
+		h.respondRefreshTokenError(c, err)
 		return
 	}
 
@@ -129,20 +215,1200 @@ func (h *AuthHandler) RefreshTokens(c *gin.Context) {
 }
 
 // @Summary User Logout
-// @Description Logout user and invalidate token
+// @Description Revoke the refresh token and every token rotated from the same login. The access token, if sent, is revoked too.
 // @Tags auth
 // @Accept json
 // @Produce json
+// @Param request body LogoutRequest true "Logout request"
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
 // @Router /api/auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
-	id, _ := middleware.GetUserID(c)
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Warn("Invalid logout request", map[string]interface{}{
+			"error": err.Error(),
+			"ip":    c.ClientIP(),
+		})
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Некорректные данные запроса",
+		})
+		return
+	}
+
+	claims, _ := middleware.GetClaims(c)
+	if err := h.authService.Logout(c.Request.Context(), &req, claims); err != nil {
+		h.respondRefreshTokenError(c, err)
+		return
+	}
+
 	h.logger.Info("User logged out", map[string]interface{}{
-		"user_id": id,
-		"ip":      c.ClientIP(),
+		"ip": c.ClientIP(),
 	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Вы успешно вышли из системы",
 	})
 }
+
+// @Summary Logout All Sessions
+// @Description Revoke every refresh token of the user and every access token issued so far, including the one used for this request
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /api/auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	if err := h.authService.RevokeUserSessions(c.Request.Context(), claims.UserID); err != nil {
+		h.respondSessionsError(c, err)
+		return
+	}
+
+	h.logger.Info("User logged out of all sessions", map[string]interface{}{
+		"user_id": claims.UserID.String(),
+		"ip":      c.ClientIP(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Все сеансы завершены",
+	})
+}
+
+// @Summary Verify Email
+// @Description Confirm the email of an account with the single-use token from the verification link
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyEmailRequest true "Email verification request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/auth/email/verify [post]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Warn("Invalid verify email request", map[string]interface{}{
+			"error": err.Error(),
+			"ip":    c.ClientIP(),
+		})
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Некорректные данные запроса",
+		})
+		return
+	}
+
+	if err := h.authService.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		h.respondEmailLinkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email подтвержден",
+	})
+}
+
+// @Summary Resend Verification Email
+// @Description Send a new verification link to the email of the current user. The previous link stops working
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/auth/email/resend [post]
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	err := h.authService.ResendVerificationEmail(c.Request.Context(), claims.UserID)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	default:
+		h.logger.Error("Failed to resend verification email", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Письмо со ссылкой для подтверждения отправлено",
+	})
+}
+
+// @Summary Forgot Password
+// @Description Send a password reset link to the email if an account has it. The response is the same whether or not the email is registered
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Password reset link request"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Warn("Invalid forgot password request", map[string]interface{}{
+			"error": err.Error(),
+			"ip":    c.ClientIP(),
+		})
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Некорректные данные запроса",
+		})
+		return
+	}
+
+	// A failure is only logged: reporting it would tell the caller that the
+	// email is registered.
+	if err := h.authService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		h.logger.Error("Failed to issue password reset link", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Если аккаунт с таким email существует, на него отправлена ссылка для сброса пароля",
+	})
+}
+
+// @Summary Reset Password
+// @Description Set a new password with the single-use token from the reset link. Every session of the account is ended
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Password reset request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Warn("Invalid reset password request", map[string]interface{}{
+			"error": err.Error(),
+			"ip":    c.ClientIP(),
+		})
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Некорректные данные запроса",
+		})
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), &req); err != nil {
+		h.respondEmailLinkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Пароль изменен, войдите с новым паролем",
+	})
+}
+
+// @Summary Complete Two-Factor Login
+// @Description Finish a login with the challenge token and a code from the authenticator app or a recovery code. For an enrollment challenge the code confirms the secret from /api/auth/login/2fa/setup and the response also carries the recovery codes
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorLoginRequest true "Two-factor login request"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/auth/login/2fa [post]
+func (h *AuthHandler) CompleteTwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	response, err := h.authService.CompleteTwoFactorLogin(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			h.logger.Warn("Invalid two-factor code", map[string]interface{}{
+				"ip": c.ClientIP(),
+			})
+		}
+		h.respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Set Up Two-Factor Login
+// @Description Get a new authenticator app secret for a login whose challenge requires enrollment
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorChallengeRequest true "Two-factor challenge"
+// @Success 200 {object} TwoFactorSetupResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/auth/login/2fa/setup [post]
+func (h *AuthHandler) SetupTwoFactorForLogin(c *gin.Context) {
+	var req TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	response, err := h.authService.SetupTwoFactorForLogin(c.Request.Context(), req.ChallengeToken)
+	if err != nil {
+		h.respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Set Up Two-Factor Authentication
+// @Description Generate a secret for an authenticator app. Two-factor authentication is enabled once a code is confirmed at /api/auth/2fa/confirm
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} TwoFactorSetupResponse
+// @Failure 401 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/auth/2fa/setup [post]
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	response, err := h.authService.SetupTwoFactor(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Confirm Two-Factor Authentication
+// @Description Enable two-factor authentication with a code from the app. The recovery codes are returned only in this response
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "Code from the authenticator app"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/auth/2fa/confirm [post]
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	claims, req, ok := h.bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.authService.ConfirmTwoFactor(c.Request.Context(), claims.UserID, req.Code)
+	if err != nil {
+		h.respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Disable Two-Factor Authentication
+// @Description Turn two-factor authentication off with a current code or a recovery code. Not allowed while the role of the user requires it
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "Code from the authenticator app or a recovery code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/auth/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	claims, req, ok := h.bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.DisableTwoFactor(c.Request.Context(), claims.UserID, req.Code); err != nil {
+		h.respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Двухфакторная аутентификация отключена",
+	})
+}
+
+// @Summary Regenerate Recovery Codes
+// @Description Replace the recovery codes after checking a current code. The previous codes stop working
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "Code from the authenticator app or a recovery code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/auth/2fa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	claims, req, ok := h.bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), claims.UserID, req.Code)
+	if err != nil {
+		h.respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Reset User Two-Factor Authentication
+// @Description Admin only. Remove the second factor of a user who lost the device and the recovery codes
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/two-factor [delete]
+func (h *AuthHandler) ResetUserTwoFactor(c *gin.Context) {
+	userID, actor, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.ResetUserTwoFactor(c.Request.Context(), userID); err != nil {
+		h.respondTwoFactorError(c, err)
+		return
+	}
+
+	h.logger.Warn("Two-factor authentication reset by admin", map[string]interface{}{
+		"user_id":  userID.String(),
+		"admin_id": actor.UserID.String(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Двухфакторная аутентификация пользователя сброшена",
+	})
+}
+
+// @Summary Unlock User Login
+// @Description Admin only. Lift the delay or lockout of password logins for the email of a user after failed attempts. Lockouts of client IPs expire by themselves
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/unlock [post]
+func (h *AuthHandler) UnlockUserLogin(c *gin.Context) {
+	userID, actor, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.UnlockUserLogin(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to unlock user login", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось снять блокировку входа"})
+		return
+	}
+
+	h.logger.Warn("Login lockout lifted by admin", map[string]interface{}{
+		"event":    "login_unlock",
+		"user_id":  userID.String(),
+		"admin_id": actor.UserID.String(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Блокировка входа снята",
+	})
+}
+
+// @Summary Get Two-Factor Policy
+// @Description Admin only. Roles that must use two-factor authentication
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} TwoFactorPolicy
+// @Failure 403 {object} map[string]interface{}
+// @Router /api/admin/two-factor [get]
+func (h *AuthHandler) GetTwoFactorPolicy(c *gin.Context) {
+	roles, err := h.authService.TwoFactorRequiredRoles(c.Request.Context())
+	if err != nil {
+		h.respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorPolicy{RequiredRoles: roles})
+}
+
+// @Summary Set Two-Factor Policy
+// @Description Admin only. Require two-factor authentication for the operator and/or admin roles. Applies from the next password login of each user
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorPolicy true "Roles that must use two-factor authentication"
+// @Success 200 {object} TwoFactorPolicy
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /api/admin/two-factor [put]
+func (h *AuthHandler) SetTwoFactorPolicy(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	var req TwoFactorPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	roles, err := h.authService.SetTwoFactorRequiredRoles(c.Request.Context(), req.RequiredRoles)
+	if err != nil {
+		h.respondTwoFactorError(c, err)
+		return
+	}
+
+	h.logger.Warn("Two-factor policy changed", map[string]interface{}{
+		"required_roles": roles,
+		"admin_id":       claims.UserID.String(),
+	})
+
+	c.JSON(http.StatusOK, TwoFactorPolicy{RequiredRoles: roles})
+}
+
+// @Summary Revoke User Sessions
+// @Description Admin only. Immediately end every session of a user
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/revoke-sessions [post]
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return
+	}
+
+	if err := h.authService.RevokeUserSessions(c.Request.Context(), userID); err != nil {
+		h.respondSessionsError(c, err)
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	h.logger.Warn("User sessions revoked by admin", map[string]interface{}{
+		"user_id":  userID.String(),
+		"admin_id": adminID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Все сеансы пользователя завершены",
+	})
+}
+
+// @Summary Set User Role
+// @Description Admin only. Promote or demote a user. A demotion ends every session of the user
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body SetRoleRequest true "New role"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/role [put]
+func (h *AuthHandler) SetUserRole(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return
+	}
+
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	user, err := h.authService.SetUserRole(c.Request.Context(), userID, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrLastAdmin):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			h.respondSessionsError(c, err)
+		}
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	h.logger.Warn("User role changed by admin", map[string]interface{}{
+		"user_id":  userID.String(),
+		"role":     user.Role,
+		"admin_id": adminID,
+	})
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary List Users
+// @Description Admin only. List every user, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param role query string false "Role"
+// @Param suspended query bool false "Only suspended (true) or active (false) users"
+// @Param email query string false "Part of the email address"
+// @Param limit query int false "Limit" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /api/admin/users [get]
+func (h *AuthHandler) ListUsers(c *gin.Context) {
+	filter := UserFilter{
+		Role:  c.Query("role"),
+		Email: c.Query("email"),
+	}
+	if suspendedStr := c.Query("suspended"); suspendedStr != "" {
+		suspended, err := strconv.ParseBool(suspendedStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректное значение параметра suspended"})
+			return
+		}
+		filter.Suspended = &suspended
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	users, err := h.authService.ListUsers(c.Request.Context(), filter, int32(limit), int32(offset))
+	if err != nil {
+		if errors.Is(err, ErrInvalidRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":  users,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// @Summary Suspend User
+// @Description Admin only. Block sign-in for the user and end every session. Jobs of the user are kept
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} AdminUserResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/suspend [post]
+func (h *AuthHandler) SuspendUser(c *gin.Context) {
+	userID, actor, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.SuspendUser(c.Request.Context(), actor.UserID, userID)
+	if err != nil {
+		h.respondAdminUserError(c, err)
+		return
+	}
+
+	h.logger.Warn("User suspended by admin", map[string]interface{}{
+		"user_id":  userID.String(),
+		"admin_id": actor.UserID.String(),
+	})
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Unsuspend User
+// @Description Admin only. Let a suspended user sign in again
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} AdminUserResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/admin/users/{id}/unsuspend [post]
+func (h *AuthHandler) UnsuspendUser(c *gin.Context) {
+	userID, actor, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.UnsuspendUser(c.Request.Context(), userID)
+	if err != nil {
+		h.respondAdminUserError(c, err)
+		return
+	}
+
+	h.logger.Warn("User unsuspended by admin", map[string]interface{}{
+		"user_id":  userID.String(),
+		"admin_id": actor.UserID.String(),
+	})
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Delete User
+// @Description Admin only. Delete the user together with their jobs and webhooks
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/admin/users/{id} [delete]
+func (h *AuthHandler) DeleteUser(c *gin.Context) {
+	userID, actor, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.DeleteUser(c.Request.Context(), actor.UserID, userID); err != nil {
+		h.respondAdminUserError(c, err)
+		return
+	}
+
+	h.logger.Warn("User deleted by admin", map[string]interface{}{
+		"user_id":  userID.String(),
+		"admin_id": actor.UserID.String(),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Start SSO Login
+// @Description Redirect the browser to the OpenID Connect provider to sign in
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Success 302
+// @Failure 404 {object} map[string]interface{}
+// @Failure 502 {object} map[string]interface{}
+// @Router /api/auth/oidc/{provider}/login [get]
+func (h *AuthHandler) StartOIDCLogin(c *gin.Context) {
+	provider := c.Param("provider")
+
+	authURL, state, err := h.authService.StartOIDCLogin(c.Request.Context(), provider)
+	if err != nil {
+		if errors.Is(err, ErrUnknownOIDCProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to start OIDC login", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": ErrOIDCLoginFailed.Error()})
+		return
+	}
+
+	// Lax lets the cookie through the top-level redirect back from the
+	// provider.
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, 0, oidcCookiePath(provider), "", isHTTPS(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary Complete SSO Login
+// @Description Callback of the OpenID Connect provider. Signs the user in, creating the account on first login, and returns tokens, or a two-factor challenge as the password login does
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "Login state"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/auth/oidc/{provider}/callback [get]
+func (h *AuthHandler) CompleteOIDCLogin(c *gin.Context) {
+	provider := c.Param("provider")
+
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath(provider), "", isHTTPS(c), true)
+
+	if providerError := c.Query("error"); providerError != "" {
+		h.logger.Warn("OIDC provider returned an error", map[string]interface{}{
+			"provider":    provider,
+			"error":       providerError,
+			"description": c.Query("error_description"),
+		})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrOIDCLoginFailed.Error()})
+		return
+	}
+
+	state := c.Query("state")
+	if state == "" || cookie != state {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidOIDCState.Error()})
+		return
+	}
+
+	response, err := h.authService.CompleteOIDCLogin(c.Request.Context(), provider, c.Query("code"), state)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownOIDCProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrInvalidOIDCState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrOIDCLoginFailed), errors.Is(err, ErrOIDCEmailMissing):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrOIDCEmailUnverified):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrUserSuspended):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("Failed to complete OIDC login", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if response.TwoFactor == nil {
+		h.logger.Info("User logged in with OIDC", map[string]interface{}{
+			"user_id":  response.User.ID,
+			"provider": provider,
+			"ip":       c.ClientIP(),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func oidcCookiePath(provider string) string {
+	return "/api/auth/oidc/" + provider
+}
+
+func isHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+// @Summary Create API Key
+// @Description Create a personal API key for scripts. The key is returned only in this response; send it in the X-API-Key header
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateAPIKeyRequest true "API key request"
+// @Success 201 {object} CreateAPIKeyResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/auth/api-keys [post]
+func (h *AuthHandler) CreateAPIKey(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	response, err := h.authService.CreateAPIKey(c.Request.Context(), claims.UserID, &req)
+	if err != nil {
+		h.respondAPIKeyError(c, err)
+		return
+	}
+
+	h.logger.Info("API key created", map[string]interface{}{
+		"user_id":    claims.UserID.String(),
+		"api_key_id": response.ID,
+		"scopes":     response.Scopes,
+	})
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// @Summary List API Keys
+// @Description List personal API keys of the current user without the keys themselves
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /api/auth/api-keys [get]
+func (h *AuthHandler) ListAPIKeys(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	keys, err := h.authService.ListAPIKeys(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// @Summary Delete API Key
+// @Description Revoke a personal API key of the current user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "API key ID"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/auth/api-keys/{id} [delete]
+func (h *AuthHandler) DeleteAPIKey(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID API ключа"})
+		return
+	}
+
+	if err := h.authService.DeleteAPIKey(c.Request.Context(), claims.UserID, keyID); err != nil {
+		h.respondAPIKeyError(c, err)
+		return
+	}
+
+	h.logger.Info("API key deleted", map[string]interface{}{
+		"user_id":    claims.UserID.String(),
+		"api_key_id": keyID.String(),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Get Profile
+// @Description Get the account of the current user
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} ProfileResponse
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /api/users/me [get]
+func (h *AuthHandler) GetProfile(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	profile, err := h.authService.GetProfile(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.respondProfileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Update Profile
+// @Description Change the name or email of the current user. A new email has to be verified again and needs current_password if the account has a password
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateProfileRequest true "Profile changes"
+// @Success 200 {object} ProfileResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/users/me [patch]
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	profile, err := h.authService.UpdateProfile(c.Request.Context(), claims.UserID, &req)
+	if err != nil {
+		h.respondProfileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Change Password
+// @Description Set a new password after checking the current one. Every other session of the user is ended; the returned tokens replace those of this session
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangePasswordRequest true "Password change request"
+// @Success 200 {object} jwt.TokenPair
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/users/me/password [post]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	tokens, err := h.authService.ChangePassword(c.Request.Context(), claims.UserID, &req)
+	if err != nil {
+		h.respondProfileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary Delete Account
+// @Description Delete the account of the current user with its jobs and webhooks. Uploaded and generated files are deleted in the background. Accounts with a password must confirm it
+// @Tags users
+// @Accept json
+// @Security BearerAuth
+// @Param request body DeleteAccountRequest false "Password confirmation"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/users/me [delete]
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	// The body is optional: accounts created by single sign-on have no
+	// password to confirm.
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	if err := h.authService.DeleteAccount(c.Request.Context(), claims.UserID, req.Password); err != nil {
+		h.respondProfileError(c, err)
+		return
+	}
+
+	h.logger.Warn("User deleted their account", map[string]interface{}{
+		"event":   "account_deleted",
+		"user_id": claims.UserID.String(),
+		"ip":      c.ClientIP(),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// adminTarget reads the user ID from the path and the claims of the admin
+// acting on it.
+func adminTarget(c *gin.Context) (uuid.UUID, *jwt.CustomClaims, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return uuid.Nil, nil, false
+	}
+
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return uuid.Nil, nil, false
+	}
+
+	return userID, claims, true
+}
+
+func (h *AuthHandler) bindTwoFactorCode(c *gin.Context) (*jwt.CustomClaims, TwoFactorCodeRequest, bool) {
+	var req TwoFactorCodeRequest
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return nil, req, false
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return nil, req, false
+	}
+	return claims, req, true
+}
+
+func (h *AuthHandler) respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidTwoFactorChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserSuspended):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTwoFactorEnabled), errors.Is(err, ErrTwoFactorNotEnabled),
+		errors.Is(err, ErrTwoFactorNotSetUp), errors.Is(err, ErrTwoFactorRequired):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("Failed to process two-factor authentication", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *AuthHandler) respondAdminUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrSelfAction), errors.Is(err, ErrLastAdmin), errors.Is(err, ErrLastOrganizationOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.respondSessionsError(c, err)
+	}
+}
+
+func (h *AuthHandler) respondProfileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidCurrentPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrEmailTaken), errors.Is(err, ErrPasswordNotSet), errors.Is(err, ErrLastAdmin), errors.Is(err, ErrLastOrganizationOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("Failed to manage profile", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *AuthHandler) respondAPIKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrAPIKeyExpiry), errors.Is(err, ErrUnknownAPIKeyScope):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrAdminScopeDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrAPIKeyNotFound), errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrAPIKeyLimit):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("Failed to manage api keys", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *AuthHandler) respondSessionsError(c *gin.Context, err error) {
+	if errors.Is(err, ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Error("Failed to revoke user sessions", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func (h *AuthHandler) respondEmailLinkError(c *gin.Context, err error) {
+	if errors.Is(err, ErrInvalidEmailLink) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Error("Failed to process email link", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func (h *AuthHandler) respondRefreshTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrRefreshTokenReused):
+		h.logger.Warn("Refresh token reuse detected, session revoked", map[string]interface{}{
+			"ip": c.ClientIP(),
+		})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidRefreshToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserSuspended):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("Failed to process refresh token", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
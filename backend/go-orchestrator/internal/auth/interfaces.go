@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
@@ -13,9 +14,94 @@ type QueriesInterface interface {
 	GetByID(ctx context.Context, id uuid.UUID) (sqlc.User, error)
 	GetByEmail(ctx context.Context, email string) (sqlc.User, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	UpdateUserRole(ctx context.Context, params sqlc.UpdateUserRoleParams) (sqlc.User, error)
+	CountUsersByRole(ctx context.Context, role string) (int64, error)
+	ListUsers(ctx context.Context, params sqlc.ListUsersParams) ([]sqlc.User, error)
+	UpdateUserSuspension(ctx context.Context, params sqlc.UpdateUserSuspensionParams) (sqlc.User, error)
+	UpdateUserPassword(ctx context.Context, params sqlc.UpdateUserPasswordParams) error
+	UpdateUserProfile(ctx context.Context, params sqlc.UpdateUserProfileParams) (sqlc.User, error)
+	ScheduleUserStorageDeletion(ctx context.Context, userID uuid.UUID) error
+	CountOrganizationsLastOwnedByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	DeleteUserSoleOrganizations(ctx context.Context, userID uuid.UUID) error
+	DeleteUserPersonalProjects(ctx context.Context, createdBy *uuid.UUID) error
+	MarkEmailVerified(ctx context.Context, params sqlc.MarkEmailVerifiedParams) error
+
+	CreateUserToken(ctx context.Context, params sqlc.CreateUserTokenParams) error
+	ConsumeUserToken(ctx context.Context, params sqlc.ConsumeUserTokenParams) (sqlc.UserToken, error)
+	DeleteUserTokens(ctx context.Context, params sqlc.DeleteUserTokensParams) error
+	DeleteExpiredUserTokens(ctx context.Context, expiresAt time.Time) error
+
+	CreateRefreshToken(ctx context.Context, params sqlc.CreateRefreshTokenParams) error
+	GetRefreshToken(ctx context.Context, id uuid.UUID) (sqlc.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, params sqlc.RotateRefreshTokenParams) (int64, error)
+	RevokeRefreshTokenFamily(ctx context.Context, params sqlc.RevokeRefreshTokenFamilyParams) error
+	RevokeUserRefreshTokens(ctx context.Context, params sqlc.RevokeUserRefreshTokensParams) error
+	DeleteExpiredRefreshTokens(ctx context.Context, expiresAt time.Time) error
+
+	CreateAPIKey(ctx context.Context, params sqlc.CreateAPIKeyParams) (sqlc.ApiKey, error)
+	ListUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]sqlc.ApiKey, error)
+	CountUserAPIKeys(ctx context.Context, userID uuid.UUID) (int64, error)
+	DeleteAPIKey(ctx context.Context, params sqlc.DeleteAPIKeyParams) (int64, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (sqlc.GetAPIKeyByHashRow, error)
+	TouchAPIKey(ctx context.Context, params sqlc.TouchAPIKeyParams) error
+
+	CreateOIDCLoginState(ctx context.Context, params sqlc.CreateOIDCLoginStateParams) error
+	ConsumeOIDCLoginState(ctx context.Context, state string) (sqlc.OidcLoginState, error)
+	DeleteExpiredOIDCLoginStates(ctx context.Context, expiresAt time.Time) error
+	GetUserByIdentity(ctx context.Context, params sqlc.GetUserByIdentityParams) (sqlc.User, error)
+	CreateUserIdentity(ctx context.Context, params sqlc.CreateUserIdentityParams) error
+
+	GetUserTOTP(ctx context.Context, userID uuid.UUID) (sqlc.UserTotp, error)
+	UpsertUserTOTP(ctx context.Context, params sqlc.UpsertUserTOTPParams) error
+	ConfirmUserTOTP(ctx context.Context, params sqlc.ConfirmUserTOTPParams) (int64, error)
+	UseTOTPStep(ctx context.Context, params sqlc.UseTOTPStepParams) (int64, error)
+	DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error
+	CreateRecoveryCode(ctx context.Context, params sqlc.CreateRecoveryCodeParams) error
+	UseRecoveryCode(ctx context.Context, params sqlc.UseRecoveryCodeParams) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error
+	CreateTwoFactorChallenge(ctx context.Context, params sqlc.CreateTwoFactorChallengeParams) error
+	GetTwoFactorChallenge(ctx context.Context, tokenHash string) (sqlc.TwoFactorChallenge, error)
+	RecordTwoFactorChallengeFailure(ctx context.Context, tokenHash string) (int32, error)
+	DeleteTwoFactorChallenge(ctx context.Context, tokenHash string) (int64, error)
+	DeleteExpiredTwoFactorChallenges(ctx context.Context, expiresAt time.Time) error
+	ListTwoFactorRequiredRoles(ctx context.Context) ([]string, error)
+	IsTwoFactorRequired(ctx context.Context, role string) (bool, error)
+	AddTwoFactorRequiredRole(ctx context.Context, role string) error
+	DeleteTwoFactorRequiredRoles(ctx context.Context) error
+
+	CountLoginAttempt(ctx context.Context, params sqlc.CountLoginAttemptParams) (int32, error)
+	GetLoginAttempt(ctx context.Context, params sqlc.GetLoginAttemptParams) (sqlc.LoginAttempt, error)
+	BlockLogin(ctx context.Context, params sqlc.BlockLoginParams) error
+	ForgiveLoginAttempt(ctx context.Context, params sqlc.ForgiveLoginAttemptParams) error
+	DeleteLoginAttempts(ctx context.Context, params sqlc.DeleteLoginAttemptsParams) (int64, error)
+	DeleteExpiredLoginAttempts(ctx context.Context, params sqlc.DeleteExpiredLoginAttemptsParams) error
+}
+
+type RevocationQueriesInterface interface {
+	RevokeAccessToken(ctx context.Context, params sqlc.RevokeAccessTokenParams) error
+	RevokeUserAccessTokens(ctx context.Context, params sqlc.RevokeUserAccessTokensParams) error
+	ListRevokedAccessTokens(ctx context.Context, expiresAt time.Time) ([]sqlc.RevokedAccessToken, error)
+	ListUserTokenRevocations(ctx context.Context, revokedBefore time.Time) ([]sqlc.UserTokenRevocation, error)
+	DeleteExpiredAccessTokenRevocations(ctx context.Context, expiresAt time.Time) error
+	DeleteUserTokenRevocationsBefore(ctx context.Context, revokedBefore time.Time) error
+}
+
+type SigningKeyQueriesInterface interface {
+	AdvisoryXactLock(ctx context.Context, key int64) error
+	CreateSigningKey(ctx context.Context, params sqlc.CreateSigningKeyParams) error
+	ListSigningKeys(ctx context.Context, expiresAt time.Time) ([]sqlc.JwtSigningKey, error)
+	DeleteExpiredSigningKeys(ctx context.Context, expiresAt time.Time) error
+}
+
+// TokenRevokerInterface is the write side of RevocationStore.
+type TokenRevokerInterface interface {
+	RevokeToken(ctx context.Context, claims *jwt.CustomClaims) error
+	RevokeUser(ctx context.Context, userID uuid.UUID) error
+	RevokeUserBefore(ctx context.Context, userID uuid.UUID, before time.Time) error
 }
 
 type JWTServiceInterface interface {
 	GenerateTokenPair(userID uuid.UUID, email, name, role string) (*jwt.TokenPair, error)
-	RefreshTokens(refreshToken string) (*jwt.TokenPair, error)
+	RotateTokenPair(claims *jwt.CustomClaims) (*jwt.TokenPair, error)
+	ValidateRefreshToken(refreshToken string) (*jwt.CustomClaims, error)
 }
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/metrics"
+)
+
+const (
+	loginScopeEmail = "email"
+	loginScopeIP    = "ip"
+
+	// loginDelayBase is the wait after the second attempt in a row without a
+	// successful login. It doubles with each further attempt up to
+	// maxLoginDelay, until the limit locks the login out.
+	loginDelayBase = time.Second
+	maxLoginDelay  = 30 * time.Second
+)
+
+var ErrTooManyLoginAttempts = errors.New("слишком много попыток входа, повторите позже")
+
+// LoginThrottledError is returned while password logins for an email or from
+// a client IP are delayed or locked out. It matches ErrTooManyLoginAttempts.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrTooManyLoginAttempts.Error()
+}
+
+func (e *LoginThrottledError) Is(target error) bool {
+	return target == ErrTooManyLoginAttempts
+}
+
+// Password logins are throttled per email and per client IP. The counters
+// live in Postgres, so every replica sees the same attempts and blocks.
+//
+// An attempt is counted before the password is checked, and a block is set
+// right after, so guesses sent in parallel are throttled as well as
+// sequential ones. A successful login clears the counter of the email and
+// takes its own attempt back from the IP, so a shared address is only
+// charged for failures. An email is counted whether or not an account has
+// it, so the responses do not reveal which accounts exist.
+
+// loginAttempt is an attempt counted against an email or a client IP.
+type loginAttempt struct {
+	scope    string
+	subject  string
+	attempts int32
+}
+
+// admitLogin counts a password login attempt for the client IP and the
+// email, or returns a LoginThrottledError if either is blocked.
+func (s *AuthService) admitLogin(ctx context.Context, email, clientIP string) ([]loginAttempt, error) {
+	now := time.Now()
+	attempts := []loginAttempt{
+		{scope: loginScopeIP, subject: clientIP},
+		{scope: loginScopeEmail, subject: normalizeEmail(email)},
+	}
+
+	for i := range attempts {
+		count, err := s.queries.CountLoginAttempt(ctx, sqlc.CountLoginAttemptParams{
+			Scope:        attempts[i].scope,
+			Subject:      attempts[i].subject,
+			AttemptedAt:  now,
+			ForgetBefore: now.Add(-s.cfg.LoginLockoutDuration),
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, s.loginThrottled(ctx, attempts[i], now)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("не удалось учесть попытку входа: %w", err)
+		}
+		attempts[i].attempts = count
+
+		if delay := loginDelay(count); delay > 0 {
+			if err := s.blockLogin(ctx, attempts[i], now.Add(delay)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return attempts, nil
+}
+
+// loginFailed locks out the email and the IP once they reach their limits.
+// A limit of zero disables the lockout.
+func (s *AuthService) loginFailed(ctx context.Context, attempts []loginAttempt) {
+	for _, attempt := range attempts {
+		limit := s.loginLimit(attempt.scope)
+		if limit <= 0 || int(attempt.attempts) < limit {
+			continue
+		}
+
+		lockedUntil := time.Now().Add(s.cfg.LoginLockoutDuration)
+		if err := s.blockLogin(ctx, attempt, lockedUntil); err != nil {
+			s.logger.Error("Failed to lock out login", err, map[string]interface{}{
+				"scope":   attempt.scope,
+				"subject": attempt.subject,
+			})
+			continue
+		}
+
+		metrics.RecordLoginLockout(attempt.scope)
+		s.logger.Warn("Login locked out after failed attempts", map[string]interface{}{
+			"event":        "login_lockout",
+			"scope":        attempt.scope,
+			"subject":      attempt.subject,
+			"attempts":     attempt.attempts,
+			"locked_until": lockedUntil,
+		})
+	}
+}
+
+// loginSucceeded clears the attempts of the email and forgives the attempt
+// of the IP. A failure here only leaves a counter that expires by itself.
+func (s *AuthService) loginSucceeded(ctx context.Context, attempts []loginAttempt) {
+	for _, attempt := range attempts {
+		var err error
+		if attempt.scope == loginScopeEmail {
+			_, err = s.queries.DeleteLoginAttempts(ctx, sqlc.DeleteLoginAttemptsParams{
+				Scope:   attempt.scope,
+				Subject: attempt.subject,
+			})
+		} else {
+			err = s.queries.ForgiveLoginAttempt(ctx, sqlc.ForgiveLoginAttemptParams{
+				Scope:   attempt.scope,
+				Subject: attempt.subject,
+			})
+		}
+		if err != nil {
+			s.logger.Error("Failed to reset login attempts", err, map[string]interface{}{
+				"scope": attempt.scope,
+			})
+		}
+	}
+}
+
+// UnlockUserLogin lifts the delay or lockout of password logins for the
+// email of a user. Lockouts of client IPs expire by themselves.
+func (s *AuthService) UnlockUserLogin(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.queries.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+
+	if _, err := s.queries.DeleteLoginAttempts(ctx, sqlc.DeleteLoginAttemptsParams{
+		Scope:   loginScopeEmail,
+		Subject: normalizeEmail(user.Email),
+	}); err != nil {
+		return fmt.Errorf("не удалось удалить попытки входа: %w", err)
+	}
+	return nil
+}
+
+func (s *AuthService) loginThrottled(ctx context.Context, attempt loginAttempt, now time.Time) error {
+	stored, err := s.queries.GetLoginAttempt(ctx, sqlc.GetLoginAttemptParams{
+		Scope:   attempt.scope,
+		Subject: attempt.subject,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("не удалось получить попытки входа: %w", err)
+	}
+
+	// The block may have ended or been lifted since it stopped the attempt.
+	retryAfter := stored.BlockedUntil.Sub(now)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &LoginThrottledError{RetryAfter: retryAfter}
+}
+
+func (s *AuthService) blockLogin(ctx context.Context, attempt loginAttempt, until time.Time) error {
+	if err := s.queries.BlockLogin(ctx, sqlc.BlockLoginParams{
+		BlockedUntil: until,
+		Scope:        attempt.scope,
+		Subject:      attempt.subject,
+	}); err != nil {
+		return fmt.Errorf("не удалось заблокировать вход: %w", err)
+	}
+	return nil
+}
+
+func (s *AuthService) loginLimit(scope string) int {
+	if scope == loginScopeIP {
+		return s.cfg.LoginMaxFailuresPerIP
+	}
+	return s.cfg.LoginMaxFailures
+}
+
+func (s *AuthService) deleteExpiredLoginAttempts(ctx context.Context) {
+	now := time.Now()
+	if err := s.queries.DeleteExpiredLoginAttempts(ctx, sqlc.DeleteExpiredLoginAttemptsParams{
+		BlockedUntil:  now,
+		LastAttemptAt: now.Add(-s.cfg.LoginLockoutDuration),
+	}); err != nil {
+		s.logger.Error("Failed to delete expired login attempts", err)
+	}
+}
+
+// loginDelay is how long the next attempt has to wait after the given number
+// of attempts in a row.
+func loginDelay(attempts int32) time.Duration {
+	if attempts < 2 {
+		return 0
+	}
+	shift := attempts - 2
+	if shift > 5 {
+		return maxLoginDelay
+	}
+	return min(loginDelayBase<<shift, maxLoginDelay)
+}
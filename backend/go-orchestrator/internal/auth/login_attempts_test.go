@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+// withLoginAttemptStore backs the login attempt queries with a map, applying
+// the same conditions as the SQL.
+func (m *mockQueries) withLoginAttemptStore() map[string]*sqlc.LoginAttempt {
+	store := make(map[string]*sqlc.LoginAttempt)
+	key := func(scope, subject string) string {
+		return scope + "/" + subject
+	}
+
+	m.countLoginAttemptFunc = func(ctx context.Context, params sqlc.CountLoginAttemptParams) (int32, error) {
+		attempt, ok := store[key(params.Scope, params.Subject)]
+		if !ok {
+			store[key(params.Scope, params.Subject)] = &sqlc.LoginAttempt{
+				Scope:         params.Scope,
+				Subject:       params.Subject,
+				Attempts:      1,
+				LastAttemptAt: params.AttemptedAt,
+				BlockedUntil:  params.AttemptedAt,
+			}
+			return 1, nil
+		}
+		if attempt.BlockedUntil.After(params.AttemptedAt) {
+			return 0, pgx.ErrNoRows
+		}
+		if attempt.LastAttemptAt.Before(params.ForgetBefore) {
+			attempt.Attempts = 1
+		} else {
+			attempt.Attempts++
+		}
+		attempt.LastAttemptAt = params.AttemptedAt
+		return attempt.Attempts, nil
+	}
+	m.getLoginAttemptFunc = func(ctx context.Context, params sqlc.GetLoginAttemptParams) (sqlc.LoginAttempt, error) {
+		attempt, ok := store[key(params.Scope, params.Subject)]
+		if !ok {
+			return sqlc.LoginAttempt{}, pgx.ErrNoRows
+		}
+		return *attempt, nil
+	}
+	m.blockLoginFunc = func(ctx context.Context, params sqlc.BlockLoginParams) error {
+		if attempt, ok := store[key(params.Scope, params.Subject)]; ok && params.BlockedUntil.After(attempt.BlockedUntil) {
+			attempt.BlockedUntil = params.BlockedUntil
+		}
+		return nil
+	}
+	m.forgiveLoginAttemptFunc = func(ctx context.Context, params sqlc.ForgiveLoginAttemptParams) error {
+		if attempt, ok := store[key(params.Scope, params.Subject)]; ok && attempt.Attempts > 0 {
+			attempt.Attempts--
+		}
+		return nil
+	}
+	m.deleteLoginAttemptsFunc = func(ctx context.Context, params sqlc.DeleteLoginAttemptsParams) (int64, error) {
+		if _, ok := store[key(params.Scope, params.Subject)]; !ok {
+			return 0, nil
+		}
+		delete(store, key(params.Scope, params.Subject))
+		return 1, nil
+	}
+	return store
+}
+
+func newTestLoginAttemptService(t *testing.T, user sqlc.User) (*AuthService, map[string]*sqlc.LoginAttempt) {
+	t.Helper()
+
+	queries := &mockQueries{
+		getByEmailFunc: func(ctx context.Context, email string) (sqlc.User, error) {
+			if email != user.Email {
+				return sqlc.User{}, pgx.ErrNoRows
+			}
+			return user, nil
+		},
+		getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+			if id != user.ID {
+				return sqlc.User{}, pgx.ErrNoRows
+			}
+			return user, nil
+		},
+	}
+	store := queries.withLoginAttemptStore()
+
+	service := newTestRefreshService(queries)
+	service.logger = nopLogger{}
+	service.cfg = config.AuthConfig{
+		LoginMaxFailures:      3,
+		LoginMaxFailuresPerIP: 10,
+		LoginLockoutDuration:  15 * time.Minute,
+	}
+	return service, store
+}
+
+// endDelay lets the next attempt through without waiting out the delay.
+func endDelay(store map[string]*sqlc.LoginAttempt, key string) {
+	if attempt, ok := store[key]; ok {
+		attempt.BlockedUntil = time.Now().Add(-time.Millisecond)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	user := sqlc.User{ID: uuid.New(), Email: "user@example.com", Name: "User", Role: RoleUser, PasswordHash: hashPassword(t, "password123")}
+	service, store := newTestLoginAttemptService(t, user)
+	ctx := context.Background()
+	wrong := &LoginUserRequest{Email: user.Email, Password: "wrong-password"}
+	right := &LoginUserRequest{Email: user.Email, Password: "password123"}
+
+	if _, err := service.LoginUser(ctx, wrong, "192.0.2.1"); err == nil || errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	if _, err := service.LoginUser(ctx, wrong, "192.0.2.1"); err == nil || errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+
+	// From the second attempt on the next one has to wait, even with the
+	// right password and from another address.
+	_, err := service.LoginUser(ctx, right, "192.0.2.2")
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter > loginDelayBase {
+		t.Fatalf("expected a delay of up to %v, got %v", loginDelayBase, err)
+	}
+
+	endDelay(store, "email/user@example.com")
+	endDelay(store, "ip/192.0.2.1")
+	if _, err := service.LoginUser(ctx, &LoginUserRequest{Email: " User@Example.com ", Password: "wrong-password"}, "192.0.2.1"); err == nil || errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+
+	// The third failure locks the email out.
+	_, err = service.LoginUser(ctx, right, "192.0.2.2")
+	if !errors.As(err, &throttled) || throttled.RetryAfter < 14*time.Minute {
+		t.Fatalf("expected a lockout, got %v", err)
+	}
+
+	if err := service.UnlockUserLogin(ctx, uuid.New()); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+	if err := service.UnlockUserLogin(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := service.LoginUser(ctx, right, "192.0.2.3"); err != nil {
+		t.Fatalf("expected the login to work after unlock, got %v", err)
+	}
+	if _, ok := store["email/user@example.com"]; ok {
+		t.Error("expected a successful login to clear the attempts of the email")
+	}
+}
+
+func TestLoginLockoutPerIP(t *testing.T) {
+	user := sqlc.User{ID: uuid.New(), Email: "user@example.com", Name: "User", Role: RoleUser, PasswordHash: hashPassword(t, "password123")}
+	service, store := newTestLoginAttemptService(t, user)
+	ctx := context.Background()
+
+	// Successful logins do not add up on a shared address.
+	for i := 0; i < 2*service.cfg.LoginMaxFailuresPerIP; i++ {
+		if _, err := service.LoginUser(ctx, &LoginUserRequest{Email: user.Email, Password: "password123"}, "192.0.2.1"); err != nil {
+			t.Fatalf("login %d: unexpected error: %v", i, err)
+		}
+	}
+	if attempts := store["ip/192.0.2.1"].Attempts; attempts != 0 {
+		t.Errorf("expected successful logins to be forgiven, got %d attempts", attempts)
+	}
+
+	// Guessing across many emails locks the address out.
+	for i := 0; i < service.cfg.LoginMaxFailuresPerIP; i++ {
+		endDelay(store, "ip/192.0.2.1")
+		email := uuid.NewString() + "@example.com"
+		if _, err := service.LoginUser(ctx, &LoginUserRequest{Email: email, Password: "password123"}, "192.0.2.1"); err == nil || errors.Is(err, ErrTooManyLoginAttempts) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i, err)
+		}
+	}
+	_, err := service.LoginUser(ctx, &LoginUserRequest{Email: user.Email, Password: "password123"}, "192.0.2.1")
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter < 14*time.Minute {
+		t.Fatalf("expected the address to be locked out, got %v", err)
+	}
+	if _, err := service.LoginUser(ctx, &LoginUserRequest{Email: user.Email, Password: "password123"}, "192.0.2.2"); err != nil {
+		t.Errorf("expected other addresses to log in, got %v", err)
+	}
+}
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 0},
+		{attempts: 2, expected: time.Second},
+		{attempts: 4, expected: 4 * time.Second},
+		{attempts: 7, expected: maxLoginDelay},
+		{attempts: 100, expected: maxLoginDelay},
+	}
+
+	for _, tt := range tests {
+		if delay := loginDelay(tt.attempts); delay != tt.expected {
+			t.Errorf("loginDelay(%d) = %v, expected %v", tt.attempts, delay, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+	"github.com/skr1ms/dev2gis/pkg/oidc"
+)
+
+// oidcRequestTimeout bounds each call to an identity provider.
+const oidcRequestTimeout = 10 * time.Second
+
+var (
+	ErrUnknownOIDCProvider = errors.New("неизвестный провайдер входа")
+	ErrInvalidOIDCState    = errors.New("недействительный или истекший запрос входа, начните вход заново")
+	ErrOIDCLoginFailed     = errors.New("не удалось войти через провайдера")
+	ErrOIDCEmailMissing    = errors.New("провайдер не передал email")
+	ErrOIDCEmailUnverified = errors.New("email не подтвержден провайдером")
+)
+
+// OIDCClient is the part of an OpenID Connect provider the login flow uses.
+type OIDCClient interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (string, error)
+	Verify(ctx context.Context, rawIDToken, nonce string) (*oidc.IDToken, error)
+}
+
+type oidcProvider struct {
+	client     OIDCClient
+	roleClaim  string
+	roleMap    map[string]string
+	mfaMethods map[string]bool
+}
+
+// Single sign-on follows the authorization code flow with PKCE. The state,
+// nonce and code verifier of a started login are kept in the database, so
+// the callback may land on any replica, and are consumed on first use. An
+// identity is linked to a user by provider and subject; on first sign-in it
+// is linked to the account with the same verified email, or a new account
+// without a password is created. Tokens are then issued as for a password
+// login, including the two-factor challenge, unless the provider is trusted
+// to have checked a second factor and says so in the amr claim.
+
+func newOIDCProviders(providers []config.OIDCProviderConfig, logger middleware.LoggerInterface) map[string]*oidcProvider {
+	client := &http.Client{Timeout: oidcRequestTimeout}
+
+	result := make(map[string]*oidcProvider, len(providers))
+	for _, cfg := range providers {
+		roleMap := make(map[string]string, len(cfg.RoleMap))
+		for value, role := range cfg.RoleMap {
+			if _, ok := roleRanks[role]; !ok {
+				logger.Warn("Ignoring OIDC role mapping to an unknown role", map[string]interface{}{
+					"provider": cfg.Name,
+					"role":     role,
+				})
+				continue
+			}
+			roleMap[value] = role
+		}
+
+		mfaMethods := make(map[string]bool, len(cfg.MFAMethods))
+		for _, method := range cfg.MFAMethods {
+			mfaMethods[method] = true
+		}
+
+		result[cfg.Name] = &oidcProvider{
+			client: oidc.NewProvider(oidc.Config{
+				Issuer:       cfg.Issuer,
+				ClientID:     cfg.ClientID,
+				ClientSecret: cfg.ClientSecret,
+				RedirectURL:  cfg.RedirectURL,
+				Scopes:       cfg.Scopes,
+			}, client),
+			roleClaim:  cfg.RoleClaim,
+			roleMap:    roleMap,
+			mfaMethods: mfaMethods,
+		}
+	}
+	return result
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// StartOIDCLogin returns the provider URL to send the browser to and the
+// state the callback must bring back.
+func (s *AuthService) StartOIDCLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return "", "", ErrUnknownOIDCProvider
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := randomToken()
+		if err != nil {
+			return "", "", fmt.Errorf("не удалось сгенерировать state для входа: %w", err)
+		}
+		values[i] = value
+	}
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+
+	authURL, err := provider.client.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", fmt.Errorf("не удалось сформировать адрес авторизации: %w", err)
+	}
+
+	if err := s.queries.CreateOIDCLoginState(ctx, sqlc.CreateOIDCLoginStateParams{
+		State:        state,
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(s.cfg.OIDCStateTTL),
+	}); err != nil {
+		return "", "", fmt.Errorf("не удалось сохранить state для входа: %w", err)
+	}
+
+	return authURL, state, nil
+}
+
+// CompleteOIDCLogin handles the provider callback: it redeems the code,
+// verifies the ID token and signs the linked user in, or returns a two-factor
+// challenge as LoginUser does.
+func (s *AuthService) CompleteOIDCLogin(ctx context.Context, providerName, code, state string) (*LoginResponse, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	stored, err := s.queries.ConsumeOIDCLoginState(ctx, state)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, fmt.Errorf("не удалось получить state для входа: %w", err)
+	}
+	if stored.Provider != providerName || !stored.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	rawIDToken, err := provider.client.Exchange(ctx, code, stored.CodeVerifier)
+	if err != nil {
+		s.logger.Warn("OIDC code exchange failed", map[string]interface{}{
+			"provider": providerName,
+			"error":    err.Error(),
+		})
+		return nil, ErrOIDCLoginFailed
+	}
+
+	idToken, err := provider.client.Verify(ctx, rawIDToken, stored.Nonce)
+	if err != nil {
+		s.logger.Warn("OIDC id token rejected", map[string]interface{}{
+			"provider": providerName,
+			"error":    err.Error(),
+		})
+		return nil, ErrOIDCLoginFailed
+	}
+
+	var user sqlc.User
+	err = s.withTx(ctx, func(q QueriesInterface) error {
+		user, err = s.oidcUser(ctx, q, providerName, provider, idToken)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if user.SuspendedAt != nil {
+		return nil, ErrUserSuspended
+	}
+
+	if !provider.assertsMFA(idToken.Claims) {
+		challenge, err := s.twoFactorChallenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if challenge != nil {
+			return &LoginResponse{TwoFactor: challenge}, nil
+		}
+	}
+
+	tokens, err := s.jwtService.GenerateTokenPair(user.ID, user.Email, user.Name, user.Role)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать токены")
+	}
+
+	if err := s.storeRefreshToken(ctx, s.queries, user.ID, tokens); err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		User:   newUserResponse(user),
+		Tokens: tokens,
+	}, nil
+}
+
+// oidcUser finds or provisions the user of an identity and applies the role
+// mapping of the provider.
+func (s *AuthService) oidcUser(ctx context.Context, q QueriesInterface, providerName string, provider *oidcProvider, idToken *oidc.IDToken) (sqlc.User, error) {
+	user, err := q.GetUserByIdentity(ctx, sqlc.GetUserByIdentityParams{
+		Provider: providerName,
+		Subject:  idToken.Subject,
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.User{}, fmt.Errorf("не удалось получить учетную запись провайдера: %w", err)
+		}
+		if user, err = s.linkOIDCIdentity(ctx, q, providerName, idToken); err != nil {
+			return sqlc.User{}, err
+		}
+	}
+
+	if provider.roleClaim == "" {
+		return user, nil
+	}
+
+	role := provider.mapRole(idToken.Claims)
+	if role == user.Role {
+		return user, nil
+	}
+
+	if user.Role == RoleAdmin && user.SuspendedAt == nil {
+		admins, err := q.CountUsersByRole(ctx, RoleAdmin)
+		if err != nil {
+			return sqlc.User{}, fmt.Errorf("не удалось посчитать администраторов: %w", err)
+		}
+		if admins <= 1 {
+			s.logger.Warn("Keeping the last admin despite the OIDC role mapping", map[string]interface{}{
+				"user_id":  user.ID.String(),
+				"provider": providerName,
+			})
+			return user, nil
+		}
+	}
+
+	// Other sessions of the user pick the new role up on their next refresh.
+	updated, err := q.UpdateUserRole(ctx, sqlc.UpdateUserRoleParams{ID: user.ID, Role: role})
+	if err != nil {
+		return sqlc.User{}, fmt.Errorf("не удалось изменить роль пользователя: %w", err)
+	}
+
+	s.logger.Info("User role synced from OIDC claims", map[string]interface{}{
+		"user_id":       user.ID.String(),
+		"provider":      providerName,
+		"previous_role": user.Role,
+		"role":          role,
+	})
+	return updated, nil
+}
+
+// linkOIDCIdentity links a first-seen identity to the account with its email,
+// or creates one. Both require the provider to vouch for the email: otherwise
+// anyone able to register that address at the provider could take an
+// existing account over, or claim the address for a new one.
+func (s *AuthService) linkOIDCIdentity(ctx context.Context, q QueriesInterface, providerName string, idToken *oidc.IDToken) (sqlc.User, error) {
+	if idToken.Email == "" {
+		return sqlc.User{}, ErrOIDCEmailMissing
+	}
+	if !idToken.EmailVerified {
+		return sqlc.User{}, ErrOIDCEmailUnverified
+	}
+
+	email := normalizeEmail(idToken.Email)
+	now := time.Now()
+
+	user, err := q.GetByEmail(ctx, email)
+	switch {
+	case err == nil:
+		if user.EmailVerifiedAt == nil {
+			if err := q.MarkEmailVerified(ctx, sqlc.MarkEmailVerifiedParams{
+				ID:              user.ID,
+				EmailVerifiedAt: &now,
+			}); err != nil {
+				return sqlc.User{}, fmt.Errorf("не удалось отметить email подтвержденным: %w", err)
+			}
+			user.EmailVerifiedAt = &now
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		name := idToken.Name
+		if name == "" {
+			name = email
+		}
+
+		// Accounts created by single sign-on have no password, so password
+		// login fails for them. Admin is only granted by the role mapping or
+		// by an admin, never for the email alone.
+		user, err = q.Create(ctx, sqlc.CreateParams{
+			Email:           email,
+			PasswordHash:    "",
+			Name:            name,
+			Role:            RoleUser,
+			EmailVerifiedAt: &now,
+		})
+		if err != nil {
+			return sqlc.User{}, fmt.Errorf("не удалось создать пользователя: %w", err)
+		}
+	default:
+		return sqlc.User{}, fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+
+	if err := q.CreateUserIdentity(ctx, sqlc.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  idToken.Subject,
+		Email:    email,
+	}); err != nil {
+		return sqlc.User{}, fmt.Errorf("не удалось привязать учетную запись провайдера: %w", err)
+	}
+
+	s.logger.Info("OIDC identity linked", map[string]interface{}{
+		"user_id":  user.ID.String(),
+		"provider": providerName,
+	})
+	return user, nil
+}
+
+// mapRole returns the highest role mapped from the values of the role claim.
+func (p *oidcProvider) mapRole(claims map[string]any) string {
+	var value any = claims
+	for _, key := range strings.Split(p.roleClaim, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return RoleUser
+		}
+		value = object[key]
+	}
+
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = []string{v}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	role := RoleUser
+	for _, v := range values {
+		if mapped, ok := p.roleMap[v]; ok && roleRanks[mapped] > roleRanks[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// assertsMFA reports whether the ID token says the provider checked a second
+// factor with one of the methods configured for it.
+func (p *oidcProvider) assertsMFA(claims map[string]any) bool {
+	methods, _ := claims["amr"].([]any)
+	for _, method := range methods {
+		if s, ok := method.(string); ok && p.mfaMethods[s] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AuthService) deleteExpiredOIDCLoginStates(ctx context.Context) {
+	if err := s.queries.DeleteExpiredOIDCLoginStates(ctx, time.Now()); err != nil {
+		s.logger.Error("Failed to delete expired OIDC login states", err)
+	}
+}
@@ -0,0 +1,443 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/oidc"
+)
+
+// mockOIDCProvider is a local identity provider serving discovery, JWKS and
+// the token endpoint. It issues an ID token for the nonce and PKCE challenge
+// of the last authorization URL it was given.
+type mockOIDCProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	nonce     string
+	challenge string
+	claims    gojwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := &mockOIDCProvider{t: t, key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func (p *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, _ := r.BasicAuth()
+	if clientID != "client" || secret != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostFormValue("code") != "code" || oidc.ChallengeS256(r.PostFormValue("code_verifier")) != p.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := gojwt.MapClaims{
+		"iss":   p.server.URL,
+		"aud":   "client",
+		"sub":   "subject-1",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": p.nonce,
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+
+	token := gojwt.NewWithClaims(gojwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		p.t.Errorf("unexpected error: %v", err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+}
+
+// authorize plays the browser: it reads the authorization URL the way the
+// provider would and returns the state to send back.
+func (p *mockOIDCProvider) authorize(authURL string) string {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatalf("unexpected error: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != "client" {
+		p.t.Fatalf("unexpected authorization url %s", authURL)
+	}
+	p.nonce = query.Get("nonce")
+	p.challenge = query.Get("code_challenge")
+	return query.Get("state")
+}
+
+func newTestOIDCService(t *testing.T, provider *mockOIDCProvider, queries *mockQueries) *AuthService {
+	t.Helper()
+
+	states := make(map[string]sqlc.OidcLoginState)
+	queries.createOIDCLoginStateFunc = func(ctx context.Context, params sqlc.CreateOIDCLoginStateParams) error {
+		states[params.State] = sqlc.OidcLoginState{
+			State:        params.State,
+			Provider:     params.Provider,
+			Nonce:        params.Nonce,
+			CodeVerifier: params.CodeVerifier,
+			ExpiresAt:    params.ExpiresAt,
+		}
+		return nil
+	}
+	queries.consumeOIDCLoginStateFunc = func(ctx context.Context, state string) (sqlc.OidcLoginState, error) {
+		stored, ok := states[state]
+		if !ok {
+			return sqlc.OidcLoginState{}, pgx.ErrNoRows
+		}
+		delete(states, state)
+		return stored, nil
+	}
+	queries.withRefreshTokenStore()
+
+	service := newTestRefreshService(queries)
+	service.logger = nopLogger{}
+	service.cfg = config.AuthConfig{OIDCStateTTL: time.Minute}
+	service.oidcProviders = newOIDCProviders([]config.OIDCProviderConfig{{
+		Name:         "corp",
+		Issuer:       provider.server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/api/auth/oidc/corp/callback",
+		Scopes:       []string{"openid", "email"},
+		RoleClaim:    "groups",
+		RoleMap:      map[string]string{"uav-ops": RoleOperator},
+	}}, nopLogger{})
+	return service
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.claims = gojwt.MapClaims{
+		"email":          "pilot@example.com",
+		"email_verified": true,
+		"name":           "Pilot",
+		"groups":         []string{"staff", "uav-ops"},
+	}
+
+	var created sqlc.CreateParams
+	var identity sqlc.CreateUserIdentityParams
+	queries := &mockQueries{
+		getByEmailFunc: func(ctx context.Context, email string) (sqlc.User, error) {
+			return sqlc.User{}, pgx.ErrNoRows
+		},
+		createFunc: func(ctx context.Context, params sqlc.CreateParams) (sqlc.User, error) {
+			created = params
+			return sqlc.User{ID: uuid.New(), Email: params.Email, Name: params.Name, Role: params.Role}, nil
+		},
+		createUserIdentityFunc: func(ctx context.Context, params sqlc.CreateUserIdentityParams) error {
+			identity = params
+			return nil
+		},
+	}
+	service := newTestOIDCService(t, provider, queries)
+
+	authURL, state, err := service.StartOIDCLogin(context.Background(), "corp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if returned := provider.authorize(authURL); returned != state {
+		t.Fatalf("expected state %q in the authorization url, got %q", state, returned)
+	}
+
+	response, err := service.CompleteOIDCLogin(context.Background(), "corp", "code", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created.Email != "pilot@example.com" || created.Name != "Pilot" || created.PasswordHash != "" {
+		t.Errorf("unexpected user %+v", created)
+	}
+	if identity.Provider != "corp" || identity.Subject != "subject-1" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+	if response.User.Role != RoleOperator {
+		t.Errorf("expected the mapped role %q, got %q", RoleOperator, response.User.Role)
+	}
+	if response.Tokens == nil || response.Tokens.AccessToken == "" {
+		t.Error("expected a token pair")
+	}
+
+	// The state is single use.
+	if _, err := service.CompleteOIDCLogin(context.Background(), "corp", "code", state); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("expected %v on replay, got %v", ErrInvalidOIDCState, err)
+	}
+}
+
+func TestOIDCLoginTwoFactor(t *testing.T) {
+	tests := []struct {
+		name            string
+		amr             []string
+		trustedAMR      []string
+		enabled         bool
+		required        bool
+		expectChallenge bool
+	}{
+		{name: "no second factor", amr: []string{"pwd"}},
+		{name: "enabled second factor", amr: []string{"pwd"}, enabled: true, expectChallenge: true},
+		{name: "required for the role", required: true, expectChallenge: true},
+		{name: "mfa claimed by an untrusted provider", amr: []string{"pwd", "mfa"}, enabled: true, expectChallenge: true},
+		{name: "mfa asserted by a trusted provider", amr: []string{"pwd", "mfa"}, trustedAMR: []string{"mfa"}, enabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := sqlc.User{ID: uuid.New(), Email: "pilot@example.com", Role: RoleUser}
+			provider := newMockOIDCProvider(t)
+			provider.claims = gojwt.MapClaims{"email": user.Email, "amr": tt.amr}
+
+			var challenged *sqlc.CreateTwoFactorChallengeParams
+			queries := &mockQueries{
+				getUserByIdentityFunc: func(ctx context.Context, params sqlc.GetUserByIdentityParams) (sqlc.User, error) {
+					return user, nil
+				},
+				getUserTOTPFunc: func(ctx context.Context, userID uuid.UUID) (sqlc.UserTotp, error) {
+					if !tt.enabled {
+						return sqlc.UserTotp{}, pgx.ErrNoRows
+					}
+					confirmedAt := time.Now()
+					return sqlc.UserTotp{UserID: userID, ConfirmedAt: &confirmedAt}, nil
+				},
+				isTwoFactorRequiredFunc: func(ctx context.Context, role string) (bool, error) {
+					return tt.required, nil
+				},
+				createTwoFactorChallengeFunc: func(ctx context.Context, params sqlc.CreateTwoFactorChallengeParams) error {
+					challenged = &params
+					return nil
+				},
+			}
+			service := newTestOIDCService(t, provider, queries)
+			service.oidcProviders["corp"].mfaMethods = map[string]bool{}
+			for _, method := range tt.trustedAMR {
+				service.oidcProviders["corp"].mfaMethods[method] = true
+			}
+
+			authURL, state, err := service.StartOIDCLogin(context.Background(), "corp")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			provider.authorize(authURL)
+
+			response, err := service.CompleteOIDCLogin(context.Background(), "corp", "code", state)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.expectChallenge {
+				if response.TwoFactor == nil || challenged == nil || challenged.UserID != user.ID {
+					t.Fatalf("expected a two-factor challenge, got %+v", response)
+				}
+				if challenged.Enrollment != !tt.enabled {
+					t.Errorf("expected enrollment=%v, got %v", !tt.enabled, challenged.Enrollment)
+				}
+				if response.Tokens != nil {
+					t.Error("tokens must not be issued before the challenge is completed")
+				}
+				return
+			}
+			if response.TwoFactor != nil || challenged != nil {
+				t.Fatal("expected no challenge")
+			}
+			if response.Tokens == nil || response.Tokens.AccessToken == "" {
+				t.Error("expected a token pair")
+			}
+		})
+	}
+}
+
+func TestOIDCLoginRejectsForeignNonce(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.claims = gojwt.MapClaims{"email": "pilot@example.com", "nonce": "replayed"}
+	service := newTestOIDCService(t, provider, &mockQueries{})
+
+	authURL, state, err := service.StartOIDCLogin(context.Background(), "corp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider.authorize(authURL)
+
+	if _, err := service.CompleteOIDCLogin(context.Background(), "corp", "code", state); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("expected %v, got %v", ErrOIDCLoginFailed, err)
+	}
+}
+
+func TestOIDCUserLinking(t *testing.T) {
+	existing := sqlc.User{ID: uuid.New(), Email: "pilot@example.com", Role: RoleUser}
+	provisionedID := uuid.New()
+
+	tests := []struct {
+		name        string
+		idToken     *oidc.IDToken
+		linked      bool
+		emailTaken  bool
+		expectedErr error
+		expectUser  uuid.UUID
+		expectLink  bool
+	}{
+		{name: "known identity", idToken: &oidc.IDToken{Subject: "s", Email: "other@example.com"}, linked: true, expectUser: existing.ID},
+		{name: "verified email of an existing account", idToken: &oidc.IDToken{Subject: "s", Email: existing.Email, EmailVerified: true}, emailTaken: true, expectUser: existing.ID, expectLink: true},
+		{name: "unverified email of an existing account", idToken: &oidc.IDToken{Subject: "s", Email: existing.Email}, emailTaken: true, expectedErr: ErrOIDCEmailUnverified},
+		{name: "unverified email of a new account", idToken: &oidc.IDToken{Subject: "s", Email: "new@example.com"}, expectedErr: ErrOIDCEmailUnverified},
+		{name: "verified email of a new account", idToken: &oidc.IDToken{Subject: "s", Email: "New@Example.com", EmailVerified: true}, expectUser: provisionedID, expectLink: true},
+		{name: "no email", idToken: &oidc.IDToken{Subject: "s"}, expectedErr: ErrOIDCEmailMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linkedTo := uuid.Nil
+			queries := &mockQueries{
+				getUserByIdentityFunc: func(ctx context.Context, params sqlc.GetUserByIdentityParams) (sqlc.User, error) {
+					if tt.linked {
+						return existing, nil
+					}
+					return sqlc.User{}, pgx.ErrNoRows
+				},
+				getByEmailFunc: func(ctx context.Context, email string) (sqlc.User, error) {
+					if tt.emailTaken {
+						return existing, nil
+					}
+					return sqlc.User{}, pgx.ErrNoRows
+				},
+				createFunc: func(ctx context.Context, params sqlc.CreateParams) (sqlc.User, error) {
+					if params.Role != RoleUser || params.Email != "new@example.com" || params.EmailVerifiedAt == nil {
+						t.Errorf("unexpected provisioned account: %+v", params)
+					}
+					return sqlc.User{ID: provisionedID, Email: params.Email, Role: params.Role}, nil
+				},
+				createUserIdentityFunc: func(ctx context.Context, params sqlc.CreateUserIdentityParams) error {
+					linkedTo = params.UserID
+					return nil
+				},
+			}
+			service := &AuthService{queries: queries, logger: nopLogger{}}
+
+			user, err := service.oidcUser(context.Background(), queries, "corp", &oidcProvider{}, tt.idToken)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if linkedTo != uuid.Nil {
+					t.Error("no identity must be linked on error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if user.ID != tt.expectUser {
+				t.Errorf("expected user %s, got %s", tt.expectUser, user.ID)
+			}
+			if (linkedTo != uuid.Nil) != tt.expectLink {
+				t.Errorf("expected link=%v, got %s", tt.expectLink, linkedTo)
+			}
+		})
+	}
+}
+
+func TestOIDCRoleMapping(t *testing.T) {
+	provider := &oidcProvider{
+		roleClaim: "realm_access.roles",
+		roleMap:   map[string]string{"ops": RoleOperator, "admins": RoleAdmin},
+	}
+
+	tests := []struct {
+		name     string
+		claims   map[string]any
+		expected string
+	}{
+		{name: "highest role wins", claims: map[string]any{"realm_access": map[string]any{"roles": []any{"ops", "admins"}}}, expected: RoleAdmin},
+		{name: "single value", claims: map[string]any{"realm_access": map[string]any{"roles": "ops"}}, expected: RoleOperator},
+		{name: "no match", claims: map[string]any{"realm_access": map[string]any{"roles": []any{"staff"}}}, expected: RoleUser},
+		{name: "missing claim", claims: map[string]any{}, expected: RoleUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if role := provider.mapRole(tt.claims); role != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, role)
+			}
+		})
+	}
+}
+
+func TestOIDCRoleMappingKeepsLastAdmin(t *testing.T) {
+	admin := sqlc.User{ID: uuid.New(), Email: "admin@example.com", Role: RoleAdmin}
+	updated := false
+	queries := &mockQueries{
+		getUserByIdentityFunc: func(ctx context.Context, params sqlc.GetUserByIdentityParams) (sqlc.User, error) {
+			return admin, nil
+		},
+		countUsersByRoleFunc: func(ctx context.Context, role string) (int64, error) {
+			return 1, nil
+		},
+		updateUserRoleFunc: func(ctx context.Context, params sqlc.UpdateUserRoleParams) (sqlc.User, error) {
+			updated = true
+			return sqlc.User{}, nil
+		},
+	}
+	service := &AuthService{queries: queries, logger: nopLogger{}}
+	provider := &oidcProvider{roleClaim: "groups", roleMap: map[string]string{"admins": RoleAdmin}}
+
+	user, err := service.oidcUser(context.Background(), queries, "corp", provider, &oidc.IDToken{Subject: "s", Claims: map[string]any{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated || user.Role != RoleAdmin {
+		t.Error("the last admin must keep the role")
+	}
+}
@@ -1,6 +1,11 @@
 package auth
 
-import "github.com/skr1ms/dev2gis/pkg/jwt"
+import (
+	"time"
+
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+)
 
 type LoginUserRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -13,18 +18,148 @@ type CreateUserRequest struct {
 	Name     string `json:"name" binding:"required,min=2"`
 }
 
+// LoginResponse carries either the user and tokens or, when the login needs
+// a second factor, only TwoFactor. RecoveryCodes are set once, when a login
+// completes a two-factor enrollment.
 type LoginResponse struct {
-	User   *UserResponse  `json:"user"`
-	Tokens *jwt.TokenPair `json:"tokens"`
+	User          *UserResponse               `json:"user,omitempty"`
+	Tokens        *jwt.TokenPair              `json:"tokens,omitempty"`
+	TwoFactor     *TwoFactorChallengeResponse `json:"two_factor,omitempty"`
+	RecoveryCodes []string                    `json:"recovery_codes,omitempty"`
+}
+
+type TwoFactorChallengeResponse struct {
+	ChallengeToken     string    `json:"challenge_token"`
+	ExpiresAt          time.Time `json:"expires_at"`
+	EnrollmentRequired bool      `json:"enrollment_required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorPolicy struct {
+	RequiredRoles []string `json:"required_roles" binding:"dive,oneof=operator admin"`
 }
 
 type UserResponse struct {
-	ID    string `json:"id"`
-	Email string `json:"email"`
-	Name  string `json:"name"`
-	Role  string `json:"role,omitempty"`
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	Name          string `json:"name"`
+	Role          string `json:"role,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+func newUserResponse(user sqlc.User) *UserResponse {
+	return &UserResponse{
+		ID:            user.ID.String(),
+		Email:         user.Email,
+		Name:          user.Name,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerifiedAt != nil,
+	}
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// ProfileResponse is the account of the current user. HasPassword is false
+// for accounts created by single sign-on until a password is set with a reset
+// link.
+type ProfileResponse struct {
+	*UserResponse
+	HasPassword      bool      `json:"has_password"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// UpdateProfileRequest changes the fields that are set. Changing the email of
+// an account with a password requires CurrentPassword.
+type UpdateProfileRequest struct {
+	Name            *string `json:"name" binding:"omitempty,min=2"`
+	Email           *string `json:"email" binding:"omitempty,email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// DeleteAccountRequest confirms the deletion of an account with a password.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// AdminUserResponse is a user as listed to administrators.
+type AdminUserResponse struct {
+	ID          string     `json:"id"`
+	Email       string     `json:"email"`
+	Name        string     `json:"name"`
+	Role        string     `json:"role"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=read upload admin"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPIKeyResponse is the only response that carries the key itself.
+type CreateAPIKeyResponse struct {
+	*APIKeyResponse
+	Key string `json:"key"`
 }
 
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user operator admin"`
+}
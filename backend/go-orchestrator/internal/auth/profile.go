@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+	"github.com/skr1ms/dev2gis/pkg/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCurrentPassword = errors.New("неверный текущий пароль")
+	ErrPasswordNotSet         = errors.New("у учетной записи нет пароля, задайте его по ссылке для сброса пароля")
+	ErrEmailTaken             = errors.New("пользователь с таким email уже существует")
+)
+
+// GetProfile returns the account of the user.
+func (s *AuthService) GetProfile(ctx context.Context, userID uuid.UUID) (*ProfileResponse, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newProfileResponse(ctx, user)
+}
+
+// UpdateProfile changes the name and email of the user. A new email has to be
+// verified again, so uploads stay blocked until the link sent to it is
+// opened; the previous address is told about the change.
+func (s *AuthService) UpdateProfile(ctx context.Context, userID uuid.UUID, req *UpdateProfileRequest) (*ProfileResponse, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := sqlc.UpdateUserProfileParams{
+		ID:    userID,
+		Name:  user.Name,
+		Email: user.Email,
+	}
+	if req.Name != nil {
+		params.Name = *req.Name
+	}
+	var email string
+	if req.Email != nil {
+		email = normalizeEmail(*req.Email)
+	}
+	emailChanged := req.Email != nil && email != user.Email
+	if emailChanged {
+		if err := confirmPassword(user, req.CurrentPassword); err != nil {
+			return nil, err
+		}
+		if existing, err := s.queries.GetByEmail(ctx, email); err == nil && existing.ID != user.ID {
+			return nil, ErrEmailTaken
+		} else if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("не удалось проверить email: %w", err)
+		}
+		params.Email = email
+	}
+
+	var updated sqlc.User
+	err = s.withTx(ctx, func(q QueriesInterface) error {
+		updated, err = q.UpdateUserProfile(ctx, params)
+		if err != nil {
+			return fmt.Errorf("не удалось обновить профиль: %w", err)
+		}
+		if !emailChanged {
+			return nil
+		}
+
+		// A link sent to the previous address must not verify the new one.
+		if err := q.DeleteUserTokens(ctx, sqlc.DeleteUserTokensParams{
+			UserID:  userID,
+			Purpose: tokenPurposeEmailVerification,
+		}); err != nil {
+			return fmt.Errorf("не удалось удалить токены подтверждения email: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if emailChanged {
+		if err := s.sendVerificationEmail(ctx, updated); err != nil {
+			s.logger.Error("Failed to send verification email", err, map[string]interface{}{
+				"user_id": userID.String(),
+			})
+		}
+		s.sendMail(mailer.Message{
+			To:      user.Email,
+			Subject: "Email учетной записи изменен",
+			Body: fmt.Sprintf("Здравствуйте, %s!\n\nEmail вашей учетной записи изменен на %s. Если это сделали не вы, срочно обратитесь к администратору.\n",
+				user.Name, updated.Email),
+		})
+		s.logger.Info("User email changed", map[string]interface{}{
+			"user_id": userID.String(),
+		})
+	}
+
+	return s.newProfileResponse(ctx, updated)
+}
+
+// ChangePassword sets a new password after checking the current one and ends
+// every other session of the user. The session that made the change gets the
+// returned tokens instead.
+func (s *AuthService) ChangePassword(ctx context.Context, userID uuid.UUID, req *ChangePasswordRequest) (*jwt.TokenPair, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordHash == "" {
+		return nil, ErrPasswordNotSet
+	}
+	if err := confirmPassword(user, req.CurrentPassword); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обработки пароля")
+	}
+
+	// Token timestamps have second precision, so access tokens are cut off
+	// before the current second to keep the new one valid.
+	cutoff := time.Now().Truncate(time.Second).Add(-time.Nanosecond)
+	tokens, err := s.jwtService.GenerateTokenPair(user.ID, user.Email, user.Name, user.Role)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать токены")
+	}
+
+	err = s.withTx(ctx, func(q QueriesInterface) error {
+		if err := q.UpdateUserPassword(ctx, sqlc.UpdateUserPasswordParams{
+			ID:           userID,
+			PasswordHash: string(hashedPassword),
+		}); err != nil {
+			return fmt.Errorf("не удалось обновить пароль: %w", err)
+		}
+
+		now := time.Now()
+		if err := q.RevokeUserRefreshTokens(ctx, sqlc.RevokeUserRefreshTokensParams{
+			UserID:    userID,
+			RevokedAt: &now,
+		}); err != nil {
+			return fmt.Errorf("не удалось отозвать refresh токены: %w", err)
+		}
+
+		return s.storeRefreshToken(ctx, q, userID, tokens)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.revocations.RevokeUserBefore(ctx, userID, cutoff); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Password changed", map[string]interface{}{
+		"user_id": userID.String(),
+	})
+	return tokens, nil
+}
+
+// DeleteAccount removes the account of the user the way an admin deletion
+// does, after checking the password of an account that has one. The last
+// admin cannot delete their account.
+func (s *AuthService) DeleteAccount(ctx context.Context, userID uuid.UUID, password string) error {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := confirmPassword(user, password); err != nil {
+		return err
+	}
+
+	if err := s.deleteUser(ctx, userID); err != nil {
+		return err
+	}
+
+	s.logger.Info("User deleted their account", map[string]interface{}{
+		"user_id": userID.String(),
+	})
+	return nil
+}
+
+func (s *AuthService) loadUser(ctx context.Context, userID uuid.UUID) (sqlc.User, error) {
+	user, err := s.queries.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.User{}, ErrUserNotFound
+		}
+		return sqlc.User{}, fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	return user, nil
+}
+
+func (s *AuthService) newProfileResponse(ctx context.Context, user sqlc.User) (*ProfileResponse, error) {
+	twoFactor, err := s.twoFactorEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProfileResponse{
+		UserResponse:     newUserResponse(user),
+		HasPassword:      user.PasswordHash != "",
+		TwoFactorEnabled: twoFactor,
+		CreatedAt:        user.CreatedAt,
+	}, nil
+}
+
+// confirmPassword checks the password of an account that has one. Accounts
+// created by single sign-on have none and are confirmed by the session alone.
+func confirmPassword(user sqlc.User, password string) error {
+	if user.PasswordHash == "" {
+		return nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return ErrInvalidCurrentPassword
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	verifiedAt := time.Now()
+	user := sqlc.User{ID: uuid.New(), Email: "user@example.com", Name: "User", PasswordHash: hashPassword(t, "password123"), EmailVerifiedAt: &verifiedAt}
+	queries := &mockQueries{
+		getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+			return user, nil
+		},
+		getByEmailFunc: func(ctx context.Context, email string) (sqlc.User, error) {
+			if email == "taken@example.com" {
+				return sqlc.User{ID: uuid.New(), Email: email}, nil
+			}
+			return sqlc.User{}, pgx.ErrNoRows
+		},
+		updateUserProfileFunc: func(ctx context.Context, params sqlc.UpdateUserProfileParams) (sqlc.User, error) {
+			updated := user
+			updated.Name = params.Name
+			if params.Email != user.Email {
+				updated.Email = params.Email
+				updated.EmailVerifiedAt = nil
+			}
+			return updated, nil
+		},
+	}
+	queries.withUserTokenStore()
+	service, sent := newTestEmailService(queries)
+	ctx := context.Background()
+
+	name := "New Name"
+	profile, err := service.UpdateProfile(ctx, user.ID, &UpdateProfileRequest{Name: &name})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.Name != name || !profile.EmailVerified {
+		t.Errorf("expected only the name to change, got %+v", profile.UserResponse)
+	}
+
+	email := "new@example.com"
+	if _, err := service.UpdateProfile(ctx, user.ID, &UpdateProfileRequest{Email: &email, CurrentPassword: "wrong-password"}); !errors.Is(err, ErrInvalidCurrentPassword) {
+		t.Fatalf("expected %v, got %v", ErrInvalidCurrentPassword, err)
+	}
+	taken := "taken@example.com"
+	if _, err := service.UpdateProfile(ctx, user.ID, &UpdateProfileRequest{Email: &taken, CurrentPassword: "password123"}); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("expected %v, got %v", ErrEmailTaken, err)
+	}
+
+	// A link sent to the old address earlier must not verify the new one.
+	oldLink, err := service.issueUserToken(ctx, queries, user.ID, tokenPurposeEmailVerification, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	profile, err = service.UpdateProfile(ctx, user.ID, &UpdateProfileRequest{Email: &email, CurrentPassword: "password123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.Email != email || profile.EmailVerified {
+		t.Errorf("expected the new email to need verification, got %+v", profile.UserResponse)
+	}
+	if err := service.VerifyEmail(ctx, oldLink); !errors.Is(err, ErrInvalidEmailLink) {
+		t.Errorf("expected the old link to stop working, got %v", err)
+	}
+
+	recipients := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-sent.sent:
+			recipients[msg.To] = true
+		case <-time.After(time.Second):
+			t.Fatal("expected a verification link and a notice to the old address")
+		}
+	}
+	if !recipients[email] || !recipients[user.Email] {
+		t.Errorf("expected emails to %s and %s, got %v", email, user.Email, recipients)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	user := sqlc.User{ID: uuid.New(), Email: "user@example.com", Name: "User", Role: RoleUser, PasswordHash: hashPassword(t, "password123")}
+	var newHash string
+	queries := &mockQueries{
+		getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+			return user, nil
+		},
+		updateUserPasswordFunc: func(ctx context.Context, params sqlc.UpdateUserPasswordParams) error {
+			newHash = params.PasswordHash
+			return nil
+		},
+	}
+	queries.withRefreshTokenStore()
+	service := newTestRefreshService(queries)
+	service.logger = nopLogger{}
+	revocations := newRevocationStore(&mockRevocationQueries{}, config.AuthConfig{}, nopLogger{})
+	service.revocations = revocations
+	ctx := context.Background()
+
+	other, err := service.jwtService.GenerateTokenPair(user.ID, user.Email, user.Name, user.Role)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := service.storeRefreshToken(ctx, queries, user.ID, other); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := service.ChangePassword(ctx, user.ID, &ChangePasswordRequest{CurrentPassword: "wrong-password", NewPassword: "new-password"}); !errors.Is(err, ErrInvalidCurrentPassword) {
+		t.Fatalf("expected %v, got %v", ErrInvalidCurrentPassword, err)
+	}
+	if newHash != "" {
+		t.Fatal("the password must not change with a wrong current password")
+	}
+
+	tokens, err := service.ChangePassword(ctx, user.ID, &ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "new-password"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(newHash), []byte("new-password")) != nil {
+		t.Error("expected the new password to be stored")
+	}
+
+	if _, err := service.RefreshTokens(ctx, &RefreshRequest{RefreshToken: other.RefreshToken}); err == nil {
+		t.Error("expected other sessions to be ended")
+	}
+	if _, err := service.RefreshTokens(ctx, &RefreshRequest{RefreshToken: tokens.RefreshToken}); err != nil {
+		t.Errorf("expected the returned session to work, got %v", err)
+	}
+
+	claims, err := service.jwtService.(*jwt.JWTService).ValidateAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if revocations.IsRevoked(claims) {
+		t.Error("expected the returned access token to stay valid")
+	}
+	earlier := &jwt.CustomClaims{
+		UserID:           user.ID,
+		RegisteredClaims: gojwt.RegisteredClaims{IssuedAt: gojwt.NewNumericDate(time.Now().Add(-time.Minute))},
+	}
+	if !revocations.IsRevoked(earlier) {
+		t.Error("expected earlier access tokens to be revoked")
+	}
+
+	user.PasswordHash = ""
+	if _, err := service.ChangePassword(ctx, user.ID, &ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "new-password"}); !errors.Is(err, ErrPasswordNotSet) {
+		t.Errorf("expected %v for an account without a password, got %v", ErrPasswordNotSet, err)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	tests := []struct {
+		name        string
+		user        sqlc.User
+		password    string
+		admins      int64
+		expectedErr error
+	}{
+		{name: "with password", user: sqlc.User{Role: RoleUser, PasswordHash: hashPassword(t, "password123")}, password: "password123"},
+		{name: "wrong password", user: sqlc.User{Role: RoleUser, PasswordHash: hashPassword(t, "password123")}, password: "wrong-password", expectedErr: ErrInvalidCurrentPassword},
+		{name: "single sign-on account", user: sqlc.User{Role: RoleUser}},
+		{name: "last admin", user: sqlc.User{Role: RoleAdmin}, admins: 1, expectedErr: ErrLastAdmin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			user.ID = uuid.New()
+			var calls []string
+			queries := &mockQueries{
+				getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+					return user, nil
+				},
+				countUsersByRoleFunc: func(ctx context.Context, role string) (int64, error) {
+					return tt.admins, nil
+				},
+				scheduleDeletionFunc: func(ctx context.Context, userID uuid.UUID) error {
+					calls = append(calls, "schedule")
+					return nil
+				},
+				deleteFunc: func(ctx context.Context, id uuid.UUID) error {
+					calls = append(calls, "delete")
+					return nil
+				},
+			}
+			service := newTestRefreshService(queries)
+			service.logger = nopLogger{}
+
+			err := service.DeleteAccount(context.Background(), user.ID, tt.password)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if len(calls) != 0 {
+					t.Errorf("expected nothing deleted, got %v", calls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// The deletion has to be scheduled while the jobs still exist.
+			if len(calls) != 2 || calls[0] != "schedule" || calls[1] != "delete" {
+				t.Errorf("expected the storage deletion scheduled before the user is deleted, got %v", calls)
+			}
+			if revoker := service.revocations.(*mockRevoker); len(revoker.revokedUsers) != 1 {
+				t.Error("expected the access tokens of the account to be revoked")
+			}
+		})
+	}
+}
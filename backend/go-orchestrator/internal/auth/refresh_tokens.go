@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+)
+
+// refreshTokenCleanupEvery is how often expired refresh tokens are deleted.
+const refreshTokenCleanupEvery = time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("недействительный токен обновления")
+	ErrRefreshTokenReused  = errors.New("токен обновления уже использован, сеанс завершен")
+	ErrUserNotFound        = errors.New("пользователь не найден")
+)
+
+// Refresh tokens are persisted by jti with a SHA-256 hash of the token, so a
+// leaked database row cannot be replayed. Each refresh rotates the token: the
+// presented one is revoked and points to its replacement. A refresh token
+// that is presented again after rotation means it was copied, so the whole
+// family issued from that login is revoked and both holders have to sign in
+// again.
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *AuthService) storeRefreshToken(ctx context.Context, q QueriesInterface, userID uuid.UUID, tokens *jwt.TokenPair) error {
+	err := q.CreateRefreshToken(ctx, sqlc.CreateRefreshTokenParams{
+		ID:        tokens.RefreshTokenID,
+		UserID:    userID,
+		FamilyID:  tokens.FamilyID,
+		TokenHash: hashRefreshToken(tokens.RefreshToken),
+		ExpiresAt: tokens.RefreshExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить сеанс")
+	}
+	return nil
+}
+
+// lookupRefreshToken validates the signature of a refresh token and returns
+// its stored row.
+func (s *AuthService) lookupRefreshToken(ctx context.Context, q QueriesInterface, refreshToken string) (*jwt.CustomClaims, *sqlc.RefreshToken, error) {
+	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	stored, err := q.GetRefreshToken(ctx, tokenID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, fmt.Errorf("не удалось получить refresh токен: %w", err)
+	}
+
+	hash := hashRefreshToken(refreshToken)
+	if stored.UserID != claims.UserID || subtle.ConstantTimeCompare([]byte(stored.TokenHash), []byte(hash)) != 1 {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	return claims, &stored, nil
+}
+
+func (s *AuthService) RefreshTokens(ctx context.Context, req *RefreshRequest) (*jwt.TokenPair, error) {
+	var tokens *jwt.TokenPair
+	reused := false
+
+	err := s.withTx(ctx, func(q QueriesInterface) error {
+		claims, stored, err := s.lookupRefreshToken(ctx, q, req.RefreshToken)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		revokeFamily := func() error {
+			reused = true
+			if err := q.RevokeRefreshTokenFamily(ctx, sqlc.RevokeRefreshTokenFamilyParams{
+				FamilyID:  stored.FamilyID,
+				RevokedAt: &now,
+			}); err != nil {
+				return fmt.Errorf("не удалось отозвать цепочку refresh токенов: %w", err)
+			}
+			return nil
+		}
+
+		if stored.RevokedAt != nil {
+			return revokeFamily()
+		}
+
+		// The new tokens carry the current profile and role, so a promotion
+		// takes effect on the next refresh.
+		user, err := q.GetByID(ctx, stored.UserID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidRefreshToken
+			}
+			return fmt.Errorf("не удалось получить пользователя: %w", err)
+		}
+		if user.SuspendedAt != nil {
+			return ErrUserSuspended
+		}
+
+		claims.Email = user.Email
+		claims.Name = user.Name
+		claims.Role = user.Role
+		claims.FamilyID = stored.FamilyID
+		tokens, err = s.jwtService.RotateTokenPair(claims)
+		if err != nil {
+			return fmt.Errorf("не удалось сгенерировать токены")
+		}
+
+		// The conditional update is the guard against two concurrent refreshes
+		// with the same token: only one of them can rotate it.
+		rotated, err := q.RotateRefreshToken(ctx, sqlc.RotateRefreshTokenParams{
+			ID:         stored.ID,
+			RevokedAt:  &now,
+			ReplacedBy: &tokens.RefreshTokenID,
+		})
+		if err != nil {
+			return fmt.Errorf("не удалось обновить refresh токен: %w", err)
+		}
+		if rotated == 0 {
+			tokens = nil
+			return revokeFamily()
+		}
+
+		return s.storeRefreshToken(ctx, q, stored.UserID, tokens)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+
+	return tokens, nil
+}
+
+// Logout ends the session the refresh token belongs to by revoking its whole
+// family. When the request also carries the session's access token, that
+// token is denylisted too.
+func (s *AuthService) Logout(ctx context.Context, req *LogoutRequest, accessClaims *jwt.CustomClaims) error {
+	_, stored, err := s.lookupRefreshToken(ctx, s.queries, req.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.queries.RevokeRefreshTokenFamily(ctx, sqlc.RevokeRefreshTokenFamilyParams{
+		FamilyID:  stored.FamilyID,
+		RevokedAt: &now,
+	}); err != nil {
+		return fmt.Errorf("не удалось завершить сеанс")
+	}
+
+	if accessClaims != nil && accessClaims.UserID == stored.UserID {
+		if err := s.revocations.RevokeToken(ctx, accessClaims); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RevokeUserSessions ends every session of the user: all refresh tokens are
+// revoked and all access tokens issued so far are denylisted.
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.queries.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+
+	now := time.Now()
+	if err := s.queries.RevokeUserRefreshTokens(ctx, sqlc.RevokeUserRefreshTokensParams{
+		UserID:    userID,
+		RevokedAt: &now,
+	}); err != nil {
+		return fmt.Errorf("не удалось отозвать refresh токены: %w", err)
+	}
+
+	return s.revocations.RevokeUser(ctx, userID)
+}
+
+// Run deletes expired refresh tokens, single sign-on login states, email
+// links, two-factor login challenges and login attempts until ctx is
+// cancelled.
+func (s *AuthService) Run(ctx context.Context) {
+	ticker := time.NewTicker(refreshTokenCleanupEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.queries.DeleteExpiredRefreshTokens(ctx, time.Now()); err != nil {
+				s.logger.Error("Failed to delete expired refresh tokens", err)
+			}
+			s.deleteExpiredOIDCLoginStates(ctx)
+			s.deleteExpiredUserTokens(ctx)
+			s.deleteExpiredTwoFactorChallenges(ctx)
+			s.deleteExpiredLoginAttempts(ctx)
+		}
+	}
+}
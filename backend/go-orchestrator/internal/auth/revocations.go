@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+// revocationCleanupEvery is how often revocations of expired tokens are
+// deleted from the database.
+const revocationCleanupEvery = time.Hour
+
+// RevocationStore is the access token denylist consulted by JWTMiddleware on
+// every request. A token is revoked either by its jti (logout) or by a
+// per-user cutoff that rejects every token issued up to that moment (logout
+// from all sessions, account cut-off).
+//
+// Revocations are written to Postgres and kept in memory. Each replica
+// reloads them every RevocationSyncInterval, so a revocation made on one
+// replica reaches the others within that interval. Entries are only needed
+// until the tokens they cover expire, which keeps both sets small.
+type RevocationStore struct {
+	queries RevocationQueriesInterface
+	cfg     config.AuthConfig
+	logger  middleware.LoggerInterface
+
+	mu     sync.RWMutex
+	tokens map[uuid.UUID]time.Time
+	users  map[uuid.UUID]time.Time
+}
+
+func NewRevocationStore(db *storage.DB, cfg config.AuthConfig, logger middleware.LoggerInterface) *RevocationStore {
+	return newRevocationStore(db.Queries, cfg, logger)
+}
+
+func newRevocationStore(queries RevocationQueriesInterface, cfg config.AuthConfig, logger middleware.LoggerInterface) *RevocationStore {
+	return &RevocationStore{
+		queries: queries,
+		cfg:     cfg,
+		logger:  logger,
+		tokens:  make(map[uuid.UUID]time.Time),
+		users:   make(map[uuid.UUID]time.Time),
+	}
+}
+
+// IsRevoked implements middleware.TokenRevocationChecker.
+func (s *RevocationStore) IsRevoked(claims *jwt.CustomClaims) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if tokenID, err := uuid.Parse(claims.ID); err == nil {
+		if _, ok := s.tokens[tokenID]; ok {
+			return true
+		}
+	}
+
+	// Token timestamps have second precision, so a token issued in the same
+	// second as the cutoff is treated as issued before it.
+	if cutoff, ok := s.users[claims.UserID]; ok && claims.IssuedAt != nil {
+		return !claims.IssuedAt.After(cutoff)
+	}
+
+	return false
+}
+
+// RevokeToken denylists a single access token until it expires.
+func (s *RevocationStore) RevokeToken(ctx context.Context, claims *jwt.CustomClaims) error {
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil || claims.ExpiresAt == nil {
+		// Tokens issued before jti was added cannot be revoked one by one.
+		return nil
+	}
+
+	if err := s.queries.RevokeAccessToken(ctx, sqlc.RevokeAccessTokenParams{
+		ID:        tokenID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		return fmt.Errorf("не удалось отозвать токен доступа: %w", err)
+	}
+
+	s.mu.Lock()
+	s.tokens[tokenID] = claims.ExpiresAt.Time
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RevokeUser rejects every access token of the user issued up to now.
+func (s *RevocationStore) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	return s.RevokeUserBefore(ctx, userID, time.Now())
+}
+
+// RevokeUserBefore rejects every access token of the user issued up to
+// before. A cutoff never moves back, so an earlier one than already stored
+// has no effect.
+func (s *RevocationStore) RevokeUserBefore(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	if err := s.queries.RevokeUserAccessTokens(ctx, sqlc.RevokeUserAccessTokensParams{
+		UserID:        userID,
+		RevokedBefore: before,
+	}); err != nil {
+		return fmt.Errorf("не удалось отозвать токены доступа пользователя: %w", err)
+	}
+
+	s.mu.Lock()
+	if before.After(s.users[userID]) {
+		s.users[userID] = before
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *RevocationStore) Run(ctx context.Context) {
+	if err := s.Sync(ctx); err != nil {
+		s.logger.Error("Failed to load revoked access tokens", err)
+	}
+
+	ticker := time.NewTicker(s.cfg.RevocationSyncInterval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(revocationCleanupEvery)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to sync revoked access tokens", err)
+			}
+		case <-cleanup.C:
+			s.cleanup(ctx)
+		}
+	}
+}
+
+func (s *RevocationStore) cleanup(ctx context.Context) {
+	now := time.Now()
+
+	if err := s.queries.DeleteExpiredAccessTokenRevocations(ctx, now); err != nil {
+		s.logger.Error("Failed to delete expired access token revocations", err)
+	}
+	if err := s.queries.DeleteUserTokenRevocationsBefore(ctx, now.Add(-s.cfg.JWTAccessTokenTTL)); err != nil {
+		s.logger.Error("Failed to delete old user token revocations", err)
+	}
+}
+
+// Sync merges revocations made on other replicas into the cache and drops
+// entries whose tokens have expired. Entries are never dropped just because
+// a load missed them, so a revocation written locally during the load is not
+// lost.
+func (s *RevocationStore) Sync(ctx context.Context) error {
+	now := time.Now()
+	userCutoff := now.Add(-s.cfg.JWTAccessTokenTTL)
+
+	tokens, err := s.queries.ListRevokedAccessTokens(ctx, now)
+	if err != nil {
+		return fmt.Errorf("не удалось получить отозванные токены доступа: %w", err)
+	}
+	users, err := s.queries.ListUserTokenRevocations(ctx, userCutoff)
+	if err != nil {
+		return fmt.Errorf("не удалось получить отзывы токенов пользователей: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for tokenID, expiresAt := range s.tokens {
+		if !expiresAt.After(now) {
+			delete(s.tokens, tokenID)
+		}
+	}
+	for userID, revokedBefore := range s.users {
+		if !revokedBefore.After(userCutoff) {
+			delete(s.users, userID)
+		}
+	}
+
+	for _, token := range tokens {
+		s.tokens[token.ID] = token.ExpiresAt
+	}
+	for _, user := range users {
+		if user.RevokedBefore.After(s.users[user.UserID]) {
+			s.users[user.UserID] = user.RevokedBefore
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...map[string]interface{})            {}
+func (nopLogger) Info(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Warn(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Error(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) Fatal(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) WithContext(ctx context.Context) *middleware.Logger            { return nil }
+func (nopLogger) Middleware() gin.HandlerFunc                                   { return nil }
+
+type mockRevocationQueries struct {
+	tokens []sqlc.RevokedAccessToken
+	users  []sqlc.UserTokenRevocation
+}
+
+func (m *mockRevocationQueries) RevokeAccessToken(ctx context.Context, params sqlc.RevokeAccessTokenParams) error {
+	m.tokens = append(m.tokens, sqlc.RevokedAccessToken{ID: params.ID, UserID: params.UserID, ExpiresAt: params.ExpiresAt})
+	return nil
+}
+
+func (m *mockRevocationQueries) RevokeUserAccessTokens(ctx context.Context, params sqlc.RevokeUserAccessTokensParams) error {
+	m.users = append(m.users, sqlc.UserTokenRevocation{UserID: params.UserID, RevokedBefore: params.RevokedBefore})
+	return nil
+}
+
+func (m *mockRevocationQueries) ListRevokedAccessTokens(ctx context.Context, expiresAt time.Time) ([]sqlc.RevokedAccessToken, error) {
+	var items []sqlc.RevokedAccessToken
+	for _, token := range m.tokens {
+		if token.ExpiresAt.After(expiresAt) {
+			items = append(items, token)
+		}
+	}
+	return items, nil
+}
+
+func (m *mockRevocationQueries) ListUserTokenRevocations(ctx context.Context, revokedBefore time.Time) ([]sqlc.UserTokenRevocation, error) {
+	var items []sqlc.UserTokenRevocation
+	for _, user := range m.users {
+		if user.RevokedBefore.After(revokedBefore) {
+			items = append(items, user)
+		}
+	}
+	return items, nil
+}
+
+func (m *mockRevocationQueries) DeleteExpiredAccessTokenRevocations(ctx context.Context, expiresAt time.Time) error {
+	return nil
+}
+
+func (m *mockRevocationQueries) DeleteUserTokenRevocationsBefore(ctx context.Context, revokedBefore time.Time) error {
+	return nil
+}
+
+func accessClaims(userID uuid.UUID, issuedAt time.Time) *jwt.CustomClaims {
+	return &jwt.CustomClaims{
+		UserID: userID,
+		RegisteredClaims: gojwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  gojwt.NewNumericDate(issuedAt),
+			ExpiresAt: gojwt.NewNumericDate(issuedAt.Add(30 * time.Minute)),
+		},
+	}
+}
+
+func TestRevocationStore(t *testing.T) {
+	cfg := config.AuthConfig{JWTAccessTokenTTL: 30 * time.Minute}
+	userID := uuid.New()
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		revoke   func(s *RevocationStore, claims *jwt.CustomClaims) error
+		claims   *jwt.CustomClaims
+		expected bool
+	}{
+		{
+			name:     "token not revoked",
+			revoke:   func(s *RevocationStore, claims *jwt.CustomClaims) error { return nil },
+			claims:   accessClaims(userID, now),
+			expected: false,
+		},
+		{
+			name: "token revoked by jti",
+			revoke: func(s *RevocationStore, claims *jwt.CustomClaims) error {
+				return s.RevokeToken(context.Background(), claims)
+			},
+			claims:   accessClaims(userID, now),
+			expected: true,
+		},
+		{
+			name: "token issued before the user cutoff",
+			revoke: func(s *RevocationStore, claims *jwt.CustomClaims) error {
+				return s.RevokeUser(context.Background(), userID)
+			},
+			claims:   accessClaims(userID, now.Add(-time.Minute)),
+			expected: true,
+		},
+		{
+			name: "token issued after the user cutoff",
+			revoke: func(s *RevocationStore, claims *jwt.CustomClaims) error {
+				return s.RevokeUser(context.Background(), userID)
+			},
+			claims:   accessClaims(userID, now.Add(time.Minute)),
+			expected: false,
+		},
+		{
+			name: "cutoff of another user",
+			revoke: func(s *RevocationStore, claims *jwt.CustomClaims) error {
+				return s.RevokeUser(context.Background(), uuid.New())
+			},
+			claims:   accessClaims(userID, now.Add(-time.Minute)),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := &mockRevocationQueries{}
+			local := newRevocationStore(queries, cfg, nopLogger{})
+			if err := tt.revoke(local, tt.claims); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := local.IsRevoked(tt.claims); got != tt.expected {
+				t.Errorf("expected revoked=%v on the revoking replica, got %v", tt.expected, got)
+			}
+
+			remote := newRevocationStore(queries, cfg, nopLogger{})
+			if err := remote.Sync(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := remote.IsRevoked(tt.claims); got != tt.expected {
+				t.Errorf("expected revoked=%v after sync, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRevocationStoreSyncKeepsLocalEntries(t *testing.T) {
+	store := newRevocationStore(&mockRevocationQueries{}, config.AuthConfig{JWTAccessTokenTTL: 30 * time.Minute}, nopLogger{})
+
+	live := accessClaims(uuid.New(), time.Now())
+	expired := accessClaims(uuid.New(), time.Now().Add(-time.Hour))
+	store.tokens[uuid.MustParse(live.ID)] = live.ExpiresAt.Time
+	store.tokens[uuid.MustParse(expired.ID)] = expired.ExpiresAt.Time
+
+	if err := store.Sync(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !store.IsRevoked(live) {
+		t.Error("a revocation missing from the load must be kept until the token expires")
+	}
+	if _, ok := store.tokens[uuid.MustParse(expired.ID)]; ok {
+		t.Error("expected the revocation of an expired token to be dropped")
+	}
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+const (
+	RoleUser     = "user"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// roleRanks orders roles by privilege to tell promotions from demotions.
+var roleRanks = map[string]int{
+	RoleUser:     0,
+	RoleOperator: 1,
+	RoleAdmin:    2,
+}
+
+var (
+	ErrInvalidRole = errors.New("неизвестная роль")
+	ErrLastAdmin   = errors.New("нельзя лишить доступа последнего администратора")
+)
+
+// SetUserRole stores a new role for the user. Tokens carry the role, so a
+// demotion also ends every session of the user; a promotion takes effect on
+// the next token refresh.
+func (s *AuthService) SetUserRole(ctx context.Context, userID uuid.UUID, role string) (*UserResponse, error) {
+	if _, ok := roleRanks[role]; !ok {
+		return nil, ErrInvalidRole
+	}
+
+	var previous string
+	var updated sqlc.User
+
+	err := s.withTx(ctx, func(q QueriesInterface) error {
+		user, err := q.GetByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("не удалось получить пользователя: %w", err)
+		}
+		previous = user.Role
+
+		if user.Role == RoleAdmin && user.SuspendedAt == nil && role != RoleAdmin {
+			admins, err := q.CountUsersByRole(ctx, RoleAdmin)
+			if err != nil {
+				return fmt.Errorf("не удалось посчитать администраторов: %w", err)
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		updated, err = q.UpdateUserRole(ctx, sqlc.UpdateUserRoleParams{ID: userID, Role: role})
+		if err != nil {
+			return fmt.Errorf("не удалось изменить роль пользователя: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if roleRanks[role] < roleRanks[previous] {
+		if err := s.RevokeUserSessions(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return newUserResponse(updated), nil
+}
+
+// BootstrapAdmin promotes the account configured by AUTH_BOOTSTRAP_ADMIN_EMAIL
+// on startup. The account must already exist and have a verified email, so
+// nobody becomes admin by registering the address first; an account that is
+// registered or verified later is promoted on the next restart.
+func (s *AuthService) BootstrapAdmin(ctx context.Context) error {
+	if s.cfg.BootstrapAdminEmail == "" {
+		return nil
+	}
+
+	user, err := s.queries.GetByEmail(ctx, normalizeEmail(s.cfg.BootstrapAdminEmail))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("не удалось получить первого администратора: %w", err)
+	}
+	if user.Role == RoleAdmin {
+		return nil
+	}
+	if user.EmailVerifiedAt == nil {
+		s.logger.Warn("Bootstrap admin not promoted: email is not verified", map[string]interface{}{
+			"user_id": user.ID.String(),
+		})
+		return nil
+	}
+
+	if _, err := s.queries.UpdateUserRole(ctx, sqlc.UpdateUserRoleParams{ID: user.ID, Role: RoleAdmin}); err != nil {
+		return fmt.Errorf("не удалось назначить первого администратора: %w", err)
+	}
+
+	s.logger.Warn("Bootstrap admin promoted", map[string]interface{}{
+		"user_id": user.ID.String(),
+	})
+	return nil
+}
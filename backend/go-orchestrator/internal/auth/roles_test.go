@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSetUserRole(t *testing.T) {
+	tests := []struct {
+		name          string
+		currentRole   string
+		newRole       string
+		admins        int64
+		lookupErr     error
+		expectedErr   error
+		expectRevoked bool
+	}{
+		{name: "promote to admin", currentRole: RoleUser, newRole: RoleAdmin},
+		{name: "promote to operator", currentRole: RoleUser, newRole: RoleOperator},
+		{name: "demote admin ends sessions", currentRole: RoleAdmin, newRole: RoleUser, admins: 2, expectRevoked: true},
+		{name: "demote the last admin", currentRole: RoleAdmin, newRole: RoleOperator, admins: 1, expectedErr: ErrLastAdmin},
+		{name: "unknown role", currentRole: RoleUser, newRole: "root", expectedErr: ErrInvalidRole},
+		{name: "unknown user", newRole: RoleAdmin, lookupErr: pgx.ErrNoRows, expectedErr: ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := false
+			queries := &mockQueries{
+				getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+					if tt.lookupErr != nil {
+						return sqlc.User{}, tt.lookupErr
+					}
+					return sqlc.User{ID: id, Role: tt.currentRole}, nil
+				},
+				countUsersByRoleFunc: func(ctx context.Context, role string) (int64, error) {
+					return tt.admins, nil
+				},
+				updateUserRoleFunc: func(ctx context.Context, params sqlc.UpdateUserRoleParams) (sqlc.User, error) {
+					updated = true
+					return sqlc.User{ID: params.ID, Role: params.Role}, nil
+				},
+			}
+			revoker := &mockRevoker{}
+			service := &AuthService{
+				queries: queries,
+				withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+					return fn(queries)
+				},
+				revocations: revoker,
+			}
+
+			user, err := service.SetUserRole(context.Background(), uuid.New(), tt.newRole)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if updated {
+					t.Error("the role must not change on error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if user.Role != tt.newRole {
+				t.Errorf("expected role %q, got %q", tt.newRole, user.Role)
+			}
+			if revoked := len(revoker.revokedUsers) > 0; revoked != tt.expectRevoked {
+				t.Errorf("expected sessions revoked=%v, got %v", tt.expectRevoked, revoked)
+			}
+		})
+	}
+}
+
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(hash)
+}
+
+func TestLoginEmbedsStoredRole(t *testing.T) {
+	queries := &mockQueries{}
+	queries.getByEmailFunc = func(ctx context.Context, email string) (sqlc.User, error) {
+		return sqlc.User{ID: uuid.New(), Email: email, PasswordHash: hashPassword(t, "password123"), Role: RoleOperator}, nil
+	}
+
+	var issuedRole string
+	jwtService := &mockJWTService{
+		generateTokenPairFunc: func(userID uuid.UUID, email, name, role string) (*jwt.TokenPair, error) {
+			issuedRole = role
+			return &jwt.TokenPair{AccessToken: "access", RefreshToken: "refresh"}, nil
+		},
+	}
+	service := &AuthService{queries: queries, jwtService: jwtService}
+
+	resp, err := service.LoginUser(context.Background(), &LoginUserRequest{Email: "op@example.com", Password: "password123"}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if issuedRole != RoleOperator || resp.User.Role != RoleOperator {
+		t.Errorf("expected operator role in token and response, got %q and %q", issuedRole, resp.User.Role)
+	}
+}
+
+func TestCreateUserIgnoresBootstrapAdmin(t *testing.T) {
+	var created sqlc.CreateParams
+	queries := &mockQueries{
+		createFunc: func(ctx context.Context, params sqlc.CreateParams) (sqlc.User, error) {
+			created = params
+			return sqlc.User{ID: uuid.New(), Email: params.Email, Role: params.Role}, nil
+		},
+	}
+	service := &AuthService{
+		queries:    queries,
+		jwtService: &mockJWTService{},
+		mailer:     newMockMailer(),
+		cfg:        config.AuthConfig{BootstrapAdminEmail: "owner@example.com"},
+	}
+
+	if _, err := service.CreateUser(context.Background(), &CreateUserRequest{Email: " Owner@Example.com", Password: "password123", Name: "Owner"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created.Role != RoleUser {
+		t.Errorf("registration must not grant %q before the email is verified", created.Role)
+	}
+	if created.Email != "owner@example.com" {
+		t.Errorf("expected normalized email, got %q", created.Email)
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	verifiedAt := time.Now()
+
+	tests := []struct {
+		name          string
+		verifiedAt    *time.Time
+		expectPromote bool
+	}{
+		{name: "verified account is promoted", verifiedAt: &verifiedAt, expectPromote: true},
+		{name: "unverified account is not promoted", verifiedAt: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookedUp string
+			promoted := false
+			queries := &mockQueries{
+				getByEmailFunc: func(ctx context.Context, email string) (sqlc.User, error) {
+					lookedUp = email
+					return sqlc.User{ID: uuid.New(), Email: email, Role: RoleUser, EmailVerifiedAt: tt.verifiedAt}, nil
+				},
+				updateUserRoleFunc: func(ctx context.Context, params sqlc.UpdateUserRoleParams) (sqlc.User, error) {
+					promoted = params.Role == RoleAdmin
+					return sqlc.User{ID: params.ID, Role: params.Role}, nil
+				},
+			}
+			service := &AuthService{
+				queries: queries,
+				cfg:     config.AuthConfig{BootstrapAdminEmail: "Owner@example.com"},
+				logger:  nopLogger{},
+			}
+
+			if err := service.BootstrapAdmin(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if lookedUp != "owner@example.com" {
+				t.Errorf("expected lookup by normalized email, got %q", lookedUp)
+			}
+			if promoted != tt.expectPromote {
+				t.Errorf("expected promoted=%v, got %v", tt.expectPromote, promoted)
+			}
+		})
+	}
+}
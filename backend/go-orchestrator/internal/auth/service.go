@@ -3,29 +3,53 @@ package auth
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/skr1ms/dev2gis/config"
 	"github.com/skr1ms/dev2gis/internal/storage"
 	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
 	"github.com/skr1ms/dev2gis/pkg/jwt"
+	"github.com/skr1ms/dev2gis/pkg/mailer"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
-	db         *storage.DB
-	queries    QueriesInterface
-	jwtService JWTServiceInterface
+	db          *storage.DB
+	queries     QueriesInterface
+	withTx      func(ctx context.Context, fn func(q QueriesInterface) error) error
+	jwtService  JWTServiceInterface
+	revocations TokenRevokerInterface
+	mailer      mailer.Mailer
+	cfg         config.AuthConfig
+	logger      middleware.LoggerInterface
+
+	oidcProviders map[string]*oidcProvider
 }
 
-func NewAuthService(db *storage.DB, jwtService *jwt.JWTService) *AuthService {
+func NewAuthService(db *storage.DB, jwtService *jwt.JWTService, revocations *RevocationStore, mailer mailer.Mailer, cfg config.AuthConfig, logger middleware.LoggerInterface) *AuthService {
 	return &AuthService{
-		db:         db,
-		queries:    db.Queries,
-		jwtService: jwtService,
+		db:      db,
+		queries: db.Queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return db.WithTx(ctx, func(q *sqlc.Queries) error {
+				return fn(q)
+			})
+		},
+		jwtService:  jwtService,
+		revocations: revocations,
+		mailer:      mailer,
+		cfg:         cfg,
+		logger:      logger,
+
+		oidcProviders: newOIDCProviders(cfg.OIDCProviders, logger),
 	}
 }
 
 func (s *AuthService) CreateUser(ctx context.Context, req *CreateUserRequest) (*LoginResponse, error) {
-	_, err := s.queries.GetByEmail(ctx, req.Email)
+	email := normalizeEmail(req.Email)
+
+	_, err := s.queries.GetByEmail(ctx, email)
 	if err == nil {
 		return nil, fmt.Errorf("пользователь с таким email уже существует")
 	}
@@ -36,64 +60,86 @@ func (s *AuthService) CreateUser(ctx context.Context, req *CreateUserRequest) (*
 	}
 
 	user, err := s.queries.Create(ctx, sqlc.CreateParams{
-		Email:        req.Email,
+		Email:        email,
 		PasswordHash: string(hashedPassword),
 		Name:         req.Name,
+		Role:         RoleUser,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("не удалось создать пользователя")
 	}
 
-	tokens, err := s.jwtService.GenerateTokenPair(user.ID, user.Email, user.Name, "user")
+	tokens, err := s.jwtService.GenerateTokenPair(user.ID, user.Email, user.Name, user.Role)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось сгенерировать токены")
 	}
 
+	if err := s.storeRefreshToken(ctx, s.queries, user.ID, tokens); err != nil {
+		return nil, err
+	}
+
+	// The account works without a verified email except for uploads, and the
+	// link can be sent again, so a failure here does not fail registration.
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		s.logger.Error("Failed to send verification email", err, map[string]interface{}{
+			"user_id": user.ID.String(),
+		})
+	}
+
 	return &LoginResponse{
-		User: &UserResponse{
-			ID:    user.ID.String(),
-			Email: user.Email,
-			Name:  user.Name,
-			Role:  "user",
-		},
+		User:   newUserResponse(user),
 		Tokens: tokens,
 	}, nil
 }
 
-func (s *AuthService) LoginUser(ctx context.Context, req *LoginUserRequest) (*LoginResponse, error) {
-	user, err := s.queries.GetByEmail(ctx, req.Email)
+func (s *AuthService) LoginUser(ctx context.Context, req *LoginUserRequest, clientIP string) (*LoginResponse, error) {
+	attempts, err := s.admitLogin(ctx, req.Email, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.queries.GetByEmail(ctx, normalizeEmail(req.Email))
 	if err != nil {
+		s.loginFailed(ctx, attempts)
 		return nil, fmt.Errorf("неверный email или пароль")
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
+		s.loginFailed(ctx, attempts)
 		return nil, fmt.Errorf("неверный email или пароль")
 	}
+	s.loginSucceeded(ctx, attempts)
 
-	role := "user"
+	if user.SuspendedAt != nil {
+		return nil, ErrUserSuspended
+	}
+
+	challenge, err := s.twoFactorChallenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return &LoginResponse{TwoFactor: challenge}, nil
+	}
 
-	tokens, err := s.jwtService.GenerateTokenPair(user.ID, user.Email, user.Name, role)
+	tokens, err := s.jwtService.GenerateTokenPair(user.ID, user.Email, user.Name, user.Role)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось сгенерировать токены")
 	}
 
+	if err := s.storeRefreshToken(ctx, s.queries, user.ID, tokens); err != nil {
+		return nil, err
+	}
+
 	return &LoginResponse{
-		User: &UserResponse{
-			ID:    user.ID.String(),
-			Email: user.Email,
-			Name:  user.Name,
-			Role:  role,
-		},
+		User:   newUserResponse(user),
 		Tokens: tokens,
 	}, nil
 }
 
-func (s *AuthService) RefreshTokens(ctx context.Context, req *RefreshRequest) (*jwt.TokenPair, error) {
-	tokens, err := s.jwtService.RefreshTokens(req.RefreshToken)
-	if err != nil {
-		return nil, fmt.Errorf("недействительный токен обновления")
-	}
-
-	return tokens, nil
+// normalizeEmail is applied to every email stored or looked up. Emails are
+// unique regardless of case, see idx_users_email_lower.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
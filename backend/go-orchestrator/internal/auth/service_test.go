@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
 	"github.com/skr1ms/dev2gis/pkg/jwt"
 	"golang.org/x/crypto/bcrypt"
@@ -17,6 +18,60 @@ type mockQueries struct {
 	getByIDFunc    func(ctx context.Context, id uuid.UUID) (sqlc.User, error)
 	getByEmailFunc func(ctx context.Context, email string) (sqlc.User, error)
 	deleteFunc     func(ctx context.Context, id uuid.UUID) error
+
+	updateUserRoleFunc   func(ctx context.Context, params sqlc.UpdateUserRoleParams) (sqlc.User, error)
+	countUsersByRoleFunc func(ctx context.Context, role string) (int64, error)
+	listUsersFunc        func(ctx context.Context, params sqlc.ListUsersParams) ([]sqlc.User, error)
+	updateSuspensionFunc func(ctx context.Context, params sqlc.UpdateUserSuspensionParams) (sqlc.User, error)
+
+	createRefreshTokenFunc       func(ctx context.Context, params sqlc.CreateRefreshTokenParams) error
+	getRefreshTokenFunc          func(ctx context.Context, id uuid.UUID) (sqlc.RefreshToken, error)
+	rotateRefreshTokenFunc       func(ctx context.Context, params sqlc.RotateRefreshTokenParams) (int64, error)
+	revokeRefreshTokenFamilyFunc func(ctx context.Context, params sqlc.RevokeRefreshTokenFamilyParams) error
+	revokeUserRefreshTokensFunc  func(ctx context.Context, params sqlc.RevokeUserRefreshTokensParams) error
+
+	createAPIKeyFunc    func(ctx context.Context, params sqlc.CreateAPIKeyParams) (sqlc.ApiKey, error)
+	countAPIKeysFunc    func(ctx context.Context, userID uuid.UUID) (int64, error)
+	deleteAPIKeyFunc    func(ctx context.Context, params sqlc.DeleteAPIKeyParams) (int64, error)
+	getAPIKeyByHashFunc func(ctx context.Context, keyHash string) (sqlc.GetAPIKeyByHashRow, error)
+	touchAPIKeyFunc     func(ctx context.Context, params sqlc.TouchAPIKeyParams) error
+
+	createOIDCLoginStateFunc  func(ctx context.Context, params sqlc.CreateOIDCLoginStateParams) error
+	consumeOIDCLoginStateFunc func(ctx context.Context, state string) (sqlc.OidcLoginState, error)
+	getUserByIdentityFunc     func(ctx context.Context, params sqlc.GetUserByIdentityParams) (sqlc.User, error)
+	createUserIdentityFunc    func(ctx context.Context, params sqlc.CreateUserIdentityParams) error
+
+	markEmailVerifiedFunc  func(ctx context.Context, params sqlc.MarkEmailVerifiedParams) error
+	updateUserPasswordFunc func(ctx context.Context, params sqlc.UpdateUserPasswordParams) error
+	updateUserProfileFunc  func(ctx context.Context, params sqlc.UpdateUserProfileParams) (sqlc.User, error)
+	scheduleDeletionFunc   func(ctx context.Context, userID uuid.UUID) error
+	lastOwnedOrgsFunc      func(ctx context.Context, userID uuid.UUID) (int64, error)
+	deleteSoleOrgsFunc     func(ctx context.Context, userID uuid.UUID) error
+	createUserTokenFunc    func(ctx context.Context, params sqlc.CreateUserTokenParams) error
+	consumeUserTokenFunc   func(ctx context.Context, params sqlc.ConsumeUserTokenParams) (sqlc.UserToken, error)
+	deleteUserTokensFunc   func(ctx context.Context, params sqlc.DeleteUserTokensParams) error
+
+	getUserTOTPFunc              func(ctx context.Context, userID uuid.UUID) (sqlc.UserTotp, error)
+	upsertUserTOTPFunc           func(ctx context.Context, params sqlc.UpsertUserTOTPParams) error
+	confirmUserTOTPFunc          func(ctx context.Context, params sqlc.ConfirmUserTOTPParams) (int64, error)
+	useTOTPStepFunc              func(ctx context.Context, params sqlc.UseTOTPStepParams) (int64, error)
+	deleteUserTOTPFunc           func(ctx context.Context, userID uuid.UUID) error
+	createRecoveryCodeFunc       func(ctx context.Context, params sqlc.CreateRecoveryCodeParams) error
+	useRecoveryCodeFunc          func(ctx context.Context, params sqlc.UseRecoveryCodeParams) (int64, error)
+	deleteRecoveryCodesFunc      func(ctx context.Context, userID uuid.UUID) error
+	createTwoFactorChallengeFunc func(ctx context.Context, params sqlc.CreateTwoFactorChallengeParams) error
+	getTwoFactorChallengeFunc    func(ctx context.Context, tokenHash string) (sqlc.TwoFactorChallenge, error)
+	recordTwoFactorFailureFunc   func(ctx context.Context, tokenHash string) (int32, error)
+	deleteTwoFactorChallengeFunc func(ctx context.Context, tokenHash string) (int64, error)
+	listTwoFactorRolesFunc       func(ctx context.Context) ([]string, error)
+	isTwoFactorRequiredFunc      func(ctx context.Context, role string) (bool, error)
+	addTwoFactorRoleFunc         func(ctx context.Context, role string) error
+	deleteTwoFactorRolesFunc     func(ctx context.Context) error
+	countLoginAttemptFunc        func(ctx context.Context, params sqlc.CountLoginAttemptParams) (int32, error)
+	getLoginAttemptFunc          func(ctx context.Context, params sqlc.GetLoginAttemptParams) (sqlc.LoginAttempt, error)
+	blockLoginFunc               func(ctx context.Context, params sqlc.BlockLoginParams) error
+	forgiveLoginAttemptFunc      func(ctx context.Context, params sqlc.ForgiveLoginAttemptParams) error
+	deleteLoginAttemptsFunc      func(ctx context.Context, params sqlc.DeleteLoginAttemptsParams) (int64, error)
 }
 
 func (m *mockQueries) Create(ctx context.Context, params sqlc.CreateParams) (sqlc.User, error) {
@@ -47,9 +102,444 @@ func (m *mockQueries) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+func (m *mockQueries) UpdateUserRole(ctx context.Context, params sqlc.UpdateUserRoleParams) (sqlc.User, error) {
+	if m.updateUserRoleFunc != nil {
+		return m.updateUserRoleFunc(ctx, params)
+	}
+	return sqlc.User{ID: params.ID, Role: params.Role}, nil
+}
+
+func (m *mockQueries) CountUsersByRole(ctx context.Context, role string) (int64, error) {
+	if m.countUsersByRoleFunc != nil {
+		return m.countUsersByRoleFunc(ctx, role)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) ListUsers(ctx context.Context, params sqlc.ListUsersParams) ([]sqlc.User, error) {
+	if m.listUsersFunc != nil {
+		return m.listUsersFunc(ctx, params)
+	}
+	return []sqlc.User{}, nil
+}
+
+func (m *mockQueries) UpdateUserSuspension(ctx context.Context, params sqlc.UpdateUserSuspensionParams) (sqlc.User, error) {
+	if m.updateSuspensionFunc != nil {
+		return m.updateSuspensionFunc(ctx, params)
+	}
+	return sqlc.User{ID: params.ID, SuspendedAt: params.SuspendedAt}, nil
+}
+
+func (m *mockQueries) CreateRefreshToken(ctx context.Context, params sqlc.CreateRefreshTokenParams) error {
+	if m.createRefreshTokenFunc != nil {
+		return m.createRefreshTokenFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) GetRefreshToken(ctx context.Context, id uuid.UUID) (sqlc.RefreshToken, error) {
+	if m.getRefreshTokenFunc != nil {
+		return m.getRefreshTokenFunc(ctx, id)
+	}
+	return sqlc.RefreshToken{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) RotateRefreshToken(ctx context.Context, params sqlc.RotateRefreshTokenParams) (int64, error) {
+	if m.rotateRefreshTokenFunc != nil {
+		return m.rotateRefreshTokenFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) RevokeRefreshTokenFamily(ctx context.Context, params sqlc.RevokeRefreshTokenFamilyParams) error {
+	if m.revokeRefreshTokenFamilyFunc != nil {
+		return m.revokeRefreshTokenFamilyFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) RevokeUserRefreshTokens(ctx context.Context, params sqlc.RevokeUserRefreshTokensParams) error {
+	if m.revokeUserRefreshTokensFunc != nil {
+		return m.revokeUserRefreshTokensFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) DeleteExpiredRefreshTokens(ctx context.Context, expiresAt time.Time) error {
+	return nil
+}
+
+func (m *mockQueries) CreateAPIKey(ctx context.Context, params sqlc.CreateAPIKeyParams) (sqlc.ApiKey, error) {
+	if m.createAPIKeyFunc != nil {
+		return m.createAPIKeyFunc(ctx, params)
+	}
+	return sqlc.ApiKey{ID: uuid.New(), UserID: params.UserID, Name: params.Name, Prefix: params.Prefix, KeyHash: params.KeyHash, Scopes: params.Scopes, ExpiresAt: params.ExpiresAt}, nil
+}
+
+func (m *mockQueries) ListUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]sqlc.ApiKey, error) {
+	return nil, nil
+}
+
+func (m *mockQueries) CountUserAPIKeys(ctx context.Context, userID uuid.UUID) (int64, error) {
+	if m.countAPIKeysFunc != nil {
+		return m.countAPIKeysFunc(ctx, userID)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) DeleteAPIKey(ctx context.Context, params sqlc.DeleteAPIKeyParams) (int64, error) {
+	if m.deleteAPIKeyFunc != nil {
+		return m.deleteAPIKeyFunc(ctx, params)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) GetAPIKeyByHash(ctx context.Context, keyHash string) (sqlc.GetAPIKeyByHashRow, error) {
+	if m.getAPIKeyByHashFunc != nil {
+		return m.getAPIKeyByHashFunc(ctx, keyHash)
+	}
+	return sqlc.GetAPIKeyByHashRow{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) TouchAPIKey(ctx context.Context, params sqlc.TouchAPIKeyParams) error {
+	if m.touchAPIKeyFunc != nil {
+		return m.touchAPIKeyFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) CreateOIDCLoginState(ctx context.Context, params sqlc.CreateOIDCLoginStateParams) error {
+	if m.createOIDCLoginStateFunc != nil {
+		return m.createOIDCLoginStateFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) ConsumeOIDCLoginState(ctx context.Context, state string) (sqlc.OidcLoginState, error) {
+	if m.consumeOIDCLoginStateFunc != nil {
+		return m.consumeOIDCLoginStateFunc(ctx, state)
+	}
+	return sqlc.OidcLoginState{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) DeleteExpiredOIDCLoginStates(ctx context.Context, expiresAt time.Time) error {
+	return nil
+}
+
+func (m *mockQueries) GetUserByIdentity(ctx context.Context, params sqlc.GetUserByIdentityParams) (sqlc.User, error) {
+	if m.getUserByIdentityFunc != nil {
+		return m.getUserByIdentityFunc(ctx, params)
+	}
+	return sqlc.User{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) CreateUserIdentity(ctx context.Context, params sqlc.CreateUserIdentityParams) error {
+	if m.createUserIdentityFunc != nil {
+		return m.createUserIdentityFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) MarkEmailVerified(ctx context.Context, params sqlc.MarkEmailVerifiedParams) error {
+	if m.markEmailVerifiedFunc != nil {
+		return m.markEmailVerifiedFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) UpdateUserPassword(ctx context.Context, params sqlc.UpdateUserPasswordParams) error {
+	if m.updateUserPasswordFunc != nil {
+		return m.updateUserPasswordFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) UpdateUserProfile(ctx context.Context, params sqlc.UpdateUserProfileParams) (sqlc.User, error) {
+	if m.updateUserProfileFunc != nil {
+		return m.updateUserProfileFunc(ctx, params)
+	}
+	return sqlc.User{ID: params.ID, Name: params.Name, Email: params.Email}, nil
+}
+
+func (m *mockQueries) ScheduleUserStorageDeletion(ctx context.Context, userID uuid.UUID) error {
+	if m.scheduleDeletionFunc != nil {
+		return m.scheduleDeletionFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockQueries) CountOrganizationsLastOwnedByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	if m.lastOwnedOrgsFunc != nil {
+		return m.lastOwnedOrgsFunc(ctx, userID)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) DeleteUserSoleOrganizations(ctx context.Context, userID uuid.UUID) error {
+	if m.deleteSoleOrgsFunc != nil {
+		return m.deleteSoleOrgsFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockQueries) DeleteUserPersonalProjects(ctx context.Context, createdBy *uuid.UUID) error {
+	return nil
+}
+
+func (m *mockQueries) CreateUserToken(ctx context.Context, params sqlc.CreateUserTokenParams) error {
+	if m.createUserTokenFunc != nil {
+		return m.createUserTokenFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) ConsumeUserToken(ctx context.Context, params sqlc.ConsumeUserTokenParams) (sqlc.UserToken, error) {
+	if m.consumeUserTokenFunc != nil {
+		return m.consumeUserTokenFunc(ctx, params)
+	}
+	return sqlc.UserToken{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) DeleteUserTokens(ctx context.Context, params sqlc.DeleteUserTokensParams) error {
+	if m.deleteUserTokensFunc != nil {
+		return m.deleteUserTokensFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) DeleteExpiredUserTokens(ctx context.Context, expiresAt time.Time) error {
+	return nil
+}
+
+func (m *mockQueries) GetUserTOTP(ctx context.Context, userID uuid.UUID) (sqlc.UserTotp, error) {
+	if m.getUserTOTPFunc != nil {
+		return m.getUserTOTPFunc(ctx, userID)
+	}
+	return sqlc.UserTotp{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) UpsertUserTOTP(ctx context.Context, params sqlc.UpsertUserTOTPParams) error {
+	if m.upsertUserTOTPFunc != nil {
+		return m.upsertUserTOTPFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) ConfirmUserTOTP(ctx context.Context, params sqlc.ConfirmUserTOTPParams) (int64, error) {
+	if m.confirmUserTOTPFunc != nil {
+		return m.confirmUserTOTPFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) UseTOTPStep(ctx context.Context, params sqlc.UseTOTPStepParams) (int64, error) {
+	if m.useTOTPStepFunc != nil {
+		return m.useTOTPStepFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error {
+	if m.deleteUserTOTPFunc != nil {
+		return m.deleteUserTOTPFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockQueries) CreateRecoveryCode(ctx context.Context, params sqlc.CreateRecoveryCodeParams) error {
+	if m.createRecoveryCodeFunc != nil {
+		return m.createRecoveryCodeFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) UseRecoveryCode(ctx context.Context, params sqlc.UseRecoveryCodeParams) (int64, error) {
+	if m.useRecoveryCodeFunc != nil {
+		return m.useRecoveryCodeFunc(ctx, params)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	if m.deleteRecoveryCodesFunc != nil {
+		return m.deleteRecoveryCodesFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockQueries) CreateTwoFactorChallenge(ctx context.Context, params sqlc.CreateTwoFactorChallengeParams) error {
+	if m.createTwoFactorChallengeFunc != nil {
+		return m.createTwoFactorChallengeFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) GetTwoFactorChallenge(ctx context.Context, tokenHash string) (sqlc.TwoFactorChallenge, error) {
+	if m.getTwoFactorChallengeFunc != nil {
+		return m.getTwoFactorChallengeFunc(ctx, tokenHash)
+	}
+	return sqlc.TwoFactorChallenge{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) RecordTwoFactorChallengeFailure(ctx context.Context, tokenHash string) (int32, error) {
+	if m.recordTwoFactorFailureFunc != nil {
+		return m.recordTwoFactorFailureFunc(ctx, tokenHash)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) DeleteTwoFactorChallenge(ctx context.Context, tokenHash string) (int64, error) {
+	if m.deleteTwoFactorChallengeFunc != nil {
+		return m.deleteTwoFactorChallengeFunc(ctx, tokenHash)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) ListTwoFactorRequiredRoles(ctx context.Context) ([]string, error) {
+	if m.listTwoFactorRolesFunc != nil {
+		return m.listTwoFactorRolesFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockQueries) IsTwoFactorRequired(ctx context.Context, role string) (bool, error) {
+	if m.isTwoFactorRequiredFunc != nil {
+		return m.isTwoFactorRequiredFunc(ctx, role)
+	}
+	return false, nil
+}
+
+func (m *mockQueries) AddTwoFactorRequiredRole(ctx context.Context, role string) error {
+	if m.addTwoFactorRoleFunc != nil {
+		return m.addTwoFactorRoleFunc(ctx, role)
+	}
+	return nil
+}
+
+func (m *mockQueries) DeleteTwoFactorRequiredRoles(ctx context.Context) error {
+	if m.deleteTwoFactorRolesFunc != nil {
+		return m.deleteTwoFactorRolesFunc(ctx)
+	}
+	return nil
+}
+
+func (m *mockQueries) DeleteExpiredTwoFactorChallenges(ctx context.Context, expiresAt time.Time) error {
+	return nil
+}
+
+func (m *mockQueries) CountLoginAttempt(ctx context.Context, params sqlc.CountLoginAttemptParams) (int32, error) {
+	if m.countLoginAttemptFunc != nil {
+		return m.countLoginAttemptFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) GetLoginAttempt(ctx context.Context, params sqlc.GetLoginAttemptParams) (sqlc.LoginAttempt, error) {
+	if m.getLoginAttemptFunc != nil {
+		return m.getLoginAttemptFunc(ctx, params)
+	}
+	return sqlc.LoginAttempt{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) BlockLogin(ctx context.Context, params sqlc.BlockLoginParams) error {
+	if m.blockLoginFunc != nil {
+		return m.blockLoginFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) ForgiveLoginAttempt(ctx context.Context, params sqlc.ForgiveLoginAttemptParams) error {
+	if m.forgiveLoginAttemptFunc != nil {
+		return m.forgiveLoginAttemptFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) DeleteLoginAttempts(ctx context.Context, params sqlc.DeleteLoginAttemptsParams) (int64, error) {
+	if m.deleteLoginAttemptsFunc != nil {
+		return m.deleteLoginAttemptsFunc(ctx, params)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) DeleteExpiredLoginAttempts(ctx context.Context, params sqlc.DeleteExpiredLoginAttemptsParams) error {
+	return nil
+}
+
+// withRefreshTokenStore backs the refresh token queries with a map, applying
+// the same conditions as the SQL.
+func (m *mockQueries) withRefreshTokenStore() map[uuid.UUID]*sqlc.RefreshToken {
+	store := make(map[uuid.UUID]*sqlc.RefreshToken)
+
+	m.createRefreshTokenFunc = func(ctx context.Context, params sqlc.CreateRefreshTokenParams) error {
+		store[params.ID] = &sqlc.RefreshToken{
+			ID:        params.ID,
+			UserID:    params.UserID,
+			FamilyID:  params.FamilyID,
+			TokenHash: params.TokenHash,
+			ExpiresAt: params.ExpiresAt,
+		}
+		return nil
+	}
+	m.getRefreshTokenFunc = func(ctx context.Context, id uuid.UUID) (sqlc.RefreshToken, error) {
+		token, ok := store[id]
+		if !ok {
+			return sqlc.RefreshToken{}, pgx.ErrNoRows
+		}
+		return *token, nil
+	}
+	m.rotateRefreshTokenFunc = func(ctx context.Context, params sqlc.RotateRefreshTokenParams) (int64, error) {
+		token, ok := store[params.ID]
+		if !ok || token.RevokedAt != nil {
+			return 0, nil
+		}
+		token.RevokedAt = params.RevokedAt
+		token.ReplacedBy = params.ReplacedBy
+		return 1, nil
+	}
+	m.revokeRefreshTokenFamilyFunc = func(ctx context.Context, params sqlc.RevokeRefreshTokenFamilyParams) error {
+		for _, token := range store {
+			if token.FamilyID == params.FamilyID && token.RevokedAt == nil {
+				token.RevokedAt = params.RevokedAt
+			}
+		}
+		return nil
+	}
+	m.revokeUserRefreshTokensFunc = func(ctx context.Context, params sqlc.RevokeUserRefreshTokensParams) error {
+		for _, token := range store {
+			if token.UserID == params.UserID && token.RevokedAt == nil {
+				token.RevokedAt = params.RevokedAt
+			}
+		}
+		return nil
+	}
+
+	return store
+}
+
+type mockRevoker struct {
+	revokedTokens []*jwt.CustomClaims
+	revokedUsers  []uuid.UUID
+	revokedBefore []time.Time
+}
+
+func (m *mockRevoker) RevokeToken(ctx context.Context, claims *jwt.CustomClaims) error {
+	m.revokedTokens = append(m.revokedTokens, claims)
+	return nil
+}
+
+func (m *mockRevoker) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	return m.RevokeUserBefore(ctx, userID, time.Now())
+}
+
+func (m *mockRevoker) RevokeUserBefore(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	m.revokedUsers = append(m.revokedUsers, userID)
+	m.revokedBefore = append(m.revokedBefore, before)
+	return nil
+}
+
 type mockJWTService struct {
 	generateTokenPairFunc func(userID uuid.UUID, email, name, role string) (*jwt.TokenPair, error)
-	refreshTokensFunc     func(refreshToken string) (*jwt.TokenPair, error)
 }
 
 func (m *mockJWTService) GenerateTokenPair(userID uuid.UUID, email, name, role string) (*jwt.TokenPair, error) {
@@ -62,16 +552,17 @@ func (m *mockJWTService) GenerateTokenPair(userID uuid.UUID, email, name, role s
 	}, nil
 }
 
-func (m *mockJWTService) RefreshTokens(refreshToken string) (*jwt.TokenPair, error) {
-	if m.refreshTokensFunc != nil {
-		return m.refreshTokensFunc(refreshToken)
-	}
+func (m *mockJWTService) RotateTokenPair(claims *jwt.CustomClaims) (*jwt.TokenPair, error) {
 	return &jwt.TokenPair{
 		AccessToken:  "new-access-token",
 		RefreshToken: "new-refresh-token",
 	}, nil
 }
 
+func (m *mockJWTService) ValidateRefreshToken(refreshToken string) (*jwt.CustomClaims, error) {
+	return nil, errors.New("invalid refresh token")
+}
+
 func TestCreateUser(t *testing.T) {
 	userID := uuid.New()
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
@@ -185,6 +676,7 @@ func TestCreateUser(t *testing.T) {
 			service := &AuthService{
 				queries:    queries,
 				jwtService: jwtService,
+				mailer:     newMockMailer(),
 			}
 
 			result, err := service.CreateUser(context.Background(), tt.request)
@@ -318,7 +810,7 @@ func TestLoginUser(t *testing.T) {
 				jwtService: jwtService,
 			}
 
-			result, err := service.LoginUser(context.Background(), tt.request)
+			result, err := service.LoginUser(context.Background(), tt.request, "192.0.2.1")
 
 			if tt.expectError {
 				if err == nil {
@@ -347,94 +839,172 @@ func TestLoginUser(t *testing.T) {
 	}
 }
 
+func newTestRefreshService(queries *mockQueries) *AuthService {
+	return &AuthService{
+		queries: queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return fn(queries)
+		},
+		jwtService:  jwt.NewJWTService("access-secret", "refresh-secret", time.Minute, time.Hour),
+		revocations: &mockRevoker{},
+	}
+}
+
 func TestRefreshTokens(t *testing.T) {
+	userID := uuid.New()
+	queries := &mockQueries{}
+	queries.withRefreshTokenStore()
+	service := newTestRefreshService(queries)
+
+	login, err := service.jwtService.GenerateTokenPair(userID, "test@example.com", "Test User", "user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := service.storeRefreshToken(context.Background(), queries, userID, login); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
 	tests := []struct {
-		name        string
-		request     *RefreshRequest
-		setupMock   func(*mockJWTService)
-		expectError bool
-		errorMsg    string
+		name         string
+		refreshToken string
+		expectedErr  error
 	}{
-		{
-			name: "successful token refresh",
-			request: &RefreshRequest{
-				RefreshToken: "valid-refresh-token",
-			},
-			setupMock: func(mj *mockJWTService) {
-				mj.refreshTokensFunc = func(refreshToken string) (*jwt.TokenPair, error) {
-					return &jwt.TokenPair{
-						AccessToken:  "new-access-token",
-						RefreshToken: "new-refresh-token",
-					}, nil
-				}
-			},
-			expectError: false,
-		},
-		{
-			name: "invalid refresh token",
-			request: &RefreshRequest{
-				RefreshToken: "invalid-token",
-			},
-			setupMock: func(mj *mockJWTService) {
-				mj.refreshTokensFunc = func(refreshToken string) (*jwt.TokenPair, error) {
-					return nil, errors.New("invalid token")
-				}
-			},
-			expectError: true,
-			errorMsg:    "invalid refresh token",
-		},
-		{
-			name: "expired refresh token",
-			request: &RefreshRequest{
-				RefreshToken: "expired-token",
-			},
-			setupMock: func(mj *mockJWTService) {
-				mj.refreshTokensFunc = func(refreshToken string) (*jwt.TokenPair, error) {
-					return nil, errors.New("token expired")
-				}
-			},
-			expectError: true,
-			errorMsg:    "invalid refresh token",
-		},
+		{name: "malformed token", refreshToken: "invalid-token", expectedErr: ErrInvalidRefreshToken},
+		{name: "signed but never issued", refreshToken: unstoredRefreshToken(t, service, userID), expectedErr: ErrInvalidRefreshToken},
+		{name: "rotates a valid token", refreshToken: login.RefreshToken},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			jwtService := &mockJWTService{}
+			tokens, err := service.RefreshTokens(context.Background(), &RefreshRequest{RefreshToken: tt.refreshToken})
 
-			if tt.setupMock != nil {
-				tt.setupMock(jwtService)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
 			}
-
-			service := &AuthService{
-				jwtService: jwtService,
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
 			}
-
-			result, err := service.RefreshTokens(context.Background(), tt.request)
-
-			if tt.expectError {
-				if err == nil {
-					t.Errorf("expected error but got none")
-				}
-				if err != nil && tt.errorMsg != "" && err.Error() != tt.errorMsg {
-					t.Errorf("expected error message '%s', got '%s'", tt.errorMsg, err.Error())
-				}
-			} else {
-				if err != nil {
-					t.Errorf("unexpected error: %v", err)
-				}
-				if result == nil {
-					t.Errorf("expected result but got nil")
-				}
-				if result != nil {
-					if result.AccessToken == "" {
-						t.Errorf("expected access token but got empty string")
-					}
-					if result.RefreshToken == "" {
-						t.Errorf("expected refresh token but got empty string")
-					}
-				}
+			if tokens.RefreshToken == tt.refreshToken {
+				t.Error("expected a new refresh token")
+			}
+			if tokens.FamilyID != login.FamilyID {
+				t.Errorf("expected family %s to be kept, got %s", login.FamilyID, tokens.FamilyID)
 			}
 		})
 	}
 }
+
+func unstoredRefreshToken(t *testing.T, service *AuthService, userID uuid.UUID) string {
+	t.Helper()
+
+	tokens, err := service.jwtService.GenerateTokenPair(userID, "test@example.com", "Test User", "user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return tokens.RefreshToken
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	userID := uuid.New()
+	queries := &mockQueries{}
+	store := queries.withRefreshTokenStore()
+	service := newTestRefreshService(queries)
+	ctx := context.Background()
+
+	login, _ := service.jwtService.GenerateTokenPair(userID, "test@example.com", "Test User", "user")
+	if err := service.storeRefreshToken(ctx, queries, userID, login); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rotated, err := service.RefreshTokens(ctx, &RefreshRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := service.RefreshTokens(ctx, &RefreshRequest{RefreshToken: login.RefreshToken}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected %v, got %v", ErrRefreshTokenReused, err)
+	}
+
+	if store[rotated.RefreshTokenID].RevokedAt == nil {
+		t.Fatal("expected the token issued by the rotation to be revoked")
+	}
+	if _, err := service.RefreshTokens(ctx, &RefreshRequest{RefreshToken: rotated.RefreshToken}); err == nil {
+		t.Fatal("expected the revoked family to be rejected")
+	}
+}
+
+func TestLogout(t *testing.T) {
+	userID := uuid.New()
+	queries := &mockQueries{}
+	store := queries.withRefreshTokenStore()
+	service := newTestRefreshService(queries)
+	ctx := context.Background()
+
+	login, _ := service.jwtService.GenerateTokenPair(userID, "test@example.com", "Test User", "user")
+	if err := service.storeRefreshToken(ctx, queries, userID, login); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other, _ := service.jwtService.GenerateTokenPair(userID, "test@example.com", "Test User", "user")
+	if err := service.storeRefreshToken(ctx, queries, userID, other); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	accessClaims := &jwt.CustomClaims{UserID: userID}
+	if err := service.Logout(ctx, &LogoutRequest{RefreshToken: login.RefreshToken}, accessClaims); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if store[login.RefreshTokenID].RevokedAt == nil {
+		t.Error("expected the refresh token to be revoked")
+	}
+	if store[other.RefreshTokenID].RevokedAt != nil {
+		t.Error("logout must not end the user's other sessions")
+	}
+	if _, err := service.RefreshTokens(ctx, &RefreshRequest{RefreshToken: login.RefreshToken}); err == nil {
+		t.Error("expected refresh after logout to fail")
+	}
+	if revoker := service.revocations.(*mockRevoker); len(revoker.revokedTokens) != 1 || revoker.revokedTokens[0] != accessClaims {
+		t.Error("expected the access token of the session to be revoked")
+	}
+	if err := service.Logout(ctx, &LogoutRequest{RefreshToken: "invalid-token"}, nil); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected %v, got %v", ErrInvalidRefreshToken, err)
+	}
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	userID := uuid.New()
+	queries := &mockQueries{}
+	store := queries.withRefreshTokenStore()
+	service := newTestRefreshService(queries)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		tokens, _ := service.jwtService.GenerateTokenPair(userID, "test@example.com", "Test User", "user")
+		if err := service.storeRefreshToken(ctx, queries, userID, tokens); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if err := service.RevokeUserSessions(ctx, userID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, token := range store {
+		if token.RevokedAt == nil {
+			t.Errorf("expected refresh token %s to be revoked", token.ID)
+		}
+	}
+	if revoker := service.revocations.(*mockRevoker); len(revoker.revokedUsers) != 1 || revoker.revokedUsers[0] != userID {
+		t.Error("expected the user's access tokens to be revoked")
+	}
+
+	queries.getByIDFunc = func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+		return sqlc.User{}, pgx.ErrNoRows
+	}
+	if err := service.RevokeUserSessions(ctx, uuid.New()); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+}
@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+// signingKeySyncEvery is how often each replica reloads the signing keys and
+// rotates them when due.
+const signingKeySyncEvery = time.Minute
+
+// signingKeyLockKey serializes key rotation across replicas.
+const signingKeyLockKey int64 = 0x64326700_00000004
+
+// SigningKeyStore keeps the asymmetric access token signing keys in Postgres
+// and loads them into a jwt.KeySet, so every replica signs with the same key
+// and publishes the same JWKS.
+//
+// Each key signs for JWTKeyRotationInterval. Its successor is created
+// JWTKeyOverlap before it retires and is published right away, so verifiers
+// that cache the JWKS know it before the first token signed with it. A
+// retired key stays published for JWTKeyOverlap more, which is at least the
+// access token lifetime, so the tokens it signed stay verifiable until they
+// expire.
+//
+// Private keys are stored encrypted with a key derived from the access token
+// secret, which is not used for signing in this mode.
+type SigningKeyStore struct {
+	queries SigningKeyQueriesInterface
+	withTx  func(ctx context.Context, fn func(q SigningKeyQueriesInterface) error) error
+	keys    *jwt.KeySet
+	aead    cipher.AEAD
+	cfg     config.AuthConfig
+	overlap time.Duration
+	logger  middleware.LoggerInterface
+}
+
+func NewSigningKeyStore(db *storage.DB, keys *jwt.KeySet, cfg config.AuthConfig, logger middleware.LoggerInterface) *SigningKeyStore {
+	return newSigningKeyStore(db.Queries, func(ctx context.Context, fn func(q SigningKeyQueriesInterface) error) error {
+		return db.WithTx(ctx, func(q *sqlc.Queries) error {
+			return fn(q)
+		})
+	}, keys, cfg, logger)
+}
+
+func newSigningKeyStore(
+	queries SigningKeyQueriesInterface,
+	withTx func(ctx context.Context, fn func(q SigningKeyQueriesInterface) error) error,
+	keys *jwt.KeySet,
+	cfg config.AuthConfig,
+	logger middleware.LoggerInterface,
+) *SigningKeyStore {
+	secret := sha256.Sum256([]byte("jwt-signing-keys:" + cfg.AccessTokenSecret))
+	block, _ := aes.NewCipher(secret[:])
+	aead, _ := cipher.NewGCM(block)
+
+	return &SigningKeyStore{
+		queries: queries,
+		withTx:  withTx,
+		keys:    keys,
+		aead:    aead,
+		cfg:     cfg,
+		overlap: max(cfg.JWTKeyOverlap, cfg.JWTAccessTokenTTL),
+		logger:  logger,
+	}
+}
+
+// Run keeps the keys in sync until ctx is cancelled. The first Sync is
+// expected to have been done by the caller before tokens are issued.
+func (s *SigningKeyStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(signingKeySyncEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to sync JWT signing keys", err)
+			}
+		}
+	}
+}
+
+// Sync rotates the keys when due and loads them into the key set.
+func (s *SigningKeyStore) Sync(ctx context.Context) error {
+	return s.sync(ctx, time.Now())
+}
+
+func (s *SigningKeyStore) sync(ctx context.Context, now time.Time) error {
+	err := s.withTx(ctx, func(q SigningKeyQueriesInterface) error {
+		if err := q.AdvisoryXactLock(ctx, signingKeyLockKey); err != nil {
+			return fmt.Errorf("не удалось захватить блокировку ключей подписи: %w", err)
+		}
+		keys, err := s.load(ctx, q, now)
+		if err != nil {
+			return err
+		}
+		if err := s.rotate(ctx, q, keys, now); err != nil {
+			return err
+		}
+		if err := q.DeleteExpiredSigningKeys(ctx, now); err != nil {
+			return fmt.Errorf("не удалось удалить истекшие ключи подписи: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.load(ctx, s.queries, now)
+	if err != nil {
+		return err
+	}
+	s.keys.Replace(keys)
+	return nil
+}
+
+// load returns the unexpired keys. Keys that cannot be decrypted, because
+// the access token secret changed, are skipped; rotation then replaces them.
+func (s *SigningKeyStore) load(ctx context.Context, q SigningKeyQueriesInterface, now time.Time) ([]jwt.SigningKey, error) {
+	rows, err := q.ListSigningKeys(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить ключи подписи: %w", err)
+	}
+
+	keys := make([]jwt.SigningKey, 0, len(rows))
+	for _, row := range rows {
+		privateKey, err := s.open(row.PrivateKey)
+		if err != nil {
+			s.logger.Warn("Skipping unreadable JWT signing key", map[string]interface{}{
+				"kid":   row.Kid,
+				"error": err.Error(),
+			})
+			continue
+		}
+		keys = append(keys, jwt.SigningKey{
+			ID:          row.Kid,
+			Algorithm:   row.Algorithm,
+			PrivateKey:  privateKey,
+			ActivatesAt: row.ActivatesAt,
+			RetiresAt:   row.RetiresAt,
+			ExpiresAt:   row.ExpiresAt,
+		})
+	}
+	return keys, nil
+}
+
+// rotate creates the key that signs now if there is none, and the next one
+// once the current key is within the overlap of retiring. A change of
+// algorithm takes effect right away instead of waiting for the rotation.
+func (s *SigningKeyStore) rotate(ctx context.Context, q SigningKeyQueriesInterface, keys []jwt.SigningKey, now time.Time) error {
+	var current, next *jwt.SigningKey
+	for i := range keys {
+		key := &keys[i]
+		if key.Algorithm != s.cfg.JWTSigningAlgorithm {
+			continue
+		}
+		switch {
+		case key.ActivatesAt.After(now):
+			next = key
+		case key.RetiresAt.After(now):
+			current = key
+		}
+	}
+
+	if current == nil {
+		if next != nil && !next.ActivatesAt.After(now.Add(signingKeySyncEvery)) {
+			return nil
+		}
+		return s.create(ctx, q, now)
+	}
+	if next == nil && !current.RetiresAt.After(now.Add(s.overlap)) {
+		return s.create(ctx, q, current.RetiresAt)
+	}
+	return nil
+}
+
+func (s *SigningKeyStore) create(ctx context.Context, q SigningKeyQueriesInterface, activatesAt time.Time) error {
+	privateKey, err := jwt.GenerateSigningKey(s.cfg.JWTSigningAlgorithm)
+	if err != nil {
+		return fmt.Errorf("не удалось сгенерировать ключ подписи: %w", err)
+	}
+	sealed, err := s.seal(privateKey)
+	if err != nil {
+		return err
+	}
+
+	kid := uuid.NewString()
+	retiresAt := activatesAt.Add(s.cfg.JWTKeyRotationInterval)
+	if err := q.CreateSigningKey(ctx, sqlc.CreateSigningKeyParams{
+		Kid:         kid,
+		Algorithm:   s.cfg.JWTSigningAlgorithm,
+		PrivateKey:  sealed,
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		ExpiresAt:   retiresAt.Add(s.overlap),
+	}); err != nil {
+		return fmt.Errorf("не удалось сохранить ключ подписи: %w", err)
+	}
+
+	s.logger.Info("JWT signing key created", map[string]interface{}{
+		"kid":          kid,
+		"algorithm":    s.cfg.JWTSigningAlgorithm,
+		"activates_at": activatesAt,
+	})
+	return nil
+}
+
+func (s *SigningKeyStore) seal(privateKey crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось закодировать ключ подписи: %w", err)
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать nonce: %w", err)
+	}
+	return s.aead.Seal(nonce, nonce, der, nil), nil
+}
+
+func (s *SigningKeyStore) open(sealed []byte) (crypto.Signer, error) {
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, errors.New("зашифрованный ключ слишком короткий")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	der, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("неподдерживаемый тип ключа")
+	}
+	return signer, nil
+}
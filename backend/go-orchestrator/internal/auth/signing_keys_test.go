@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/jwt"
+)
+
+type mockSigningKeyQueries struct {
+	keys []sqlc.JwtSigningKey
+}
+
+func (m *mockSigningKeyQueries) AdvisoryXactLock(ctx context.Context, key int64) error {
+	return nil
+}
+
+func (m *mockSigningKeyQueries) CreateSigningKey(ctx context.Context, params sqlc.CreateSigningKeyParams) error {
+	m.keys = append(m.keys, sqlc.JwtSigningKey{
+		Kid:         params.Kid,
+		Algorithm:   params.Algorithm,
+		PrivateKey:  params.PrivateKey,
+		ActivatesAt: params.ActivatesAt,
+		RetiresAt:   params.RetiresAt,
+		ExpiresAt:   params.ExpiresAt,
+	})
+	return nil
+}
+
+func (m *mockSigningKeyQueries) ListSigningKeys(ctx context.Context, expiresAt time.Time) ([]sqlc.JwtSigningKey, error) {
+	var items []sqlc.JwtSigningKey
+	for _, key := range m.keys {
+		if key.ExpiresAt.After(expiresAt) {
+			items = append(items, key)
+		}
+	}
+	return items, nil
+}
+
+func (m *mockSigningKeyQueries) DeleteExpiredSigningKeys(ctx context.Context, expiresAt time.Time) error {
+	m.keys, _ = m.ListSigningKeys(ctx, expiresAt)
+	return nil
+}
+
+func newTestSigningKeyStore(queries *mockSigningKeyQueries, algorithm string) (*SigningKeyStore, *jwt.KeySet) {
+	keys := jwt.NewKeySet()
+	withTx := func(ctx context.Context, fn func(q SigningKeyQueriesInterface) error) error {
+		return fn(queries)
+	}
+	return newSigningKeyStore(queries, withTx, keys, config.AuthConfig{
+		AccessTokenSecret:      "access-secret",
+		JWTAccessTokenTTL:      time.Minute,
+		JWTSigningAlgorithm:    algorithm,
+		JWTKeyRotationInterval: 30 * 24 * time.Hour,
+		JWTKeyOverlap:          24 * time.Hour,
+	}, nopLogger{}), keys
+}
+
+func TestSigningKeysSignAndPublish(t *testing.T) {
+	for _, algorithm := range []string{jwt.AlgorithmRS256, jwt.AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			queries := &mockSigningKeyQueries{}
+			store, keys := newTestSigningKeyStore(queries, algorithm)
+			if err := store.Sync(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(queries.keys) != 1 {
+				t.Fatalf("expected one key, got %d", len(queries.keys))
+			}
+
+			service := jwt.NewJWTService("access-secret", "refresh-secret", time.Minute, time.Hour)
+			service.UseKeySet(keys)
+			tokens, err := service.GenerateTokenPair(uuid.New(), "user@example.com", "User", RoleUser)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := service.ValidateAccessToken(tokens.AccessToken); err != nil {
+				t.Errorf("token must validate: %v", err)
+			}
+			if _, err := service.ValidateRefreshToken(tokens.RefreshToken); err != nil {
+				t.Errorf("refresh token must validate: %v", err)
+			}
+
+			jwks := service.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != queries.keys[0].Kid || jwks.Keys[0].Alg != algorithm {
+				t.Errorf("unexpected jwks %+v", jwks)
+			}
+			if jwks.Keys[0].N == "" && jwks.Keys[0].X == "" {
+				t.Error("jwks must carry the public key")
+			}
+		})
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	const interval = 30 * 24 * time.Hour
+	queries := &mockSigningKeyQueries{}
+	store, keys := newTestSigningKeyStore(queries, jwt.AlgorithmEdDSA)
+	service := jwt.NewJWTService("access-secret", "refresh-secret", time.Minute, time.Hour)
+	service.UseKeySet(keys)
+
+	// The first key was created long enough ago to have just retired, so it
+	// only signs as the fallback until its successor is known.
+	start := time.Now().Add(-interval - time.Minute)
+	if err := store.sync(context.Background(), start); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	oldTokens, err := service.GenerateTokenPair(uuid.New(), "user@example.com", "User", RoleUser)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Within the overlap of retiring, the successor is created to take over
+	// exactly when the first key retires, and is published at once.
+	if err := store.sync(context.Background(), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queries.keys) != 2 || !queries.keys[1].ActivatesAt.Equal(queries.keys[0].RetiresAt) {
+		t.Fatalf("expected a successor activating when the first key retires, got %+v", queries.keys)
+	}
+	if err := store.sync(context.Background(), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queries.keys) != 2 {
+		t.Fatalf("expected no further key, got %d", len(queries.keys))
+	}
+	if jwks := service.JWKS(); len(jwks.Keys) != 2 {
+		t.Errorf("expected both keys published, got %d", len(jwks.Keys))
+	}
+
+	newTokens, err := service.GenerateTokenPair(uuid.New(), "user@example.com", "User", RoleUser)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if kidOf(t, newTokens.AccessToken) != queries.keys[1].Kid || kidOf(t, oldTokens.AccessToken) != queries.keys[0].Kid {
+		t.Error("tokens must be signed with the key active when they were issued")
+	}
+	if _, err := service.ValidateAccessToken(oldTokens.AccessToken); err != nil {
+		t.Errorf("token of the retired key must validate during the overlap: %v", err)
+	}
+
+	// Once the overlap is over, the retired key is dropped.
+	if err := store.sync(context.Background(), queries.keys[0].ExpiresAt.Add(time.Second)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queries.keys) != 1 {
+		t.Errorf("expected the expired key deleted, got %d keys", len(queries.keys))
+	}
+}
+
+func TestAccessTokenKeys(t *testing.T) {
+	queries := &mockSigningKeyQueries{}
+	store, keys := newTestSigningKeyStore(queries, jwt.AlgorithmRS256)
+	if err := store.Sync(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	legacy := jwt.NewJWTService("access-secret", "refresh-secret", time.Minute, time.Hour)
+	legacyTokens, err := legacy.GenerateTokenPair(uuid.New(), "user@example.com", "User", RoleUser)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	service := jwt.NewJWTService("access-secret", "refresh-secret", time.Minute, time.Hour)
+	service.UseKeySet(keys)
+	if _, err := service.ValidateAccessToken(legacyTokens.AccessToken); err != nil {
+		t.Errorf("tokens signed with the secret must validate right after the switch: %v", err)
+	}
+
+	otherStore, otherKeys := newTestSigningKeyStore(&mockSigningKeyQueries{}, jwt.AlgorithmRS256)
+	if err := otherStore.Sync(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other := jwt.NewJWTService("access-secret", "refresh-secret", time.Minute, time.Hour)
+	other.UseKeySet(otherKeys)
+	foreignTokens, err := other.GenerateTokenPair(uuid.New(), "user@example.com", "User", RoleUser)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := service.ValidateAccessToken(foreignTokens.AccessToken); err == nil {
+		t.Error("token signed with an unknown key must be rejected")
+	}
+
+	// A changed access token secret makes the stored keys unreadable; they
+	// are replaced rather than failing every login.
+	cfg := store.cfg
+	cfg.AccessTokenSecret = "rotated-secret"
+	rekeyed := newSigningKeyStore(queries, store.withTx, jwt.NewKeySet(), cfg, nopLogger{})
+	if err := rekeyed.Sync(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queries.keys) != 2 {
+		t.Errorf("expected a replacement key, got %d keys", len(queries.keys))
+	}
+}
+
+func kidOf(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := gojwt.NewParser().ParseUnverified(token, &jwt.CustomClaims{})
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
@@ -0,0 +1,539 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/totp"
+)
+
+const (
+	recoveryCodeCount = 10
+	// maxTwoFactorAttempts is how many wrong codes a login challenge takes
+	// before it is dropped and the password has to be entered again.
+	maxTwoFactorAttempts = 5
+)
+
+var (
+	ErrInvalidTwoFactorCode      = errors.New("неверный код подтверждения")
+	ErrInvalidTwoFactorChallenge = errors.New("время на подтверждение входа истекло, войдите снова")
+	ErrTwoFactorEnabled          = errors.New("двухфакторная аутентификация уже включена")
+	ErrTwoFactorNotEnabled       = errors.New("двухфакторная аутентификация не включена")
+	ErrTwoFactorNotSetUp         = errors.New("сначала получите секрет для приложения-аутентификатора")
+	ErrTwoFactorRequired         = errors.New("для вашей роли двухфакторная аутентификация обязательна")
+)
+
+// Two-factor authentication uses TOTP codes from an authenticator app, with
+// single-use recovery codes for a lost device. With it enabled, or required
+// for the role by an admin, a correct password only yields a challenge token;
+// the tokens are issued once the challenge is completed with a code. A user
+// who must enroll but has not completes the enrollment with the challenge.
+//
+// Single sign-on logins get the same challenge. Only a provider configured
+// with OIDC_<NAME>_MFA_AMR can skip it, by naming one of those methods in the
+// amr claim of the ID token.
+
+// twoFactorChallenge starts the second step of a password or single sign-on
+// login, or returns nil if the user needs none.
+func (s *AuthService) twoFactorChallenge(ctx context.Context, user sqlc.User) (*TwoFactorChallengeResponse, error) {
+	enabled, err := s.twoFactorEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		required, err := s.queries.IsTwoFactorRequired(ctx, user.Role)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить политику двухфакторной аутентификации: %w", err)
+		}
+		if !required {
+			return nil, nil
+		}
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос подтверждения входа: %w", err)
+	}
+	expiresAt := time.Now().Add(s.cfg.TwoFactorChallengeTTL)
+	if err := s.queries.CreateTwoFactorChallenge(ctx, sqlc.CreateTwoFactorChallengeParams{
+		TokenHash:  hashUserToken(token),
+		UserID:     user.ID,
+		Enrollment: !enabled,
+		ExpiresAt:  expiresAt,
+	}); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить запрос подтверждения входа: %w", err)
+	}
+
+	return &TwoFactorChallengeResponse{
+		ChallengeToken:     token,
+		ExpiresAt:          expiresAt,
+		EnrollmentRequired: !enabled,
+	}, nil
+}
+
+// CompleteTwoFactorLogin finishes a login with a code from the authenticator
+// app or a recovery code. For an enrollment challenge the code confirms the
+// new secret and the response carries the recovery codes.
+func (s *AuthService) CompleteTwoFactorLogin(ctx context.Context, req *TwoFactorLoginRequest) (*LoginResponse, error) {
+	challenge, user, err := s.loadTwoFactorChallenge(ctx, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if challenge.Enrollment {
+		recoveryCodes, err = s.ConfirmTwoFactor(ctx, user.ID, req.Code)
+	} else {
+		err = s.verifyTwoFactorCode(ctx, user.ID, req.Code)
+	}
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		s.recordTwoFactorFailure(ctx, challenge.TokenHash)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The challenge is single-use; of two concurrent completions one fails.
+	deleted, err := s.queries.DeleteTwoFactorChallenge(ctx, challenge.TokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось удалить запрос подтверждения входа: %w", err)
+	}
+	if deleted == 0 {
+		return nil, ErrInvalidTwoFactorChallenge
+	}
+
+	tokens, err := s.jwtService.GenerateTokenPair(user.ID, user.Email, user.Name, user.Role)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать токены")
+	}
+	if err := s.storeRefreshToken(ctx, s.queries, user.ID, tokens); err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		User:          newUserResponse(user),
+		Tokens:        tokens,
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// SetupTwoFactorForLogin starts the enrollment a login challenge requires.
+func (s *AuthService) SetupTwoFactorForLogin(ctx context.Context, challengeToken string) (*TwoFactorSetupResponse, error) {
+	challenge, user, err := s.loadTwoFactorChallenge(ctx, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.Enrollment {
+		return nil, ErrTwoFactorEnabled
+	}
+	return s.setupTwoFactor(ctx, user)
+}
+
+// SetupTwoFactor generates a new secret for the user. Two-factor
+// authentication is enabled only once ConfirmTwoFactor gets a code for it,
+// so an enrollment abandoned halfway does not lock the user out.
+func (s *AuthService) SetupTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactorSetupResponse, error) {
+	user, err := s.queries.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	return s.setupTwoFactor(ctx, user)
+}
+
+func (s *AuthService) setupTwoFactor(ctx context.Context, user sqlc.User) (*TwoFactorSetupResponse, error) {
+	enabled, err := s.twoFactorEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать секрет: %w", err)
+	}
+	sealed, err := s.sealTOTPSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.queries.UpsertUserTOTP(ctx, sqlc.UpsertUserTOTPParams{
+		UserID: user.ID,
+		Secret: sealed,
+	}); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить секрет: %w", err)
+	}
+
+	return &TwoFactorSetupResponse{
+		Secret: secret,
+		URI:    totp.URI(s.cfg.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves
+// the app is set up, and returns the recovery codes. They are shown only
+// this once.
+func (s *AuthService) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	row, err := s.queries.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTwoFactorNotSetUp
+		}
+		return nil, fmt.Errorf("не удалось получить секрет двухфакторной аутентификации: %w", err)
+	}
+	if row.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	step, err := s.validateTOTP(row, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.withTx(ctx, func(q QueriesInterface) error {
+		now := time.Now()
+		confirmed, err := q.ConfirmUserTOTP(ctx, sqlc.ConfirmUserTOTPParams{
+			UserID:      userID,
+			ConfirmedAt: &now,
+		})
+		if err != nil {
+			return fmt.Errorf("не удалось включить двухфакторную аутентификацию: %w", err)
+		}
+		if confirmed == 0 {
+			return ErrTwoFactorEnabled
+		}
+		if _, err := q.UseTOTPStep(ctx, sqlc.UseTOTPStepParams{UserID: userID, LastUsedStep: step}); err != nil {
+			return fmt.Errorf("не удалось сохранить использованный код: %w", err)
+		}
+		return storeRecoveryCodes(ctx, q, userID, codes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Two-factor authentication enabled", map[string]interface{}{
+		"user_id": userID.String(),
+	})
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking a
+// current code. It stays on while the role of the user requires it.
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	user, err := s.queries.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	required, err := s.queries.IsTwoFactorRequired(ctx, user.Role)
+	if err != nil {
+		return fmt.Errorf("не удалось получить политику двухфакторной аутентификации: %w", err)
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+
+	if err := s.verifyTwoFactorCode(ctx, userID, code); err != nil {
+		return err
+	}
+	if err := s.removeTwoFactor(ctx, userID); err != nil {
+		return err
+	}
+
+	s.logger.Info("Two-factor authentication disabled", map[string]interface{}{
+		"user_id": userID.String(),
+	})
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user after
+// checking a current code.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if err := s.verifyTwoFactorCode(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.withTx(ctx, func(q QueriesInterface) error {
+		if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+			return fmt.Errorf("не удалось удалить резервные коды: %w", err)
+		}
+		return storeRecoveryCodes(ctx, q, userID, codes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetUserTwoFactor removes the second factor of a user who lost both the
+// device and the recovery codes. If the role requires two-factor
+// authentication, the next login enrolls again.
+func (s *AuthService) ResetUserTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.queries.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	return s.removeTwoFactor(ctx, userID)
+}
+
+// TwoFactorRequiredRoles returns the roles that must use two-factor
+// authentication.
+func (s *AuthService) TwoFactorRequiredRoles(ctx context.Context) ([]string, error) {
+	roles, err := s.queries.ListTwoFactorRequiredRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить политику двухфакторной аутентификации: %w", err)
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	return roles, nil
+}
+
+// SetTwoFactorRequiredRoles replaces the roles that must use two-factor
+// authentication. It applies from the next password login; sessions that
+// already exist are kept.
+func (s *AuthService) SetTwoFactorRequiredRoles(ctx context.Context, roles []string) ([]string, error) {
+	err := s.withTx(ctx, func(q QueriesInterface) error {
+		if err := q.DeleteTwoFactorRequiredRoles(ctx); err != nil {
+			return fmt.Errorf("не удалось сбросить политику двухфакторной аутентификации: %w", err)
+		}
+		for _, role := range roles {
+			if err := q.AddTwoFactorRequiredRole(ctx, role); err != nil {
+				return fmt.Errorf("не удалось сохранить политику двухфакторной аутентификации: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.TwoFactorRequiredRoles(ctx)
+}
+
+func (s *AuthService) twoFactorEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	row, err := s.queries.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("не удалось получить секрет двухфакторной аутентификации: %w", err)
+	}
+	return row.ConfirmedAt != nil, nil
+}
+
+func (s *AuthService) loadTwoFactorChallenge(ctx context.Context, token string) (sqlc.TwoFactorChallenge, sqlc.User, error) {
+	challenge, err := s.queries.GetTwoFactorChallenge(ctx, hashUserToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.TwoFactorChallenge{}, sqlc.User{}, ErrInvalidTwoFactorChallenge
+		}
+		return sqlc.TwoFactorChallenge{}, sqlc.User{}, fmt.Errorf("не удалось получить запрос подтверждения входа: %w", err)
+	}
+	if !challenge.ExpiresAt.After(time.Now()) || challenge.Attempts >= maxTwoFactorAttempts {
+		return sqlc.TwoFactorChallenge{}, sqlc.User{}, ErrInvalidTwoFactorChallenge
+	}
+
+	user, err := s.queries.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.TwoFactorChallenge{}, sqlc.User{}, ErrInvalidTwoFactorChallenge
+		}
+		return sqlc.TwoFactorChallenge{}, sqlc.User{}, fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	if user.SuspendedAt != nil {
+		return sqlc.TwoFactorChallenge{}, sqlc.User{}, ErrUserSuspended
+	}
+	return challenge, user, nil
+}
+
+func (s *AuthService) recordTwoFactorFailure(ctx context.Context, tokenHash string) {
+	attempts, err := s.queries.RecordTwoFactorChallengeFailure(ctx, tokenHash)
+	if err != nil {
+		s.logger.Error("Failed to record two-factor failure", err)
+		return
+	}
+	if attempts < maxTwoFactorAttempts {
+		return
+	}
+	if _, err := s.queries.DeleteTwoFactorChallenge(ctx, tokenHash); err != nil {
+		s.logger.Error("Failed to delete two-factor challenge", err)
+	}
+}
+
+// verifyTwoFactorCode accepts a current code from the app, once, or an
+// unused recovery code, which is then spent.
+func (s *AuthService) verifyTwoFactorCode(ctx context.Context, userID uuid.UUID, code string) error {
+	row, err := s.queries.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTwoFactorNotEnabled
+		}
+		return fmt.Errorf("не удалось получить секрет двухфакторной аутентификации: %w", err)
+	}
+	if row.ConfirmedAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	step, err := s.validateTOTP(row, code)
+	if err == nil {
+		used, err := s.queries.UseTOTPStep(ctx, sqlc.UseTOTPStepParams{UserID: userID, LastUsedStep: step})
+		if err != nil {
+			return fmt.Errorf("не удалось сохранить использованный код: %w", err)
+		}
+		if used == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+	if !errors.Is(err, ErrInvalidTwoFactorCode) {
+		return err
+	}
+
+	used, err := s.queries.UseRecoveryCode(ctx, sqlc.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: hashRecoveryCode(code),
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось использовать резервный код: %w", err)
+	}
+	if used == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	s.logger.Warn("Recovery code used", map[string]interface{}{
+		"user_id": userID.String(),
+	})
+	return nil
+}
+
+// validateTOTP checks a code against the secret and returns its time step.
+// Steps up to the last used one are rejected, so a code works only once.
+func (s *AuthService) validateTOTP(row sqlc.UserTotp, code string) (int64, error) {
+	secret, err := s.openTOTPSecret(row.Secret)
+	if err != nil {
+		return 0, err
+	}
+	step, ok := totp.Validate(secret, strings.ReplaceAll(code, " ", ""), time.Now())
+	if !ok || step <= row.LastUsedStep {
+		return 0, ErrInvalidTwoFactorCode
+	}
+	return step, nil
+}
+
+func (s *AuthService) removeTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	return s.withTx(ctx, func(q QueriesInterface) error {
+		if err := q.DeleteUserTOTP(ctx, userID); err != nil {
+			return fmt.Errorf("не удалось удалить секрет двухфакторной аутентификации: %w", err)
+		}
+		if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+			return fmt.Errorf("не удалось удалить резервные коды: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *AuthService) deleteExpiredTwoFactorChallenges(ctx context.Context) {
+	if err := s.queries.DeleteExpiredTwoFactorChallenges(ctx, time.Now()); err != nil {
+		s.logger.Error("Failed to delete expired two-factor challenges", err)
+	}
+}
+
+// totpCipher encrypts TOTP secrets at rest with a key derived from the
+// access token secret, as the signing keys are. Changing that secret makes
+// the stored secrets unreadable, and the affected users need a reset.
+func (s *AuthService) totpCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("totp-secrets:" + s.cfg.AccessTokenSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *AuthService) sealTOTPSecret(secret string) ([]byte, error) {
+	aead, err := s.totpCipher()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось инициализировать шифр: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, []byte(secret), nil), nil
+}
+
+func (s *AuthService) openTOTPSecret(sealed []byte) (string, error) {
+	aead, err := s.totpCipher()
+	if err != nil {
+		return "", fmt.Errorf("не удалось инициализировать шифр: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("зашифрованный секрет двухфакторной аутентификации слишком короткий")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	secret, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("не удалось расшифровать секрет двухфакторной аутентификации: %w", err)
+	}
+	return string(secret), nil
+}
+
+// newRecoveryCodes returns codes like "k3m9q-x7wpa", 50 random bits each.
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("не удалось сгенерировать резервные коды: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+func storeRecoveryCodes(ctx context.Context, q QueriesInterface, userID uuid.UUID, codes []string) error {
+	for _, code := range codes {
+		if err := q.CreateRecoveryCode(ctx, sqlc.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		}); err != nil {
+			return fmt.Errorf("не удалось сохранить резервный код: %w", err)
+		}
+	}
+	return nil
+}
+
+// hashRecoveryCode ignores case, dashes and spaces, which users get wrong
+// when typing codes over.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashUserToken(code)
+}
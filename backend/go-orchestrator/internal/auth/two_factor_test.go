@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/totp"
+)
+
+// withTwoFactorStore backs the two-factor queries with maps, applying the
+// same conditions as the SQL.
+func (m *mockQueries) withTwoFactorStore() map[string]bool {
+	secrets := make(map[uuid.UUID]*sqlc.UserTotp)
+	recoveryCodes := make(map[uuid.UUID]map[string]bool)
+	challenges := make(map[string]*sqlc.TwoFactorChallenge)
+	requiredRoles := make(map[string]bool)
+
+	m.getUserTOTPFunc = func(ctx context.Context, userID uuid.UUID) (sqlc.UserTotp, error) {
+		row, ok := secrets[userID]
+		if !ok {
+			return sqlc.UserTotp{}, pgx.ErrNoRows
+		}
+		return *row, nil
+	}
+	m.upsertUserTOTPFunc = func(ctx context.Context, params sqlc.UpsertUserTOTPParams) error {
+		if row, ok := secrets[params.UserID]; ok && row.ConfirmedAt != nil {
+			return nil
+		}
+		secrets[params.UserID] = &sqlc.UserTotp{UserID: params.UserID, Secret: params.Secret}
+		return nil
+	}
+	m.confirmUserTOTPFunc = func(ctx context.Context, params sqlc.ConfirmUserTOTPParams) (int64, error) {
+		row, ok := secrets[params.UserID]
+		if !ok || row.ConfirmedAt != nil {
+			return 0, nil
+		}
+		row.ConfirmedAt = params.ConfirmedAt
+		return 1, nil
+	}
+	m.useTOTPStepFunc = func(ctx context.Context, params sqlc.UseTOTPStepParams) (int64, error) {
+		row, ok := secrets[params.UserID]
+		if !ok || row.LastUsedStep >= params.LastUsedStep {
+			return 0, nil
+		}
+		row.LastUsedStep = params.LastUsedStep
+		return 1, nil
+	}
+	m.deleteUserTOTPFunc = func(ctx context.Context, userID uuid.UUID) error {
+		delete(secrets, userID)
+		return nil
+	}
+	m.createRecoveryCodeFunc = func(ctx context.Context, params sqlc.CreateRecoveryCodeParams) error {
+		if recoveryCodes[params.UserID] == nil {
+			recoveryCodes[params.UserID] = make(map[string]bool)
+		}
+		recoveryCodes[params.UserID][params.CodeHash] = true
+		return nil
+	}
+	m.useRecoveryCodeFunc = func(ctx context.Context, params sqlc.UseRecoveryCodeParams) (int64, error) {
+		if !recoveryCodes[params.UserID][params.CodeHash] {
+			return 0, nil
+		}
+		delete(recoveryCodes[params.UserID], params.CodeHash)
+		return 1, nil
+	}
+	m.deleteRecoveryCodesFunc = func(ctx context.Context, userID uuid.UUID) error {
+		delete(recoveryCodes, userID)
+		return nil
+	}
+	m.createTwoFactorChallengeFunc = func(ctx context.Context, params sqlc.CreateTwoFactorChallengeParams) error {
+		challenges[params.TokenHash] = &sqlc.TwoFactorChallenge{
+			TokenHash:  params.TokenHash,
+			UserID:     params.UserID,
+			Enrollment: params.Enrollment,
+			ExpiresAt:  params.ExpiresAt,
+		}
+		return nil
+	}
+	m.getTwoFactorChallengeFunc = func(ctx context.Context, tokenHash string) (sqlc.TwoFactorChallenge, error) {
+		challenge, ok := challenges[tokenHash]
+		if !ok {
+			return sqlc.TwoFactorChallenge{}, pgx.ErrNoRows
+		}
+		return *challenge, nil
+	}
+	m.recordTwoFactorFailureFunc = func(ctx context.Context, tokenHash string) (int32, error) {
+		challenge, ok := challenges[tokenHash]
+		if !ok {
+			return 0, pgx.ErrNoRows
+		}
+		challenge.Attempts++
+		return challenge.Attempts, nil
+	}
+	m.deleteTwoFactorChallengeFunc = func(ctx context.Context, tokenHash string) (int64, error) {
+		if _, ok := challenges[tokenHash]; !ok {
+			return 0, nil
+		}
+		delete(challenges, tokenHash)
+		return 1, nil
+	}
+	m.isTwoFactorRequiredFunc = func(ctx context.Context, role string) (bool, error) {
+		return requiredRoles[role], nil
+	}
+	m.listTwoFactorRolesFunc = func(ctx context.Context) ([]string, error) {
+		var roles []string
+		for role := range requiredRoles {
+			roles = append(roles, role)
+		}
+		return roles, nil
+	}
+	m.addTwoFactorRoleFunc = func(ctx context.Context, role string) error {
+		requiredRoles[role] = true
+		return nil
+	}
+	m.deleteTwoFactorRolesFunc = func(ctx context.Context) error {
+		clear(requiredRoles)
+		return nil
+	}
+	return requiredRoles
+}
+
+func newTestTwoFactorService(t *testing.T, user sqlc.User) (*AuthService, *mockQueries) {
+	t.Helper()
+
+	queries := &mockQueries{
+		getByEmailFunc: func(ctx context.Context, email string) (sqlc.User, error) {
+			return user, nil
+		},
+		getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+			return user, nil
+		},
+	}
+	queries.withTwoFactorStore()
+	queries.withRefreshTokenStore()
+
+	service := newTestRefreshService(queries)
+	service.logger = nopLogger{}
+	service.cfg = config.AuthConfig{
+		AccessTokenSecret:     "access-secret",
+		TwoFactorChallengeTTL: 5 * time.Minute,
+		TOTPIssuer:            "Dev2GIS",
+	}
+	return service, queries
+}
+
+func codeAt(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return code
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 test vector for SHA-1, truncated to six digits.
+	code, err := totp.Code("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", totp.Step(time.Unix(59, 0)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code != "287082" {
+		t.Errorf("expected 287082, got %s", code)
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	user := sqlc.User{ID: uuid.New(), Email: "user@example.com", Name: "User", Role: RoleUser, PasswordHash: hashPassword(t, "password123")}
+	service, queries := newTestTwoFactorService(t, user)
+	ctx := context.Background()
+	login := &LoginUserRequest{Email: user.Email, Password: "password123"}
+
+	setup, err := service.SetupTwoFactor(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored, _ := queries.GetUserTOTP(ctx, user.ID); string(stored.Secret) == setup.Secret {
+		t.Error("the secret must be stored encrypted")
+	}
+
+	// Until the code is confirmed, login needs no second factor.
+	if response, err := service.LoginUser(ctx, login, "192.0.2.1"); err != nil || response.Tokens == nil {
+		t.Fatalf("expected tokens before confirmation, got %+v, %v", response, err)
+	}
+	if _, err := service.ConfirmTwoFactor(ctx, user.ID, "000000"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("expected %v, got %v", ErrInvalidTwoFactorCode, err)
+	}
+	confirmCode := codeAt(t, setup.Secret, 0)
+	recoveryCodes, err := service.ConfirmTwoFactor(ctx, user.ID, confirmCode)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(recoveryCodes))
+	}
+
+	response, err := service.LoginUser(ctx, login, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Tokens != nil || response.TwoFactor == nil || response.TwoFactor.EnrollmentRequired {
+		t.Fatalf("expected a challenge without tokens, got %+v", response)
+	}
+	challenge := response.TwoFactor.ChallengeToken
+
+	// The code used to confirm cannot be replayed.
+	if _, err := service.CompleteTwoFactorLogin(ctx, &TwoFactorLoginRequest{ChallengeToken: challenge, Code: confirmCode}); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("expected %v for a replayed code, got %v", ErrInvalidTwoFactorCode, err)
+	}
+	response, err = service.CompleteTwoFactorLogin(ctx, &TwoFactorLoginRequest{ChallengeToken: challenge, Code: codeAt(t, setup.Secret, 1)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Tokens == nil || response.User == nil {
+		t.Fatalf("expected tokens, got %+v", response)
+	}
+	if _, err := service.CompleteTwoFactorLogin(ctx, &TwoFactorLoginRequest{ChallengeToken: challenge, Code: recoveryCodes[0]}); !errors.Is(err, ErrInvalidTwoFactorChallenge) {
+		t.Errorf("expected %v for a used challenge, got %v", ErrInvalidTwoFactorChallenge, err)
+	}
+
+	// A recovery code works once, however it is typed.
+	for i, want := range []error{nil, ErrInvalidTwoFactorCode} {
+		response, err := service.LoginUser(ctx, login, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, err = service.CompleteTwoFactorLogin(ctx, &TwoFactorLoginRequest{
+			ChallengeToken: response.TwoFactor.ChallengeToken,
+			Code:           " " + recoveryCodes[1] + " ",
+		})
+		if !errors.Is(err, want) {
+			t.Errorf("attempt %d: expected %v, got %v", i, want, err)
+		}
+	}
+
+	// Wrong codes use up the challenge.
+	response, err = service.LoginUser(ctx, login, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < maxTwoFactorAttempts; i++ {
+		if _, err := service.CompleteTwoFactorLogin(ctx, &TwoFactorLoginRequest{ChallengeToken: response.TwoFactor.ChallengeToken, Code: "wrong"}); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("expected %v, got %v", ErrInvalidTwoFactorCode, err)
+		}
+	}
+	if _, err := service.CompleteTwoFactorLogin(ctx, &TwoFactorLoginRequest{ChallengeToken: response.TwoFactor.ChallengeToken, Code: recoveryCodes[2]}); !errors.Is(err, ErrInvalidTwoFactorChallenge) {
+		t.Errorf("expected %v after too many attempts, got %v", ErrInvalidTwoFactorChallenge, err)
+	}
+
+	if err := service.DisableTwoFactor(ctx, user.ID, recoveryCodes[3]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response, err := service.LoginUser(ctx, login, "192.0.2.1"); err != nil || response.Tokens == nil {
+		t.Errorf("expected tokens once disabled, got %+v, %v", response, err)
+	}
+}
+
+func TestTwoFactorRequiredForRole(t *testing.T) {
+	user := sqlc.User{ID: uuid.New(), Email: "admin@example.com", Name: "Admin", Role: RoleAdmin, PasswordHash: hashPassword(t, "password123")}
+	service, _ := newTestTwoFactorService(t, user)
+	ctx := context.Background()
+
+	roles, err := service.SetTwoFactorRequiredRoles(ctx, []string{RoleAdmin})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(roles) != 1 || roles[0] != RoleAdmin {
+		t.Errorf("expected the admin role required, got %v", roles)
+	}
+
+	response, err := service.LoginUser(ctx, &LoginUserRequest{Email: user.Email, Password: "password123"}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Tokens != nil || response.TwoFactor == nil || !response.TwoFactor.EnrollmentRequired {
+		t.Fatalf("expected an enrollment challenge, got %+v", response)
+	}
+	challenge := response.TwoFactor.ChallengeToken
+
+	if _, err := service.CompleteTwoFactorLogin(ctx, &TwoFactorLoginRequest{ChallengeToken: challenge, Code: "123456"}); !errors.Is(err, ErrTwoFactorNotSetUp) {
+		t.Errorf("expected %v before setup, got %v", ErrTwoFactorNotSetUp, err)
+	}
+	if _, err := service.SetupTwoFactorForLogin(ctx, "unknown"); !errors.Is(err, ErrInvalidTwoFactorChallenge) {
+		t.Errorf("expected %v, got %v", ErrInvalidTwoFactorChallenge, err)
+	}
+	setup, err := service.SetupTwoFactorForLogin(ctx, challenge)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	response, err = service.CompleteTwoFactorLogin(ctx, &TwoFactorLoginRequest{ChallengeToken: challenge, Code: codeAt(t, setup.Secret, 0)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Tokens == nil || len(response.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected tokens and recovery codes, got %+v", response)
+	}
+
+	if err := service.DisableTwoFactor(ctx, user.ID, codeAt(t, setup.Secret, 1)); !errors.Is(err, ErrTwoFactorRequired) {
+		t.Errorf("expected %v, got %v", ErrTwoFactorRequired, err)
+	}
+
+	// An admin reset brings the enrollment back on the next login.
+	if err := service.ResetUserTwoFactor(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response, err = service.LoginUser(ctx, &LoginUserRequest{Email: user.Email, Password: "password123"}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.TwoFactor == nil || !response.TwoFactor.EnrollmentRequired {
+		t.Errorf("expected an enrollment challenge after reset, got %+v", response)
+	}
+}
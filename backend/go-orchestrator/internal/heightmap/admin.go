@@ -0,0 +1,263 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+var ErrUnknownJobStatus = errors.New("неизвестный статус задачи")
+
+// jobStatuses lists the states a single or batch job can be in.
+var jobStatuses = map[string]bool{
+	"scheduled":  true,
+	"pending":    true,
+	"processing": true,
+	"completed":  true,
+	"failed":     true,
+	"cancelled":  true,
+}
+
+// JobFilter narrows the admin job listings. Empty fields match every job.
+type JobFilter struct {
+	Status string
+	UserID *uuid.UUID
+}
+
+// AdminHeightmapJob is a job as seen by an administrator: besides the public
+// fields it shows how often the job was requeued and its recorded events,
+// which carry the errors reported by workers.
+type AdminHeightmapJob struct {
+	*HeightmapJob
+	RequeueCount int32              `json:"requeue_count"`
+	DispatchedAt *time.Time         `json:"dispatched_at,omitempty"`
+	Events       []*JobEventMessage `json:"events"`
+}
+
+type AdminBatchHeightmapJob struct {
+	*BatchHeightmapJob
+	GenerationMode string             `json:"generation_mode"`
+	FastMode       bool               `json:"fast_mode"`
+	RequeueCount   int32              `json:"requeue_count"`
+	DispatchedAt   *time.Time         `json:"dispatched_at,omitempty"`
+	Images         []*BatchImage      `json:"images"`
+	Events         []*JobEventMessage `json:"events"`
+}
+
+type BatchImage struct {
+	ID             uuid.UUID  `json:"id"`
+	ImageURL       string     `json:"image_url"`
+	HeightmapJobID *uuid.UUID `json:"heightmap_job_id,omitempty"`
+	Status         string     `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func validateJobFilter(filter JobFilter) error {
+	if filter.Status != "" && !jobStatuses[filter.Status] {
+		return ErrUnknownJobStatus
+	}
+	return nil
+}
+
+// ListAllHeightmaps lists single jobs of every user, newest first.
+func (s *Service) ListAllHeightmaps(ctx context.Context, filter JobFilter, limit, offset int32) ([]*HeightmapJob, error) {
+	if err := validateJobFilter(filter); err != nil {
+		return nil, err
+	}
+
+	var jobs []sqlc.HeightmapJob
+	var err error
+	switch {
+	case filter.UserID != nil && filter.Status != "":
+		jobs, err = s.queries.ListUserHeightmapsByStatus(ctx, sqlc.ListUserHeightmapsByStatusParams{
+			UserID: *filter.UserID,
+			Status: filter.Status,
+			Limit:  limit,
+			Offset: offset,
+		})
+	case filter.UserID != nil:
+		jobs, err = s.queries.ListUserHeightmaps(ctx, sqlc.ListUserHeightmapsParams{
+			UserID: *filter.UserID,
+			Limit:  limit,
+			Offset: offset,
+		})
+	case filter.Status != "":
+		jobs, err = s.queries.ListHeightmapsByStatus(ctx, sqlc.ListHeightmapsByStatusParams{
+			Status: filter.Status,
+			Limit:  limit,
+			Offset: offset,
+		})
+	default:
+		jobs, err = s.queries.ListHeightmaps(ctx, sqlc.ListHeightmapsParams{
+			Limit:  limit,
+			Offset: offset,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список карт высот: %w", err)
+	}
+
+	result := make([]*HeightmapJob, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, newHeightmapJob(job))
+	}
+
+	return result, nil
+}
+
+// ListAllBatchHeightmaps lists batch jobs of every user, newest first.
+func (s *Service) ListAllBatchHeightmaps(ctx context.Context, filter JobFilter, limit, offset int32) ([]*BatchHeightmapJob, error) {
+	if err := validateJobFilter(filter); err != nil {
+		return nil, err
+	}
+
+	var jobs []sqlc.BatchHeightmapJob
+	var err error
+	switch {
+	case filter.UserID != nil && filter.Status != "":
+		jobs, err = s.queries.ListUserBatchHeightmapsByStatus(ctx, sqlc.ListUserBatchHeightmapsByStatusParams{
+			UserID: *filter.UserID,
+			Status: filter.Status,
+			Limit:  limit,
+			Offset: offset,
+		})
+	case filter.UserID != nil:
+		jobs, err = s.queries.ListUserBatchHeightmaps(ctx, sqlc.ListUserBatchHeightmapsParams{
+			UserID: *filter.UserID,
+			Limit:  limit,
+			Offset: offset,
+		})
+	case filter.Status != "":
+		jobs, err = s.queries.ListBatchHeightmapsByStatus(ctx, sqlc.ListBatchHeightmapsByStatusParams{
+			Status: filter.Status,
+			Limit:  limit,
+			Offset: offset,
+		})
+	default:
+		jobs, err = s.queries.ListBatchHeightmaps(ctx, sqlc.ListBatchHeightmapsParams{
+			Limit:  limit,
+			Offset: offset,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список пакетных карт высот: %w", err)
+	}
+
+	result := make([]*BatchHeightmapJob, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, newBatchHeightmapJob(job))
+	}
+
+	return result, nil
+}
+
+// GetHeightmapJobDetails returns a single job of any user.
+func (s *Service) GetHeightmapJobDetails(ctx context.Context, jobID uuid.UUID) (*AdminHeightmapJob, error) {
+	job, err := s.queries.GetHeightmapJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+
+	events, err := s.listJobEvents(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AdminHeightmapJob{
+		HeightmapJob: newHeightmapJob(job),
+		RequeueCount: job.RequeueCount,
+		DispatchedAt: job.DispatchedAt,
+		Events:       events,
+	}
+	if job.Status == "pending" && job.DispatchedAt == nil {
+		result.QueuePosition, err = s.queuePosition(ctx, JobTypeHeightmap, job.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// GetBatchHeightmapJobDetails returns a batch job of any user together with
+// the state of each of its images.
+func (s *Service) GetBatchHeightmapJobDetails(ctx context.Context, batchJobID uuid.UUID) (*AdminBatchHeightmapJob, error) {
+	job, err := s.queries.GetBatchHeightmapJob(ctx, batchJobID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("не удалось получить пакетную задачу: %w", err)
+	}
+
+	images, err := s.queries.GetBatchImages(ctx, pgtype.UUID{Bytes: job.ID, Valid: true})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить изображения пакета: %w", err)
+	}
+
+	events, err := s.listJobEvents(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AdminBatchHeightmapJob{
+		BatchHeightmapJob: newBatchHeightmapJob(job),
+		GenerationMode:    job.GenerationMode,
+		FastMode:          job.FastMode,
+		RequeueCount:      job.RequeueCount,
+		DispatchedAt:      job.DispatchedAt,
+		Images:            make([]*BatchImage, 0, len(images)),
+		Events:            events,
+	}
+	for _, image := range images {
+		result.Images = append(result.Images, newBatchImage(image))
+	}
+	if job.Status == "pending" && job.DispatchedAt == nil {
+		result.QueuePosition, err = s.queuePosition(ctx, JobTypeBatch, job.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// listJobEvents returns the recorded events of a job. Events older than the
+// retention period are already deleted, so old jobs may have none.
+func (s *Service) listJobEvents(ctx context.Context, jobID uuid.UUID) ([]*JobEventMessage, error) {
+	events, err := s.queries.ListJobEvents(ctx, pgtype.UUID{Bytes: jobID, Valid: true})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить события задачи: %w", err)
+	}
+
+	result := make([]*JobEventMessage, 0, len(events))
+	for _, event := range events {
+		result = append(result, newJobEventMessage(event))
+	}
+
+	return result, nil
+}
+
+func newBatchImage(image sqlc.BatchImage) *BatchImage {
+	result := &BatchImage{
+		ID:        image.ID,
+		ImageURL:  image.ImageUrl,
+		Status:    image.Status,
+		CreatedAt: image.CreatedAt,
+	}
+	if image.HeightmapJobID.Valid {
+		jobID := uuid.UUID(image.HeightmapJobID.Bytes)
+		result.HeightmapJobID = &jobID
+	}
+
+	return result
+}
@@ -0,0 +1,194 @@
+package heightmap
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+// AdminHandler serves the jobs of every user to administrators.
+type AdminHandler struct {
+	service *Service
+}
+
+func NewAdminHandler(service *Service) *AdminHandler {
+	return &AdminHandler{service: service}
+}
+
+func (h *AdminHandler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMiddleware) {
+	admin := r.Group("admin/heightmaps")
+	admin.Use(jwtMiddleware.RequireAuth(), jwtMiddleware.AdminOnly())
+	{
+		admin.GET("", h.ListHeightMaps)
+		admin.GET("/:id", h.GetHeightMap)
+		admin.GET("/batch", h.ListBatchHeightMaps)
+		admin.GET("/batch/:id", h.GetBatchHeightMap)
+	}
+}
+
+// @Summary List All Height Maps
+// @Description Admin only. List single jobs of every user, newest first
+// @Tags admin
+// @Produce json
+// @Param status query string false "Job status"
+// @Param user_id query string false "Owner ID"
+// @Param limit query int false "Limit" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/heightmaps [get]
+func (h *AdminHandler) ListHeightMaps(c *gin.Context) {
+	filter, ok := jobFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	limit, offset := paginationFromQuery(c)
+
+	maps, err := h.service.ListAllHeightmaps(c.Request.Context(), filter, int32(limit), int32(offset))
+	if err != nil {
+		respondAdminJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"heightmaps": maps,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// @Summary Get Any Height Map
+// @Description Admin only. Inspect a single job of any user with its requeue count and recorded events, including worker errors
+// @Tags admin
+// @Produce json
+// @Param id path string true "Height Map ID"
+// @Success 200 {object} AdminHeightmapJob
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/heightmaps/{id} [get]
+func (h *AdminHandler) GetHeightMap(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID"})
+		return
+	}
+
+	job, err := h.service.GetHeightmapJobDetails(c.Request.Context(), id)
+	if err != nil {
+		respondAdminJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary List All Batch Height Maps
+// @Description Admin only. List batch jobs of every user, newest first
+// @Tags admin
+// @Produce json
+// @Param status query string false "Job status"
+// @Param user_id query string false "Owner ID"
+// @Param limit query int false "Limit" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/heightmaps/batch [get]
+func (h *AdminHandler) ListBatchHeightMaps(c *gin.Context) {
+	filter, ok := jobFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	limit, offset := paginationFromQuery(c)
+
+	maps, err := h.service.ListAllBatchHeightmaps(c.Request.Context(), filter, int32(limit), int32(offset))
+	if err != nil {
+		respondAdminJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"batch_heightmaps": maps,
+		"limit":            limit,
+		"offset":           offset,
+	})
+}
+
+// @Summary Get Any Batch Height Map
+// @Description Admin only. Inspect a batch job of any user with the state of each image and its recorded events, including worker errors
+// @Tags admin
+// @Produce json
+// @Param id path string true "Batch Height Map ID"
+// @Success 200 {object} AdminBatchHeightmapJob
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/heightmaps/batch/{id} [get]
+func (h *AdminHandler) GetBatchHeightMap(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID"})
+		return
+	}
+
+	job, err := h.service.GetBatchHeightmapJobDetails(c.Request.Context(), id)
+	if err != nil {
+		respondAdminJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func jobFilterFromQuery(c *gin.Context) (JobFilter, bool) {
+	filter := JobFilter{Status: c.Query("status")}
+
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+			return JobFilter{}, false
+		}
+		filter.UserID = &userID
+	}
+
+	return filter, true
+}
+
+// paginationFromQuery reads limit and offset the same way the user job
+// listings do.
+func paginationFromQuery(c *gin.Context) (int, int) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+func respondAdminJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownJobStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
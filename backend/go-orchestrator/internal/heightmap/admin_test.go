@@ -0,0 +1,122 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+func TestListAllHeightmapsFilters(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name          string
+		filter        JobFilter
+		expectedQuery string
+		expectedErr   error
+	}{
+		{name: "no filter", filter: JobFilter{}, expectedQuery: "all"},
+		{name: "by status", filter: JobFilter{Status: "failed"}, expectedQuery: "status"},
+		{name: "by user", filter: JobFilter{UserID: &userID}, expectedQuery: "user"},
+		{name: "by user and status", filter: JobFilter{Status: "failed", UserID: &userID}, expectedQuery: "user_status"},
+		{name: "unknown status", filter: JobFilter{Status: "broken"}, expectedErr: ErrUnknownJobStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called string
+			job := sqlc.HeightmapJob{ID: uuid.New(), UserID: userID, Status: "failed"}
+			queries := &mockQueries{
+				listHeightmaps: func(ctx context.Context, params sqlc.ListHeightmapsParams) ([]sqlc.HeightmapJob, error) {
+					called = "all"
+					return []sqlc.HeightmapJob{job}, nil
+				},
+				listByStatus: func(ctx context.Context, params sqlc.ListHeightmapsByStatusParams) ([]sqlc.HeightmapJob, error) {
+					called = "status"
+					return []sqlc.HeightmapJob{job}, nil
+				},
+				listUserHeightmaps: func(ctx context.Context, params sqlc.ListUserHeightmapsParams) ([]sqlc.HeightmapJob, error) {
+					called = "user"
+					return []sqlc.HeightmapJob{job}, nil
+				},
+				listUserByStatus: func(ctx context.Context, params sqlc.ListUserHeightmapsByStatusParams) ([]sqlc.HeightmapJob, error) {
+					called = "user_status"
+					if params.UserID != userID || params.Status != "failed" {
+						t.Errorf("unexpected params %+v", params)
+					}
+					return []sqlc.HeightmapJob{job}, nil
+				},
+			}
+			service := &Service{queries: queries}
+
+			jobs, err := service.ListAllHeightmaps(context.Background(), tt.filter, 20, 0)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if called != tt.expectedQuery {
+				t.Errorf("expected %q query, got %q", tt.expectedQuery, called)
+			}
+			if len(jobs) != 1 || jobs[0].ID != job.ID {
+				t.Errorf("unexpected jobs %+v", jobs)
+			}
+		})
+	}
+}
+
+func TestGetBatchHeightmapJobDetails(t *testing.T) {
+	batchID := uuid.New()
+	imageJobID := uuid.New()
+	errorMessage := "worker crashed"
+
+	queries := &mockQueries{
+		getBatchJobFunc: func(ctx context.Context, id uuid.UUID) (sqlc.BatchHeightmapJob, error) {
+			if id != batchID {
+				return sqlc.BatchHeightmapJob{}, pgx.ErrNoRows
+			}
+			return sqlc.BatchHeightmapJob{ID: id, Status: "failed", ErrorMessage: &errorMessage, RequeueCount: 2}, nil
+		},
+		getBatchImagesFunc: func(ctx context.Context, batchJobID pgtype.UUID) ([]sqlc.BatchImage, error) {
+			return []sqlc.BatchImage{
+				{ID: uuid.New(), Status: "completed", HeightmapJobID: pgtype.UUID{Bytes: imageJobID, Valid: true}},
+				{ID: uuid.New(), Status: "failed"},
+			}, nil
+		},
+		listJobEventsFunc: func(ctx context.Context, jobID pgtype.UUID) ([]sqlc.JobEvent, error) {
+			return []sqlc.JobEvent{{Seq: 1, JobID: jobID, JobType: JobTypeBatch, EventType: "error", Payload: []byte(`{}`)}}, nil
+		},
+	}
+	service := &Service{queries: queries}
+
+	job, err := service.GetBatchHeightmapJobDetails(context.Background(), batchID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.ErrorMessage == nil || *job.ErrorMessage != errorMessage {
+		t.Errorf("expected the worker error, got %v", job.ErrorMessage)
+	}
+	if job.RequeueCount != 2 {
+		t.Errorf("expected requeue count 2, got %d", job.RequeueCount)
+	}
+	if len(job.Images) != 2 || job.Images[0].HeightmapJobID == nil || *job.Images[0].HeightmapJobID != imageJobID || job.Images[1].HeightmapJobID != nil {
+		t.Errorf("unexpected images %+v", job.Images)
+	}
+	if len(job.Events) != 1 || job.Events[0].JobID != batchID {
+		t.Errorf("unexpected events %+v", job.Events)
+	}
+
+	if _, err := service.GetBatchHeightmapJobDetails(context.Background(), uuid.New()); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected %v, got %v", ErrJobNotFound, err)
+	}
+}
@@ -0,0 +1,233 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+var (
+	ErrDeadLetterNotFound   = errors.New("сообщение не найдено в очереди недоставленных")
+	ErrDeadLetterWithoutJob = errors.New("сообщение не связано с задачей")
+	ErrDeadLetterJobMissing = errors.New("задача сообщения не найдена")
+	ErrJobNotRedrivable     = errors.New("задача завершена или отменена и не может быть перезапущена")
+)
+
+// DeadLetter is a dead-lettered task together with the current state of the
+// job it was published for.
+type DeadLetter struct {
+	*rabbitmq.DeadLetter
+	JobType string      `json:"job_type,omitempty"`
+	Job     interface{} `json:"job,omitempty"`
+}
+
+// DeadLetterService lets administrators inspect tasks that workers rejected.
+// Redriving a message does not republish its body: the job is requeued from
+// its row through the outbox, exactly as the reaper does, and the dead letter
+// is removed only after that transaction commits.
+type DeadLetterService struct {
+	service *Service
+	queue   DeadLetterQueueInterface
+	logger  middleware.LoggerInterface
+}
+
+func NewDeadLetterService(service *Service, queue DeadLetterQueueInterface, logger middleware.LoggerInterface) *DeadLetterService {
+	return &DeadLetterService{
+		service: service,
+		queue:   queue,
+		logger:  logger,
+	}
+}
+
+func (s *DeadLetterService) List(ctx context.Context, limit int) ([]*DeadLetter, error) {
+	letters, err := s.queue.ListDeadLetters(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать очередь недоставленных сообщений: %w", err)
+	}
+
+	result := make([]*DeadLetter, 0, len(letters))
+	for _, letter := range letters {
+		entry, err := s.withJob(ctx, letter)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entry)
+	}
+
+	return result, nil
+}
+
+func (s *DeadLetterService) Get(ctx context.Context, id string) (*DeadLetter, error) {
+	letter, err := s.queue.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, deadLetterError(err)
+	}
+
+	return s.withJob(ctx, letter)
+}
+
+// redrivableStatuses are the statuses from which a dead-lettered job may be
+// requeued: a failure, or a job the worker rejected before reporting anything.
+// A completed or cancelled job stays as it is, a redrive would rerun work that
+// is either done or no longer wanted.
+var redrivableStatuses = map[string]bool{
+	"pending":    true,
+	"processing": true,
+	"failed":     true,
+}
+
+// Redrive requeues the job of the dead letter and drops the message.
+func (s *DeadLetterService) Redrive(ctx context.Context, id string) (*DeadLetter, error) {
+	var jobType string
+	var jobID uuid.UUID
+
+	letter, err := s.queue.HandleDeadLetter(ctx, id, func(letter *rabbitmq.DeadLetter) error {
+		var err error
+		jobType, jobID, err = deadLetterJob(letter)
+		if err != nil {
+			return err
+		}
+
+		return s.service.withTx(ctx, func(q QueriesInterface) error {
+			return s.requeue(ctx, q, jobType, jobID, time.Now())
+		})
+	})
+	if err != nil {
+		return nil, deadLetterError(err)
+	}
+
+	if err := s.service.afterJobEvent(ctx, s.service.queries, jobType, jobID, rabbitmq.JobEventStatus); err != nil {
+		s.logger.Error("Failed to publish redriven job event", err, map[string]interface{}{
+			"job_id":   jobID.String(),
+			"job_type": jobType,
+		})
+	}
+
+	s.logger.Info("Dead letter redriven", map[string]interface{}{
+		"message_id": id,
+		"job_id":     jobID.String(),
+		"job_type":   jobType,
+	})
+
+	return s.withJob(ctx, letter)
+}
+
+// Delete drops a single dead letter. The job row is left as the worker
+// reported it.
+func (s *DeadLetterService) Delete(ctx context.Context, id string) error {
+	_, err := s.queue.HandleDeadLetter(ctx, id, func(letter *rabbitmq.DeadLetter) error {
+		return nil
+	})
+	if err != nil {
+		return deadLetterError(err)
+	}
+
+	return nil
+}
+
+func (s *DeadLetterService) Purge(ctx context.Context) (int, error) {
+	count, err := s.queue.PurgeDeadLetters(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось очистить очередь недоставленных сообщений: %w", err)
+	}
+
+	s.logger.Warn("Dead-letter queue purged", map[string]interface{}{
+		"messages": count,
+	})
+
+	return count, nil
+}
+
+func (s *DeadLetterService) requeue(ctx context.Context, q QueriesInterface, jobType string, jobID uuid.UUID, now time.Time) error {
+	switch jobType {
+	case JobTypeHeightmap:
+		job, err := q.GetHeightmapJob(ctx, jobID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrDeadLetterJobMissing
+			}
+			return fmt.Errorf("не удалось получить задачу %s: %w", jobID, err)
+		}
+		if !redrivableStatuses[job.Status] {
+			return ErrJobNotRedrivable
+		}
+		return s.service.requeueHeightmapJob(ctx, q, job, now)
+
+	default:
+		job, err := q.GetBatchHeightmapJob(ctx, jobID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrDeadLetterJobMissing
+			}
+			return fmt.Errorf("не удалось получить пакетную задачу %s: %w", jobID, err)
+		}
+		if !redrivableStatuses[job.Status] {
+			return ErrJobNotRedrivable
+		}
+		return s.service.requeueBatchJob(ctx, q, job, now)
+	}
+}
+
+// withJob attaches the job row referenced by the task. A job deleted since
+// the message was dead-lettered is reported without a job.
+func (s *DeadLetterService) withJob(ctx context.Context, letter *rabbitmq.DeadLetter) (*DeadLetter, error) {
+	entry := &DeadLetter{DeadLetter: letter}
+
+	jobType, jobID, err := deadLetterJob(letter)
+	if err != nil {
+		return entry, nil
+	}
+	entry.JobType = jobType
+
+	switch jobType {
+	case JobTypeHeightmap:
+		job, err := s.service.queries.GetHeightmapJob(ctx, jobID)
+		if err == nil {
+			entry.Job = newHeightmapJob(job)
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("не удалось получить задачу: %w", err)
+		}
+	case JobTypeBatch:
+		job, err := s.service.queries.GetBatchHeightmapJob(ctx, jobID)
+		if err == nil {
+			entry.Job = newBatchHeightmapJob(job)
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("не удалось получить пакетную задачу: %w", err)
+		}
+	}
+
+	return entry, nil
+}
+
+func deadLetterJob(letter *rabbitmq.DeadLetter) (string, uuid.UUID, error) {
+	jobType, rawID := JobTypeHeightmap, letter.JobID
+	if letter.BatchJobID != "" {
+		jobType, rawID = JobTypeBatch, letter.BatchJobID
+	}
+
+	jobID, err := uuid.Parse(rawID)
+	if err != nil {
+		return "", uuid.Nil, ErrDeadLetterWithoutJob
+	}
+
+	return jobType, jobID, nil
+}
+
+func deadLetterError(err error) error {
+	switch {
+	case errors.Is(err, rabbitmq.ErrDeadLetterNotFound):
+		return ErrDeadLetterNotFound
+	case errors.Is(err, ErrDeadLetterWithoutJob),
+		errors.Is(err, ErrDeadLetterJobMissing),
+		errors.Is(err, ErrJobNotRedrivable):
+		return err
+	default:
+		return fmt.Errorf("не удалось обработать недоставленное сообщение: %w", err)
+	}
+}
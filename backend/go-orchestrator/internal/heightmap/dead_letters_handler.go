@@ -0,0 +1,144 @@
+package heightmap
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+type DeadLetterHandler struct {
+	service *DeadLetterService
+}
+
+func NewDeadLetterHandler(service *DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{service: service}
+}
+
+func (h *DeadLetterHandler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMiddleware) {
+	admin := r.Group("admin/dead-letters")
+	admin.Use(jwtMiddleware.RequireAuth(), jwtMiddleware.AdminOnly())
+	{
+		admin.GET("", h.ListDeadLetters)
+		admin.DELETE("", h.PurgeDeadLetters)
+		admin.GET("/:id", h.GetDeadLetter)
+		admin.POST("/:id/redrive", h.RedriveDeadLetter)
+		admin.DELETE("/:id", h.DeleteDeadLetter)
+	}
+}
+
+// @Summary List Dead Letters
+// @Description List task messages rejected by workers together with their jobs
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Limit" default(50)
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/dead-letters [get]
+func (h *DeadLetterHandler) ListDeadLetters(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	letters, err := h.service.List(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dead_letters": letters,
+		"limit":        limit,
+	})
+}
+
+// @Summary Get Dead Letter
+// @Description Inspect a dead-lettered task message and its job
+// @Tags admin
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {object} DeadLetter
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/dead-letters/{id} [get]
+func (h *DeadLetterHandler) GetDeadLetter(c *gin.Context) {
+	letter, err := h.service.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, letter)
+}
+
+// @Summary Redrive Dead Letter
+// @Description Requeue the job of a dead-lettered task and remove the message
+// @Tags admin
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 202 {object} DeadLetter
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/dead-letters/{id}/redrive [post]
+func (h *DeadLetterHandler) RedriveDeadLetter(c *gin.Context) {
+	letter, err := h.service.Redrive(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, letter)
+}
+
+// @Summary Delete Dead Letter
+// @Description Remove a single dead-lettered task message
+// @Tags admin
+// @Param id path string true "Message ID"
+// @Success 204
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/dead-letters/{id} [delete]
+func (h *DeadLetterHandler) DeleteDeadLetter(c *gin.Context) {
+	if err := h.service.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Purge Dead Letters
+// @Description Remove every message from the dead-letter queue
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/dead-letters [delete]
+func (h *DeadLetterHandler) PurgeDeadLetters(c *gin.Context) {
+	count, err := h.service.Purge(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": count})
+}
+
+func respondDeadLetterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrDeadLetterNotFound), errors.Is(err, ErrDeadLetterJobMissing):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrDeadLetterWithoutJob), errors.Is(err, ErrJobNotRedrivable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,155 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+type mockDeadLetterQueue struct {
+	letters []*rabbitmq.DeadLetter
+	acked   []string
+}
+
+func (m *mockDeadLetterQueue) ListDeadLetters(ctx context.Context, limit int) ([]*rabbitmq.DeadLetter, error) {
+	return m.letters, nil
+}
+
+func (m *mockDeadLetterQueue) GetDeadLetter(ctx context.Context, id string) (*rabbitmq.DeadLetter, error) {
+	for _, letter := range m.letters {
+		if letter.ID == id {
+			return letter, nil
+		}
+	}
+	return nil, rabbitmq.ErrDeadLetterNotFound
+}
+
+func (m *mockDeadLetterQueue) HandleDeadLetter(ctx context.Context, id string, fn func(letter *rabbitmq.DeadLetter) error) (*rabbitmq.DeadLetter, error) {
+	letter, err := m.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(letter); err != nil {
+		return letter, err
+	}
+	m.acked = append(m.acked, id)
+	return letter, nil
+}
+
+func (m *mockDeadLetterQueue) PurgeDeadLetters(ctx context.Context) (int, error) {
+	count := len(m.letters)
+	m.letters = nil
+	return count, nil
+}
+
+func newTestDeadLetterService(queries *mockQueries, queue *mockDeadLetterQueue) *DeadLetterService {
+	s := &Service{
+		queries: queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return fn(queries)
+		},
+		cfg: &config.Config{Minio: config.MinioConfig{UAVModelsBucketName: "uav-models"}},
+	}
+	return NewDeadLetterService(s, queue, nopLogger{})
+}
+
+func TestDeadLetterListLinksJobs(t *testing.T) {
+	job := sqlc.HeightmapJob{ID: uuid.New(), UserID: uuid.New(), Status: "failed"}
+	queue := &mockDeadLetterQueue{letters: []*rabbitmq.DeadLetter{
+		{ID: "linked", JobID: job.ID.String()},
+		{ID: "deleted-job", JobID: uuid.NewString()},
+		{ID: "garbage"},
+	}}
+	queries := &mockQueries{
+		getJobFunc: func(ctx context.Context, id uuid.UUID) (sqlc.HeightmapJob, error) {
+			if id == job.ID {
+				return job, nil
+			}
+			return sqlc.HeightmapJob{}, pgx.ErrNoRows
+		},
+	}
+
+	letters, err := newTestDeadLetterService(queries, queue).List(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(letters) != 3 {
+		t.Fatalf("expected 3 dead letters, got %d", len(letters))
+	}
+
+	linked, ok := letters[0].Job.(*HeightmapJob)
+	if !ok || linked.ID != job.ID || letters[0].JobType != JobTypeHeightmap {
+		t.Errorf("expected dead letter to be linked to job %s, got %+v", job.ID, letters[0])
+	}
+	if letters[1].Job != nil || letters[1].JobType != JobTypeHeightmap {
+		t.Errorf("expected deleted job to be reported without a row, got %+v", letters[1])
+	}
+	if letters[2].Job != nil || letters[2].JobType != "" {
+		t.Errorf("expected message without job id to stay unlinked, got %+v", letters[2])
+	}
+}
+
+func TestDeadLetterRedrive(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		letterID    string
+		expectedErr error
+	}{
+		{name: "failed job is requeued", status: "failed", letterID: "msg-1"},
+		{name: "completed job is not redriven", status: "completed", letterID: "msg-1", expectedErr: ErrJobNotRedrivable},
+		{name: "cancelled job is not redriven", status: "cancelled", letterID: "msg-1", expectedErr: ErrJobNotRedrivable},
+		{name: "unknown message", status: "failed", letterID: "missing", expectedErr: ErrDeadLetterNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := sqlc.HeightmapJob{ID: uuid.New(), UserID: uuid.New(), Status: tt.status}
+			queue := &mockDeadLetterQueue{letters: []*rabbitmq.DeadLetter{{ID: "msg-1", JobID: job.ID.String()}}}
+
+			var requeued bool
+			var message *sqlc.CreateOutboxMessageParams
+			queries := &mockQueries{
+				getJobFunc: func(ctx context.Context, id uuid.UUID) (sqlc.HeightmapJob, error) {
+					return job, nil
+				},
+				requeueJobFunc: func(ctx context.Context, params sqlc.RequeueHeightmapJobParams) error {
+					requeued = params.ID == job.ID
+					return nil
+				},
+				createOutboxMessageFunc: func(ctx context.Context, params sqlc.CreateOutboxMessageParams) (sqlc.OutboxMessage, error) {
+					message = &params
+					return sqlc.OutboxMessage{}, nil
+				},
+			}
+
+			_, err := newTestDeadLetterService(queries, queue).Redrive(context.Background(), tt.letterID)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if requeued || message != nil || len(queue.acked) != 0 {
+					t.Error("rejected redrive must not touch the job or the queue")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !requeued || message == nil || message.AggregateID.Bytes != job.ID {
+				t.Errorf("expected job to be requeued through the outbox, got %+v", message)
+			}
+			if len(queue.acked) != 1 || queue.acked[0] != "msg-1" {
+				t.Errorf("expected dead letter to be removed, got %v", queue.acked)
+			}
+		})
+	}
+}
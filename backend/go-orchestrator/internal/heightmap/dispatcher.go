@@ -0,0 +1,164 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+// dispatcherLockKey elects the single replica that dispatches jobs.
+const dispatcherLockKey int64 = 0x64326700_00000002
+
+// Dispatcher moves waiting jobs to the outbox while the number of jobs in
+// flight is below the configured limit. Waiting jobs are taken by priority
+// first; within a priority, each user's n-th waiting job ranks after every
+// other user's (n-1)-th, counting the jobs the user already has in flight, so
+// one user submitting many jobs cannot starve the rest.
+type Dispatcher struct {
+	service *Service
+	cfg     config.DispatchConfig
+	logger  middleware.LoggerInterface
+}
+
+func NewDispatcher(service *Service, cfg config.DispatchConfig, logger middleware.LoggerInterface) *Dispatcher {
+	return &Dispatcher{
+		service: service,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := d.service.withTx(ctx, func(q QueriesInterface) error {
+				return d.dispatch(ctx, q)
+			})
+			if err != nil && ctx.Err() == nil {
+				d.logger.Error("Failed to dispatch jobs", err)
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, q QueriesInterface) error {
+	acquired, err := q.TryAdvisoryXactLock(ctx, dispatcherLockKey)
+	if err != nil {
+		return fmt.Errorf("не удалось захватить блокировку диспетчера: %w", err)
+	}
+	if !acquired {
+		return nil
+	}
+
+	now := time.Now()
+
+	inFlight, err := q.CountInFlightHeightmapJobs(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось посчитать выполняющиеся задачи: %w", err)
+	}
+	if free := d.capacity(d.cfg.MaxInFlight, inFlight); free > 0 {
+		jobs, err := q.ListWaitingHeightmapJobs(ctx, free)
+		if err != nil {
+			return fmt.Errorf("не удалось получить ожидающие задачи: %w", err)
+		}
+		for _, job := range jobs {
+			message, err := d.service.heightmapTaskMessage(job)
+			if err != nil {
+				return err
+			}
+			// The list takes no locks, so the job may have been cancelled
+			// since; the update then matches nothing and it is not sent.
+			marked, err := q.MarkHeightmapJobDispatched(ctx, sqlc.MarkHeightmapJobDispatchedParams{ID: job.ID, UpdatedAt: now})
+			if err != nil {
+				return fmt.Errorf("не удалось отметить отправку задачи %s: %w", job.ID, err)
+			}
+			if marked == 0 {
+				continue
+			}
+			if _, err := q.CreateOutboxMessage(ctx, message); err != nil {
+				return fmt.Errorf("не удалось поставить задачу %s в очередь: %w", job.ID, err)
+			}
+		}
+	}
+
+	inFlight, err = q.CountInFlightBatchHeightmapJobs(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось посчитать выполняющиеся пакетные задачи: %w", err)
+	}
+	if free := d.capacity(d.cfg.MaxInFlightBatch, inFlight); free > 0 {
+		jobs, err := q.ListWaitingBatchHeightmapJobs(ctx, free)
+		if err != nil {
+			return fmt.Errorf("не удалось получить ожидающие пакетные задачи: %w", err)
+		}
+		for _, job := range jobs {
+			imageURLs, err := batchImageURLs(ctx, q, job.ID)
+			if err != nil {
+				return err
+			}
+
+			message, err := d.service.batchTaskMessage(job, imageURLs)
+			if err != nil {
+				return err
+			}
+			marked, err := q.MarkBatchHeightmapJobDispatched(ctx, sqlc.MarkBatchHeightmapJobDispatchedParams{ID: job.ID, UpdatedAt: now})
+			if err != nil {
+				return fmt.Errorf("не удалось отметить отправку пакетной задачи %s: %w", job.ID, err)
+			}
+			if marked == 0 {
+				continue
+			}
+			if _, err := q.CreateOutboxMessage(ctx, message); err != nil {
+				return fmt.Errorf("не удалось поставить пакетную задачу %s в очередь: %w", job.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// capacity returns how many jobs may be dispatched in this round.
+func (d *Dispatcher) capacity(limit int, inFlight int64) int32 {
+	free := int64(d.cfg.BatchSize)
+	if limit > 0 && int64(limit)-inFlight < free {
+		free = int64(limit) - inFlight
+	}
+	if free < 0 {
+		return 0
+	}
+	return int32(free)
+}
+
+// queuePosition returns the 1-based position of a waiting job in dispatch
+// order, or nil if the job is no longer waiting.
+func (s *Service) queuePosition(ctx context.Context, jobType string, jobID uuid.UUID) (*int64, error) {
+	var position int64
+	var err error
+
+	switch jobType {
+	case JobTypeHeightmap:
+		position, err = s.queries.GetHeightmapJobQueuePosition(ctx, jobID)
+	default:
+		position, err = s.queries.GetBatchHeightmapJobQueuePosition(ctx, jobID)
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("не удалось определить позицию в очереди: %w", err)
+	}
+
+	return &position, nil
+}
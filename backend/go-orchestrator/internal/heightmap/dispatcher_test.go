@@ -0,0 +1,269 @@
+package heightmap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+func newTestDispatcher(queries *mockQueries) *Dispatcher {
+	s := &Service{
+		queries: queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return fn(queries)
+		},
+		cfg: &config.Config{Minio: config.MinioConfig{UAVModelsBucketName: "uav-models"}},
+	}
+
+	return NewDispatcher(s, config.DispatchConfig{
+		Interval:         time.Second,
+		MaxInFlight:      8,
+		MaxInFlightBatch: 2,
+		BatchSize:        50,
+	}, nopLogger{})
+}
+
+func TestUploadRequiresVerifiedEmail(t *testing.T) {
+	created := false
+	queries := &mockQueries{
+		isEmailVerifiedFunc: func(ctx context.Context, id uuid.UUID) (bool, error) {
+			return false, nil
+		},
+		createJobFunc: func(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error) {
+			created = true
+			return sqlc.HeightmapJob{ID: params.ID}, nil
+		},
+	}
+
+	s := &Service{
+		queries:     queries,
+		minioClient: &mockMinioClient{},
+		cfg:         &config.Config{Minio: config.MinioConfig{UAVDataBucketName: "uav-data"}},
+	}
+
+	file := memoryFile{bytes.NewReader([]byte("image"))}
+	_, err := s.UploadPhoto(context.Background(), uuid.New(), nil, nil, file, &multipart.FileHeader{Filename: "photo.jpg", Size: 5}, priorityValues[PriorityNormal], nil)
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("expected %v, got %v", ErrEmailNotVerified, err)
+	}
+
+	files := []*multipart.FileHeader{{Filename: "a.jpg"}, {Filename: "b.jpg"}}
+	_, err = s.BatchUploadPhotos(context.Background(), uuid.New(), nil, nil, files, "", false, "", priorityValues[PriorityNormal], nil)
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("expected %v, got %v", ErrEmailNotVerified, err)
+	}
+
+	if created {
+		t.Error("no job must be created for an unverified account")
+	}
+}
+
+func TestUploadPhotoWaitsForDispatch(t *testing.T) {
+	var created *sqlc.CreateHeightmapJobParams
+	outboxWritten := false
+	queries := &mockQueries{
+		createJobFunc: func(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error) {
+			created = &params
+			return sqlc.HeightmapJob{ID: params.ID}, nil
+		},
+		createOutboxMessageFunc: func(ctx context.Context, params sqlc.CreateOutboxMessageParams) (sqlc.OutboxMessage, error) {
+			outboxWritten = true
+			return sqlc.OutboxMessage{}, nil
+		},
+	}
+
+	s := &Service{
+		queries: queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return fn(queries)
+		},
+		minioClient: &mockMinioClient{},
+		cfg:         &config.Config{Minio: config.MinioConfig{UAVDataBucketName: "uav-data"}},
+	}
+
+	file := memoryFile{bytes.NewReader([]byte("image"))}
+	resp, err := s.UploadPhoto(context.Background(), uuid.New(), nil, nil, file, &multipart.FileHeader{Filename: "photo.jpg", Size: 5}, priorityValues[PriorityHigh], nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if created == nil || created.Priority != priorityValues[PriorityHigh] {
+		t.Fatalf("expected job to be created with high priority, got %+v", created)
+	}
+	if outboxWritten {
+		t.Error("upload must leave the job to the dispatcher instead of writing the outbox")
+	}
+	if resp.Priority != PriorityHigh {
+		t.Errorf("expected priority %q in response, got %q", PriorityHigh, resp.Priority)
+	}
+}
+
+func TestDispatcherCapacity(t *testing.T) {
+	tests := []struct {
+		name          string
+		locked        bool
+		inFlight      int64
+		expectedLimit int32
+	}{
+		{name: "fills free slots", locked: true, inFlight: 6, expectedLimit: 2},
+		{name: "full workers", locked: true, inFlight: 8},
+		{name: "over the limit after a config change", locked: true, inFlight: 12},
+		{name: "another replica holds the lock", locked: false, inFlight: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := sqlc.HeightmapJob{ID: uuid.New(), UserID: uuid.New(), Status: "pending", Priority: priorityValues[PriorityHigh]}
+
+			var limit int32
+			var dispatched []uuid.UUID
+			var messages []sqlc.CreateOutboxMessageParams
+			queries := &mockQueries{
+				tryAdvisoryXactLockFunc: func(ctx context.Context, key int64) (bool, error) {
+					return tt.locked, nil
+				},
+				countInFlightFunc: func(ctx context.Context) (int64, error) {
+					return tt.inFlight, nil
+				},
+				countInFlightBatchFunc: func(ctx context.Context) (int64, error) {
+					return 2, nil
+				},
+				listWaitingJobsFunc: func(ctx context.Context, l int32) ([]sqlc.HeightmapJob, error) {
+					limit = l
+					return []sqlc.HeightmapJob{job}, nil
+				},
+				listWaitingBatchJobsFunc: func(ctx context.Context, l int32) ([]sqlc.BatchHeightmapJob, error) {
+					t.Error("batch jobs must not be listed while batch workers are full")
+					return nil, nil
+				},
+				markDispatchedFunc: func(ctx context.Context, params sqlc.MarkHeightmapJobDispatchedParams) (int64, error) {
+					dispatched = append(dispatched, params.ID)
+					return 1, nil
+				},
+				createOutboxMessageFunc: func(ctx context.Context, params sqlc.CreateOutboxMessageParams) (sqlc.OutboxMessage, error) {
+					messages = append(messages, params)
+					return sqlc.OutboxMessage{}, nil
+				},
+			}
+
+			if err := newTestDispatcher(queries).dispatch(context.Background(), queries); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.expectedLimit == 0 {
+				if limit != 0 || len(dispatched) != 0 || len(messages) != 0 {
+					t.Fatalf("expected nothing to be dispatched, got limit=%d dispatched=%v", limit, dispatched)
+				}
+				return
+			}
+
+			if limit != tt.expectedLimit {
+				t.Errorf("expected %d free slots, got %d", tt.expectedLimit, limit)
+			}
+			if len(dispatched) != 1 || dispatched[0] != job.ID {
+				t.Fatalf("expected job to be marked dispatched, got %v", dispatched)
+			}
+			if len(messages) != 1 || messages[0].MessageType != OutboxMessageHeightmapTask {
+				t.Fatalf("expected one task in the outbox, got %+v", messages)
+			}
+
+			var task rabbitmq.HeightmapTask
+			if err := json.Unmarshal(messages[0].Payload, &task); err != nil {
+				t.Fatalf("invalid payload: %v", err)
+			}
+			if task.Priority != int(priorityValues[PriorityHigh]) {
+				t.Errorf("expected task priority %d, got %d", priorityValues[PriorityHigh], task.Priority)
+			}
+		})
+	}
+}
+
+func TestDispatchSkipsJobsCancelledMeanwhile(t *testing.T) {
+	cancelled := sqlc.HeightmapJob{ID: uuid.New(), UserID: uuid.New(), Status: "pending"}
+	waiting := sqlc.HeightmapJob{ID: uuid.New(), UserID: uuid.New(), Status: "pending"}
+
+	var messages []sqlc.CreateOutboxMessageParams
+	queries := &mockQueries{
+		tryAdvisoryXactLockFunc: func(ctx context.Context, key int64) (bool, error) {
+			return true, nil
+		},
+		listWaitingJobsFunc: func(ctx context.Context, l int32) ([]sqlc.HeightmapJob, error) {
+			return []sqlc.HeightmapJob{cancelled, waiting}, nil
+		},
+		// The job was cancelled after it was listed, so the guarded update
+		// no longer matches it.
+		markDispatchedFunc: func(ctx context.Context, params sqlc.MarkHeightmapJobDispatchedParams) (int64, error) {
+			if params.ID == cancelled.ID {
+				return 0, nil
+			}
+			return 1, nil
+		},
+		createOutboxMessageFunc: func(ctx context.Context, params sqlc.CreateOutboxMessageParams) (sqlc.OutboxMessage, error) {
+			messages = append(messages, params)
+			return sqlc.OutboxMessage{}, nil
+		},
+	}
+
+	if err := newTestDispatcher(queries).dispatch(context.Background(), queries); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("expected only the waiting job in the outbox, got %d messages", len(messages))
+	}
+	var task rabbitmq.HeightmapTask
+	if err := json.Unmarshal(messages[0].Payload, &task); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if task.JobID != waiting.ID.String() {
+		t.Errorf("expected task for job %s, got %s", waiting.ID, task.JobID)
+	}
+}
+
+func TestGetHeightmapJobQueuePosition(t *testing.T) {
+	dispatchedAt := time.Now()
+	tests := []struct {
+		name             string
+		job              sqlc.HeightmapJob
+		expectedPosition *int64
+	}{
+		{name: "waiting job", job: sqlc.HeightmapJob{Status: "pending"}, expectedPosition: func() *int64 { p := int64(3); return &p }()},
+		{name: "dispatched job", job: sqlc.HeightmapJob{Status: "pending", DispatchedAt: &dispatchedAt}},
+		{name: "completed job", job: sqlc.HeightmapJob{Status: "completed", DispatchedAt: &dispatchedAt}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := &mockQueries{
+				getAccessibleJobFunc: func(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error) {
+					return tt.job, nil
+				},
+				queuePositionFunc: func(ctx context.Context, id uuid.UUID) (int64, error) {
+					return 3, nil
+				},
+			}
+			s := &Service{queries: queries}
+
+			job, err := s.GetHeightmapJob(context.Background(), uuid.New(), uuid.New())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			switch {
+			case tt.expectedPosition == nil && job.QueuePosition != nil:
+				t.Errorf("expected no queue position, got %d", *job.QueuePosition)
+			case tt.expectedPosition != nil && (job.QueuePosition == nil || *job.QueuePosition != *tt.expectedPosition):
+				t.Errorf("expected queue position %d, got %v", *tt.expectedPosition, job.QueuePosition)
+			}
+		})
+	}
+}
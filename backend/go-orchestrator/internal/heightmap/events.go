@@ -0,0 +1,223 @@
+package heightmap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+const (
+	jobEventsChannel      = "job_events"
+	subscriberBufferSize  = 64
+	jobEventsCleanupEvery = time.Hour
+)
+
+type JobEventMessage struct {
+	Seq       int64           `json:"seq"`
+	UserID    uuid.UUID       `json:"user_id"`
+	JobID     uuid.UUID       `json:"job_id"`
+	JobType   string          `json:"job_type"`
+	EventType string          `json:"event_type"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// jobEventKey is the payload of a job_events notification. NOTIFY payloads
+// are limited to 8000 bytes, so the event itself is loaded by seq.
+type jobEventKey struct {
+	Seq   int64     `json:"seq"`
+	JobID uuid.UUID `json:"job_id"`
+}
+
+// EventBroker listens for job_events notifications from Postgres and fans
+// them out to the SSE subscribers of this replica. A subscriber that falls
+// behind is disconnected and expected to resume with Last-Event-ID.
+type EventBroker struct {
+	db     *storage.DB
+	cfg    config.EventsConfig
+	logger middleware.LoggerInterface
+
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan *JobEventMessage]struct{}
+}
+
+func NewEventBroker(db *storage.DB, cfg config.EventsConfig, logger middleware.LoggerInterface) *EventBroker {
+	return &EventBroker{
+		db:          db,
+		cfg:         cfg,
+		logger:      logger,
+		subscribers: make(map[uuid.UUID]map[chan *JobEventMessage]struct{}),
+	}
+}
+
+func (b *EventBroker) Subscribe(userID uuid.UUID) (<-chan *JobEventMessage, func()) {
+	ch := make(chan *JobEventMessage, subscriberBufferSize)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan *JobEventMessage]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[userID][ch]; ok {
+			delete(b.subscribers[userID], ch)
+			close(ch)
+		}
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// Run blocks until ctx is cancelled, reconnecting the LISTEN connection
+// whenever it drops.
+func (b *EventBroker) Run(ctx context.Context) {
+	go b.cleanupLoop(ctx)
+
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		b.logger.Error("Job events listener stopped, restarting", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (b *EventBroker) listen(ctx context.Context) error {
+	conn, err := b.db.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось получить соединение: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+jobEventsChannel); err != nil {
+		return fmt.Errorf("не удалось подписаться на %s: %w", jobEventsChannel, err)
+	}
+
+	b.logger.Info("Listening for job events", map[string]interface{}{
+		"channel": jobEventsChannel,
+	})
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("не удалось дождаться уведомления: %w", err)
+		}
+
+		var key jobEventKey
+		if err := json.Unmarshal([]byte(notification.Payload), &key); err != nil {
+			b.logger.Error("Failed to unmarshal job event notification", err)
+			continue
+		}
+
+		if !b.hasSubscribers() {
+			continue
+		}
+
+		event, err := b.db.Queries.GetJobEvent(ctx, key.Seq)
+		if err != nil {
+			// The event may already be gone through the retention cleanup;
+			// subscribers that care replay it with Last-Event-ID.
+			if !errors.Is(err, pgx.ErrNoRows) {
+				b.logger.Error("Failed to load job event", err, map[string]interface{}{
+					"seq": key.Seq,
+				})
+			}
+			continue
+		}
+
+		b.publish(newJobEventMessage(event))
+	}
+}
+
+func (b *EventBroker) hasSubscribers() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers) > 0
+}
+
+func (b *EventBroker) publish(msg *JobEventMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[msg.UserID] {
+		select {
+		case ch <- msg:
+		default:
+			delete(b.subscribers[msg.UserID], ch)
+			close(ch)
+			b.logger.Warn("Dropping slow job events subscriber", map[string]interface{}{
+				"user_id": msg.UserID.String(),
+			})
+		}
+	}
+}
+
+func (b *EventBroker) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(jobEventsCleanupEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.db.Queries.DeleteJobEventsBefore(ctx, time.Now().Add(-b.cfg.Retention)); err != nil {
+				b.logger.Error("Failed to delete old job events", err)
+			}
+		}
+	}
+}
+
+func newJobEventMessage(event sqlc.JobEvent) *JobEventMessage {
+	return &JobEventMessage{
+		Seq:       event.Seq,
+		UserID:    event.UserID,
+		JobID:     event.JobID.Bytes,
+		JobType:   event.JobType,
+		EventType: event.EventType,
+		Payload:   event.Payload,
+	}
+}
+
+// ListJobEventsAfter returns the events of the user recorded after seq, used to
+// replay what a reconnecting client missed.
+func (s *Service) ListJobEventsAfter(ctx context.Context, userID uuid.UUID, seq int64, limit int32) ([]*JobEventMessage, error) {
+	events, err := s.queries.ListUserJobEventsAfter(ctx, sqlc.ListUserJobEventsAfterParams{
+		UserID: userID,
+		Seq:    seq,
+		Limit:  limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить события задач: %w", err)
+	}
+
+	result := make([]*JobEventMessage, 0, len(events))
+	for _, event := range events {
+		result = append(result, newJobEventMessage(event))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package heightmap
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+const eventsReplayPageSize = 500
+
+// @Summary Stream Job Events
+// @Description Server-Sent Events stream of status, progress and result updates for the user's jobs. Send Last-Event-ID to resume after a reconnect.
+// @Description The token is only read from the Authorization header, so the stream is meant for non-browser clients; browsers cannot set headers on EventSource and use the WebSocket endpoint instead.
+// @Tags heightmaps
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "Last received event ID"
+// @Success 200 {string} string "event stream"
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/events [get]
+func (h *Handler) StreamEvents(c *gin.Context) {
+	userIDStr, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+
+	var lastSeq int64
+	if lastEventID != "" {
+		lastSeq, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || lastSeq < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный Last-Event-ID"})
+			return
+		}
+	}
+
+	// Subscribe before replaying so that nothing recorded in between is lost;
+	// duplicates are filtered by seq below.
+	events, unsubscribe := h.broker.Subscribe(userID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+
+	if lastEventID != "" {
+		for {
+			backlog, err := h.service.ListJobEventsAfter(ctx, userID, lastSeq, eventsReplayPageSize)
+			if err != nil {
+				return
+			}
+			for _, event := range backlog {
+				if err := writeJobEvent(c, event); err != nil {
+					return
+				}
+				lastSeq = event.Seq
+			}
+			if len(backlog) < eventsReplayPageSize {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(h.broker.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Seq <= lastSeq {
+				continue
+			}
+			if err := writeJobEvent(c, event); err != nil {
+				return
+			}
+			lastSeq = event.Seq
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeJobEvent(c *gin.Context, event *JobEventMessage) error {
+	if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.EventType, event.Payload); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
@@ -1,6 +1,7 @@
 package heightmap
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -11,10 +12,12 @@ import (
 
 type Handler struct {
 	service *Service
+	broker  *EventBroker
+	hub     *JobUpdatesHub
 }
 
-func NewHandler(service *Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(service *Service, broker *EventBroker, hub *JobUpdatesHub) *Handler {
+	return &Handler{service: service, broker: broker, hub: hub}
 }
 
 func (h *Handler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMiddleware) {
@@ -22,11 +25,19 @@ func (h *Handler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMid
 	protected.Use(jwtMiddleware.RequireAuth())
 	{
 		protected.POST("/upload", h.UploadPhoto)
+		protected.GET("/events", h.StreamEvents)
+		protected.GET("/ws", h.JobUpdatesWebSocket)
 		protected.GET("/:id", h.GetHeightMap)
+		protected.PATCH("/:id/schedule", h.RescheduleHeightMap)
+		protected.POST("/:id/cancel", h.CancelHeightMap)
+		protected.DELETE("/:id", h.DeleteHeightMap)
 		protected.GET("", h.ListHeightMaps)
 
 		protected.POST("/batch/upload", h.BatchUploadPhotos)
 		protected.GET("/batch/:id", h.GetBatchHeightMap)
+		protected.PATCH("/batch/:id/schedule", h.RescheduleBatchHeightMap)
+		protected.POST("/batch/:id/cancel", h.CancelBatchHeightMap)
+		protected.DELETE("/batch/:id", h.DeleteBatchHeightMap)
 		protected.GET("/batch", h.ListBatchHeightMaps)
 	}
 }
@@ -37,8 +48,16 @@ func (h *Handler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMid
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "Photo file"
+// @Param priority formData string false "Priority class: low, normal, high, urgent" default(normal)
+// @Param scheduled_at formData string false "Run the job at this time (RFC3339) instead of immediately"
+// @Param organization_id formData string false "Share the job with an organization the user is a member of"
+// @Param project_id formData string false "Add the job to a project; the job goes to the organization of the project"
 // @Success 202 {object} UploadResponse
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 413 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Security BearerAuth
 // @Router /api/heightmaps/upload [post]
@@ -62,7 +81,43 @@ func (h *Handler) UploadPhoto(c *gin.Context) {
 	}
 	defer file.Close()
 
-	result, err := h.service.UploadPhoto(c.Request.Context(), userID, file, header)
+	priority, ok := resolvePriority(c)
+	if !ok {
+		return
+	}
+
+	scheduledAt, err := h.service.ParseSchedule(c.PostForm("scheduled_at"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organizationID, ok := parseOptionalID(c, c.PostForm("organization_id"), "Некорректный ID организации")
+	if !ok {
+		return
+	}
+
+	projectID, ok := parseOptionalID(c, c.PostForm("project_id"), "Некорректный ID проекта")
+	if !ok {
+		return
+	}
+
+	result, err := h.service.UploadPhoto(c.Request.Context(), userID, organizationID, projectID, file, header, priority, scheduledAt)
+	if errors.Is(err, ErrEmailNotVerified) || errors.Is(err, ErrOrganizationAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrProjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrProjectOrganizationMismatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if respondQuotaError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -72,7 +127,7 @@ func (h *Handler) UploadPhoto(c *gin.Context) {
 }
 
 // @Summary Get Height Map by ID
-// @Description Retrieve height map metadata by ID
+// @Description Retrieve height map metadata by ID. Jobs shared with an organization are visible to all its members
 // @Tags heightmaps
 // @Produce json
 // @Param id path string true "Height Map ID"
@@ -111,12 +166,16 @@ func (h *Handler) GetHeightMap(c *gin.Context) {
 }
 
 // @Summary List Height Maps
-// @Description Get list of all available height maps for current user
+// @Description Get list of all available height maps for current user, of an organization the user is a member of, or of a project
 // @Tags heightmaps
 // @Produce json
 // @Param limit query int false "Limit" default(20)
 // @Param offset query int false "Offset" default(0)
+// @Param organization_id query string false "List the jobs of this organization instead"
+// @Param project_id query string false "List the jobs of this project instead; takes precedence over organization_id"
 // @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Security BearerAuth
 // @Router /api/heightmaps [get]
 func (h *Handler) ListHeightMaps(c *gin.Context) {
@@ -148,7 +207,33 @@ func (h *Handler) ListHeightMaps(c *gin.Context) {
 		offset = 0
 	}
 
-	maps, err := h.service.ListUserHeightmaps(c.Request.Context(), userID, int32(limit), int32(offset))
+	organizationID, ok := parseOptionalID(c, c.Query("organization_id"), "Некорректный ID организации")
+	if !ok {
+		return
+	}
+
+	projectID, ok := parseOptionalID(c, c.Query("project_id"), "Некорректный ID проекта")
+	if !ok {
+		return
+	}
+
+	var maps []*HeightmapJob
+	switch {
+	case projectID != nil:
+		maps, err = h.service.ListProjectHeightmaps(c.Request.Context(), *projectID, userID, int32(limit), int32(offset))
+	case organizationID != nil:
+		maps, err = h.service.ListOrganizationHeightmaps(c.Request.Context(), *organizationID, userID, int32(limit), int32(offset))
+	default:
+		maps, err = h.service.ListUserHeightmaps(c.Request.Context(), userID, int32(limit), int32(offset))
+	}
+	if errors.Is(err, ErrOrganizationAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrProjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -190,7 +275,43 @@ func (h *Handler) BatchUploadPhotos(c *gin.Context) {
 	fastMode := c.DefaultPostForm("fast_mode", "false") == "true"
 	generationMode := c.DefaultPostForm("generation_mode", "heightmap")
 
-	result, err := h.service.BatchUploadPhotos(c.Request.Context(), userID, files, mergeMethod, fastMode, generationMode)
+	priority, ok := resolvePriority(c)
+	if !ok {
+		return
+	}
+
+	scheduledAt, err := h.service.ParseSchedule(c.PostForm("scheduled_at"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organizationID, ok := parseOptionalID(c, c.PostForm("organization_id"), "Некорректный ID организации")
+	if !ok {
+		return
+	}
+
+	projectID, ok := parseOptionalID(c, c.PostForm("project_id"), "Некорректный ID проекта")
+	if !ok {
+		return
+	}
+
+	result, err := h.service.BatchUploadPhotos(c.Request.Context(), userID, organizationID, projectID, files, mergeMethod, fastMode, generationMode, priority, scheduledAt)
+	if errors.Is(err, ErrEmailNotVerified) || errors.Is(err, ErrOrganizationAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrProjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrProjectOrganizationMismatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if respondQuotaError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -257,7 +378,33 @@ func (h *Handler) ListBatchHeightMaps(c *gin.Context) {
 		offset = 0
 	}
 
-	jobs, err := h.service.ListUserBatchHeightmaps(c.Request.Context(), userID, int32(limit), int32(offset))
+	organizationID, ok := parseOptionalID(c, c.Query("organization_id"), "Некорректный ID организации")
+	if !ok {
+		return
+	}
+
+	projectID, ok := parseOptionalID(c, c.Query("project_id"), "Некорректный ID проекта")
+	if !ok {
+		return
+	}
+
+	var jobs []*BatchHeightmapJob
+	switch {
+	case projectID != nil:
+		jobs, err = h.service.ListProjectBatchHeightmaps(c.Request.Context(), *projectID, userID, int32(limit), int32(offset))
+	case organizationID != nil:
+		jobs, err = h.service.ListOrganizationBatchHeightmaps(c.Request.Context(), *organizationID, userID, int32(limit), int32(offset))
+	default:
+		jobs, err = h.service.ListUserBatchHeightmaps(c.Request.Context(), userID, int32(limit), int32(offset))
+	}
+	if errors.Is(err, ErrOrganizationAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrProjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -269,3 +416,250 @@ func (h *Handler) ListBatchHeightMaps(c *gin.Context) {
 		"offset":           offset,
 	})
 }
+
+// parseOptionalID parses an optional organization_id or project_id. It
+// writes the error response itself.
+func parseOptionalID(c *gin.Context, value, invalidMessage string) (*uuid.UUID, bool) {
+	if value == "" {
+		return nil, true
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidMessage})
+		return nil, false
+	}
+
+	return &id, true
+}
+
+// resolvePriority reads the priority form field and checks it against the
+// caller's role. It writes the error response itself.
+func resolvePriority(c *gin.Context) (int32, bool) {
+	role, _ := middleware.GetUserRole(c)
+
+	priority, err := ResolvePriority(c.PostForm("priority"), role)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrPriorityNotAllowed) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return 0, false
+	}
+
+	return priority, true
+}
+
+// @Summary Reschedule Height Map
+// @Description Move a scheduled job to a new time. Only jobs that have not been released yet can be rescheduled
+// @Tags heightmaps
+// @Accept json
+// @Produce json
+// @Param id path string true "Height Map ID"
+// @Param request body ScheduleRequest true "New time"
+// @Success 200 {object} HeightmapJob
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/{id}/schedule [patch]
+func (h *Handler) RescheduleHeightMap(c *gin.Context) {
+	userID, id, ok := jobRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	job, err := h.service.RescheduleHeightmapJob(c.Request.Context(), id, userID, req.ScheduledAt)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Cancel Height Map
+// @Description Cancel a scheduled job, or a pending job that has not been sent to the workers yet
+// @Tags heightmaps
+// @Produce json
+// @Param id path string true "Height Map ID"
+// @Success 200 {object} HeightmapJob
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/{id}/cancel [post]
+func (h *Handler) CancelHeightMap(c *gin.Context) {
+	userID, id, ok := jobRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.service.CancelHeightmapJob(c.Request.Context(), id, userID)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Delete Height Map
+// @Description Delete a completed, failed or cancelled job with its files. The storage it used is returned to the quota
+// @Tags heightmaps
+// @Param id path string true "Height Map ID"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/{id} [delete]
+func (h *Handler) DeleteHeightMap(c *gin.Context) {
+	userID, id, ok := jobRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteHeightmapJob(c.Request.Context(), id, userID); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Reschedule Batch Height Map
+// @Description Move a scheduled batch job to a new time
+// @Tags heightmaps
+// @Accept json
+// @Produce json
+// @Param id path string true "Batch Height Map ID"
+// @Param request body ScheduleRequest true "New time"
+// @Success 200 {object} BatchHeightmapJob
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/batch/{id}/schedule [patch]
+func (h *Handler) RescheduleBatchHeightMap(c *gin.Context) {
+	userID, id, ok := jobRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	job, err := h.service.RescheduleBatchHeightmapJob(c.Request.Context(), id, userID, req.ScheduledAt)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Cancel Batch Height Map
+// @Description Cancel a scheduled batch job, or a pending one that has not been sent to the workers yet
+// @Tags heightmaps
+// @Produce json
+// @Param id path string true "Batch Height Map ID"
+// @Success 200 {object} BatchHeightmapJob
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/batch/{id}/cancel [post]
+func (h *Handler) CancelBatchHeightMap(c *gin.Context) {
+	userID, id, ok := jobRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.service.CancelBatchHeightmapJob(c.Request.Context(), id, userID)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Delete Batch Height Map
+// @Description Delete a completed, failed or cancelled batch job with its files. The storage it used is returned to the quota
+// @Tags heightmaps
+// @Param id path string true "Batch Height Map ID"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/batch/{id} [delete]
+func (h *Handler) DeleteBatchHeightMap(c *gin.Context) {
+	userID, id, ok := jobRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteBatchHeightmapJob(c.Request.Context(), id, userID); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// jobRequestIDs parses the caller and the :id path parameter. It writes the
+// error response itself.
+func jobRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userIDStr, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, id, true
+}
+
+func respondScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrJobAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrJobNotScheduled), errors.Is(err, ErrJobNotCancellable), errors.Is(err, ErrJobNotDeletable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrScheduleInPast), errors.Is(err, ErrScheduleTooFar):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
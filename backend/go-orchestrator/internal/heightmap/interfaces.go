@@ -3,36 +3,158 @@ package heightmap
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/skr1ms/dev2gis/internal/storage/minio"
 	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/internal/webhook"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
 )
 
 type MinioClientInterface interface {
 	UploadFile(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) error
 	FileExists(ctx context.Context, bucket, objectName string) (bool, error)
 	GetPresignedURL(ctx context.Context, bucket, objectName string, expiry int) (string, error)
+	RemoveObject(ctx context.Context, bucket, objectName string) error
+	GetFileInfo(ctx context.Context, bucket, objectName string) (*minio.FileInfo, error)
+}
+
+// JobNotifierInterface is implemented by the webhook service; it is called on
+// job state transitions, with the queries of the transaction that applied them.
+type JobNotifierInterface interface {
+	Notify(ctx context.Context, q webhook.DeliveryQueriesInterface, userID uuid.UUID, event string, data interface{}) error
+}
+
+// TaskPublisherInterface is implemented by the RabbitMQ client; the outbox
+// relay is its only caller.
+type TaskPublisherInterface interface {
+	PublishTask(ctx context.Context, task *rabbitmq.HeightmapTask) error
+	PublishBatchTask(ctx context.Context, task *rabbitmq.BatchHeightmapTask) error
+}
+
+// ObjectPresignerInterface is implemented by the MinIO client; the outbox
+// relay uses it to grant workers access to the objects of a single task.
+type ObjectPresignerInterface interface {
+	PresignObject(ctx context.Context, method, bucket, objectName string, expiry time.Duration) (string, error)
+}
+
+// ObjectRemoverInterface is implemented by the MinIO client; the storage
+// cleaner uses it to delete the objects of deleted accounts.
+type ObjectRemoverInterface interface {
+	RemoveObject(ctx context.Context, bucket, objectName string) error
+	RemovePrefix(ctx context.Context, bucket, prefix string) error
+}
+
+// DeadLetterQueueInterface is implemented by the RabbitMQ client and backs the
+// admin dead-letter endpoints.
+type DeadLetterQueueInterface interface {
+	ListDeadLetters(ctx context.Context, limit int) ([]*rabbitmq.DeadLetter, error)
+	GetDeadLetter(ctx context.Context, id string) (*rabbitmq.DeadLetter, error)
+	HandleDeadLetter(ctx context.Context, id string, fn func(letter *rabbitmq.DeadLetter) error) (*rabbitmq.DeadLetter, error)
+	PurgeDeadLetters(ctx context.Context) (int, error)
 }
 
 type QueriesInterface interface {
+	IsEmailVerified(ctx context.Context, id uuid.UUID) (bool, error)
+	GetOrganizationMemberRole(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error)
+	GetAccessibleProject(ctx context.Context, params sqlc.GetAccessibleProjectParams) (sqlc.Project, error)
+
 	CreateHeightmapJob(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error)
 	GetHeightmapJob(ctx context.Context, id uuid.UUID) (sqlc.HeightmapJob, error)
-	GetHeightmapJobByUserID(ctx context.Context, params sqlc.GetHeightmapJobByUserIDParams) (sqlc.HeightmapJob, error)
+	LockHeightmapJobStatus(ctx context.Context, id uuid.UUID) (string, error)
+	GetAccessibleHeightmapJob(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error)
 	ListUserHeightmaps(ctx context.Context, params sqlc.ListUserHeightmapsParams) ([]sqlc.HeightmapJob, error)
+	ListOrganizationHeightmaps(ctx context.Context, params sqlc.ListOrganizationHeightmapsParams) ([]sqlc.HeightmapJob, error)
+	ListProjectHeightmaps(ctx context.Context, params sqlc.ListProjectHeightmapsParams) ([]sqlc.HeightmapJob, error)
+	ListUserHeightmapsByStatus(ctx context.Context, params sqlc.ListUserHeightmapsByStatusParams) ([]sqlc.HeightmapJob, error)
+	ListHeightmaps(ctx context.Context, params sqlc.ListHeightmapsParams) ([]sqlc.HeightmapJob, error)
+	ListHeightmapsByStatus(ctx context.Context, params sqlc.ListHeightmapsByStatusParams) ([]sqlc.HeightmapJob, error)
 	UpdateJobStatus(ctx context.Context, params sqlc.UpdateJobStatusParams) error
 	UpdateJobResult(ctx context.Context, params sqlc.UpdateJobResultParams) error
 	UpdateJobError(ctx context.Context, params sqlc.UpdateJobErrorParams) error
+	ListStaleHeightmapJobs(ctx context.Context, params sqlc.ListStaleHeightmapJobsParams) ([]sqlc.HeightmapJob, error)
+	CountStaleHeightmapJobs(ctx context.Context, params sqlc.CountStaleHeightmapJobsParams) (int64, error)
+	RequeueHeightmapJob(ctx context.Context, params sqlc.RequeueHeightmapJobParams) error
+	CountInFlightHeightmapJobs(ctx context.Context) (int64, error)
+	ListWaitingHeightmapJobs(ctx context.Context, limit int32) ([]sqlc.HeightmapJob, error)
+	GetHeightmapJobQueuePosition(ctx context.Context, id uuid.UUID) (int64, error)
+	MarkHeightmapJobDispatched(ctx context.Context, params sqlc.MarkHeightmapJobDispatchedParams) (int64, error)
+	ReleaseDueHeightmapJobs(ctx context.Context, params sqlc.ReleaseDueHeightmapJobsParams) ([]sqlc.HeightmapJob, error)
+	RescheduleHeightmapJob(ctx context.Context, params sqlc.RescheduleHeightmapJobParams) (int64, error)
+	CancelHeightmapJob(ctx context.Context, params sqlc.CancelHeightmapJobParams) (int64, error)
+	DeleteFinishedHeightmapJob(ctx context.Context, params sqlc.DeleteFinishedHeightmapJobParams) (sqlc.HeightmapJob, error)
 
 	CreateBatchHeightmapJob(ctx context.Context, params sqlc.CreateBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error)
 	CreateBatchImage(ctx context.Context, params sqlc.CreateBatchImageParams) (sqlc.BatchImage, error)
 	GetBatchHeightmapJob(ctx context.Context, id uuid.UUID) (sqlc.BatchHeightmapJob, error)
-	GetBatchHeightmapJobByUserID(ctx context.Context, params sqlc.GetBatchHeightmapJobByUserIDParams) (sqlc.BatchHeightmapJob, error)
+	LockBatchHeightmapJobStatus(ctx context.Context, id uuid.UUID) (string, error)
+	GetAccessibleBatchHeightmapJob(ctx context.Context, params sqlc.GetAccessibleBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error)
 	ListUserBatchHeightmaps(ctx context.Context, params sqlc.ListUserBatchHeightmapsParams) ([]sqlc.BatchHeightmapJob, error)
+	ListOrganizationBatchHeightmaps(ctx context.Context, params sqlc.ListOrganizationBatchHeightmapsParams) ([]sqlc.BatchHeightmapJob, error)
+	ListProjectBatchHeightmaps(ctx context.Context, params sqlc.ListProjectBatchHeightmapsParams) ([]sqlc.BatchHeightmapJob, error)
+	ListUserBatchHeightmapsByStatus(ctx context.Context, params sqlc.ListUserBatchHeightmapsByStatusParams) ([]sqlc.BatchHeightmapJob, error)
+	ListBatchHeightmaps(ctx context.Context, params sqlc.ListBatchHeightmapsParams) ([]sqlc.BatchHeightmapJob, error)
+	ListBatchHeightmapsByStatus(ctx context.Context, params sqlc.ListBatchHeightmapsByStatusParams) ([]sqlc.BatchHeightmapJob, error)
 	UpdateBatchJobStatus(ctx context.Context, params sqlc.UpdateBatchJobStatusParams) error
 	UpdateBatchJobResult(ctx context.Context, params sqlc.UpdateBatchJobResultParams) error
 	UpdateBatchJobError(ctx context.Context, params sqlc.UpdateBatchJobErrorParams) error
 	UpdateBatchJobProgress(ctx context.Context, params sqlc.UpdateBatchJobProgressParams) error
 	GetBatchImages(ctx context.Context, batchJobID pgtype.UUID) ([]sqlc.BatchImage, error)
 	UpdateBatchImageStatus(ctx context.Context, params sqlc.UpdateBatchImageStatusParams) error
+	ListStaleBatchHeightmapJobs(ctx context.Context, params sqlc.ListStaleBatchHeightmapJobsParams) ([]sqlc.BatchHeightmapJob, error)
+	CountStaleBatchHeightmapJobs(ctx context.Context, params sqlc.CountStaleBatchHeightmapJobsParams) (int64, error)
+	RequeueBatchHeightmapJob(ctx context.Context, params sqlc.RequeueBatchHeightmapJobParams) error
+	CountInFlightBatchHeightmapJobs(ctx context.Context) (int64, error)
+	ListWaitingBatchHeightmapJobs(ctx context.Context, limit int32) ([]sqlc.BatchHeightmapJob, error)
+	GetBatchHeightmapJobQueuePosition(ctx context.Context, id uuid.UUID) (int64, error)
+	MarkBatchHeightmapJobDispatched(ctx context.Context, params sqlc.MarkBatchHeightmapJobDispatchedParams) (int64, error)
+	ReleaseDueBatchHeightmapJobs(ctx context.Context, params sqlc.ReleaseDueBatchHeightmapJobsParams) ([]sqlc.BatchHeightmapJob, error)
+	RescheduleBatchHeightmapJob(ctx context.Context, params sqlc.RescheduleBatchHeightmapJobParams) (int64, error)
+	CancelBatchHeightmapJob(ctx context.Context, params sqlc.CancelBatchHeightmapJobParams) (int64, error)
+	DeleteFinishedBatchHeightmapJob(ctx context.Context, params sqlc.DeleteFinishedBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error)
+
+	CreateJobEvent(ctx context.Context, params sqlc.CreateJobEventParams) (sqlc.JobEvent, error)
+	ListUserJobEventsAfter(ctx context.Context, params sqlc.ListUserJobEventsAfterParams) ([]sqlc.JobEvent, error)
+	ListJobEvents(ctx context.Context, jobID pgtype.UUID) ([]sqlc.JobEvent, error)
+	webhook.DeliveryQueriesInterface
+
+	CreateOutboxMessage(ctx context.Context, params sqlc.CreateOutboxMessageParams) (sqlc.OutboxMessage, error)
+
+	CreateJobShare(ctx context.Context, params sqlc.CreateJobShareParams) (sqlc.JobShare, error)
+	GetJobShareByTokenHash(ctx context.Context, tokenHash string) (sqlc.JobShare, error)
+	ListHeightmapJobShares(ctx context.Context, heightmapJobID *uuid.UUID) ([]sqlc.JobShare, error)
+	ListBatchJobShares(ctx context.Context, batchJobID *uuid.UUID) ([]sqlc.JobShare, error)
+	RevokeJobShare(ctx context.Context, params sqlc.RevokeJobShareParams) (int64, error)
+	JobShareExists(ctx context.Context, params sqlc.JobShareExistsParams) (bool, error)
+	CreateJobShareAccess(ctx context.Context, params sqlc.CreateJobShareAccessParams) error
+	CountJobShareDenialsSince(ctx context.Context, params sqlc.CountJobShareDenialsSinceParams) (int64, error)
+	ListJobShareAccesses(ctx context.Context, params sqlc.ListJobShareAccessesParams) ([]sqlc.JobShareAccess, error)
+
+	GetUserQuotaPlan(ctx context.Context, userID uuid.UUID) (sqlc.QuotaPlan, error)
+	GetUserUsage(ctx context.Context, params sqlc.GetUserUsageParams) (sqlc.GetUserUsageRow, error)
+	CreateStorageUsage(ctx context.Context, params sqlc.CreateStorageUsageParams) error
+	DeleteHeightmapJobStorageUsage(ctx context.Context, heightmapJobID *uuid.UUID) error
+	DeleteBatchJobStorageUsage(ctx context.Context, batchJobID *uuid.UUID) error
+	LockUserQuota(ctx context.Context, userID uuid.UUID) error
+	ScheduleJobStorageDeletion(ctx context.Context, params sqlc.ScheduleJobStorageDeletionParams) error
+	ListQuotaPlans(ctx context.Context) ([]sqlc.QuotaPlan, error)
+	GetQuotaPlan(ctx context.Context, name string) (sqlc.QuotaPlan, error)
+	SetUserQuotaPlan(ctx context.Context, params sqlc.SetUserQuotaPlanParams) (int64, error)
+
+	TryAdvisoryXactLock(ctx context.Context, key int64) (bool, error)
+}
+
+type OutboxQueriesInterface interface {
+	ClaimPendingOutboxMessages(ctx context.Context, limit int32) ([]sqlc.OutboxMessage, error)
+	MarkOutboxMessageSent(ctx context.Context, params sqlc.MarkOutboxMessageSentParams) error
+	RescheduleOutboxMessage(ctx context.Context, params sqlc.RescheduleOutboxMessageParams) error
+	DeleteSentOutboxMessagesBefore(ctx context.Context, sentAt *time.Time) error
+}
+
+type StorageDeletionQueriesInterface interface {
+	ClaimDueStorageDeletions(ctx context.Context, params sqlc.ClaimDueStorageDeletionsParams) ([]sqlc.StorageDeletion, error)
+	RescheduleStorageDeletion(ctx context.Context, params sqlc.RescheduleStorageDeletionParams) error
+	DeleteStorageDeletion(ctx context.Context, id uuid.UUID) error
 }
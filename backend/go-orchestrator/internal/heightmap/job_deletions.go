@@ -0,0 +1,82 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+var ErrJobNotDeletable = errors.New("удалить можно только завершенную, упавшую или отмененную задачу")
+
+// DeleteHeightmapJob deletes a finished job. Its storage_usage rows go with
+// it, which gives the bytes back to the quota of the owner, and its upload and
+// results are handed to the StorageCleaner.
+func (s *Service) DeleteHeightmapJob(ctx context.Context, jobID, userID uuid.UUID) error {
+	err := s.withTx(ctx, func(q QueriesInterface) error {
+		job, err := q.DeleteFinishedHeightmapJob(ctx, sqlc.DeleteFinishedHeightmapJobParams{
+			ID:     jobID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.ScheduleJobStorageDeletion(ctx, sqlc.ScheduleJobStorageDeletionParams{
+			UserID:          job.UserID,
+			HeightmapJobIds: []uuid.UUID{job.ID},
+			BatchJobIds:     []uuid.UUID{},
+			InputPrefixes:   []string{heightmapInputPrefix(job.UserID, job.ID)},
+		})
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.jobStateError(ctx, JobTypeHeightmap, jobID, userID, ErrJobNotDeletable)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось удалить задачу: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteBatchHeightmapJob is DeleteHeightmapJob for batch jobs.
+func (s *Service) DeleteBatchHeightmapJob(ctx context.Context, batchJobID, userID uuid.UUID) error {
+	err := s.withTx(ctx, func(q QueriesInterface) error {
+		job, err := q.DeleteFinishedBatchHeightmapJob(ctx, sqlc.DeleteFinishedBatchHeightmapJobParams{
+			ID:     batchJobID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.ScheduleJobStorageDeletion(ctx, sqlc.ScheduleJobStorageDeletionParams{
+			UserID:          job.UserID,
+			HeightmapJobIds: []uuid.UUID{},
+			BatchJobIds:     []uuid.UUID{job.ID},
+			InputPrefixes:   []string{batchInputPrefix(job.UserID, job.ID)},
+		})
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.jobStateError(ctx, JobTypeBatch, batchJobID, userID, ErrJobNotDeletable)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось удалить пакетную задачу: %w", err)
+	}
+
+	return nil
+}
+
+// heightmapInputPrefix matches the upload of a job, heightmaps/<user>/<job><ext>,
+// see UploadPhoto.
+func heightmapInputPrefix(userID, jobID uuid.UUID) string {
+	return fmt.Sprintf("heightmaps/%s/%s", userID, jobID)
+}
+
+// batchInputPrefix matches the images of a batch job, see BatchUploadPhotos.
+func batchInputPrefix(userID, batchJobID uuid.UUID) string {
+	return fmt.Sprintf("batch-heightmaps/%s/%s/", userID, batchJobID)
+}
@@ -0,0 +1,111 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+func TestDeleteHeightmapJob(t *testing.T) {
+	userID := uuid.New()
+	jobID := uuid.New()
+
+	tests := []struct {
+		name        string
+		deleted     bool
+		lookupErr   error
+		expectedErr error
+	}{
+		{name: "deletes finished job", deleted: true},
+		{name: "job still running", expectedErr: ErrJobNotDeletable},
+		{name: "job of another user", lookupErr: pgx.ErrNoRows, expectedErr: ErrJobNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var scheduled []sqlc.ScheduleJobStorageDeletionParams
+			queries := &mockQueries{
+				deleteFinishedJobFunc: func(ctx context.Context, params sqlc.DeleteFinishedHeightmapJobParams) (sqlc.HeightmapJob, error) {
+					if !tt.deleted {
+						return sqlc.HeightmapJob{}, pgx.ErrNoRows
+					}
+					return sqlc.HeightmapJob{ID: params.ID, UserID: userID, Status: "completed"}, nil
+				},
+				getAccessibleJobFunc: func(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error) {
+					if tt.lookupErr != nil {
+						return sqlc.HeightmapJob{}, tt.lookupErr
+					}
+					return sqlc.HeightmapJob{ID: params.ID, UserID: userID, Status: "processing"}, nil
+				},
+				scheduleJobDeletionFunc: func(ctx context.Context, params sqlc.ScheduleJobStorageDeletionParams) error {
+					scheduled = append(scheduled, params)
+					return nil
+				},
+			}
+			s := newTestScheduleService(queries)
+
+			err := s.DeleteHeightmapJob(context.Background(), jobID, userID)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if len(scheduled) != 0 {
+					t.Error("no storage deletion must be scheduled when nothing was deleted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(scheduled) != 1 {
+				t.Fatalf("expected one storage deletion, got %d", len(scheduled))
+			}
+			deletion := scheduled[0]
+			if len(deletion.HeightmapJobIds) != 1 || deletion.HeightmapJobIds[0] != jobID {
+				t.Errorf("expected results of job %s to be removed, got %v", jobID, deletion.HeightmapJobIds)
+			}
+			expectedPrefix := "heightmaps/" + userID.String() + "/" + jobID.String()
+			if len(deletion.InputPrefixes) != 1 || deletion.InputPrefixes[0] != expectedPrefix {
+				t.Errorf("expected upload prefix %q, got %v", expectedPrefix, deletion.InputPrefixes)
+			}
+		})
+	}
+}
+
+func TestDeleteBatchHeightmapJobRemovesImages(t *testing.T) {
+	ownerID := uuid.New()
+	batchID := uuid.New()
+
+	var scheduled sqlc.ScheduleJobStorageDeletionParams
+	queries := &mockQueries{
+		deleteFinishedBatchJobFunc: func(ctx context.Context, params sqlc.DeleteFinishedBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error) {
+			return sqlc.BatchHeightmapJob{ID: params.ID, UserID: ownerID, Status: "failed"}, nil
+		},
+		scheduleJobDeletionFunc: func(ctx context.Context, params sqlc.ScheduleJobStorageDeletionParams) error {
+			scheduled = params
+			return nil
+		},
+	}
+	s := newTestScheduleService(queries)
+
+	// An organization admin deletes the job; its files are still under the owner.
+	if err := s.DeleteBatchHeightmapJob(context.Background(), batchID, uuid.New()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if scheduled.UserID != ownerID {
+		t.Errorf("expected deletion for owner %s, got %s", ownerID, scheduled.UserID)
+	}
+	expectedPrefix := "batch-heightmaps/" + ownerID.String() + "/" + batchID.String() + "/"
+	if len(scheduled.InputPrefixes) != 1 || scheduled.InputPrefixes[0] != expectedPrefix {
+		t.Errorf("expected upload prefix %q, got %v", expectedPrefix, scheduled.InputPrefixes)
+	}
+	if len(scheduled.BatchJobIds) != 1 || scheduled.BatchJobIds[0] != batchID {
+		t.Errorf("expected results of batch %s to be removed, got %v", batchID, scheduled.BatchJobIds)
+	}
+}
@@ -0,0 +1,128 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+type JobUpdatesSubscriberInterface interface {
+	Bind(jobID string) error
+	Unbind(jobID string) error
+	Consume(ctx context.Context, handler rabbitmq.JobUpdateHandler) error
+}
+
+// JobUpdatesHub routes worker updates received from RabbitMQ to the WebSocket
+// connections watching the job. The routing key of a job is bound while at
+// least one connection of this replica watches it.
+type JobUpdatesHub struct {
+	subscriber JobUpdatesSubscriberInterface
+	logger     middleware.LoggerInterface
+
+	mu       sync.Mutex
+	watchers map[string]map[chan<- *rabbitmq.JobUpdate]struct{}
+}
+
+func NewJobUpdatesHub(subscriber JobUpdatesSubscriberInterface, logger middleware.LoggerInterface) *JobUpdatesHub {
+	return &JobUpdatesHub{
+		subscriber: subscriber,
+		logger:     logger,
+		watchers:   make(map[string]map[chan<- *rabbitmq.JobUpdate]struct{}),
+	}
+}
+
+func (h *JobUpdatesHub) Run(ctx context.Context) {
+	if err := h.subscriber.Consume(ctx, h.dispatch); err != nil {
+		h.logger.Error("Job updates hub stopped", err)
+	}
+}
+
+func (h *JobUpdatesHub) Watch(jobID string, ch chan<- *rabbitmq.JobUpdate) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.watchers[jobID] == nil {
+		if err := h.subscriber.Bind(jobID); err != nil {
+			return err
+		}
+		h.watchers[jobID] = make(map[chan<- *rabbitmq.JobUpdate]struct{})
+	}
+	h.watchers[jobID][ch] = struct{}{}
+
+	return nil
+}
+
+func (h *JobUpdatesHub) Unwatch(jobID string, ch chan<- *rabbitmq.JobUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	watchers, ok := h.watchers[jobID]
+	if !ok {
+		return
+	}
+
+	delete(watchers, ch)
+	if len(watchers) > 0 {
+		return
+	}
+
+	delete(h.watchers, jobID)
+	if err := h.subscriber.Unbind(jobID); err != nil {
+		h.logger.Error("Failed to unbind job updates", err, map[string]interface{}{
+			"job_id": jobID,
+		})
+	}
+}
+
+// dispatch never blocks the consumer: updates for a connection whose buffer is
+// full are dropped.
+func (h *JobUpdatesHub) dispatch(update *rabbitmq.JobUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.watchers[update.JobID] {
+		select {
+		case ch <- update:
+		default:
+			h.logger.Warn("Dropping job update for slow WebSocket client", map[string]interface{}{
+				"job_id": update.JobID,
+				"type":   update.Type,
+			})
+		}
+	}
+}
+
+// CanWatchJob reports whether the user owns the single or batch job with the
+// given ID, or sees it through an organization.
+func (s *Service) CanWatchJob(ctx context.Context, jobID uuid.UUID, userID uuid.UUID) (bool, error) {
+	_, err := s.queries.GetAccessibleHeightmapJob(ctx, sqlc.GetAccessibleHeightmapJobParams{
+		ID:     jobID,
+		UserID: userID,
+	})
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("не удалось проверить доступ к задаче: %w", err)
+	}
+
+	_, err = s.queries.GetAccessibleBatchHeightmapJob(ctx, sqlc.GetAccessibleBatchHeightmapJobParams{
+		ID:     jobID,
+		UserID: userID,
+	})
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("не удалось проверить доступ к задаче: %w", err)
+	}
+
+	return false, nil
+}
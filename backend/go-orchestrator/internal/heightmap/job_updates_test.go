@@ -0,0 +1,133 @@
+package heightmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+type mockJobUpdatesSubscriber struct {
+	bound map[string]int
+}
+
+func (m *mockJobUpdatesSubscriber) Bind(jobID string) error {
+	m.bound[jobID]++
+	return nil
+}
+
+func (m *mockJobUpdatesSubscriber) Unbind(jobID string) error {
+	m.bound[jobID]--
+	return nil
+}
+
+func (m *mockJobUpdatesSubscriber) Consume(ctx context.Context, handler rabbitmq.JobUpdateHandler) error {
+	return nil
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...map[string]interface{})            {}
+func (nopLogger) Info(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Warn(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Error(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) Fatal(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) WithContext(ctx context.Context) *middleware.Logger            { return nil }
+func (nopLogger) Middleware() gin.HandlerFunc                                   { return nil }
+
+func TestJobUpdatesHub(t *testing.T) {
+	subscriber := &mockJobUpdatesSubscriber{bound: make(map[string]int)}
+	hub := NewJobUpdatesHub(subscriber, nopLogger{})
+
+	jobID := uuid.New().String()
+	first := make(chan *rabbitmq.JobUpdate, 1)
+	second := make(chan *rabbitmq.JobUpdate, 1)
+
+	if err := hub.Watch(jobID, first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := hub.Watch(jobID, second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if subscriber.bound[jobID] != 1 {
+		t.Errorf("expected routing key to be bound once, got %d", subscriber.bound[jobID])
+	}
+
+	hub.dispatch(&rabbitmq.JobUpdate{Type: rabbitmq.JobUpdateLog, JobID: jobID, Message: "loading model"})
+	hub.dispatch(&rabbitmq.JobUpdate{Type: rabbitmq.JobUpdateLog, JobID: uuid.New().String(), Message: "other job"})
+
+	for _, ch := range []chan *rabbitmq.JobUpdate{first, second} {
+		select {
+		case update := <-ch:
+			if update.Message != "loading model" {
+				t.Errorf("unexpected update: %+v", update)
+			}
+		default:
+			t.Error("expected update to be delivered")
+		}
+	}
+
+	// A full buffer must not block the dispatcher.
+	hub.dispatch(&rabbitmq.JobUpdate{Type: rabbitmq.JobUpdateStatus, JobID: jobID})
+	hub.dispatch(&rabbitmq.JobUpdate{Type: rabbitmq.JobUpdateStatus, JobID: jobID})
+
+	hub.Unwatch(jobID, first)
+	if subscriber.bound[jobID] != 1 {
+		t.Errorf("expected routing key to stay bound while watched")
+	}
+
+	hub.Unwatch(jobID, second)
+	if subscriber.bound[jobID] != 0 {
+		t.Errorf("expected routing key to be unbound, got %d", subscriber.bound[jobID])
+	}
+}
+
+func TestCanWatchJob(t *testing.T) {
+	userID := uuid.New()
+	jobID := uuid.New()
+
+	tests := []struct {
+		name       string
+		ownsSingle bool
+		ownsBatch  bool
+		expected   bool
+	}{
+		{name: "own single job", ownsSingle: true, expected: true},
+		{name: "own batch job", ownsBatch: true, expected: true},
+		{name: "foreign job", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := &mockQueries{
+				getAccessibleJobFunc: func(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error) {
+					if tt.ownsSingle {
+						return sqlc.HeightmapJob{ID: params.ID, UserID: params.UserID}, nil
+					}
+					return sqlc.HeightmapJob{}, pgx.ErrNoRows
+				},
+				getAccessibleBatchJobFunc: func(ctx context.Context, params sqlc.GetAccessibleBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error) {
+					if tt.ownsBatch {
+						return sqlc.BatchHeightmapJob{ID: params.ID, UserID: params.UserID}, nil
+					}
+					return sqlc.BatchHeightmapJob{}, pgx.ErrNoRows
+				},
+			}
+
+			s := &Service{queries: queries}
+
+			allowed, err := s.CanWatchJob(context.Background(), jobID, userID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, allowed)
+			}
+		})
+	}
+}
@@ -4,20 +4,31 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+const (
+	JobTypeHeightmap = "heightmap"
+	JobTypeBatch     = "batch"
 )
 
 type HeightmapJob struct {
-	ID             uuid.UUID `json:"id"`
-	UserID         uuid.UUID `json:"user_id"`
-	ImageURL       string    `json:"image_url"`
-	ResultURL      *string   `json:"result_url,omitempty"`
-	Status         string    `json:"status"`
-	Width          *int32    `json:"width,omitempty"`
-	Height         *int32    `json:"height,omitempty"`
-	ErrorMessage   *string   `json:"error_message,omitempty"`
-	ProcessingTime *float64  `json:"processing_time,omitempty"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ImageURL       string     `json:"image_url"`
+	ResultURL      *string    `json:"result_url,omitempty"`
+	Status         string     `json:"status"`
+	Width          *int32     `json:"width,omitempty"`
+	Height         *int32     `json:"height,omitempty"`
+	ErrorMessage   *string    `json:"error_message,omitempty"`
+	ProcessingTime *float64   `json:"processing_time,omitempty"`
+	Priority       string     `json:"priority"`
+	QueuePosition  *int64     `json:"queue_position,omitempty"`
+	ScheduledAt    *time.Time `json:"scheduled_at,omitempty"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	ProjectID      *uuid.UUID `json:"project_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 type Point struct {
@@ -30,3 +41,157 @@ type PointHeight struct {
 	Y      float64 `json:"y"`
 	Height float64 `json:"height"`
 }
+
+func newHeightmapJob(job sqlc.HeightmapJob) *HeightmapJob {
+	return &HeightmapJob{
+		ID:             job.ID,
+		UserID:         job.UserID,
+		ImageURL:       job.ImageUrl,
+		ResultURL:      job.ResultUrl,
+		Status:         job.Status,
+		Width:          job.Width,
+		Height:         job.Height,
+		ErrorMessage:   job.ErrorMessage,
+		ProcessingTime: job.ProcessingTime,
+		Priority:       priorityClass(job.Priority),
+		ScheduledAt:    job.ScheduledAt,
+		OrganizationID: job.OrganizationID,
+		ProjectID:      job.ProjectID,
+		CreatedAt:      job.CreatedAt,
+		UpdatedAt:      job.UpdatedAt,
+	}
+}
+
+func newBatchHeightmapJob(job sqlc.BatchHeightmapJob) *BatchHeightmapJob {
+	result := &BatchHeightmapJob{
+		ID:             job.ID,
+		UserID:         job.UserID,
+		Status:         job.Status,
+		ResultURL:      job.ResultUrl,
+		OrthophotoURL:  job.OrthophotoUrl,
+		Width:          job.Width,
+		Height:         job.Height,
+		ImageCount:     job.ImageCount,
+		ProcessedCount: job.ProcessedCount,
+		ErrorMessage:   job.ErrorMessage,
+		ProcessingTime: job.ProcessingTime,
+		MergeMethod:    job.MergeMethod,
+		Priority:       priorityClass(job.Priority),
+		OrganizationID: job.OrganizationID,
+		ProjectID:      job.ProjectID,
+		CreatedAt:      job.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      job.UpdatedAt.Format(time.RFC3339),
+	}
+	if job.ScheduledAt != nil {
+		scheduledAt := job.ScheduledAt.Format(time.RFC3339)
+		result.ScheduledAt = &scheduledAt
+	}
+
+	return result
+}
+
+// JobShare is a public link to the result of a job. Token and URL are only
+// set in the response that creates the link.
+type JobShare struct {
+	ID                uuid.UUID  `json:"id"`
+	JobType           string     `json:"job_type"`
+	JobID             uuid.UUID  `json:"job_id"`
+	CreatedBy         *uuid.UUID `json:"created_by,omitempty"`
+	PasswordProtected bool       `json:"password_protected"`
+	AllowDownload     bool       `json:"allow_download"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	Token             string     `json:"token,omitempty"`
+	URL               string     `json:"url,omitempty"`
+}
+
+type ShareAccess struct {
+	ID        uuid.UUID `json:"id"`
+	Action    string    `json:"action"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SharedJob is what a visitor of a share link sees. PreviewURL and the files
+// are only available once the job has completed, and only if the link allows
+// downloads.
+type SharedJob struct {
+	JobType       string     `json:"job_type"`
+	Status        string     `json:"status"`
+	Width         *int32     `json:"width,omitempty"`
+	Height        *int32     `json:"height,omitempty"`
+	ImageCount    *int32     `json:"image_count,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PreviewURL    *string    `json:"preview_url,omitempty"`
+	URLExpiresAt  *time.Time `json:"url_expires_at,omitempty"`
+	AllowDownload bool       `json:"allow_download"`
+	Files         []string   `json:"files,omitempty"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+}
+
+type SharedFile struct {
+	File      string    `json:"file"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newJobShare(share sqlc.JobShare) *JobShare {
+	result := &JobShare{
+		ID:                share.ID,
+		CreatedBy:         share.CreatedBy,
+		PasswordProtected: share.PasswordHash != nil,
+		AllowDownload:     share.AllowDownload,
+		ExpiresAt:         share.ExpiresAt,
+		RevokedAt:         share.RevokedAt,
+		CreatedAt:         share.CreatedAt,
+	}
+	if share.HeightmapJobID != nil {
+		result.JobType, result.JobID = JobTypeHeightmap, *share.HeightmapJobID
+	} else if share.BatchJobID != nil {
+		result.JobType, result.JobID = JobTypeBatch, *share.BatchJobID
+	}
+	return result
+}
+
+func newShareAccess(access sqlc.JobShareAccess) *ShareAccess {
+	return &ShareAccess{
+		ID:        access.ID,
+		Action:    access.Action,
+		IPAddress: access.IpAddress,
+		UserAgent: access.UserAgent,
+		CreatedAt: access.CreatedAt,
+	}
+}
+
+// QuotaPlan limits what a user may upload. Zero means no limit.
+type QuotaPlan struct {
+	Name                 string `json:"name"`
+	MaxStorageBytes      int64  `json:"max_storage_bytes"`
+	MaxJobsPerDay        int32  `json:"max_jobs_per_day"`
+	MaxConcurrentBatches int32  `json:"max_concurrent_batches"`
+	MaxImagesPerBatch    int32  `json:"max_images_per_batch"`
+	MaxPriority          string `json:"max_priority"`
+}
+
+// Usage is what a user has used of their plan. Jobs are counted per UTC day,
+// until JobsResetAt.
+type Usage struct {
+	Plan          *QuotaPlan `json:"plan"`
+	StorageBytes  int64      `json:"storage_bytes"`
+	JobsToday     int64      `json:"jobs_today"`
+	ActiveBatches int64      `json:"active_batches"`
+	JobsResetAt   time.Time  `json:"jobs_reset_at"`
+}
+
+func newQuotaPlan(plan sqlc.QuotaPlan) *QuotaPlan {
+	return &QuotaPlan{
+		Name:                 plan.Name,
+		MaxStorageBytes:      plan.MaxStorageBytes,
+		MaxJobsPerDay:        plan.MaxJobsPerDay,
+		MaxConcurrentBatches: plan.MaxConcurrentBatches,
+		MaxImagesPerBatch:    plan.MaxImagesPerBatch,
+		MaxPriority:          plan.MaxPriority,
+	}
+}
@@ -0,0 +1,88 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+// ErrOrganizationAccessDenied rejects uploads to and listings of an
+// organization the user is not a member of.
+var ErrOrganizationAccessDenied = errors.New("вы не состоите в этой организации")
+
+// organizationRole returns the role of the user in the organization, or
+// ErrOrganizationAccessDenied when they are not a member.
+func (s *Service) organizationRole(ctx context.Context, organizationID, userID uuid.UUID) (string, error) {
+	role, err := s.queries.GetOrganizationMemberRole(ctx, sqlc.GetOrganizationMemberRoleParams{
+		OrganizationID: organizationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrOrganizationAccessDenied
+		}
+		return "", fmt.Errorf("не удалось получить роль в организации: %w", err)
+	}
+	return role, nil
+}
+
+// requireOrganizationMember passes for personal uploads without an
+// organization and for members of the given one.
+func (s *Service) requireOrganizationMember(ctx context.Context, organizationID *uuid.UUID, userID uuid.UUID) error {
+	if organizationID == nil {
+		return nil
+	}
+	_, err := s.organizationRole(ctx, *organizationID, userID)
+	return err
+}
+
+// ListOrganizationHeightmaps lists the jobs every member uploaded to the
+// organization.
+func (s *Service) ListOrganizationHeightmaps(ctx context.Context, organizationID, userID uuid.UUID, limit, offset int32) ([]*HeightmapJob, error) {
+	if _, err := s.organizationRole(ctx, organizationID, userID); err != nil {
+		return nil, err
+	}
+
+	jobs, err := s.queries.ListOrganizationHeightmaps(ctx, sqlc.ListOrganizationHeightmapsParams{
+		OrganizationID: &organizationID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список карт высот: %w", err)
+	}
+
+	result := make([]*HeightmapJob, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, newHeightmapJob(job))
+	}
+
+	return result, nil
+}
+
+// ListOrganizationBatchHeightmaps is ListOrganizationHeightmaps for batch jobs.
+func (s *Service) ListOrganizationBatchHeightmaps(ctx context.Context, organizationID, userID uuid.UUID, limit, offset int32) ([]*BatchHeightmapJob, error) {
+	if _, err := s.organizationRole(ctx, organizationID, userID); err != nil {
+		return nil, err
+	}
+
+	jobs, err := s.queries.ListOrganizationBatchHeightmaps(ctx, sqlc.ListOrganizationBatchHeightmapsParams{
+		OrganizationID: &organizationID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список пакетных карт высот: %w", err)
+	}
+
+	result := make([]*BatchHeightmapJob, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, newBatchHeightmapJob(job))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,119 @@
+package heightmap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+func TestUploadPhotoToOrganization(t *testing.T) {
+	tests := []struct {
+		name        string
+		roleErr     error
+		expectedErr error
+	}{
+		{name: "member shares the job"},
+		{name: "not a member", roleErr: pgx.ErrNoRows, expectedErr: ErrOrganizationAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *sqlc.CreateHeightmapJobParams
+			queries := &mockQueries{
+				memberRoleFunc: func(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error) {
+					return "member", tt.roleErr
+				},
+				createJobFunc: func(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error) {
+					created = &params
+					return sqlc.HeightmapJob{ID: params.ID}, nil
+				},
+			}
+			s := newTestScheduleService(queries)
+
+			organizationID := uuid.New()
+			file := memoryFile{bytes.NewReader([]byte("image"))}
+			resp, err := s.UploadPhoto(context.Background(), uuid.New(), &organizationID, nil, file, &multipart.FileHeader{Filename: "photo.jpg", Size: 5}, priorityValues[PriorityNormal], nil)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if created != nil {
+					t.Error("no job must be created outside the user's organizations")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if created == nil || created.OrganizationID == nil || *created.OrganizationID != organizationID {
+				t.Fatalf("expected the job to belong to the organization, got %+v", created)
+			}
+			if resp.OrganizationID == nil || *resp.OrganizationID != organizationID {
+				t.Errorf("expected organization in response, got %+v", resp)
+			}
+		})
+	}
+}
+
+func TestCancelOrganizationJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        string
+		expectedErr error
+	}{
+		{name: "member cannot cancel another member's job", role: "member", expectedErr: ErrJobAccessDenied},
+		{name: "admin sees the real state", role: "admin", expectedErr: ErrJobNotCancellable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			organizationID := uuid.New()
+			queries := &mockQueries{
+				cancelJobFunc: func(ctx context.Context, params sqlc.CancelHeightmapJobParams) (int64, error) {
+					return 0, nil
+				},
+				getAccessibleJobFunc: func(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error) {
+					return sqlc.HeightmapJob{ID: params.ID, UserID: uuid.New(), Status: "processing", OrganizationID: &organizationID}, nil
+				},
+				memberRoleFunc: func(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error) {
+					if params.OrganizationID != organizationID {
+						t.Errorf("expected role lookup in %s, got %s", organizationID, params.OrganizationID)
+					}
+					return tt.role, nil
+				},
+			}
+			s := newTestScheduleService(queries)
+
+			_, err := s.CancelHeightmapJob(context.Background(), uuid.New(), uuid.New())
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestListOrganizationHeightmapsRequiresMembership(t *testing.T) {
+	listed := false
+	queries := &mockQueries{
+		listOrgHeightmaps: func(ctx context.Context, params sqlc.ListOrganizationHeightmapsParams) ([]sqlc.HeightmapJob, error) {
+			listed = true
+			return nil, nil
+		},
+	}
+	s := newTestScheduleService(queries)
+
+	_, err := s.ListOrganizationHeightmaps(context.Background(), uuid.New(), uuid.New(), 20, 0)
+	if !errors.Is(err, ErrOrganizationAccessDenied) {
+		t.Fatalf("expected %v, got %v", ErrOrganizationAccessDenied, err)
+	}
+	if listed {
+		t.Error("jobs must not be listed for a non-member")
+	}
+}
@@ -0,0 +1,277 @@
+package heightmap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/internal/webhook"
+	"github.com/skr1ms/dev2gis/pkg/metrics"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+const (
+	OutboxMessageHeightmapTask = "heightmap_task"
+	OutboxMessageBatchTask     = "batch_heightmap_task"
+
+	OutboxStatusPending = "pending"
+	OutboxStatusFailed  = "failed"
+
+	outboxCleanupEvery   = time.Hour
+	maxOutboxErrorLength = 1000
+)
+
+// OutboxRelay publishes tasks that were committed to outbox_messages together
+// with their job rows. Messages are claimed with FOR UPDATE SKIP LOCKED and
+// marked sent in the same transaction, so a crash after publishing and before
+// commit sends the task again: delivery is at-least-once.
+type OutboxRelay struct {
+	withTx    func(ctx context.Context, fn func(q OutboxQueriesInterface) error) error
+	queries   OutboxQueriesInterface
+	publisher TaskPublisherInterface
+	presigner ObjectPresignerInterface
+	cfg       *config.Config
+	logger    middleware.LoggerInterface
+}
+
+func NewOutboxRelay(db *storage.DB, publisher TaskPublisherInterface, presigner ObjectPresignerInterface, cfg *config.Config, logger middleware.LoggerInterface) *OutboxRelay {
+	return &OutboxRelay{
+		withTx: func(ctx context.Context, fn func(q OutboxQueriesInterface) error) error {
+			return db.WithTx(ctx, func(q *sqlc.Queries) error {
+				return fn(q)
+			})
+		},
+		queries:   db.Queries,
+		publisher: publisher,
+		presigner: presigner,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+func (r *OutboxRelay) Run(ctx context.Context) {
+	go r.cleanupLoop(ctx)
+
+	ticker := time.NewTicker(r.cfg.Outbox.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.withTx(ctx, func(q OutboxQueriesInterface) error {
+				return r.relayBatch(ctx, q)
+			})
+			if err != nil && ctx.Err() == nil {
+				r.logger.Error("Failed to relay outbox messages", err)
+			}
+		}
+	}
+}
+
+func (r *OutboxRelay) relayBatch(ctx context.Context, q OutboxQueriesInterface) error {
+	messages, err := q.ClaimPendingOutboxMessages(ctx, int32(r.cfg.Outbox.BatchSize))
+	if err != nil {
+		return fmt.Errorf("не удалось получить сообщения outbox: %w", err)
+	}
+
+	for _, message := range messages {
+		if err := r.publish(ctx, message); err != nil {
+			if err := r.reschedule(ctx, q, message, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		now := time.Now()
+		if err := q.MarkOutboxMessageSent(ctx, sqlc.MarkOutboxMessageSentParams{
+			ID:     message.ID,
+			SentAt: &now,
+		}); err != nil {
+			return fmt.Errorf("не удалось отметить сообщение outbox %s отправленным: %w", message.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// reschedule records a failed publish. The message is retried with exponential
+// backoff and marked failed after OUTBOX_MAX_ATTEMPTS; its job then stays
+// pending until the reaper requeues it with a fresh message or fails it.
+func (r *OutboxRelay) reschedule(ctx context.Context, q OutboxQueriesInterface, message sqlc.OutboxMessage, publishErr error) error {
+	attempts := message.Attempts + 1
+	status := OutboxStatusPending
+	if int(attempts) >= r.cfg.Outbox.MaxAttempts {
+		status = OutboxStatusFailed
+	}
+
+	errMsg := publishErr.Error()
+	if len(errMsg) > maxOutboxErrorLength {
+		errMsg = errMsg[:maxOutboxErrorLength]
+	}
+
+	r.logger.Warn("Failed to publish outbox message", map[string]interface{}{
+		"message_id":   message.ID.String(),
+		"message_type": message.MessageType,
+		"attempts":     attempts,
+		"status":       status,
+		"error":        errMsg,
+	})
+
+	if err := q.RescheduleOutboxMessage(ctx, sqlc.RescheduleOutboxMessageParams{
+		ID:            message.ID,
+		Status:        status,
+		NextAttemptAt: time.Now().Add(webhook.Backoff(r.cfg.Outbox.BaseBackoff, r.cfg.Outbox.MaxBackoff, attempts)),
+		LastError:     &errMsg,
+	}); err != nil {
+		return fmt.Errorf("не удалось отложить сообщение outbox %s: %w", message.ID, err)
+	}
+
+	if status == OutboxStatusFailed {
+		metrics.RecordOutboxMessageFailed(message.MessageType)
+	}
+	return nil
+}
+
+// publish decodes the stored task and attaches presigned URLs for its
+// objects. Storage credentials never leave the gateway.
+func (r *OutboxRelay) publish(ctx context.Context, message sqlc.OutboxMessage) error {
+	switch message.MessageType {
+	case OutboxMessageHeightmapTask:
+		var task rabbitmq.HeightmapTask
+		if err := json.Unmarshal(message.Payload, &task); err != nil {
+			return fmt.Errorf("некорректное тело задачи: %w", err)
+		}
+		if err := r.attachHeightmapObjects(ctx, &task); err != nil {
+			return err
+		}
+		return r.publisher.PublishTask(ctx, &task)
+
+	case OutboxMessageBatchTask:
+		var task rabbitmq.BatchHeightmapTask
+		if err := json.Unmarshal(message.Payload, &task); err != nil {
+			return fmt.Errorf("некорректное тело пакетной задачи: %w", err)
+		}
+		if err := r.attachBatchObjects(ctx, &task); err != nil {
+			return err
+		}
+		return r.publisher.PublishBatchTask(ctx, &task)
+
+	default:
+		return fmt.Errorf("неизвестный тип сообщения outbox %q", message.MessageType)
+	}
+}
+
+func (r *OutboxRelay) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(outboxCleanupEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			before := time.Now().Add(-r.cfg.Outbox.Retention)
+			if err := r.queries.DeleteSentOutboxMessagesBefore(ctx, &before); err != nil {
+				r.logger.Error("Failed to delete sent outbox messages", err)
+			}
+		}
+	}
+}
+
+// heightmapTaskMessage builds the outbox row that enqueues job. The task does
+// not carry storage access; the relay presigns its objects at publish time.
+func (s *Service) heightmapTaskMessage(job sqlc.HeightmapJob) (sqlc.CreateOutboxMessageParams, error) {
+	task := &rabbitmq.HeightmapTask{
+		JobID:     job.ID.String(),
+		UserID:    job.UserID.String(),
+		ImageURL:  job.ImageUrl,
+		CreatedAt: job.CreatedAt,
+		Priority:  int(job.Priority),
+	}
+	return newOutboxMessage(JobTypeHeightmap, job.ID, OutboxMessageHeightmapTask, task)
+}
+
+func (s *Service) batchTaskMessage(job sqlc.BatchHeightmapJob, imageURLs []string) (sqlc.CreateOutboxMessageParams, error) {
+	task := &rabbitmq.BatchHeightmapTask{
+		BatchJobID:     job.ID.String(),
+		UserID:         job.UserID.String(),
+		ImageURLs:      imageURLs,
+		MergeMethod:    job.MergeMethod,
+		FastMode:       job.FastMode,
+		GenerationMode: job.GenerationMode,
+		CreatedAt:      job.CreatedAt,
+		Priority:       int(job.Priority),
+	}
+	return newOutboxMessage(JobTypeBatch, job.ID, OutboxMessageBatchTask, task)
+}
+
+// requeueHeightmapJob moves job back to pending and writes a fresh task to the
+// outbox in the caller's transaction.
+func (s *Service) requeueHeightmapJob(ctx context.Context, q QueriesInterface, job sqlc.HeightmapJob, now time.Time) error {
+	message, err := s.heightmapTaskMessage(job)
+	if err != nil {
+		return err
+	}
+	if err := q.RequeueHeightmapJob(ctx, sqlc.RequeueHeightmapJobParams{ID: job.ID, UpdatedAt: now}); err != nil {
+		return fmt.Errorf("не удалось вернуть задачу %s в очередь: %w", job.ID, err)
+	}
+	if _, err := q.CreateOutboxMessage(ctx, message); err != nil {
+		return fmt.Errorf("не удалось поставить задачу %s в очередь: %w", job.ID, err)
+	}
+	return nil
+}
+
+func (s *Service) requeueBatchJob(ctx context.Context, q QueriesInterface, job sqlc.BatchHeightmapJob, now time.Time) error {
+	imageURLs, err := batchImageURLs(ctx, q, job.ID)
+	if err != nil {
+		return err
+	}
+
+	message, err := s.batchTaskMessage(job, imageURLs)
+	if err != nil {
+		return err
+	}
+	if err := q.RequeueBatchHeightmapJob(ctx, sqlc.RequeueBatchHeightmapJobParams{ID: job.ID, UpdatedAt: now}); err != nil {
+		return fmt.Errorf("не удалось вернуть пакетную задачу %s в очередь: %w", job.ID, err)
+	}
+	if _, err := q.CreateOutboxMessage(ctx, message); err != nil {
+		return fmt.Errorf("не удалось поставить пакетную задачу %s в очередь: %w", job.ID, err)
+	}
+	return nil
+}
+
+func batchImageURLs(ctx context.Context, q QueriesInterface, batchJobID uuid.UUID) ([]string, error) {
+	images, err := q.GetBatchImages(ctx, pgtype.UUID{Bytes: batchJobID, Valid: true})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить изображения пакетной задачи %s: %w", batchJobID, err)
+	}
+
+	imageURLs := make([]string, 0, len(images))
+	for _, image := range images {
+		imageURLs = append(imageURLs, image.ImageUrl)
+	}
+	return imageURLs, nil
+}
+
+func newOutboxMessage(aggregateType string, aggregateID uuid.UUID, messageType string, task interface{}) (sqlc.CreateOutboxMessageParams, error) {
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return sqlc.CreateOutboxMessageParams{}, fmt.Errorf("не удалось сериализовать задачу: %w", err)
+	}
+
+	return sqlc.CreateOutboxMessageParams{
+		AggregateType: aggregateType,
+		AggregateID:   pgtype.UUID{Bytes: aggregateID, Valid: true},
+		MessageType:   messageType,
+		Payload:       payload,
+	}, nil
+}
@@ -0,0 +1,252 @@
+package heightmap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+type mockOutboxQueries struct {
+	messages []sqlc.OutboxMessage
+	sent     []uuid.UUID
+	failed   []sqlc.RescheduleOutboxMessageParams
+}
+
+func (m *mockOutboxQueries) ClaimPendingOutboxMessages(ctx context.Context, limit int32) ([]sqlc.OutboxMessage, error) {
+	return m.messages, nil
+}
+
+func (m *mockOutboxQueries) MarkOutboxMessageSent(ctx context.Context, params sqlc.MarkOutboxMessageSentParams) error {
+	m.sent = append(m.sent, params.ID)
+	return nil
+}
+
+func (m *mockOutboxQueries) RescheduleOutboxMessage(ctx context.Context, params sqlc.RescheduleOutboxMessageParams) error {
+	m.failed = append(m.failed, params)
+	return nil
+}
+
+func (m *mockOutboxQueries) DeleteSentOutboxMessagesBefore(ctx context.Context, sentAt *time.Time) error {
+	return nil
+}
+
+type mockTaskPublisher struct {
+	publishTaskFunc      func(ctx context.Context, task *rabbitmq.HeightmapTask) error
+	publishBatchTaskFunc func(ctx context.Context, task *rabbitmq.BatchHeightmapTask) error
+}
+
+func (m *mockTaskPublisher) PublishTask(ctx context.Context, task *rabbitmq.HeightmapTask) error {
+	if m.publishTaskFunc != nil {
+		return m.publishTaskFunc(ctx, task)
+	}
+	return nil
+}
+
+func (m *mockTaskPublisher) PublishBatchTask(ctx context.Context, task *rabbitmq.BatchHeightmapTask) error {
+	if m.publishBatchTaskFunc != nil {
+		return m.publishBatchTaskFunc(ctx, task)
+	}
+	return nil
+}
+
+type mockPresigner struct {
+	requests []string
+}
+
+func (m *mockPresigner) PresignObject(ctx context.Context, method, bucket, objectName string, expiry time.Duration) (string, error) {
+	m.requests = append(m.requests, method+" "+bucket+"/"+objectName)
+	return fmt.Sprintf("http://minio:9000/%s/%s?method=%s&expires=%d", bucket, objectName, method, int(expiry.Seconds())), nil
+}
+
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error { return nil }
+
+func TestOutboxRelayBatch(t *testing.T) {
+	cfg := &config.Config{
+		Minio: config.MinioConfig{
+			Endpoint:            "minio:9000",
+			AccessKeyID:         "access",
+			SecretAccessKey:     "secret",
+			PublicURL:           "http://localhost:9000",
+			UAVModelsBucketName: "uav-models",
+			TaskURLExpiry:       time.Hour,
+		},
+		Outbox: config.OutboxConfig{BatchSize: 10, MaxAttempts: 5, BaseBackoff: time.Second, MaxBackoff: time.Minute},
+	}
+
+	single, _ := newOutboxMessage(JobTypeHeightmap, uuid.New(), OutboxMessageHeightmapTask, &rabbitmq.HeightmapTask{
+		JobID:    "job-1",
+		ImageURL: "http://localhost:9000/uav-data/heightmaps/user/job-1.jpg",
+	})
+	batch, _ := newOutboxMessage(JobTypeBatch, uuid.New(), OutboxMessageBatchTask, &rabbitmq.BatchHeightmapTask{BatchJobID: "batch-1"})
+
+	queries := &mockOutboxQueries{
+		messages: []sqlc.OutboxMessage{
+			{ID: uuid.New(), MessageType: single.MessageType, Payload: single.Payload},
+			{ID: uuid.New(), MessageType: batch.MessageType, Payload: batch.Payload},
+			{ID: uuid.New(), MessageType: "unknown", Payload: []byte(`{}`)},
+		},
+	}
+
+	var published *rabbitmq.HeightmapTask
+	publisher := &mockTaskPublisher{
+		publishTaskFunc: func(ctx context.Context, task *rabbitmq.HeightmapTask) error {
+			published = task
+			return nil
+		},
+		publishBatchTaskFunc: func(ctx context.Context, task *rabbitmq.BatchHeightmapTask) error {
+			return errors.New("channel closed")
+		},
+	}
+
+	r := &OutboxRelay{publisher: publisher, presigner: &mockPresigner{}, cfg: cfg, logger: nopLogger{}}
+
+	if err := r.relayBatch(context.Background(), queries); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if published == nil || published.JobID != "job-1" {
+		t.Fatalf("expected heightmap task to be published, got %+v", published)
+	}
+	if published.SchemaVersion != rabbitmq.TaskSchemaVersion {
+		t.Errorf("expected schema version %d, got %d", rabbitmq.TaskSchemaVersion, published.SchemaVersion)
+	}
+	if published.Input.Bucket != "uav-data" || published.Input.Object != "heightmaps/user/job-1.jpg" || published.Input.GetURL == "" || published.Input.DeleteURL == "" {
+		t.Errorf("expected presigned input, got %+v", published.Input)
+	}
+	if published.Output.URL != "http://localhost:9000/uav-models/heightmaps/job-1_heightmap.png" || published.Output.PutURL == "" {
+		t.Errorf("expected presigned output, got %+v", published.Output)
+	}
+
+	body, _ := json.Marshal(published)
+	if bytes.Contains(body, []byte("access")) || bytes.Contains(body, []byte("secret")) {
+		t.Errorf("task must not carry MinIO credentials: %s", body)
+	}
+
+	if len(queries.sent) != 1 || queries.sent[0] != queries.messages[0].ID {
+		t.Errorf("expected only the heightmap task to be marked sent, got %v", queries.sent)
+	}
+	if len(queries.failed) != 2 {
+		t.Fatalf("expected 2 failed messages, got %d", len(queries.failed))
+	}
+	for _, failed := range queries.failed {
+		if failed.LastError == nil || *failed.LastError == "" {
+			t.Errorf("expected last error to be stored for %s", failed.ID)
+		}
+		if failed.Status != OutboxStatusPending || !failed.NextAttemptAt.After(time.Now()) {
+			t.Errorf("expected %s to be retried later, got %+v", failed.ID, failed)
+		}
+	}
+}
+
+func TestOutboxRelayGivesUp(t *testing.T) {
+	cfg := &config.Config{
+		Outbox: config.OutboxConfig{BatchSize: 10, MaxAttempts: 3, BaseBackoff: time.Second, MaxBackoff: time.Minute},
+	}
+
+	tests := []struct {
+		name           string
+		attempts       int32
+		expectedStatus string
+		expectedDelay  time.Duration
+	}{
+		{name: "first failure", attempts: 0, expectedStatus: OutboxStatusPending, expectedDelay: time.Second},
+		{name: "backoff doubles", attempts: 1, expectedStatus: OutboxStatusPending, expectedDelay: 2 * time.Second},
+		{name: "last attempt", attempts: 2, expectedStatus: OutboxStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := &mockOutboxQueries{
+				messages: []sqlc.OutboxMessage{{ID: uuid.New(), MessageType: "unknown", Payload: []byte(`{}`), Attempts: tt.attempts}},
+			}
+			r := &OutboxRelay{publisher: &mockTaskPublisher{}, presigner: &mockPresigner{}, cfg: cfg, logger: nopLogger{}}
+
+			start := time.Now()
+			if err := r.relayBatch(context.Background(), queries); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(queries.failed) != 1 {
+				t.Fatalf("expected the message to be rescheduled, got %v", queries.failed)
+			}
+			failed := queries.failed[0]
+			if failed.Status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q", tt.expectedStatus, failed.Status)
+			}
+			if tt.expectedStatus == OutboxStatusPending {
+				delay := failed.NextAttemptAt.Sub(start)
+				if delay < tt.expectedDelay || delay > tt.expectedDelay+time.Second {
+					t.Errorf("expected a retry after %v, got %v", tt.expectedDelay, delay)
+				}
+			}
+		})
+	}
+}
+
+func TestAttachBatchObjects(t *testing.T) {
+	cfg := &config.Config{Minio: config.MinioConfig{
+		PublicURL:                "http://localhost:9000",
+		UAVModelsBucketName:      "uav-models",
+		UAVPhotoplanesBucketName: "uav-photoplanes",
+		TaskURLExpiry:            time.Hour,
+	}}
+
+	tests := []struct {
+		name           string
+		mode           string
+		imageURL       string
+		wantHeightmap  bool
+		wantOrthophoto bool
+		wantErr        bool
+	}{
+		{name: "heightmap only", mode: "heightmap", imageURL: "http://localhost:9000/uav-data/batch/image_0.jpg", wantHeightmap: true},
+		{name: "orthophoto only", mode: "orthophoto", imageURL: "http://localhost:9000/uav-data/batch/image_0.jpg", wantOrthophoto: true},
+		{name: "both", mode: "both", imageURL: "http://localhost:9000/uav-data/batch/image_0.jpg", wantHeightmap: true, wantOrthophoto: true},
+		{name: "image outside the public URL", mode: "heightmap", imageURL: "http://elsewhere/uav-data/image_0.jpg", wantErr: true},
+		{name: "image without key", mode: "heightmap", imageURL: "http://localhost:9000/uav-data", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &OutboxRelay{presigner: &mockPresigner{}, cfg: cfg}
+			task := &rabbitmq.BatchHeightmapTask{BatchJobID: "batch-1", ImageURLs: []string{tt.imageURL}, GenerationMode: tt.mode}
+
+			err := r.attachBatchObjects(context.Background(), task)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(task.Inputs) != 1 || task.Inputs[0].Object != "batch/image_0.jpg" {
+				t.Errorf("unexpected inputs %+v", task.Inputs)
+			}
+			if (task.Heightmap != nil) != tt.wantHeightmap {
+				t.Errorf("expected heightmap output=%v, got %+v", tt.wantHeightmap, task.Heightmap)
+			}
+			if (task.Orthophoto != nil) != tt.wantOrthophoto {
+				t.Errorf("expected orthophoto output=%v, got %+v", tt.wantOrthophoto, task.Orthophoto)
+			}
+			if task.Orthophoto != nil && task.Orthophoto.Bucket != "uav-photoplanes" {
+				t.Errorf("expected orthophoto in the photoplanes bucket, got %q", task.Orthophoto.Bucket)
+			}
+		})
+	}
+}
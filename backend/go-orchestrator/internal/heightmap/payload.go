@@ -1,14 +1,26 @@
 package heightmap
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type UploadRequest struct {
 	Name string `json:"name" form:"name" binding:"required"`
 }
 
 type UploadResponse struct {
-	ID     uuid.UUID `json:"id"`
-	Status string    `json:"status"`
+	ID             uuid.UUID  `json:"id"`
+	Status         string     `json:"status"`
+	Priority       string     `json:"priority"`
+	ScheduledAt    *time.Time `json:"scheduled_at,omitempty"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	ProjectID      *uuid.UUID `json:"project_id,omitempty"`
+}
+
+type ScheduleRequest struct {
+	ScheduledAt time.Time `json:"scheduled_at" binding:"required"`
 }
 
 type HeightRequest struct {
@@ -30,25 +42,47 @@ type ProfileResponse struct {
 }
 
 type BatchUploadResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Status      string    `json:"status"`
-	ImageCount  int       `json:"image_count"`
-	MergeMethod string    `json:"merge_method"`
+	ID             uuid.UUID  `json:"id"`
+	Status         string     `json:"status"`
+	ImageCount     int        `json:"image_count"`
+	MergeMethod    string     `json:"merge_method"`
+	Priority       string     `json:"priority"`
+	ScheduledAt    *time.Time `json:"scheduled_at,omitempty"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	ProjectID      *uuid.UUID `json:"project_id,omitempty"`
 }
 
 type BatchHeightmapJob struct {
-	ID             uuid.UUID `json:"id"`
-	UserID         uuid.UUID `json:"user_id"`
-	Status         string    `json:"status"`
-	ResultURL      *string   `json:"result_url,omitempty"`
-	OrthophotoURL  *string   `json:"orthophoto_url,omitempty"`
-	Width          *int32    `json:"width,omitempty"`
-	Height         *int32    `json:"height,omitempty"`
-	ImageCount     int32     `json:"image_count"`
-	ProcessedCount int32     `json:"processed_count"`
-	ErrorMessage   *string   `json:"error_message,omitempty"`
-	ProcessingTime *float64  `json:"processing_time,omitempty"`
-	MergeMethod    string    `json:"merge_method"`
-	CreatedAt      string    `json:"created_at"`
-	UpdatedAt      string    `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	Status         string     `json:"status"`
+	ResultURL      *string    `json:"result_url,omitempty"`
+	OrthophotoURL  *string    `json:"orthophoto_url,omitempty"`
+	Width          *int32     `json:"width,omitempty"`
+	Height         *int32     `json:"height,omitempty"`
+	ImageCount     int32      `json:"image_count"`
+	ProcessedCount int32      `json:"processed_count"`
+	ErrorMessage   *string    `json:"error_message,omitempty"`
+	ProcessingTime *float64   `json:"processing_time,omitempty"`
+	MergeMethod    string     `json:"merge_method"`
+	Priority       string     `json:"priority"`
+	QueuePosition  *int64     `json:"queue_position,omitempty"`
+	ScheduledAt    *string    `json:"scheduled_at,omitempty"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	ProjectID      *uuid.UUID `json:"project_id,omitempty"`
+	CreatedAt      string     `json:"created_at"`
+	UpdatedAt      string     `json:"updated_at"`
+}
+
+// CreateShareRequest creates a public link to a job result. Without
+// ExpiresAt the link lives SHARE_DEFAULT_TTL; an empty Password leaves it
+// open to anyone with the link.
+type CreateShareRequest struct {
+	ExpiresAt     *time.Time `json:"expires_at"`
+	Password      string     `json:"password" binding:"omitempty,min=4,max=72"`
+	AllowDownload bool       `json:"allow_download"`
+}
+
+type SetQuotaPlanRequest struct {
+	Plan string `json:"plan" binding:"required"`
 }
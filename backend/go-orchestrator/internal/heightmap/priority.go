@@ -0,0 +1,84 @@
+package heightmap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+// Priority classes accepted on upload. The numeric values are RabbitMQ message
+// priorities and must stay below RABBITMQ_MAX_PRIORITY.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+var (
+	ErrUnknownPriority    = errors.New("неизвестный класс приоритета")
+	ErrPriorityNotAllowed = errors.New("приоритет недоступен для вашей роли")
+)
+
+var priorityValues = map[string]int32{
+	PriorityLow:    1,
+	PriorityNormal: 4,
+	PriorityHigh:   7,
+	PriorityUrgent: 9,
+}
+
+// maxRolePriority is the highest class each role may request. Roles not
+// listed are limited to normal.
+var maxRolePriority = map[string]string{
+	"admin":    PriorityUrgent,
+	"operator": PriorityHigh,
+}
+
+// ResolvePriority validates the requested class against the caller's role and
+// returns the numeric priority stored on the job. An empty class means normal.
+// The quota plan of the uploader caps the priority as well; that is checked
+// with the other plan limits in checkQuota.
+func ResolvePriority(class, role string) (int32, error) {
+	if class == "" {
+		class = PriorityNormal
+	}
+
+	value, ok := priorityValues[class]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s (разрешены: low, normal, high, urgent)", ErrUnknownPriority, class)
+	}
+
+	limit, ok := maxRolePriority[role]
+	if !ok {
+		limit = PriorityNormal
+	}
+	if value > priorityValues[limit] {
+		return 0, fmt.Errorf("%w: максимум %s", ErrPriorityNotAllowed, limit)
+	}
+
+	return value, nil
+}
+
+// maxPlanPriority is the highest class jobs of the plan may request. A plan
+// without a known class is limited to normal, like a role.
+func maxPlanPriority(plan sqlc.QuotaPlan) string {
+	if _, ok := priorityValues[plan.MaxPriority]; !ok {
+		return PriorityNormal
+	}
+	return plan.MaxPriority
+}
+
+// priorityClass maps a stored priority back to the highest class it reaches.
+func priorityClass(value int32) string {
+	switch {
+	case value >= priorityValues[PriorityUrgent]:
+		return PriorityUrgent
+	case value >= priorityValues[PriorityHigh]:
+		return PriorityHigh
+	case value >= priorityValues[PriorityNormal]:
+		return PriorityNormal
+	default:
+		return PriorityLow
+	}
+}
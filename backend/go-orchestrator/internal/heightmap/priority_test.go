@@ -0,0 +1,47 @@
+package heightmap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolvePriority(t *testing.T) {
+	tests := []struct {
+		name        string
+		class       string
+		role        string
+		expected    int32
+		expectedErr error
+	}{
+		{name: "default is normal", role: "user", expected: priorityValues[PriorityNormal]},
+		{name: "user may lower priority", class: PriorityLow, role: "user", expected: priorityValues[PriorityLow]},
+		{name: "user may not raise priority", class: PriorityHigh, role: "user", expectedErr: ErrPriorityNotAllowed},
+		{name: "operator may use high", class: PriorityHigh, role: "operator", expected: priorityValues[PriorityHigh]},
+		{name: "operator may not use urgent", class: PriorityUrgent, role: "operator", expectedErr: ErrPriorityNotAllowed},
+		{name: "admin may use urgent", class: PriorityUrgent, role: "admin", expected: priorityValues[PriorityUrgent]},
+		{name: "unknown role is limited to normal", class: PriorityHigh, role: "", expectedErr: ErrPriorityNotAllowed},
+		{name: "unknown class", class: "asap", role: "admin", expectedErr: ErrUnknownPriority},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priority, err := ResolvePriority(tt.class, tt.role)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if priority != tt.expected {
+				t.Errorf("expected priority %d, got %d", tt.expected, priority)
+			}
+			if priorityClass(priority) != tt.class && tt.class != "" {
+				t.Errorf("priority %d does not map back to %q", priority, tt.class)
+			}
+		})
+	}
+}
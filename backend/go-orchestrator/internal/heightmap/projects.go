@@ -0,0 +1,100 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+var (
+	// ErrProjectNotFound rejects uploads to and listings of a project the
+	// user cannot see.
+	ErrProjectNotFound             = errors.New("проект не найден")
+	ErrProjectOrganizationMismatch = errors.New("проект принадлежит другой организации")
+)
+
+// projectOrganization checks that the user sees the project and returns the
+// organization the job goes to: the one of the project, which the upload
+// may omit but not contradict. Without a project it returns organizationID.
+func (s *Service) projectOrganization(ctx context.Context, projectID, organizationID *uuid.UUID, userID uuid.UUID) (*uuid.UUID, error) {
+	if projectID == nil {
+		return organizationID, nil
+	}
+
+	project, err := s.accessibleProject(ctx, *projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if organizationID == nil {
+		return project.OrganizationID, nil
+	}
+	if project.OrganizationID == nil || *project.OrganizationID != *organizationID {
+		return nil, ErrProjectOrganizationMismatch
+	}
+	return organizationID, nil
+}
+
+func (s *Service) accessibleProject(ctx context.Context, projectID, userID uuid.UUID) (sqlc.Project, error) {
+	project, err := s.queries.GetAccessibleProject(ctx, sqlc.GetAccessibleProjectParams{
+		ID:     projectID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.Project{}, ErrProjectNotFound
+		}
+		return sqlc.Project{}, fmt.Errorf("не удалось получить проект: %w", err)
+	}
+	return project, nil
+}
+
+// ListProjectHeightmaps lists the jobs of the project, whoever uploaded them.
+func (s *Service) ListProjectHeightmaps(ctx context.Context, projectID, userID uuid.UUID, limit, offset int32) ([]*HeightmapJob, error) {
+	if _, err := s.accessibleProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+
+	jobs, err := s.queries.ListProjectHeightmaps(ctx, sqlc.ListProjectHeightmapsParams{
+		ProjectID: &projectID,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список карт высот: %w", err)
+	}
+
+	result := make([]*HeightmapJob, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, newHeightmapJob(job))
+	}
+
+	return result, nil
+}
+
+// ListProjectBatchHeightmaps is ListProjectHeightmaps for batch jobs.
+func (s *Service) ListProjectBatchHeightmaps(ctx context.Context, projectID, userID uuid.UUID, limit, offset int32) ([]*BatchHeightmapJob, error) {
+	if _, err := s.accessibleProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+
+	jobs, err := s.queries.ListProjectBatchHeightmaps(ctx, sqlc.ListProjectBatchHeightmapsParams{
+		ProjectID: &projectID,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список пакетных карт высот: %w", err)
+	}
+
+	result := make([]*BatchHeightmapJob, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, newBatchHeightmapJob(job))
+	}
+
+	return result, nil
+}
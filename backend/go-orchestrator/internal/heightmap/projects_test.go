@@ -0,0 +1,91 @@
+package heightmap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+func TestUploadPhotoToProject(t *testing.T) {
+	projectOrg := uuid.New()
+	otherOrg := uuid.New()
+
+	tests := []struct {
+		name            string
+		projectOrg      *uuid.UUID
+		organizationID  *uuid.UUID
+		expectedOrg     *uuid.UUID
+		expectedErr     error
+		projectNotFound bool
+	}{
+		{name: "personal project"},
+		{name: "inherits the organization of the project", projectOrg: &projectOrg, expectedOrg: &projectOrg},
+		{name: "same organization", projectOrg: &projectOrg, organizationID: &projectOrg, expectedOrg: &projectOrg},
+		{name: "other organization", projectOrg: &projectOrg, organizationID: &otherOrg, expectedErr: ErrProjectOrganizationMismatch},
+		{name: "personal project in an organization", organizationID: &otherOrg, expectedErr: ErrProjectOrganizationMismatch},
+		{name: "hidden project", projectNotFound: true, expectedErr: ErrProjectNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectID := uuid.New()
+			var created *sqlc.CreateHeightmapJobParams
+			queries := &mockQueries{
+				memberRoleFunc: func(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error) {
+					return "member", nil
+				},
+				createJobFunc: func(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error) {
+					created = &params
+					return sqlc.HeightmapJob{ID: params.ID}, nil
+				},
+			}
+			if !tt.projectNotFound {
+				queries.getProjectFunc = func(ctx context.Context, params sqlc.GetAccessibleProjectParams) (sqlc.Project, error) {
+					return sqlc.Project{ID: params.ID, OrganizationID: tt.projectOrg}, nil
+				}
+			}
+			s := newTestScheduleService(queries)
+
+			file := memoryFile{bytes.NewReader([]byte("image"))}
+			resp, err := s.UploadPhoto(context.Background(), uuid.New(), tt.organizationID, &projectID, file, &multipart.FileHeader{Filename: "photo.jpg", Size: 5}, priorityValues[PriorityNormal], nil)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if created != nil {
+					t.Error("no job must be created")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if created.ProjectID == nil || *created.ProjectID != projectID {
+				t.Errorf("expected the job in project %s, got %v", projectID, created.ProjectID)
+			}
+			if (created.OrganizationID == nil) != (tt.expectedOrg == nil) || (tt.expectedOrg != nil && *created.OrganizationID != *tt.expectedOrg) {
+				t.Errorf("expected organization %v, got %v", tt.expectedOrg, created.OrganizationID)
+			}
+			if resp.ProjectID == nil || *resp.ProjectID != projectID {
+				t.Errorf("expected project in response, got %+v", resp)
+			}
+		})
+	}
+}
+
+func TestListProjectHeightmapsRequiresAccess(t *testing.T) {
+	s := newTestScheduleService(&mockQueries{})
+
+	if _, err := s.ListProjectHeightmaps(context.Background(), uuid.New(), uuid.New(), 20, 0); !errors.Is(err, ErrProjectNotFound) {
+		t.Fatalf("expected %v, got %v", ErrProjectNotFound, err)
+	}
+	if _, err := s.ListProjectBatchHeightmaps(context.Background(), uuid.New(), uuid.New(), 20, 0); !errors.Is(err, ErrProjectNotFound) {
+		t.Fatalf("expected %v, got %v", ErrProjectNotFound, err)
+	}
+}
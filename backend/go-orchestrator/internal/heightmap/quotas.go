@@ -0,0 +1,254 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+// Codes returned next to quota errors, so clients can tell the limits apart
+// without parsing the message.
+const (
+	QuotaCodeStorage           = "storage_quota_exceeded"
+	QuotaCodeBatchSize         = "batch_too_large"
+	QuotaCodeDailyJobs         = "daily_job_quota_exceeded"
+	QuotaCodeConcurrentBatches = "concurrent_batch_quota_exceeded"
+	QuotaCodePriority          = "priority_not_in_plan"
+)
+
+var (
+	ErrStorageQuotaExceeded         = errors.New("превышен объем хранилища вашего тарифа")
+	ErrBatchTooLarge                = errors.New("слишком много файлов в пакете для вашего тарифа")
+	ErrDailyJobQuotaExceeded        = errors.New("превышен дневной лимит задач вашего тарифа")
+	ErrConcurrentBatchQuotaExceeded = errors.New("превышен лимит одновременных пакетных задач вашего тарифа")
+	ErrPriorityNotInPlan            = errors.New("приоритет недоступен на вашем тарифе")
+	ErrQuotaPlanNotFound            = errors.New("тариф не найден")
+	ErrUserNotFound                 = errors.New("пользователь не найден")
+)
+
+// upload describes what an upload adds to the usage of its user.
+type upload struct {
+	images   int
+	bytes    int64
+	batch    bool
+	priority int32
+}
+
+// checkQuota rejects an upload that would exceed the plan of the user. An
+// upload is checked twice: before the files reach MinIO, so most rejected
+// uploads use no storage, and by reserveQuota in the transaction that records
+// the job.
+func (s *Service) checkQuota(ctx context.Context, q QueriesInterface, userID uuid.UUID, u upload) error {
+	plan, err := userQuotaPlan(ctx, q, userID)
+	if err != nil {
+		return err
+	}
+
+	if limit := maxPlanPriority(plan); u.priority > priorityValues[limit] {
+		return fmt.Errorf("%w: максимум %s", ErrPriorityNotInPlan, limit)
+	}
+	if u.batch && plan.MaxImagesPerBatch > 0 && u.images > int(plan.MaxImagesPerBatch) {
+		return fmt.Errorf("%w: максимум %d", ErrBatchTooLarge, plan.MaxImagesPerBatch)
+	}
+
+	usage, err := q.GetUserUsage(ctx, sqlc.GetUserUsageParams{
+		UserID: userID,
+		Since:  quotaDayStart(time.Now()),
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось получить использование квот: %w", err)
+	}
+
+	if plan.MaxStorageBytes > 0 && usage.StorageBytes+u.bytes > plan.MaxStorageBytes {
+		return fmt.Errorf("%w: использовано %d из %d байт", ErrStorageQuotaExceeded, usage.StorageBytes, plan.MaxStorageBytes)
+	}
+	if plan.MaxJobsPerDay > 0 && usage.JobsSince >= int64(plan.MaxJobsPerDay) {
+		return fmt.Errorf("%w: максимум %d", ErrDailyJobQuotaExceeded, plan.MaxJobsPerDay)
+	}
+	if u.batch && plan.MaxConcurrentBatches > 0 && usage.ActiveBatches >= int64(plan.MaxConcurrentBatches) {
+		return fmt.Errorf("%w: максимум %d", ErrConcurrentBatchQuotaExceeded, plan.MaxConcurrentBatches)
+	}
+
+	return nil
+}
+
+// reserveQuota checks the upload again under a per-user advisory lock held
+// until the transaction ends, so the job and its storage usage are inserted
+// before a concurrent upload of the same user can read the usage.
+func (s *Service) reserveQuota(ctx context.Context, q QueriesInterface, userID uuid.UUID, u upload) error {
+	if err := q.LockUserQuota(ctx, userID); err != nil {
+		return fmt.Errorf("не удалось заблокировать квоту: %w", err)
+	}
+	return s.checkQuota(ctx, q, userID, u)
+}
+
+// GetUsage returns the plan of the user together with what they have used.
+func (s *Service) GetUsage(ctx context.Context, userID uuid.UUID) (*Usage, error) {
+	plan, err := userQuotaPlan(ctx, s.queries, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	dayStart := quotaDayStart(time.Now())
+	usage, err := s.queries.GetUserUsage(ctx, sqlc.GetUserUsageParams{
+		UserID: userID,
+		Since:  dayStart,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить использование квот: %w", err)
+	}
+
+	return &Usage{
+		Plan:          newQuotaPlan(plan),
+		StorageBytes:  usage.StorageBytes,
+		JobsToday:     usage.JobsSince,
+		ActiveBatches: usage.ActiveBatches,
+		JobsResetAt:   dayStart.Add(24 * time.Hour),
+	}, nil
+}
+
+func (s *Service) ListQuotaPlans(ctx context.Context) ([]*QuotaPlan, error) {
+	plans, err := s.queries.ListQuotaPlans(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список тарифов: %w", err)
+	}
+
+	result := make([]*QuotaPlan, 0, len(plans))
+	for _, plan := range plans {
+		result = append(result, newQuotaPlan(plan))
+	}
+	return result, nil
+}
+
+// SetUserQuotaPlan moves the user to another plan. The new limits apply to the
+// next upload; nothing already stored is removed.
+func (s *Service) SetUserQuotaPlan(ctx context.Context, userID uuid.UUID, name string) (*QuotaPlan, error) {
+	plan, err := s.queries.GetQuotaPlan(ctx, name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrQuotaPlanNotFound
+		}
+		return nil, fmt.Errorf("не удалось получить тариф: %w", err)
+	}
+
+	updated, err := s.queries.SetUserQuotaPlan(ctx, sqlc.SetUserQuotaPlanParams{
+		UserID:    userID,
+		Plan:      plan.Name,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось изменить тариф: %w", err)
+	}
+	if updated == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	return newQuotaPlan(plan), nil
+}
+
+// userQuotaPlan returns the assigned plan of the user, or the default plan.
+func userQuotaPlan(ctx context.Context, q QueriesInterface, userID uuid.UUID) (sqlc.QuotaPlan, error) {
+	plan, err := q.GetUserQuotaPlan(ctx, userID)
+	if err != nil {
+		return sqlc.QuotaPlan{}, fmt.Errorf("не удалось получить тариф: %w", err)
+	}
+	return plan, nil
+}
+
+// recordStorageUsage adds the stored bytes of a job to the usage of its
+// uploader: the upload until the job completes, then its results, see
+// chargeResults. The row goes away with the job, which frees the storage again.
+func recordStorageUsage(ctx context.Context, q QueriesInterface, userID uuid.UUID, jobType string, jobID uuid.UUID, bytes int64) error {
+	params := sqlc.CreateStorageUsageParams{
+		UserID: userID,
+		Bytes:  bytes,
+	}
+	if jobType == JobTypeHeightmap {
+		params.HeightmapJobID = &jobID
+	} else {
+		params.BatchJobID = &jobID
+	}
+
+	if err := q.CreateStorageUsage(ctx, params); err != nil {
+		return fmt.Errorf("не удалось учесть объем хранилища: %w", err)
+	}
+	return nil
+}
+
+// chargeResults moves the storage usage of a completed job from its upload to
+// its results. Workers delete the upload once the result is confirmed, so from
+// then on only the results take space. The upload is also handed to the
+// StorageCleaner, in case the worker could not delete it.
+func (s *Service) chargeResults(ctx context.Context, q QueriesInterface, jobType string, jobID uuid.UUID, event *rabbitmq.JobResultEvent) error {
+	bytes, err := s.resultBytes(ctx, event.ResultURL, event.OrthophotoURL)
+	if err != nil {
+		return err
+	}
+
+	var userID uuid.UUID
+	var inputPrefix string
+	switch jobType {
+	case JobTypeHeightmap:
+		job, err := q.GetHeightmapJob(ctx, jobID)
+		if err != nil {
+			return fmt.Errorf("не удалось получить задачу %s: %w", jobID, err)
+		}
+		userID, inputPrefix = job.UserID, heightmapInputPrefix(job.UserID, jobID)
+		err = q.DeleteHeightmapJobStorageUsage(ctx, &jobID)
+	default:
+		job, err := q.GetBatchHeightmapJob(ctx, jobID)
+		if err != nil {
+			return fmt.Errorf("не удалось получить пакетную задачу %s: %w", jobID, err)
+		}
+		userID, inputPrefix = job.UserID, batchInputPrefix(job.UserID, jobID)
+		err = q.DeleteBatchJobStorageUsage(ctx, &jobID)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось освободить объем хранилища: %w", err)
+	}
+
+	if err := recordStorageUsage(ctx, q, userID, jobType, jobID, bytes); err != nil {
+		return err
+	}
+
+	if err := q.ScheduleJobStorageDeletion(ctx, sqlc.ScheduleJobStorageDeletionParams{
+		UserID:          userID,
+		HeightmapJobIds: []uuid.UUID{},
+		BatchJobIds:     []uuid.UUID{},
+		InputPrefixes:   []string{inputPrefix},
+	}); err != nil {
+		return fmt.Errorf("не удалось запланировать удаление загруженных файлов: %w", err)
+	}
+	return nil
+}
+
+// resultBytes sums the sizes of the stored result objects.
+func (s *Service) resultBytes(ctx context.Context, objectURLs ...*string) (int64, error) {
+	var total int64
+	for _, objectURL := range objectURLs {
+		if objectURL == nil || *objectURL == "" {
+			continue
+		}
+		bucket, object, err := splitObjectURL(s.cfg.Minio.PublicURL, *objectURL)
+		if err != nil {
+			return 0, err
+		}
+		info, err := s.minioClient.GetFileInfo(ctx, bucket, object)
+		if err != nil {
+			return 0, fmt.Errorf("не удалось получить размер результата %s: %w", *objectURL, err)
+		}
+		total += info.Size
+	}
+	return total, nil
+}
+
+// quotaDayStart is the start of the UTC day daily job limits count from.
+func quotaDayStart(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour)
+}
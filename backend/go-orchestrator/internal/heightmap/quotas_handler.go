@@ -0,0 +1,167 @@
+package heightmap
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+// QuotaHandler serves the usage of the current user and lets administrators
+// assign quota plans.
+type QuotaHandler struct {
+	service *Service
+}
+
+func NewQuotaHandler(service *Service) *QuotaHandler {
+	return &QuotaHandler{service: service}
+}
+
+func (h *QuotaHandler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMiddleware) {
+	me := r.Group("users/me")
+	me.Use(jwtMiddleware.RequireAuth())
+	{
+		me.GET("/usage", h.GetUsage)
+	}
+
+	plans := r.Group("admin/quota-plans")
+	plans.Use(jwtMiddleware.RequireAuth(), jwtMiddleware.AdminOnly())
+	{
+		plans.GET("", h.ListQuotaPlans)
+	}
+
+	users := r.Group("admin/users")
+	users.Use(jwtMiddleware.RequireAuth(), jwtMiddleware.AdminOnly())
+	{
+		users.PUT("/:id/quota-plan", h.SetUserQuotaPlan)
+	}
+}
+
+// @Summary Get Usage
+// @Description Quota plan of the current user and what has been used of it. Zero limits mean no limit
+// @Tags users
+// @Produce json
+// @Success 200 {object} Usage
+// @Failure 401 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/users/me/usage [get]
+func (h *QuotaHandler) GetUsage(c *gin.Context) {
+	userIDStr, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return
+	}
+
+	usage, err := h.service.GetUsage(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// @Summary List Quota Plans
+// @Description Admin only
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/quota-plans [get]
+func (h *QuotaHandler) ListQuotaPlans(c *gin.Context) {
+	plans, err := h.service.ListQuotaPlans(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// @Summary Set User Quota Plan
+// @Description Admin only. Move a user to another quota plan
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body SetQuotaPlanRequest true "Plan name"
+// @Success 200 {object} QuotaPlan
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/users/{id}/quota-plan [put]
+func (h *QuotaHandler) SetUserQuotaPlan(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return
+	}
+
+	var req SetQuotaPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	plan, err := h.service.SetUserQuotaPlan(c.Request.Context(), userID, req.Plan)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrQuotaPlanNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// respondQuotaError answers an upload rejected by the quota plan and reports
+// whether err was one. Storage and batch size limits are about the request
+// itself (413); job limits clear with time (429), and the daily one says
+// when with Retry-After. A priority above the plan is forbidden (403), like
+// one above the role.
+func respondQuotaError(c *gin.Context, err error) bool {
+	var (
+		status int
+		code   string
+	)
+	switch {
+	case errors.Is(err, ErrStorageQuotaExceeded):
+		status, code = http.StatusRequestEntityTooLarge, QuotaCodeStorage
+	case errors.Is(err, ErrBatchTooLarge):
+		status, code = http.StatusRequestEntityTooLarge, QuotaCodeBatchSize
+	case errors.Is(err, ErrDailyJobQuotaExceeded):
+		status, code = http.StatusTooManyRequests, QuotaCodeDailyJobs
+		now := time.Now()
+		retryAfter := quotaDayStart(now).Add(24 * time.Hour).Sub(now)
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	case errors.Is(err, ErrConcurrentBatchQuotaExceeded):
+		status, code = http.StatusTooManyRequests, QuotaCodeConcurrentBatches
+	case errors.Is(err, ErrPriorityNotInPlan):
+		status, code = http.StatusForbidden, QuotaCodePriority
+	default:
+		return false
+	}
+
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+		"code":  code,
+	})
+	return true
+}
@@ -0,0 +1,284 @@
+package heightmap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+var testQuotaPlan = sqlc.QuotaPlan{
+	Name:                 "default",
+	MaxStorageBytes:      1000,
+	MaxJobsPerDay:        10,
+	MaxConcurrentBatches: 2,
+	MaxImagesPerBatch:    3,
+	MaxPriority:          PriorityHigh,
+}
+
+func TestUploadPhotoQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		usage       sqlc.GetUserUsageRow
+		size        int64
+		priority    string
+		expectedErr error
+	}{
+		{name: "within limits", usage: sqlc.GetUserUsageRow{StorageBytes: 900, JobsSince: 9}, size: 100},
+		{name: "priority within the plan", size: 5, priority: PriorityHigh},
+		{name: "priority above the plan", size: 5, priority: PriorityUrgent, expectedErr: ErrPriorityNotInPlan},
+		{name: "storage full", usage: sqlc.GetUserUsageRow{StorageBytes: 900}, size: 101, expectedErr: ErrStorageQuotaExceeded},
+		{name: "daily jobs used up", usage: sqlc.GetUserUsageRow{JobsSince: 10}, size: 5, expectedErr: ErrDailyJobQuotaExceeded},
+		{name: "active batches do not limit single jobs", usage: sqlc.GetUserUsageRow{ActiveBatches: 2}, size: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			var recorded *sqlc.CreateStorageUsageParams
+			created := false
+			queries := &mockQueries{
+				getUserQuotaPlanFunc: func(ctx context.Context, id uuid.UUID) (sqlc.QuotaPlan, error) {
+					return testQuotaPlan, nil
+				},
+				getUserUsageFunc: func(ctx context.Context, params sqlc.GetUserUsageParams) (sqlc.GetUserUsageRow, error) {
+					if !params.Since.Equal(quotaDayStart(time.Now())) {
+						t.Errorf("expected jobs to be counted from the start of the UTC day, got %v", params.Since)
+					}
+					return tt.usage, nil
+				},
+				createJobFunc: func(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error) {
+					created = true
+					return sqlc.HeightmapJob{ID: params.ID}, nil
+				},
+				createStorageUsageFunc: func(ctx context.Context, params sqlc.CreateStorageUsageParams) error {
+					recorded = &params
+					return nil
+				},
+			}
+			s := newTestScheduleService(queries)
+			uploaded := false
+			s.minioClient = &mockMinioClient{
+				uploadFileFunc: func(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) error {
+					uploaded = true
+					return nil
+				},
+			}
+
+			priority := priorityValues[PriorityNormal]
+			if tt.priority != "" {
+				priority = priorityValues[tt.priority]
+			}
+
+			file := memoryFile{bytes.NewReader([]byte("image"))}
+			resp, err := s.UploadPhoto(context.Background(), userID, nil, nil, file, &multipart.FileHeader{Filename: "photo.jpg", Size: tt.size}, priority, nil)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if uploaded || created || recorded != nil {
+					t.Error("a rejected upload must not reach storage or the database")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if recorded == nil {
+				t.Fatal("expected the upload to be recorded in the storage usage")
+			}
+			if recorded.UserID != userID || recorded.Bytes != tt.size {
+				t.Errorf("unexpected usage record %+v", recorded)
+			}
+			if recorded.HeightmapJobID == nil || *recorded.HeightmapJobID != resp.ID || recorded.BatchJobID != nil {
+				t.Errorf("expected the usage to belong to job %s, got %+v", resp.ID, recorded)
+			}
+		})
+	}
+}
+
+func TestBatchUploadQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		images      int
+		usage       sqlc.GetUserUsageRow
+		expectedErr error
+	}{
+		{name: "too many images", images: 4, expectedErr: ErrBatchTooLarge},
+		{name: "storage full", images: 3, usage: sqlc.GetUserUsageRow{StorageBytes: 800}, expectedErr: ErrStorageQuotaExceeded},
+		{name: "daily jobs used up", images: 2, usage: sqlc.GetUserUsageRow{JobsSince: 10}, expectedErr: ErrDailyJobQuotaExceeded},
+		{name: "too many active batches", images: 2, usage: sqlc.GetUserUsageRow{ActiveBatches: 2}, expectedErr: ErrConcurrentBatchQuotaExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := &mockQueries{
+				getUserQuotaPlanFunc: func(ctx context.Context, id uuid.UUID) (sqlc.QuotaPlan, error) {
+					return testQuotaPlan, nil
+				},
+				getUserUsageFunc: func(ctx context.Context, params sqlc.GetUserUsageParams) (sqlc.GetUserUsageRow, error) {
+					return tt.usage, nil
+				},
+			}
+			s := newTestScheduleService(queries)
+
+			files := make([]*multipart.FileHeader, 0, tt.images)
+			for range tt.images {
+				files = append(files, &multipart.FileHeader{Filename: "photo.jpg", Size: 100})
+			}
+
+			_, err := s.BatchUploadPhotos(context.Background(), uuid.New(), nil, nil, files, "", false, "", priorityValues[PriorityNormal], nil)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestUploadQuotaRecheckedUnderLock(t *testing.T) {
+	userID := uuid.New()
+	var calls []string
+	queries := &mockQueries{
+		getUserQuotaPlanFunc: func(ctx context.Context, id uuid.UUID) (sqlc.QuotaPlan, error) {
+			return testQuotaPlan, nil
+		},
+		getUserUsageFunc: func(ctx context.Context, params sqlc.GetUserUsageParams) (sqlc.GetUserUsageRow, error) {
+			calls = append(calls, "usage")
+			// A concurrent upload of the user committed after the first check.
+			if len(calls) > 1 {
+				return sqlc.GetUserUsageRow{StorageBytes: 950}, nil
+			}
+			return sqlc.GetUserUsageRow{}, nil
+		},
+		lockUserQuotaFunc: func(ctx context.Context, id uuid.UUID) error {
+			if id != userID {
+				t.Errorf("expected the lock of user %s, got %s", userID, id)
+			}
+			calls = append(calls, "lock")
+			return nil
+		},
+		createJobFunc: func(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error) {
+			calls = append(calls, "create")
+			return sqlc.HeightmapJob{ID: params.ID}, nil
+		},
+	}
+	s := newTestScheduleService(queries)
+	var uploaded, removed []string
+	s.minioClient = &mockMinioClient{
+		uploadFileFunc: func(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) error {
+			uploaded = append(uploaded, objectName)
+			return nil
+		},
+		removeObjectFunc: func(ctx context.Context, bucket, objectName string) error {
+			removed = append(removed, objectName)
+			return nil
+		},
+	}
+
+	file := memoryFile{bytes.NewReader([]byte("image"))}
+	_, err := s.UploadPhoto(context.Background(), userID, nil, nil, file, &multipart.FileHeader{Filename: "photo.jpg", Size: 100}, priorityValues[PriorityNormal], nil)
+
+	if !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("expected %v, got %v", ErrStorageQuotaExceeded, err)
+	}
+	if len(calls) != 3 || calls[0] != "usage" || calls[1] != "lock" || calls[2] != "usage" {
+		t.Errorf("expected the usage to be read again under the lock and no job created, got %v", calls)
+	}
+	if len(uploaded) != 1 || len(removed) != 1 || removed[0] != uploaded[0] {
+		t.Errorf("expected the uploaded file to be removed, uploaded %v, removed %v", uploaded, removed)
+	}
+}
+
+func TestUnlimitedQuotaPlan(t *testing.T) {
+	queries := &mockQueries{
+		getUserUsageFunc: func(ctx context.Context, params sqlc.GetUserUsageParams) (sqlc.GetUserUsageRow, error) {
+			return sqlc.GetUserUsageRow{StorageBytes: 1 << 40, JobsSince: 1 << 20, ActiveBatches: 100}, nil
+		},
+	}
+	s := newTestScheduleService(queries)
+
+	if err := s.checkQuota(context.Background(), queries, uuid.New(), upload{images: 1000, bytes: 1 << 30, batch: true}); err != nil {
+		t.Fatalf("zero limits must not reject uploads, got %v", err)
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	queries := &mockQueries{
+		getUserQuotaPlanFunc: func(ctx context.Context, id uuid.UUID) (sqlc.QuotaPlan, error) {
+			return testQuotaPlan, nil
+		},
+		getUserUsageFunc: func(ctx context.Context, params sqlc.GetUserUsageParams) (sqlc.GetUserUsageRow, error) {
+			return sqlc.GetUserUsageRow{StorageBytes: 500, JobsSince: 4, ActiveBatches: 1}, nil
+		},
+	}
+	s := newTestScheduleService(queries)
+
+	usage, err := s.GetUsage(context.Background(), uuid.New())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usage.Plan.Name != "default" || usage.StorageBytes != 500 || usage.JobsToday != 4 || usage.ActiveBatches != 1 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+
+	now := time.Now().UTC()
+	if !usage.JobsResetAt.After(now) || usage.JobsResetAt.Sub(now) > 24*time.Hour {
+		t.Errorf("expected the daily limit to reset within a day, got %v", usage.JobsResetAt)
+	}
+	if hour, minute, sec := usage.JobsResetAt.Clock(); hour != 0 || minute != 0 || sec != 0 {
+		t.Errorf("expected the daily limit to reset at UTC midnight, got %v", usage.JobsResetAt)
+	}
+}
+
+func TestSetUserQuotaPlan(t *testing.T) {
+	tests := []struct {
+		name        string
+		plan        string
+		updated     int64
+		expectedErr error
+	}{
+		{name: "assigns plan", plan: "extended", updated: 1},
+		{name: "unknown plan", plan: "gold", expectedErr: ErrQuotaPlanNotFound},
+		{name: "unknown user", plan: "extended", updated: 0, expectedErr: ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := &mockQueries{
+				getQuotaPlanFunc: func(ctx context.Context, name string) (sqlc.QuotaPlan, error) {
+					if name != "extended" {
+						return sqlc.QuotaPlan{}, pgx.ErrNoRows
+					}
+					return sqlc.QuotaPlan{Name: name}, nil
+				},
+				setUserQuotaPlanFunc: func(ctx context.Context, params sqlc.SetUserQuotaPlanParams) (int64, error) {
+					return tt.updated, nil
+				},
+			}
+			s := newTestScheduleService(queries)
+
+			plan, err := s.SetUserQuotaPlan(context.Background(), uuid.New(), tt.plan)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if plan.Name != tt.plan {
+				t.Errorf("expected plan %q, got %q", tt.plan, plan.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package heightmap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/metrics"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+// reaperLockKey is the pg_try_advisory_xact_lock key that elects the single
+// replica running the reaper.
+const reaperLockKey int64 = 0x64326700_00000001
+
+// Reaper finds jobs that stayed in pending or processing longer than the
+// configured timeout, which happens when a task message is lost or a worker
+// dies mid-job. Such jobs are requeued through the outbox until MaxRequeues
+// is reached, then failed with an explanatory error message.
+type Reaper struct {
+	service *Service
+	cfg     config.ReaperConfig
+	logger  middleware.LoggerInterface
+}
+
+type reapPolicy struct {
+	jobType string
+	status  string
+	timeout time.Duration
+}
+
+type reapedJob struct {
+	jobType   string
+	jobID     uuid.UUID
+	eventType string
+}
+
+func NewReaper(service *Service, cfg config.ReaperConfig, logger middleware.LoggerInterface) *Reaper {
+	return &Reaper{
+		service: service,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reap(ctx)
+		}
+	}
+}
+
+func (r *Reaper) policies() []reapPolicy {
+	return []reapPolicy{
+		{jobType: JobTypeHeightmap, status: "pending", timeout: r.cfg.PendingTimeout},
+		{jobType: JobTypeHeightmap, status: "processing", timeout: r.cfg.ProcessingTimeout},
+		{jobType: JobTypeBatch, status: "pending", timeout: r.cfg.PendingTimeout},
+		{jobType: JobTypeBatch, status: "processing", timeout: r.cfg.BatchProcessingTimeout},
+	}
+}
+
+func (r *Reaper) reap(ctx context.Context) {
+	var reaped []reapedJob
+	locked := false
+
+	err := r.service.withTx(ctx, func(q QueriesInterface) error {
+		acquired, err := q.TryAdvisoryXactLock(ctx, reaperLockKey)
+		if err != nil {
+			return fmt.Errorf("не удалось захватить блокировку очистки зависших задач: %w", err)
+		}
+		if !acquired {
+			return nil
+		}
+		locked = true
+
+		reaped, err = r.reapStale(ctx, q)
+		return err
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Error("Failed to reap stuck jobs", err)
+		}
+		return
+	}
+
+	if !locked {
+		metrics.ResetStuckJobs()
+		return
+	}
+
+	// Events and webhooks are emitted after commit so that subscribers never
+	// see a state that was rolled back.
+	for _, job := range reaped {
+		if job.eventType == rabbitmq.JobEventError {
+			metrics.RecordProcessingJobFailed()
+		}
+		if err := r.service.afterJobEvent(ctx, r.service.queries, job.jobType, job.jobID, job.eventType); err != nil {
+			r.logger.Error("Failed to publish reaped job event", err, map[string]interface{}{
+				"job_id":   job.jobID.String(),
+				"job_type": job.jobType,
+			})
+		}
+	}
+}
+
+func (r *Reaper) reapStale(ctx context.Context, q QueriesInterface) ([]reapedJob, error) {
+	now := time.Now()
+	var reaped []reapedJob
+
+	for _, policy := range r.policies() {
+		if policy.timeout <= 0 {
+			continue
+		}
+
+		staleBefore := now.Add(-policy.timeout)
+
+		switch policy.jobType {
+		case JobTypeHeightmap:
+			jobs, err := q.ListStaleHeightmapJobs(ctx, sqlc.ListStaleHeightmapJobsParams{
+				Status:      policy.status,
+				StaleBefore: staleBefore,
+				BatchSize:   int32(r.cfg.BatchSize),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("не удалось получить зависшие задачи: %w", err)
+			}
+			stuck, err := q.CountStaleHeightmapJobs(ctx, sqlc.CountStaleHeightmapJobsParams{
+				Status:      policy.status,
+				StaleBefore: staleBefore,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("не удалось посчитать зависшие задачи: %w", err)
+			}
+			metrics.SetStuckJobs(policy.jobType, policy.status, int(stuck))
+
+			for _, job := range jobs {
+				eventType, err := r.reapHeightmapJob(ctx, q, job, policy, now)
+				if err != nil {
+					return nil, err
+				}
+				reaped = append(reaped, reapedJob{jobType: policy.jobType, jobID: job.ID, eventType: eventType})
+			}
+
+		case JobTypeBatch:
+			jobs, err := q.ListStaleBatchHeightmapJobs(ctx, sqlc.ListStaleBatchHeightmapJobsParams{
+				Status:      policy.status,
+				StaleBefore: staleBefore,
+				BatchSize:   int32(r.cfg.BatchSize),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("не удалось получить зависшие пакетные задачи: %w", err)
+			}
+			stuck, err := q.CountStaleBatchHeightmapJobs(ctx, sqlc.CountStaleBatchHeightmapJobsParams{
+				Status:      policy.status,
+				StaleBefore: staleBefore,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("не удалось посчитать зависшие пакетные задачи: %w", err)
+			}
+			metrics.SetStuckJobs(policy.jobType, policy.status, int(stuck))
+
+			for _, job := range jobs {
+				eventType, err := r.reapBatchJob(ctx, q, job, policy, now)
+				if err != nil {
+					return nil, err
+				}
+				reaped = append(reaped, reapedJob{jobType: policy.jobType, jobID: job.ID, eventType: eventType})
+			}
+		}
+	}
+
+	return reaped, nil
+}
+
+func (r *Reaper) reapHeightmapJob(ctx context.Context, q QueriesInterface, job sqlc.HeightmapJob, policy reapPolicy, now time.Time) (string, error) {
+	if int(job.RequeueCount) >= r.cfg.MaxRequeues {
+		errMsg := stuckJobError(policy, job.RequeueCount)
+		if err := q.UpdateJobError(ctx, sqlc.UpdateJobErrorParams{
+			ID:           job.ID,
+			ErrorMessage: &errMsg,
+			UpdatedAt:    now,
+		}); err != nil {
+			return "", fmt.Errorf("не удалось завершить с ошибкой зависшую задачу %s: %w", job.ID, err)
+		}
+		r.logStuck(policy, job.ID, job.RequeueCount, "failed")
+		return rabbitmq.JobEventError, nil
+	}
+
+	if err := r.service.requeueHeightmapJob(ctx, q, job, now); err != nil {
+		return "", err
+	}
+	r.logStuck(policy, job.ID, job.RequeueCount, "requeued")
+	return rabbitmq.JobEventStatus, nil
+}
+
+func (r *Reaper) reapBatchJob(ctx context.Context, q QueriesInterface, job sqlc.BatchHeightmapJob, policy reapPolicy, now time.Time) (string, error) {
+	if int(job.RequeueCount) >= r.cfg.MaxRequeues {
+		errMsg := stuckJobError(policy, job.RequeueCount)
+		if err := q.UpdateBatchJobError(ctx, sqlc.UpdateBatchJobErrorParams{
+			ID:           job.ID,
+			ErrorMessage: &errMsg,
+			UpdatedAt:    now,
+		}); err != nil {
+			return "", fmt.Errorf("не удалось завершить с ошибкой зависшую пакетную задачу %s: %w", job.ID, err)
+		}
+		r.logStuck(policy, job.ID, job.RequeueCount, "failed")
+		return rabbitmq.JobEventError, nil
+	}
+
+	if err := r.service.requeueBatchJob(ctx, q, job, now); err != nil {
+		return "", err
+	}
+	r.logStuck(policy, job.ID, job.RequeueCount, "requeued")
+	return rabbitmq.JobEventStatus, nil
+}
+
+func (r *Reaper) logStuck(policy reapPolicy, jobID uuid.UUID, requeues int32, action string) {
+	r.logger.Warn("Stuck job reaped", map[string]interface{}{
+		"job_id":   jobID.String(),
+		"job_type": policy.jobType,
+		"status":   policy.status,
+		"timeout":  policy.timeout.String(),
+		"requeues": requeues,
+		"action":   action,
+	})
+}
+
+func stuckJobError(policy reapPolicy, requeues int32) string {
+	return fmt.Sprintf("задача находилась в статусе %s дольше %s; повторных постановок в очередь: %d, лимит исчерпан",
+		policy.status, policy.timeout, requeues)
+}
@@ -0,0 +1,206 @@
+package heightmap
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+func newTestReaper(queries *mockQueries) *Reaper {
+	s := &Service{
+		queries: queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return fn(queries)
+		},
+		cfg: &config.Config{Minio: config.MinioConfig{UAVModelsBucketName: "uav-models"}},
+	}
+
+	return NewReaper(s, config.ReaperConfig{
+		PendingTimeout:         30 * time.Minute,
+		ProcessingTimeout:      time.Hour,
+		BatchProcessingTimeout: 6 * time.Hour,
+		MaxRequeues:            2,
+		BatchSize:              100,
+	}, nopLogger{})
+}
+
+func TestReaperSkipsWithoutLock(t *testing.T) {
+	listed := false
+	queries := &mockQueries{
+		tryAdvisoryXactLockFunc: func(ctx context.Context, key int64) (bool, error) {
+			return false, nil
+		},
+		listStaleJobsFunc: func(ctx context.Context, params sqlc.ListStaleHeightmapJobsParams) ([]sqlc.HeightmapJob, error) {
+			listed = true
+			return nil, nil
+		},
+	}
+
+	newTestReaper(queries).reap(context.Background())
+
+	if listed {
+		t.Error("reaper must not scan jobs without holding the advisory lock")
+	}
+}
+
+func TestReaperCountsAllStuckJobs(t *testing.T) {
+	listed := map[string]sqlc.ListStaleHeightmapJobsParams{}
+	counted := map[string]sqlc.CountStaleHeightmapJobsParams{}
+	queries := &mockQueries{
+		listStaleJobsFunc: func(ctx context.Context, params sqlc.ListStaleHeightmapJobsParams) ([]sqlc.HeightmapJob, error) {
+			listed[params.Status] = params
+			return nil, nil
+		},
+		countStaleJobsFunc: func(ctx context.Context, params sqlc.CountStaleHeightmapJobsParams) (int64, error) {
+			counted[params.Status] = params
+			return 250, nil
+		},
+	}
+
+	newTestReaper(queries).reap(context.Background())
+
+	if len(counted) == 0 || len(counted) != len(listed) {
+		t.Fatalf("expected every scanned status to be counted, listed %v, counted %v", listed, counted)
+	}
+	for status, list := range listed {
+		count, ok := counted[status]
+		if !ok || !count.StaleBefore.Equal(list.StaleBefore) {
+			t.Errorf("expected %q jobs to be counted with the scan predicate, got %+v", status, count)
+		}
+	}
+}
+
+func TestReaperHeightmapJobs(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        string
+		requeueCount  int32
+		expectRequeue bool
+		expectedEvent string
+	}{
+		{name: "pending job is requeued", status: "pending", expectRequeue: true, expectedEvent: rabbitmq.JobEventStatus},
+		{name: "processing job is requeued", status: "processing", requeueCount: 1, expectRequeue: true, expectedEvent: rabbitmq.JobEventStatus},
+		{name: "job is failed after max requeues", status: "processing", requeueCount: 2, expectedEvent: rabbitmq.JobEventError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := sqlc.HeightmapJob{
+				ID:           uuid.New(),
+				UserID:       uuid.New(),
+				ImageUrl:     "http://minio/uav-data/heightmaps/a.jpg",
+				Status:       tt.status,
+				RequeueCount: tt.requeueCount,
+			}
+
+			var requeued *sqlc.RequeueHeightmapJobParams
+			var failed *sqlc.UpdateJobErrorParams
+			var message *sqlc.CreateOutboxMessageParams
+			var event *sqlc.CreateJobEventParams
+
+			queries := &mockQueries{
+				listStaleJobsFunc: func(ctx context.Context, params sqlc.ListStaleHeightmapJobsParams) ([]sqlc.HeightmapJob, error) {
+					if params.Status != tt.status {
+						return nil, nil
+					}
+					return []sqlc.HeightmapJob{job}, nil
+				},
+				requeueJobFunc: func(ctx context.Context, params sqlc.RequeueHeightmapJobParams) error {
+					requeued = &params
+					return nil
+				},
+				updateJobErrorFunc: func(ctx context.Context, params sqlc.UpdateJobErrorParams) error {
+					failed = &params
+					return nil
+				},
+				createOutboxMessageFunc: func(ctx context.Context, params sqlc.CreateOutboxMessageParams) (sqlc.OutboxMessage, error) {
+					message = &params
+					return sqlc.OutboxMessage{}, nil
+				},
+				getJobFunc: func(ctx context.Context, id uuid.UUID) (sqlc.HeightmapJob, error) {
+					return job, nil
+				},
+				createJobEventFunc: func(ctx context.Context, params sqlc.CreateJobEventParams) (sqlc.JobEvent, error) {
+					event = &params
+					return sqlc.JobEvent{}, nil
+				},
+			}
+
+			newTestReaper(queries).reap(context.Background())
+
+			if tt.expectRequeue {
+				if requeued == nil || requeued.ID != job.ID {
+					t.Fatalf("expected job to be requeued, got %+v", requeued)
+				}
+				if message == nil || message.AggregateID.Bytes != job.ID || message.MessageType != OutboxMessageHeightmapTask {
+					t.Errorf("expected task to be written to the outbox, got %+v", message)
+				}
+				if failed != nil {
+					t.Error("requeued job must not be failed")
+				}
+			} else {
+				if failed == nil || failed.ErrorMessage == nil || !strings.Contains(*failed.ErrorMessage, tt.status) {
+					t.Fatalf("expected job to be failed with an explanation, got %+v", failed)
+				}
+				if requeued != nil || message != nil {
+					t.Error("failed job must not be requeued")
+				}
+			}
+
+			if event == nil || event.EventType != tt.expectedEvent {
+				t.Errorf("expected %q job event, got %+v", tt.expectedEvent, event)
+			}
+		})
+	}
+}
+
+func TestReaperRequeuesBatchJob(t *testing.T) {
+	job := sqlc.BatchHeightmapJob{
+		ID:             uuid.New(),
+		UserID:         uuid.New(),
+		Status:         "processing",
+		MergeMethod:    "max",
+		GenerationMode: "both",
+		FastMode:       true,
+	}
+
+	var requeued bool
+	var message *sqlc.CreateOutboxMessageParams
+	queries := &mockQueries{
+		listStaleBatchJobsFunc: func(ctx context.Context, params sqlc.ListStaleBatchHeightmapJobsParams) ([]sqlc.BatchHeightmapJob, error) {
+			if params.Status != "processing" {
+				return nil, nil
+			}
+			if time.Since(params.StaleBefore) < 6*time.Hour {
+				t.Errorf("batch processing timeout not applied: %v", params.StaleBefore)
+			}
+			return []sqlc.BatchHeightmapJob{job}, nil
+		},
+		requeueBatchJobFunc: func(ctx context.Context, params sqlc.RequeueBatchHeightmapJobParams) error {
+			requeued = params.ID == job.ID
+			return nil
+		},
+		createOutboxMessageFunc: func(ctx context.Context, params sqlc.CreateOutboxMessageParams) (sqlc.OutboxMessage, error) {
+			message = &params
+			return sqlc.OutboxMessage{}, nil
+		},
+	}
+
+	newTestReaper(queries).reap(context.Background())
+
+	if !requeued {
+		t.Fatal("expected batch job to be requeued")
+	}
+	if message == nil || message.MessageType != OutboxMessageBatchTask {
+		t.Fatalf("expected batch task in the outbox, got %+v", message)
+	}
+	if !strings.Contains(string(message.Payload), `"fast_mode":true`) || !strings.Contains(string(message.Payload), `"merge_method":"max"`) {
+		t.Errorf("task parameters were not preserved: %s", message.Payload)
+	}
+}
@@ -0,0 +1,286 @@
+package heightmap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/internal/webhook"
+	"github.com/skr1ms/dev2gis/pkg/metrics"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+// runningStatuses are the statuses in which a job accepts worker messages.
+// completed, failed and cancelled are terminal: later messages for such a job
+// are late or duplicated deliveries and are dropped, so a stale "processing"
+// or error cannot overwrite a job that has already finished.
+var runningStatuses = map[string]bool{
+	"pending":    true,
+	"processing": true,
+}
+
+// HandleJobResult applies a status, progress or result event reported by a
+// worker to the corresponding job row. The job row is locked for the whole
+// transaction, which also records the job event, charges the results of a
+// completed job to its owner and queues the webhooks, so
+// concurrent results for one job are applied one at a time and either all of
+// their effects are stored or none.
+func (s *Service) HandleJobResult(ctx context.Context, event *rabbitmq.JobResultEvent) error {
+	updatedAt := event.Timestamp
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+
+	switch {
+	case event.JobID != "":
+		jobID, err := uuid.Parse(event.JobID)
+		if err != nil {
+			return fmt.Errorf("некорректный id задачи %q: %w", event.JobID, err)
+		}
+		return s.withTx(ctx, func(q QueriesInterface) error {
+			status, err := q.LockHeightmapJobStatus(ctx, jobID)
+			if err != nil {
+				return lockJobError(jobID, err)
+			}
+			applied, err := s.applyJobEvent(ctx, q, status, jobID, event, updatedAt)
+			if err != nil || !applied {
+				return err
+			}
+			if event.Type == rabbitmq.JobEventResult {
+				if err := s.chargeResults(ctx, q, JobTypeHeightmap, jobID, event); err != nil {
+					return err
+				}
+			}
+			return s.afterJobEvent(ctx, q, JobTypeHeightmap, jobID, event.Type)
+		})
+	case event.BatchJobID != "":
+		batchJobID, err := uuid.Parse(event.BatchJobID)
+		if err != nil {
+			return fmt.Errorf("некорректный id пакетной задачи %q: %w", event.BatchJobID, err)
+		}
+		return s.withTx(ctx, func(q QueriesInterface) error {
+			status, err := q.LockBatchHeightmapJobStatus(ctx, batchJobID)
+			if err != nil {
+				return lockJobError(batchJobID, err)
+			}
+			applied, err := s.applyBatchJobEvent(ctx, q, status, batchJobID, event, updatedAt)
+			if err != nil || !applied {
+				return err
+			}
+			if event.Type == rabbitmq.JobEventResult {
+				if err := s.chargeResults(ctx, q, JobTypeBatch, batchJobID, event); err != nil {
+					return err
+				}
+			}
+			return s.afterJobEvent(ctx, q, JobTypeBatch, batchJobID, event.Type)
+		})
+	default:
+		return fmt.Errorf("в событии результата нет id задачи")
+	}
+}
+
+// lockJobError drops results of jobs that no longer exist, such as jobs of a
+// deleted account: retrying them cannot succeed.
+func lockJobError(jobID uuid.UUID, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	return fmt.Errorf("не удалось заблокировать задачу %s: %w", jobID, err)
+}
+
+// acceptResult reports whether an event may be applied to a job in status
+// current. A running job may move to processing through a status message, to
+// completed through a result and to failed through an error; status messages
+// cannot finish a job, since only results and errors carry its output.
+func acceptResult(current string, event *rabbitmq.JobResultEvent) (bool, error) {
+	switch event.Type {
+	case rabbitmq.JobEventStatus, rabbitmq.JobEventProgress:
+		if event.Status != "" && !jobStatuses[event.Status] {
+			return false, fmt.Errorf("некорректный статус задачи %q", event.Status)
+		}
+		if event.Status != "" && event.Status != "processing" {
+			return false, nil
+		}
+	case rabbitmq.JobEventResult, rabbitmq.JobEventError:
+	default:
+		return false, fmt.Errorf("неизвестный тип события результата %q", event.Type)
+	}
+
+	return runningStatuses[current], nil
+}
+
+func (s *Service) applyJobEvent(ctx context.Context, q QueriesInterface, current string, jobID uuid.UUID, event *rabbitmq.JobResultEvent, updatedAt time.Time) (bool, error) {
+	accepted, err := acceptResult(current, event)
+	if err != nil || !accepted {
+		return false, err
+	}
+
+	switch event.Type {
+	case rabbitmq.JobEventStatus, rabbitmq.JobEventProgress:
+		if event.Status == "" {
+			return false, nil
+		}
+		return true, q.UpdateJobStatus(ctx, sqlc.UpdateJobStatusParams{
+			ID:        jobID,
+			Status:    event.Status,
+			UpdatedAt: updatedAt,
+		})
+	case rabbitmq.JobEventResult:
+		if err := q.UpdateJobResult(ctx, sqlc.UpdateJobResultParams{
+			ID:             jobID,
+			Status:         "completed",
+			ResultUrl:      event.ResultURL,
+			Width:          event.Width,
+			Height:         event.Height,
+			ProcessingTime: event.ProcessingTime,
+			UpdatedAt:      updatedAt,
+		}); err != nil {
+			return false, err
+		}
+		metrics.RecordProcessingJobCompleted()
+		return true, nil
+	default:
+		if err := q.UpdateJobError(ctx, sqlc.UpdateJobErrorParams{
+			ID:           jobID,
+			ErrorMessage: event.ErrorMessage,
+			UpdatedAt:    updatedAt,
+		}); err != nil {
+			return false, err
+		}
+		metrics.RecordProcessingJobFailed()
+		return true, nil
+	}
+}
+
+func (s *Service) applyBatchJobEvent(ctx context.Context, q QueriesInterface, current string, batchJobID uuid.UUID, event *rabbitmq.JobResultEvent, updatedAt time.Time) (bool, error) {
+	accepted, err := acceptResult(current, event)
+	if err != nil || !accepted {
+		return false, err
+	}
+
+	switch event.Type {
+	case rabbitmq.JobEventStatus:
+		if event.Status == "" {
+			return false, nil
+		}
+		return true, q.UpdateBatchJobStatus(ctx, sqlc.UpdateBatchJobStatusParams{
+			ID:        batchJobID,
+			Status:    event.Status,
+			UpdatedAt: updatedAt,
+		})
+	case rabbitmq.JobEventProgress:
+		if event.ProcessedCount == nil {
+			return false, nil
+		}
+		return true, q.UpdateBatchJobProgress(ctx, sqlc.UpdateBatchJobProgressParams{
+			ID:             batchJobID,
+			ProcessedCount: *event.ProcessedCount,
+			UpdatedAt:      updatedAt,
+		})
+	case rabbitmq.JobEventResult:
+		if err := q.UpdateBatchJobResult(ctx, sqlc.UpdateBatchJobResultParams{
+			ID:             batchJobID,
+			Status:         "completed",
+			ResultUrl:      event.ResultURL,
+			OrthophotoUrl:  event.OrthophotoURL,
+			Width:          event.Width,
+			Height:         event.Height,
+			ProcessingTime: event.ProcessingTime,
+			UpdatedAt:      updatedAt,
+		}); err != nil {
+			return false, err
+		}
+		metrics.RecordProcessingJobCompleted()
+		return true, nil
+	default:
+		if err := q.UpdateBatchJobError(ctx, sqlc.UpdateBatchJobErrorParams{
+			ID:           batchJobID,
+			ErrorMessage: event.ErrorMessage,
+			UpdatedAt:    updatedAt,
+		}); err != nil {
+			return false, err
+		}
+		metrics.RecordProcessingJobFailed()
+		return true, nil
+	}
+}
+
+// afterJobEvent publishes an applied state change through q: it stores a job
+// snapshot, whose insert triggers a job_events notification for the SSE
+// streams, and queues the matching webhook deliveries. Called with the queries
+// of the transaction that applied the change, it commits or rolls back with it.
+func (s *Service) afterJobEvent(ctx context.Context, q QueriesInterface, jobType string, jobID uuid.UUID, eventType string) error {
+	var userID uuid.UUID
+	var snapshot interface{}
+
+	switch jobType {
+	case JobTypeHeightmap:
+		job, err := q.GetHeightmapJob(ctx, jobID)
+		if err != nil {
+			return fmt.Errorf("не удалось получить задачу %s: %w", jobID, err)
+		}
+		userID = job.UserID
+		snapshot = newHeightmapJob(job)
+	case JobTypeBatch:
+		job, err := q.GetBatchHeightmapJob(ctx, jobID)
+		if err != nil {
+			return fmt.Errorf("не удалось получить пакетную задачу %s: %w", jobID, err)
+		}
+		userID = job.UserID
+		snapshot = newBatchHeightmapJob(job)
+	default:
+		return fmt.Errorf("неизвестный тип задачи %q", jobType)
+	}
+
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать снимок задачи: %w", err)
+	}
+
+	_, err = q.CreateJobEvent(ctx, sqlc.CreateJobEventParams{
+		UserID:    userID,
+		JobID:     pgtype.UUID{Bytes: jobID, Valid: true},
+		JobType:   jobType,
+		EventType: eventType,
+		Payload:   payload,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить событие задачи: %w", err)
+	}
+
+	webhookEvent := webhookEventFor(jobType, eventType)
+	if webhookEvent == "" || s.notifier == nil {
+		return nil
+	}
+
+	data := map[string]interface{}{
+		"job_type": jobType,
+		"job":      snapshot,
+	}
+	if err := s.notifier.Notify(ctx, q, userID, webhookEvent, data); err != nil {
+		return fmt.Errorf("не удалось поставить вебхуки в очередь: %w", err)
+	}
+
+	return nil
+}
+
+// webhookEventFor maps a worker event to the webhook event it triggers, or ""
+// when subscribers are not interested in it.
+func webhookEventFor(jobType, eventType string) string {
+	switch {
+	case eventType == rabbitmq.JobEventResult:
+		return webhook.EventJobCompleted
+	case eventType == rabbitmq.JobEventError:
+		return webhook.EventJobFailed
+	case eventType == rabbitmq.JobEventProgress && jobType == JobTypeBatch:
+		return webhook.EventBatchProgress
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,428 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/minio"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/internal/webhook"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+func TestHandleJobResult(t *testing.T) {
+	jobID := uuid.New()
+	batchJobID := uuid.New()
+	resultURL := "http://minio:9000/uav-models/heightmaps/test.png"
+	errMsg := "worker crashed"
+	processed := int32(3)
+	width := int32(640)
+
+	tests := []struct {
+		name        string
+		event       *rabbitmq.JobResultEvent
+		expectCall  string
+		expectError bool
+	}{
+		{
+			name:       "single job status",
+			event:      &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventStatus, JobID: jobID.String(), Status: "processing"},
+			expectCall: "UpdateJobStatus",
+		},
+		{
+			name:       "single job result",
+			event:      &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventResult, JobID: jobID.String(), ResultURL: &resultURL, Width: &width},
+			expectCall: "UpdateJobResult",
+		},
+		{
+			name:       "single job error",
+			event:      &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventError, JobID: jobID.String(), ErrorMessage: &errMsg},
+			expectCall: "UpdateJobError",
+		},
+		{
+			name:       "batch job progress",
+			event:      &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventProgress, BatchJobID: batchJobID.String(), ProcessedCount: &processed},
+			expectCall: "UpdateBatchJobProgress",
+		},
+		{
+			name:       "batch job result",
+			event:      &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventResult, BatchJobID: batchJobID.String(), ResultURL: &resultURL},
+			expectCall: "UpdateBatchJobResult",
+		},
+		{
+			name:       "batch job error",
+			event:      &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventError, BatchJobID: batchJobID.String(), ErrorMessage: &errMsg},
+			expectCall: "UpdateBatchJobError",
+		},
+		{
+			name:        "missing job id",
+			event:       &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventStatus, Status: "processing"},
+			expectError: true,
+		},
+		{
+			name:        "invalid job id",
+			event:       &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventStatus, JobID: "not-a-uuid"},
+			expectError: true,
+		},
+		{
+			name:        "unknown event type",
+			event:       &rabbitmq.JobResultEvent{Type: "unknown", JobID: jobID.String()},
+			expectError: true,
+		},
+		{
+			name:        "invalid status",
+			event:       &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventStatus, JobID: jobID.String(), Status: "done"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called string
+			queries := &mockQueries{
+				updateJobStatusFunc: func(ctx context.Context, params sqlc.UpdateJobStatusParams) error {
+					called = "UpdateJobStatus"
+					if params.ID != jobID || params.Status != tt.event.Status {
+						t.Errorf("unexpected params: %+v", params)
+					}
+					return nil
+				},
+				updateJobResultFunc: func(ctx context.Context, params sqlc.UpdateJobResultParams) error {
+					called = "UpdateJobResult"
+					if params.Status != "completed" || params.ResultUrl == nil || *params.ResultUrl != resultURL {
+						t.Errorf("unexpected params: %+v", params)
+					}
+					return nil
+				},
+				updateJobErrorFunc: func(ctx context.Context, params sqlc.UpdateJobErrorParams) error {
+					called = "UpdateJobError"
+					if params.ErrorMessage == nil || *params.ErrorMessage != errMsg {
+						t.Errorf("unexpected params: %+v", params)
+					}
+					return nil
+				},
+				updateBatchJobProgressFunc: func(ctx context.Context, params sqlc.UpdateBatchJobProgressParams) error {
+					called = "UpdateBatchJobProgress"
+					if params.ID != batchJobID || params.ProcessedCount != processed {
+						t.Errorf("unexpected params: %+v", params)
+					}
+					return nil
+				},
+				updateBatchJobResultFunc: func(ctx context.Context, params sqlc.UpdateBatchJobResultParams) error {
+					called = "UpdateBatchJobResult"
+					if params.Status != "completed" {
+						t.Errorf("unexpected params: %+v", params)
+					}
+					return nil
+				},
+				updateBatchJobErrorFunc: func(ctx context.Context, params sqlc.UpdateBatchJobErrorParams) error {
+					called = "UpdateBatchJobError"
+					return nil
+				},
+			}
+
+			s := newTestScheduleService(queries)
+
+			tt.event.Timestamp = time.Now()
+			err := s.HandleJobResult(context.Background(), tt.event)
+
+			if tt.expectError && err == nil {
+				t.Errorf("expected error but got none")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if called != tt.expectCall {
+				t.Errorf("expected %q to be called, got %q", tt.expectCall, called)
+			}
+		})
+	}
+}
+
+func TestHandleJobResultRecordsEvent(t *testing.T) {
+	userID := uuid.New()
+	jobID := uuid.New()
+
+	var recorded *sqlc.CreateJobEventParams
+	queries := &mockQueries{
+		getJobFunc: func(ctx context.Context, id uuid.UUID) (sqlc.HeightmapJob, error) {
+			return sqlc.HeightmapJob{ID: id, UserID: userID, Status: "processing"}, nil
+		},
+		createJobEventFunc: func(ctx context.Context, params sqlc.CreateJobEventParams) (sqlc.JobEvent, error) {
+			recorded = &params
+			return sqlc.JobEvent{}, nil
+		},
+	}
+
+	s := newTestScheduleService(queries)
+
+	err := s.HandleJobResult(context.Background(), &rabbitmq.JobResultEvent{
+		Type:   rabbitmq.JobEventStatus,
+		JobID:  jobID.String(),
+		Status: "processing",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if recorded == nil {
+		t.Fatal("expected job event to be recorded")
+	}
+	if recorded.UserID != userID || recorded.JobID.Bytes != jobID || recorded.JobType != JobTypeHeightmap || recorded.EventType != rabbitmq.JobEventStatus {
+		t.Errorf("unexpected event params: %+v", recorded)
+	}
+}
+
+type mockNotifier struct {
+	events []string
+}
+
+func (m *mockNotifier) Notify(ctx context.Context, q webhook.DeliveryQueriesInterface, userID uuid.UUID, event string, data interface{}) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func TestHandleJobResultNotifiesWebhooks(t *testing.T) {
+	jobID := uuid.New().String()
+	processed := int32(1)
+
+	tests := []struct {
+		name     string
+		event    *rabbitmq.JobResultEvent
+		expected string
+	}{
+		{name: "job completed", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventResult, JobID: jobID}, expected: webhook.EventJobCompleted},
+		{name: "job failed", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventError, BatchJobID: jobID}, expected: webhook.EventJobFailed},
+		{name: "batch progress", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventProgress, BatchJobID: jobID, ProcessedCount: &processed}, expected: webhook.EventBatchProgress},
+		{name: "status change", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventStatus, JobID: jobID, Status: "processing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &mockNotifier{}
+			s := newTestScheduleService(&mockQueries{})
+			s.notifier = notifier
+
+			if err := s.HandleJobResult(context.Background(), tt.event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.expected == "" {
+				if len(notifier.events) != 0 {
+					t.Errorf("expected no webhook event, got %v", notifier.events)
+				}
+				return
+			}
+
+			if len(notifier.events) != 1 || notifier.events[0] != tt.expected {
+				t.Errorf("expected %q, got %v", tt.expected, notifier.events)
+			}
+		})
+	}
+}
+
+func TestHandleJobResultTransitions(t *testing.T) {
+	errMsg := "late failure"
+	processed := int32(2)
+
+	tests := []struct {
+		name          string
+		current       string
+		event         *rabbitmq.JobResultEvent
+		expectApplied bool
+	}{
+		{name: "pending to processing", current: "pending", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventStatus, Status: "processing"}, expectApplied: true},
+		{name: "processing to completed", current: "processing", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventResult}, expectApplied: true},
+		{name: "late processing after completion", current: "completed", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventStatus, Status: "processing"}},
+		{name: "late failure after cancellation", current: "cancelled", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventError, ErrorMessage: &errMsg}},
+		{name: "duplicate result", current: "completed", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventResult}},
+		{name: "progress of failed job", current: "failed", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventProgress, ProcessedCount: &processed}},
+		{name: "worker cannot complete without result", current: "processing", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventStatus, Status: "completed"}},
+		{name: "worker cannot move job back to pending", current: "processing", event: &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventStatus, Status: "pending"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := false
+			recorded := false
+			notifier := &mockNotifier{}
+			queries := &mockQueries{
+				lockBatchJobStatusFunc: func(ctx context.Context, id uuid.UUID) (string, error) {
+					return tt.current, nil
+				},
+				updateBatchJobStatusFunc: func(ctx context.Context, params sqlc.UpdateBatchJobStatusParams) error {
+					applied = true
+					return nil
+				},
+				updateBatchJobResultFunc: func(ctx context.Context, params sqlc.UpdateBatchJobResultParams) error {
+					applied = true
+					return nil
+				},
+				updateBatchJobErrorFunc: func(ctx context.Context, params sqlc.UpdateBatchJobErrorParams) error {
+					applied = true
+					return nil
+				},
+				updateBatchJobProgressFunc: func(ctx context.Context, params sqlc.UpdateBatchJobProgressParams) error {
+					applied = true
+					return nil
+				},
+				createJobEventFunc: func(ctx context.Context, params sqlc.CreateJobEventParams) (sqlc.JobEvent, error) {
+					recorded = true
+					return sqlc.JobEvent{}, nil
+				},
+			}
+			s := newTestScheduleService(queries)
+			s.notifier = notifier
+
+			tt.event.BatchJobID = uuid.New().String()
+			if err := s.HandleJobResult(context.Background(), tt.event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if applied != tt.expectApplied {
+				t.Errorf("expected applied %v, got %v", tt.expectApplied, applied)
+			}
+			if recorded != tt.expectApplied {
+				t.Errorf("expected the job event to be recorded only with the update, got %v", recorded)
+			}
+			if !tt.expectApplied && len(notifier.events) != 0 {
+				t.Errorf("expected no webhook for a dropped message, got %v", notifier.events)
+			}
+		})
+	}
+}
+
+func TestHandleJobResultForDeletedJob(t *testing.T) {
+	queries := &mockQueries{
+		lockJobStatusFunc: func(ctx context.Context, id uuid.UUID) (string, error) {
+			return "", pgx.ErrNoRows
+		},
+		updateJobResultFunc: func(ctx context.Context, params sqlc.UpdateJobResultParams) error {
+			t.Error("a deleted job must not be updated")
+			return nil
+		},
+	}
+	s := newTestScheduleService(queries)
+
+	err := s.HandleJobResult(context.Background(), &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventResult, JobID: uuid.New().String()})
+	if err != nil {
+		t.Fatalf("expected the result to be dropped, got %v", err)
+	}
+}
+
+func TestHandleJobResultChargesResults(t *testing.T) {
+	userID := uuid.New()
+	jobID := uuid.New()
+	resultURL := "http://minio:9000/uav-models/heightmaps/result.png"
+	orthophotoURL := "http://minio:9000/uav-photoplanes/orthophotos/result.tif"
+	sizes := map[string]int64{
+		"uav-models/heightmaps/result.png":       300,
+		"uav-photoplanes/orthophotos/result.tif": 700,
+	}
+
+	tests := []struct {
+		name           string
+		event          *rabbitmq.JobResultEvent
+		expectedBytes  int64
+		expectedPrefix string
+	}{
+		{
+			name:           "single job",
+			event:          &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventResult, JobID: jobID.String(), ResultURL: &resultURL},
+			expectedBytes:  300,
+			expectedPrefix: "heightmaps/" + userID.String() + "/" + jobID.String(),
+		},
+		{
+			name:           "batch job with orthophoto",
+			event:          &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventResult, BatchJobID: jobID.String(), ResultURL: &resultURL, OrthophotoURL: &orthophotoURL},
+			expectedBytes:  1000,
+			expectedPrefix: "batch-heightmaps/" + userID.String() + "/" + jobID.String() + "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			var recorded sqlc.CreateStorageUsageParams
+			var scheduled sqlc.ScheduleJobStorageDeletionParams
+			queries := &mockQueries{
+				getJobFunc: func(ctx context.Context, id uuid.UUID) (sqlc.HeightmapJob, error) {
+					return sqlc.HeightmapJob{ID: id, UserID: userID, Status: "completed"}, nil
+				},
+				getBatchJobFunc: func(ctx context.Context, id uuid.UUID) (sqlc.BatchHeightmapJob, error) {
+					return sqlc.BatchHeightmapJob{ID: id, UserID: userID, Status: "completed"}, nil
+				},
+				deleteJobUsageFunc: func(ctx context.Context, heightmapJobID, batchJobID *uuid.UUID) error {
+					calls = append(calls, "release")
+					released := heightmapJobID
+					if released == nil {
+						released = batchJobID
+					}
+					if released == nil || *released != jobID {
+						t.Errorf("expected the usage of job %s to be released, got %v", jobID, released)
+					}
+					return nil
+				},
+				createStorageUsageFunc: func(ctx context.Context, params sqlc.CreateStorageUsageParams) error {
+					calls = append(calls, "charge")
+					recorded = params
+					return nil
+				},
+				scheduleJobDeletionFunc: func(ctx context.Context, params sqlc.ScheduleJobStorageDeletionParams) error {
+					scheduled = params
+					return nil
+				},
+			}
+			s := newTestScheduleService(queries)
+			s.minioClient = &mockMinioClient{
+				getFileInfoFunc: func(ctx context.Context, bucket, objectName string) (*minio.FileInfo, error) {
+					return &minio.FileInfo{Name: objectName, Size: sizes[bucket+"/"+objectName]}, nil
+				},
+			}
+
+			if err := s.HandleJobResult(context.Background(), tt.event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(calls) != 2 || calls[0] != "release" || calls[1] != "charge" {
+				t.Errorf("expected the upload usage to be replaced by the results, got %v", calls)
+			}
+			if recorded.UserID != userID || recorded.Bytes != tt.expectedBytes {
+				t.Errorf("expected %d bytes charged to %s, got %+v", tt.expectedBytes, userID, recorded)
+			}
+			if len(scheduled.InputPrefixes) != 1 || scheduled.InputPrefixes[0] != tt.expectedPrefix {
+				t.Errorf("expected upload prefix %q, got %v", tt.expectedPrefix, scheduled.InputPrefixes)
+			}
+			if len(scheduled.HeightmapJobIds) != 0 || len(scheduled.BatchJobIds) != 0 {
+				t.Errorf("the results must not be deleted, got %+v", scheduled)
+			}
+		})
+	}
+}
+
+func TestHandleJobResultWithMissingResult(t *testing.T) {
+	resultURL := "http://minio:9000/uav-models/heightmaps/result.png"
+	queries := &mockQueries{
+		createStorageUsageFunc: func(ctx context.Context, params sqlc.CreateStorageUsageParams) error {
+			t.Error("no usage must be recorded for a result that is not stored")
+			return nil
+		},
+	}
+	s := newTestScheduleService(queries)
+	s.minioClient = &mockMinioClient{
+		getFileInfoFunc: func(ctx context.Context, bucket, objectName string) (*minio.FileInfo, error) {
+			return nil, errors.New("object not found")
+		},
+	}
+
+	err := s.HandleJobResult(context.Background(), &rabbitmq.JobResultEvent{Type: rabbitmq.JobEventResult, JobID: uuid.New().String(), ResultURL: &resultURL})
+	if err == nil {
+		t.Fatal("expected the result to be retried")
+	}
+}
@@ -0,0 +1,299 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/organization"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+// schedulerLockKey elects the single replica that releases scheduled jobs.
+const schedulerLockKey int64 = 0x64326700_00000003
+
+var (
+	ErrInvalidSchedule   = errors.New("некорректное время запуска, ожидается формат RFC3339")
+	ErrScheduleInPast    = errors.New("время запуска должно быть в будущем")
+	ErrScheduleTooFar    = errors.New("время запуска слишком далеко в будущем")
+	ErrJobNotFound       = errors.New("задача не найдена")
+	ErrJobNotScheduled   = errors.New("задача не ожидает запуска по расписанию")
+	ErrJobNotCancellable = errors.New("задача уже передана в обработку и не может быть отменена")
+	ErrJobAccessDenied   = errors.New("управлять задачами других участников могут только администраторы организации")
+)
+
+// Scheduler releases jobs uploaded with scheduled_at once their time has
+// come. Released jobs become pending and are then sent to the workers by the
+// Dispatcher like any other upload. The schedule lives in the database, so
+// jobs due while the orchestrator was down are released on the next tick.
+type Scheduler struct {
+	service *Service
+	cfg     config.SchedulerConfig
+	logger  middleware.LoggerInterface
+}
+
+type releasedJob struct {
+	jobType string
+	jobID   uuid.UUID
+}
+
+func NewScheduler(service *Service, cfg config.SchedulerConfig, logger middleware.LoggerInterface) *Scheduler {
+	return &Scheduler{
+		service: service,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.release(ctx)
+		}
+	}
+}
+
+func (s *Scheduler) release(ctx context.Context) {
+	var released []releasedJob
+
+	err := s.service.withTx(ctx, func(q QueriesInterface) error {
+		acquired, err := q.TryAdvisoryXactLock(ctx, schedulerLockKey)
+		if err != nil {
+			return fmt.Errorf("не удалось захватить блокировку планировщика: %w", err)
+		}
+		if !acquired {
+			return nil
+		}
+
+		released, err = s.releaseDue(ctx, q, time.Now())
+		return err
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("Failed to release scheduled jobs", err)
+		}
+		return
+	}
+
+	for _, job := range released {
+		if err := s.service.afterJobEvent(ctx, s.service.queries, job.jobType, job.jobID, rabbitmq.JobEventStatus); err != nil {
+			s.logger.Error("Failed to publish released job event", err, map[string]interface{}{
+				"job_id":   job.jobID.String(),
+				"job_type": job.jobType,
+			})
+		}
+	}
+}
+
+func (s *Scheduler) releaseDue(ctx context.Context, q QueriesInterface, now time.Time) ([]releasedJob, error) {
+	var released []releasedJob
+
+	jobs, err := q.ReleaseDueHeightmapJobs(ctx, sqlc.ReleaseDueHeightmapJobsParams{
+		UpdatedAt: now,
+		Limit:     int32(s.cfg.BatchSize),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось запустить запланированные задачи: %w", err)
+	}
+	for _, job := range jobs {
+		released = append(released, releasedJob{jobType: JobTypeHeightmap, jobID: job.ID})
+	}
+
+	batchJobs, err := q.ReleaseDueBatchHeightmapJobs(ctx, sqlc.ReleaseDueBatchHeightmapJobsParams{
+		UpdatedAt: now,
+		Limit:     int32(s.cfg.BatchSize),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось запустить запланированные пакетные задачи: %w", err)
+	}
+	for _, job := range batchJobs {
+		released = append(released, releasedJob{jobType: JobTypeBatch, jobID: job.ID})
+	}
+
+	return released, nil
+}
+
+// ParseSchedule parses the optional scheduled_at upload field. An empty value
+// means the job should run as soon as possible.
+func (s *Service) ParseSchedule(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, ErrInvalidSchedule
+	}
+	if err := s.validateSchedule(at, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return &at, nil
+}
+
+func (s *Service) validateSchedule(at, now time.Time) error {
+	if !at.After(now) {
+		return ErrScheduleInPast
+	}
+	if horizon := s.cfg.Scheduler.MaxHorizon; horizon > 0 && at.Sub(now) > horizon {
+		return fmt.Errorf("%w: максимум %s", ErrScheduleTooFar, horizon)
+	}
+	return nil
+}
+
+// initialStatus is the status a new job is created with.
+func initialStatus(scheduledAt *time.Time) string {
+	if scheduledAt != nil {
+		return "scheduled"
+	}
+	return "pending"
+}
+
+// RescheduleHeightmapJob moves a job that has not been released yet to a new
+// time.
+func (s *Service) RescheduleHeightmapJob(ctx context.Context, jobID, userID uuid.UUID, at time.Time) (*HeightmapJob, error) {
+	if err := s.validateSchedule(at, time.Now()); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.queries.RescheduleHeightmapJob(ctx, sqlc.RescheduleHeightmapJobParams{
+		ID:          jobID,
+		UserID:      userID,
+		ScheduledAt: &at,
+		UpdatedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось изменить расписание задачи: %w", err)
+	}
+	if updated == 0 {
+		return nil, s.jobStateError(ctx, JobTypeHeightmap, jobID, userID, ErrJobNotScheduled)
+	}
+
+	return s.GetHeightmapJob(ctx, jobID, userID)
+}
+
+// RescheduleBatchHeightmapJob is RescheduleHeightmapJob for batch jobs.
+func (s *Service) RescheduleBatchHeightmapJob(ctx context.Context, batchJobID, userID uuid.UUID, at time.Time) (*BatchHeightmapJob, error) {
+	if err := s.validateSchedule(at, time.Now()); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.queries.RescheduleBatchHeightmapJob(ctx, sqlc.RescheduleBatchHeightmapJobParams{
+		ID:          batchJobID,
+		UserID:      userID,
+		ScheduledAt: &at,
+		UpdatedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось изменить расписание пакетной задачи: %w", err)
+	}
+	if updated == 0 {
+		return nil, s.jobStateError(ctx, JobTypeBatch, batchJobID, userID, ErrJobNotScheduled)
+	}
+
+	return s.GetBatchHeightmapJob(ctx, batchJobID, userID)
+}
+
+// CancelHeightmapJob cancels a scheduled job, or a pending one the dispatcher
+// has not sent to the workers yet.
+func (s *Service) CancelHeightmapJob(ctx context.Context, jobID, userID uuid.UUID) (*HeightmapJob, error) {
+	cancelled, err := s.queries.CancelHeightmapJob(ctx, sqlc.CancelHeightmapJobParams{
+		ID:        jobID,
+		UserID:    userID,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отменить задачу: %w", err)
+	}
+	if cancelled == 0 {
+		return nil, s.jobStateError(ctx, JobTypeHeightmap, jobID, userID, ErrJobNotCancellable)
+	}
+
+	if err := s.afterJobEvent(ctx, s.queries, JobTypeHeightmap, jobID, rabbitmq.JobEventStatus); err != nil {
+		return nil, err
+	}
+
+	return s.GetHeightmapJob(ctx, jobID, userID)
+}
+
+// CancelBatchHeightmapJob is CancelHeightmapJob for batch jobs.
+func (s *Service) CancelBatchHeightmapJob(ctx context.Context, batchJobID, userID uuid.UUID) (*BatchHeightmapJob, error) {
+	cancelled, err := s.queries.CancelBatchHeightmapJob(ctx, sqlc.CancelBatchHeightmapJobParams{
+		ID:        batchJobID,
+		UserID:    userID,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отменить пакетную задачу: %w", err)
+	}
+	if cancelled == 0 {
+		return nil, s.jobStateError(ctx, JobTypeBatch, batchJobID, userID, ErrJobNotCancellable)
+	}
+
+	if err := s.afterJobEvent(ctx, s.queries, JobTypeBatch, batchJobID, rabbitmq.JobEventStatus); err != nil {
+		return nil, err
+	}
+
+	return s.GetBatchHeightmapJob(ctx, batchJobID, userID)
+}
+
+// jobStateError explains why a conditional update matched no rows: the job is
+// not visible to the user, it belongs to another member of an organization
+// where the user may not manage jobs, or it is in the wrong state.
+func (s *Service) jobStateError(ctx context.Context, jobType string, jobID, userID uuid.UUID, stateErr error) error {
+	if err := s.requireJobManager(ctx, jobType, jobID, userID); err != nil {
+		return err
+	}
+	return stateErr
+}
+
+// requireJobManager passes for the owner of the job and for the admins and
+// owners of its organization.
+func (s *Service) requireJobManager(ctx context.Context, jobType string, jobID, userID uuid.UUID) error {
+	var (
+		ownerID        uuid.UUID
+		organizationID *uuid.UUID
+		err            error
+	)
+
+	switch jobType {
+	case JobTypeHeightmap:
+		var job sqlc.HeightmapJob
+		job, err = s.queries.GetAccessibleHeightmapJob(ctx, sqlc.GetAccessibleHeightmapJobParams{ID: jobID, UserID: userID})
+		ownerID, organizationID = job.UserID, job.OrganizationID
+	default:
+		var job sqlc.BatchHeightmapJob
+		job, err = s.queries.GetAccessibleBatchHeightmapJob(ctx, sqlc.GetAccessibleBatchHeightmapJobParams{ID: jobID, UserID: userID})
+		ownerID, organizationID = job.UserID, job.OrganizationID
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrJobNotFound
+		}
+		return fmt.Errorf("не удалось загрузить задачу: %w", err)
+	}
+
+	if ownerID != userID && organizationID != nil {
+		role, err := s.organizationRole(ctx, *organizationID, userID)
+		if err != nil {
+			return err
+		}
+		if !organization.CanManageJobs(role) {
+			return ErrJobAccessDenied
+		}
+	}
+
+	return nil
+}
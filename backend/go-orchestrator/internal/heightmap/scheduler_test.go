@@ -0,0 +1,223 @@
+package heightmap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+func newTestScheduleService(queries *mockQueries) *Service {
+	return &Service{
+		queries: queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return fn(queries)
+		},
+		minioClient: &mockMinioClient{},
+		cfg: &config.Config{
+			Minio:     config.MinioConfig{PublicURL: "http://minio:9000", UAVDataBucketName: "uav-data"},
+			Scheduler: config.SchedulerConfig{Interval: time.Second, BatchSize: 100, MaxHorizon: 24 * time.Hour},
+		},
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	s := newTestScheduleService(&mockQueries{})
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		value       string
+		expectNil   bool
+		expectedErr error
+	}{
+		{name: "empty runs immediately", value: "", expectNil: true},
+		{name: "future time", value: now.Add(time.Hour).Format(time.RFC3339)},
+		{name: "not RFC3339", value: "tomorrow", expectedErr: ErrInvalidSchedule},
+		{name: "past time", value: now.Add(-time.Minute).Format(time.RFC3339), expectedErr: ErrScheduleInPast},
+		{name: "beyond the horizon", value: now.Add(48 * time.Hour).Format(time.RFC3339), expectedErr: ErrScheduleTooFar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := s.ParseSchedule(tt.value)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (at == nil) != tt.expectNil {
+				t.Errorf("expected nil=%v, got %v", tt.expectNil, at)
+			}
+		})
+	}
+}
+
+func TestUploadPhotoScheduled(t *testing.T) {
+	var created *sqlc.CreateHeightmapJobParams
+	queries := &mockQueries{
+		createJobFunc: func(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error) {
+			created = &params
+			return sqlc.HeightmapJob{ID: params.ID}, nil
+		},
+	}
+	s := newTestScheduleService(queries)
+
+	at := time.Now().Add(time.Hour)
+	file := memoryFile{bytes.NewReader([]byte("image"))}
+	resp, err := s.UploadPhoto(context.Background(), uuid.New(), nil, nil, file, &multipart.FileHeader{Filename: "photo.jpg", Size: 5}, priorityValues[PriorityNormal], &at)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if created == nil || created.Status != "scheduled" || created.ScheduledAt == nil || !created.ScheduledAt.Equal(at) {
+		t.Fatalf("expected a scheduled job, got %+v", created)
+	}
+	if resp.Status != "scheduled" || resp.ScheduledAt == nil {
+		t.Errorf("expected scheduled response, got %+v", resp)
+	}
+}
+
+func TestSchedulerRelease(t *testing.T) {
+	tests := []struct {
+		name          string
+		locked        bool
+		expectedEvent int
+	}{
+		{name: "releases due jobs", locked: true, expectedEvent: 2},
+		{name: "another replica holds the lock", locked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobID := uuid.New()
+			batchJobID := uuid.New()
+
+			var events []sqlc.CreateJobEventParams
+			released := false
+			queries := &mockQueries{
+				tryAdvisoryXactLockFunc: func(ctx context.Context, key int64) (bool, error) {
+					if key != schedulerLockKey {
+						t.Errorf("unexpected lock key %x", key)
+					}
+					return tt.locked, nil
+				},
+				releaseDueJobsFunc: func(ctx context.Context, params sqlc.ReleaseDueHeightmapJobsParams) ([]sqlc.HeightmapJob, error) {
+					released = true
+					if params.Limit != 100 {
+						t.Errorf("expected limit 100, got %d", params.Limit)
+					}
+					return []sqlc.HeightmapJob{{ID: jobID, Status: "pending"}}, nil
+				},
+				releaseDueBatchJobsFunc: func(ctx context.Context, params sqlc.ReleaseDueBatchHeightmapJobsParams) ([]sqlc.BatchHeightmapJob, error) {
+					return []sqlc.BatchHeightmapJob{{ID: batchJobID, Status: "pending"}}, nil
+				},
+				createJobEventFunc: func(ctx context.Context, params sqlc.CreateJobEventParams) (sqlc.JobEvent, error) {
+					events = append(events, params)
+					return sqlc.JobEvent{}, nil
+				},
+			}
+			s := newTestScheduleService(queries)
+
+			NewScheduler(s, s.cfg.Scheduler, nopLogger{}).release(context.Background())
+
+			if released != tt.locked {
+				t.Fatalf("expected release=%v, got %v", tt.locked, released)
+			}
+			if len(events) != tt.expectedEvent {
+				t.Fatalf("expected %d events, got %d", tt.expectedEvent, len(events))
+			}
+			for _, event := range events {
+				if event.EventType != rabbitmq.JobEventStatus {
+					t.Errorf("expected status event, got %q", event.EventType)
+				}
+			}
+		})
+	}
+}
+
+func TestCancelHeightmapJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		cancelled   int64
+		lookupErr   error
+		expectedErr error
+	}{
+		{name: "cancels waiting job", cancelled: 1},
+		{name: "job already dispatched", cancelled: 0, expectedErr: ErrJobNotCancellable},
+		{name: "job of another user", cancelled: 0, lookupErr: pgx.ErrNoRows, expectedErr: ErrJobNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventRecorded := false
+			queries := &mockQueries{
+				cancelJobFunc: func(ctx context.Context, params sqlc.CancelHeightmapJobParams) (int64, error) {
+					return tt.cancelled, nil
+				},
+				getAccessibleJobFunc: func(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error) {
+					if tt.lookupErr != nil {
+						return sqlc.HeightmapJob{}, tt.lookupErr
+					}
+					return sqlc.HeightmapJob{ID: params.ID, Status: "cancelled"}, nil
+				},
+				createJobEventFunc: func(ctx context.Context, params sqlc.CreateJobEventParams) (sqlc.JobEvent, error) {
+					eventRecorded = true
+					return sqlc.JobEvent{}, nil
+				},
+			}
+			s := newTestScheduleService(queries)
+
+			job, err := s.CancelHeightmapJob(context.Background(), uuid.New(), uuid.New())
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if eventRecorded {
+					t.Error("no event must be recorded when nothing was cancelled")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if job.Status != "cancelled" {
+				t.Errorf("expected cancelled job, got %q", job.Status)
+			}
+			if !eventRecorded {
+				t.Error("expected a status event for the cancellation")
+			}
+		})
+	}
+}
+
+func TestRescheduleRejectsReleasedJob(t *testing.T) {
+	queries := &mockQueries{
+		rescheduleBatchJobFunc: func(ctx context.Context, params sqlc.RescheduleBatchHeightmapJobParams) (int64, error) {
+			return 0, nil
+		},
+		getAccessibleBatchJobFunc: func(ctx context.Context, params sqlc.GetAccessibleBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error) {
+			return sqlc.BatchHeightmapJob{ID: params.ID, Status: "processing"}, nil
+		},
+	}
+	s := newTestScheduleService(queries)
+
+	_, err := s.RescheduleBatchHeightmapJob(context.Background(), uuid.New(), uuid.New(), time.Now().Add(time.Hour))
+	if !errors.Is(err, ErrJobNotScheduled) {
+		t.Fatalf("expected %v, got %v", ErrJobNotScheduled, err)
+	}
+}
@@ -2,6 +2,7 @@ package heightmap
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mime/multipart"
 	"path/filepath"
@@ -14,28 +15,41 @@ import (
 	"github.com/skr1ms/dev2gis/internal/storage/minio"
 	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
 	"github.com/skr1ms/dev2gis/pkg/metrics"
-	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
 )
 
+// ErrEmailNotVerified rejects uploads from accounts that have not confirmed
+// their email yet.
+var ErrEmailNotVerified = errors.New("подтвердите email, чтобы загружать фото")
+
 type Service struct {
-	db             *storage.DB
-	queries        QueriesInterface
-	minioClient    MinioClientInterface
-	rabbitmqClient *rabbitmq.Client
-	cfg            *config.Config
+	db          *storage.DB
+	queries     QueriesInterface
+	withTx      func(ctx context.Context, fn func(q QueriesInterface) error) error
+	minioClient MinioClientInterface
+	notifier    JobNotifierInterface
+	cfg         *config.Config
 }
 
-func NewService(db *storage.DB, minioClient *minio.MinioClient, rabbitmqClient *rabbitmq.Client, cfg *config.Config) *Service {
+// NewService creates the heightmap service. Tasks are not published directly:
+// new jobs wait in the database until Dispatcher writes them to the outbox in
+// fair-share order, and OutboxRelay sends them to RabbitMQ. Jobs uploaded with
+// scheduled_at wait for Scheduler to release them first.
+func NewService(db *storage.DB, minioClient *minio.MinioClient, notifier JobNotifierInterface, cfg *config.Config) *Service {
 	return &Service{
-		db:             db,
-		queries:        db.Queries,
-		minioClient:    minioClient,
-		rabbitmqClient: rabbitmqClient,
-		cfg:            cfg,
+		db:      db,
+		queries: db.Queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return db.WithTx(ctx, func(q *sqlc.Queries) error {
+				return fn(q)
+			})
+		},
+		minioClient: minioClient,
+		notifier:    notifier,
+		cfg:         cfg,
 	}
 }
 
-func (s *Service) UploadPhoto(ctx context.Context, userID uuid.UUID, file multipart.File, header *multipart.FileHeader) (*UploadResponse, error) {
+func (s *Service) UploadPhoto(ctx context.Context, userID uuid.UUID, organizationID, projectID *uuid.UUID, file multipart.File, header *multipart.FileHeader, priority int32, scheduledAt *time.Time) (*UploadResponse, error) {
 	start := time.Now()
 	metrics.RecordProcessingJob()
 
@@ -48,6 +62,24 @@ func (s *Service) UploadPhoto(ctx context.Context, userID uuid.UUID, file multip
 		return nil, fmt.Errorf("файл слишком большой: максимум 100МБ")
 	}
 
+	if err := s.requireVerifiedEmail(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	organizationID, err := s.projectOrganization(ctx, projectID, organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireOrganizationMember(ctx, organizationID, userID); err != nil {
+		return nil, err
+	}
+
+	quota := upload{images: 1, bytes: header.Size, priority: priority}
+	if err := s.checkQuota(ctx, s.queries, userID, quota); err != nil {
+		return nil, err
+	}
+
 	jobID := uuid.New()
 	objectName := fmt.Sprintf("heightmaps/%s/%s%s", userID.String(), jobID.String(), ext)
 
@@ -59,53 +91,69 @@ func (s *Service) UploadPhoto(ctx context.Context, userID uuid.UUID, file multip
 
 	now := time.Now()
 	job := sqlc.CreateHeightmapJobParams{
-		ID:        jobID,
-		UserID:    userID,
-		ImageUrl:  imageURL,
-		Status:    "pending",
-		CreatedAt: now,
-		UpdatedAt: now,
-	}
-
-	if _, err := s.queries.CreateHeightmapJob(ctx, job); err != nil {
-		return nil, fmt.Errorf("не удалось создать задачу в базе данных: %w", err)
-	}
-
-	task := &rabbitmq.HeightmapTask{
-		JobID:          jobID.String(),
-		UserID:         userID.String(),
-		ImageURL:       imageURL,
-		OutputBucket:   s.cfg.Minio.UAVModelsBucketName,
-		MinioEndpoint:  s.cfg.Minio.Endpoint,
-		MinioPublicURL: s.cfg.Minio.PublicURL,
-		MinioAccessKey: s.cfg.Minio.AccessKeyID,
-		MinioSecretKey: s.cfg.Minio.SecretAccessKey,
-		MinioUseSSL:    s.cfg.Minio.UseSSL,
+		ID:             jobID,
+		UserID:         userID,
+		ImageUrl:       imageURL,
+		Status:         initialStatus(scheduledAt),
+		Priority:       priority,
+		ScheduledAt:    scheduledAt,
 		CreatedAt:      now,
-		Priority:       0,
+		UpdatedAt:      now,
+		OrganizationID: organizationID,
+		ProjectID:      projectID,
 	}
 
-	if err := s.rabbitmqClient.PublishTask(ctx, task); err != nil {
-		now := time.Now()
-		errMsg := fmt.Sprintf("не удалось отправить задачу в очередь: %v", err)
-		_ = s.queries.UpdateJobError(ctx, sqlc.UpdateJobErrorParams{
-			ID:           jobID,
-			ErrorMessage: &errMsg,
-			UpdatedAt:    now,
-		})
-		return nil, fmt.Errorf("не удалось отправить задачу: %w", err)
+	err = s.withTx(ctx, func(q QueriesInterface) error {
+		if err := s.reserveQuota(ctx, q, userID, quota); err != nil {
+			return err
+		}
+		if _, err := q.CreateHeightmapJob(ctx, job); err != nil {
+			return fmt.Errorf("не удалось создать задачу в базе данных: %w", err)
+		}
+		return recordStorageUsage(ctx, q, userID, JobTypeHeightmap, jobID, header.Size)
+	})
+	if err != nil {
+		s.removeUploads(ctx, objectName)
+		return nil, err
 	}
 
 	metrics.RecordProcessingJobDuration(time.Since(start))
 
 	return &UploadResponse{
-		ID:     jobID,
-		Status: "pending",
+		ID:             jobID,
+		Status:         job.Status,
+		Priority:       priorityClass(priority),
+		ScheduledAt:    scheduledAt,
+		OrganizationID: organizationID,
+		ProjectID:      projectID,
 	}, nil
 }
 
+// removeUploads deletes the files of an upload whose job was not recorded,
+// such as one the quota rejected under the lock or a batch in which a later
+// image could not be stored. It is best effort: leftovers
+// are not counted as usage and go with the account, under the user prefix.
+func (s *Service) removeUploads(ctx context.Context, objectNames ...string) {
+	for _, objectName := range objectNames {
+		_ = s.minioClient.RemoveObject(ctx, s.cfg.Minio.UAVDataBucketName, objectName)
+	}
+}
+
+func (s *Service) requireVerifiedEmail(ctx context.Context, userID uuid.UUID) error {
+	verified, err := s.queries.IsEmailVerified(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("не удалось проверить подтверждение email: %w", err)
+	}
+	if !verified {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// GetHeightmapJob returns a job the user uploaded or one shared with them
+// through an organization they are a member of.
 func (s *Service) GetHeightmapJob(ctx context.Context, jobID uuid.UUID, userID uuid.UUID) (*HeightmapJob, error) {
-	job, err := s.queries.GetHeightmapJobByUserID(ctx, sqlc.GetHeightmapJobByUserIDParams{
+	job, err := s.queries.GetAccessibleHeightmapJob(ctx, sqlc.GetAccessibleHeightmapJobParams{
 		ID:     jobID,
 		UserID: userID,
 	})
@@ -113,18 +161,12 @@ func (s *Service) GetHeightmapJob(ctx context.Context, jobID uuid.UUID, userID u
 		return nil, fmt.Errorf("карта высот не найдена: %w", err)
 	}
 
-	result := &HeightmapJob{
-		ID:             job.ID,
-		UserID:         job.UserID,
-		ImageURL:       job.ImageUrl,
-		ResultURL:      job.ResultUrl,
-		Status:         job.Status,
-		Width:          job.Width,
-		Height:         job.Height,
-		ErrorMessage:   job.ErrorMessage,
-		ProcessingTime: job.ProcessingTime,
-		CreatedAt:      job.CreatedAt,
-		UpdatedAt:      job.UpdatedAt,
+	result := newHeightmapJob(job)
+	if job.Status == "pending" && job.DispatchedAt == nil {
+		result.QueuePosition, err = s.queuePosition(ctx, JobTypeHeightmap, job.ID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return result, nil
@@ -142,21 +184,7 @@ func (s *Service) ListUserHeightmaps(ctx context.Context, userID uuid.UUID, limi
 
 	result := make([]*HeightmapJob, 0, len(jobs))
 	for _, job := range jobs {
-		hm := &HeightmapJob{
-			ID:             job.ID,
-			UserID:         job.UserID,
-			ImageURL:       job.ImageUrl,
-			ResultURL:      job.ResultUrl,
-			Status:         job.Status,
-			Width:          job.Width,
-			Height:         job.Height,
-			ErrorMessage:   job.ErrorMessage,
-			ProcessingTime: job.ProcessingTime,
-			CreatedAt:      job.CreatedAt,
-			UpdatedAt:      job.UpdatedAt,
-		}
-
-		result = append(result, hm)
+		result = append(result, newHeightmapJob(job))
 	}
 
 	return result, nil
@@ -170,7 +198,7 @@ func (s *Service) GetProfile(ctx context.Context, id uuid.UUID, startPoint, endP
 	return nil, fmt.Errorf("not implemented")
 }
 
-func (s *Service) BatchUploadPhotos(ctx context.Context, userID uuid.UUID, files []*multipart.FileHeader, mergeMethod string, fastMode bool, generationMode string) (*BatchUploadResponse, error) {
+func (s *Service) BatchUploadPhotos(ctx context.Context, userID uuid.UUID, organizationID, projectID *uuid.UUID, files []*multipart.FileHeader, mergeMethod string, fastMode bool, generationMode string, priority int32, scheduledAt *time.Time) (*BatchUploadResponse, error) {
 	start := time.Now()
 	metrics.RecordProcessingJob()
 
@@ -178,10 +206,6 @@ func (s *Service) BatchUploadPhotos(ctx context.Context, userID uuid.UUID, files
 		return nil, fmt.Errorf("файлы не предоставлены")
 	}
 
-	if len(files) > 50 {
-		return nil, fmt.Errorf("слишком много файлов: максимум 50 файлов в пакете")
-	}
-
 	if mergeMethod == "" {
 		mergeMethod = "medium"
 	}
@@ -200,40 +224,67 @@ func (s *Service) BatchUploadPhotos(ctx context.Context, userID uuid.UUID, files
 		return nil, fmt.Errorf("для генерации ортофотоплана требуется минимум 5 изображений, предоставлено: %d", len(files))
 	}
 
+	if err := s.requireVerifiedEmail(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	organizationID, err := s.projectOrganization(ctx, projectID, organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireOrganizationMember(ctx, organizationID, userID); err != nil {
+		return nil, err
+	}
+
+	var totalBytes int64
+	for _, fileHeader := range files {
+		totalBytes += fileHeader.Size
+	}
+	quota := upload{images: len(files), bytes: totalBytes, batch: true, priority: priority}
+	if err := s.checkQuota(ctx, s.queries, userID, quota); err != nil {
+		return nil, err
+	}
+
 	batchJobID := uuid.New()
 	now := time.Now()
 
 	batchJob := sqlc.CreateBatchHeightmapJobParams{
 		ID:             batchJobID,
 		UserID:         userID,
-		Status:         "pending",
+		Status:         initialStatus(scheduledAt),
 		ImageCount:     int32(len(files)),
 		MergeMethod:    mergeMethod,
 		GenerationMode: generationMode,
+		FastMode:       fastMode,
+		Priority:       priority,
+		ScheduledAt:    scheduledAt,
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		OrganizationID: organizationID,
+		ProjectID:      projectID,
 	}
 
-	if _, err := s.queries.CreateBatchHeightmapJob(ctx, batchJob); err != nil {
-		return nil, fmt.Errorf("не удалось создать пакетную задачу в базе данных: %w", err)
-	}
-
-	imageURLs := make([]string, 0, len(files))
+	batchImages := make([]sqlc.CreateBatchImageParams, 0, len(files))
+	objectNames := make([]string, 0, len(files))
 
 	for idx, fileHeader := range files {
 		file, err := fileHeader.Open()
 		if err != nil {
+			s.removeUploads(ctx, objectNames...)
 			return nil, fmt.Errorf("не удалось открыть файл %s: %w", fileHeader.Filename, err)
 		}
 
 		ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
 		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
 			file.Close()
+			s.removeUploads(ctx, objectNames...)
 			return nil, fmt.Errorf("неподдерживаемый формат файла %s: %s", fileHeader.Filename, ext)
 		}
 
 		if fileHeader.Size > 100*1024*1024 {
 			file.Close()
+			s.removeUploads(ctx, objectNames...)
 			return nil, fmt.Errorf("файл %s слишком большой: максимум 100МБ", fileHeader.Filename)
 		}
 
@@ -242,12 +293,13 @@ func (s *Service) BatchUploadPhotos(ctx context.Context, userID uuid.UUID, files
 
 		if err := s.minioClient.UploadFile(ctx, s.cfg.Minio.UAVDataBucketName, objectName, file, fileHeader.Size, fileHeader.Header.Get("Content-Type")); err != nil {
 			file.Close()
+			s.removeUploads(ctx, objectNames...)
 			return nil, fmt.Errorf("не удалось загрузить файл %s в хранилище: %w", fileHeader.Filename, err)
 		}
 		file.Close()
+		objectNames = append(objectNames, objectName)
 
 		imageURL := fmt.Sprintf("%s/%s/%s", s.cfg.Minio.PublicURL, s.cfg.Minio.UAVDataBucketName, objectName)
-		imageURLs = append(imageURLs, imageURL)
 
 		batchImage := sqlc.CreateBatchImageParams{
 			ID:        imageID,
@@ -257,52 +309,46 @@ func (s *Service) BatchUploadPhotos(ctx context.Context, userID uuid.UUID, files
 		}
 		batchImage.BatchJobID.Bytes = batchJobID
 		batchImage.BatchJobID.Valid = true
-
-		if _, err := s.queries.CreateBatchImage(ctx, batchImage); err != nil {
-			return nil, fmt.Errorf("не удалось создать запись изображения в базе данных: %w", err)
-		}
+		batchImages = append(batchImages, batchImage)
 	}
 
-	task := &rabbitmq.BatchHeightmapTask{
-		BatchJobID:     batchJobID.String(),
-		UserID:         userID.String(),
-		ImageURLs:      imageURLs,
-		OutputBucket:   s.cfg.Minio.UAVModelsBucketName,
-		MinioEndpoint:  s.cfg.Minio.Endpoint,
-		MinioPublicURL: s.cfg.Minio.PublicURL,
-		MinioAccessKey: s.cfg.Minio.AccessKeyID,
-		MinioSecretKey: s.cfg.Minio.SecretAccessKey,
-		MinioUseSSL:    s.cfg.Minio.UseSSL,
-		MergeMethod:    mergeMethod,
-		FastMode:       fastMode,
-		GenerationMode: generationMode,
-		CreatedAt:      now,
-		Priority:       0,
-	}
-
-	if err := s.rabbitmqClient.PublishBatchTask(ctx, task); err != nil {
-		now := time.Now()
-		errMsg := fmt.Sprintf("не удалось отправить пакетную задачу в очередь: %v", err)
-		_ = s.queries.UpdateBatchJobError(ctx, sqlc.UpdateBatchJobErrorParams{
-			ID:           batchJobID,
-			ErrorMessage: &errMsg,
-			UpdatedAt:    now,
-		})
-		return nil, fmt.Errorf("не удалось отправить пакетную задачу: %w", err)
+	// The job and its images are committed together, so a crash can no longer
+	// leave a half-created batch behind for the dispatcher to pick up.
+	err = s.withTx(ctx, func(q QueriesInterface) error {
+		if err := s.reserveQuota(ctx, q, userID, quota); err != nil {
+			return err
+		}
+		if _, err := q.CreateBatchHeightmapJob(ctx, batchJob); err != nil {
+			return fmt.Errorf("не удалось создать пакетную задачу в базе данных: %w", err)
+		}
+		for _, batchImage := range batchImages {
+			if _, err := q.CreateBatchImage(ctx, batchImage); err != nil {
+				return fmt.Errorf("не удалось создать запись изображения в базе данных: %w", err)
+			}
+		}
+		return recordStorageUsage(ctx, q, userID, JobTypeBatch, batchJobID, totalBytes)
+	})
+	if err != nil {
+		s.removeUploads(ctx, objectNames...)
+		return nil, err
 	}
 
 	metrics.RecordProcessingJobDuration(time.Since(start))
 
 	return &BatchUploadResponse{
-		ID:          batchJobID,
-		Status:      "pending",
-		ImageCount:  len(files),
-		MergeMethod: mergeMethod,
+		ID:             batchJobID,
+		Status:         batchJob.Status,
+		ImageCount:     len(files),
+		MergeMethod:    mergeMethod,
+		Priority:       priorityClass(priority),
+		ScheduledAt:    scheduledAt,
+		OrganizationID: organizationID,
+		ProjectID:      projectID,
 	}, nil
 }
 
 func (s *Service) GetBatchHeightmapJob(ctx context.Context, batchJobID uuid.UUID, userID uuid.UUID) (*BatchHeightmapJob, error) {
-	job, err := s.queries.GetBatchHeightmapJobByUserID(ctx, sqlc.GetBatchHeightmapJobByUserIDParams{
+	job, err := s.queries.GetAccessibleBatchHeightmapJob(ctx, sqlc.GetAccessibleBatchHeightmapJobParams{
 		ID:     batchJobID,
 		UserID: userID,
 	})
@@ -310,21 +356,12 @@ func (s *Service) GetBatchHeightmapJob(ctx context.Context, batchJobID uuid.UUID
 		return nil, fmt.Errorf("пакетная карта высот не найдена: %w", err)
 	}
 
-	result := &BatchHeightmapJob{
-		ID:             job.ID,
-		UserID:         job.UserID,
-		Status:         job.Status,
-		ResultURL:      job.ResultUrl,
-		OrthophotoURL:  job.OrthophotoUrl,
-		Width:          job.Width,
-		Height:         job.Height,
-		ImageCount:     job.ImageCount,
-		ProcessedCount: job.ProcessedCount,
-		ErrorMessage:   job.ErrorMessage,
-		ProcessingTime: job.ProcessingTime,
-		MergeMethod:    job.MergeMethod,
-		CreatedAt:      job.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:      job.UpdatedAt.Format(time.RFC3339),
+	result := newBatchHeightmapJob(job)
+	if job.Status == "pending" && job.DispatchedAt == nil {
+		result.QueuePosition, err = s.queuePosition(ctx, JobTypeBatch, job.ID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return result, nil
@@ -342,24 +379,7 @@ func (s *Service) ListUserBatchHeightmaps(ctx context.Context, userID uuid.UUID,
 
 	result := make([]*BatchHeightmapJob, 0, len(jobs))
 	for _, job := range jobs {
-		hm := &BatchHeightmapJob{
-			ID:             job.ID,
-			UserID:         job.UserID,
-			Status:         job.Status,
-			ResultURL:      job.ResultUrl,
-			OrthophotoURL:  job.OrthophotoUrl,
-			Width:          job.Width,
-			Height:         job.Height,
-			ImageCount:     job.ImageCount,
-			ProcessedCount: job.ProcessedCount,
-			ErrorMessage:   job.ErrorMessage,
-			ProcessingTime: job.ProcessingTime,
-			MergeMethod:    job.MergeMethod,
-			CreatedAt:      job.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:      job.UpdatedAt.Format(time.RFC3339),
-		}
-
-		result = append(result, hm)
+		result = append(result, newBatchHeightmapJob(job))
 	}
 
 	return result, nil
@@ -1,26 +1,121 @@
 package heightmap
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
+	"mime/multipart"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/minio"
 	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
 )
 
 type mockQueries struct {
-	createJobFunc       func(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error)
-	getJobFunc          func(ctx context.Context, id uuid.UUID) (sqlc.HeightmapJob, error)
-	getJobByUserIDFunc  func(ctx context.Context, params sqlc.GetHeightmapJobByUserIDParams) (sqlc.HeightmapJob, error)
-	listUserHeightmaps  func(ctx context.Context, params sqlc.ListUserHeightmapsParams) ([]sqlc.HeightmapJob, error)
-	updateJobStatusFunc func(ctx context.Context, params sqlc.UpdateJobStatusParams) error
-	updateJobResultFunc func(ctx context.Context, params sqlc.UpdateJobResultParams) error
-	updateJobErrorFunc  func(ctx context.Context, params sqlc.UpdateJobErrorParams) error
+	isEmailVerifiedFunc func(ctx context.Context, id uuid.UUID) (bool, error)
+	memberRoleFunc      func(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error)
+	getProjectFunc      func(ctx context.Context, params sqlc.GetAccessibleProjectParams) (sqlc.Project, error)
+
+	createJobFunc         func(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error)
+	getJobFunc            func(ctx context.Context, id uuid.UUID) (sqlc.HeightmapJob, error)
+	lockJobStatusFunc     func(ctx context.Context, id uuid.UUID) (string, error)
+	getAccessibleJobFunc  func(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error)
+	listOrgHeightmaps     func(ctx context.Context, params sqlc.ListOrganizationHeightmapsParams) ([]sqlc.HeightmapJob, error)
+	listUserHeightmaps    func(ctx context.Context, params sqlc.ListUserHeightmapsParams) ([]sqlc.HeightmapJob, error)
+	listUserByStatus      func(ctx context.Context, params sqlc.ListUserHeightmapsByStatusParams) ([]sqlc.HeightmapJob, error)
+	listHeightmaps        func(ctx context.Context, params sqlc.ListHeightmapsParams) ([]sqlc.HeightmapJob, error)
+	listByStatus          func(ctx context.Context, params sqlc.ListHeightmapsByStatusParams) ([]sqlc.HeightmapJob, error)
+	updateJobStatusFunc   func(ctx context.Context, params sqlc.UpdateJobStatusParams) error
+	updateJobResultFunc   func(ctx context.Context, params sqlc.UpdateJobResultParams) error
+	updateJobErrorFunc    func(ctx context.Context, params sqlc.UpdateJobErrorParams) error
+	listStaleJobsFunc     func(ctx context.Context, params sqlc.ListStaleHeightmapJobsParams) ([]sqlc.HeightmapJob, error)
+	countStaleJobsFunc    func(ctx context.Context, params sqlc.CountStaleHeightmapJobsParams) (int64, error)
+	requeueJobFunc        func(ctx context.Context, params sqlc.RequeueHeightmapJobParams) error
+	countInFlightFunc     func(ctx context.Context) (int64, error)
+	listWaitingJobsFunc   func(ctx context.Context, limit int32) ([]sqlc.HeightmapJob, error)
+	queuePositionFunc     func(ctx context.Context, id uuid.UUID) (int64, error)
+	markDispatchedFunc    func(ctx context.Context, params sqlc.MarkHeightmapJobDispatchedParams) (int64, error)
+	releaseDueJobsFunc    func(ctx context.Context, params sqlc.ReleaseDueHeightmapJobsParams) ([]sqlc.HeightmapJob, error)
+	rescheduleJobFunc     func(ctx context.Context, params sqlc.RescheduleHeightmapJobParams) (int64, error)
+	cancelJobFunc         func(ctx context.Context, params sqlc.CancelHeightmapJobParams) (int64, error)
+	deleteFinishedJobFunc func(ctx context.Context, params sqlc.DeleteFinishedHeightmapJobParams) (sqlc.HeightmapJob, error)
+
+	getBatchJobFunc            func(ctx context.Context, id uuid.UUID) (sqlc.BatchHeightmapJob, error)
+	lockBatchJobStatusFunc     func(ctx context.Context, id uuid.UUID) (string, error)
+	getBatchImagesFunc         func(ctx context.Context, batchJobID pgtype.UUID) ([]sqlc.BatchImage, error)
+	listBatchHeightmaps        func(ctx context.Context, params sqlc.ListBatchHeightmapsParams) ([]sqlc.BatchHeightmapJob, error)
+	listBatchByStatus          func(ctx context.Context, params sqlc.ListBatchHeightmapsByStatusParams) ([]sqlc.BatchHeightmapJob, error)
+	listUserBatchByStatus      func(ctx context.Context, params sqlc.ListUserBatchHeightmapsByStatusParams) ([]sqlc.BatchHeightmapJob, error)
+	getAccessibleBatchJobFunc  func(ctx context.Context, params sqlc.GetAccessibleBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error)
+	updateBatchJobStatusFunc   func(ctx context.Context, params sqlc.UpdateBatchJobStatusParams) error
+	updateBatchJobResultFunc   func(ctx context.Context, params sqlc.UpdateBatchJobResultParams) error
+	updateBatchJobErrorFunc    func(ctx context.Context, params sqlc.UpdateBatchJobErrorParams) error
+	updateBatchJobProgressFunc func(ctx context.Context, params sqlc.UpdateBatchJobProgressParams) error
+	listStaleBatchJobsFunc     func(ctx context.Context, params sqlc.ListStaleBatchHeightmapJobsParams) ([]sqlc.BatchHeightmapJob, error)
+	countStaleBatchJobsFunc    func(ctx context.Context, params sqlc.CountStaleBatchHeightmapJobsParams) (int64, error)
+	requeueBatchJobFunc        func(ctx context.Context, params sqlc.RequeueBatchHeightmapJobParams) error
+	countInFlightBatchFunc     func(ctx context.Context) (int64, error)
+	listWaitingBatchJobsFunc   func(ctx context.Context, limit int32) ([]sqlc.BatchHeightmapJob, error)
+	batchQueuePositionFunc     func(ctx context.Context, id uuid.UUID) (int64, error)
+	markBatchDispatchedFunc    func(ctx context.Context, params sqlc.MarkBatchHeightmapJobDispatchedParams) (int64, error)
+	releaseDueBatchJobsFunc    func(ctx context.Context, params sqlc.ReleaseDueBatchHeightmapJobsParams) ([]sqlc.BatchHeightmapJob, error)
+	rescheduleBatchJobFunc     func(ctx context.Context, params sqlc.RescheduleBatchHeightmapJobParams) (int64, error)
+	cancelBatchJobFunc         func(ctx context.Context, params sqlc.CancelBatchHeightmapJobParams) (int64, error)
+	deleteFinishedBatchJobFunc func(ctx context.Context, params sqlc.DeleteFinishedBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error)
+
+	createJobEventFunc         func(ctx context.Context, params sqlc.CreateJobEventParams) (sqlc.JobEvent, error)
+	listUserJobEventsAfterFunc func(ctx context.Context, params sqlc.ListUserJobEventsAfterParams) ([]sqlc.JobEvent, error)
+	listJobEventsFunc          func(ctx context.Context, jobID pgtype.UUID) ([]sqlc.JobEvent, error)
+
+	createOutboxMessageFunc func(ctx context.Context, params sqlc.CreateOutboxMessageParams) (sqlc.OutboxMessage, error)
+
+	countShareDenialsFunc   func(ctx context.Context, params sqlc.CountJobShareDenialsSinceParams) (int64, error)
+	createJobShareFunc      func(ctx context.Context, params sqlc.CreateJobShareParams) (sqlc.JobShare, error)
+	createShareAccessFunc   func(ctx context.Context, params sqlc.CreateJobShareAccessParams) error
+	getShareByTokenHashFunc func(ctx context.Context, tokenHash string) (sqlc.JobShare, error)
+	jobShareExistsFunc      func(ctx context.Context, params sqlc.JobShareExistsParams) (bool, error)
+	listBatchJobSharesFunc  func(ctx context.Context, batchJobID *uuid.UUID) ([]sqlc.JobShare, error)
+	listHeightmapSharesFunc func(ctx context.Context, heightmapJobID *uuid.UUID) ([]sqlc.JobShare, error)
+	listShareAccessesFunc   func(ctx context.Context, params sqlc.ListJobShareAccessesParams) ([]sqlc.JobShareAccess, error)
+	revokeJobShareFunc      func(ctx context.Context, params sqlc.RevokeJobShareParams) (int64, error)
+
+	getUserQuotaPlanFunc    func(ctx context.Context, userID uuid.UUID) (sqlc.QuotaPlan, error)
+	getUserUsageFunc        func(ctx context.Context, params sqlc.GetUserUsageParams) (sqlc.GetUserUsageRow, error)
+	createStorageUsageFunc  func(ctx context.Context, params sqlc.CreateStorageUsageParams) error
+	deleteJobUsageFunc      func(ctx context.Context, heightmapJobID, batchJobID *uuid.UUID) error
+	getQuotaPlanFunc        func(ctx context.Context, name string) (sqlc.QuotaPlan, error)
+	setUserQuotaPlanFunc    func(ctx context.Context, params sqlc.SetUserQuotaPlanParams) (int64, error)
+	lockUserQuotaFunc       func(ctx context.Context, userID uuid.UUID) error
+	scheduleJobDeletionFunc func(ctx context.Context, params sqlc.ScheduleJobStorageDeletionParams) error
+
+	tryAdvisoryXactLockFunc func(ctx context.Context, key int64) (bool, error)
+}
+
+func (m *mockQueries) IsEmailVerified(ctx context.Context, id uuid.UUID) (bool, error) {
+	if m.isEmailVerifiedFunc != nil {
+		return m.isEmailVerifiedFunc(ctx, id)
+	}
+	return true, nil
+}
+
+func (m *mockQueries) GetOrganizationMemberRole(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error) {
+	if m.memberRoleFunc != nil {
+		return m.memberRoleFunc(ctx, params)
+	}
+	return "", pgx.ErrNoRows
+}
+
+func (m *mockQueries) GetAccessibleProject(ctx context.Context, params sqlc.GetAccessibleProjectParams) (sqlc.Project, error) {
+	if m.getProjectFunc != nil {
+		return m.getProjectFunc(ctx, params)
+	}
+	return sqlc.Project{}, pgx.ErrNoRows
 }
 
 func (m *mockQueries) CreateHeightmapJob(ctx context.Context, params sqlc.CreateHeightmapJobParams) (sqlc.HeightmapJob, error) {
@@ -37,13 +132,31 @@ func (m *mockQueries) GetHeightmapJob(ctx context.Context, id uuid.UUID) (sqlc.H
 	return sqlc.HeightmapJob{}, nil
 }
 
-func (m *mockQueries) GetHeightmapJobByUserID(ctx context.Context, params sqlc.GetHeightmapJobByUserIDParams) (sqlc.HeightmapJob, error) {
-	if m.getJobByUserIDFunc != nil {
-		return m.getJobByUserIDFunc(ctx, params)
+func (m *mockQueries) LockHeightmapJobStatus(ctx context.Context, id uuid.UUID) (string, error) {
+	if m.lockJobStatusFunc != nil {
+		return m.lockJobStatusFunc(ctx, id)
+	}
+	return "processing", nil
+}
+
+func (m *mockQueries) GetAccessibleHeightmapJob(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error) {
+	if m.getAccessibleJobFunc != nil {
+		return m.getAccessibleJobFunc(ctx, params)
 	}
 	return sqlc.HeightmapJob{}, nil
 }
 
+func (m *mockQueries) ListOrganizationHeightmaps(ctx context.Context, params sqlc.ListOrganizationHeightmapsParams) ([]sqlc.HeightmapJob, error) {
+	if m.listOrgHeightmaps != nil {
+		return m.listOrgHeightmaps(ctx, params)
+	}
+	return []sqlc.HeightmapJob{}, nil
+}
+
+func (m *mockQueries) ListProjectHeightmaps(ctx context.Context, params sqlc.ListProjectHeightmapsParams) ([]sqlc.HeightmapJob, error) {
+	return []sqlc.HeightmapJob{}, nil
+}
+
 func (m *mockQueries) ListUserHeightmaps(ctx context.Context, params sqlc.ListUserHeightmapsParams) ([]sqlc.HeightmapJob, error) {
 	if m.listUserHeightmaps != nil {
 		return m.listUserHeightmaps(ctx, params)
@@ -51,6 +164,27 @@ func (m *mockQueries) ListUserHeightmaps(ctx context.Context, params sqlc.ListUs
 	return []sqlc.HeightmapJob{}, nil
 }
 
+func (m *mockQueries) ListUserHeightmapsByStatus(ctx context.Context, params sqlc.ListUserHeightmapsByStatusParams) ([]sqlc.HeightmapJob, error) {
+	if m.listUserByStatus != nil {
+		return m.listUserByStatus(ctx, params)
+	}
+	return []sqlc.HeightmapJob{}, nil
+}
+
+func (m *mockQueries) ListHeightmaps(ctx context.Context, params sqlc.ListHeightmapsParams) ([]sqlc.HeightmapJob, error) {
+	if m.listHeightmaps != nil {
+		return m.listHeightmaps(ctx, params)
+	}
+	return []sqlc.HeightmapJob{}, nil
+}
+
+func (m *mockQueries) ListHeightmapsByStatus(ctx context.Context, params sqlc.ListHeightmapsByStatusParams) ([]sqlc.HeightmapJob, error) {
+	if m.listByStatus != nil {
+		return m.listByStatus(ctx, params)
+	}
+	return []sqlc.HeightmapJob{}, nil
+}
+
 func (m *mockQueries) UpdateJobStatus(ctx context.Context, params sqlc.UpdateJobStatusParams) error {
 	if m.updateJobStatusFunc != nil {
 		return m.updateJobStatusFunc(ctx, params)
@@ -72,6 +206,83 @@ func (m *mockQueries) UpdateJobError(ctx context.Context, params sqlc.UpdateJobE
 	return nil
 }
 
+func (m *mockQueries) ListStaleHeightmapJobs(ctx context.Context, params sqlc.ListStaleHeightmapJobsParams) ([]sqlc.HeightmapJob, error) {
+	if m.listStaleJobsFunc != nil {
+		return m.listStaleJobsFunc(ctx, params)
+	}
+	return []sqlc.HeightmapJob{}, nil
+}
+
+func (m *mockQueries) CountStaleHeightmapJobs(ctx context.Context, params sqlc.CountStaleHeightmapJobsParams) (int64, error) {
+	if m.countStaleJobsFunc != nil {
+		return m.countStaleJobsFunc(ctx, params)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) RequeueHeightmapJob(ctx context.Context, params sqlc.RequeueHeightmapJobParams) error {
+	if m.requeueJobFunc != nil {
+		return m.requeueJobFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) CountInFlightHeightmapJobs(ctx context.Context) (int64, error) {
+	if m.countInFlightFunc != nil {
+		return m.countInFlightFunc(ctx)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) ListWaitingHeightmapJobs(ctx context.Context, limit int32) ([]sqlc.HeightmapJob, error) {
+	if m.listWaitingJobsFunc != nil {
+		return m.listWaitingJobsFunc(ctx, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockQueries) GetHeightmapJobQueuePosition(ctx context.Context, id uuid.UUID) (int64, error) {
+	if m.queuePositionFunc != nil {
+		return m.queuePositionFunc(ctx, id)
+	}
+	return 0, pgx.ErrNoRows
+}
+
+func (m *mockQueries) MarkHeightmapJobDispatched(ctx context.Context, params sqlc.MarkHeightmapJobDispatchedParams) (int64, error) {
+	if m.markDispatchedFunc != nil {
+		return m.markDispatchedFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) ReleaseDueHeightmapJobs(ctx context.Context, params sqlc.ReleaseDueHeightmapJobsParams) ([]sqlc.HeightmapJob, error) {
+	if m.releaseDueJobsFunc != nil {
+		return m.releaseDueJobsFunc(ctx, params)
+	}
+	return nil, nil
+}
+
+func (m *mockQueries) RescheduleHeightmapJob(ctx context.Context, params sqlc.RescheduleHeightmapJobParams) (int64, error) {
+	if m.rescheduleJobFunc != nil {
+		return m.rescheduleJobFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) CancelHeightmapJob(ctx context.Context, params sqlc.CancelHeightmapJobParams) (int64, error) {
+	if m.cancelJobFunc != nil {
+		return m.cancelJobFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) DeleteFinishedHeightmapJob(ctx context.Context, params sqlc.DeleteFinishedHeightmapJobParams) (sqlc.HeightmapJob, error) {
+	if m.deleteFinishedJobFunc != nil {
+		return m.deleteFinishedJobFunc(ctx, params)
+	}
+	return sqlc.HeightmapJob{}, pgx.ErrNoRows
+}
+
 func (m *mockQueries) CreateBatchHeightmapJob(ctx context.Context, params sqlc.CreateBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error) {
 	return sqlc.BatchHeightmapJob{}, nil
 }
@@ -81,10 +292,23 @@ func (m *mockQueries) CreateBatchImage(ctx context.Context, params sqlc.CreateBa
 }
 
 func (m *mockQueries) GetBatchHeightmapJob(ctx context.Context, id uuid.UUID) (sqlc.BatchHeightmapJob, error) {
+	if m.getBatchJobFunc != nil {
+		return m.getBatchJobFunc(ctx, id)
+	}
 	return sqlc.BatchHeightmapJob{}, nil
 }
 
-func (m *mockQueries) GetBatchHeightmapJobByUserID(ctx context.Context, params sqlc.GetBatchHeightmapJobByUserIDParams) (sqlc.BatchHeightmapJob, error) {
+func (m *mockQueries) LockBatchHeightmapJobStatus(ctx context.Context, id uuid.UUID) (string, error) {
+	if m.lockBatchJobStatusFunc != nil {
+		return m.lockBatchJobStatusFunc(ctx, id)
+	}
+	return "processing", nil
+}
+
+func (m *mockQueries) GetAccessibleBatchHeightmapJob(ctx context.Context, params sqlc.GetAccessibleBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error) {
+	if m.getAccessibleBatchJobFunc != nil {
+		return m.getAccessibleBatchJobFunc(ctx, params)
+	}
 	return sqlc.BatchHeightmapJob{}, nil
 }
 
@@ -92,23 +316,67 @@ func (m *mockQueries) ListUserBatchHeightmaps(ctx context.Context, params sqlc.L
 	return []sqlc.BatchHeightmapJob{}, nil
 }
 
+func (m *mockQueries) ListOrganizationBatchHeightmaps(ctx context.Context, params sqlc.ListOrganizationBatchHeightmapsParams) ([]sqlc.BatchHeightmapJob, error) {
+	return []sqlc.BatchHeightmapJob{}, nil
+}
+
+func (m *mockQueries) ListProjectBatchHeightmaps(ctx context.Context, params sqlc.ListProjectBatchHeightmapsParams) ([]sqlc.BatchHeightmapJob, error) {
+	return []sqlc.BatchHeightmapJob{}, nil
+}
+
+func (m *mockQueries) ListUserBatchHeightmapsByStatus(ctx context.Context, params sqlc.ListUserBatchHeightmapsByStatusParams) ([]sqlc.BatchHeightmapJob, error) {
+	if m.listUserBatchByStatus != nil {
+		return m.listUserBatchByStatus(ctx, params)
+	}
+	return []sqlc.BatchHeightmapJob{}, nil
+}
+
+func (m *mockQueries) ListBatchHeightmaps(ctx context.Context, params sqlc.ListBatchHeightmapsParams) ([]sqlc.BatchHeightmapJob, error) {
+	if m.listBatchHeightmaps != nil {
+		return m.listBatchHeightmaps(ctx, params)
+	}
+	return []sqlc.BatchHeightmapJob{}, nil
+}
+
+func (m *mockQueries) ListBatchHeightmapsByStatus(ctx context.Context, params sqlc.ListBatchHeightmapsByStatusParams) ([]sqlc.BatchHeightmapJob, error) {
+	if m.listBatchByStatus != nil {
+		return m.listBatchByStatus(ctx, params)
+	}
+	return []sqlc.BatchHeightmapJob{}, nil
+}
+
 func (m *mockQueries) UpdateBatchJobStatus(ctx context.Context, params sqlc.UpdateBatchJobStatusParams) error {
+	if m.updateBatchJobStatusFunc != nil {
+		return m.updateBatchJobStatusFunc(ctx, params)
+	}
 	return nil
 }
 
 func (m *mockQueries) UpdateBatchJobResult(ctx context.Context, params sqlc.UpdateBatchJobResultParams) error {
+	if m.updateBatchJobResultFunc != nil {
+		return m.updateBatchJobResultFunc(ctx, params)
+	}
 	return nil
 }
 
 func (m *mockQueries) UpdateBatchJobError(ctx context.Context, params sqlc.UpdateBatchJobErrorParams) error {
+	if m.updateBatchJobErrorFunc != nil {
+		return m.updateBatchJobErrorFunc(ctx, params)
+	}
 	return nil
 }
 
 func (m *mockQueries) UpdateBatchJobProgress(ctx context.Context, params sqlc.UpdateBatchJobProgressParams) error {
+	if m.updateBatchJobProgressFunc != nil {
+		return m.updateBatchJobProgressFunc(ctx, params)
+	}
 	return nil
 }
 
 func (m *mockQueries) GetBatchImages(ctx context.Context, batchJobID pgtype.UUID) ([]sqlc.BatchImage, error) {
+	if m.getBatchImagesFunc != nil {
+		return m.getBatchImagesFunc(ctx, batchJobID)
+	}
 	return []sqlc.BatchImage{}, nil
 }
 
@@ -116,10 +384,263 @@ func (m *mockQueries) UpdateBatchImageStatus(ctx context.Context, params sqlc.Up
 	return nil
 }
 
+func (m *mockQueries) ListStaleBatchHeightmapJobs(ctx context.Context, params sqlc.ListStaleBatchHeightmapJobsParams) ([]sqlc.BatchHeightmapJob, error) {
+	if m.listStaleBatchJobsFunc != nil {
+		return m.listStaleBatchJobsFunc(ctx, params)
+	}
+	return []sqlc.BatchHeightmapJob{}, nil
+}
+
+func (m *mockQueries) CountStaleBatchHeightmapJobs(ctx context.Context, params sqlc.CountStaleBatchHeightmapJobsParams) (int64, error) {
+	if m.countStaleBatchJobsFunc != nil {
+		return m.countStaleBatchJobsFunc(ctx, params)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) RequeueBatchHeightmapJob(ctx context.Context, params sqlc.RequeueBatchHeightmapJobParams) error {
+	if m.requeueBatchJobFunc != nil {
+		return m.requeueBatchJobFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) CountInFlightBatchHeightmapJobs(ctx context.Context) (int64, error) {
+	if m.countInFlightBatchFunc != nil {
+		return m.countInFlightBatchFunc(ctx)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) ListWaitingBatchHeightmapJobs(ctx context.Context, limit int32) ([]sqlc.BatchHeightmapJob, error) {
+	if m.listWaitingBatchJobsFunc != nil {
+		return m.listWaitingBatchJobsFunc(ctx, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockQueries) GetBatchHeightmapJobQueuePosition(ctx context.Context, id uuid.UUID) (int64, error) {
+	if m.batchQueuePositionFunc != nil {
+		return m.batchQueuePositionFunc(ctx, id)
+	}
+	return 0, pgx.ErrNoRows
+}
+
+func (m *mockQueries) MarkBatchHeightmapJobDispatched(ctx context.Context, params sqlc.MarkBatchHeightmapJobDispatchedParams) (int64, error) {
+	if m.markBatchDispatchedFunc != nil {
+		return m.markBatchDispatchedFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) ReleaseDueBatchHeightmapJobs(ctx context.Context, params sqlc.ReleaseDueBatchHeightmapJobsParams) ([]sqlc.BatchHeightmapJob, error) {
+	if m.releaseDueBatchJobsFunc != nil {
+		return m.releaseDueBatchJobsFunc(ctx, params)
+	}
+	return nil, nil
+}
+
+func (m *mockQueries) RescheduleBatchHeightmapJob(ctx context.Context, params sqlc.RescheduleBatchHeightmapJobParams) (int64, error) {
+	if m.rescheduleBatchJobFunc != nil {
+		return m.rescheduleBatchJobFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) CancelBatchHeightmapJob(ctx context.Context, params sqlc.CancelBatchHeightmapJobParams) (int64, error) {
+	if m.cancelBatchJobFunc != nil {
+		return m.cancelBatchJobFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) DeleteFinishedBatchHeightmapJob(ctx context.Context, params sqlc.DeleteFinishedBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error) {
+	if m.deleteFinishedBatchJobFunc != nil {
+		return m.deleteFinishedBatchJobFunc(ctx, params)
+	}
+	return sqlc.BatchHeightmapJob{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) CreateJobEvent(ctx context.Context, params sqlc.CreateJobEventParams) (sqlc.JobEvent, error) {
+	if m.createJobEventFunc != nil {
+		return m.createJobEventFunc(ctx, params)
+	}
+	return sqlc.JobEvent{}, nil
+}
+
+func (m *mockQueries) ListActiveWebhooksForEvent(ctx context.Context, params sqlc.ListActiveWebhooksForEventParams) ([]sqlc.Webhook, error) {
+	return []sqlc.Webhook{}, nil
+}
+
+func (m *mockQueries) CreateWebhookDelivery(ctx context.Context, params sqlc.CreateWebhookDeliveryParams) (sqlc.WebhookDelivery, error) {
+	return sqlc.WebhookDelivery{}, nil
+}
+
+func (m *mockQueries) ListUserJobEventsAfter(ctx context.Context, params sqlc.ListUserJobEventsAfterParams) ([]sqlc.JobEvent, error) {
+	if m.listUserJobEventsAfterFunc != nil {
+		return m.listUserJobEventsAfterFunc(ctx, params)
+	}
+	return []sqlc.JobEvent{}, nil
+}
+
+func (m *mockQueries) ListJobEvents(ctx context.Context, jobID pgtype.UUID) ([]sqlc.JobEvent, error) {
+	if m.listJobEventsFunc != nil {
+		return m.listJobEventsFunc(ctx, jobID)
+	}
+	return []sqlc.JobEvent{}, nil
+}
+
+func (m *mockQueries) CreateOutboxMessage(ctx context.Context, params sqlc.CreateOutboxMessageParams) (sqlc.OutboxMessage, error) {
+	if m.createOutboxMessageFunc != nil {
+		return m.createOutboxMessageFunc(ctx, params)
+	}
+	return sqlc.OutboxMessage{}, nil
+}
+
+func (m *mockQueries) CountJobShareDenialsSince(ctx context.Context, params sqlc.CountJobShareDenialsSinceParams) (int64, error) {
+	if m.countShareDenialsFunc != nil {
+		return m.countShareDenialsFunc(ctx, params)
+	}
+	return 0, nil
+}
+
+func (m *mockQueries) CreateJobShare(ctx context.Context, params sqlc.CreateJobShareParams) (sqlc.JobShare, error) {
+	if m.createJobShareFunc != nil {
+		return m.createJobShareFunc(ctx, params)
+	}
+	return sqlc.JobShare{}, nil
+}
+
+func (m *mockQueries) CreateJobShareAccess(ctx context.Context, params sqlc.CreateJobShareAccessParams) error {
+	if m.createShareAccessFunc != nil {
+		return m.createShareAccessFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) GetJobShareByTokenHash(ctx context.Context, tokenHash string) (sqlc.JobShare, error) {
+	if m.getShareByTokenHashFunc != nil {
+		return m.getShareByTokenHashFunc(ctx, tokenHash)
+	}
+	return sqlc.JobShare{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) JobShareExists(ctx context.Context, params sqlc.JobShareExistsParams) (bool, error) {
+	if m.jobShareExistsFunc != nil {
+		return m.jobShareExistsFunc(ctx, params)
+	}
+	return false, nil
+}
+
+func (m *mockQueries) ListBatchJobShares(ctx context.Context, batchJobID *uuid.UUID) ([]sqlc.JobShare, error) {
+	if m.listBatchJobSharesFunc != nil {
+		return m.listBatchJobSharesFunc(ctx, batchJobID)
+	}
+	return []sqlc.JobShare{}, nil
+}
+
+func (m *mockQueries) ListHeightmapJobShares(ctx context.Context, heightmapJobID *uuid.UUID) ([]sqlc.JobShare, error) {
+	if m.listHeightmapSharesFunc != nil {
+		return m.listHeightmapSharesFunc(ctx, heightmapJobID)
+	}
+	return []sqlc.JobShare{}, nil
+}
+
+func (m *mockQueries) ListJobShareAccesses(ctx context.Context, params sqlc.ListJobShareAccessesParams) ([]sqlc.JobShareAccess, error) {
+	if m.listShareAccessesFunc != nil {
+		return m.listShareAccessesFunc(ctx, params)
+	}
+	return []sqlc.JobShareAccess{}, nil
+}
+
+func (m *mockQueries) RevokeJobShare(ctx context.Context, params sqlc.RevokeJobShareParams) (int64, error) {
+	if m.revokeJobShareFunc != nil {
+		return m.revokeJobShareFunc(ctx, params)
+	}
+	return 0, nil
+}
+
+// GetUserQuotaPlan defaults to a plan without limits.
+func (m *mockQueries) GetUserQuotaPlan(ctx context.Context, userID uuid.UUID) (sqlc.QuotaPlan, error) {
+	if m.getUserQuotaPlanFunc != nil {
+		return m.getUserQuotaPlanFunc(ctx, userID)
+	}
+	return sqlc.QuotaPlan{Name: "unlimited", MaxPriority: PriorityUrgent}, nil
+}
+
+func (m *mockQueries) GetUserUsage(ctx context.Context, params sqlc.GetUserUsageParams) (sqlc.GetUserUsageRow, error) {
+	if m.getUserUsageFunc != nil {
+		return m.getUserUsageFunc(ctx, params)
+	}
+	return sqlc.GetUserUsageRow{}, nil
+}
+
+func (m *mockQueries) LockUserQuota(ctx context.Context, userID uuid.UUID) error {
+	if m.lockUserQuotaFunc != nil {
+		return m.lockUserQuotaFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockQueries) ScheduleJobStorageDeletion(ctx context.Context, params sqlc.ScheduleJobStorageDeletionParams) error {
+	if m.scheduleJobDeletionFunc != nil {
+		return m.scheduleJobDeletionFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) CreateStorageUsage(ctx context.Context, params sqlc.CreateStorageUsageParams) error {
+	if m.createStorageUsageFunc != nil {
+		return m.createStorageUsageFunc(ctx, params)
+	}
+	return nil
+}
+
+func (m *mockQueries) DeleteHeightmapJobStorageUsage(ctx context.Context, heightmapJobID *uuid.UUID) error {
+	if m.deleteJobUsageFunc != nil {
+		return m.deleteJobUsageFunc(ctx, heightmapJobID, nil)
+	}
+	return nil
+}
+
+func (m *mockQueries) DeleteBatchJobStorageUsage(ctx context.Context, batchJobID *uuid.UUID) error {
+	if m.deleteJobUsageFunc != nil {
+		return m.deleteJobUsageFunc(ctx, nil, batchJobID)
+	}
+	return nil
+}
+
+func (m *mockQueries) ListQuotaPlans(ctx context.Context) ([]sqlc.QuotaPlan, error) {
+	return []sqlc.QuotaPlan{}, nil
+}
+
+func (m *mockQueries) GetQuotaPlan(ctx context.Context, name string) (sqlc.QuotaPlan, error) {
+	if m.getQuotaPlanFunc != nil {
+		return m.getQuotaPlanFunc(ctx, name)
+	}
+	return sqlc.QuotaPlan{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) SetUserQuotaPlan(ctx context.Context, params sqlc.SetUserQuotaPlanParams) (int64, error) {
+	if m.setUserQuotaPlanFunc != nil {
+		return m.setUserQuotaPlanFunc(ctx, params)
+	}
+	return 1, nil
+}
+
+func (m *mockQueries) TryAdvisoryXactLock(ctx context.Context, key int64) (bool, error) {
+	if m.tryAdvisoryXactLockFunc != nil {
+		return m.tryAdvisoryXactLockFunc(ctx, key)
+	}
+	return true, nil
+}
+
 type mockMinioClient struct {
 	uploadFileFunc      func(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) error
 	fileExistsFunc      func(ctx context.Context, bucket, objectName string) (bool, error)
 	getPresignedURLFunc func(ctx context.Context, bucket, objectName string, expiry int) (string, error)
+	removeObjectFunc    func(ctx context.Context, bucket, objectName string) error
+	getFileInfoFunc     func(ctx context.Context, bucket, objectName string) (*minio.FileInfo, error)
 }
 
 func (m *mockMinioClient) UploadFile(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) error {
@@ -143,6 +664,20 @@ func (m *mockMinioClient) GetPresignedURL(ctx context.Context, bucket, objectNam
 	return "https://minio.example.com/presigned-url", nil
 }
 
+func (m *mockMinioClient) RemoveObject(ctx context.Context, bucket, objectName string) error {
+	if m.removeObjectFunc != nil {
+		return m.removeObjectFunc(ctx, bucket, objectName)
+	}
+	return nil
+}
+
+func (m *mockMinioClient) GetFileInfo(ctx context.Context, bucket, objectName string) (*minio.FileInfo, error) {
+	if m.getFileInfoFunc != nil {
+		return m.getFileInfoFunc(ctx, bucket, objectName)
+	}
+	return &minio.FileInfo{Name: objectName}, nil
+}
+
 func TestGetHeightmapJob(t *testing.T) {
 	jobID := uuid.New()
 	userID := uuid.New()
@@ -177,7 +712,7 @@ func TestGetHeightmapJob(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			queries := &mockQueries{
-				getJobByUserIDFunc: func(ctx context.Context, params sqlc.GetHeightmapJobByUserIDParams) (sqlc.HeightmapJob, error) {
+				getAccessibleJobFunc: func(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error) {
 					if tt.expectError {
 						return sqlc.HeightmapJob{}, errors.New("not found")
 					}
@@ -379,3 +914,55 @@ func TestBatchHeightmapJobMethods(t *testing.T) {
 		}
 	})
 }
+
+func TestBatchUploadRemovesStoredImagesOnFailure(t *testing.T) {
+	s := newTestScheduleService(&mockQueries{
+		lockUserQuotaFunc: func(ctx context.Context, id uuid.UUID) error {
+			t.Error("no batch job must be recorded")
+			return nil
+		},
+	})
+	var uploaded, removed []string
+	s.minioClient = &mockMinioClient{
+		uploadFileFunc: func(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) error {
+			uploaded = append(uploaded, objectName)
+			return nil
+		},
+		removeObjectFunc: func(ctx context.Context, bucket, objectName string) error {
+			removed = append(removed, objectName)
+			return nil
+		},
+	}
+
+	files := multipartFiles(t, "a.jpg", "b.png", "c.gif")
+	_, err := s.BatchUploadPhotos(context.Background(), uuid.New(), nil, nil, files, "", false, "", priorityValues[PriorityNormal], nil)
+
+	if err == nil {
+		t.Fatal("expected the unsupported image to be rejected")
+	}
+	if len(uploaded) != 2 || len(removed) != 2 || removed[0] != uploaded[0] || removed[1] != uploaded[1] {
+		t.Errorf("expected the stored images to be removed, uploaded %v, removed %v", uploaded, removed)
+	}
+}
+
+// multipartFiles builds the file headers of a form with one image per name.
+func multipartFiles(t *testing.T, names ...string) []*multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, name := range names {
+		part, err := writer.CreateFormFile("images", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("image"))
+	}
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form.File["images"]
+}
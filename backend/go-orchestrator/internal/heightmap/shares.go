@@ -0,0 +1,408 @@
+package heightmap
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Actions recorded in the access log of a share link.
+const (
+	ShareActionView     = "view"
+	ShareActionDownload = "download"
+	ShareActionDenied   = "denied"
+)
+
+// Files of a job result a share link may hand out.
+const (
+	SharedFileHeightmap  = "heightmap"
+	SharedFileOrthophoto = "orthophoto"
+)
+
+// maxUserAgentLength matches job_share_accesses.user_agent.
+const maxUserAgentLength = 512
+
+var (
+	ErrInvalidShareExpiry    = errors.New("срок действия ссылки должен быть в будущем")
+	ErrShareExpiryTooFar     = errors.New("срок действия ссылки слишком большой")
+	ErrShareNotFound         = errors.New("ссылка не найдена")
+	ErrShareExpired          = errors.New("срок действия ссылки истек или она отозвана")
+	ErrSharePasswordRequired = errors.New("ссылка защищена паролем")
+	ErrInvalidSharePassword  = errors.New("неверный пароль")
+	ErrShareLocked           = errors.New("слишком много неверных паролей, попробуйте позже")
+	ErrShareDownloadDenied   = errors.New("скачивание по этой ссылке запрещено")
+	ErrSharedFileNotFound    = errors.New("файл недоступен")
+)
+
+// ShareClient identifies a visitor of a share link in the access log.
+type ShareClient struct {
+	IP        string
+	UserAgent string
+}
+
+// CreateShare creates a public link to the result of a job. Links are made by
+// the owner of the job and by the admins and owners of its organization. The
+// token is returned once; only its SHA-256 hash is stored.
+func (s *Service) CreateShare(ctx context.Context, jobType string, jobID, userID uuid.UUID, req *CreateShareRequest) (*JobShare, error) {
+	if err := s.requireJobManager(ctx, jobType, jobID, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.cfg.Shares.DefaultTTL)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) {
+		return nil, ErrInvalidShareExpiry
+	}
+	if expiresAt.After(now.Add(s.cfg.Shares.MaxTTL)) {
+		return nil, ErrShareExpiryTooFar
+	}
+
+	var passwordHash *string
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось захешировать пароль ссылки: %w", err)
+		}
+		hashed := string(hash)
+		passwordHash = &hashed
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать токен ссылки: %w", err)
+	}
+
+	params := sqlc.CreateJobShareParams{
+		CreatedBy:     &userID,
+		TokenHash:     hashShareToken(token),
+		PasswordHash:  passwordHash,
+		AllowDownload: req.AllowDownload,
+		ExpiresAt:     expiresAt,
+	}
+	if jobType == JobTypeHeightmap {
+		params.HeightmapJobID = &jobID
+	} else {
+		params.BatchJobID = &jobID
+	}
+
+	share, err := s.queries.CreateJobShare(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать ссылку: %w", err)
+	}
+
+	result := newJobShare(share)
+	result.Token = token
+	result.URL = fmt.Sprintf("%s/shared/%s", s.cfg.Auth.AppURL, token)
+	return result, nil
+}
+
+// ListShares lists the links to a job, including expired and revoked ones.
+func (s *Service) ListShares(ctx context.Context, jobType string, jobID, userID uuid.UUID) ([]*JobShare, error) {
+	if err := s.requireJobManager(ctx, jobType, jobID, userID); err != nil {
+		return nil, err
+	}
+
+	var (
+		shares []sqlc.JobShare
+		err    error
+	)
+	if jobType == JobTypeHeightmap {
+		shares, err = s.queries.ListHeightmapJobShares(ctx, &jobID)
+	} else {
+		shares, err = s.queries.ListBatchJobShares(ctx, &jobID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список ссылок: %w", err)
+	}
+
+	result := make([]*JobShare, 0, len(shares))
+	for _, share := range shares {
+		result = append(result, newJobShare(share))
+	}
+	return result, nil
+}
+
+// RevokeShare disables a link right away. Presigned URLs it already handed
+// out stay valid until they expire, which SHARE_URL_EXPIRY keeps short.
+func (s *Service) RevokeShare(ctx context.Context, jobType string, jobID, shareID, userID uuid.UUID) error {
+	if err := s.requireJobManager(ctx, jobType, jobID, userID); err != nil {
+		return err
+	}
+
+	revoked, err := s.queries.RevokeJobShare(ctx, sqlc.RevokeJobShareParams{
+		ID:    shareID,
+		JobID: &jobID,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать ссылку: %w", err)
+	}
+	if revoked == 0 {
+		// Revoking twice is not an error, a link of another job is.
+		return s.requireShareOfJob(ctx, jobID, shareID)
+	}
+	return nil
+}
+
+// ListShareAccesses returns the newest entries of the access log of a link.
+func (s *Service) ListShareAccesses(ctx context.Context, jobType string, jobID, shareID, userID uuid.UUID, limit int32) ([]*ShareAccess, error) {
+	if err := s.requireJobManager(ctx, jobType, jobID, userID); err != nil {
+		return nil, err
+	}
+	if err := s.requireShareOfJob(ctx, jobID, shareID); err != nil {
+		return nil, err
+	}
+
+	accesses, err := s.queries.ListJobShareAccesses(ctx, sqlc.ListJobShareAccessesParams{
+		ShareID: shareID,
+		Limit:   limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить журнал доступа: %w", err)
+	}
+
+	result := make([]*ShareAccess, 0, len(accesses))
+	for _, access := range accesses {
+		result = append(result, newShareAccess(access))
+	}
+	return result, nil
+}
+
+func (s *Service) requireShareOfJob(ctx context.Context, jobID, shareID uuid.UUID) error {
+	exists, err := s.queries.JobShareExists(ctx, sqlc.JobShareExistsParams{
+		ID:    shareID,
+		JobID: &jobID,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось проверить ссылку: %w", err)
+	}
+	if !exists {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// ViewShare opens a link for a visitor without an account. Without download
+// permission it only shows the job: the preview is the full result object, so
+// a presigned URL to it would be a download. Otherwise the result of a
+// completed job comes as a presigned preview URL and the files are listed.
+func (s *Service) ViewShare(ctx context.Context, token, password string, client ShareClient) (*SharedJob, error) {
+	share, err := s.openShare(ctx, token, password, client)
+	if err != nil {
+		return nil, err
+	}
+
+	result, files, err := s.sharedJob(ctx, share)
+	if err != nil {
+		return nil, err
+	}
+
+	if share.AllowDownload {
+		if heightmapURL, ok := files[SharedFileHeightmap]; ok {
+			previewURL, err := s.presignSharedObject(ctx, heightmapURL)
+			if err != nil {
+				return nil, err
+			}
+			urlExpiresAt := time.Now().Add(s.cfg.Shares.URLExpiry)
+			result.PreviewURL = &previewURL
+			result.URLExpiresAt = &urlExpiresAt
+		}
+		for _, name := range []string{SharedFileHeightmap, SharedFileOrthophoto} {
+			if _, ok := files[name]; ok {
+				result.Files = append(result.Files, name)
+			}
+		}
+	}
+
+	if err := s.logShareAccess(ctx, share.ID, ShareActionView, client); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DownloadSharedFile presigns one file of the result for a link that allows
+// downloads.
+func (s *Service) DownloadSharedFile(ctx context.Context, token, password, file string, client ShareClient) (*SharedFile, error) {
+	share, err := s.openShare(ctx, token, password, client)
+	if err != nil {
+		return nil, err
+	}
+	if !share.AllowDownload {
+		return nil, ErrShareDownloadDenied
+	}
+
+	_, files, err := s.sharedJob(ctx, share)
+	if err != nil {
+		return nil, err
+	}
+	objectURL, ok := files[file]
+	if !ok {
+		return nil, ErrSharedFileNotFound
+	}
+
+	url, err := s.presignSharedObject(ctx, objectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.logShareAccess(ctx, share.ID, ShareActionDownload, client); err != nil {
+		return nil, err
+	}
+	return &SharedFile{
+		File:      file,
+		URL:       url,
+		ExpiresAt: time.Now().Add(s.cfg.Shares.URLExpiry),
+	}, nil
+}
+
+// openShare resolves a token to a live link and checks its password. Wrong
+// passwords are logged, and after MaxPasswordFailures of them within
+// PasswordLockout the link stops accepting passwords for a while.
+func (s *Service) openShare(ctx context.Context, token, password string, client ShareClient) (sqlc.JobShare, error) {
+	share, err := s.queries.GetJobShareByTokenHash(ctx, hashShareToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.JobShare{}, ErrShareNotFound
+		}
+		return sqlc.JobShare{}, fmt.Errorf("не удалось загрузить ссылку: %w", err)
+	}
+
+	now := time.Now()
+	if share.RevokedAt != nil || !share.ExpiresAt.After(now) {
+		return sqlc.JobShare{}, ErrShareExpired
+	}
+	if share.PasswordHash == nil {
+		return share, nil
+	}
+
+	if limit := s.cfg.Shares.MaxPasswordFailures; limit > 0 {
+		denials, err := s.queries.CountJobShareDenialsSince(ctx, sqlc.CountJobShareDenialsSinceParams{
+			ShareID:   share.ID,
+			CreatedAt: now.Add(-s.cfg.Shares.PasswordLockout),
+		})
+		if err != nil {
+			return sqlc.JobShare{}, fmt.Errorf("не удалось посчитать неверные пароли ссылки: %w", err)
+		}
+		if denials >= int64(limit) {
+			return sqlc.JobShare{}, ErrShareLocked
+		}
+	}
+
+	if password == "" {
+		return sqlc.JobShare{}, ErrSharePasswordRequired
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(*share.PasswordHash), []byte(password)); err != nil {
+		if err := s.logShareAccess(ctx, share.ID, ShareActionDenied, client); err != nil {
+			return sqlc.JobShare{}, err
+		}
+		return sqlc.JobShare{}, ErrInvalidSharePassword
+	}
+	return share, nil
+}
+
+// sharedJob loads the job of a link together with the public URLs of its
+// result files by name. Files are only returned for completed jobs.
+func (s *Service) sharedJob(ctx context.Context, share sqlc.JobShare) (*SharedJob, map[string]string, error) {
+	result := &SharedJob{
+		AllowDownload: share.AllowDownload,
+		ExpiresAt:     share.ExpiresAt,
+	}
+	files := make(map[string]string)
+
+	var err error
+	if share.HeightmapJobID != nil {
+		var job sqlc.HeightmapJob
+		job, err = s.queries.GetHeightmapJob(ctx, *share.HeightmapJobID)
+		if err == nil {
+			result.JobType = JobTypeHeightmap
+			result.Status = job.Status
+			result.Width = job.Width
+			result.Height = job.Height
+			result.CreatedAt = job.CreatedAt
+			if job.Status == "completed" && job.ResultUrl != nil {
+				files[SharedFileHeightmap] = *job.ResultUrl
+			}
+		}
+	} else if share.BatchJobID != nil {
+		var job sqlc.BatchHeightmapJob
+		job, err = s.queries.GetBatchHeightmapJob(ctx, *share.BatchJobID)
+		if err == nil {
+			result.JobType = JobTypeBatch
+			result.Status = job.Status
+			result.Width = job.Width
+			result.Height = job.Height
+			result.ImageCount = &job.ImageCount
+			result.CreatedAt = job.CreatedAt
+			if job.Status == "completed" && job.ResultUrl != nil {
+				files[SharedFileHeightmap] = *job.ResultUrl
+			}
+			if job.Status == "completed" && job.OrthophotoUrl != nil {
+				files[SharedFileOrthophoto] = *job.OrthophotoUrl
+			}
+		}
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, ErrShareNotFound
+		}
+		return nil, nil, fmt.Errorf("не удалось загрузить задачу: %w", err)
+	}
+
+	return result, files, nil
+}
+
+func (s *Service) presignSharedObject(ctx context.Context, objectURL string) (string, error) {
+	bucket, object, err := splitObjectURL(s.cfg.Minio.PublicURL, objectURL)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := s.minioClient.GetPresignedURL(ctx, bucket, object, int(s.cfg.Shares.URLExpiry/time.Second))
+	if err != nil {
+		return "", fmt.Errorf("не удалось подготовить ссылку на файл: %w", err)
+	}
+	return url, nil
+}
+
+func (s *Service) logShareAccess(ctx context.Context, shareID uuid.UUID, action string, client ShareClient) error {
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	if err := s.queries.CreateJobShareAccess(ctx, sqlc.CreateJobShareAccessParams{
+		ShareID:   shareID,
+		Action:    action,
+		IpAddress: client.IP,
+		UserAgent: userAgent,
+	}); err != nil {
+		return fmt.Errorf("не удалось записать обращение к ссылке: %w", err)
+	}
+	return nil
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
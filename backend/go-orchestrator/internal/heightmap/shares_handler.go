@@ -0,0 +1,269 @@
+package heightmap
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+// sharePasswordHeader carries the password of a protected share link, so it
+// does not end up in URLs and access logs.
+const sharePasswordHeader = "X-Share-Password"
+
+type ShareHandler struct {
+	service *Service
+}
+
+func NewShareHandler(service *Service) *ShareHandler {
+	return &ShareHandler{service: service}
+}
+
+// RegisterRoutes registers the management of share links next to the jobs
+// and the public viewer, which needs no account.
+func (h *ShareHandler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMiddleware) {
+	protected := r.Group("heightmaps")
+	protected.Use(jwtMiddleware.RequireAuth())
+	{
+		protected.POST("/:id/shares", h.CreateShare(JobTypeHeightmap))
+		protected.GET("/:id/shares", h.ListShares(JobTypeHeightmap))
+		protected.DELETE("/:id/shares/:share_id", h.RevokeShare(JobTypeHeightmap))
+		protected.GET("/:id/shares/:share_id/accesses", h.ListShareAccesses(JobTypeHeightmap))
+
+		protected.POST("/batch/:id/shares", h.CreateShare(JobTypeBatch))
+		protected.GET("/batch/:id/shares", h.ListShares(JobTypeBatch))
+		protected.DELETE("/batch/:id/shares/:share_id", h.RevokeShare(JobTypeBatch))
+		protected.GET("/batch/:id/shares/:share_id/accesses", h.ListShareAccesses(JobTypeBatch))
+	}
+
+	public := r.Group("shares")
+	{
+		public.GET("/:token", h.ViewShare)
+		public.GET("/:token/files/:file", h.DownloadSharedFile)
+	}
+}
+
+// @Summary Create Share Link
+// @Description Create a public link to the result of a job. Owners of the job and admins of its organization only. The token is returned once
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body CreateShareRequest true "Link settings"
+// @Success 201 {object} JobShare
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/{id}/shares [post]
+// @Router /api/heightmaps/batch/{id}/shares [post]
+func (h *ShareHandler) CreateShare(jobType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, jobID, ok := jobRequestIDs(c)
+		if !ok {
+			return
+		}
+
+		var req CreateShareRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+			return
+		}
+
+		share, err := h.service.CreateShare(c.Request.Context(), jobType, jobID, userID, &req)
+		if err != nil {
+			respondShareError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, share)
+	}
+}
+
+// @Summary List Share Links
+// @Description Links to the result of a job, including expired and revoked ones
+// @Tags shares
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/{id}/shares [get]
+// @Router /api/heightmaps/batch/{id}/shares [get]
+func (h *ShareHandler) ListShares(jobType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, jobID, ok := jobRequestIDs(c)
+		if !ok {
+			return
+		}
+
+		shares, err := h.service.ListShares(c.Request.Context(), jobType, jobID, userID)
+		if err != nil {
+			respondShareError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"shares": shares})
+	}
+}
+
+// @Summary Revoke Share Link
+// @Tags shares
+// @Param id path string true "Job ID"
+// @Param share_id path string true "Share ID"
+// @Success 204
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/{id}/shares/{share_id} [delete]
+// @Router /api/heightmaps/batch/{id}/shares/{share_id} [delete]
+func (h *ShareHandler) RevokeShare(jobType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, jobID, shareID, ok := shareRequestIDs(c)
+		if !ok {
+			return
+		}
+
+		if err := h.service.RevokeShare(c.Request.Context(), jobType, jobID, shareID, userID); err != nil {
+			respondShareError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary Share Link Access Log
+// @Description Newest views, downloads and wrong passwords of a link
+// @Tags shares
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param share_id path string true "Share ID"
+// @Param limit query int false "Limit" default(100)
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/{id}/shares/{share_id}/accesses [get]
+// @Router /api/heightmaps/batch/{id}/shares/{share_id}/accesses [get]
+func (h *ShareHandler) ListShareAccesses(jobType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, jobID, shareID, ok := shareRequestIDs(c)
+		if !ok {
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit <= 0 {
+			limit = 100
+		}
+		if limit > 1000 {
+			limit = 1000
+		}
+
+		accesses, err := h.service.ListShareAccesses(c.Request.Context(), jobType, jobID, shareID, userID, int32(limit))
+		if err != nil {
+			respondShareError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"accesses": accesses,
+			"limit":    limit,
+		})
+	}
+}
+
+// @Summary View Shared Result
+// @Description Public viewer of a share link. Returns the job status and, once completed and if the link allows downloads, a short-lived presigned preview URL
+// @Tags shares
+// @Produce json
+// @Param token path string true "Share token"
+// @Param X-Share-Password header string false "Password of a protected link"
+// @Success 200 {object} SharedJob
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Router /api/shares/{token} [get]
+func (h *ShareHandler) ViewShare(c *gin.Context) {
+	job, err := h.service.ViewShare(c.Request.Context(), c.Param("token"), c.GetHeader(sharePasswordHeader), shareClient(c))
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Download Shared File
+// @Description Short-lived presigned URL of a result file (heightmap or orthophoto) of a link that allows downloads
+// @Tags shares
+// @Produce json
+// @Param token path string true "Share token"
+// @Param file path string true "heightmap or orthophoto"
+// @Param X-Share-Password header string false "Password of a protected link"
+// @Success 200 {object} SharedFile
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Router /api/shares/{token}/files/{file} [get]
+func (h *ShareHandler) DownloadSharedFile(c *gin.Context) {
+	file, err := h.service.DownloadSharedFile(c.Request.Context(), c.Param("token"), c.GetHeader(sharePasswordHeader), c.Param("file"), shareClient(c))
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+func shareRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, uuid.UUID, bool) {
+	userID, jobID, ok := jobRequestIDs(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+
+	shareID, err := uuid.Parse(c.Param("share_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID ссылки"})
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, jobID, shareID, true
+}
+
+func shareClient(c *gin.Context) ShareClient {
+	return ShareClient{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+func respondShareError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrJobNotFound), errors.Is(err, ErrShareNotFound), errors.Is(err, ErrSharedFileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrJobAccessDenied), errors.Is(err, ErrShareDownloadDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrSharePasswordRequired), errors.Is(err, ErrInvalidSharePassword):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":             err.Error(),
+			"password_required": true,
+		})
+	case errors.Is(err, ErrShareExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrShareLocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidShareExpiry), errors.Is(err, ErrShareExpiryTooFar):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,312 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/organization"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testSharePublicURL = "http://minio:9000"
+
+func newTestShareService(queries *mockQueries, minio *mockMinioClient) *Service {
+	return &Service{
+		queries:     queries,
+		minioClient: minio,
+		cfg: &config.Config{
+			Auth:  config.AuthConfig{AppURL: "https://app.example.com"},
+			Minio: config.MinioConfig{PublicURL: testSharePublicURL},
+			Shares: config.SharesConfig{
+				DefaultTTL:          7 * 24 * time.Hour,
+				MaxTTL:              30 * 24 * time.Hour,
+				URLExpiry:           5 * time.Minute,
+				MaxPasswordFailures: 3,
+				PasswordLockout:     15 * time.Minute,
+			},
+		},
+	}
+}
+
+func TestCreateShare(t *testing.T) {
+	ownerID := uuid.New()
+	memberID := uuid.New()
+	orgID := uuid.New()
+	inOneHour := time.Now().Add(time.Hour)
+	inOneYear := time.Now().AddDate(1, 0, 0)
+	anHourAgo := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		userID      uuid.UUID
+		role        string
+		req         CreateShareRequest
+		expectedErr error
+	}{
+		{name: "owner with default expiry", userID: ownerID},
+		{name: "owner with password", userID: ownerID, req: CreateShareRequest{ExpiresAt: &inOneHour, Password: "secret"}},
+		{name: "organization admin", userID: memberID, role: organization.RoleAdmin},
+		{name: "organization member", userID: memberID, role: organization.RoleMember, expectedErr: ErrJobAccessDenied},
+		{name: "expiry in the past", userID: ownerID, req: CreateShareRequest{ExpiresAt: &anHourAgo}, expectedErr: ErrInvalidShareExpiry},
+		{name: "expiry beyond the limit", userID: ownerID, req: CreateShareRequest{ExpiresAt: &inOneYear}, expectedErr: ErrShareExpiryTooFar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *sqlc.CreateJobShareParams
+			queries := &mockQueries{
+				getAccessibleBatchJobFunc: func(ctx context.Context, params sqlc.GetAccessibleBatchHeightmapJobParams) (sqlc.BatchHeightmapJob, error) {
+					return sqlc.BatchHeightmapJob{ID: params.ID, UserID: ownerID, OrganizationID: &orgID}, nil
+				},
+				memberRoleFunc: func(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error) {
+					return tt.role, nil
+				},
+				createJobShareFunc: func(ctx context.Context, params sqlc.CreateJobShareParams) (sqlc.JobShare, error) {
+					created = &params
+					return sqlc.JobShare{
+						ID:           uuid.New(),
+						BatchJobID:   params.BatchJobID,
+						TokenHash:    params.TokenHash,
+						PasswordHash: params.PasswordHash,
+						ExpiresAt:    params.ExpiresAt,
+					}, nil
+				},
+			}
+			s := newTestShareService(queries, &mockMinioClient{})
+
+			share, err := s.CreateShare(context.Background(), JobTypeBatch, uuid.New(), tt.userID, &tt.req)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if created != nil {
+					t.Error("no share must be created")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if created.HeightmapJobID != nil || created.BatchJobID == nil {
+				t.Error("expected the share to reference the batch job only")
+			}
+			if created.TokenHash != hashShareToken(share.Token) {
+				t.Error("expected only the hash of the token to be stored")
+			}
+			if !strings.HasSuffix(share.URL, "/shared/"+share.Token) {
+				t.Errorf("unexpected share URL %q", share.URL)
+			}
+			if share.PasswordProtected != (tt.req.Password != "") {
+				t.Errorf("expected password protection %v", tt.req.Password != "")
+			}
+			if created.PasswordHash != nil && *created.PasswordHash == tt.req.Password {
+				t.Error("the password must not be stored in plain text")
+			}
+		})
+	}
+}
+
+func TestViewShare(t *testing.T) {
+	jobID := uuid.New()
+	resultURL := testSharePublicURL + "/uav-models/heightmaps/result.png"
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := string(passwordHash)
+	revokedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name           string
+		share          sqlc.JobShare
+		password       string
+		denials        int64
+		expectedErr    error
+		expectedAction string
+	}{
+		{name: "open link", expectedAction: ShareActionView},
+		{name: "link allowing downloads", share: sqlc.JobShare{AllowDownload: true}, expectedAction: ShareActionView},
+		{name: "correct password", share: sqlc.JobShare{PasswordHash: &hash}, password: "secret", expectedAction: ShareActionView},
+		{name: "missing password", share: sqlc.JobShare{PasswordHash: &hash}, expectedErr: ErrSharePasswordRequired},
+		{name: "wrong password", share: sqlc.JobShare{PasswordHash: &hash}, password: "guess", expectedErr: ErrInvalidSharePassword, expectedAction: ShareActionDenied},
+		{name: "too many wrong passwords", share: sqlc.JobShare{PasswordHash: &hash}, password: "secret", denials: 3, expectedErr: ErrShareLocked},
+		{name: "revoked link", share: sqlc.JobShare{RevokedAt: &revokedAt}, expectedErr: ErrShareExpired},
+		{name: "expired link", share: sqlc.JobShare{ExpiresAt: time.Now().Add(-time.Minute)}, expectedErr: ErrShareExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			share := tt.share
+			share.ID = uuid.New()
+			share.HeightmapJobID = &jobID
+			if share.ExpiresAt.IsZero() {
+				share.ExpiresAt = time.Now().Add(time.Hour)
+			}
+
+			var logged []string
+			presigned := 0
+			queries := &mockQueries{
+				getShareByTokenHashFunc: func(ctx context.Context, tokenHash string) (sqlc.JobShare, error) {
+					if tokenHash != hashShareToken("token") {
+						t.Errorf("expected the token to be looked up by its hash")
+					}
+					return share, nil
+				},
+				countShareDenialsFunc: func(ctx context.Context, params sqlc.CountJobShareDenialsSinceParams) (int64, error) {
+					return tt.denials, nil
+				},
+				createShareAccessFunc: func(ctx context.Context, params sqlc.CreateJobShareAccessParams) error {
+					logged = append(logged, params.Action)
+					return nil
+				},
+				getJobFunc: func(ctx context.Context, id uuid.UUID) (sqlc.HeightmapJob, error) {
+					return sqlc.HeightmapJob{ID: id, Status: "completed", ResultUrl: &resultURL}, nil
+				},
+			}
+			minio := &mockMinioClient{
+				getPresignedURLFunc: func(ctx context.Context, bucket, objectName string, expiry int) (string, error) {
+					if bucket != "uav-models" || objectName != "heightmaps/result.png" {
+						t.Errorf("unexpected object %s/%s", bucket, objectName)
+					}
+					if expiry != 300 {
+						t.Errorf("expected the URL expiry of the config, got %d", expiry)
+					}
+					presigned++
+					return "https://presigned.url", nil
+				},
+			}
+			s := newTestShareService(queries, minio)
+
+			job, err := s.ViewShare(context.Background(), "token", tt.password, ShareClient{IP: "203.0.113.7"})
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if share.AllowDownload {
+					if job.PreviewURL == nil || *job.PreviewURL != "https://presigned.url" {
+						t.Errorf("expected a presigned preview URL, got %v", job.PreviewURL)
+					}
+					if len(job.Files) != 1 || job.Files[0] != SharedFileHeightmap {
+						t.Errorf("expected the heightmap to be listed, got %v", job.Files)
+					}
+				} else {
+					// The preview is the full result, so it would be a download.
+					if job.PreviewURL != nil || job.URLExpiresAt != nil || presigned != 0 {
+						t.Errorf("expected no URL to the result without download permission, got %v", job.PreviewURL)
+					}
+					if len(job.Files) != 0 {
+						t.Errorf("expected no files without download permission, got %v", job.Files)
+					}
+				}
+			}
+
+			if tt.expectedAction == "" && len(logged) != 0 {
+				t.Errorf("expected nothing logged, got %v", logged)
+			}
+			if tt.expectedAction != "" && (len(logged) != 1 || logged[0] != tt.expectedAction) {
+				t.Errorf("expected %q logged, got %v", tt.expectedAction, logged)
+			}
+		})
+	}
+}
+
+func TestDownloadSharedFile(t *testing.T) {
+	batchJobID := uuid.New()
+	resultURL := testSharePublicURL + "/uav-models/batch/result.png"
+
+	tests := []struct {
+		name          string
+		allowDownload bool
+		status        string
+		file          string
+		expectedErr   error
+	}{
+		{name: "download allowed", allowDownload: true, status: "completed", file: SharedFileHeightmap},
+		{name: "download denied", status: "completed", file: SharedFileHeightmap, expectedErr: ErrShareDownloadDenied},
+		{name: "job not completed", allowDownload: true, status: "processing", file: SharedFileHeightmap, expectedErr: ErrSharedFileNotFound},
+		{name: "no orthophoto", allowDownload: true, status: "completed", file: SharedFileOrthophoto, expectedErr: ErrSharedFileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged []string
+			queries := &mockQueries{
+				getShareByTokenHashFunc: func(ctx context.Context, tokenHash string) (sqlc.JobShare, error) {
+					return sqlc.JobShare{
+						ID:            uuid.New(),
+						BatchJobID:    &batchJobID,
+						AllowDownload: tt.allowDownload,
+						ExpiresAt:     time.Now().Add(time.Hour),
+					}, nil
+				},
+				getBatchJobFunc: func(ctx context.Context, id uuid.UUID) (sqlc.BatchHeightmapJob, error) {
+					return sqlc.BatchHeightmapJob{ID: id, Status: tt.status, ResultUrl: &resultURL}, nil
+				},
+				createShareAccessFunc: func(ctx context.Context, params sqlc.CreateJobShareAccessParams) error {
+					logged = append(logged, params.Action)
+					return nil
+				},
+			}
+			minio := &mockMinioClient{
+				getPresignedURLFunc: func(ctx context.Context, bucket, objectName string, expiry int) (string, error) {
+					return "https://presigned.url", nil
+				},
+			}
+			s := newTestShareService(queries, minio)
+
+			file, err := s.DownloadSharedFile(context.Background(), "token", "", tt.file, ShareClient{})
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if len(logged) != 0 {
+					t.Errorf("expected nothing logged, got %v", logged)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if file.URL != "https://presigned.url" {
+				t.Errorf("unexpected URL %q", file.URL)
+			}
+			if len(logged) != 1 || logged[0] != ShareActionDownload {
+				t.Errorf("expected a download logged, got %v", logged)
+			}
+		})
+	}
+}
+
+func TestRevokeShareOfAnotherJob(t *testing.T) {
+	ownerID := uuid.New()
+	queries := &mockQueries{
+		getAccessibleJobFunc: func(ctx context.Context, params sqlc.GetAccessibleHeightmapJobParams) (sqlc.HeightmapJob, error) {
+			return sqlc.HeightmapJob{ID: params.ID, UserID: ownerID}, nil
+		},
+		revokeJobShareFunc: func(ctx context.Context, params sqlc.RevokeJobShareParams) (int64, error) {
+			return 0, nil
+		},
+		jobShareExistsFunc: func(ctx context.Context, params sqlc.JobShareExistsParams) (bool, error) {
+			return false, nil
+		},
+	}
+	s := newTestShareService(queries, &mockMinioClient{})
+
+	err := s.RevokeShare(context.Background(), JobTypeHeightmap, uuid.New(), uuid.New(), ownerID)
+	if !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("expected %v, got %v", ErrShareNotFound, err)
+	}
+}
@@ -0,0 +1,150 @@
+package heightmap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+const (
+	storageDeletionPollEvery  = time.Minute
+	storageDeletionBatchSize  = 20
+	storageDeletionRetryDelay = 5 * time.Minute
+)
+
+// StorageCleaner removes the objects of deleted accounts and deleted jobs.
+// Deleting either schedules a storage_deletions row with the IDs of the jobs,
+// since the job rows are gone by then, and the prefixes of their uploads: the
+// user prefixes for an account, the objects of the job otherwise. The cleaner
+// then removes the uploads under those prefixes and the results stored under
+// each job ID.
+//
+// A job may still be processed when its account is deleted, and the worker
+// can write the result until its presigned URL expires. The cleaner therefore
+// makes a second pass once Minio.TaskURLExpiry has passed since the deletion.
+type StorageCleaner struct {
+	withTx  func(ctx context.Context, fn func(q StorageDeletionQueriesInterface) error) error
+	remover ObjectRemoverInterface
+	cfg     *config.Config
+	logger  middleware.LoggerInterface
+}
+
+func NewStorageCleaner(db *storage.DB, remover ObjectRemoverInterface, cfg *config.Config, logger middleware.LoggerInterface) *StorageCleaner {
+	return &StorageCleaner{
+		withTx: func(ctx context.Context, fn func(q StorageDeletionQueriesInterface) error) error {
+			return db.WithTx(ctx, func(q *sqlc.Queries) error {
+				return fn(q)
+			})
+		},
+		remover: remover,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+func (c *StorageCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(storageDeletionPollEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := c.withTx(ctx, func(q StorageDeletionQueriesInterface) error {
+				return c.cleanBatch(ctx, q)
+			})
+			if err != nil && ctx.Err() == nil {
+				c.logger.Error("Failed to delete stored objects of deleted users", err)
+			}
+		}
+	}
+}
+
+func (c *StorageCleaner) cleanBatch(ctx context.Context, q StorageDeletionQueriesInterface) error {
+	now := time.Now()
+	deletions, err := q.ClaimDueStorageDeletions(ctx, sqlc.ClaimDueStorageDeletionsParams{
+		RunAfter: now,
+		Limit:    storageDeletionBatchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось получить задания на удаление файлов: %w", err)
+	}
+
+	for _, deletion := range deletions {
+		if err := c.removeObjects(ctx, deletion); err != nil {
+			errMsg := err.Error()
+			if len(errMsg) > maxOutboxErrorLength {
+				errMsg = errMsg[:maxOutboxErrorLength]
+			}
+
+			c.logger.Warn("Failed to delete stored objects of deleted user", map[string]interface{}{
+				"user_id":  deletion.UserID.String(),
+				"attempts": deletion.Attempts + 1,
+				"error":    errMsg,
+			})
+
+			if err := q.RescheduleStorageDeletion(ctx, sqlc.RescheduleStorageDeletionParams{
+				ID:        deletion.ID,
+				LastError: &errMsg,
+				RunAfter:  now.Add(storageDeletionRetryDelay),
+			}); err != nil {
+				return fmt.Errorf("не удалось отложить задание на удаление файлов %s: %w", deletion.ID, err)
+			}
+			continue
+		}
+
+		if lastWrite := deletion.CreatedAt.Add(c.cfg.Minio.TaskURLExpiry); lastWrite.After(now) {
+			if err := q.RescheduleStorageDeletion(ctx, sqlc.RescheduleStorageDeletionParams{
+				ID:       deletion.ID,
+				RunAfter: lastWrite,
+			}); err != nil {
+				return fmt.Errorf("не удалось отложить задание на удаление файлов %s: %w", deletion.ID, err)
+			}
+			continue
+		}
+
+		if err := q.DeleteStorageDeletion(ctx, deletion.ID); err != nil {
+			return fmt.Errorf("не удалось удалить задание на удаление файлов %s: %w", deletion.ID, err)
+		}
+		c.logger.Info("Deleted stored objects of deleted user", map[string]interface{}{
+			"user_id": deletion.UserID.String(),
+		})
+	}
+
+	return nil
+}
+
+// removeObjects deletes everything stored for the deletion. Removal is
+// idempotent, so a failed pass is simply repeated.
+func (c *StorageCleaner) removeObjects(ctx context.Context, deletion sqlc.StorageDeletion) error {
+	minioCfg := c.cfg.Minio
+
+	for _, prefix := range deletion.InputPrefixes {
+		if err := c.remover.RemovePrefix(ctx, minioCfg.UAVDataBucketName, prefix); err != nil {
+			return err
+		}
+	}
+
+	remove := func(bucket, keyFormat string, ids []uuid.UUID) error {
+		for _, id := range ids {
+			if err := c.remover.RemoveObject(ctx, bucket, fmt.Sprintf(keyFormat, id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := remove(minioCfg.UAVModelsBucketName, heightmapOutputKey, deletion.HeightmapJobIds); err != nil {
+		return err
+	}
+	if err := remove(minioCfg.UAVModelsBucketName, batchHeightmapOutputKey, deletion.BatchJobIds); err != nil {
+		return err
+	}
+	return remove(minioCfg.UAVPhotoplanesBucketName, orthophotoOutputKey, deletion.BatchJobIds)
+}
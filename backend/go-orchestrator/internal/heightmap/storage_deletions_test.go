@@ -0,0 +1,136 @@
+package heightmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+type mockStorageDeletionQueries struct {
+	deletions   []sqlc.StorageDeletion
+	rescheduled []sqlc.RescheduleStorageDeletionParams
+	deleted     []uuid.UUID
+}
+
+func (m *mockStorageDeletionQueries) ClaimDueStorageDeletions(ctx context.Context, params sqlc.ClaimDueStorageDeletionsParams) ([]sqlc.StorageDeletion, error) {
+	return m.deletions, nil
+}
+
+func (m *mockStorageDeletionQueries) RescheduleStorageDeletion(ctx context.Context, params sqlc.RescheduleStorageDeletionParams) error {
+	m.rescheduled = append(m.rescheduled, params)
+	return nil
+}
+
+func (m *mockStorageDeletionQueries) DeleteStorageDeletion(ctx context.Context, id uuid.UUID) error {
+	m.deleted = append(m.deleted, id)
+	return nil
+}
+
+type mockObjectRemover struct {
+	removed []string
+	err     error
+}
+
+func (m *mockObjectRemover) RemoveObject(ctx context.Context, bucket, objectName string) error {
+	m.removed = append(m.removed, bucket+"/"+objectName)
+	return m.err
+}
+
+func (m *mockObjectRemover) RemovePrefix(ctx context.Context, bucket, prefix string) error {
+	m.removed = append(m.removed, bucket+"/"+prefix+"*")
+	return m.err
+}
+
+func TestStorageCleanerBatch(t *testing.T) {
+	cfg := &config.Config{
+		Minio: config.MinioConfig{
+			UAVDataBucketName:        "uav-data",
+			UAVModelsBucketName:      "uav-models",
+			UAVPhotoplanesBucketName: "uav-photoplanes",
+			TaskURLExpiry:            time.Hour,
+		},
+	}
+	userID := uuid.New()
+	jobID := uuid.New()
+	batchID := uuid.New()
+
+	t.Run("removes uploads and results", func(t *testing.T) {
+		deletion := sqlc.StorageDeletion{
+			ID:              uuid.New(),
+			UserID:          userID,
+			HeightmapJobIds: []uuid.UUID{jobID},
+			BatchJobIds:     []uuid.UUID{batchID},
+			InputPrefixes:   []string{"heightmaps/" + userID.String() + "/", "batch-heightmaps/" + userID.String() + "/"},
+			CreatedAt:       time.Now().Add(-2 * time.Hour),
+		}
+		queries := &mockStorageDeletionQueries{deletions: []sqlc.StorageDeletion{deletion}}
+		remover := &mockObjectRemover{}
+		cleaner := &StorageCleaner{remover: remover, cfg: cfg, logger: nopLogger{}}
+
+		if err := cleaner.cleanBatch(context.Background(), queries); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{
+			"uav-data/heightmaps/" + userID.String() + "/*",
+			"uav-data/batch-heightmaps/" + userID.String() + "/*",
+			"uav-models/heightmaps/" + jobID.String() + "_heightmap.png",
+			"uav-models/batch-heightmaps/" + batchID.String() + "_heightmap.png",
+			"uav-photoplanes/orthophotos/" + batchID.String() + "_orthophoto.tif",
+		}
+		if len(remover.removed) != len(expected) {
+			t.Fatalf("expected %v removed, got %v", expected, remover.removed)
+		}
+		for i := range expected {
+			if remover.removed[i] != expected[i] {
+				t.Errorf("expected %s removed, got %s", expected[i], remover.removed[i])
+			}
+		}
+		if len(queries.deleted) != 1 || len(queries.rescheduled) != 0 {
+			t.Errorf("expected the deletion to be done, got deleted=%v rescheduled=%v", queries.deleted, queries.rescheduled)
+		}
+	})
+
+	t.Run("passes again after task urls expire", func(t *testing.T) {
+		createdAt := time.Now().Add(-time.Minute)
+		queries := &mockStorageDeletionQueries{deletions: []sqlc.StorageDeletion{{ID: uuid.New(), UserID: userID, CreatedAt: createdAt}}}
+		cleaner := &StorageCleaner{remover: &mockObjectRemover{}, cfg: cfg, logger: nopLogger{}}
+
+		if err := cleaner.cleanBatch(context.Background(), queries); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(queries.deleted) != 0 || len(queries.rescheduled) != 1 {
+			t.Fatalf("expected a second pass, got deleted=%v rescheduled=%v", queries.deleted, queries.rescheduled)
+		}
+		if params := queries.rescheduled[0]; !params.RunAfter.Equal(createdAt.Add(time.Hour)) || params.LastError != nil {
+			t.Errorf("expected the second pass at %v, got %+v", createdAt.Add(time.Hour), params)
+		}
+	})
+
+	t.Run("retries failures", func(t *testing.T) {
+		queries := &mockStorageDeletionQueries{deletions: []sqlc.StorageDeletion{{
+			ID:              uuid.New(),
+			UserID:          userID,
+			HeightmapJobIds: []uuid.UUID{jobID},
+			CreatedAt:       time.Now().Add(-2 * time.Hour),
+		}}}
+		cleaner := &StorageCleaner{remover: &mockObjectRemover{err: errors.New("minio unavailable")}, cfg: cfg, logger: nopLogger{}}
+
+		if err := cleaner.cleanBatch(context.Background(), queries); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(queries.deleted) != 0 || len(queries.rescheduled) != 1 {
+			t.Fatalf("expected a retry, got deleted=%v rescheduled=%v", queries.deleted, queries.rescheduled)
+		}
+		if params := queries.rescheduled[0]; params.LastError == nil || *params.LastError != "minio unavailable" {
+			t.Errorf("expected the error to be recorded, got %+v", params)
+		}
+	})
+}
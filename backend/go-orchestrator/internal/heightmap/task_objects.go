@@ -0,0 +1,126 @@
+package heightmap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+// Output object keys. Results are written under the job ID, so a requeued job
+// overwrites the output of its previous attempt.
+const (
+	heightmapOutputKey      = "heightmaps/%s_heightmap.png"
+	batchHeightmapOutputKey = "batch-heightmaps/%s_heightmap.png"
+	orthophotoOutputKey     = "orthophotos/%s_orthophoto.tif"
+)
+
+// attachHeightmapObjects fills in the task objects and their presigned URLs.
+// The outbox only stores the job fields, so URLs are fresh on every publish,
+// including requeues and redrives.
+func (r *OutboxRelay) attachHeightmapObjects(ctx context.Context, task *rabbitmq.HeightmapTask) error {
+	input, err := r.inputObject(ctx, task.ImageURL)
+	if err != nil {
+		return err
+	}
+
+	output, err := r.outputObject(ctx, r.cfg.Minio.UAVModelsBucketName, fmt.Sprintf(heightmapOutputKey, task.JobID))
+	if err != nil {
+		return err
+	}
+
+	task.SchemaVersion = rabbitmq.TaskSchemaVersion
+	task.Input = input
+	task.Output = output
+	return nil
+}
+
+func (r *OutboxRelay) attachBatchObjects(ctx context.Context, task *rabbitmq.BatchHeightmapTask) error {
+	task.Inputs = make([]rabbitmq.TaskObject, 0, len(task.ImageURLs))
+	for _, imageURL := range task.ImageURLs {
+		input, err := r.inputObject(ctx, imageURL)
+		if err != nil {
+			return err
+		}
+		task.Inputs = append(task.Inputs, input)
+	}
+
+	task.Heightmap = nil
+	task.Orthophoto = nil
+
+	if task.GenerationMode != "orthophoto" {
+		output, err := r.outputObject(ctx, r.cfg.Minio.UAVModelsBucketName, fmt.Sprintf(batchHeightmapOutputKey, task.BatchJobID))
+		if err != nil {
+			return err
+		}
+		task.Heightmap = &output
+	}
+	if task.GenerationMode == "orthophoto" || task.GenerationMode == "both" {
+		output, err := r.outputObject(ctx, r.cfg.Minio.UAVPhotoplanesBucketName, fmt.Sprintf(orthophotoOutputKey, task.BatchJobID))
+		if err != nil {
+			return err
+		}
+		task.Orthophoto = &output
+	}
+
+	task.SchemaVersion = rabbitmq.TaskSchemaVersion
+	return nil
+}
+
+// inputObject presigns download and delete of an uploaded image; workers
+// remove their inputs once the result is published.
+func (r *OutboxRelay) inputObject(ctx context.Context, imageURL string) (rabbitmq.TaskObject, error) {
+	bucket, object, err := splitObjectURL(r.cfg.Minio.PublicURL, imageURL)
+	if err != nil {
+		return rabbitmq.TaskObject{}, err
+	}
+
+	getURL, err := r.presigner.PresignObject(ctx, http.MethodGet, bucket, object, r.cfg.Minio.TaskURLExpiry)
+	if err != nil {
+		return rabbitmq.TaskObject{}, err
+	}
+	deleteURL, err := r.presigner.PresignObject(ctx, http.MethodDelete, bucket, object, r.cfg.Minio.TaskURLExpiry)
+	if err != nil {
+		return rabbitmq.TaskObject{}, err
+	}
+
+	return rabbitmq.TaskObject{
+		Bucket:    bucket,
+		Object:    object,
+		URL:       imageURL,
+		GetURL:    getURL,
+		DeleteURL: deleteURL,
+	}, nil
+}
+
+func (r *OutboxRelay) outputObject(ctx context.Context, bucket, object string) (rabbitmq.TaskObject, error) {
+	putURL, err := r.presigner.PresignObject(ctx, http.MethodPut, bucket, object, r.cfg.Minio.TaskURLExpiry)
+	if err != nil {
+		return rabbitmq.TaskObject{}, err
+	}
+
+	return rabbitmq.TaskObject{
+		Bucket: bucket,
+		Object: object,
+		URL:    fmt.Sprintf("%s/%s/%s", r.cfg.Minio.PublicURL, bucket, object),
+		PutURL: putURL,
+	}, nil
+}
+
+// splitObjectURL maps a public object URL stored on a job back to its bucket
+// and key.
+func splitObjectURL(publicURL, objectURL string) (string, string, error) {
+	path, ok := strings.CutPrefix(objectURL, publicURL+"/")
+	if !ok {
+		return "", "", fmt.Errorf("адрес объекта %q не относится к %s", objectURL, publicURL)
+	}
+
+	bucket, object, ok := strings.Cut(path, "/")
+	if !ok || bucket == "" || object == "" {
+		return "", "", fmt.Errorf("в адресе объекта %q нет бакета или ключа", objectURL)
+	}
+
+	return bucket, object, nil
+}
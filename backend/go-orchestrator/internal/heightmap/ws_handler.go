@@ -0,0 +1,179 @@
+package heightmap
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+	"github.com/skr1ms/dev2gis/pkg/rabbitmq"
+)
+
+const (
+	wsWriteTimeout     = 10 * time.Second
+	wsPongTimeout      = 60 * time.Second
+	wsPingInterval     = 45 * time.Second
+	wsMaxMessageSize   = 4096
+	wsUpdateBufferSize = 256
+
+	wsActionSubscribe   = "subscribe"
+	wsActionUnsubscribe = "unsubscribe"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{middleware.WebSocketBearerProtocol},
+	// Authentication is token based and CORS is open, so the origin is not checked.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type wsCommand struct {
+	Action string `json:"action"`
+	JobID  string `json:"job_id"`
+}
+
+type wsReply struct {
+	Type  string `json:"type"`
+	JobID string `json:"job_id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// @Summary Job Updates WebSocket
+// @Description WebSocket carrying status transitions, progress and worker log lines of the watched jobs. Send {"action":"subscribe","job_id":"..."} or {"action":"unsubscribe","job_id":"..."}. Browsers pass the token as Sec-WebSocket-Protocol: bearer, <token>.
+// @Tags heightmaps
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 401 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/heightmaps/ws [get]
+func (h *Handler) JobUpdatesWebSocket(c *gin.Context) {
+	userIDStr, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return
+	}
+
+	// Operators follow the logs of any job, other users only of their own.
+	canWatchAny := middleware.IsOperator(c)
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	updates := make(chan *rabbitmq.JobUpdate, wsUpdateBufferSize)
+	watched := make(map[string]struct{})
+	defer func() {
+		for jobID := range watched {
+			h.hub.Unwatch(jobID, updates)
+		}
+	}()
+
+	commands := make(chan wsCommand)
+	done := make(chan struct{})
+	go readWSCommands(conn, commands, done)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	ctx := c.Request.Context()
+
+	for {
+		var reply interface{}
+
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case cmd := <-commands:
+			reply = h.handleWSCommand(c, cmd, userID, canWatchAny, updates, watched)
+		case update := <-updates:
+			if _, ok := watched[update.JobID]; !ok {
+				continue
+			}
+			reply = update
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+			continue
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteJSON(reply); err != nil {
+			return
+		}
+	}
+}
+
+func (h *Handler) handleWSCommand(c *gin.Context, cmd wsCommand, userID uuid.UUID, canWatchAny bool, updates chan *rabbitmq.JobUpdate, watched map[string]struct{}) *wsReply {
+	jobID, err := uuid.Parse(cmd.JobID)
+	if err != nil {
+		return &wsReply{Type: "error", JobID: cmd.JobID, Error: "Некорректный ID задачи"}
+	}
+	key := jobID.String()
+
+	switch cmd.Action {
+	case wsActionSubscribe:
+		if _, ok := watched[key]; ok {
+			return &wsReply{Type: "subscribed", JobID: key}
+		}
+
+		if !canWatchAny {
+			allowed, err := h.service.CanWatchJob(c.Request.Context(), jobID, userID)
+			if err != nil {
+				return &wsReply{Type: "error", JobID: key, Error: err.Error()}
+			}
+			if !allowed {
+				return &wsReply{Type: "error", JobID: key, Error: "Задача не найдена"}
+			}
+		}
+
+		if err := h.hub.Watch(key, updates); err != nil {
+			return &wsReply{Type: "error", JobID: key, Error: "Не удалось подписаться на обновления задачи"}
+		}
+		watched[key] = struct{}{}
+		return &wsReply{Type: "subscribed", JobID: key}
+	case wsActionUnsubscribe:
+		if _, ok := watched[key]; ok {
+			h.hub.Unwatch(key, updates)
+			delete(watched, key)
+		}
+		return &wsReply{Type: "unsubscribed", JobID: key}
+	default:
+		return &wsReply{Type: "error", JobID: key, Error: "Неизвестное действие"}
+	}
+}
+
+func readWSCommands(conn *websocket.Conn, commands chan<- wsCommand, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		var cmd wsCommand
+		if err := conn.ReadJSON(&cmd); err != nil {
+			return
+		}
+
+		select {
+		case commands <- cmd:
+		case <-time.After(wsWriteTimeout):
+			return
+		}
+	}
+}
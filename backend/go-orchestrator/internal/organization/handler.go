@@ -0,0 +1,431 @@
+package organization
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMiddleware) {
+	protected := r.Group("organizations")
+	protected.Use(jwtMiddleware.RequireAuth())
+	{
+		protected.POST("", h.CreateOrganization)
+		protected.GET("", h.ListOrganizations)
+		protected.POST("/invitations/accept", h.AcceptInvitation)
+		protected.GET("/:id", h.GetOrganization)
+		protected.PATCH("/:id", h.UpdateOrganization)
+		protected.DELETE("/:id", h.DeleteOrganization)
+		protected.GET("/:id/members", h.ListMembers)
+		protected.PATCH("/:id/members/:user_id", h.UpdateMemberRole)
+		protected.DELETE("/:id/members/:user_id", h.RemoveMember)
+		protected.POST("/:id/invitations", h.InviteMember)
+		protected.GET("/:id/invitations", h.ListInvitations)
+		protected.DELETE("/:id/invitations/:invitation_id", h.RevokeInvitation)
+	}
+}
+
+// @Summary Create Organization
+// @Description Create an organization owned by the authenticated user
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body CreateOrganizationRequest true "Organization"
+// @Success 201 {object} Organization
+// @Failure 400 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations [post]
+func (h *Handler) CreateOrganization(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	org, err := h.service.CreateOrganization(c.Request.Context(), userID, &req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// @Summary List Organizations
+// @Description Organizations the authenticated user is a member of, with their role in each
+// @Tags organizations
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations [get]
+func (h *Handler) ListOrganizations(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	orgs, err := h.service.ListOrganizations(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// @Summary Get Organization
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Success 200 {object} Organization
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/{id} [get]
+func (h *Handler) GetOrganization(c *gin.Context) {
+	userID, orgID, ok := organizationRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	org, err := h.service.GetOrganization(c.Request.Context(), orgID, userID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// @Summary Rename Organization
+// @Description Admins and owners only
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param request body UpdateOrganizationRequest true "New name"
+// @Success 200 {object} Organization
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/{id} [patch]
+func (h *Handler) UpdateOrganization(c *gin.Context) {
+	userID, orgID, ok := organizationRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	org, err := h.service.UpdateOrganization(c.Request.Context(), orgID, userID, &req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// @Summary Delete Organization
+// @Description Owners only. Jobs of the organization stay with the members who uploaded them
+// @Tags organizations
+// @Param id path string true "Organization ID"
+// @Success 204
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/{id} [delete]
+func (h *Handler) DeleteOrganization(c *gin.Context) {
+	userID, orgID, ok := organizationRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteOrganization(c.Request.Context(), orgID, userID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary List Organization Members
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/{id}/members [get]
+func (h *Handler) ListMembers(c *gin.Context) {
+	userID, orgID, ok := organizationRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.service.ListMembers(c.Request.Context(), orgID, userID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// @Summary Change Member Role
+// @Description Admins switch members between member and admin; only owners grant or take away the owner role
+// @Tags organizations
+// @Accept json
+// @Param id path string true "Organization ID"
+// @Param user_id path string true "Member user ID"
+// @Param request body UpdateMemberRoleRequest true "New role"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/{id}/members/{user_id} [patch]
+func (h *Handler) UpdateMemberRole(c *gin.Context) {
+	userID, orgID, ok := organizationRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID участника"})
+		return
+	}
+
+	var req UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	if err := h.service.UpdateMemberRole(c.Request.Context(), orgID, userID, memberID, req.Role); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Remove Member
+// @Description Remove a member, or leave the organization with your own user ID
+// @Tags organizations
+// @Param id path string true "Organization ID"
+// @Param user_id path string true "Member user ID"
+// @Success 204
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/{id}/members/{user_id} [delete]
+func (h *Handler) RemoveMember(c *gin.Context) {
+	userID, orgID, ok := organizationRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID участника"})
+		return
+	}
+
+	if err := h.service.RemoveMember(c.Request.Context(), orgID, userID, memberID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Invite Member
+// @Description Email an invitation link to FRONTEND_URL/accept-invitation. Admins and owners only
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param request body InviteMemberRequest true "Invitation"
+// @Success 201 {object} Invitation
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/{id}/invitations [post]
+func (h *Handler) InviteMember(c *gin.Context) {
+	userID, orgID, ok := organizationRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	invitation, err := h.service.InviteMember(c.Request.Context(), orgID, userID, &req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// @Summary List Invitations
+// @Description Pending invitations of the organization. Admins and owners only
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/{id}/invitations [get]
+func (h *Handler) ListInvitations(c *gin.Context) {
+	userID, orgID, ok := organizationRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.service.ListInvitations(c.Request.Context(), orgID, userID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// @Summary Revoke Invitation
+// @Tags organizations
+// @Param id path string true "Organization ID"
+// @Param invitation_id path string true "Invitation ID"
+// @Success 204
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/{id}/invitations/{invitation_id} [delete]
+func (h *Handler) RevokeInvitation(c *gin.Context) {
+	userID, orgID, ok := organizationRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("invitation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID приглашения"})
+		return
+	}
+
+	if err := h.service.RevokeInvitation(c.Request.Context(), orgID, userID, invitationID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Accept Invitation
+// @Description Join the organization with the token from an invitation link. The account email must match the invited one
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body AcceptInvitationRequest true "Invitation token"
+// @Success 200 {object} Organization
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations/invitations/accept [post]
+func (h *Handler) AcceptInvitation(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	org, err := h.service.AcceptInvitation(c.Request.Context(), userID, req.Token)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+func getUserID(c *gin.Context) (uuid.UUID, bool) {
+	userIDStr, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func organizationRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID организации"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, orgID, true
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrOrganizationNotFound), errors.Is(err, ErrMemberNotFound), errors.Is(err, ErrInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInsufficientRole), errors.Is(err, ErrInvitationEmailMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidInvitation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrAlreadyMember), errors.Is(err, ErrLastOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,34 @@
+package organization
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+type QueriesInterface interface {
+	GetByID(ctx context.Context, id uuid.UUID) (sqlc.User, error)
+
+	CreateOrganization(ctx context.Context, name string) (sqlc.Organization, error)
+	GetOrganization(ctx context.Context, id uuid.UUID) (sqlc.Organization, error)
+	LockOrganization(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+	UpdateOrganizationName(ctx context.Context, params sqlc.UpdateOrganizationNameParams) (sqlc.Organization, error)
+	DeleteOrganization(ctx context.Context, id uuid.UUID) error
+	ListUserOrganizations(ctx context.Context, userID uuid.UUID) ([]sqlc.ListUserOrganizationsRow, error)
+
+	AddOrganizationMember(ctx context.Context, params sqlc.AddOrganizationMemberParams) error
+	GetOrganizationMemberRole(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error)
+	ListOrganizationMembers(ctx context.Context, organizationID uuid.UUID) ([]sqlc.ListOrganizationMembersRow, error)
+	OrganizationMemberEmailExists(ctx context.Context, params sqlc.OrganizationMemberEmailExistsParams) (bool, error)
+	UpdateOrganizationMemberRole(ctx context.Context, params sqlc.UpdateOrganizationMemberRoleParams) (int64, error)
+	DeleteOrganizationMember(ctx context.Context, params sqlc.DeleteOrganizationMemberParams) (int64, error)
+	CountOrganizationOwners(ctx context.Context, organizationID uuid.UUID) (int64, error)
+
+	UpsertOrganizationInvitation(ctx context.Context, params sqlc.UpsertOrganizationInvitationParams) (sqlc.OrganizationInvitation, error)
+	ListOrganizationInvitations(ctx context.Context, organizationID uuid.UUID) ([]sqlc.OrganizationInvitation, error)
+	DeleteOrganizationInvitation(ctx context.Context, params sqlc.DeleteOrganizationInvitationParams) (int64, error)
+	ConsumeOrganizationInvitation(ctx context.Context, tokenHash string) (sqlc.OrganizationInvitation, error)
+	DeleteExpiredOrganizationInvitations(ctx context.Context, expiresAt time.Time) error
+}
@@ -0,0 +1,91 @@
+package organization
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+// Organization roles. Every member sees the jobs of the organization and may
+// upload to it; admins also manage the jobs of other members and invite new
+// ones; owners also change roles and delete the organization.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+var roleRanks = map[string]int{
+	RoleMember: 1,
+	RoleAdmin:  2,
+	RoleOwner:  3,
+}
+
+// CanManageJobs reports whether a member with the role may reschedule and
+// cancel jobs other members uploaded to the organization.
+func CanManageJobs(role string) bool {
+	return roleRanks[role] >= roleRanks[RoleAdmin]
+}
+
+func hasRole(role, required string) bool {
+	return roleRanks[role] >= roleRanks[required]
+}
+
+// Organization is an organization of the caller together with their role in
+// it.
+type Organization struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Member struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email"`
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type Invitation struct {
+	ID        uuid.UUID  `json:"id"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	InvitedBy *uuid.UUID `json:"invited_by,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func newOrganization(o sqlc.Organization, role string) *Organization {
+	return &Organization{
+		ID:        o.ID,
+		Name:      o.Name,
+		Role:      role,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+}
+
+func newMember(m sqlc.ListOrganizationMembersRow) *Member {
+	return &Member{
+		UserID:   m.UserID,
+		Email:    m.Email,
+		Name:     m.Name,
+		Role:     m.Role,
+		JoinedAt: m.CreatedAt,
+	}
+}
+
+func newInvitation(i sqlc.OrganizationInvitation) *Invitation {
+	return &Invitation{
+		ID:        i.ID,
+		Email:     i.Email,
+		Role:      i.Role,
+		InvitedBy: i.InvitedBy,
+		ExpiresAt: i.ExpiresAt,
+		CreatedAt: i.CreatedAt,
+	}
+}
@@ -0,0 +1,24 @@
+package organization
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+// InviteMemberRequest invites an email as a member unless Role says admin.
+// Owners are made by changing the role of a member.
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email,max=255"`
+	Role  string `json:"role" binding:"omitempty,oneof=admin member"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,544 @@
+package organization
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/mailer"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+const (
+	invitationCleanupEvery = time.Hour
+	// mailTimeout bounds the delivery of a single email.
+	mailTimeout = 30 * time.Second
+)
+
+var (
+	ErrOrganizationNotFound    = errors.New("организация не найдена")
+	ErrInsufficientRole        = errors.New("недостаточно прав в организации")
+	ErrMemberNotFound          = errors.New("участник не найден")
+	ErrInvitationNotFound      = errors.New("приглашение не найдено")
+	ErrInvalidInvitation       = errors.New("приглашение недействительно или устарело")
+	ErrInvitationEmailMismatch = errors.New("приглашение отправлено на другой email")
+	ErrAlreadyMember           = errors.New("пользователь уже состоит в организации")
+	ErrLastOwner               = errors.New("в организации должен остаться хотя бы один владелец")
+)
+
+var roleNames = map[string]string{
+	RoleOwner:  "владелец",
+	RoleAdmin:  "администратор",
+	RoleMember: "участник",
+}
+
+// Service manages organizations, their members and invitations. Access to the
+// jobs of an organization is checked by the heightmap service against the
+// same memberships.
+//
+// An invitation is a single-use link sent to an email; only the SHA-256 hash
+// of its token is stored. It can only be accepted from an account with that
+// email, and inviting the same email again replaces the previous link.
+type Service struct {
+	queries QueriesInterface
+	withTx  func(ctx context.Context, fn func(q QueriesInterface) error) error
+	mailer  mailer.Mailer
+	cfg     config.AuthConfig
+	logger  middleware.LoggerInterface
+}
+
+func NewService(db *storage.DB, m mailer.Mailer, cfg config.AuthConfig, logger middleware.LoggerInterface) *Service {
+	return &Service{
+		queries: db.Queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return db.WithTx(ctx, func(q *sqlc.Queries) error {
+				return fn(q)
+			})
+		},
+		mailer: m,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// Run deletes expired invitations until ctx is cancelled.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(invitationCleanupEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.queries.DeleteExpiredOrganizationInvitations(ctx, time.Now()); err != nil {
+				s.logger.Error("Failed to delete expired organization invitations", err)
+			}
+		}
+	}
+}
+
+// CreateOrganization creates an organization owned by the user.
+func (s *Service) CreateOrganization(ctx context.Context, userID uuid.UUID, req *CreateOrganizationRequest) (*Organization, error) {
+	var org sqlc.Organization
+	err := s.withTx(ctx, func(q QueriesInterface) error {
+		var err error
+		org, err = q.CreateOrganization(ctx, strings.TrimSpace(req.Name))
+		if err != nil {
+			return fmt.Errorf("не удалось создать организацию: %w", err)
+		}
+
+		if err := q.AddOrganizationMember(ctx, sqlc.AddOrganizationMemberParams{
+			OrganizationID: org.ID,
+			UserID:         userID,
+			Role:           RoleOwner,
+		}); err != nil {
+			return fmt.Errorf("не удалось добавить владельца организации: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Organization created", map[string]interface{}{
+		"organization_id": org.ID.String(),
+		"user_id":         userID.String(),
+	})
+	return newOrganization(org, RoleOwner), nil
+}
+
+// ListOrganizations returns the organizations the user is a member of.
+func (s *Service) ListOrganizations(ctx context.Context, userID uuid.UUID) ([]*Organization, error) {
+	rows, err := s.queries.ListUserOrganizations(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список организаций: %w", err)
+	}
+
+	result := make([]*Organization, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, &Organization{
+			ID:        row.ID,
+			Name:      row.Name,
+			Role:      row.Role,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+		})
+	}
+	return result, nil
+}
+
+func (s *Service) GetOrganization(ctx context.Context, orgID, userID uuid.UUID) (*Organization, error) {
+	role, err := s.requireRole(ctx, s.queries, orgID, userID, RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.queries.GetOrganization(ctx, orgID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("не удалось загрузить организацию: %w", err)
+	}
+	return newOrganization(org, role), nil
+}
+
+func (s *Service) UpdateOrganization(ctx context.Context, orgID, userID uuid.UUID, req *UpdateOrganizationRequest) (*Organization, error) {
+	role, err := s.requireRole(ctx, s.queries, orgID, userID, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.queries.UpdateOrganizationName(ctx, sqlc.UpdateOrganizationNameParams{
+		ID:   orgID,
+		Name: strings.TrimSpace(req.Name),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("не удалось обновить организацию: %w", err)
+	}
+	return newOrganization(org, role), nil
+}
+
+// DeleteOrganization deletes the organization. Its jobs are kept as personal
+// jobs of the members who uploaded them.
+func (s *Service) DeleteOrganization(ctx context.Context, orgID, userID uuid.UUID) error {
+	if _, err := s.requireRole(ctx, s.queries, orgID, userID, RoleOwner); err != nil {
+		return err
+	}
+
+	if err := s.queries.DeleteOrganization(ctx, orgID); err != nil {
+		return fmt.Errorf("не удалось удалить организацию: %w", err)
+	}
+
+	s.logger.Info("Organization deleted", map[string]interface{}{
+		"organization_id": orgID.String(),
+		"user_id":         userID.String(),
+	})
+	return nil
+}
+
+func (s *Service) ListMembers(ctx context.Context, orgID, userID uuid.UUID) ([]*Member, error) {
+	if _, err := s.requireRole(ctx, s.queries, orgID, userID, RoleMember); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queries.ListOrganizationMembers(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список участников: %w", err)
+	}
+
+	result := make([]*Member, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, newMember(row))
+	}
+	return result, nil
+}
+
+// UpdateMemberRole changes the role of a member. Admins switch members
+// between member and admin; only owners grant or take away the owner role,
+// and the last owner cannot be demoted.
+func (s *Service) UpdateMemberRole(ctx context.Context, orgID, userID, memberID uuid.UUID, role string) error {
+	return s.withTx(ctx, func(q QueriesInterface) error {
+		callerRole, current, err := s.lockMembers(ctx, q, orgID, userID, memberID)
+		if err != nil {
+			return err
+		}
+
+		required := RoleAdmin
+		if role == RoleOwner || current == RoleOwner {
+			required = RoleOwner
+		}
+		if !hasRole(callerRole, required) {
+			return ErrInsufficientRole
+		}
+		if current == role {
+			return nil
+		}
+		if current == RoleOwner {
+			if err := s.requireAnotherOwner(ctx, q, orgID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := q.UpdateOrganizationMemberRole(ctx, sqlc.UpdateOrganizationMemberRoleParams{
+			OrganizationID: orgID,
+			UserID:         memberID,
+			Role:           role,
+		}); err != nil {
+			return fmt.Errorf("не удалось изменить роль участника: %w", err)
+		}
+
+		s.logger.Info("Organization member role changed", map[string]interface{}{
+			"organization_id": orgID.String(),
+			"user_id":         memberID.String(),
+			"role":            role,
+			"changed_by":      userID.String(),
+		})
+		return nil
+	})
+}
+
+// RemoveMember removes a member from the organization, or lets a member
+// leave it. Admins remove members and admins, owners remove anyone; the last
+// owner can neither be removed nor leave.
+func (s *Service) RemoveMember(ctx context.Context, orgID, userID, memberID uuid.UUID) error {
+	return s.withTx(ctx, func(q QueriesInterface) error {
+		callerRole, current, err := s.lockMembers(ctx, q, orgID, userID, memberID)
+		if err != nil {
+			return err
+		}
+
+		if memberID != userID {
+			required := RoleAdmin
+			if current == RoleOwner {
+				required = RoleOwner
+			}
+			if !hasRole(callerRole, required) {
+				return ErrInsufficientRole
+			}
+		}
+		if current == RoleOwner {
+			if err := s.requireAnotherOwner(ctx, q, orgID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := q.DeleteOrganizationMember(ctx, sqlc.DeleteOrganizationMemberParams{
+			OrganizationID: orgID,
+			UserID:         memberID,
+		}); err != nil {
+			return fmt.Errorf("не удалось удалить участника: %w", err)
+		}
+
+		s.logger.Info("Organization member removed", map[string]interface{}{
+			"organization_id": orgID.String(),
+			"user_id":         memberID.String(),
+			"removed_by":      userID.String(),
+		})
+		return nil
+	})
+}
+
+// InviteMember emails an invitation to join the organization.
+func (s *Service) InviteMember(ctx context.Context, orgID, userID uuid.UUID, req *InviteMemberRequest) (*Invitation, error) {
+	if _, err := s.requireRole(ctx, s.queries, orgID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	role := req.Role
+	if role == "" {
+		role = RoleMember
+	}
+
+	member, err := s.queries.OrganizationMemberEmailExists(ctx, sqlc.OrganizationMemberEmailExistsParams{
+		OrganizationID: orgID,
+		Email:          email,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check membership: %w", err)
+	}
+	if member {
+		return nil, ErrAlreadyMember
+	}
+
+	org, err := s.queries.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось загрузить организацию: %w", err)
+	}
+	inviter, err := s.queries.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	invitation, err := s.queries.UpsertOrganizationInvitation(ctx, sqlc.UpsertOrganizationInvitationParams{
+		OrganizationID: orgID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      hashToken(token),
+		InvitedBy:      &userID,
+		ExpiresAt:      time.Now().Add(s.cfg.OrganizationInvitationTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать приглашение: %w", err)
+	}
+
+	s.sendMail(mailer.Message{
+		To:      email,
+		Subject: fmt.Sprintf("Приглашение в организацию «%s»", org.Name),
+		Body: fmt.Sprintf("Здравствуйте!\n\n%s приглашает вас в организацию «%s» с ролью «%s». Чтобы принять приглашение, войдите в учетную запись с этим email или зарегистрируйтесь и перейдите по ссылке:\n%s\n\nСсылка действует до %s.\n",
+			inviter.Name, org.Name, roleNames[role], s.cfg.AppURL+"/accept-invitation?token="+url.QueryEscape(token), invitation.ExpiresAt.Format("02.01.2006 15:04 MST")),
+	})
+
+	s.logger.Info("Organization invitation sent", map[string]interface{}{
+		"organization_id": orgID.String(),
+		"invitation_id":   invitation.ID.String(),
+		"invited_by":      userID.String(),
+	})
+	return newInvitation(invitation), nil
+}
+
+func (s *Service) ListInvitations(ctx context.Context, orgID, userID uuid.UUID) ([]*Invitation, error) {
+	if _, err := s.requireRole(ctx, s.queries, orgID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	invitations, err := s.queries.ListOrganizationInvitations(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список приглашений: %w", err)
+	}
+
+	result := make([]*Invitation, 0, len(invitations))
+	for _, invitation := range invitations {
+		result = append(result, newInvitation(invitation))
+	}
+	return result, nil
+}
+
+func (s *Service) RevokeInvitation(ctx context.Context, orgID, userID, invitationID uuid.UUID) error {
+	if _, err := s.requireRole(ctx, s.queries, orgID, userID, RoleAdmin); err != nil {
+		return err
+	}
+
+	deleted, err := s.queries.DeleteOrganizationInvitation(ctx, sqlc.DeleteOrganizationInvitationParams{
+		ID:             invitationID,
+		OrganizationID: orgID,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать приглашение: %w", err)
+	}
+	if deleted == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation adds the user to the organization of the invitation. The
+// email of the account must be the one the invitation was sent to; otherwise
+// the invitation is kept for its addressee.
+func (s *Service) AcceptInvitation(ctx context.Context, userID uuid.UUID, rawToken string) (*Organization, error) {
+	user, err := s.queries.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	var result *Organization
+	err = s.withTx(ctx, func(q QueriesInterface) error {
+		invitation, err := q.ConsumeOrganizationInvitation(ctx, hashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidInvitation
+			}
+			return fmt.Errorf("failed to load invitation: %w", err)
+		}
+		if !invitation.ExpiresAt.After(time.Now()) {
+			return ErrInvalidInvitation
+		}
+		if !strings.EqualFold(strings.TrimSpace(user.Email), invitation.Email) {
+			return ErrInvitationEmailMismatch
+		}
+
+		role, err := q.GetOrganizationMemberRole(ctx, sqlc.GetOrganizationMemberRoleParams{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         userID,
+		})
+		switch {
+		case err == nil:
+			// Already a member: the invitation is used up without touching
+			// the role.
+		case errors.Is(err, pgx.ErrNoRows):
+			role = invitation.Role
+			if err := q.AddOrganizationMember(ctx, sqlc.AddOrganizationMemberParams{
+				OrganizationID: invitation.OrganizationID,
+				UserID:         userID,
+				Role:           role,
+			}); err != nil {
+				return fmt.Errorf("не удалось добавить участника: %w", err)
+			}
+		default:
+			return fmt.Errorf("failed to check membership: %w", err)
+		}
+
+		org, err := q.GetOrganization(ctx, invitation.OrganizationID)
+		if err != nil {
+			return fmt.Errorf("не удалось загрузить организацию: %w", err)
+		}
+		result = newOrganization(org, role)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Organization invitation accepted", map[string]interface{}{
+		"organization_id": result.ID.String(),
+		"user_id":         userID.String(),
+	})
+	return result, nil
+}
+
+// requireRole returns the role of the user in the organization. Organizations
+// the user is not a member of are reported as not found.
+func (s *Service) requireRole(ctx context.Context, q QueriesInterface, orgID, userID uuid.UUID, required string) (string, error) {
+	role, err := q.GetOrganizationMemberRole(ctx, sqlc.GetOrganizationMemberRoleParams{
+		OrganizationID: orgID,
+		UserID:         userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrOrganizationNotFound
+		}
+		return "", fmt.Errorf("failed to load organization role: %w", err)
+	}
+	if !hasRole(role, required) {
+		return "", ErrInsufficientRole
+	}
+	return role, nil
+}
+
+// lockMembers locks the organization, so concurrent role changes cannot leave
+// it without an owner, and returns the roles of the caller and the member.
+func (s *Service) lockMembers(ctx context.Context, q QueriesInterface, orgID, userID, memberID uuid.UUID) (string, string, error) {
+	if _, err := q.LockOrganization(ctx, orgID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", "", ErrOrganizationNotFound
+		}
+		return "", "", fmt.Errorf("failed to lock organization: %w", err)
+	}
+
+	callerRole, err := s.requireRole(ctx, q, orgID, userID, RoleMember)
+	if err != nil {
+		return "", "", err
+	}
+
+	memberRole, err := q.GetOrganizationMemberRole(ctx, sqlc.GetOrganizationMemberRoleParams{
+		OrganizationID: orgID,
+		UserID:         memberID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", "", ErrMemberNotFound
+		}
+		return "", "", fmt.Errorf("failed to load organization role: %w", err)
+	}
+	return callerRole, memberRole, nil
+}
+
+func (s *Service) requireAnotherOwner(ctx context.Context, q QueriesInterface, orgID uuid.UUID) error {
+	owners, err := q.CountOrganizationOwners(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to count organization owners: %w", err)
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// sendMail delivers in the background so the response does not wait for the
+// SMTP server.
+func (s *Service) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			s.logger.Error("Failed to send email", err, map[string]interface{}{
+				"subject": msg.Subject,
+			})
+		}
+	}()
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,391 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/mailer"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...map[string]interface{})            {}
+func (nopLogger) Info(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Warn(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Error(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) Fatal(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) WithContext(ctx context.Context) *middleware.Logger            { return nil }
+func (nopLogger) Middleware() gin.HandlerFunc                                   { return nil }
+
+type mockMailer struct {
+	sent chan mailer.Message
+}
+
+func (m *mockMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+// mockQueries keeps the members of a single organization in memory; the
+// other queries are stubbed with func fields.
+type mockQueries struct {
+	members map[uuid.UUID]string
+
+	getByIDFunc      func(ctx context.Context, id uuid.UUID) (sqlc.User, error)
+	upsertInviteFunc func(ctx context.Context, params sqlc.UpsertOrganizationInvitationParams) (sqlc.OrganizationInvitation, error)
+	consumeFunc      func(ctx context.Context, tokenHash string) (sqlc.OrganizationInvitation, error)
+	deleteOrgFunc    func(ctx context.Context, id uuid.UUID) error
+}
+
+func (m *mockQueries) GetByID(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+	if m.getByIDFunc != nil {
+		return m.getByIDFunc(ctx, id)
+	}
+	return sqlc.User{ID: id}, nil
+}
+
+func (m *mockQueries) CreateOrganization(ctx context.Context, name string) (sqlc.Organization, error) {
+	return sqlc.Organization{ID: uuid.New(), Name: name}, nil
+}
+
+func (m *mockQueries) GetOrganization(ctx context.Context, id uuid.UUID) (sqlc.Organization, error) {
+	return sqlc.Organization{ID: id, Name: "Геодезия"}, nil
+}
+
+func (m *mockQueries) LockOrganization(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	return id, nil
+}
+
+func (m *mockQueries) UpdateOrganizationName(ctx context.Context, params sqlc.UpdateOrganizationNameParams) (sqlc.Organization, error) {
+	return sqlc.Organization{ID: params.ID, Name: params.Name}, nil
+}
+
+func (m *mockQueries) DeleteOrganization(ctx context.Context, id uuid.UUID) error {
+	if m.deleteOrgFunc != nil {
+		return m.deleteOrgFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockQueries) ListUserOrganizations(ctx context.Context, userID uuid.UUID) ([]sqlc.ListUserOrganizationsRow, error) {
+	return nil, nil
+}
+
+func (m *mockQueries) AddOrganizationMember(ctx context.Context, params sqlc.AddOrganizationMemberParams) error {
+	if m.members == nil {
+		m.members = map[uuid.UUID]string{}
+	}
+	m.members[params.UserID] = params.Role
+	return nil
+}
+
+func (m *mockQueries) GetOrganizationMemberRole(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error) {
+	role, ok := m.members[params.UserID]
+	if !ok {
+		return "", pgx.ErrNoRows
+	}
+	return role, nil
+}
+
+func (m *mockQueries) ListOrganizationMembers(ctx context.Context, organizationID uuid.UUID) ([]sqlc.ListOrganizationMembersRow, error) {
+	return nil, nil
+}
+
+func (m *mockQueries) OrganizationMemberEmailExists(ctx context.Context, params sqlc.OrganizationMemberEmailExistsParams) (bool, error) {
+	return false, nil
+}
+
+func (m *mockQueries) UpdateOrganizationMemberRole(ctx context.Context, params sqlc.UpdateOrganizationMemberRoleParams) (int64, error) {
+	m.members[params.UserID] = params.Role
+	return 1, nil
+}
+
+func (m *mockQueries) DeleteOrganizationMember(ctx context.Context, params sqlc.DeleteOrganizationMemberParams) (int64, error) {
+	delete(m.members, params.UserID)
+	return 1, nil
+}
+
+func (m *mockQueries) CountOrganizationOwners(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	var owners int64
+	for _, role := range m.members {
+		if role == RoleOwner {
+			owners++
+		}
+	}
+	return owners, nil
+}
+
+func (m *mockQueries) UpsertOrganizationInvitation(ctx context.Context, params sqlc.UpsertOrganizationInvitationParams) (sqlc.OrganizationInvitation, error) {
+	if m.upsertInviteFunc != nil {
+		return m.upsertInviteFunc(ctx, params)
+	}
+	return sqlc.OrganizationInvitation{ID: uuid.New(), Email: params.Email, Role: params.Role, ExpiresAt: params.ExpiresAt}, nil
+}
+
+func (m *mockQueries) ListOrganizationInvitations(ctx context.Context, organizationID uuid.UUID) ([]sqlc.OrganizationInvitation, error) {
+	return nil, nil
+}
+
+func (m *mockQueries) DeleteOrganizationInvitation(ctx context.Context, params sqlc.DeleteOrganizationInvitationParams) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockQueries) ConsumeOrganizationInvitation(ctx context.Context, tokenHash string) (sqlc.OrganizationInvitation, error) {
+	if m.consumeFunc != nil {
+		return m.consumeFunc(ctx, tokenHash)
+	}
+	return sqlc.OrganizationInvitation{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) DeleteExpiredOrganizationInvitations(ctx context.Context, expiresAt time.Time) error {
+	return nil
+}
+
+func newTestService(queries *mockQueries) (*Service, *mockMailer) {
+	m := &mockMailer{sent: make(chan mailer.Message, 10)}
+	return &Service{
+		queries: queries,
+		withTx: func(ctx context.Context, fn func(q QueriesInterface) error) error {
+			return fn(queries)
+		},
+		mailer: m,
+		cfg:    config.AuthConfig{AppURL: "https://app.example.com", OrganizationInvitationTTL: 24 * time.Hour},
+		logger: nopLogger{},
+	}, m
+}
+
+func TestCreateOrganizationMakesOwner(t *testing.T) {
+	queries := &mockQueries{}
+	s, _ := newTestService(queries)
+	userID := uuid.New()
+
+	org, err := s.CreateOrganization(context.Background(), userID, &CreateOrganizationRequest{Name: "  Геодезия  "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if org.Name != "Геодезия" || org.Role != RoleOwner {
+		t.Errorf("expected trimmed name and owner role, got %+v", org)
+	}
+	if queries.members[userID] != RoleOwner {
+		t.Errorf("expected the creator to be an owner, got %q", queries.members[userID])
+	}
+}
+
+func TestUpdateMemberRole(t *testing.T) {
+	owner := uuid.New()
+	admin := uuid.New()
+	member := uuid.New()
+
+	tests := []struct {
+		name        string
+		caller      uuid.UUID
+		target      uuid.UUID
+		role        string
+		expectedErr error
+	}{
+		{name: "admin promotes member to admin", caller: admin, target: member, role: RoleAdmin},
+		{name: "admin cannot grant owner", caller: admin, target: member, role: RoleOwner, expectedErr: ErrInsufficientRole},
+		{name: "member cannot change roles", caller: member, target: admin, role: RoleMember, expectedErr: ErrInsufficientRole},
+		{name: "owner grants owner", caller: owner, target: admin, role: RoleOwner},
+		{name: "last owner cannot step down", caller: owner, target: owner, role: RoleAdmin, expectedErr: ErrLastOwner},
+		{name: "unknown member", caller: owner, target: uuid.New(), role: RoleAdmin, expectedErr: ErrMemberNotFound},
+		{name: "outsider sees no organization", caller: uuid.New(), target: member, role: RoleAdmin, expectedErr: ErrOrganizationNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := &mockQueries{members: map[uuid.UUID]string{owner: RoleOwner, admin: RoleAdmin, member: RoleMember}}
+			s, _ := newTestService(queries)
+
+			err := s.UpdateMemberRole(context.Background(), uuid.New(), tt.caller, tt.target, tt.role)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if queries.members[tt.target] != tt.role {
+				t.Errorf("expected role %q, got %q", tt.role, queries.members[tt.target])
+			}
+		})
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	owner := uuid.New()
+	admin := uuid.New()
+	member := uuid.New()
+
+	tests := []struct {
+		name        string
+		caller      uuid.UUID
+		target      uuid.UUID
+		expectedErr error
+	}{
+		{name: "member leaves", caller: member, target: member},
+		{name: "admin removes member", caller: admin, target: member},
+		{name: "member cannot remove others", caller: member, target: admin, expectedErr: ErrInsufficientRole},
+		{name: "admin cannot remove owner", caller: admin, target: owner, expectedErr: ErrInsufficientRole},
+		{name: "last owner cannot leave", caller: owner, target: owner, expectedErr: ErrLastOwner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := &mockQueries{members: map[uuid.UUID]string{owner: RoleOwner, admin: RoleAdmin, member: RoleMember}}
+			s, _ := newTestService(queries)
+
+			err := s.RemoveMember(context.Background(), uuid.New(), tt.caller, tt.target)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if _, ok := queries.members[tt.target]; !ok {
+					t.Error("the member must stay when removal is refused")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, ok := queries.members[tt.target]; ok {
+				t.Error("expected the member to be removed")
+			}
+		})
+	}
+}
+
+func TestDeleteOrganizationRequiresOwner(t *testing.T) {
+	admin := uuid.New()
+	deleted := false
+	queries := &mockQueries{
+		members: map[uuid.UUID]string{admin: RoleAdmin},
+		deleteOrgFunc: func(ctx context.Context, id uuid.UUID) error {
+			deleted = true
+			return nil
+		},
+	}
+	s, _ := newTestService(queries)
+
+	err := s.DeleteOrganization(context.Background(), uuid.New(), admin)
+	if !errors.Is(err, ErrInsufficientRole) {
+		t.Fatalf("expected %v, got %v", ErrInsufficientRole, err)
+	}
+	if deleted {
+		t.Error("an admin must not delete the organization")
+	}
+}
+
+func TestInviteMember(t *testing.T) {
+	admin := uuid.New()
+	var stored sqlc.UpsertOrganizationInvitationParams
+	queries := &mockQueries{
+		members: map[uuid.UUID]string{admin: RoleAdmin},
+		upsertInviteFunc: func(ctx context.Context, params sqlc.UpsertOrganizationInvitationParams) (sqlc.OrganizationInvitation, error) {
+			stored = params
+			return sqlc.OrganizationInvitation{ID: uuid.New(), Email: params.Email, Role: params.Role, ExpiresAt: params.ExpiresAt}, nil
+		},
+	}
+	s, m := newTestService(queries)
+
+	invitation, err := s.InviteMember(context.Background(), uuid.New(), admin, &InviteMemberRequest{Email: "New.Member@Example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if invitation.Email != "new.member@example.com" || invitation.Role != RoleMember {
+		t.Errorf("expected a member invitation for the lowercased email, got %+v", invitation)
+	}
+
+	select {
+	case msg := <-m.sent:
+		prefix := "https://app.example.com/accept-invitation?token="
+		i := strings.Index(msg.Body, prefix)
+		if i < 0 {
+			t.Fatalf("expected an invitation link in %q", msg.Body)
+		}
+		token := strings.Fields(msg.Body[i+len(prefix):])[0]
+		// Only the hash of the token may reach the database.
+		if stored.TokenHash != hashToken(token) {
+			t.Error("expected the stored hash to match the emailed token")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an invitation email")
+	}
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	orgID := uuid.New()
+	userID := uuid.New()
+
+	tests := []struct {
+		name        string
+		accountMail string
+		expiresAt   time.Time
+		members     map[uuid.UUID]string
+		expectRole  string
+		expectedErr error
+	}{
+		{name: "joins with the invited role", accountMail: "Invitee@Example.com", expiresAt: time.Now().Add(time.Hour), expectRole: RoleAdmin},
+		{name: "existing member keeps role", accountMail: "invitee@example.com", expiresAt: time.Now().Add(time.Hour), members: map[uuid.UUID]string{userID: RoleOwner}, expectRole: RoleOwner},
+		{name: "another account", accountMail: "someone@example.com", expiresAt: time.Now().Add(time.Hour), expectedErr: ErrInvitationEmailMismatch},
+		{name: "expired", accountMail: "invitee@example.com", expiresAt: time.Now().Add(-time.Minute), expectedErr: ErrInvalidInvitation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := &mockQueries{
+				members: tt.members,
+				getByIDFunc: func(ctx context.Context, id uuid.UUID) (sqlc.User, error) {
+					return sqlc.User{ID: id, Email: tt.accountMail}, nil
+				},
+				consumeFunc: func(ctx context.Context, tokenHash string) (sqlc.OrganizationInvitation, error) {
+					if tokenHash != hashToken("token") {
+						return sqlc.OrganizationInvitation{}, pgx.ErrNoRows
+					}
+					return sqlc.OrganizationInvitation{OrganizationID: orgID, Email: "invitee@example.com", Role: RoleAdmin, ExpiresAt: tt.expiresAt}, nil
+				},
+			}
+			s, _ := newTestService(queries)
+
+			org, err := s.AcceptInvitation(context.Background(), userID, "token")
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if _, ok := queries.members[userID]; ok {
+					t.Error("the user must not join on a refused invitation")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if org.ID != orgID || org.Role != tt.expectRole || queries.members[userID] != tt.expectRole {
+				t.Errorf("expected membership with role %q, got %+v (stored %q)", tt.expectRole, org, queries.members[userID])
+			}
+		})
+	}
+}
+
+func TestAcceptUnknownInvitation(t *testing.T) {
+	s, _ := newTestService(&mockQueries{})
+
+	_, err := s.AcceptInvitation(context.Background(), uuid.New(), "unknown")
+	if !errors.Is(err, ErrInvalidInvitation) {
+		t.Fatalf("expected %v, got %v", ErrInvalidInvitation, err)
+	}
+}
@@ -0,0 +1,100 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// earthRadius is the WGS 84 equatorial radius in meters.
+const earthRadius = 6378137.0
+
+type polygonGeometry struct {
+	Type        string        `json:"type"`
+	Coordinates [][][]float64 `json:"coordinates"`
+}
+
+// parseSitePolygon validates a GeoJSON Polygon: closed rings of at least four
+// longitude/latitude positions, the first ring being the boundary and the
+// others holes.
+func parseSitePolygon(raw []byte) (*polygonGeometry, error) {
+	var geom polygonGeometry
+	if err := json.Unmarshal(raw, &geom); err != nil {
+		return nil, fmt.Errorf("%w: ожидается объект GeoJSON", ErrInvalidSitePolygon)
+	}
+	if geom.Type != "Polygon" {
+		return nil, fmt.Errorf("%w: поддерживается только тип Polygon", ErrInvalidSitePolygon)
+	}
+	if len(geom.Coordinates) == 0 {
+		return nil, fmt.Errorf("%w: нет координат", ErrInvalidSitePolygon)
+	}
+
+	for _, ring := range geom.Coordinates {
+		if len(ring) < 4 {
+			return nil, fmt.Errorf("%w: контур должен содержать не меньше четырёх точек", ErrInvalidSitePolygon)
+		}
+		for _, position := range ring {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("%w: точка должна содержать долготу и широту", ErrInvalidSitePolygon)
+			}
+			if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+				return nil, fmt.Errorf("%w: координаты вне допустимого диапазона", ErrInvalidSitePolygon)
+			}
+		}
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			return nil, fmt.Errorf("%w: контур должен быть замкнут", ErrInvalidSitePolygon)
+		}
+	}
+
+	return &geom, nil
+}
+
+// area returns the area of the polygon in square meters on a sphere: the
+// boundary minus the holes.
+func (g *polygonGeometry) area() float64 {
+	total := 0.0
+	for i, ring := range g.Coordinates {
+		if i == 0 {
+			total += ringArea(ring)
+		} else {
+			total -= ringArea(ring)
+		}
+	}
+	return math.Max(total, 0)
+}
+
+// ringArea follows "Some Algorithms for Polygons on a Sphere" (Chamberlain,
+// Duquette), the same approximation turf and OpenLayers use.
+func ringArea(ring [][]float64) float64 {
+	n := len(ring) - 1 // the last position repeats the first
+	if n < 3 {
+		return 0
+	}
+
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		prev := ring[(i+n-1)%n]
+		next := ring[(i+1)%n]
+		sum += (radians(next[0]) - radians(prev[0])) * math.Sin(radians(ring[i][1]))
+	}
+	return math.Abs(sum * earthRadius * earthRadius / 2)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// siteArea returns the area of a stored polygon, or nil when the project has
+// none.
+func siteArea(raw []byte) *float64 {
+	if len(raw) == 0 {
+		return nil
+	}
+	geom, err := parseSitePolygon(raw)
+	if err != nil {
+		return nil
+	}
+	area := geom.area()
+	return &area
+}
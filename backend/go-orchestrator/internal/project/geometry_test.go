@@ -0,0 +1,54 @@
+package project
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPolygonArea(t *testing.T) {
+	// One degree square at the equator is about 12391 km² on a sphere with
+	// the WGS 84 equatorial radius.
+	square, err := parseSitePolygon([]byte(`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`))
+	if err != nil {
+		t.Fatalf("parseSitePolygon() error = %v", err)
+	}
+	if got := square.area(); math.Abs(got-12391399902) > 1e7 {
+		t.Errorf("area() = %.0f, want about 12391399902", got)
+	}
+
+	// The winding order does not matter, and holes are subtracted.
+	withHole, err := parseSitePolygon([]byte(`{"type":"Polygon","coordinates":[
+		[[0,0],[0,1],[1,1],[1,0],[0,0]],
+		[[0.25,0.25],[0.75,0.25],[0.75,0.75],[0.25,0.75],[0.25,0.25]]
+	]}`))
+	if err != nil {
+		t.Fatalf("parseSitePolygon() error = %v", err)
+	}
+	if got, want := withHole.area(), square.area()*0.75; math.Abs(got-want)/want > 0.001 {
+		t.Errorf("area() with a hole = %.0f, want about %.0f", got, want)
+	}
+}
+
+func TestParseSitePolygonRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"not json", `polygon`},
+		{"wrong type", `{"type":"Point","coordinates":[0,0]}`},
+		{"no rings", `{"type":"Polygon","coordinates":[]}`},
+		{"too few points", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,0]]]}`},
+		{"open ring", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`},
+		{"out of range", `{"type":"Polygon","coordinates":[[[0,0],[200,0],[1,1],[0,0]]]}`},
+		{"short position", `{"type":"Polygon","coordinates":[[[0],[1,0],[1,1],[0]]]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseSitePolygon([]byte(tt.raw)); !errors.Is(err, ErrInvalidSitePolygon) {
+				t.Errorf("parseSitePolygon() error = %v, want ErrInvalidSitePolygon", err)
+			}
+		})
+	}
+}
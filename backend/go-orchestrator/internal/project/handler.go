@@ -0,0 +1,230 @@
+package project
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMiddleware) {
+	protected := r.Group("projects")
+	protected.Use(jwtMiddleware.RequireAuth())
+	{
+		protected.POST("", h.CreateProject)
+		protected.GET("", h.ListProjects)
+		protected.GET("/:id", h.GetProject)
+		protected.PATCH("/:id", h.UpdateProject)
+		protected.DELETE("/:id", h.DeleteProject)
+	}
+}
+
+// @Summary Create Project
+// @Description Create a personal project, or an organization project when organization_id is set
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param request body CreateProjectRequest true "Project"
+// @Success 201 {object} Project
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/projects [post]
+func (h *Handler) CreateProject(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	project, err := h.service.CreateProject(c.Request.Context(), userID, &req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+// @Summary List Projects
+// @Description Personal projects of the authenticated user and projects of their organizations, with stats
+// @Tags projects
+// @Produce json
+// @Param tag query string false "Only projects with this tag"
+// @Param limit query int false "Limit" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/projects [get]
+func (h *Handler) ListProjects(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	projects, err := h.service.ListProjects(c.Request.Context(), userID, c.Query("tag"), int32(limit), int32(offset))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"projects": projects,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// @Summary Get Project
+// @Description Project with job count, last flight date and site area
+// @Tags projects
+// @Produce json
+// @Param id path string true "Project ID"
+// @Success 200 {object} Project
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/projects/{id} [get]
+func (h *Handler) GetProject(c *gin.Context) {
+	userID, projectID, ok := projectRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	project, err := h.service.GetProject(c.Request.Context(), projectID, userID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// @Summary Update Project
+// @Description The creator, or admins and owners of the organization. Omitted fields are kept; a null site_polygon removes it
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path string true "Project ID"
+// @Param request body UpdateProjectRequest true "Changes"
+// @Success 200 {object} Project
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/projects/{id} [patch]
+func (h *Handler) UpdateProject(c *gin.Context) {
+	userID, projectID, ok := projectRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	project, err := h.service.UpdateProject(c.Request.Context(), projectID, userID, &req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// @Summary Delete Project
+// @Description The creator, or admins and owners of the organization. Jobs of the project are kept without a project
+// @Tags projects
+// @Param id path string true "Project ID"
+// @Success 204
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/projects/{id} [delete]
+func (h *Handler) DeleteProject(c *gin.Context) {
+	userID, projectID, ok := projectRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteProject(c.Request.Context(), projectID, userID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func getUserID(c *gin.Context) (uuid.UUID, bool) {
+	userIDStr, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func projectRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID проекта"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, projectID, true
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrOrganizationAccessDenied), errors.Is(err, ErrInsufficientRights):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidName), errors.Is(err, ErrInvalidSitePolygon), errors.Is(err, ErrInvalidTags):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,19 @@
+package project
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+type QueriesInterface interface {
+	CreateProject(ctx context.Context, params sqlc.CreateProjectParams) (sqlc.Project, error)
+	GetAccessibleProject(ctx context.Context, params sqlc.GetAccessibleProjectParams) (sqlc.Project, error)
+	ListAccessibleProjects(ctx context.Context, params sqlc.ListAccessibleProjectsParams) ([]sqlc.ListAccessibleProjectsRow, error)
+	GetProjectStats(ctx context.Context, projectID *uuid.UUID) (sqlc.GetProjectStatsRow, error)
+	UpdateProject(ctx context.Context, params sqlc.UpdateProjectParams) (sqlc.Project, error)
+	DeleteProject(ctx context.Context, id uuid.UUID) error
+
+	GetOrganizationMemberRole(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error)
+}
@@ -0,0 +1,70 @@
+package project
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+// Project groups the jobs of one client site. A project without an
+// organization is personal to its creator; otherwise every member of the
+// organization sees it.
+type Project struct {
+	ID             uuid.UUID       `json:"id"`
+	OrganizationID *uuid.UUID      `json:"organization_id,omitempty"`
+	CreatedBy      *uuid.UUID      `json:"created_by,omitempty"`
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	SitePolygon    json.RawMessage `json:"site_polygon,omitempty"`
+	Tags           []string        `json:"tags"`
+	Stats          Stats           `json:"stats"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Stats aggregates the single and batch jobs of a project. LastFlightAt is
+// the upload time of the newest job, and AreaM2 is the area of the site
+// polygon in square meters.
+type Stats struct {
+	JobCount     int64      `json:"job_count"`
+	LastFlightAt *time.Time `json:"last_flight_at,omitempty"`
+	AreaM2       *float64   `json:"area_m2,omitempty"`
+}
+
+func newProject(p sqlc.Project, stats sqlc.GetProjectStatsRow) *Project {
+	return &Project{
+		ID:             p.ID,
+		OrganizationID: p.OrganizationID,
+		CreatedBy:      p.CreatedBy,
+		Name:           p.Name,
+		Description:    p.Description,
+		SitePolygon:    p.SitePolygon,
+		Tags:           p.Tags,
+		Stats: Stats{
+			JobCount:     stats.JobCount,
+			LastFlightAt: stats.LastJobAt,
+			AreaM2:       siteArea(p.SitePolygon),
+		},
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+func newListedProject(row sqlc.ListAccessibleProjectsRow) *Project {
+	return newProject(sqlc.Project{
+		ID:             row.ID,
+		CreatedBy:      row.CreatedBy,
+		OrganizationID: row.OrganizationID,
+		Name:           row.Name,
+		Description:    row.Description,
+		SitePolygon:    row.SitePolygon,
+		Tags:           row.Tags,
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+	}, sqlc.GetProjectStatsRow{
+		JobCount:  row.JobCount,
+		LastJobAt: row.LastJobAt,
+	})
+}
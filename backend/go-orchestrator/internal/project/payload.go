@@ -0,0 +1,26 @@
+package project
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// CreateProjectRequest creates a personal project unless OrganizationID is
+// set. SitePolygon is a GeoJSON Polygon in WGS 84.
+type CreateProjectRequest struct {
+	Name           string          `json:"name" binding:"required,max=255"`
+	Description    string          `json:"description" binding:"max=5000"`
+	SitePolygon    json.RawMessage `json:"site_polygon"`
+	Tags           []string        `json:"tags"`
+	OrganizationID *uuid.UUID      `json:"organization_id"`
+}
+
+// UpdateProjectRequest changes only the fields that are present. A null
+// site_polygon removes the polygon.
+type UpdateProjectRequest struct {
+	Name        *string         `json:"name" binding:"omitempty,max=255"`
+	Description *string         `json:"description" binding:"omitempty,max=5000"`
+	SitePolygon json.RawMessage `json:"site_polygon"`
+	Tags        *[]string       `json:"tags"`
+}
@@ -0,0 +1,283 @@
+package project
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/organization"
+	"github.com/skr1ms/dev2gis/internal/storage"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+const (
+	maxTags      = 20
+	maxTagLength = 50
+)
+
+var (
+	ErrProjectNotFound          = errors.New("проект не найден")
+	ErrOrganizationAccessDenied = errors.New("вы не состоите в этой организации")
+	ErrInsufficientRights       = errors.New("недостаточно прав для изменения проекта")
+	ErrInvalidName              = errors.New("название проекта не может быть пустым")
+	ErrInvalidSitePolygon       = errors.New("некорректный полигон участка")
+	ErrInvalidTags              = errors.New("некорректные теги")
+)
+
+// Service manages projects. Jobs are attached to a project at upload by the
+// heightmap service, which checks access with the same rules: the creator
+// sees a personal project, every member sees an organization project.
+// Changing or deleting a project is left to its creator and to the admins
+// and owners of its organization.
+type Service struct {
+	queries QueriesInterface
+	logger  middleware.LoggerInterface
+}
+
+func NewService(db *storage.DB, logger middleware.LoggerInterface) *Service {
+	return &Service{
+		queries: db.Queries,
+		logger:  logger,
+	}
+}
+
+func (s *Service) CreateProject(ctx context.Context, userID uuid.UUID, req *CreateProjectRequest) (*Project, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+
+	sitePolygon, err := normalizeSitePolygon(req.SitePolygon)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.OrganizationID != nil {
+		if _, err := s.organizationRole(ctx, *req.OrganizationID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	project, err := s.queries.CreateProject(ctx, sqlc.CreateProjectParams{
+		CreatedBy:      &userID,
+		OrganizationID: req.OrganizationID,
+		Name:           name,
+		Description:    strings.TrimSpace(req.Description),
+		SitePolygon:    sitePolygon,
+		Tags:           tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать проект: %w", err)
+	}
+
+	s.logger.Info("Project created", map[string]interface{}{
+		"project_id": project.ID.String(),
+		"user_id":    userID.String(),
+	})
+	return newProject(project, sqlc.GetProjectStatsRow{}), nil
+}
+
+// ListProjects returns the personal projects of the user and the projects of
+// their organizations, optionally only those with the tag.
+func (s *Service) ListProjects(ctx context.Context, userID uuid.UUID, tag string, limit, offset int32) ([]*Project, error) {
+	params := sqlc.ListAccessibleProjectsParams{
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	}
+	if tag = normalizeTag(tag); tag != "" {
+		params.Tag = &tag
+	}
+
+	rows, err := s.queries.ListAccessibleProjects(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список проектов: %w", err)
+	}
+
+	result := make([]*Project, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, newListedProject(row))
+	}
+	return result, nil
+}
+
+func (s *Service) GetProject(ctx context.Context, projectID, userID uuid.UUID) (*Project, error) {
+	project, err := s.getProject(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withStats(ctx, project)
+}
+
+func (s *Service) UpdateProject(ctx context.Context, projectID, userID uuid.UUID, req *UpdateProjectRequest) (*Project, error) {
+	project, err := s.getEditableProject(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := sqlc.UpdateProjectParams{
+		ID:          project.ID,
+		Name:        project.Name,
+		Description: project.Description,
+		SitePolygon: project.SitePolygon,
+		Tags:        project.Tags,
+	}
+	if req.Name != nil {
+		params.Name = strings.TrimSpace(*req.Name)
+		if params.Name == "" {
+			return nil, ErrInvalidName
+		}
+	}
+	if req.Description != nil {
+		params.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.SitePolygon != nil {
+		if params.SitePolygon, err = normalizeSitePolygon(req.SitePolygon); err != nil {
+			return nil, err
+		}
+	}
+	if req.Tags != nil {
+		if params.Tags, err = normalizeTags(*req.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.queries.UpdateProject(ctx, params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("не удалось обновить проект: %w", err)
+	}
+	return s.withStats(ctx, updated)
+}
+
+// DeleteProject deletes the project. Its jobs are kept without a project.
+func (s *Service) DeleteProject(ctx context.Context, projectID, userID uuid.UUID) error {
+	project, err := s.getEditableProject(ctx, projectID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.queries.DeleteProject(ctx, project.ID); err != nil {
+		return fmt.Errorf("не удалось удалить проект: %w", err)
+	}
+
+	s.logger.Info("Project deleted", map[string]interface{}{
+		"project_id": project.ID.String(),
+		"user_id":    userID.String(),
+	})
+	return nil
+}
+
+func (s *Service) getProject(ctx context.Context, projectID, userID uuid.UUID) (sqlc.Project, error) {
+	project, err := s.queries.GetAccessibleProject(ctx, sqlc.GetAccessibleProjectParams{
+		ID:     projectID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.Project{}, ErrProjectNotFound
+		}
+		return sqlc.Project{}, fmt.Errorf("не удалось загрузить проект: %w", err)
+	}
+	return project, nil
+}
+
+// getEditableProject returns the project if the user created it or manages
+// the jobs of its organization.
+func (s *Service) getEditableProject(ctx context.Context, projectID, userID uuid.UUID) (sqlc.Project, error) {
+	project, err := s.getProject(ctx, projectID, userID)
+	if err != nil {
+		return sqlc.Project{}, err
+	}
+	if project.CreatedBy != nil && *project.CreatedBy == userID {
+		return project, nil
+	}
+	if project.OrganizationID == nil {
+		return sqlc.Project{}, ErrInsufficientRights
+	}
+
+	role, err := s.organizationRole(ctx, *project.OrganizationID, userID)
+	if err != nil {
+		return sqlc.Project{}, err
+	}
+	if !organization.CanManageJobs(role) {
+		return sqlc.Project{}, ErrInsufficientRights
+	}
+	return project, nil
+}
+
+func (s *Service) organizationRole(ctx context.Context, organizationID, userID uuid.UUID) (string, error) {
+	role, err := s.queries.GetOrganizationMemberRole(ctx, sqlc.GetOrganizationMemberRoleParams{
+		OrganizationID: organizationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrOrganizationAccessDenied
+		}
+		return "", fmt.Errorf("не удалось получить роль в организации: %w", err)
+	}
+	return role, nil
+}
+
+func (s *Service) withStats(ctx context.Context, project sqlc.Project) (*Project, error) {
+	stats, err := s.queries.GetProjectStats(ctx, &project.ID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить статистику проекта: %w", err)
+	}
+	return newProject(project, stats), nil
+}
+
+// normalizeSitePolygon validates a polygon from a request. Both a missing
+// polygon and an explicit null mean no polygon.
+func normalizeSitePolygon(raw []byte) ([]byte, error) {
+	if len(raw) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+	if _, err := parseSitePolygon(raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// normalizeTags lowercases and trims the tags and drops empty ones and
+// duplicates, keeping the order. The result is never nil, the column is not
+// nullable.
+func normalizeTags(tags []string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" {
+			continue
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("%w: тег длиннее %d символов", ErrInvalidTags, maxTagLength)
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		result = append(result, tag)
+	}
+	if len(result) > maxTags {
+		return nil, fmt.Errorf("%w: не больше %d тегов", ErrInvalidTags, maxTags)
+	}
+	return result, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
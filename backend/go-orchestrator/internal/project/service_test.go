@@ -0,0 +1,282 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...map[string]interface{})            {}
+func (nopLogger) Info(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Warn(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Error(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) Fatal(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) WithContext(ctx context.Context) *middleware.Logger            { return nil }
+func (nopLogger) Middleware() gin.HandlerFunc                                   { return nil }
+
+// mockQueries serves a single project and the organization roles of users.
+type mockQueries struct {
+	project sqlc.Project
+	roles   map[uuid.UUID]string
+
+	createFunc func(ctx context.Context, params sqlc.CreateProjectParams) (sqlc.Project, error)
+	listFunc   func(ctx context.Context, params sqlc.ListAccessibleProjectsParams) ([]sqlc.ListAccessibleProjectsRow, error)
+	updated    *sqlc.UpdateProjectParams
+	deleted    bool
+}
+
+func (m *mockQueries) CreateProject(ctx context.Context, params sqlc.CreateProjectParams) (sqlc.Project, error) {
+	if m.createFunc != nil {
+		return m.createFunc(ctx, params)
+	}
+	return sqlc.Project{
+		ID:             uuid.New(),
+		CreatedBy:      params.CreatedBy,
+		OrganizationID: params.OrganizationID,
+		Name:           params.Name,
+		Description:    params.Description,
+		SitePolygon:    params.SitePolygon,
+		Tags:           params.Tags,
+	}, nil
+}
+
+func (m *mockQueries) GetAccessibleProject(ctx context.Context, params sqlc.GetAccessibleProjectParams) (sqlc.Project, error) {
+	if params.ID != m.project.ID {
+		return sqlc.Project{}, pgx.ErrNoRows
+	}
+	if m.project.OrganizationID != nil {
+		if _, ok := m.roles[params.UserID]; ok {
+			return m.project, nil
+		}
+	} else if m.project.CreatedBy != nil && *m.project.CreatedBy == params.UserID {
+		return m.project, nil
+	}
+	return sqlc.Project{}, pgx.ErrNoRows
+}
+
+func (m *mockQueries) ListAccessibleProjects(ctx context.Context, params sqlc.ListAccessibleProjectsParams) ([]sqlc.ListAccessibleProjectsRow, error) {
+	if m.listFunc != nil {
+		return m.listFunc(ctx, params)
+	}
+	return nil, nil
+}
+
+func (m *mockQueries) GetProjectStats(ctx context.Context, projectID *uuid.UUID) (sqlc.GetProjectStatsRow, error) {
+	return sqlc.GetProjectStatsRow{JobCount: 3}, nil
+}
+
+func (m *mockQueries) UpdateProject(ctx context.Context, params sqlc.UpdateProjectParams) (sqlc.Project, error) {
+	m.updated = &params
+	project := m.project
+	project.Name = params.Name
+	project.Description = params.Description
+	project.SitePolygon = params.SitePolygon
+	project.Tags = params.Tags
+	return project, nil
+}
+
+func (m *mockQueries) DeleteProject(ctx context.Context, id uuid.UUID) error {
+	m.deleted = true
+	return nil
+}
+
+func (m *mockQueries) GetOrganizationMemberRole(ctx context.Context, params sqlc.GetOrganizationMemberRoleParams) (string, error) {
+	if role, ok := m.roles[params.UserID]; ok {
+		return role, nil
+	}
+	return "", pgx.ErrNoRows
+}
+
+func newTestService(queries *mockQueries) *Service {
+	return &Service{
+		queries: queries,
+		logger:  nopLogger{},
+	}
+}
+
+const testPolygon = `{"type":"Polygon","coordinates":[[[37.6,55.7],[37.61,55.7],[37.61,55.71],[37.6,55.71],[37.6,55.7]]]}`
+
+func TestCreateProject(t *testing.T) {
+	userID := uuid.New()
+	orgID := uuid.New()
+	queries := &mockQueries{roles: map[uuid.UUID]string{}}
+	service := newTestService(queries)
+
+	project, err := service.CreateProject(context.Background(), userID, &CreateProjectRequest{
+		Name:        "  Карьер  ",
+		SitePolygon: []byte(testPolygon),
+		Tags:        []string{" Quarry", "quarry", "", "North"},
+	})
+	if err != nil {
+		t.Fatalf("CreateProject() error = %v", err)
+	}
+	if project.Name != "Карьер" {
+		t.Errorf("Name = %q, want trimmed", project.Name)
+	}
+	if len(project.Tags) != 2 || project.Tags[0] != "quarry" || project.Tags[1] != "north" {
+		t.Errorf("Tags = %v, want [quarry north]", project.Tags)
+	}
+	if project.Stats.AreaM2 == nil || *project.Stats.AreaM2 <= 0 {
+		t.Errorf("AreaM2 = %v, want the polygon area", project.Stats.AreaM2)
+	}
+
+	_, err = service.CreateProject(context.Background(), userID, &CreateProjectRequest{
+		Name:           "Чужая организация",
+		OrganizationID: &orgID,
+	})
+	if !errors.Is(err, ErrOrganizationAccessDenied) {
+		t.Errorf("CreateProject() in a foreign organization error = %v, want ErrOrganizationAccessDenied", err)
+	}
+
+	_, err = service.CreateProject(context.Background(), userID, &CreateProjectRequest{
+		Name:        "Линия",
+		SitePolygon: []byte(`{"type":"LineString","coordinates":[[0,0],[1,1]]}`),
+	})
+	if !errors.Is(err, ErrInvalidSitePolygon) {
+		t.Errorf("CreateProject() with a line error = %v, want ErrInvalidSitePolygon", err)
+	}
+}
+
+func TestCreateProjectTagsNeverNil(t *testing.T) {
+	queries := &mockQueries{}
+	queries.createFunc = func(ctx context.Context, params sqlc.CreateProjectParams) (sqlc.Project, error) {
+		if params.Tags == nil {
+			t.Error("CreateProject() passed nil tags to a NOT NULL column")
+		}
+		return sqlc.Project{ID: uuid.New(), Name: params.Name, Tags: params.Tags}, nil
+	}
+
+	if _, err := newTestService(queries).CreateProject(context.Background(), uuid.New(), &CreateProjectRequest{Name: "Без тегов"}); err != nil {
+		t.Fatalf("CreateProject() error = %v", err)
+	}
+}
+
+func TestListProjectsNormalizesTag(t *testing.T) {
+	queries := &mockQueries{}
+	var got *string
+	queries.listFunc = func(ctx context.Context, params sqlc.ListAccessibleProjectsParams) ([]sqlc.ListAccessibleProjectsRow, error) {
+		got = params.Tag
+		return nil, nil
+	}
+	service := newTestService(queries)
+
+	if _, err := service.ListProjects(context.Background(), uuid.New(), " Quarry ", 20, 0); err != nil {
+		t.Fatalf("ListProjects() error = %v", err)
+	}
+	if got == nil || *got != "quarry" {
+		t.Errorf("Tag = %v, want quarry", got)
+	}
+
+	if _, err := service.ListProjects(context.Background(), uuid.New(), "", 20, 0); err != nil {
+		t.Fatalf("ListProjects() error = %v", err)
+	}
+	if got != nil {
+		t.Errorf("Tag = %q, want nil without a filter", *got)
+	}
+}
+
+func TestUpdateProjectPermissions(t *testing.T) {
+	creator := uuid.New()
+	admin := uuid.New()
+	member := uuid.New()
+	orgID := uuid.New()
+	queries := &mockQueries{
+		project: sqlc.Project{
+			ID:             uuid.New(),
+			CreatedBy:      &creator,
+			OrganizationID: &orgID,
+			Name:           "Карьер",
+			SitePolygon:    []byte(testPolygon),
+			Tags:           []string{"quarry"},
+		},
+		roles: map[uuid.UUID]string{
+			creator: "member",
+			admin:   "admin",
+			member:  "member",
+		},
+	}
+	service := newTestService(queries)
+	name := "Карьер 2"
+
+	_, err := service.UpdateProject(context.Background(), queries.project.ID, member, &UpdateProjectRequest{Name: &name})
+	if !errors.Is(err, ErrInsufficientRights) {
+		t.Errorf("UpdateProject() by a member error = %v, want ErrInsufficientRights", err)
+	}
+
+	_, err = service.UpdateProject(context.Background(), queries.project.ID, uuid.New(), &UpdateProjectRequest{Name: &name})
+	if !errors.Is(err, ErrProjectNotFound) {
+		t.Errorf("UpdateProject() by an outsider error = %v, want ErrProjectNotFound", err)
+	}
+
+	for _, userID := range []uuid.UUID{creator, admin} {
+		project, err := service.UpdateProject(context.Background(), queries.project.ID, userID, &UpdateProjectRequest{Name: &name})
+		if err != nil {
+			t.Fatalf("UpdateProject() error = %v", err)
+		}
+		if project.Name != name {
+			t.Errorf("Name = %q, want %q", project.Name, name)
+		}
+		if len(queries.updated.Tags) != 1 || queries.updated.SitePolygon == nil {
+			t.Errorf("UpdateProject() changed omitted fields: %+v", queries.updated)
+		}
+		if project.Stats.JobCount != 3 {
+			t.Errorf("JobCount = %d, want 3", project.Stats.JobCount)
+		}
+	}
+
+	if err := service.DeleteProject(context.Background(), queries.project.ID, member); !errors.Is(err, ErrInsufficientRights) {
+		t.Errorf("DeleteProject() by a member error = %v, want ErrInsufficientRights", err)
+	}
+	if err := service.DeleteProject(context.Background(), queries.project.ID, admin); err != nil || !queries.deleted {
+		t.Errorf("DeleteProject() by an admin error = %v, deleted = %v", err, queries.deleted)
+	}
+}
+
+func TestUpdateProjectClearsSitePolygon(t *testing.T) {
+	userID := uuid.New()
+	queries := &mockQueries{
+		project: sqlc.Project{
+			ID:          uuid.New(),
+			CreatedBy:   &userID,
+			Name:        "Карьер",
+			SitePolygon: []byte(testPolygon),
+			Tags:        []string{},
+		},
+	}
+
+	project, err := newTestService(queries).UpdateProject(context.Background(), queries.project.ID, userID, &UpdateProjectRequest{
+		SitePolygon: []byte("null"),
+	})
+	if err != nil {
+		t.Fatalf("UpdateProject() error = %v", err)
+	}
+	if queries.updated.SitePolygon != nil || project.Stats.AreaM2 != nil {
+		t.Errorf("site polygon = %s, area = %v, want both cleared", queries.updated.SitePolygon, project.Stats.AreaM2)
+	}
+}
+
+func TestNormalizeTagsLimits(t *testing.T) {
+	tooMany := make([]string, 0, maxTags+1)
+	for i := 0; i <= maxTags; i++ {
+		tooMany = append(tooMany, uuid.NewString()[:8])
+	}
+	if _, err := normalizeTags(tooMany); !errors.Is(err, ErrInvalidTags) {
+		t.Errorf("normalizeTags() with %d tags error = %v, want ErrInvalidTags", len(tooMany), err)
+	}
+
+	long := make([]rune, maxTagLength+1)
+	for i := range long {
+		long[i] = 'я'
+	}
+	if _, err := normalizeTags([]string{string(long)}); !errors.Is(err, ErrInvalidTags) {
+		t.Errorf("normalizeTags() with a long tag error = %v, want ErrInvalidTags", err)
+	}
+}
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS job_events_notify ON job_events;
+DROP FUNCTION IF EXISTS notify_job_event();
+
+DROP INDEX IF EXISTS idx_job_events_created_at;
+DROP INDEX IF EXISTS idx_job_events_user_id_seq;
+DROP TABLE IF EXISTS job_events;
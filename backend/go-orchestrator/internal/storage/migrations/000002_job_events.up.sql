@@ -0,0 +1,30 @@
+CREATE TABLE job_events (
+    seq BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_id UUID NOT NULL,
+    job_type VARCHAR(50) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_job_events_user_id_seq ON job_events(user_id, seq);
+CREATE INDEX idx_job_events_created_at ON job_events(created_at);
+
+CREATE OR REPLACE FUNCTION notify_job_event() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('job_events', json_build_object(
+        'seq', NEW.seq,
+        'user_id', NEW.user_id,
+        'job_id', NEW.job_id,
+        'job_type', NEW.job_type,
+        'event_type', NEW.event_type,
+        'payload', NEW.payload
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER job_events_notify
+    AFTER INSERT ON job_events
+    FOR EACH ROW EXECUTE FUNCTION notify_job_event();
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_batch_heightmap_jobs_status_updated_at;
+DROP INDEX IF EXISTS idx_heightmap_jobs_status_updated_at;
+
+ALTER TABLE batch_heightmap_jobs DROP COLUMN IF EXISTS requeue_count;
+ALTER TABLE batch_heightmap_jobs DROP COLUMN IF EXISTS fast_mode;
+
+ALTER TABLE heightmap_jobs DROP COLUMN IF EXISTS requeue_count;
@@ -0,0 +1,7 @@
+ALTER TABLE heightmap_jobs ADD COLUMN requeue_count INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE batch_heightmap_jobs ADD COLUMN fast_mode BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE batch_heightmap_jobs ADD COLUMN requeue_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_heightmap_jobs_status_updated_at ON heightmap_jobs(status, updated_at);
+CREATE INDEX idx_batch_heightmap_jobs_status_updated_at ON batch_heightmap_jobs(status, updated_at);
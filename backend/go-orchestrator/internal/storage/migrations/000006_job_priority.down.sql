@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_batch_heightmap_jobs_waiting;
+DROP INDEX IF EXISTS idx_heightmap_jobs_waiting;
+
+ALTER TABLE batch_heightmap_jobs DROP COLUMN IF EXISTS dispatched_at;
+ALTER TABLE batch_heightmap_jobs DROP COLUMN IF EXISTS priority;
+
+ALTER TABLE heightmap_jobs DROP COLUMN IF EXISTS dispatched_at;
+ALTER TABLE heightmap_jobs DROP COLUMN IF EXISTS priority;
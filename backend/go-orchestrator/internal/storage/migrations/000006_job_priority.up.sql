@@ -0,0 +1,14 @@
+ALTER TABLE heightmap_jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 4;
+ALTER TABLE heightmap_jobs ADD COLUMN dispatched_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE batch_heightmap_jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 4;
+ALTER TABLE batch_heightmap_jobs ADD COLUMN dispatched_at TIMESTAMP WITH TIME ZONE;
+
+-- Jobs created before the dispatcher existed were published on upload.
+UPDATE heightmap_jobs SET dispatched_at = updated_at;
+UPDATE batch_heightmap_jobs SET dispatched_at = updated_at;
+
+CREATE INDEX idx_heightmap_jobs_waiting ON heightmap_jobs(priority DESC, created_at)
+    WHERE status = 'pending' AND dispatched_at IS NULL;
+CREATE INDEX idx_batch_heightmap_jobs_waiting ON batch_heightmap_jobs(priority DESC, created_at)
+    WHERE status = 'pending' AND dispatched_at IS NULL;
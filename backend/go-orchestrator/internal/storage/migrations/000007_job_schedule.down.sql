@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_batch_heightmap_jobs_scheduled;
+DROP INDEX IF EXISTS idx_heightmap_jobs_scheduled;
+
+ALTER TABLE batch_heightmap_jobs DROP COLUMN IF EXISTS scheduled_at;
+ALTER TABLE heightmap_jobs DROP COLUMN IF EXISTS scheduled_at;
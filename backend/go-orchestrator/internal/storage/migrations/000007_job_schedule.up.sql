@@ -0,0 +1,5 @@
+ALTER TABLE heightmap_jobs ADD COLUMN scheduled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE batch_heightmap_jobs ADD COLUMN scheduled_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_heightmap_jobs_scheduled ON heightmap_jobs(scheduled_at) WHERE status = 'scheduled';
+CREATE INDEX idx_batch_heightmap_jobs_scheduled ON batch_heightmap_jobs(scheduled_at) WHERE status = 'scheduled';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS user_token_revocations;
+
+DROP INDEX IF EXISTS idx_revoked_access_tokens_expires_at;
+DROP TABLE IF EXISTS revoked_access_tokens;
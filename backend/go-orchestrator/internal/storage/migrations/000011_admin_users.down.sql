@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_job_events_job_id;
+DROP INDEX IF EXISTS idx_batch_heightmap_jobs_created_at;
+DROP INDEX IF EXISTS idx_users_created_at;
+
+DELETE FROM user_token_revocations WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM revoked_access_tokens WHERE user_id NOT IN (SELECT id FROM users);
+ALTER TABLE user_token_revocations
+    ADD CONSTRAINT user_token_revocations_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE revoked_access_tokens
+    ADD CONSTRAINT revoked_access_tokens_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_at;
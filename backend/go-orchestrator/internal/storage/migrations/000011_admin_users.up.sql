@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMP WITH TIME ZONE;
+
+-- Revocations must outlive the users they cut off: the tokens of a deleted
+-- user stay valid until they expire unless the denylist still covers them.
+ALTER TABLE revoked_access_tokens DROP CONSTRAINT IF EXISTS revoked_access_tokens_user_id_fkey;
+ALTER TABLE user_token_revocations DROP CONSTRAINT IF EXISTS user_token_revocations_user_id_fkey;
+
+CREATE INDEX idx_users_created_at ON users(created_at DESC);
+CREATE INDEX idx_batch_heightmap_jobs_created_at ON batch_heightmap_jobs(created_at DESC);
+CREATE INDEX idx_job_events_job_id ON job_events(job_id, seq);
@@ -0,0 +1,11 @@
+CREATE TABLE jwt_signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    private_key BYTEA NOT NULL,
+    activates_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    retires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_jwt_signing_keys_expires_at ON jwt_signing_keys(expires_at);
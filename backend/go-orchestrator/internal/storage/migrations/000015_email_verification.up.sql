@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Accounts created before verification existed keep uploading.
+UPDATE users SET email_verified_at = created_at;
+
+CREATE TABLE user_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(20) NOT NULL CHECK (purpose IN ('email_verification', 'password_reset')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_tokens_user_id ON user_tokens(user_id, purpose);
+CREATE INDEX idx_user_tokens_expires_at ON user_tokens(expires_at);
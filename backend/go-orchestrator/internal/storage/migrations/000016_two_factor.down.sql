@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS two_factor_required_roles;
+DROP INDEX IF EXISTS idx_two_factor_challenges_expires_at;
+DROP TABLE IF EXISTS two_factor_challenges;
+DROP TABLE IF EXISTS user_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
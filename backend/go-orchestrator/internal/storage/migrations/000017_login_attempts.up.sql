@@ -0,0 +1,10 @@
+CREATE TABLE login_attempts (
+    scope VARCHAR(10) NOT NULL CHECK (scope IN ('email', 'ip')),
+    subject VARCHAR(255) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    blocked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (scope, subject)
+);
+
+CREATE INDEX idx_login_attempts_last_attempt_at ON login_attempts(last_attempt_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_storage_deletions_run_after;
+DROP TABLE IF EXISTS storage_deletions;
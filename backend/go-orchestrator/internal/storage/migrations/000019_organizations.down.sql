@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_batch_heightmap_jobs_organization_id;
+DROP INDEX IF EXISTS idx_heightmap_jobs_organization_id;
+ALTER TABLE batch_heightmap_jobs DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE heightmap_jobs DROP COLUMN IF EXISTS organization_id;
+DROP TABLE IF EXISTS organization_invitations;
+DROP INDEX IF EXISTS idx_organization_members_user_id;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
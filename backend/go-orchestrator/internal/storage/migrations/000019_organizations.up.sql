@@ -0,0 +1,36 @@
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+CREATE TABLE organization_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'member')),
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (organization_id, email)
+);
+
+-- Jobs of a deleted organization go back to being personal jobs of whoever
+-- uploaded them.
+ALTER TABLE heightmap_jobs ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+ALTER TABLE batch_heightmap_jobs ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_heightmap_jobs_organization_id ON heightmap_jobs(organization_id, created_at DESC) WHERE organization_id IS NOT NULL;
+CREATE INDEX idx_batch_heightmap_jobs_organization_id ON batch_heightmap_jobs(organization_id, created_at DESC) WHERE organization_id IS NOT NULL;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_batch_heightmap_jobs_project_id;
+DROP INDEX IF EXISTS idx_heightmap_jobs_project_id;
+ALTER TABLE batch_heightmap_jobs DROP COLUMN IF EXISTS project_id;
+ALTER TABLE heightmap_jobs DROP COLUMN IF EXISTS project_id;
+DROP INDEX IF EXISTS idx_projects_tags;
+DROP INDEX IF EXISTS idx_projects_organization_id;
+DROP INDEX IF EXISTS idx_projects_created_by;
+DROP TABLE IF EXISTS projects;
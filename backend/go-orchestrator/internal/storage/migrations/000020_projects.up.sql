@@ -0,0 +1,23 @@
+CREATE TABLE projects (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    -- NULL once the creator deleted their account; personal projects are
+    -- deleted together with the account instead.
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    site_polygon JSONB,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_projects_created_by ON projects(created_by) WHERE organization_id IS NULL;
+CREATE INDEX idx_projects_organization_id ON projects(organization_id) WHERE organization_id IS NOT NULL;
+CREATE INDEX idx_projects_tags ON projects USING GIN (tags);
+
+ALTER TABLE heightmap_jobs ADD COLUMN project_id UUID REFERENCES projects(id) ON DELETE SET NULL;
+ALTER TABLE batch_heightmap_jobs ADD COLUMN project_id UUID REFERENCES projects(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_heightmap_jobs_project_id ON heightmap_jobs(project_id, created_at DESC) WHERE project_id IS NOT NULL;
+CREATE INDEX idx_batch_heightmap_jobs_project_id ON batch_heightmap_jobs(project_id, created_at DESC) WHERE project_id IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_job_share_accesses_share_id;
+DROP TABLE IF EXISTS job_share_accesses;
+DROP INDEX IF EXISTS idx_job_shares_batch_job_id;
+DROP INDEX IF EXISTS idx_job_shares_heightmap_job_id;
+DROP TABLE IF EXISTS job_shares;
@@ -0,0 +1,27 @@
+CREATE TABLE job_shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    heightmap_job_id UUID REFERENCES heightmap_jobs(id) ON DELETE CASCADE,
+    batch_job_id UUID REFERENCES batch_heightmap_jobs(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    password_hash VARCHAR(255),
+    allow_download BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (num_nonnulls(heightmap_job_id, batch_job_id) = 1)
+);
+
+CREATE INDEX idx_job_shares_heightmap_job_id ON job_shares(heightmap_job_id) WHERE heightmap_job_id IS NOT NULL;
+CREATE INDEX idx_job_shares_batch_job_id ON job_shares(batch_job_id) WHERE batch_job_id IS NOT NULL;
+
+CREATE TABLE job_share_accesses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    share_id UUID NOT NULL REFERENCES job_shares(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('view', 'download', 'denied')),
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_job_share_accesses_share_id ON job_share_accesses(share_id, created_at DESC);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_batch_heightmap_jobs_user_id_created_at;
+DROP INDEX IF EXISTS idx_heightmap_jobs_user_id_created_at;
+DROP INDEX IF EXISTS idx_storage_usage_batch_job_id;
+DROP INDEX IF EXISTS idx_storage_usage_heightmap_job_id;
+DROP INDEX IF EXISTS idx_storage_usage_user_id;
+DROP TABLE IF EXISTS storage_usage;
+DROP TABLE IF EXISTS user_quota_plans;
+DROP TABLE IF EXISTS quota_plans;
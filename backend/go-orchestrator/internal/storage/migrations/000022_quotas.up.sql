@@ -0,0 +1,35 @@
+CREATE TABLE quota_plans (
+    name VARCHAR(50) PRIMARY KEY,
+    max_storage_bytes BIGINT NOT NULL CHECK (max_storage_bytes >= 0),
+    max_jobs_per_day INTEGER NOT NULL CHECK (max_jobs_per_day >= 0),
+    max_concurrent_batches INTEGER NOT NULL CHECK (max_concurrent_batches >= 0),
+    max_images_per_batch INTEGER NOT NULL CHECK (max_images_per_batch >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO quota_plans (name, max_storage_bytes, max_jobs_per_day, max_concurrent_batches, max_images_per_batch) VALUES
+    ('default', 10737418240, 100, 2, 50),
+    ('extended', 107374182400, 1000, 10, 200),
+    ('unlimited', 0, 0, 0, 0);
+
+CREATE TABLE user_quota_plans (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    plan VARCHAR(50) NOT NULL REFERENCES quota_plans(name),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE storage_usage (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    heightmap_job_id UUID REFERENCES heightmap_jobs(id) ON DELETE CASCADE,
+    batch_job_id UUID REFERENCES batch_heightmap_jobs(id) ON DELETE CASCADE,
+    bytes BIGINT NOT NULL CHECK (bytes >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (num_nonnulls(heightmap_job_id, batch_job_id) = 1)
+);
+
+CREATE INDEX idx_storage_usage_user_id ON storage_usage(user_id);
+CREATE INDEX idx_storage_usage_heightmap_job_id ON storage_usage(heightmap_job_id) WHERE heightmap_job_id IS NOT NULL;
+CREATE INDEX idx_storage_usage_batch_job_id ON storage_usage(batch_job_id) WHERE batch_job_id IS NOT NULL;
+CREATE INDEX idx_heightmap_jobs_user_id_created_at ON heightmap_jobs(user_id, created_at);
+CREATE INDEX idx_batch_heightmap_jobs_user_id_created_at ON batch_heightmap_jobs(user_id, created_at);
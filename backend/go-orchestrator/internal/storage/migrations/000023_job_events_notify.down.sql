@@ -0,0 +1,13 @@
+CREATE OR REPLACE FUNCTION notify_job_event() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('job_events', json_build_object(
+        'seq', NEW.seq,
+        'user_id', NEW.user_id,
+        'job_id', NEW.job_id,
+        'job_type', NEW.job_type,
+        'event_type', NEW.event_type,
+        'payload', NEW.payload
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
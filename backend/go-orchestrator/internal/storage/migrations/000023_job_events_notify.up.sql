@@ -0,0 +1,11 @@
+-- NOTIFY payloads are limited to 8000 bytes, and a job snapshot can exceed
+-- that. Only the key of the event is sent; listeners load the row by seq.
+CREATE OR REPLACE FUNCTION notify_job_event() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('job_events', json_build_object(
+        'seq', NEW.seq,
+        'job_id', NEW.job_id
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
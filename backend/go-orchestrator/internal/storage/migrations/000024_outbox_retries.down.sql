@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_outbox_messages_pending;
+CREATE INDEX idx_outbox_messages_pending ON outbox_messages(created_at) WHERE status = 'pending';
+
+UPDATE outbox_messages SET status = 'pending' WHERE status = 'failed';
+
+ALTER TABLE outbox_messages DROP COLUMN IF EXISTS next_attempt_at;
@@ -0,0 +1,5 @@
+ALTER TABLE outbox_messages
+    ADD COLUMN next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+DROP INDEX IF EXISTS idx_outbox_messages_pending;
+CREATE INDEX idx_outbox_messages_pending ON outbox_messages(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1 @@
+ALTER TABLE quota_plans DROP COLUMN IF EXISTS max_priority;
@@ -0,0 +1,6 @@
+ALTER TABLE quota_plans
+    ADD COLUMN max_priority VARCHAR(10) NOT NULL DEFAULT 'normal'
+        CHECK (max_priority IN ('low', 'normal', 'high', 'urgent'));
+
+UPDATE quota_plans SET max_priority = 'high' WHERE name = 'extended';
+UPDATE quota_plans SET max_priority = 'urgent' WHERE name = 'unlimited';
@@ -0,0 +1,6 @@
+-- Without input_prefixes every row removes all uploads of its user, so rows
+-- of deleted jobs are dropped rather than widened to the whole account.
+DELETE FROM storage_deletions
+WHERE input_prefixes <> ARRAY['heightmaps/' || user_id || '/', 'batch-heightmaps/' || user_id || '/'];
+
+ALTER TABLE storage_deletions DROP COLUMN IF EXISTS input_prefixes;
@@ -0,0 +1,5 @@
+ALTER TABLE storage_deletions
+    ADD COLUMN input_prefixes TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE storage_deletions
+SET input_prefixes = ARRAY['heightmaps/' || user_id || '/', 'batch-heightmaps/' || user_id || '/'];
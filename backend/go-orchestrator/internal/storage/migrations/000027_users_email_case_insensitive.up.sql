@@ -0,0 +1,5 @@
+-- Fails if two accounts differ only in the case of their email; merge or
+-- rename them before upgrading.
+CREATE UNIQUE INDEX idx_users_email_lower ON users(LOWER(email));
+
+UPDATE users SET email = LOWER(email) WHERE email <> LOWER(email);
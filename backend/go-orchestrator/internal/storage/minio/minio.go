@@ -119,6 +119,16 @@ func (m *Minio) GetPresignedURL(ctx context.Context, bucket, objectName string,
 	return url.String(), nil
 }
 
+// PresignObject returns a URL that allows a single HTTP method on one object
+// until expiry, without sharing the client credentials.
+func (m *Minio) PresignObject(ctx context.Context, method, bucket, objectName string, expiry time.Duration) (string, error) {
+	url, err := m.client.Presign(ctx, method, bucket, objectName, expiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign %s %s/%s: %w", method, bucket, objectName, err)
+	}
+	return url.String(), nil
+}
+
 func (m *Minio) FileExists(ctx context.Context, bucket, objectName string) (bool, error) {
 	_, err := m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{})
 	if err != nil {
@@ -145,3 +155,49 @@ func (m *Minio) GetFileInfo(ctx context.Context, bucket, objectName string) (*Fi
 		ETag:         stat.ETag,
 	}, nil
 }
+
+// RemoveObject deletes one object. Deleting an object that does not exist
+// succeeds.
+func (m *Minio) RemoveObject(ctx context.Context, bucket, objectName string) error {
+	if err := m.client.RemoveObject(ctx, bucket, objectName, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove %s/%s: %w", bucket, objectName, err)
+	}
+	return nil
+}
+
+// RemovePrefix deletes every object whose name starts with prefix, in bulk
+// requests of up to a thousand objects.
+func (m *Minio) RemovePrefix(ctx context.Context, bucket, prefix string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objects := make(chan minio.ObjectInfo)
+	var listErr error
+	go func() {
+		defer close(objects)
+		for object := range m.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if object.Err != nil {
+				listErr = object.Err
+				return
+			}
+			select {
+			case objects <- object:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// The error channel is closed only after the objects channel, so listErr
+	// is safe to read once it is drained.
+	var removeErr error
+	for result := range m.client.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{}) {
+		if removeErr == nil {
+			removeErr = fmt.Errorf("failed to remove %s/%s: %w", bucket, result.ObjectName, result.Err)
+		}
+	}
+	if listErr != nil {
+		return fmt.Errorf("failed to list %s/%s: %w", bucket, prefix, listErr)
+	}
+	return removeErr
+}
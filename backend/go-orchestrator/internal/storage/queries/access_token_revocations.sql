@@ -0,0 +1,31 @@
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens (
+    id, user_id, expires_at
+) VALUES (
+    $1, $2, $3
+) ON CONFLICT (id) DO NOTHING;
+
+-- name: RevokeUserAccessTokens :exec
+INSERT INTO user_token_revocations (
+    user_id, revoked_before
+) VALUES (
+    $1, $2
+) ON CONFLICT (user_id) DO UPDATE
+SET revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before),
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: ListRevokedAccessTokens :many
+SELECT * FROM revoked_access_tokens
+WHERE expires_at > $1;
+
+-- name: ListUserTokenRevocations :many
+SELECT * FROM user_token_revocations
+WHERE revoked_before > $1;
+
+-- name: DeleteExpiredAccessTokenRevocations :exec
+DELETE FROM revoked_access_tokens
+WHERE expires_at < $1;
+
+-- name: DeleteUserTokenRevocationsBefore :exec
+DELETE FROM user_token_revocations
+WHERE revoked_before < $1;
@@ -0,0 +1,30 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (
+    user_id, name, prefix, key_hash, scopes, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: ListUserAPIKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: CountUserAPIKeys :one
+SELECT COUNT(*) FROM api_keys
+WHERE user_id = $1;
+
+-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetAPIKeyByHash :one
+SELECT k.id, k.user_id, k.scopes, k.expires_at, k.last_used_at, u.email, u.name, u.role, u.suspended_at, u.email_verified_at
+FROM api_keys k
+JOIN users u ON u.id = k.user_id
+WHERE k.key_hash = $1;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = $2
+WHERE id = $1;
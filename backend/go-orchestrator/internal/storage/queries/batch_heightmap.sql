@@ -1,8 +1,8 @@
 -- name: CreateBatchHeightmapJob :one
 INSERT INTO batch_heightmap_jobs (
-    id, user_id, status, image_count, merge_method, generation_mode, created_at, updated_at
+    id, user_id, status, image_count, merge_method, generation_mode, fast_mode, priority, scheduled_at, created_at, updated_at, organization_id, project_id
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 ) RETURNING *;
 
 -- name: CreateBatchImage :one
@@ -16,9 +16,14 @@ INSERT INTO batch_images (
 SELECT * FROM batch_heightmap_jobs
 WHERE id = $1 LIMIT 1;
 
--- name: GetBatchHeightmapJobByUserID :one
+-- name: LockBatchHeightmapJobStatus :one
+SELECT status FROM batch_heightmap_jobs WHERE id = $1 FOR UPDATE;
+
+-- name: GetAccessibleBatchHeightmapJob :one
 SELECT * FROM batch_heightmap_jobs
-WHERE id = $1 AND user_id = $2 LIMIT 1;
+WHERE id = $1 AND (user_id = $2 OR organization_id IN (
+    SELECT organization_id FROM organization_members WHERE organization_members.user_id = $2
+)) LIMIT 1;
 
 -- name: ListUserBatchHeightmaps :many
 SELECT * FROM batch_heightmap_jobs
@@ -26,6 +31,35 @@ WHERE user_id = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3;
 
+-- name: ListOrganizationBatchHeightmaps :many
+SELECT * FROM batch_heightmap_jobs
+WHERE organization_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListProjectBatchHeightmaps :many
+SELECT * FROM batch_heightmap_jobs
+WHERE project_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListUserBatchHeightmapsByStatus :many
+SELECT * FROM batch_heightmap_jobs
+WHERE user_id = $1 AND status = $2
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4;
+
+-- name: ListBatchHeightmapsByStatus :many
+SELECT * FROM batch_heightmap_jobs
+WHERE status = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListBatchHeightmaps :many
+SELECT * FROM batch_heightmap_jobs
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+
 -- name: GetBatchImages :many
 SELECT * FROM batch_images
 WHERE batch_job_id = $1
@@ -52,8 +86,109 @@ UPDATE batch_heightmap_jobs
 SET processed_count = $2, updated_at = $3
 WHERE id = $1;
 
+-- name: CountStaleBatchHeightmapJobs :one
+SELECT COUNT(*) FROM batch_heightmap_jobs
+WHERE status = sqlc.arg(status) AND dispatched_at IS NOT NULL AND updated_at < sqlc.arg(stale_before);
+
+-- name: ListStaleBatchHeightmapJobs :many
+SELECT * FROM batch_heightmap_jobs
+WHERE status = sqlc.arg(status) AND dispatched_at IS NOT NULL AND updated_at < sqlc.arg(stale_before)
+ORDER BY updated_at ASC
+LIMIT sqlc.arg(batch_size)
+FOR UPDATE SKIP LOCKED;
+
+-- name: RequeueBatchHeightmapJob :exec
+UPDATE batch_heightmap_jobs
+SET status = 'pending', processed_count = 0, requeue_count = requeue_count + 1, updated_at = $2, dispatched_at = $2
+WHERE id = $1;
+
 -- name: UpdateBatchImageStatus :exec
 UPDATE batch_images
 SET status = $2, heightmap_job_id = $3
 WHERE id = $1;
 
+
+-- name: ListWaitingBatchHeightmapJobs :many
+WITH in_flight AS (
+    SELECT user_id, COUNT(*) AS jobs FROM batch_heightmap_jobs
+    WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+    GROUP BY user_id
+), waiting AS (
+    SELECT j.id, j.priority, j.created_at,
+        ROW_NUMBER() OVER (PARTITION BY j.user_id ORDER BY j.priority DESC, j.created_at) + COALESCE(f.jobs, 0) AS share_rank
+    FROM batch_heightmap_jobs j
+    LEFT JOIN in_flight f ON f.user_id = j.user_id
+    WHERE j.status = 'pending' AND j.dispatched_at IS NULL
+)
+SELECT j.id, j.user_id, j.status, j.result_url, j.orthophoto_url, j.width, j.height, j.image_count, j.processed_count, j.error_message, j.processing_time, j.merge_method, j.generation_mode, j.created_at, j.updated_at, j.fast_mode, j.requeue_count, j.priority, j.dispatched_at, j.scheduled_at, j.organization_id, j.project_id
+FROM batch_heightmap_jobs j
+JOIN waiting w ON w.id = j.id
+ORDER BY w.priority DESC, w.share_rank, w.created_at
+LIMIT $1;
+
+-- name: GetBatchHeightmapJobQueuePosition :one
+WITH in_flight AS (
+    SELECT user_id, COUNT(*) AS jobs FROM batch_heightmap_jobs
+    WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+    GROUP BY user_id
+), waiting AS (
+    SELECT j.id, j.priority, j.created_at,
+        ROW_NUMBER() OVER (PARTITION BY j.user_id ORDER BY j.priority DESC, j.created_at) + COALESCE(f.jobs, 0) AS share_rank
+    FROM batch_heightmap_jobs j
+    LEFT JOIN in_flight f ON f.user_id = j.user_id
+    WHERE j.status = 'pending' AND j.dispatched_at IS NULL
+), ordered AS (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY priority DESC, share_rank, created_at) AS position
+    FROM waiting
+)
+SELECT position FROM ordered WHERE id = $1;
+
+-- name: CountInFlightBatchHeightmapJobs :one
+SELECT COUNT(*) FROM batch_heightmap_jobs
+WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing');
+
+-- name: MarkBatchHeightmapJobDispatched :execrows
+UPDATE batch_heightmap_jobs
+SET updated_at = $2, dispatched_at = $2
+WHERE id = $1 AND status = 'pending' AND dispatched_at IS NULL;
+
+-- name: ReleaseDueBatchHeightmapJobs :many
+UPDATE batch_heightmap_jobs
+SET status = 'pending', updated_at = $1
+WHERE id IN (
+    SELECT id FROM batch_heightmap_jobs
+    WHERE status = 'scheduled' AND scheduled_at <= $1
+    ORDER BY scheduled_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RescheduleBatchHeightmapJob :execrows
+UPDATE batch_heightmap_jobs
+SET scheduled_at = $3, updated_at = $4
+WHERE id = $1 AND status = 'scheduled'
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ));
+
+-- name: CancelBatchHeightmapJob :execrows
+UPDATE batch_heightmap_jobs
+SET status = 'cancelled', updated_at = $3
+WHERE id = $1
+    AND (status = 'scheduled' OR (status = 'pending' AND dispatched_at IS NULL))
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ));
+
+-- name: DeleteFinishedBatchHeightmapJob :one
+DELETE FROM batch_heightmap_jobs
+WHERE id = $1
+    AND status IN ('completed', 'failed', 'cancelled')
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ))
+RETURNING *;
@@ -1,14 +1,20 @@
 -- name: CreateHeightmapJob :one
 INSERT INTO heightmap_jobs (
-    id, user_id, image_url, status, created_at, updated_at
-) VALUES ($1, $2, $3, $4, $5, $6)
+    id, user_id, image_url, status, priority, scheduled_at, created_at, updated_at, organization_id, project_id
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 RETURNING *;
 
 -- name: GetHeightmapJob :one
 SELECT * FROM heightmap_jobs WHERE id = $1;
 
--- name: GetHeightmapJobByUserID :one
-SELECT * FROM heightmap_jobs WHERE id = $1 AND user_id = $2;
+-- name: LockHeightmapJobStatus :one
+SELECT status FROM heightmap_jobs WHERE id = $1 FOR UPDATE;
+
+-- name: GetAccessibleHeightmapJob :one
+SELECT * FROM heightmap_jobs
+WHERE id = $1 AND (user_id = $2 OR organization_id IN (
+    SELECT organization_id FROM organization_members WHERE organization_members.user_id = $2
+));
 
 -- name: UpdateJobStatus :exec
 UPDATE heightmap_jobs
@@ -40,6 +46,18 @@ WHERE user_id = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3;
 
+-- name: ListOrganizationHeightmaps :many
+SELECT * FROM heightmap_jobs
+WHERE organization_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListProjectHeightmaps :many
+SELECT * FROM heightmap_jobs
+WHERE project_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
 -- name: CountUserHeightmaps :one
 SELECT COUNT(*) FROM heightmap_jobs
 WHERE user_id = $1;
@@ -50,6 +68,118 @@ WHERE status = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3;
 
+-- name: ListHeightmaps :many
+SELECT * FROM heightmap_jobs
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+
+-- name: ListUserHeightmapsByStatus :many
+SELECT * FROM heightmap_jobs
+WHERE user_id = $1 AND status = $2
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4;
+
+-- name: CountStaleHeightmapJobs :one
+SELECT COUNT(*) FROM heightmap_jobs
+WHERE status = sqlc.arg(status) AND dispatched_at IS NOT NULL AND updated_at < sqlc.arg(stale_before);
+
+-- name: ListStaleHeightmapJobs :many
+SELECT * FROM heightmap_jobs
+WHERE status = sqlc.arg(status) AND dispatched_at IS NOT NULL AND updated_at < sqlc.arg(stale_before)
+ORDER BY updated_at ASC
+LIMIT sqlc.arg(batch_size)
+FOR UPDATE SKIP LOCKED;
+
+-- name: RequeueHeightmapJob :exec
+UPDATE heightmap_jobs
+SET status = 'pending', requeue_count = requeue_count + 1, updated_at = $2, dispatched_at = $2
+WHERE id = $1;
+
 -- name: DeleteHeightmapJob :exec
 DELETE FROM heightmap_jobs WHERE id = $1;
 
+
+-- name: ListWaitingHeightmapJobs :many
+WITH in_flight AS (
+    SELECT user_id, COUNT(*) AS jobs FROM heightmap_jobs
+    WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+    GROUP BY user_id
+), waiting AS (
+    SELECT j.id, j.priority, j.created_at,
+        ROW_NUMBER() OVER (PARTITION BY j.user_id ORDER BY j.priority DESC, j.created_at) + COALESCE(f.jobs, 0) AS share_rank
+    FROM heightmap_jobs j
+    LEFT JOIN in_flight f ON f.user_id = j.user_id
+    WHERE j.status = 'pending' AND j.dispatched_at IS NULL
+)
+SELECT j.id, j.user_id, j.image_url, j.result_url, j.status, j.width, j.height, j.error_message, j.processing_time, j.created_at, j.updated_at, j.requeue_count, j.priority, j.dispatched_at, j.scheduled_at, j.organization_id, j.project_id
+FROM heightmap_jobs j
+JOIN waiting w ON w.id = j.id
+ORDER BY w.priority DESC, w.share_rank, w.created_at
+LIMIT $1;
+
+-- name: GetHeightmapJobQueuePosition :one
+WITH in_flight AS (
+    SELECT user_id, COUNT(*) AS jobs FROM heightmap_jobs
+    WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+    GROUP BY user_id
+), waiting AS (
+    SELECT j.id, j.priority, j.created_at,
+        ROW_NUMBER() OVER (PARTITION BY j.user_id ORDER BY j.priority DESC, j.created_at) + COALESCE(f.jobs, 0) AS share_rank
+    FROM heightmap_jobs j
+    LEFT JOIN in_flight f ON f.user_id = j.user_id
+    WHERE j.status = 'pending' AND j.dispatched_at IS NULL
+), ordered AS (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY priority DESC, share_rank, created_at) AS position
+    FROM waiting
+)
+SELECT position FROM ordered WHERE id = $1;
+
+-- name: CountInFlightHeightmapJobs :one
+SELECT COUNT(*) FROM heightmap_jobs
+WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing');
+
+-- name: MarkHeightmapJobDispatched :execrows
+UPDATE heightmap_jobs
+SET updated_at = $2, dispatched_at = $2
+WHERE id = $1 AND status = 'pending' AND dispatched_at IS NULL;
+
+-- name: ReleaseDueHeightmapJobs :many
+UPDATE heightmap_jobs
+SET status = 'pending', updated_at = $1
+WHERE id IN (
+    SELECT id FROM heightmap_jobs
+    WHERE status = 'scheduled' AND scheduled_at <= $1
+    ORDER BY scheduled_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RescheduleHeightmapJob :execrows
+UPDATE heightmap_jobs
+SET scheduled_at = $3, updated_at = $4
+WHERE id = $1 AND status = 'scheduled'
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ));
+
+-- name: CancelHeightmapJob :execrows
+UPDATE heightmap_jobs
+SET status = 'cancelled', updated_at = $3
+WHERE id = $1
+    AND (status = 'scheduled' OR (status = 'pending' AND dispatched_at IS NULL))
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ));
+
+-- name: DeleteFinishedHeightmapJob :one
+DELETE FROM heightmap_jobs
+WHERE id = $1
+    AND status IN ('completed', 'failed', 'cancelled')
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ))
+RETURNING *;
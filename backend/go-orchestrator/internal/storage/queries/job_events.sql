@@ -0,0 +1,24 @@
+-- name: CreateJobEvent :one
+INSERT INTO job_events (
+    user_id, job_id, job_type, event_type, payload
+) VALUES (
+    $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetJobEvent :one
+SELECT * FROM job_events WHERE seq = $1;
+
+-- name: ListUserJobEventsAfter :many
+SELECT * FROM job_events
+WHERE user_id = $1 AND seq > $2
+ORDER BY seq ASC
+LIMIT $3;
+
+-- name: ListJobEvents :many
+SELECT * FROM job_events
+WHERE job_id = $1
+ORDER BY seq ASC;
+
+-- name: DeleteJobEventsBefore :exec
+DELETE FROM job_events
+WHERE created_at < $1;
@@ -0,0 +1,45 @@
+-- name: CreateJobShare :one
+INSERT INTO job_shares (heightmap_job_id, batch_job_id, created_by, token_hash, password_hash, allow_download, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetJobShareByTokenHash :one
+SELECT * FROM job_shares WHERE token_hash = $1;
+
+-- name: ListHeightmapJobShares :many
+SELECT * FROM job_shares
+WHERE heightmap_job_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListBatchJobShares :many
+SELECT * FROM job_shares
+WHERE batch_job_id = $1
+ORDER BY created_at DESC;
+
+-- name: RevokeJobShare :execrows
+UPDATE job_shares
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id)
+    AND (heightmap_job_id = sqlc.arg(job_id) OR batch_job_id = sqlc.arg(job_id))
+    AND revoked_at IS NULL;
+
+-- name: JobShareExists :one
+SELECT EXISTS (
+    SELECT 1 FROM job_shares
+    WHERE id = sqlc.arg(id)
+        AND (heightmap_job_id = sqlc.arg(job_id) OR batch_job_id = sqlc.arg(job_id))
+);
+
+-- name: CreateJobShareAccess :exec
+INSERT INTO job_share_accesses (share_id, action, ip_address, user_agent)
+VALUES ($1, $2, $3, $4);
+
+-- name: CountJobShareDenialsSince :one
+SELECT COUNT(*) FROM job_share_accesses
+WHERE share_id = $1 AND action = 'denied' AND created_at > $2;
+
+-- name: ListJobShareAccesses :many
+SELECT * FROM job_share_accesses
+WHERE share_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
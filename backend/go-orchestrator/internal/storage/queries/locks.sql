@@ -0,0 +1,5 @@
+-- name: TryAdvisoryXactLock :one
+SELECT pg_try_advisory_xact_lock($1)::boolean AS acquired;
+
+-- name: AdvisoryXactLock :exec
+SELECT pg_advisory_xact_lock($1);
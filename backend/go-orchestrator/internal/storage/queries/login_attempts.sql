@@ -0,0 +1,36 @@
+-- name: CountLoginAttempt :one
+INSERT INTO login_attempts (
+    scope, subject, attempts, last_attempt_at, blocked_until
+) VALUES (
+    sqlc.arg(scope), sqlc.arg(subject), 1, sqlc.arg(attempted_at), sqlc.arg(attempted_at)
+)
+ON CONFLICT (scope, subject) DO UPDATE
+SET attempts = CASE
+        WHEN login_attempts.last_attempt_at < sqlc.arg(forget_before) THEN 1
+        ELSE login_attempts.attempts + 1
+    END,
+    last_attempt_at = EXCLUDED.last_attempt_at
+WHERE login_attempts.blocked_until <= EXCLUDED.last_attempt_at
+RETURNING attempts;
+
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts
+WHERE scope = $1 AND subject = $2;
+
+-- name: BlockLogin :exec
+UPDATE login_attempts
+SET blocked_until = GREATEST(blocked_until, sqlc.arg(blocked_until))
+WHERE scope = sqlc.arg(scope) AND subject = sqlc.arg(subject);
+
+-- name: ForgiveLoginAttempt :exec
+UPDATE login_attempts
+SET attempts = GREATEST(attempts - 1, 0)
+WHERE scope = $1 AND subject = $2;
+
+-- name: DeleteLoginAttempts :execrows
+DELETE FROM login_attempts
+WHERE scope = $1 AND subject = $2;
+
+-- name: DeleteExpiredLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE blocked_until < $1 AND last_attempt_at < $2;
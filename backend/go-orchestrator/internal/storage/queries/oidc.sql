@@ -0,0 +1,27 @@
+-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states (
+    state, provider, nonce, code_verifier, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5
+);
+
+-- name: ConsumeOIDCLoginState :one
+DELETE FROM oidc_login_states
+WHERE state = $1
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLoginStates :exec
+DELETE FROM oidc_login_states
+WHERE expires_at < $1;
+
+-- name: GetUserByIdentity :one
+SELECT users.* FROM users
+JOIN user_identities ON user_identities.user_id = users.id
+WHERE user_identities.provider = $1 AND user_identities.subject = $2;
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (
+    user_id, provider, subject, email
+) VALUES (
+    $1, $2, $3, $4
+);
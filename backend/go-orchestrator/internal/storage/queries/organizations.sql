@@ -0,0 +1,110 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (name) VALUES ($1)
+RETURNING *;
+
+-- name: GetOrganization :one
+SELECT * FROM organizations WHERE id = $1;
+
+-- name: LockOrganization :one
+SELECT id FROM organizations WHERE id = $1 FOR UPDATE;
+
+-- name: UpdateOrganizationName :one
+UPDATE organizations
+SET name = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteOrganization :exec
+DELETE FROM organizations WHERE id = $1;
+
+-- name: ListUserOrganizations :many
+SELECT o.id, o.name, o.created_at, o.updated_at, m.role
+FROM organizations o
+JOIN organization_members m ON m.organization_id = o.id
+WHERE m.user_id = $1
+ORDER BY o.name, o.created_at;
+
+-- name: AddOrganizationMember :exec
+INSERT INTO organization_members (organization_id, user_id, role)
+VALUES ($1, $2, $3);
+
+-- name: GetOrganizationMemberRole :one
+SELECT role FROM organization_members
+WHERE organization_id = $1 AND user_id = $2;
+
+-- name: ListOrganizationMembers :many
+SELECT m.user_id, u.email, u.name, m.role, m.created_at
+FROM organization_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.organization_id = $1
+ORDER BY m.created_at;
+
+-- name: OrganizationMemberEmailExists :one
+SELECT EXISTS (
+    SELECT 1 FROM organization_members m
+    JOIN users u ON u.id = m.user_id
+    WHERE m.organization_id = sqlc.arg(organization_id) AND LOWER(u.email) = LOWER(sqlc.arg(email))
+) AS member;
+
+-- name: UpdateOrganizationMemberRole :execrows
+UPDATE organization_members
+SET role = $3
+WHERE organization_id = $1 AND user_id = $2;
+
+-- name: DeleteOrganizationMember :execrows
+DELETE FROM organization_members
+WHERE organization_id = $1 AND user_id = $2;
+
+-- name: CountOrganizationOwners :one
+SELECT COUNT(*) FROM organization_members
+WHERE organization_id = $1 AND role = 'owner';
+
+-- name: CountOrganizationsLastOwnedByUser :one
+SELECT COUNT(*) FROM organization_members m
+WHERE m.user_id = $1 AND m.role = 'owner'
+    AND NOT EXISTS (
+        SELECT 1 FROM organization_members o
+        WHERE o.organization_id = m.organization_id AND o.user_id <> m.user_id AND o.role = 'owner'
+    )
+    AND EXISTS (
+        SELECT 1 FROM organization_members o
+        WHERE o.organization_id = m.organization_id AND o.user_id <> m.user_id
+    );
+
+-- name: DeleteUserSoleOrganizations :exec
+DELETE FROM organizations
+WHERE id IN (
+    SELECT organization_id FROM organization_members WHERE user_id = $1
+) AND NOT EXISTS (
+    SELECT 1 FROM organization_members m
+    WHERE m.organization_id = organizations.id AND m.user_id <> $1
+);
+
+-- name: UpsertOrganizationInvitation :one
+INSERT INTO organization_invitations (
+    organization_id, email, role, token_hash, invited_by, expires_at
+) VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (organization_id, email) DO UPDATE
+SET role = EXCLUDED.role,
+    token_hash = EXCLUDED.token_hash,
+    invited_by = EXCLUDED.invited_by,
+    expires_at = EXCLUDED.expires_at,
+    created_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: ListOrganizationInvitations :many
+SELECT * FROM organization_invitations
+WHERE organization_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteOrganizationInvitation :execrows
+DELETE FROM organization_invitations
+WHERE id = $1 AND organization_id = $2;
+
+-- name: ConsumeOrganizationInvitation :one
+DELETE FROM organization_invitations
+WHERE token_hash = $1
+RETURNING *;
+
+-- name: DeleteExpiredOrganizationInvitations :exec
+DELETE FROM organization_invitations WHERE expires_at <= $1;
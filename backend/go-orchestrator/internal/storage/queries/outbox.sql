@@ -0,0 +1,27 @@
+-- name: CreateOutboxMessage :one
+INSERT INTO outbox_messages (
+    aggregate_type, aggregate_id, message_type, payload
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ClaimPendingOutboxMessages :many
+SELECT * FROM outbox_messages
+WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+ORDER BY created_at ASC
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxMessageSent :exec
+UPDATE outbox_messages
+SET status = 'sent', attempts = attempts + 1, last_error = NULL, sent_at = $2, updated_at = $2
+WHERE id = $1;
+
+-- name: RescheduleOutboxMessage :exec
+UPDATE outbox_messages
+SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_error = $4, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: DeleteSentOutboxMessagesBefore :exec
+DELETE FROM outbox_messages
+WHERE status = 'sent' AND sent_at < $1;
@@ -0,0 +1,54 @@
+-- name: CreateProject :one
+INSERT INTO projects (created_by, organization_id, name, description, site_polygon, tags)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetAccessibleProject :one
+SELECT * FROM projects
+WHERE id = sqlc.arg(id) AND (
+    organization_id IN (
+        SELECT organization_id FROM organization_members WHERE organization_members.user_id = sqlc.arg(user_id)
+    )
+    OR (organization_id IS NULL AND created_by = sqlc.arg(user_id))
+);
+
+-- name: ListAccessibleProjects :many
+SELECT p.id, p.created_by, p.organization_id, p.name, p.description, p.site_polygon, p.tags, p.created_at, p.updated_at,
+    (SELECT COUNT(*) FROM heightmap_jobs WHERE heightmap_jobs.project_id = p.id)
+        + (SELECT COUNT(*) FROM batch_heightmap_jobs WHERE batch_heightmap_jobs.project_id = p.id) AS job_count,
+    GREATEST(
+        (SELECT MAX(created_at) FROM heightmap_jobs WHERE heightmap_jobs.project_id = p.id),
+        (SELECT MAX(created_at)::timestamptz FROM batch_heightmap_jobs WHERE batch_heightmap_jobs.project_id = p.id)
+    )::timestamptz AS last_job_at
+FROM projects p
+WHERE (
+    p.organization_id IN (
+        SELECT organization_id FROM organization_members WHERE organization_members.user_id = sqlc.arg(user_id)
+    )
+    OR (p.organization_id IS NULL AND p.created_by = sqlc.arg(user_id))
+)
+    AND (sqlc.narg(tag)::text IS NULL OR sqlc.narg(tag) = ANY(p.tags))
+ORDER BY p.created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: GetProjectStats :one
+SELECT
+    (SELECT COUNT(*) FROM heightmap_jobs WHERE heightmap_jobs.project_id = $1)
+        + (SELECT COUNT(*) FROM batch_heightmap_jobs WHERE batch_heightmap_jobs.project_id = $1) AS job_count,
+    GREATEST(
+        (SELECT MAX(created_at) FROM heightmap_jobs WHERE heightmap_jobs.project_id = $1),
+        (SELECT MAX(created_at)::timestamptz FROM batch_heightmap_jobs WHERE batch_heightmap_jobs.project_id = $1)
+    )::timestamptz AS last_job_at;
+
+-- name: UpdateProject :one
+UPDATE projects
+SET name = $2, description = $3, site_polygon = $4, tags = $5, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteProject :exec
+DELETE FROM projects WHERE id = $1;
+
+-- name: DeleteUserPersonalProjects :exec
+DELETE FROM projects
+WHERE created_by = $1 AND organization_id IS NULL;
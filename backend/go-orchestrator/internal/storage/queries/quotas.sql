@@ -0,0 +1,36 @@
+-- name: CreateStorageUsage :exec
+INSERT INTO storage_usage (user_id, heightmap_job_id, batch_job_id, bytes)
+VALUES ($1, $2, $3, $4);
+
+-- name: DeleteBatchJobStorageUsage :exec
+DELETE FROM storage_usage WHERE batch_job_id = $1;
+
+-- name: DeleteHeightmapJobStorageUsage :exec
+DELETE FROM storage_usage WHERE heightmap_job_id = $1;
+
+-- name: GetQuotaPlan :one
+SELECT * FROM quota_plans WHERE name = $1;
+
+-- name: GetUserQuotaPlan :one
+SELECT * FROM quota_plans
+WHERE name = COALESCE((SELECT plan FROM user_quota_plans WHERE user_quota_plans.user_id = $1), 'default');
+
+-- name: GetUserUsage :one
+SELECT
+    (SELECT COALESCE(SUM(bytes), 0) FROM storage_usage WHERE storage_usage.user_id = sqlc.arg(user_id))::bigint AS storage_bytes,
+    ((SELECT COUNT(*) FROM heightmap_jobs WHERE heightmap_jobs.user_id = sqlc.arg(user_id) AND heightmap_jobs.created_at >= sqlc.arg(since))
+        + (SELECT COUNT(*) FROM batch_heightmap_jobs WHERE batch_heightmap_jobs.user_id = sqlc.arg(user_id) AND batch_heightmap_jobs.created_at >= sqlc.arg(since)))::bigint AS jobs_since,
+    (SELECT COUNT(*) FROM batch_heightmap_jobs
+        WHERE batch_heightmap_jobs.user_id = sqlc.arg(user_id) AND status IN ('scheduled', 'pending', 'processing'))::bigint AS active_batches;
+
+-- name: ListQuotaPlans :many
+SELECT * FROM quota_plans ORDER BY max_storage_bytes, name;
+
+-- name: LockUserQuota :exec
+SELECT pg_advisory_xact_lock(hashtextextended('quota:' || sqlc.arg(user_id)::uuid::text, 0));
+
+-- name: SetUserQuotaPlan :execrows
+INSERT INTO user_quota_plans (user_id, plan, updated_at)
+SELECT sqlc.arg(user_id)::uuid, sqlc.arg(plan)::varchar, sqlc.arg(updated_at)::timestamptz
+WHERE EXISTS (SELECT 1 FROM users WHERE id = sqlc.arg(user_id))
+ON CONFLICT (user_id) DO UPDATE SET plan = EXCLUDED.plan, updated_at = EXCLUDED.updated_at;
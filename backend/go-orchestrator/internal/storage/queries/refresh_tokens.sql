@@ -0,0 +1,29 @@
+-- name: CreateRefreshToken :exec
+INSERT INTO refresh_tokens (
+    id, user_id, family_id, token_hash, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5
+);
+
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE id = $1;
+
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = $2, replaced_by = $3
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = $2
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :exec
+DELETE FROM refresh_tokens
+WHERE expires_at < $1;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = $2
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,15 @@
+-- name: CreateSigningKey :exec
+INSERT INTO jwt_signing_keys (
+    kid, algorithm, private_key, activates_at, retires_at, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+);
+
+-- name: ListSigningKeys :many
+SELECT * FROM jwt_signing_keys
+WHERE expires_at > $1
+ORDER BY activates_at;
+
+-- name: DeleteExpiredSigningKeys :exec
+DELETE FROM jwt_signing_keys
+WHERE expires_at < $1;
@@ -0,0 +1,27 @@
+-- name: ScheduleUserStorageDeletion :exec
+INSERT INTO storage_deletions (user_id, heightmap_job_ids, batch_job_ids, input_prefixes)
+VALUES (
+    $1,
+    ARRAY(SELECT id FROM heightmap_jobs WHERE user_id = $1),
+    ARRAY(SELECT id FROM batch_heightmap_jobs WHERE user_id = $1),
+    ARRAY['heightmaps/' || $1::uuid || '/', 'batch-heightmaps/' || $1::uuid || '/']
+);
+
+-- name: ScheduleJobStorageDeletion :exec
+INSERT INTO storage_deletions (user_id, heightmap_job_ids, batch_job_ids, input_prefixes)
+VALUES ($1, $2, $3, $4);
+
+-- name: ClaimDueStorageDeletions :many
+SELECT * FROM storage_deletions
+WHERE run_after <= $1
+ORDER BY run_after ASC
+LIMIT $2
+FOR UPDATE SKIP LOCKED;
+
+-- name: RescheduleStorageDeletion :exec
+UPDATE storage_deletions
+SET attempts = attempts + 1, last_error = $2, run_after = $3
+WHERE id = $1;
+
+-- name: DeleteStorageDeletion :exec
+DELETE FROM storage_deletions WHERE id = $1;
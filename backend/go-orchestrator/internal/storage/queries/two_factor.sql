@@ -0,0 +1,83 @@
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = $1;
+
+-- name: UpsertUserTOTP :exec
+INSERT INTO user_totp (
+    user_id, secret
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+WHERE user_totp.confirmed_at IS NULL;
+
+-- name: ConfirmUserTOTP :execrows
+UPDATE user_totp
+SET confirmed_at = $2
+WHERE user_id = $1 AND confirmed_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO user_recovery_codes (
+    user_id, code_hash
+) VALUES (
+    $1, $2
+);
+
+-- name: UseRecoveryCode :execrows
+DELETE FROM user_recovery_codes
+WHERE user_id = $1 AND code_hash = $2;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM user_recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateTwoFactorChallenge :exec
+INSERT INTO two_factor_challenges (
+    token_hash, user_id, enrollment, expires_at
+) VALUES (
+    $1, $2, $3, $4
+);
+
+-- name: GetTwoFactorChallenge :one
+SELECT * FROM two_factor_challenges
+WHERE token_hash = $1;
+
+-- name: RecordTwoFactorChallengeFailure :one
+UPDATE two_factor_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1
+RETURNING attempts;
+
+-- name: DeleteTwoFactorChallenge :execrows
+DELETE FROM two_factor_challenges
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredTwoFactorChallenges :exec
+DELETE FROM two_factor_challenges
+WHERE expires_at < $1;
+
+-- name: ListTwoFactorRequiredRoles :many
+SELECT role FROM two_factor_required_roles
+ORDER BY role;
+
+-- name: IsTwoFactorRequired :one
+SELECT EXISTS (
+    SELECT 1 FROM two_factor_required_roles WHERE role = $1
+) AS required;
+
+-- name: AddTwoFactorRequiredRole :exec
+INSERT INTO two_factor_required_roles (role) VALUES ($1)
+ON CONFLICT (role) DO NOTHING;
+
+-- name: DeleteTwoFactorRequiredRoles :exec
+DELETE FROM two_factor_required_roles;
@@ -0,0 +1,19 @@
+-- name: CreateUserToken :exec
+INSERT INTO user_tokens (
+    token_hash, user_id, purpose, expires_at
+) VALUES (
+    $1, $2, $3, $4
+);
+
+-- name: ConsumeUserToken :one
+DELETE FROM user_tokens
+WHERE token_hash = $1 AND purpose = $2
+RETURNING *;
+
+-- name: DeleteUserTokens :exec
+DELETE FROM user_tokens
+WHERE user_id = $1 AND purpose = $2;
+
+-- name: DeleteExpiredUserTokens :exec
+DELETE FROM user_tokens
+WHERE expires_at < $1;
@@ -1,13 +1,54 @@
 -- name: Create :one
-INSERT INTO users (email, password_hash, name) 
-VALUES ($1, $2, $3) 
+INSERT INTO users (email, password_hash, name, role, email_verified_at) 
+VALUES ($1, $2, $3, $4, $5) 
 RETURNING *;
 
 -- name: GetByID :one
 SELECT * FROM users WHERE id = $1 LIMIT 1;
 
 -- name: GetByEmail :one
-SELECT * FROM users WHERE email = $1 LIMIT 1;
+SELECT * FROM users WHERE LOWER(email) = LOWER($1) LIMIT 1;
 
 -- name: Delete :exec
 DELETE FROM users WHERE id = $1;
+
+-- name: UpdateUserRole :one
+UPDATE users SET role = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: CountUsersByRole :one
+SELECT COUNT(*) FROM users WHERE role = $1 AND suspended_at IS NULL;
+
+-- name: ListUsers :many
+SELECT * FROM users
+WHERE (sqlc.narg(role)::varchar IS NULL OR role = sqlc.narg(role))
+    AND (sqlc.narg(suspended)::boolean IS NULL OR (suspended_at IS NOT NULL) = sqlc.narg(suspended))
+    AND (sqlc.narg(email)::text IS NULL OR email ILIKE '%' || sqlc.narg(email) || '%')
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: UpdateUserSuspension :one
+UPDATE users SET suspended_at = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: IsEmailVerified :one
+SELECT email_verified_at IS NOT NULL AS verified FROM users WHERE id = $1;
+
+-- name: MarkEmailVerified :exec
+UPDATE users SET email_verified_at = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND email_verified_at IS NULL;
+
+-- name: UpdateUserPassword :exec
+UPDATE users SET password_hash = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET name = $2,
+    email = $3,
+    email_verified_at = CASE WHEN email = $3 THEN email_verified_at ELSE NULL END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
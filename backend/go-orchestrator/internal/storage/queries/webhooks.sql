@@ -0,0 +1,66 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (
+    user_id, url, secret, events
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetWebhookByUserID :one
+SELECT * FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = $1;
+
+-- name: ListUserWebhooks :many
+SELECT * FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListActiveWebhooksForEvent :many
+SELECT * FROM webhooks
+WHERE user_id = $1 AND is_active = TRUE AND sqlc.arg(event)::text = ANY(events);
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (
+    webhook_id, event_type, payload
+) VALUES (
+    $1, $2, $3
+) RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1 AND webhook_id = $2;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET status = 'sending', updated_at = CURRENT_TIMESTAMP
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE (status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP)
+       OR (status = 'sending' AND updated_at < sqlc.arg(stale_before))
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+) RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', attempts = $2, response_code = $3, last_error = NULL, delivered_at = $4, updated_at = $4
+WHERE id = $1;
+
+-- name: RescheduleWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = $3, next_attempt_at = $4, response_code = $5, last_error = $6, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
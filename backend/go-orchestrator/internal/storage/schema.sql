@@ -5,7 +5,10 @@ CREATE TABLE users (
     password_hash VARCHAR(255) NOT NULL,
     name VARCHAR(255) NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    role VARCHAR(20) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'operator', 'admin')),
+    suspended_at TIMESTAMP WITH TIME ZONE,
+    email_verified_at TIMESTAMP WITH TIME ZONE
 );
 
 CREATE TABLE heightmap_jobs (
@@ -19,7 +22,11 @@ CREATE TABLE heightmap_jobs (
     error_message TEXT,
     processing_time FLOAT,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    requeue_count INTEGER NOT NULL DEFAULT 0,
+    priority INTEGER NOT NULL DEFAULT 4,
+    dispatched_at TIMESTAMP WITH TIME ZONE,
+    scheduled_at TIMESTAMP WITH TIME ZONE
 );
 
 CREATE INDEX idx_heightmap_jobs_user_id ON heightmap_jobs(user_id);
@@ -41,7 +48,12 @@ CREATE TABLE batch_heightmap_jobs (
     merge_method VARCHAR(50) NOT NULL DEFAULT 'average',
     generation_mode VARCHAR(50) NOT NULL DEFAULT 'heightmap',
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    fast_mode BOOLEAN NOT NULL DEFAULT FALSE,
+    requeue_count INTEGER NOT NULL DEFAULT 0,
+    priority INTEGER NOT NULL DEFAULT 4,
+    dispatched_at TIMESTAMP WITH TIME ZONE,
+    scheduled_at TIMESTAMP WITH TIME ZONE
 );
 
 CREATE TABLE batch_images (
@@ -57,3 +69,347 @@ CREATE INDEX idx_batch_heightmap_jobs_user_id ON batch_heightmap_jobs(user_id);
 CREATE INDEX idx_batch_heightmap_jobs_status ON batch_heightmap_jobs(status);
 CREATE INDEX idx_batch_images_batch_job_id ON batch_images(batch_job_id);
 CREATE INDEX idx_batch_images_status ON batch_images(status);
+
+CREATE TABLE job_events (
+    seq BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_id UUID NOT NULL,
+    job_type VARCHAR(50) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_job_events_user_id_seq ON job_events(user_id, seq);
+CREATE INDEX idx_job_events_created_at ON job_events(created_at);
+
+CREATE TABLE webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    events TEXT[] NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhooks_user_id ON webhooks(user_id);
+
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    response_code INTEGER,
+    last_error TEXT,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at DESC);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status IN ('pending', 'sending');
+
+CREATE TABLE outbox_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id UUID NOT NULL,
+    message_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_messages_pending ON outbox_messages(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_outbox_messages_sent_at ON outbox_messages(sent_at) WHERE status = 'sent';
+
+CREATE INDEX idx_heightmap_jobs_status_updated_at ON heightmap_jobs(status, updated_at);
+CREATE INDEX idx_batch_heightmap_jobs_status_updated_at ON batch_heightmap_jobs(status, updated_at);
+CREATE INDEX idx_heightmap_jobs_waiting ON heightmap_jobs(priority DESC, created_at)
+    WHERE status = 'pending' AND dispatched_at IS NULL;
+CREATE INDEX idx_batch_heightmap_jobs_waiting ON batch_heightmap_jobs(priority DESC, created_at)
+    WHERE status = 'pending' AND dispatched_at IS NULL;
+CREATE INDEX idx_heightmap_jobs_scheduled ON heightmap_jobs(scheduled_at) WHERE status = 'scheduled';
+CREATE INDEX idx_batch_heightmap_jobs_scheduled ON batch_heightmap_jobs(scheduled_at) WHERE status = 'scheduled';
+
+CREATE TABLE refresh_tokens (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    replaced_by UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+
+CREATE TABLE revoked_access_tokens (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_revoked_access_tokens_expires_at ON revoked_access_tokens(expires_at);
+
+CREATE TABLE user_token_revocations (
+    user_id UUID PRIMARY KEY,
+    revoked_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_users_role ON users(role) WHERE role <> 'user';
+CREATE INDEX idx_users_created_at ON users(created_at DESC);
+CREATE UNIQUE INDEX idx_users_email_lower ON users(LOWER(email));
+CREATE INDEX idx_batch_heightmap_jobs_created_at ON batch_heightmap_jobs(created_at DESC);
+CREATE INDEX idx_job_events_job_id ON job_events(job_id, seq);
+
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id, created_at DESC);
+
+CREATE TABLE oidc_login_states (
+    state VARCHAR(64) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_oidc_login_states_expires_at ON oidc_login_states(expires_at);
+
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+CREATE TABLE jwt_signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    private_key BYTEA NOT NULL,
+    activates_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    retires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_jwt_signing_keys_expires_at ON jwt_signing_keys(expires_at);
+
+CREATE TABLE user_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(20) NOT NULL CHECK (purpose IN ('email_verification', 'password_reset')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_tokens_user_id ON user_tokens(user_id, purpose);
+CREATE INDEX idx_user_tokens_expires_at ON user_tokens(expires_at);
+
+CREATE TABLE user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret BYTEA NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_recovery_codes (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+CREATE TABLE two_factor_challenges (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    enrollment BOOLEAN NOT NULL DEFAULT FALSE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_two_factor_challenges_expires_at ON two_factor_challenges(expires_at);
+
+CREATE TABLE two_factor_required_roles (
+    role VARCHAR(20) PRIMARY KEY CHECK (role IN ('operator', 'admin')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE login_attempts (
+    scope VARCHAR(10) NOT NULL CHECK (scope IN ('email', 'ip')),
+    subject VARCHAR(255) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    blocked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (scope, subject)
+);
+
+CREATE INDEX idx_login_attempts_last_attempt_at ON login_attempts(last_attempt_at);
+
+CREATE TABLE storage_deletions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    heightmap_job_ids UUID[] NOT NULL DEFAULT '{}',
+    batch_job_ids UUID[] NOT NULL DEFAULT '{}',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    run_after TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    input_prefixes TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX idx_storage_deletions_run_after ON storage_deletions(run_after);
+
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+CREATE TABLE organization_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'member')),
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (organization_id, email)
+);
+
+-- Jobs of a deleted organization go back to being personal jobs of whoever
+-- uploaded them.
+ALTER TABLE heightmap_jobs ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+ALTER TABLE batch_heightmap_jobs ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_heightmap_jobs_organization_id ON heightmap_jobs(organization_id, created_at DESC) WHERE organization_id IS NOT NULL;
+CREATE INDEX idx_batch_heightmap_jobs_organization_id ON batch_heightmap_jobs(organization_id, created_at DESC) WHERE organization_id IS NOT NULL;
+
+CREATE TABLE projects (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    -- NULL once the creator deleted their account; personal projects are
+    -- deleted together with the account instead.
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    site_polygon JSONB,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_projects_created_by ON projects(created_by) WHERE organization_id IS NULL;
+CREATE INDEX idx_projects_organization_id ON projects(organization_id) WHERE organization_id IS NOT NULL;
+CREATE INDEX idx_projects_tags ON projects USING GIN (tags);
+
+ALTER TABLE heightmap_jobs ADD COLUMN project_id UUID REFERENCES projects(id) ON DELETE SET NULL;
+ALTER TABLE batch_heightmap_jobs ADD COLUMN project_id UUID REFERENCES projects(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_heightmap_jobs_project_id ON heightmap_jobs(project_id, created_at DESC) WHERE project_id IS NOT NULL;
+CREATE INDEX idx_batch_heightmap_jobs_project_id ON batch_heightmap_jobs(project_id, created_at DESC) WHERE project_id IS NOT NULL;
+
+CREATE TABLE job_shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    heightmap_job_id UUID REFERENCES heightmap_jobs(id) ON DELETE CASCADE,
+    batch_job_id UUID REFERENCES batch_heightmap_jobs(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    password_hash VARCHAR(255),
+    allow_download BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (num_nonnulls(heightmap_job_id, batch_job_id) = 1)
+);
+
+CREATE INDEX idx_job_shares_heightmap_job_id ON job_shares(heightmap_job_id) WHERE heightmap_job_id IS NOT NULL;
+CREATE INDEX idx_job_shares_batch_job_id ON job_shares(batch_job_id) WHERE batch_job_id IS NOT NULL;
+
+CREATE TABLE job_share_accesses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    share_id UUID NOT NULL REFERENCES job_shares(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('view', 'download', 'denied')),
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_job_share_accesses_share_id ON job_share_accesses(share_id, created_at DESC);
+
+CREATE TABLE quota_plans (
+    name VARCHAR(50) PRIMARY KEY,
+    max_storage_bytes BIGINT NOT NULL CHECK (max_storage_bytes >= 0),
+    max_jobs_per_day INTEGER NOT NULL CHECK (max_jobs_per_day >= 0),
+    max_concurrent_batches INTEGER NOT NULL CHECK (max_concurrent_batches >= 0),
+    max_images_per_batch INTEGER NOT NULL CHECK (max_images_per_batch >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    max_priority VARCHAR(10) NOT NULL DEFAULT 'normal' CHECK (max_priority IN ('low', 'normal', 'high', 'urgent'))
+);
+
+CREATE TABLE user_quota_plans (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    plan VARCHAR(50) NOT NULL REFERENCES quota_plans(name),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE storage_usage (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    heightmap_job_id UUID REFERENCES heightmap_jobs(id) ON DELETE CASCADE,
+    batch_job_id UUID REFERENCES batch_heightmap_jobs(id) ON DELETE CASCADE,
+    bytes BIGINT NOT NULL CHECK (bytes >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (num_nonnulls(heightmap_job_id, batch_job_id) = 1)
+);
+
+CREATE INDEX idx_storage_usage_user_id ON storage_usage(user_id);
+CREATE INDEX idx_storage_usage_heightmap_job_id ON storage_usage(heightmap_job_id) WHERE heightmap_job_id IS NOT NULL;
+CREATE INDEX idx_storage_usage_batch_job_id ON storage_usage(batch_job_id) WHERE batch_job_id IS NOT NULL;
+CREATE INDEX idx_heightmap_jobs_user_id_created_at ON heightmap_jobs(user_id, created_at);
+CREATE INDEX idx_batch_heightmap_jobs_user_id_created_at ON batch_heightmap_jobs(user_id, created_at);
@@ -13,23 +13,82 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const CancelBatchHeightmapJob = `-- name: CancelBatchHeightmapJob :execrows
+UPDATE batch_heightmap_jobs
+SET status = 'cancelled', updated_at = $3
+WHERE id = $1
+    AND (status = 'scheduled' OR (status = 'pending' AND dispatched_at IS NULL))
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ))
+`
+
+type CancelBatchHeightmapJobParams struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) CancelBatchHeightmapJob(ctx context.Context, arg CancelBatchHeightmapJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, CancelBatchHeightmapJob, arg.ID, arg.UserID, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const CountInFlightBatchHeightmapJobs = `-- name: CountInFlightBatchHeightmapJobs :one
+SELECT COUNT(*) FROM batch_heightmap_jobs
+WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+`
+
+func (q *Queries) CountInFlightBatchHeightmapJobs(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, CountInFlightBatchHeightmapJobs)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const CountStaleBatchHeightmapJobs = `-- name: CountStaleBatchHeightmapJobs :one
+SELECT COUNT(*) FROM batch_heightmap_jobs
+WHERE status = $1 AND dispatched_at IS NOT NULL AND updated_at < $2
+`
+
+type CountStaleBatchHeightmapJobsParams struct {
+	Status      string    `json:"status"`
+	StaleBefore time.Time `json:"stale_before"`
+}
+
+func (q *Queries) CountStaleBatchHeightmapJobs(ctx context.Context, arg CountStaleBatchHeightmapJobsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, CountStaleBatchHeightmapJobs, arg.Status, arg.StaleBefore)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const CreateBatchHeightmapJob = `-- name: CreateBatchHeightmapJob :one
 INSERT INTO batch_heightmap_jobs (
-    id, user_id, status, image_count, merge_method, generation_mode, created_at, updated_at
+    id, user_id, status, image_count, merge_method, generation_mode, fast_mode, priority, scheduled_at, created_at, updated_at, organization_id, project_id
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8
-) RETURNING id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+) RETURNING id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id
 `
 
 type CreateBatchHeightmapJobParams struct {
-	ID             uuid.UUID `json:"id"`
-	UserID         uuid.UUID `json:"user_id"`
-	Status         string    `json:"status"`
-	ImageCount     int32     `json:"image_count"`
-	MergeMethod    string    `json:"merge_method"`
-	GenerationMode string    `json:"generation_mode"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	Status         string     `json:"status"`
+	ImageCount     int32      `json:"image_count"`
+	MergeMethod    string     `json:"merge_method"`
+	GenerationMode string     `json:"generation_mode"`
+	FastMode       bool       `json:"fast_mode"`
+	Priority       int32      `json:"priority"`
+	ScheduledAt    *time.Time `json:"scheduled_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	ProjectID      *uuid.UUID `json:"project_id"`
 }
 
 func (q *Queries) CreateBatchHeightmapJob(ctx context.Context, arg CreateBatchHeightmapJobParams) (BatchHeightmapJob, error) {
@@ -40,8 +99,13 @@ func (q *Queries) CreateBatchHeightmapJob(ctx context.Context, arg CreateBatchHe
 		arg.ImageCount,
 		arg.MergeMethod,
 		arg.GenerationMode,
+		arg.FastMode,
+		arg.Priority,
+		arg.ScheduledAt,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.OrganizationID,
+		arg.ProjectID,
 	)
 	var i BatchHeightmapJob
 	err := row.Scan(
@@ -60,6 +124,13 @@ func (q *Queries) CreateBatchHeightmapJob(ctx context.Context, arg CreateBatchHe
 		&i.GenerationMode,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FastMode,
+		&i.RequeueCount,
+		&i.Priority,
+		&i.DispatchedAt,
+		&i.ScheduledAt,
+		&i.OrganizationID,
+		&i.ProjectID,
 	)
 	return i, err
 }
@@ -100,13 +171,24 @@ func (q *Queries) CreateBatchImage(ctx context.Context, arg CreateBatchImagePara
 	return i, err
 }
 
-const GetBatchHeightmapJob = `-- name: GetBatchHeightmapJob :one
-SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at FROM batch_heightmap_jobs
-WHERE id = $1 LIMIT 1
+const DeleteFinishedBatchHeightmapJob = `-- name: DeleteFinishedBatchHeightmapJob :one
+DELETE FROM batch_heightmap_jobs
+WHERE id = $1
+    AND status IN ('completed', 'failed', 'cancelled')
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ))
+RETURNING id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id
 `
 
-func (q *Queries) GetBatchHeightmapJob(ctx context.Context, id uuid.UUID) (BatchHeightmapJob, error) {
-	row := q.db.QueryRow(ctx, GetBatchHeightmapJob, id)
+type DeleteFinishedBatchHeightmapJobParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeleteFinishedBatchHeightmapJob(ctx context.Context, arg DeleteFinishedBatchHeightmapJobParams) (BatchHeightmapJob, error) {
+	row := q.db.QueryRow(ctx, DeleteFinishedBatchHeightmapJob, arg.ID, arg.UserID)
 	var i BatchHeightmapJob
 	err := row.Scan(
 		&i.ID,
@@ -124,22 +206,31 @@ func (q *Queries) GetBatchHeightmapJob(ctx context.Context, id uuid.UUID) (Batch
 		&i.GenerationMode,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FastMode,
+		&i.RequeueCount,
+		&i.Priority,
+		&i.DispatchedAt,
+		&i.ScheduledAt,
+		&i.OrganizationID,
+		&i.ProjectID,
 	)
 	return i, err
 }
 
-const GetBatchHeightmapJobByUserID = `-- name: GetBatchHeightmapJobByUserID :one
-SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at FROM batch_heightmap_jobs
-WHERE id = $1 AND user_id = $2 LIMIT 1
+const GetAccessibleBatchHeightmapJob = `-- name: GetAccessibleBatchHeightmapJob :one
+SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM batch_heightmap_jobs
+WHERE id = $1 AND (user_id = $2 OR organization_id IN (
+    SELECT organization_id FROM organization_members WHERE organization_members.user_id = $2
+)) LIMIT 1
 `
 
-type GetBatchHeightmapJobByUserIDParams struct {
+type GetAccessibleBatchHeightmapJobParams struct {
 	ID     uuid.UUID `json:"id"`
 	UserID uuid.UUID `json:"user_id"`
 }
 
-func (q *Queries) GetBatchHeightmapJobByUserID(ctx context.Context, arg GetBatchHeightmapJobByUserIDParams) (BatchHeightmapJob, error) {
-	row := q.db.QueryRow(ctx, GetBatchHeightmapJobByUserID, arg.ID, arg.UserID)
+func (q *Queries) GetAccessibleBatchHeightmapJob(ctx context.Context, arg GetAccessibleBatchHeightmapJobParams) (BatchHeightmapJob, error) {
+	row := q.db.QueryRow(ctx, GetAccessibleBatchHeightmapJob, arg.ID, arg.UserID)
 	var i BatchHeightmapJob
 	err := row.Scan(
 		&i.ID,
@@ -157,10 +248,77 @@ func (q *Queries) GetBatchHeightmapJobByUserID(ctx context.Context, arg GetBatch
 		&i.GenerationMode,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FastMode,
+		&i.RequeueCount,
+		&i.Priority,
+		&i.DispatchedAt,
+		&i.ScheduledAt,
+		&i.OrganizationID,
+		&i.ProjectID,
 	)
 	return i, err
 }
 
+const GetBatchHeightmapJob = `-- name: GetBatchHeightmapJob :one
+SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM batch_heightmap_jobs
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetBatchHeightmapJob(ctx context.Context, id uuid.UUID) (BatchHeightmapJob, error) {
+	row := q.db.QueryRow(ctx, GetBatchHeightmapJob, id)
+	var i BatchHeightmapJob
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.ResultUrl,
+		&i.OrthophotoUrl,
+		&i.Width,
+		&i.Height,
+		&i.ImageCount,
+		&i.ProcessedCount,
+		&i.ErrorMessage,
+		&i.ProcessingTime,
+		&i.MergeMethod,
+		&i.GenerationMode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FastMode,
+		&i.RequeueCount,
+		&i.Priority,
+		&i.DispatchedAt,
+		&i.ScheduledAt,
+		&i.OrganizationID,
+		&i.ProjectID,
+	)
+	return i, err
+}
+
+const GetBatchHeightmapJobQueuePosition = `-- name: GetBatchHeightmapJobQueuePosition :one
+WITH in_flight AS (
+    SELECT user_id, COUNT(*) AS jobs FROM batch_heightmap_jobs
+    WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+    GROUP BY user_id
+), waiting AS (
+    SELECT j.id, j.priority, j.created_at,
+        ROW_NUMBER() OVER (PARTITION BY j.user_id ORDER BY j.priority DESC, j.created_at) + COALESCE(f.jobs, 0) AS share_rank
+    FROM batch_heightmap_jobs j
+    LEFT JOIN in_flight f ON f.user_id = j.user_id
+    WHERE j.status = 'pending' AND j.dispatched_at IS NULL
+), ordered AS (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY priority DESC, share_rank, created_at) AS position
+    FROM waiting
+)
+SELECT position FROM ordered WHERE id = $1
+`
+
+func (q *Queries) GetBatchHeightmapJobQueuePosition(ctx context.Context, id uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, GetBatchHeightmapJobQueuePosition, id)
+	var position int64
+	err := row.Scan(&position)
+	return position, err
+}
+
 const GetBatchImages = `-- name: GetBatchImages :many
 SELECT id, batch_job_id, image_url, heightmap_job_id, status, created_at FROM batch_images
 WHERE batch_job_id = $1
@@ -194,8 +352,287 @@ func (q *Queries) GetBatchImages(ctx context.Context, batchJobID pgtype.UUID) ([
 	return items, nil
 }
 
+const ListBatchHeightmaps = `-- name: ListBatchHeightmaps :many
+SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM batch_heightmap_jobs
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2
+`
+
+type ListBatchHeightmapsParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListBatchHeightmaps(ctx context.Context, arg ListBatchHeightmapsParams) ([]BatchHeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListBatchHeightmaps, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BatchHeightmapJob
+	for rows.Next() {
+		var i BatchHeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.ResultUrl,
+			&i.OrthophotoUrl,
+			&i.Width,
+			&i.Height,
+			&i.ImageCount,
+			&i.ProcessedCount,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.MergeMethod,
+			&i.GenerationMode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FastMode,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListBatchHeightmapsByStatus = `-- name: ListBatchHeightmapsByStatus :many
+SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM batch_heightmap_jobs
+WHERE status = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListBatchHeightmapsByStatusParams struct {
+	Status string `json:"status"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+func (q *Queries) ListBatchHeightmapsByStatus(ctx context.Context, arg ListBatchHeightmapsByStatusParams) ([]BatchHeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListBatchHeightmapsByStatus, arg.Status, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BatchHeightmapJob
+	for rows.Next() {
+		var i BatchHeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.ResultUrl,
+			&i.OrthophotoUrl,
+			&i.Width,
+			&i.Height,
+			&i.ImageCount,
+			&i.ProcessedCount,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.MergeMethod,
+			&i.GenerationMode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FastMode,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListOrganizationBatchHeightmaps = `-- name: ListOrganizationBatchHeightmaps :many
+SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM batch_heightmap_jobs
+WHERE organization_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListOrganizationBatchHeightmapsParams struct {
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	Limit          int32      `json:"limit"`
+	Offset         int32      `json:"offset"`
+}
+
+func (q *Queries) ListOrganizationBatchHeightmaps(ctx context.Context, arg ListOrganizationBatchHeightmapsParams) ([]BatchHeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListOrganizationBatchHeightmaps, arg.OrganizationID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BatchHeightmapJob
+	for rows.Next() {
+		var i BatchHeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.ResultUrl,
+			&i.OrthophotoUrl,
+			&i.Width,
+			&i.Height,
+			&i.ImageCount,
+			&i.ProcessedCount,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.MergeMethod,
+			&i.GenerationMode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FastMode,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListProjectBatchHeightmaps = `-- name: ListProjectBatchHeightmaps :many
+SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM batch_heightmap_jobs
+WHERE project_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListProjectBatchHeightmapsParams struct {
+	ProjectID *uuid.UUID `json:"project_id"`
+	Limit     int32      `json:"limit"`
+	Offset    int32      `json:"offset"`
+}
+
+func (q *Queries) ListProjectBatchHeightmaps(ctx context.Context, arg ListProjectBatchHeightmapsParams) ([]BatchHeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListProjectBatchHeightmaps, arg.ProjectID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BatchHeightmapJob
+	for rows.Next() {
+		var i BatchHeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.ResultUrl,
+			&i.OrthophotoUrl,
+			&i.Width,
+			&i.Height,
+			&i.ImageCount,
+			&i.ProcessedCount,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.MergeMethod,
+			&i.GenerationMode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FastMode,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListStaleBatchHeightmapJobs = `-- name: ListStaleBatchHeightmapJobs :many
+SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM batch_heightmap_jobs
+WHERE status = $1 AND dispatched_at IS NOT NULL AND updated_at < $2
+ORDER BY updated_at ASC
+LIMIT $3
+FOR UPDATE SKIP LOCKED
+`
+
+type ListStaleBatchHeightmapJobsParams struct {
+	Status      string    `json:"status"`
+	StaleBefore time.Time `json:"stale_before"`
+	BatchSize   int32     `json:"batch_size"`
+}
+
+func (q *Queries) ListStaleBatchHeightmapJobs(ctx context.Context, arg ListStaleBatchHeightmapJobsParams) ([]BatchHeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListStaleBatchHeightmapJobs, arg.Status, arg.StaleBefore, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BatchHeightmapJob
+	for rows.Next() {
+		var i BatchHeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.ResultUrl,
+			&i.OrthophotoUrl,
+			&i.Width,
+			&i.Height,
+			&i.ImageCount,
+			&i.ProcessedCount,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.MergeMethod,
+			&i.GenerationMode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FastMode,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const ListUserBatchHeightmaps = `-- name: ListUserBatchHeightmaps :many
-SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at FROM batch_heightmap_jobs
+SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM batch_heightmap_jobs
 WHERE user_id = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3
@@ -232,6 +669,228 @@ func (q *Queries) ListUserBatchHeightmaps(ctx context.Context, arg ListUserBatch
 			&i.GenerationMode,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.FastMode,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListUserBatchHeightmapsByStatus = `-- name: ListUserBatchHeightmapsByStatus :many
+SELECT id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM batch_heightmap_jobs
+WHERE user_id = $1 AND status = $2
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4
+`
+
+type ListUserBatchHeightmapsByStatusParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Status string    `json:"status"`
+	Limit  int32     `json:"limit"`
+	Offset int32     `json:"offset"`
+}
+
+func (q *Queries) ListUserBatchHeightmapsByStatus(ctx context.Context, arg ListUserBatchHeightmapsByStatusParams) ([]BatchHeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListUserBatchHeightmapsByStatus,
+		arg.UserID,
+		arg.Status,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BatchHeightmapJob
+	for rows.Next() {
+		var i BatchHeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.ResultUrl,
+			&i.OrthophotoUrl,
+			&i.Width,
+			&i.Height,
+			&i.ImageCount,
+			&i.ProcessedCount,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.MergeMethod,
+			&i.GenerationMode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FastMode,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListWaitingBatchHeightmapJobs = `-- name: ListWaitingBatchHeightmapJobs :many
+WITH in_flight AS (
+    SELECT user_id, COUNT(*) AS jobs FROM batch_heightmap_jobs
+    WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+    GROUP BY user_id
+), waiting AS (
+    SELECT j.id, j.priority, j.created_at,
+        ROW_NUMBER() OVER (PARTITION BY j.user_id ORDER BY j.priority DESC, j.created_at) + COALESCE(f.jobs, 0) AS share_rank
+    FROM batch_heightmap_jobs j
+    LEFT JOIN in_flight f ON f.user_id = j.user_id
+    WHERE j.status = 'pending' AND j.dispatched_at IS NULL
+)
+SELECT j.id, j.user_id, j.status, j.result_url, j.orthophoto_url, j.width, j.height, j.image_count, j.processed_count, j.error_message, j.processing_time, j.merge_method, j.generation_mode, j.created_at, j.updated_at, j.fast_mode, j.requeue_count, j.priority, j.dispatched_at, j.scheduled_at, j.organization_id
+FROM batch_heightmap_jobs j
+JOIN waiting w ON w.id = j.id
+ORDER BY w.priority DESC, w.share_rank, w.created_at
+LIMIT $1
+`
+
+func (q *Queries) ListWaitingBatchHeightmapJobs(ctx context.Context, limit int32) ([]BatchHeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListWaitingBatchHeightmapJobs, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BatchHeightmapJob
+	for rows.Next() {
+		var i BatchHeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.ResultUrl,
+			&i.OrthophotoUrl,
+			&i.Width,
+			&i.Height,
+			&i.ImageCount,
+			&i.ProcessedCount,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.MergeMethod,
+			&i.GenerationMode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FastMode,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const LockBatchHeightmapJobStatus = `-- name: LockBatchHeightmapJobStatus :one
+SELECT status FROM batch_heightmap_jobs WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) LockBatchHeightmapJobStatus(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRow(ctx, LockBatchHeightmapJobStatus, id)
+	var status string
+	err := row.Scan(&status)
+	return status, err
+}
+
+const MarkBatchHeightmapJobDispatched = `-- name: MarkBatchHeightmapJobDispatched :execrows
+UPDATE batch_heightmap_jobs
+SET updated_at = $2, dispatched_at = $2
+WHERE id = $1 AND status = 'pending' AND dispatched_at IS NULL
+`
+
+type MarkBatchHeightmapJobDispatchedParams struct {
+	ID        uuid.UUID `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) MarkBatchHeightmapJobDispatched(ctx context.Context, arg MarkBatchHeightmapJobDispatchedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, MarkBatchHeightmapJobDispatched, arg.ID, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const ReleaseDueBatchHeightmapJobs = `-- name: ReleaseDueBatchHeightmapJobs :many
+UPDATE batch_heightmap_jobs
+SET status = 'pending', updated_at = $1
+WHERE id IN (
+    SELECT id FROM batch_heightmap_jobs
+    WHERE status = 'scheduled' AND scheduled_at <= $1
+    ORDER BY scheduled_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, user_id, status, result_url, orthophoto_url, width, height, image_count, processed_count, error_message, processing_time, merge_method, generation_mode, created_at, updated_at, fast_mode, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id
+`
+
+type ReleaseDueBatchHeightmapJobsParams struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	Limit     int32     `json:"limit"`
+}
+
+func (q *Queries) ReleaseDueBatchHeightmapJobs(ctx context.Context, arg ReleaseDueBatchHeightmapJobsParams) ([]BatchHeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ReleaseDueBatchHeightmapJobs, arg.UpdatedAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BatchHeightmapJob
+	for rows.Next() {
+		var i BatchHeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.ResultUrl,
+			&i.OrthophotoUrl,
+			&i.Width,
+			&i.Height,
+			&i.ImageCount,
+			&i.ProcessedCount,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.MergeMethod,
+			&i.GenerationMode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FastMode,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
 		); err != nil {
 			return nil, err
 		}
@@ -243,6 +902,52 @@ func (q *Queries) ListUserBatchHeightmaps(ctx context.Context, arg ListUserBatch
 	return items, nil
 }
 
+const RescheduleBatchHeightmapJob = `-- name: RescheduleBatchHeightmapJob :execrows
+UPDATE batch_heightmap_jobs
+SET scheduled_at = $3, updated_at = $4
+WHERE id = $1 AND status = 'scheduled'
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ))
+`
+
+type RescheduleBatchHeightmapJobParams struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (q *Queries) RescheduleBatchHeightmapJob(ctx context.Context, arg RescheduleBatchHeightmapJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, RescheduleBatchHeightmapJob,
+		arg.ID,
+		arg.UserID,
+		arg.ScheduledAt,
+		arg.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const RequeueBatchHeightmapJob = `-- name: RequeueBatchHeightmapJob :exec
+UPDATE batch_heightmap_jobs
+SET status = 'pending', processed_count = 0, requeue_count = requeue_count + 1, updated_at = $2, dispatched_at = $2
+WHERE id = $1
+`
+
+type RequeueBatchHeightmapJobParams struct {
+	ID        uuid.UUID `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) RequeueBatchHeightmapJob(ctx context.Context, arg RequeueBatchHeightmapJobParams) error {
+	_, err := q.db.Exec(ctx, RequeueBatchHeightmapJob, arg.ID, arg.UpdatedAt)
+	return err
+}
+
 const UpdateBatchImageStatus = `-- name: UpdateBatchImageStatus :exec
 UPDATE batch_images
 SET status = $2, heightmap_job_id = $3
@@ -12,6 +12,60 @@ import (
 	"github.com/google/uuid"
 )
 
+const CancelHeightmapJob = `-- name: CancelHeightmapJob :execrows
+UPDATE heightmap_jobs
+SET status = 'cancelled', updated_at = $3
+WHERE id = $1
+    AND (status = 'scheduled' OR (status = 'pending' AND dispatched_at IS NULL))
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ))
+`
+
+type CancelHeightmapJobParams struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) CancelHeightmapJob(ctx context.Context, arg CancelHeightmapJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, CancelHeightmapJob, arg.ID, arg.UserID, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const CountInFlightHeightmapJobs = `-- name: CountInFlightHeightmapJobs :one
+SELECT COUNT(*) FROM heightmap_jobs
+WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+`
+
+func (q *Queries) CountInFlightHeightmapJobs(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, CountInFlightHeightmapJobs)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const CountStaleHeightmapJobs = `-- name: CountStaleHeightmapJobs :one
+SELECT COUNT(*) FROM heightmap_jobs
+WHERE status = $1 AND dispatched_at IS NOT NULL AND updated_at < $2
+`
+
+type CountStaleHeightmapJobsParams struct {
+	Status      string    `json:"status"`
+	StaleBefore time.Time `json:"stale_before"`
+}
+
+func (q *Queries) CountStaleHeightmapJobs(ctx context.Context, arg CountStaleHeightmapJobsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, CountStaleHeightmapJobs, arg.Status, arg.StaleBefore)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const CountUserHeightmaps = `-- name: CountUserHeightmaps :one
 SELECT COUNT(*) FROM heightmap_jobs
 WHERE user_id = $1
@@ -26,18 +80,22 @@ func (q *Queries) CountUserHeightmaps(ctx context.Context, userID uuid.UUID) (in
 
 const CreateHeightmapJob = `-- name: CreateHeightmapJob :one
 INSERT INTO heightmap_jobs (
-    id, user_id, image_url, status, created_at, updated_at
-) VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at
+    id, user_id, image_url, status, priority, scheduled_at, created_at, updated_at, organization_id, project_id
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id
 `
 
 type CreateHeightmapJobParams struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
-	ImageUrl  string    `json:"image_url"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ImageUrl       string     `json:"image_url"`
+	Status         string     `json:"status"`
+	Priority       int32      `json:"priority"`
+	ScheduledAt    *time.Time `json:"scheduled_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	ProjectID      *uuid.UUID `json:"project_id"`
 }
 
 func (q *Queries) CreateHeightmapJob(ctx context.Context, arg CreateHeightmapJobParams) (HeightmapJob, error) {
@@ -46,8 +104,12 @@ func (q *Queries) CreateHeightmapJob(ctx context.Context, arg CreateHeightmapJob
 		arg.UserID,
 		arg.ImageUrl,
 		arg.Status,
+		arg.Priority,
+		arg.ScheduledAt,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.OrganizationID,
+		arg.ProjectID,
 	)
 	var i HeightmapJob
 	err := row.Scan(
@@ -62,6 +124,53 @@ func (q *Queries) CreateHeightmapJob(ctx context.Context, arg CreateHeightmapJob
 		&i.ProcessingTime,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.RequeueCount,
+		&i.Priority,
+		&i.DispatchedAt,
+		&i.ScheduledAt,
+		&i.OrganizationID,
+		&i.ProjectID,
+	)
+	return i, err
+}
+
+const DeleteFinishedHeightmapJob = `-- name: DeleteFinishedHeightmapJob :one
+DELETE FROM heightmap_jobs
+WHERE id = $1
+    AND status IN ('completed', 'failed', 'cancelled')
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ))
+RETURNING id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id
+`
+
+type DeleteFinishedHeightmapJobParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeleteFinishedHeightmapJob(ctx context.Context, arg DeleteFinishedHeightmapJobParams) (HeightmapJob, error) {
+	row := q.db.QueryRow(ctx, DeleteFinishedHeightmapJob, arg.ID, arg.UserID)
+	var i HeightmapJob
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ImageUrl,
+		&i.ResultUrl,
+		&i.Status,
+		&i.Width,
+		&i.Height,
+		&i.ErrorMessage,
+		&i.ProcessingTime,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RequeueCount,
+		&i.Priority,
+		&i.DispatchedAt,
+		&i.ScheduledAt,
+		&i.OrganizationID,
+		&i.ProjectID,
 	)
 	return i, err
 }
@@ -75,12 +184,20 @@ func (q *Queries) DeleteHeightmapJob(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const GetHeightmapJob = `-- name: GetHeightmapJob :one
-SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at FROM heightmap_jobs WHERE id = $1
+const GetAccessibleHeightmapJob = `-- name: GetAccessibleHeightmapJob :one
+SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM heightmap_jobs
+WHERE id = $1 AND (user_id = $2 OR organization_id IN (
+    SELECT organization_id FROM organization_members WHERE organization_members.user_id = $2
+))
 `
 
-func (q *Queries) GetHeightmapJob(ctx context.Context, id uuid.UUID) (HeightmapJob, error) {
-	row := q.db.QueryRow(ctx, GetHeightmapJob, id)
+type GetAccessibleHeightmapJobParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) GetAccessibleHeightmapJob(ctx context.Context, arg GetAccessibleHeightmapJobParams) (HeightmapJob, error) {
+	row := q.db.QueryRow(ctx, GetAccessibleHeightmapJob, arg.ID, arg.UserID)
 	var i HeightmapJob
 	err := row.Scan(
 		&i.ID,
@@ -94,21 +211,22 @@ func (q *Queries) GetHeightmapJob(ctx context.Context, id uuid.UUID) (HeightmapJ
 		&i.ProcessingTime,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.RequeueCount,
+		&i.Priority,
+		&i.DispatchedAt,
+		&i.ScheduledAt,
+		&i.OrganizationID,
+		&i.ProjectID,
 	)
 	return i, err
 }
 
-const GetHeightmapJobByUserID = `-- name: GetHeightmapJobByUserID :one
-SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at FROM heightmap_jobs WHERE id = $1 AND user_id = $2
+const GetHeightmapJob = `-- name: GetHeightmapJob :one
+SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM heightmap_jobs WHERE id = $1
 `
 
-type GetHeightmapJobByUserIDParams struct {
-	ID     uuid.UUID `json:"id"`
-	UserID uuid.UUID `json:"user_id"`
-}
-
-func (q *Queries) GetHeightmapJobByUserID(ctx context.Context, arg GetHeightmapJobByUserIDParams) (HeightmapJob, error) {
-	row := q.db.QueryRow(ctx, GetHeightmapJobByUserID, arg.ID, arg.UserID)
+func (q *Queries) GetHeightmapJob(ctx context.Context, id uuid.UUID) (HeightmapJob, error) {
+	row := q.db.QueryRow(ctx, GetHeightmapJob, id)
 	var i HeightmapJob
 	err := row.Scan(
 		&i.ID,
@@ -122,12 +240,92 @@ func (q *Queries) GetHeightmapJobByUserID(ctx context.Context, arg GetHeightmapJ
 		&i.ProcessingTime,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.RequeueCount,
+		&i.Priority,
+		&i.DispatchedAt,
+		&i.ScheduledAt,
+		&i.OrganizationID,
+		&i.ProjectID,
 	)
 	return i, err
 }
 
+const GetHeightmapJobQueuePosition = `-- name: GetHeightmapJobQueuePosition :one
+WITH in_flight AS (
+    SELECT user_id, COUNT(*) AS jobs FROM heightmap_jobs
+    WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+    GROUP BY user_id
+), waiting AS (
+    SELECT j.id, j.priority, j.created_at,
+        ROW_NUMBER() OVER (PARTITION BY j.user_id ORDER BY j.priority DESC, j.created_at) + COALESCE(f.jobs, 0) AS share_rank
+    FROM heightmap_jobs j
+    LEFT JOIN in_flight f ON f.user_id = j.user_id
+    WHERE j.status = 'pending' AND j.dispatched_at IS NULL
+), ordered AS (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY priority DESC, share_rank, created_at) AS position
+    FROM waiting
+)
+SELECT position FROM ordered WHERE id = $1
+`
+
+func (q *Queries) GetHeightmapJobQueuePosition(ctx context.Context, id uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, GetHeightmapJobQueuePosition, id)
+	var position int64
+	err := row.Scan(&position)
+	return position, err
+}
+
+const ListHeightmaps = `-- name: ListHeightmaps :many
+SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM heightmap_jobs
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2
+`
+
+type ListHeightmapsParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListHeightmaps(ctx context.Context, arg ListHeightmapsParams) ([]HeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListHeightmaps, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HeightmapJob
+	for rows.Next() {
+		var i HeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImageUrl,
+			&i.ResultUrl,
+			&i.Status,
+			&i.Width,
+			&i.Height,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const ListHeightmapsByStatus = `-- name: ListHeightmapsByStatus :many
-SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at FROM heightmap_jobs
+SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM heightmap_jobs
 WHERE status = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3
@@ -160,6 +358,166 @@ func (q *Queries) ListHeightmapsByStatus(ctx context.Context, arg ListHeightmaps
 			&i.ProcessingTime,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListOrganizationHeightmaps = `-- name: ListOrganizationHeightmaps :many
+SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM heightmap_jobs
+WHERE organization_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListOrganizationHeightmapsParams struct {
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	Limit          int32      `json:"limit"`
+	Offset         int32      `json:"offset"`
+}
+
+func (q *Queries) ListOrganizationHeightmaps(ctx context.Context, arg ListOrganizationHeightmapsParams) ([]HeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListOrganizationHeightmaps, arg.OrganizationID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HeightmapJob
+	for rows.Next() {
+		var i HeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImageUrl,
+			&i.ResultUrl,
+			&i.Status,
+			&i.Width,
+			&i.Height,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListProjectHeightmaps = `-- name: ListProjectHeightmaps :many
+SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM heightmap_jobs
+WHERE project_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListProjectHeightmapsParams struct {
+	ProjectID *uuid.UUID `json:"project_id"`
+	Limit     int32      `json:"limit"`
+	Offset    int32      `json:"offset"`
+}
+
+func (q *Queries) ListProjectHeightmaps(ctx context.Context, arg ListProjectHeightmapsParams) ([]HeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListProjectHeightmaps, arg.ProjectID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HeightmapJob
+	for rows.Next() {
+		var i HeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImageUrl,
+			&i.ResultUrl,
+			&i.Status,
+			&i.Width,
+			&i.Height,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListStaleHeightmapJobs = `-- name: ListStaleHeightmapJobs :many
+SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM heightmap_jobs
+WHERE status = $1 AND dispatched_at IS NOT NULL AND updated_at < $2
+ORDER BY updated_at ASC
+LIMIT $3
+FOR UPDATE SKIP LOCKED
+`
+
+type ListStaleHeightmapJobsParams struct {
+	Status      string    `json:"status"`
+	StaleBefore time.Time `json:"stale_before"`
+	BatchSize   int32     `json:"batch_size"`
+}
+
+func (q *Queries) ListStaleHeightmapJobs(ctx context.Context, arg ListStaleHeightmapJobsParams) ([]HeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListStaleHeightmapJobs, arg.Status, arg.StaleBefore, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HeightmapJob
+	for rows.Next() {
+		var i HeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImageUrl,
+			&i.ResultUrl,
+			&i.Status,
+			&i.Width,
+			&i.Height,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
 		); err != nil {
 			return nil, err
 		}
@@ -172,7 +530,7 @@ func (q *Queries) ListHeightmapsByStatus(ctx context.Context, arg ListHeightmaps
 }
 
 const ListUserHeightmaps = `-- name: ListUserHeightmaps :many
-SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at FROM heightmap_jobs
+SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM heightmap_jobs
 WHERE user_id = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3
@@ -205,6 +563,212 @@ func (q *Queries) ListUserHeightmaps(ctx context.Context, arg ListUserHeightmaps
 			&i.ProcessingTime,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListUserHeightmapsByStatus = `-- name: ListUserHeightmapsByStatus :many
+SELECT id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id FROM heightmap_jobs
+WHERE user_id = $1 AND status = $2
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4
+`
+
+type ListUserHeightmapsByStatusParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Status string    `json:"status"`
+	Limit  int32     `json:"limit"`
+	Offset int32     `json:"offset"`
+}
+
+func (q *Queries) ListUserHeightmapsByStatus(ctx context.Context, arg ListUserHeightmapsByStatusParams) ([]HeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListUserHeightmapsByStatus,
+		arg.UserID,
+		arg.Status,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HeightmapJob
+	for rows.Next() {
+		var i HeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImageUrl,
+			&i.ResultUrl,
+			&i.Status,
+			&i.Width,
+			&i.Height,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListWaitingHeightmapJobs = `-- name: ListWaitingHeightmapJobs :many
+WITH in_flight AS (
+    SELECT user_id, COUNT(*) AS jobs FROM heightmap_jobs
+    WHERE dispatched_at IS NOT NULL AND status IN ('pending', 'processing')
+    GROUP BY user_id
+), waiting AS (
+    SELECT j.id, j.priority, j.created_at,
+        ROW_NUMBER() OVER (PARTITION BY j.user_id ORDER BY j.priority DESC, j.created_at) + COALESCE(f.jobs, 0) AS share_rank
+    FROM heightmap_jobs j
+    LEFT JOIN in_flight f ON f.user_id = j.user_id
+    WHERE j.status = 'pending' AND j.dispatched_at IS NULL
+)
+SELECT j.id, j.user_id, j.image_url, j.result_url, j.status, j.width, j.height, j.error_message, j.processing_time, j.created_at, j.updated_at, j.requeue_count, j.priority, j.dispatched_at, j.scheduled_at, j.organization_id
+FROM heightmap_jobs j
+JOIN waiting w ON w.id = j.id
+ORDER BY w.priority DESC, w.share_rank, w.created_at
+LIMIT $1
+`
+
+func (q *Queries) ListWaitingHeightmapJobs(ctx context.Context, limit int32) ([]HeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ListWaitingHeightmapJobs, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HeightmapJob
+	for rows.Next() {
+		var i HeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImageUrl,
+			&i.ResultUrl,
+			&i.Status,
+			&i.Width,
+			&i.Height,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const LockHeightmapJobStatus = `-- name: LockHeightmapJobStatus :one
+SELECT status FROM heightmap_jobs WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) LockHeightmapJobStatus(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRow(ctx, LockHeightmapJobStatus, id)
+	var status string
+	err := row.Scan(&status)
+	return status, err
+}
+
+const MarkHeightmapJobDispatched = `-- name: MarkHeightmapJobDispatched :execrows
+UPDATE heightmap_jobs
+SET updated_at = $2, dispatched_at = $2
+WHERE id = $1 AND status = 'pending' AND dispatched_at IS NULL
+`
+
+type MarkHeightmapJobDispatchedParams struct {
+	ID        uuid.UUID `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) MarkHeightmapJobDispatched(ctx context.Context, arg MarkHeightmapJobDispatchedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, MarkHeightmapJobDispatched, arg.ID, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const ReleaseDueHeightmapJobs = `-- name: ReleaseDueHeightmapJobs :many
+UPDATE heightmap_jobs
+SET status = 'pending', updated_at = $1
+WHERE id IN (
+    SELECT id FROM heightmap_jobs
+    WHERE status = 'scheduled' AND scheduled_at <= $1
+    ORDER BY scheduled_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, user_id, image_url, result_url, status, width, height, error_message, processing_time, created_at, updated_at, requeue_count, priority, dispatched_at, scheduled_at, organization_id, project_id
+`
+
+type ReleaseDueHeightmapJobsParams struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	Limit     int32     `json:"limit"`
+}
+
+func (q *Queries) ReleaseDueHeightmapJobs(ctx context.Context, arg ReleaseDueHeightmapJobsParams) ([]HeightmapJob, error) {
+	rows, err := q.db.Query(ctx, ReleaseDueHeightmapJobs, arg.UpdatedAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HeightmapJob
+	for rows.Next() {
+		var i HeightmapJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImageUrl,
+			&i.ResultUrl,
+			&i.Status,
+			&i.Width,
+			&i.Height,
+			&i.ErrorMessage,
+			&i.ProcessingTime,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RequeueCount,
+			&i.Priority,
+			&i.DispatchedAt,
+			&i.ScheduledAt,
+			&i.OrganizationID,
+			&i.ProjectID,
 		); err != nil {
 			return nil, err
 		}
@@ -216,6 +780,52 @@ func (q *Queries) ListUserHeightmaps(ctx context.Context, arg ListUserHeightmaps
 	return items, nil
 }
 
+const RescheduleHeightmapJob = `-- name: RescheduleHeightmapJob :execrows
+UPDATE heightmap_jobs
+SET scheduled_at = $3, updated_at = $4
+WHERE id = $1 AND status = 'scheduled'
+    AND (user_id = $2 OR organization_id IN (
+        SELECT organization_id FROM organization_members
+        WHERE organization_members.user_id = $2 AND role IN ('owner', 'admin')
+    ))
+`
+
+type RescheduleHeightmapJobParams struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (q *Queries) RescheduleHeightmapJob(ctx context.Context, arg RescheduleHeightmapJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, RescheduleHeightmapJob,
+		arg.ID,
+		arg.UserID,
+		arg.ScheduledAt,
+		arg.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const RequeueHeightmapJob = `-- name: RequeueHeightmapJob :exec
+UPDATE heightmap_jobs
+SET status = 'pending', requeue_count = requeue_count + 1, updated_at = $2, dispatched_at = $2
+WHERE id = $1
+`
+
+type RequeueHeightmapJobParams struct {
+	ID        uuid.UUID `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) RequeueHeightmapJob(ctx context.Context, arg RequeueHeightmapJobParams) error {
+	_, err := q.db.Exec(ctx, RequeueHeightmapJob, arg.ID, arg.UpdatedAt)
+	return err
+}
+
 const UpdateJobError = `-- name: UpdateJobError :exec
 UPDATE heightmap_jobs
 SET 
@@ -11,22 +11,41 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"key_hash"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
 type BatchHeightmapJob struct {
-	ID             uuid.UUID `json:"id"`
-	UserID         uuid.UUID `json:"user_id"`
-	Status         string    `json:"status"`
-	ResultUrl      *string   `json:"result_url"`
-	OrthophotoUrl  *string   `json:"orthophoto_url"`
-	Width          *int32    `json:"width"`
-	Height         *int32    `json:"height"`
-	ImageCount     int32     `json:"image_count"`
-	ProcessedCount int32     `json:"processed_count"`
-	ErrorMessage   *string   `json:"error_message"`
-	ProcessingTime *float64  `json:"processing_time"`
-	MergeMethod    string    `json:"merge_method"`
-	GenerationMode string    `json:"generation_mode"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	Status         string     `json:"status"`
+	ResultUrl      *string    `json:"result_url"`
+	OrthophotoUrl  *string    `json:"orthophoto_url"`
+	Width          *int32     `json:"width"`
+	Height         *int32     `json:"height"`
+	ImageCount     int32      `json:"image_count"`
+	ProcessedCount int32      `json:"processed_count"`
+	ErrorMessage   *string    `json:"error_message"`
+	ProcessingTime *float64   `json:"processing_time"`
+	MergeMethod    string     `json:"merge_method"`
+	GenerationMode string     `json:"generation_mode"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	FastMode       bool       `json:"fast_mode"`
+	RequeueCount   int32      `json:"requeue_count"`
+	Priority       int32      `json:"priority"`
+	DispatchedAt   *time.Time `json:"dispatched_at"`
+	ScheduledAt    *time.Time `json:"scheduled_at"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	ProjectID      *uuid.UUID `json:"project_id"`
 }
 
 type BatchImage struct {
@@ -39,24 +58,276 @@ type BatchImage struct {
 }
 
 type HeightmapJob struct {
-	ID             uuid.UUID `json:"id"`
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ImageUrl       string     `json:"image_url"`
+	ResultUrl      *string    `json:"result_url"`
+	Status         string     `json:"status"`
+	Width          *int32     `json:"width"`
+	Height         *int32     `json:"height"`
+	ErrorMessage   *string    `json:"error_message"`
+	ProcessingTime *float64   `json:"processing_time"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	RequeueCount   int32      `json:"requeue_count"`
+	Priority       int32      `json:"priority"`
+	DispatchedAt   *time.Time `json:"dispatched_at"`
+	ScheduledAt    *time.Time `json:"scheduled_at"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	ProjectID      *uuid.UUID `json:"project_id"`
+}
+
+type JobEvent struct {
+	Seq       int64       `json:"seq"`
+	UserID    uuid.UUID   `json:"user_id"`
+	JobID     pgtype.UUID `json:"job_id"`
+	JobType   string      `json:"job_type"`
+	EventType string      `json:"event_type"`
+	Payload   []byte      `json:"payload"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type JobShare struct {
+	ID             uuid.UUID  `json:"id"`
+	HeightmapJobID *uuid.UUID `json:"heightmap_job_id"`
+	BatchJobID     *uuid.UUID `json:"batch_job_id"`
+	CreatedBy      *uuid.UUID `json:"created_by"`
+	TokenHash      string     `json:"token_hash"`
+	PasswordHash   *string    `json:"password_hash"`
+	AllowDownload  bool       `json:"allow_download"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type JobShareAccess struct {
+	ID        uuid.UUID `json:"id"`
+	ShareID   uuid.UUID `json:"share_id"`
+	Action    string    `json:"action"`
+	IpAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type JwtSigningKey struct {
+	Kid         string    `json:"kid"`
+	Algorithm   string    `json:"algorithm"`
+	PrivateKey  []byte    `json:"private_key"`
+	ActivatesAt time.Time `json:"activates_at"`
+	RetiresAt   time.Time `json:"retires_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type LoginAttempt struct {
+	Scope         string    `json:"scope"`
+	Subject       string    `json:"subject"`
+	Attempts      int32     `json:"attempts"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	BlockedUntil  time.Time `json:"blocked_until"`
+}
+
+type OidcLoginState struct {
+	State        string    `json:"state"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Organization struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type OrganizationInvitation struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	TokenHash      string     `json:"token_hash"`
+	InvitedBy      *uuid.UUID `json:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
 	UserID         uuid.UUID `json:"user_id"`
-	ImageUrl       string    `json:"image_url"`
-	ResultUrl      *string   `json:"result_url"`
-	Status         string    `json:"status"`
-	Width          *int32    `json:"width"`
-	Height         *int32    `json:"height"`
-	ErrorMessage   *string   `json:"error_message"`
-	ProcessingTime *float64  `json:"processing_time"`
+	Role           string    `json:"role"`
 	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type OutboxMessage struct {
+	ID            uuid.UUID   `json:"id"`
+	AggregateType string      `json:"aggregate_type"`
+	AggregateID   pgtype.UUID `json:"aggregate_id"`
+	MessageType   string      `json:"message_type"`
+	Payload       []byte      `json:"payload"`
+	Status        string      `json:"status"`
+	Attempts      int32       `json:"attempts"`
+	LastError     *string     `json:"last_error"`
+	SentAt        *time.Time  `json:"sent_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	NextAttemptAt time.Time   `json:"next_attempt_at"`
+}
+
+type Project struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedBy      *uuid.UUID `json:"created_by"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	SitePolygon    []byte     `json:"site_polygon"`
+	Tags           []string   `json:"tags"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type QuotaPlan struct {
+	Name                 string    `json:"name"`
+	MaxStorageBytes      int64     `json:"max_storage_bytes"`
+	MaxJobsPerDay        int32     `json:"max_jobs_per_day"`
+	MaxConcurrentBatches int32     `json:"max_concurrent_batches"`
+	MaxImagesPerBatch    int32     `json:"max_images_per_batch"`
+	CreatedAt            time.Time `json:"created_at"`
+	MaxPriority          string    `json:"max_priority"`
+}
+
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	FamilyID   uuid.UUID  `json:"family_id"`
+	TokenHash  string     `json:"token_hash"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy *uuid.UUID `json:"replaced_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type RevokedAccessToken struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type StorageDeletion struct {
+	ID              uuid.UUID   `json:"id"`
+	UserID          uuid.UUID   `json:"user_id"`
+	HeightmapJobIds []uuid.UUID `json:"heightmap_job_ids"`
+	BatchJobIds     []uuid.UUID `json:"batch_job_ids"`
+	Attempts        int32       `json:"attempts"`
+	LastError       *string     `json:"last_error"`
+	RunAfter        time.Time   `json:"run_after"`
+	CreatedAt       time.Time   `json:"created_at"`
+	InputPrefixes   []string    `json:"input_prefixes"`
+}
+
+type StorageUsage struct {
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	HeightmapJobID *uuid.UUID `json:"heightmap_job_id"`
+	BatchJobID     *uuid.UUID `json:"batch_job_id"`
+	Bytes          int64      `json:"bytes"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type TwoFactorChallenge struct {
+	TokenHash  string    `json:"token_hash"`
+	UserID     uuid.UUID `json:"user_id"`
+	Enrollment bool      `json:"enrollment"`
+	Attempts   int32     `json:"attempts"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TwoFactorRequiredRole struct {
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"password_hash"`
-	Name         string    `json:"name"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID              uuid.UUID  `json:"id"`
+	Email           string     `json:"email"`
+	PasswordHash    string     `json:"password_hash"`
+	Name            string     `json:"name"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Role            string     `json:"role"`
+	SuspendedAt     *time.Time `json:"suspended_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserQuotaPlan struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Plan      string    `json:"plan"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type UserRecoveryCode struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CodeHash  string    `json:"code_hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserToken struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    uuid.UUID `json:"user_id"`
+	Purpose   string    `json:"purpose"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserTokenRevocation struct {
+	UserID        uuid.UUID `json:"user_id"`
+	RevokedBefore time.Time `json:"revoked_before"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type UserTotp struct {
+	UserID       uuid.UUID  `json:"user_id"`
+	Secret       []byte     `json:"secret"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	LastUsedStep int64      `json:"last_used_step"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type Webhook struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID   `json:"id"`
+	WebhookID     pgtype.UUID `json:"webhook_id"`
+	EventType     string      `json:"event_type"`
+	Payload       []byte      `json:"payload"`
+	Status        string      `json:"status"`
+	Attempts      int32       `json:"attempts"`
+	NextAttemptAt time.Time   `json:"next_attempt_at"`
+	ResponseCode  *int32      `json:"response_code"`
+	LastError     *string     `json:"last_error"`
+	DeliveredAt   *time.Time  `json:"delivered_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
 }
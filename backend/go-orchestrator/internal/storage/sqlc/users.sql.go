@@ -7,24 +7,44 @@ package sqlc
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const CountUsersByRole = `-- name: CountUsersByRole :one
+SELECT COUNT(*) FROM users WHERE role = $1 AND suspended_at IS NULL
+`
+
+func (q *Queries) CountUsersByRole(ctx context.Context, role string) (int64, error) {
+	row := q.db.QueryRow(ctx, CountUsersByRole, role)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const Create = `-- name: Create :one
-INSERT INTO users (email, password_hash, name) 
-VALUES ($1, $2, $3) 
-RETURNING id, email, password_hash, name, created_at, updated_at
+INSERT INTO users (email, password_hash, name, role, email_verified_at) 
+VALUES ($1, $2, $3, $4, $5) 
+RETURNING id, email, password_hash, name, created_at, updated_at, role, suspended_at, email_verified_at
 `
 
 type CreateParams struct {
-	Email        string `json:"email"`
-	PasswordHash string `json:"password_hash"`
-	Name         string `json:"name"`
+	Email           string     `json:"email"`
+	PasswordHash    string     `json:"password_hash"`
+	Name            string     `json:"name"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 }
 
 func (q *Queries) Create(ctx context.Context, arg CreateParams) (User, error) {
-	row := q.db.QueryRow(ctx, Create, arg.Email, arg.PasswordHash, arg.Name)
+	row := q.db.QueryRow(ctx, Create,
+		arg.Email,
+		arg.PasswordHash,
+		arg.Name,
+		arg.Role,
+		arg.EmailVerifiedAt,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -33,6 +53,9 @@ func (q *Queries) Create(ctx context.Context, arg CreateParams) (User, error) {
 		&i.Name,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -47,7 +70,7 @@ func (q *Queries) Delete(ctx context.Context, id uuid.UUID) error {
 }
 
 const GetByEmail = `-- name: GetByEmail :one
-SELECT id, email, password_hash, name, created_at, updated_at FROM users WHERE email = $1 LIMIT 1
+SELECT id, email, password_hash, name, created_at, updated_at, role, suspended_at, email_verified_at FROM users WHERE LOWER(email) = LOWER($1) LIMIT 1
 `
 
 func (q *Queries) GetByEmail(ctx context.Context, email string) (User, error) {
@@ -60,12 +83,15 @@ func (q *Queries) GetByEmail(ctx context.Context, email string) (User, error) {
 		&i.Name,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const GetByID = `-- name: GetByID :one
-SELECT id, email, password_hash, name, created_at, updated_at FROM users WHERE id = $1 LIMIT 1
+SELECT id, email, password_hash, name, created_at, updated_at, role, suspended_at, email_verified_at FROM users WHERE id = $1 LIMIT 1
 `
 
 func (q *Queries) GetByID(ctx context.Context, id uuid.UUID) (User, error) {
@@ -78,6 +104,192 @@ func (q *Queries) GetByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Name,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const IsEmailVerified = `-- name: IsEmailVerified :one
+SELECT email_verified_at IS NOT NULL AS verified FROM users WHERE id = $1
+`
+
+func (q *Queries) IsEmailVerified(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRow(ctx, IsEmailVerified, id)
+	var verified bool
+	err := row.Scan(&verified)
+	return verified, err
+}
+
+const ListUsers = `-- name: ListUsers :many
+SELECT id, email, password_hash, name, created_at, updated_at, role, suspended_at, email_verified_at FROM users
+WHERE ($1::varchar IS NULL OR role = $1)
+    AND ($2::boolean IS NULL OR (suspended_at IS NOT NULL) = $2)
+    AND ($3::text IS NULL OR email ILIKE '%' || $3 || '%')
+ORDER BY created_at DESC
+LIMIT $4 OFFSET $5
+`
+
+type ListUsersParams struct {
+	Role      *string `json:"role"`
+	Suspended *bool   `json:"suspended"`
+	Email     *string `json:"email"`
+	Limit     int32   `json:"limit"`
+	Offset    int32   `json:"offset"`
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, ListUsers,
+		arg.Role,
+		arg.Suspended,
+		arg.Email,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.PasswordHash,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.EmailVerifiedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const MarkEmailVerified = `-- name: MarkEmailVerified :exec
+UPDATE users SET email_verified_at = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND email_verified_at IS NULL
+`
+
+type MarkEmailVerifiedParams struct {
+	ID              uuid.UUID  `json:"id"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) error {
+	_, err := q.db.Exec(ctx, MarkEmailVerified, arg.ID, arg.EmailVerifiedAt)
+	return err
+}
+
+const UpdateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET password_hash = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID           uuid.UUID `json:"id"`
+	PasswordHash string    `json:"password_hash"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, UpdateUserPassword, arg.ID, arg.PasswordHash)
+	return err
+}
+
+const UpdateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET name = $2,
+    email = $3,
+    email_verified_at = CASE WHEN email = $3 THEN email_verified_at ELSE NULL END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, email, password_hash, name, created_at, updated_at, role, suspended_at, email_verified_at
+`
+
+type UpdateUserProfileParams struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRow(ctx, UpdateUserProfile, arg.ID, arg.Name, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const UpdateUserRole = `-- name: UpdateUserRole :one
+UPDATE users SET role = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, email, password_hash, name, created_at, updated_at, role, suspended_at, email_verified_at
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRow(ctx, UpdateUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const UpdateUserSuspension = `-- name: UpdateUserSuspension :one
+UPDATE users SET suspended_at = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, email, password_hash, name, created_at, updated_at, role, suspended_at, email_verified_at
+`
+
+type UpdateUserSuspensionParams struct {
+	ID          uuid.UUID  `json:"id"`
+	SuspendedAt *time.Time `json:"suspended_at"`
+}
+
+func (q *Queries) UpdateUserSuspension(ctx context.Context, arg UpdateUserSuspensionParams) (User, error) {
+	row := q.db.QueryRow(ctx, UpdateUserSuspension, arg.ID, arg.SuspendedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+const (
+	HeaderEvent     = "X-Dev2GIS-Event"
+	HeaderDelivery  = "X-Dev2GIS-Delivery"
+	HeaderTimestamp = "X-Dev2GIS-Timestamp"
+	HeaderSignature = "X-Dev2GIS-Signature"
+
+	// A delivery stuck in "sending" longer than this is assumed to belong to a
+	// crashed replica and is picked up again.
+	staleSendingAfter = 5 * time.Minute
+)
+
+// Dispatcher sends queued webhook deliveries. Deliveries are claimed with
+// FOR UPDATE SKIP LOCKED, so several replicas can run it concurrently.
+type Dispatcher struct {
+	queries QueriesInterface
+	cfg     config.WebhooksConfig
+	client  *http.Client
+	logger  middleware.LoggerInterface
+}
+
+func NewDispatcher(db *storage.DB, cfg config.WebhooksConfig, logger middleware.LoggerInterface) *Dispatcher {
+	return &Dispatcher{
+		queries: db.Queries,
+		cfg:     cfg,
+		client:  newGuard(cfg.AllowedHosts).client(cfg.RequestTimeout),
+		logger:  logger,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.deliverDue(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	deliveries, err := d.queries.ClaimDueWebhookDeliveries(ctx, sqlc.ClaimDueWebhookDeliveriesParams{
+		StaleBefore: time.Now().Add(-staleSendingAfter),
+		BatchSize:   int32(d.cfg.BatchSize),
+	})
+	if err != nil {
+		d.logger.Error("Failed to claim webhook deliveries", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		d.deliver(ctx, newDelivery(delivery))
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) {
+	webhook, err := d.queries.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			d.logger.Error("Failed to load webhook for delivery", err, map[string]interface{}{
+				"delivery_id": delivery.ID.String(),
+			})
+		}
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	statusCode, sendErr := d.send(ctx, webhook.Url, webhook.Secret, delivery)
+
+	var responseCode *int32
+	if statusCode != 0 {
+		code := int32(statusCode)
+		responseCode = &code
+	}
+
+	if sendErr == nil {
+		now := time.Now()
+		err := d.queries.MarkWebhookDeliverySucceeded(ctx, sqlc.MarkWebhookDeliverySucceededParams{
+			ID:           delivery.ID,
+			Attempts:     attempts,
+			ResponseCode: responseCode,
+			DeliveredAt:  &now,
+		})
+		if err != nil {
+			d.logger.Error("Failed to mark webhook delivery as succeeded", err, map[string]interface{}{
+				"delivery_id": delivery.ID.String(),
+			})
+		}
+		return
+	}
+
+	status := DeliveryStatusPending
+	if int(attempts) >= d.cfg.MaxAttempts {
+		status = DeliveryStatusFailed
+	}
+
+	errMsg := deliveryError(statusCode, sendErr)
+
+	d.logger.Warn("Webhook delivery failed", map[string]interface{}{
+		"delivery_id": delivery.ID.String(),
+		"webhook_id":  webhook.ID.String(),
+		"attempts":    attempts,
+		"status":      status,
+		"error":       sendErr.Error(),
+	})
+
+	err = d.queries.RescheduleWebhookDelivery(ctx, sqlc.RescheduleWebhookDeliveryParams{
+		ID:            delivery.ID,
+		Status:        status,
+		Attempts:      attempts,
+		NextAttemptAt: time.Now().Add(Backoff(d.cfg.BaseBackoff, d.cfg.MaxBackoff, attempts)),
+		ResponseCode:  responseCode,
+		LastError:     &errMsg,
+	})
+	if err != nil {
+		d.logger.Error("Failed to reschedule webhook delivery", err, map[string]interface{}{
+			"delivery_id": delivery.ID.String(),
+		})
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, url, secret string, delivery *Delivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Dev2GIS-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>". Receivers recompute
+// it with their secret and compare against the X-Dev2GIS-Signature header.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the next attempt: base doubled for every
+// failed attempt, capped at max.
+func Backoff(base, max time.Duration, attempts int32) time.Duration {
+	delay := base
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
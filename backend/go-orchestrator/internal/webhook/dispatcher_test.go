@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...map[string]interface{})            {}
+func (nopLogger) Info(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Warn(msg string, fields ...map[string]interface{})             {}
+func (nopLogger) Error(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) Fatal(msg string, err error, fields ...map[string]interface{}) {}
+func (nopLogger) WithContext(ctx context.Context) *middleware.Logger            { return nil }
+func (nopLogger) Middleware() gin.HandlerFunc                                   { return nil }
+
+func TestDispatcherDeliver(t *testing.T) {
+	secret := "whsec_test"
+	payload := []byte(`{"event":"job.completed"}`)
+
+	tests := []struct {
+		name           string
+		responseStatus int
+		attempts       int32
+		expectSuccess  bool
+		expectedStatus string
+	}{
+		{name: "success", responseStatus: http.StatusOK, expectSuccess: true},
+		{name: "retry on server error", responseStatus: http.StatusInternalServerError, expectedStatus: DeliveryStatusPending},
+		{name: "give up after max attempts", responseStatus: http.StatusBadGateway, attempts: 2, expectedStatus: DeliveryStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				expected := "sha256=" + Sign(secret, r.Header.Get(HeaderTimestamp), body)
+				if r.Header.Get(HeaderSignature) != expected {
+					t.Errorf("invalid signature %q", r.Header.Get(HeaderSignature))
+				}
+				if r.Header.Get(HeaderEvent) != EventJobCompleted {
+					t.Errorf("unexpected event header %q", r.Header.Get(HeaderEvent))
+				}
+				w.WriteHeader(tt.responseStatus)
+			}))
+			defer server.Close()
+
+			var succeeded *sqlc.MarkWebhookDeliverySucceededParams
+			var rescheduled *sqlc.RescheduleWebhookDeliveryParams
+			queries := &mockQueries{
+				getWebhookFunc: func(ctx context.Context, id uuid.UUID) (sqlc.Webhook, error) {
+					return sqlc.Webhook{ID: id, Url: server.URL, Secret: secret, IsActive: true}, nil
+				},
+				markWebhookDeliverySucceededFunc: func(ctx context.Context, params sqlc.MarkWebhookDeliverySucceededParams) error {
+					succeeded = &params
+					return nil
+				},
+				rescheduleWebhookDeliveryFunc: func(ctx context.Context, params sqlc.RescheduleWebhookDeliveryParams) error {
+					rescheduled = &params
+					return nil
+				},
+			}
+
+			d := &Dispatcher{
+				queries: queries,
+				cfg:     config.WebhooksConfig{MaxAttempts: 3, BaseBackoff: time.Second, MaxBackoff: time.Minute},
+				client:  server.Client(),
+				logger:  nopLogger{},
+			}
+
+			d.deliver(context.Background(), &Delivery{
+				ID:        uuid.New(),
+				WebhookID: uuid.New(),
+				EventType: EventJobCompleted,
+				Payload:   payload,
+				Attempts:  tt.attempts,
+			})
+
+			if tt.expectSuccess {
+				if succeeded == nil || succeeded.Attempts != 1 || succeeded.DeliveredAt == nil {
+					t.Errorf("expected delivery to be marked as succeeded, got %+v", succeeded)
+				}
+				return
+			}
+
+			if rescheduled == nil {
+				t.Fatal("expected delivery to be rescheduled")
+			}
+			if rescheduled.Status != tt.expectedStatus || rescheduled.Attempts != tt.attempts+1 {
+				t.Errorf("unexpected reschedule params: %+v", rescheduled)
+			}
+			if rescheduled.ResponseCode == nil || int(*rescheduled.ResponseCode) != tt.responseStatus {
+				t.Errorf("expected response code %d to be stored", tt.responseStatus)
+			}
+		})
+	}
+}
+
+func TestDeliveryClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request must not reach an internal address")
+	}))
+	defer server.Close()
+
+	_, err := newGuard(nil).client(time.Second).Get(server.URL)
+	if err == nil {
+		t.Fatal("expected the request to be refused")
+	}
+	if msg := deliveryError(0, err); msg != errForbiddenDestination.Error() {
+		t.Errorf("expected a short reason without internal details, got %q", msg)
+	}
+}
+
+func TestDeliveryClientDoesNotFollowRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the redirect must not be followed")
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	hostname, _, _ := strings.Cut(host, ":")
+	resp, err := newGuard([]string{hostname}).client(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("expected the redirect to be returned as is, got %d", resp.StatusCode)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 30 * time.Second},
+		{attempts: 2, expected: time.Minute},
+		{attempts: 4, expected: 4 * time.Minute},
+		{attempts: 20, expected: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(30*time.Second, time.Hour, tt.attempts); got != tt.expected {
+			t.Errorf("attempt %d: expected %v, got %v", tt.attempts, tt.expected, got)
+		}
+	}
+}
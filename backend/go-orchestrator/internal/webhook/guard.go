@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidWebhookURL  = errors.New("некорректный адрес вебхука")
+	ErrInsecureWebhookURL = errors.New("адрес вебхука должен использовать https")
+	ErrInternalWebhookURL = errors.New("адрес вебхука указывает на внутреннюю сеть")
+
+	errForbiddenDestination = errors.New("destination address is not allowed")
+)
+
+// guard decides which destinations webhooks may reach. A webhook URL is a
+// request the server makes on behalf of a user, so unless the host is on the
+// configured allowlist it must use https and resolve to a public address.
+type guard struct {
+	allowedHosts map[string]bool
+}
+
+func newGuard(hosts []string) *guard {
+	allowed := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		allowed[strings.ToLower(strings.TrimSpace(host))] = true
+	}
+	return &guard{allowedHosts: allowed}
+}
+
+func (g *guard) allowed(host string) bool {
+	return g.allowedHosts[strings.ToLower(host)]
+}
+
+// validateURL rejects what can be rejected before any lookup: other schemes,
+// plain http and literal internal addresses. Host names are checked again at
+// dial time, when they are resolved.
+func (g *guard) validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidWebhookURL
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrInvalidWebhookURL
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if g.allowed(host) {
+		return nil
+	}
+	if u.Scheme != "https" {
+		return ErrInsecureWebhookURL
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInternalWebhookURL
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return ErrInternalWebhookURL
+	}
+	return nil
+}
+
+// dialContext resolves the host itself and connects to the vetted address, so
+// a DNS answer that changes between the check and the connection cannot point
+// the request at an internal service.
+func (g *guard) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if g.allowed(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if !publicIP(ip.IP) {
+				return nil, errForbiddenDestination
+			}
+		}
+
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+// client returns the HTTP client for deliveries. It ignores proxy settings of
+// the environment, since the proxy would be dialed instead of the receiver,
+// and does not follow redirects: a 3xx answer is a failed delivery.
+func (g *guard) client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         g.dialContext(dialer),
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// deliveryError turns a send error into the short reason kept in the delivery
+// log, which the webhook owner can read. The full error only goes to the
+// server log, so it cannot be used to probe what the server can reach.
+func deliveryError(statusCode int, err error) string {
+	if statusCode != 0 {
+		return fmt.Sprintf("unexpected status code %d", statusCode)
+	}
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	switch {
+	case errors.Is(err, errForbiddenDestination):
+		return errForbiddenDestination.Error()
+	case errors.As(err, &dnsErr):
+		return "host could not be resolved"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.As(err, &certErr):
+		return "TLS certificate verification failed"
+	default:
+		return "request failed"
+	}
+}
@@ -0,0 +1,222 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/pkg/middleware"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) RegisterRoutes(r gin.IRouter, jwtMiddleware *middleware.JWTMiddleware) {
+	protected := r.Group("webhooks")
+	protected.Use(jwtMiddleware.RequireAuth())
+	{
+		protected.POST("", h.CreateWebhook)
+		protected.GET("", h.ListWebhooks)
+		protected.DELETE("/:id", h.DeleteWebhook)
+		protected.GET("/:id/deliveries", h.ListDeliveries)
+		protected.POST("/:id/deliveries/:delivery_id/redeliver", h.Redeliver)
+	}
+}
+
+// @Summary Create Webhook
+// @Description Subscribe a URL to job events. The signing secret is returned only once.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body CreateWebhookRequest true "Webhook subscription"
+// @Success 201 {object} CreateWebhookResponse
+// @Failure 400 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/webhooks [post]
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные запроса"})
+		return
+	}
+
+	result, err := h.service.CreateWebhook(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// @Summary List Webhooks
+// @Description List webhook subscriptions of the authenticated user
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/webhooks [get]
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.service.ListWebhooks(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+// @Summary Delete Webhook
+// @Description Delete a webhook subscription together with its delivery log
+// @Tags webhooks
+// @Param id path string true "Webhook ID"
+// @Success 204
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/webhooks/{id} [delete]
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID вебхука"})
+		return
+	}
+
+	if err := h.service.DeleteWebhook(c.Request.Context(), userID, webhookID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary List Webhook Deliveries
+// @Description Delivery log of a webhook, newest first
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param limit query int false "Limit" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/webhooks/{id}/deliveries [get]
+func (h *Handler) ListDeliveries(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID вебхука"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	deliveries, err := h.service.ListDeliveries(c.Request.Context(), userID, webhookID, int32(limit), int32(offset))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// @Summary Redeliver Webhook
+// @Description Queue a new delivery with the payload of an earlier one
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 202 {object} Delivery
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *Handler) Redeliver(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID вебхука"})
+		return
+	}
+
+	deliveryID, err := uuid.Parse(c.Param("delivery_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID доставки"})
+		return
+	}
+
+	delivery, err := h.service.Redeliver(c.Request.Context(), userID, webhookID, deliveryID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func getUserID(c *gin.Context) (uuid.UUID, bool) {
+	userIDStr, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Не авторизован"})
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func writeError(c *gin.Context, err error) {
+	if errors.Is(err, ErrWebhookNotFound) || errors.Is(err, ErrDeliveryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,32 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+type QueriesInterface interface {
+	CreateWebhook(ctx context.Context, params sqlc.CreateWebhookParams) (sqlc.Webhook, error)
+	GetWebhook(ctx context.Context, id uuid.UUID) (sqlc.Webhook, error)
+	GetWebhookByUserID(ctx context.Context, params sqlc.GetWebhookByUserIDParams) (sqlc.Webhook, error)
+	ListUserWebhooks(ctx context.Context, userID uuid.UUID) ([]sqlc.Webhook, error)
+	ListActiveWebhooksForEvent(ctx context.Context, params sqlc.ListActiveWebhooksForEventParams) ([]sqlc.Webhook, error)
+	DeleteWebhook(ctx context.Context, params sqlc.DeleteWebhookParams) (int64, error)
+
+	CreateWebhookDelivery(ctx context.Context, params sqlc.CreateWebhookDeliveryParams) (sqlc.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, params sqlc.GetWebhookDeliveryParams) (sqlc.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, params sqlc.ListWebhookDeliveriesParams) ([]sqlc.WebhookDelivery, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, params sqlc.ClaimDueWebhookDeliveriesParams) ([]sqlc.WebhookDelivery, error)
+	MarkWebhookDeliverySucceeded(ctx context.Context, params sqlc.MarkWebhookDeliverySucceededParams) error
+	RescheduleWebhookDelivery(ctx context.Context, params sqlc.RescheduleWebhookDeliveryParams) error
+}
+
+// DeliveryQueriesInterface is the part of the queries Notify needs. Callers
+// pass the queries of their transaction, so deliveries are queued together
+// with the state change that triggered them.
+type DeliveryQueriesInterface interface {
+	ListActiveWebhooksForEvent(ctx context.Context, params sqlc.ListActiveWebhooksForEventParams) ([]sqlc.Webhook, error)
+	CreateWebhookDelivery(ctx context.Context, params sqlc.CreateWebhookDeliveryParams) (sqlc.WebhookDelivery, error)
+}
@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+const (
+	EventJobCompleted  = "job.completed"
+	EventJobFailed     = "job.failed"
+	EventBatchProgress = "batch.progress"
+)
+
+var supportedEvents = map[string]bool{
+	EventJobCompleted:  true,
+	EventJobFailed:     true,
+	EventBatchProgress: true,
+}
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSending   = "sending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+type Webhook struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Delivery struct {
+	ID            uuid.UUID       `json:"id"`
+	WebhookID     uuid.UUID       `json:"webhook_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	ResponseCode  *int32          `json:"response_code,omitempty"`
+	LastError     *string         `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Envelope is the JSON body POSTed to the subscriber.
+type Envelope struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func newWebhook(w sqlc.Webhook) *Webhook {
+	return &Webhook{
+		ID:        w.ID,
+		URL:       w.Url,
+		Events:    w.Events,
+		IsActive:  w.IsActive,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+}
+
+func newDelivery(d sqlc.WebhookDelivery) *Delivery {
+	return &Delivery{
+		ID:            d.ID,
+		WebhookID:     d.WebhookID.Bytes,
+		EventType:     d.EventType,
+		Payload:       d.Payload,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		NextAttemptAt: d.NextAttemptAt,
+		ResponseCode:  d.ResponseCode,
+		LastError:     d.LastError,
+		DeliveredAt:   d.DeliveredAt,
+		CreatedAt:     d.CreatedAt,
+	}
+}
@@ -0,0 +1,12 @@
+package webhook
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"required,min=1"`
+}
+
+// CreateWebhookResponse carries the signing secret, which is only shown once.
+type CreateWebhookResponse struct {
+	*Webhook
+	Secret string `json:"secret"`
+}
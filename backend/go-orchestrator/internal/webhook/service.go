@@ -0,0 +1,216 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/skr1ms/dev2gis/config"
+	"github.com/skr1ms/dev2gis/internal/storage"
+	"github.com/skr1ms/dev2gis/internal/storage/sqlc"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("вебхук не найден")
+	ErrDeliveryNotFound = errors.New("доставка не найдена")
+)
+
+type Service struct {
+	db      *storage.DB
+	queries QueriesInterface
+	guard   *guard
+}
+
+func NewService(db *storage.DB, cfg config.WebhooksConfig) *Service {
+	return &Service{
+		db:      db,
+		queries: db.Queries,
+		guard:   newGuard(cfg.AllowedHosts),
+	}
+}
+
+func (s *Service) CreateWebhook(ctx context.Context, userID uuid.UUID, req *CreateWebhookRequest) (*CreateWebhookResponse, error) {
+	if err := s.guard.validateURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	events := make([]string, 0, len(req.Events))
+	seen := make(map[string]bool, len(req.Events))
+	for _, event := range req.Events {
+		if !supportedEvents[event] {
+			return nil, fmt.Errorf("неподдерживаемое событие: %s", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать секрет вебхука: %w", err)
+	}
+
+	webhook, err := s.queries.CreateWebhook(ctx, sqlc.CreateWebhookParams{
+		UserID: userID,
+		Url:    req.URL,
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать вебхук: %w", err)
+	}
+
+	return &CreateWebhookResponse{
+		Webhook: newWebhook(webhook),
+		Secret:  secret,
+	}, nil
+}
+
+func (s *Service) ListWebhooks(ctx context.Context, userID uuid.UUID) ([]*Webhook, error) {
+	webhooks, err := s.queries.ListUserWebhooks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список вебхуков: %w", err)
+	}
+
+	result := make([]*Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		result = append(result, newWebhook(webhook))
+	}
+
+	return result, nil
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, userID, webhookID uuid.UUID) error {
+	deleted, err := s.queries.DeleteWebhook(ctx, sqlc.DeleteWebhookParams{
+		ID:     webhookID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось удалить вебхук: %w", err)
+	}
+	if deleted == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (s *Service) ListDeliveries(ctx context.Context, userID, webhookID uuid.UUID, limit, offset int32) ([]*Delivery, error) {
+	if err := s.checkOwnership(ctx, userID, webhookID); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.queries.ListWebhookDeliveries(ctx, sqlc.ListWebhookDeliveriesParams{
+		WebhookID: pgtype.UUID{Bytes: webhookID, Valid: true},
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить журнал доставок: %w", err)
+	}
+
+	result := make([]*Delivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		result = append(result, newDelivery(delivery))
+	}
+
+	return result, nil
+}
+
+// Redeliver queues a new delivery with the payload of an earlier one, keeping
+// the original entry in the log untouched.
+func (s *Service) Redeliver(ctx context.Context, userID, webhookID, deliveryID uuid.UUID) (*Delivery, error) {
+	if err := s.checkOwnership(ctx, userID, webhookID); err != nil {
+		return nil, err
+	}
+
+	original, err := s.queries.GetWebhookDelivery(ctx, sqlc.GetWebhookDeliveryParams{
+		ID:        deliveryID,
+		WebhookID: pgtype.UUID{Bytes: webhookID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, fmt.Errorf("не удалось получить доставку: %w", err)
+	}
+
+	delivery, err := s.queries.CreateWebhookDelivery(ctx, sqlc.CreateWebhookDeliveryParams{
+		WebhookID: original.WebhookID,
+		EventType: original.EventType,
+		Payload:   original.Payload,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось поставить доставку в очередь: %w", err)
+	}
+
+	return newDelivery(delivery), nil
+}
+
+// Notify queues a delivery of event to every active webhook of the user that
+// subscribed to it, using q. The dispatcher sends them asynchronously.
+func (s *Service) Notify(ctx context.Context, q DeliveryQueriesInterface, userID uuid.UUID, event string, data interface{}) error {
+	webhooks, err := q.ListActiveWebhooksForEvent(ctx, sqlc.ListActiveWebhooksForEventParams{
+		UserID: userID,
+		Event:  event,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks for %s: %w", event, err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(&Envelope{
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		_, err := q.CreateWebhookDelivery(ctx, sqlc.CreateWebhookDeliveryParams{
+			WebhookID: pgtype.UUID{Bytes: webhook.ID, Valid: true},
+			EventType: event,
+			Payload:   payload,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to queue webhook delivery: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) checkOwnership(ctx context.Context, userID, webhookID uuid.UUID) error {
+	_, err := s.queries.GetWebhookByUserID(ctx, sqlc.GetWebhookByUserIDParams{
+		ID:     webhookID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrWebhookNotFound
+		}
+		return fmt.Errorf("не удалось получить вебхук: %w", err)
+	}
+
+	return nil
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
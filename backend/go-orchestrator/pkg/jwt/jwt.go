@@ -8,11 +8,21 @@ import (
 	"github.com/google/uuid"
 )
 
+// JWTService issues and validates tokens. Access tokens are signed with the
+// shared access secret unless a KeySet is in use, in which case they carry
+// the kid of an asymmetric key and anyone can verify them against the JWKS.
+// Refresh tokens only ever come back to this service and stay on the refresh
+// secret.
 type JWTService struct {
 	accessSecret  []byte
 	refreshSecret []byte
 	accessTTL     time.Duration
 	refreshTTL    time.Duration
+
+	keys *KeySet
+	// legacyUntil is when access tokens signed with the access secret
+	// before the switch to a KeySet have all expired.
+	legacyUntil time.Time
 }
 
 type CustomClaims struct {
@@ -20,6 +30,14 @@ type CustomClaims struct {
 	Email  string    `json:"email"`
 	Name   string    `json:"name"`
 	Role   string    `json:"role,omitempty"`
+	// FamilyID links a refresh token to the login it descends from. Every
+	// rotation keeps the family, so reuse of an old token can revoke the
+	// whole chain.
+	FamilyID uuid.UUID `json:"fid,omitzero"`
+	// APIKeyID and Scopes are set instead of a token when the request was
+	// authenticated with a personal API key. They never appear in a JWT.
+	APIKeyID uuid.UUID `json:"-"`
+	Scopes   []string  `json:"-"`
 	jwt.RegisteredClaims
 }
 
@@ -27,6 +45,10 @@ type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	ExpiresAt    int64  `json:"expires_at"`
+
+	RefreshTokenID   uuid.UUID `json:"-"`
+	FamilyID         uuid.UUID `json:"-"`
+	RefreshExpiresAt time.Time `json:"-"`
 }
 
 func NewJWTService(accessSecret, refreshSecret string, accessTTL, refreshTTL time.Duration) *JWTService {
@@ -38,7 +60,38 @@ func NewJWTService(accessSecret, refreshSecret string, accessTTL, refreshTTL tim
 	}
 }
 
+// UseKeySet switches access tokens to the keys of the set. Tokens signed
+// with the access secret are still accepted for one access token lifetime,
+// so sessions survive the switch. It must be called before the service is
+// used.
+func (j *JWTService) UseKeySet(keys *KeySet) {
+	j.keys = keys
+	j.legacyUntil = time.Now().Add(j.accessTTL)
+}
+
+// JWKS returns the public keys that verify access tokens. It is empty when
+// tokens are signed with the access secret.
+func (j *JWTService) JWKS() JSONWebKeySet {
+	if j.keys == nil {
+		return JSONWebKeySet{Keys: []JSONWebKey{}}
+	}
+	return j.keys.JWKS(time.Now())
+}
+
+// GenerateTokenPair issues tokens for a new login, starting a new refresh
+// token family.
 func (j *JWTService) GenerateTokenPair(userID uuid.UUID, email, name, role string) (*TokenPair, error) {
+	return j.generateTokenPair(userID, email, name, role, uuid.New())
+}
+
+// RotateTokenPair issues the tokens that replace a refresh token, keeping its
+// family. The caller is responsible for checking the refresh token has not
+// been used before.
+func (j *JWTService) RotateTokenPair(claims *CustomClaims) (*TokenPair, error) {
+	return j.generateTokenPair(claims.UserID, claims.Email, claims.Name, claims.Role, claims.FamilyID)
+}
+
+func (j *JWTService) generateTokenPair(userID uuid.UUID, email, name, role string, familyID uuid.UUID) (*TokenPair, error) {
 	now := time.Now()
 	accessExpiry := now.Add(j.accessTTL)
 	refreshExpiry := now.Add(j.refreshTTL)
@@ -54,25 +107,28 @@ func (j *JWTService) GenerateTokenPair(userID uuid.UUID, email, name, role strin
 			NotBefore: jwt.NewNumericDate(now),
 			Subject:   userID.String(),
 			Issuer:    "dev2gis-gateway",
+			ID:        uuid.NewString(),
 		},
 	}
 
+	refreshID := uuid.New()
 	refreshClaims := &CustomClaims{
-		UserID: userID,
-		Email:  email,
-		Name:   name,
-		Role:   role,
+		UserID:   userID,
+		Email:    email,
+		Name:     name,
+		Role:     role,
+		FamilyID: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(refreshExpiry),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Subject:   userID.String(),
 			Issuer:    "dev2gis-gateway",
+			ID:        refreshID.String(),
 		},
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(j.accessSecret)
+	accessTokenString, err := j.signAccessToken(accessClaims, now)
 	if err != nil {
 		return nil, err
 	}
@@ -87,16 +143,51 @@ func (j *JWTService) GenerateTokenPair(userID uuid.UUID, email, name, role strin
 		AccessToken:  accessTokenString,
 		RefreshToken: refreshTokenString,
 		ExpiresAt:    accessExpiry.Unix(),
+
+		RefreshTokenID:   refreshID,
+		FamilyID:         familyID,
+		RefreshExpiresAt: refreshExpiry,
 	}, nil
 }
 
-func (j *JWTService) ValidateAccessToken(tokenString string) (*CustomClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+func (j *JWTService) signAccessToken(claims *CustomClaims, now time.Time) (string, error) {
+	if j.keys == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(j.accessSecret)
+	}
+
+	key, ok := j.keys.signingKey(now)
+	if !ok {
+		return "", errors.New("no active signing key")
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+func (j *JWTService) accessTokenKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if j.keys != nil && !time.Now().Before(j.legacyUntil) {
 			return nil, errors.New("unexpected signing method")
 		}
 		return j.accessSecret, nil
-	})
+	}
+	if j.keys == nil {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := j.keys.verificationKey(kid, time.Now())
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.PrivateKey.Public(), nil
+}
+
+func (j *JWTService) ValidateAccessToken(tokenString string) (*CustomClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, j.accessTokenKey)
 
 	if err != nil {
 		return nil, err
@@ -135,12 +226,3 @@ func (j *JWTService) GetAccessSecret() []byte {
 func (j *JWTService) GetRefreshSecret() []byte {
 	return j.refreshSecret
 }
-
-func (j *JWTService) RefreshTokens(refreshTokenString string) (*TokenPair, error) {
-	claims, err := j.ValidateRefreshToken(refreshTokenString)
-	if err != nil {
-		return nil, err
-	}
-
-	return j.GenerateTokenPair(claims.UserID, claims.Email, claims.Name, claims.Role)
-}
@@ -0,0 +1,164 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms access tokens can be signed with. HS256 uses the shared access
+// secret, the others the keys of a KeySet.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// SigningKey is an asymmetric key of a KeySet. A key signs tokens from
+// ActivatesAt until RetiresAt and verifies them until ExpiresAt; it is
+// published in the JWKS for its whole life, so verifiers learn of it before
+// the first token signed with it appears.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	ActivatesAt time.Time
+	RetiresAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// GenerateSigningKey creates a private key for the algorithm.
+func GenerateSigningKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+func (k SigningKey) method() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// JSONWebKey is the public part of a signing key as published in the JWKS.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// KeySet holds the signing keys shared by all replicas. It is safe for
+// concurrent use; the owner replaces its contents whenever the keys change.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []SigningKey
+}
+
+func NewKeySet() *KeySet {
+	return &KeySet{}
+}
+
+// Replace swaps in the current keys.
+func (s *KeySet) Replace(keys []SigningKey) {
+	keys = append([]SigningKey(nil), keys...)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+}
+
+// signingKey returns the most recently activated key that has not retired.
+// If every key has retired, because the keys could not be refreshed in time,
+// the newest unexpired one keeps signing rather than failing every login.
+func (s *KeySet) signingKey(now time.Time) (SigningKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var fallback *SigningKey
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		key := &s.keys[i]
+		if key.ActivatesAt.After(now) || !key.ExpiresAt.After(now) {
+			continue
+		}
+		if key.RetiresAt.After(now) {
+			return *key, true
+		}
+		if fallback == nil {
+			fallback = key
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return SigningKey{}, false
+}
+
+func (s *KeySet) verificationKey(kid string, now time.Time) (SigningKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.ID == kid && key.ExpiresAt.After(now) {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// JWKS returns the public keys of all unexpired keys.
+func (s *KeySet) JWKS(now time.Time) JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range s.keys {
+		if !key.ExpiresAt.After(now) {
+			continue
+		}
+		jwk := JSONWebKey{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch public := key.PrivateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
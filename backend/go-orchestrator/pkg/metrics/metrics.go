@@ -55,6 +55,30 @@ var (
 			Buckets: []float64{1, 5, 10, 30, 60, 120, 300},
 		},
 	)
+
+	stuckJobs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "uav_stuck_jobs",
+			Help: "Number of jobs found past their status timeout in the last reaper scan",
+		},
+		[]string{"job_type", "status"},
+	)
+
+	outboxMessagesFailedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "uav_outbox_messages_failed_total",
+			Help: "Total number of outbox messages given up after the maximum number of publish attempts",
+		},
+		[]string{"message_type"},
+	)
+
+	loginLockoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "uav_login_lockouts_total",
+			Help: "Total number of password login lockouts after repeated failed attempts",
+		},
+		[]string{"scope"},
+	)
 )
 
 func init() {
@@ -64,6 +88,9 @@ func init() {
 	prometheus.MustRegister(processingJobsCompletedTotal)
 	prometheus.MustRegister(processingJobsFailedTotal)
 	prometheus.MustRegister(processingJobDuration)
+	prometheus.MustRegister(stuckJobs)
+	prometheus.MustRegister(outboxMessagesFailedTotal)
+	prometheus.MustRegister(loginLockoutsTotal)
 }
 
 func PrometheusHandler() gin.HandlerFunc {
@@ -106,3 +133,24 @@ func RecordProcessingJobFailed() {
 func RecordProcessingJobDuration(duration time.Duration) {
 	processingJobDuration.Observe(duration.Seconds())
 }
+
+func SetStuckJobs(jobType, status string, count int) {
+	stuckJobs.WithLabelValues(jobType, status).Set(float64(count))
+}
+
+// ResetStuckJobs clears the gauge on replicas that do not run the reaper, so
+// that only the lock holder reports stuck jobs.
+func ResetStuckJobs() {
+	stuckJobs.Reset()
+}
+
+// RecordOutboxMessageFailed counts an outbox message that reached its last
+// publish attempt.
+func RecordOutboxMessageFailed(messageType string) {
+	outboxMessagesFailedTotal.WithLabelValues(messageType).Inc()
+}
+
+// RecordLoginLockout counts a lockout of an email or a client IP.
+func RecordLoginLockout(scope string) {
+	loginLockoutsTotal.WithLabelValues(scope).Inc()
+}
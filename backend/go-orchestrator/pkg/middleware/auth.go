@@ -1,38 +1,78 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/skr1ms/dev2gis/pkg/jwt"
 )
 
 const (
 	AuthorizationHeader = "Authorization"
 	BearerSchema        = "Bearer "
+	APIKeyHeader        = "X-API-Key"
 	UserIDKey           = "user_id"
 	EmailKey            = "email"
 	NameKey             = "name"
 	RoleKey             = "role"
 	ClaimsKey           = "jwt_claims"
+
+	// Browsers cannot set headers on WebSocket handshakes, so the token is
+	// passed as the second value of Sec-WebSocket-Protocol: "bearer, <token>".
+	WebSocketProtocolHeader = "Sec-WebSocket-Protocol"
+	WebSocketBearerProtocol = "bearer"
+)
+
+// Scopes of personal API keys. Read allows safe methods, upload allows the
+// rest, admin allows admin-only endpoints and implies both others.
+const (
+	ScopeRead   = "read"
+	ScopeUpload = "upload"
+	ScopeAdmin  = "admin"
 )
 
+// TokenRevocationChecker reports whether a validly signed access token was
+// revoked before it expired.
+type TokenRevocationChecker interface {
+	IsRevoked(claims *jwt.CustomClaims) bool
+}
+
+// APIKeyAuthenticator resolves a personal API key to the claims of its owner.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*jwt.CustomClaims, error)
+}
+
 type JWTMiddleware struct {
-	jwtService *jwt.JWTService
-	logger     LoggerInterface
+	jwtService  *jwt.JWTService
+	revocations TokenRevocationChecker
+	apiKeys     APIKeyAuthenticator
+	logger      LoggerInterface
 }
 
-func NewJWTMiddleware(jwtService *jwt.JWTService, logger LoggerInterface) *JWTMiddleware {
+func NewJWTMiddleware(jwtService *jwt.JWTService, revocations TokenRevocationChecker, apiKeys APIKeyAuthenticator, logger LoggerInterface) *JWTMiddleware {
 	return &JWTMiddleware{
-		jwtService: jwtService,
-		logger:     logger,
+		jwtService:  jwtService,
+		revocations: revocations,
+		apiKeys:     apiKeys,
+		logger:      logger,
 	}
 }
 
+// RequireAuth accepts either a Bearer access token or a personal API key in
+// the X-API-Key header. An API key must carry the scope the request method
+// needs.
 func (j *JWTMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			j.requireAPIKey(c, key)
+			return
+		}
+
 		token, err := j.extractToken(c)
 		if err != nil {
 			j.logAuthFailure(c, err.Error())
@@ -53,11 +93,16 @@ func (j *JWTMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
-		c.Set(UserIDKey, claims.UserID.String())
-		c.Set(EmailKey, claims.Email)
-		c.Set(NameKey, claims.Name)
-		c.Set(RoleKey, claims.Role)
-		c.Set(ClaimsKey, claims)
+		if j.revocations.IsRevoked(claims) {
+			j.logAuthFailure(c, "Revoked token")
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Токен отозван",
+			})
+			c.Abort()
+			return
+		}
+
+		setClaims(c, claims)
 
 		j.logAuthSuccess(c, claims.UserID.String(), claims.Role)
 		c.Next()
@@ -87,7 +132,7 @@ func (j *JWTMiddleware) RequireRole(allowedRoles ...string) gin.HandlerFunc {
 		}
 
 		for _, role := range allowedRoles {
-			if userClaims.Role == role {
+			if userClaims.Role == role && (role != "admin" || HasScope(userClaims, ScopeAdmin)) {
 				j.logAuthorizationSuccess(c, userClaims.UserID.String(), userClaims.Role, role)
 				c.Next()
 				return
@@ -110,8 +155,35 @@ func (j *JWTMiddleware) OperatorOrAdmin() gin.HandlerFunc {
 	return j.RequireRole("operator", "admin")
 }
 
+// SessionOnly rejects requests authenticated with an API key. It guards
+// account management, so a leaked key cannot be used to mint more keys.
+func (j *JWTMiddleware) SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if ok && claims.APIKeyID != uuid.Nil {
+			j.logAuthorizationFailure(c, "API key used for a session-only endpoint", "session")
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Действие недоступно по API ключу",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 func (j *JWTMiddleware) OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			claims, err := j.apiKeys.AuthenticateAPIKey(c.Request.Context(), key)
+			if err == nil && HasScope(claims, methodScope(c.Request.Method)) {
+				setClaims(c, claims)
+			}
+			c.Next()
+			return
+		}
+
 		token, err := j.extractToken(c)
 		if err != nil {
 			c.Next()
@@ -119,24 +191,76 @@ func (j *JWTMiddleware) OptionalAuth() gin.HandlerFunc {
 		}
 
 		claims, err := j.jwtService.ValidateAccessToken(token)
-		if err != nil {
+		if err != nil || j.revocations.IsRevoked(claims) {
 			c.Next()
 			return
 		}
 
-		c.Set(UserIDKey, claims.UserID.String())
-		c.Set(EmailKey, claims.Email)
-		c.Set(NameKey, claims.Name)
-		c.Set(RoleKey, claims.Role)
-		c.Set(ClaimsKey, claims)
-
+		setClaims(c, claims)
 		c.Next()
 	}
 }
 
+func (j *JWTMiddleware) requireAPIKey(c *gin.Context, key string) {
+	claims, err := j.apiKeys.AuthenticateAPIKey(c.Request.Context(), key)
+	if err != nil {
+		j.logAuthFailure(c, "Invalid API key: "+err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Недействительный или истекший API ключ",
+		})
+		c.Abort()
+		return
+	}
+
+	required := methodScope(c.Request.Method)
+	if !HasScope(claims, required) {
+		j.logAuthorizationFailure(c, "API key scope missing", required)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "У API ключа нет разрешения " + required,
+		})
+		c.Abort()
+		return
+	}
+
+	setClaims(c, claims)
+
+	j.logAuthSuccess(c, claims.UserID.String(), claims.Role)
+	c.Next()
+}
+
+func setClaims(c *gin.Context, claims *jwt.CustomClaims) {
+	c.Set(UserIDKey, claims.UserID.String())
+	c.Set(EmailKey, claims.Email)
+	c.Set(NameKey, claims.Name)
+	c.Set(RoleKey, claims.Role)
+	c.Set(ClaimsKey, claims)
+}
+
+// methodScope is the API key scope a request with the method needs.
+func methodScope(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ScopeRead
+	default:
+		return ScopeUpload
+	}
+}
+
+// HasScope reports whether the request may use scope. Requests with a Bearer
+// token are not limited by scopes.
+func HasScope(claims *jwt.CustomClaims, scope string) bool {
+	if claims.APIKeyID == uuid.Nil {
+		return true
+	}
+	return slices.Contains(claims.Scopes, scope) || slices.Contains(claims.Scopes, ScopeAdmin)
+}
+
 func (j *JWTMiddleware) extractToken(c *gin.Context) (string, error) {
 	authHeader := c.GetHeader(AuthorizationHeader)
 	if authHeader == "" {
+		if token, ok := extractWebSocketToken(c); ok {
+			return token, nil
+		}
 		return "", errors.New("authorization header missing")
 	}
 
@@ -147,6 +271,20 @@ func (j *JWTMiddleware) extractToken(c *gin.Context) (string, error) {
 	return strings.TrimPrefix(authHeader, BearerSchema), nil
 }
 
+func extractWebSocketToken(c *gin.Context) (string, bool) {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		return "", false
+	}
+
+	protocols := strings.Split(c.GetHeader(WebSocketProtocolHeader), ",")
+	if len(protocols) != 2 || strings.TrimSpace(protocols[0]) != WebSocketBearerProtocol {
+		return "", false
+	}
+
+	token := strings.TrimSpace(protocols[1])
+	return token, token != ""
+}
+
 func (j *JWTMiddleware) logAuthFailure(c *gin.Context, errMsg string) {
 	j.logger.Warn("Authentication failure", map[string]interface{}{
 		"ip":         c.ClientIP(),
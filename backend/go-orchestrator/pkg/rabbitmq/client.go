@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rabbitmq/amqp091-go"
 	"github.com/skr1ms/dev2gis/config"
 	"github.com/skr1ms/dev2gis/pkg/middleware"
@@ -50,18 +51,10 @@ func NewClient(cfg *config.RabbitMQConfig, logger middleware.LoggerInterface) (*
 		return nil, fmt.Errorf("failed to open channel: %w", err)
 	}
 
-	_, err = channel.QueueDeclare(
-		cfg.QueueName,
-		cfg.DurableQueue,
-		cfg.AutoDelete,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
+	if err := declareTaskQueues(channel, cfg); err != nil {
 		channel.Close()
 		conn.Close()
-		return nil, fmt.Errorf("failed to declare queue: %w", err)
+		return nil, err
 	}
 
 	client := &Client{
@@ -119,9 +112,10 @@ func (c *Client) PublishTask(ctx context.Context, task *HeightmapTask) error {
 			amqp091.Publishing{
 				DeliveryMode: amqp091.Persistent,
 				ContentType:  "application/json",
+				MessageId:    uuid.NewString(),
 				Body:         body,
 				Timestamp:    time.Now(),
-				Priority:     uint8(task.Priority),
+				Priority:     messagePriority(c.config, task.Priority),
 			},
 		)
 
@@ -152,7 +146,7 @@ func (c *Client) PublishBatchTask(ctx context.Context, task *BatchHeightmapTask)
 		return fmt.Errorf("failed to marshal batch task: %w", err)
 	}
 
-	batchQueueName := c.config.QueueName + "_batch"
+	batchQueueName := BatchQueueName(c.config)
 
 	_, err = c.channel.QueueDeclare(
 		batchQueueName,
@@ -160,7 +154,7 @@ func (c *Client) PublishBatchTask(ctx context.Context, task *BatchHeightmapTask)
 		c.config.AutoDelete,
 		false,
 		false,
-		nil,
+		taskQueueArgs(c.config),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to declare batch queue: %w", err)
@@ -192,9 +186,10 @@ func (c *Client) PublishBatchTask(ctx context.Context, task *BatchHeightmapTask)
 			amqp091.Publishing{
 				DeliveryMode: amqp091.Persistent,
 				ContentType:  "application/json",
+				MessageId:    uuid.NewString(),
 				Body:         body,
 				Timestamp:    time.Now(),
-				Priority:     uint8(task.Priority),
+				Priority:     messagePriority(c.config, task.Priority),
 			},
 		)
 
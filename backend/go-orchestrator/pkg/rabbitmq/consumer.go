@@ -0,0 +1,184 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+type ResultHandler func(ctx context.Context, event *JobResultEvent) error
+
+// resultAttemptsHeader counts how often a result has failed to apply.
+const resultAttemptsHeader = "x-result-attempts"
+
+// ConsumeResults blocks until ctx is cancelled, delivering every event from the
+// results queue to handler. A delivery is acked once handler succeeds. A failed
+// one is republished to the retry queue, which returns it after
+// ResultRetryDelay, and after ResultMaxAttempts to the dead queue; it is acked
+// only once the broker confirms the copy, so a result is never dropped.
+func (c *Client) ConsumeResults(ctx context.Context, handler ResultHandler) error {
+	for {
+		err := c.consumeResults(ctx, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		c.logger.Error("Results consumer stopped, restarting", err, map[string]interface{}{
+			"queue":    c.config.ResultsQueueName,
+			"retry_in": c.config.ReconnectDelay,
+		})
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.config.ReconnectDelay):
+		}
+	}
+}
+
+func (c *Client) consumeResults(ctx context.Context, handler ResultHandler) error {
+	if !c.IsConnected() {
+		return fmt.Errorf("connection is closed")
+	}
+
+	channel, err := c.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open consumer channel: %w", err)
+	}
+	defer channel.Close()
+
+	if err := declareResultQueues(channel, c.config); err != nil {
+		return err
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	if err := channel.Qos(c.config.PrefetchCount, 0, false); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	deliveries, err := channel.Consume(
+		c.config.ResultsQueueName,
+		"",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming results: %w", err)
+	}
+
+	c.logger.Info("Results consumer started", map[string]interface{}{
+		"queue": c.config.ResultsQueueName,
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				return fmt.Errorf("delivery channel closed")
+			}
+			c.handleResultDelivery(ctx, channel, d, handler)
+		}
+	}
+}
+
+func (c *Client) handleResultDelivery(ctx context.Context, channel *amqp091.Channel, d amqp091.Delivery, handler ResultHandler) {
+	var event JobResultEvent
+	if err := json.Unmarshal(d.Body, &event); err != nil {
+		c.logger.Error("Failed to unmarshal job result event", err)
+		c.forwardResult(ctx, channel, d, resultsDeadQueueName(c.config), resultAttempts(d), "")
+		return
+	}
+
+	if err := handler(ctx, &event); err != nil {
+		attempts := resultAttempts(d) + 1
+		c.logger.Error("Failed to handle job result event", err, map[string]interface{}{
+			"type":         event.Type,
+			"job_id":       event.JobID,
+			"batch_job_id": event.BatchJobID,
+			"attempts":     attempts,
+		})
+
+		if attempts >= c.config.ResultMaxAttempts {
+			c.forwardResult(ctx, channel, d, resultsDeadQueueName(c.config), attempts, "")
+			return
+		}
+		expiration := strconv.FormatInt(c.config.ResultRetryDelay.Milliseconds(), 10)
+		c.forwardResult(ctx, channel, d, resultsRetryQueueName(c.config), attempts, expiration)
+		return
+	}
+
+	_ = d.Ack(false)
+}
+
+// forwardResult moves a delivery to queue and acks it once the broker has
+// confirmed the copy. If the copy fails the delivery is requeued instead.
+func (c *Client) forwardResult(ctx context.Context, channel *amqp091.Channel, d amqp091.Delivery, queue string, attempts int, expiration string) {
+	headers := amqp091.Table{}
+	for key, value := range d.Headers {
+		headers[key] = value
+	}
+	headers[resultAttemptsHeader] = int32(attempts)
+
+	err := func() error {
+		confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, amqp091.Publishing{
+			ContentType:  d.ContentType,
+			DeliveryMode: amqp091.Persistent,
+			Headers:      headers,
+			Expiration:   expiration,
+			Timestamp:    time.Now(),
+			Body:         d.Body,
+		})
+		if err != nil {
+			return err
+		}
+		acked, err := confirmation.WaitContext(ctx)
+		if err != nil {
+			return err
+		}
+		if !acked {
+			return fmt.Errorf("broker did not accept the message")
+		}
+		return nil
+	}()
+	if err != nil {
+		c.logger.Error("Failed to move job result event, requeueing", err, map[string]interface{}{
+			"queue": queue,
+		})
+		_ = d.Nack(false, true)
+		return
+	}
+
+	if queue == resultsDeadQueueName(c.config) {
+		c.logger.Warn("Job result event parked", map[string]interface{}{
+			"queue":    queue,
+			"attempts": attempts,
+		})
+	}
+	_ = d.Ack(false)
+}
+
+// resultAttempts returns how often the delivery has already failed.
+func resultAttempts(d amqp091.Delivery) int {
+	switch value := d.Headers[resultAttemptsHeader].(type) {
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case int:
+		return value
+	default:
+		return 0
+	}
+}
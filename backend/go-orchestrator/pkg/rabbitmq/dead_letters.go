@@ -0,0 +1,233 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// Task message fields that must never leave the broker through the admin API.
+var redactedTaskFields = []string{"minio_access_key", "minio_secret_key", "get_url", "put_url", "delete_url"}
+
+// DeadLetter is a task message that a worker rejected without requeueing and
+// that RabbitMQ moved to the dead-letter queue.
+type DeadLetter struct {
+	ID             string          `json:"id"`
+	Queue          string          `json:"queue"`
+	Reason         string          `json:"reason"`
+	DeathCount     int64           `json:"death_count"`
+	DeadLetteredAt *time.Time      `json:"dead_lettered_at,omitempty"`
+	JobID          string          `json:"job_id,omitempty"`
+	BatchJobID     string          `json:"batch_job_id,omitempty"`
+	Body           json.RawMessage `json:"body"`
+}
+
+// ListDeadLetters returns up to limit messages from the head of the
+// dead-letter queue. Messages are fetched without ack and returned to the
+// queue when the inspection channel is closed.
+func (c *Client) ListDeadLetters(ctx context.Context, limit int) ([]*DeadLetter, error) {
+	channel, err := c.deadLetterChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer channel.Close()
+
+	letters := make([]*DeadLetter, 0)
+	for len(letters) < limit {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		d, ok, err := channel.Get(c.config.DeadLetterQueue, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, newDeadLetter(d))
+	}
+
+	return letters, nil
+}
+
+// GetDeadLetter returns the dead letter with the given id without removing it
+// from the queue.
+func (c *Client) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	channel, err := c.deadLetterChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer channel.Close()
+
+	_, letter, err := c.findDeadLetter(ctx, channel, id)
+	return letter, err
+}
+
+// HandleDeadLetter finds the dead letter with the given id and passes it to
+// fn. The message is removed from the queue only if fn succeeds; every other
+// message fetched on the way is returned to the queue.
+func (c *Client) HandleDeadLetter(ctx context.Context, id string, fn func(letter *DeadLetter) error) (*DeadLetter, error) {
+	channel, err := c.deadLetterChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer channel.Close()
+
+	d, letter, err := c.findDeadLetter(ctx, channel, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fn(letter); err != nil {
+		return letter, err
+	}
+	if err := d.Ack(false); err != nil {
+		return letter, fmt.Errorf("failed to ack dead letter: %w", err)
+	}
+
+	return letter, nil
+}
+
+// findDeadLetter scans the dead-letter queue once. Fetched messages stay
+// unacknowledged on channel until it is closed or the caller acks them.
+func (c *Client) findDeadLetter(ctx context.Context, channel *amqp091.Channel, id string) (amqp091.Delivery, *DeadLetter, error) {
+	queue, err := channel.QueueDeclarePassive(c.config.DeadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return amqp091.Delivery{}, nil, fmt.Errorf("failed to inspect dead-letter queue: %w", err)
+	}
+
+	for i := 0; i < queue.Messages; i++ {
+		if err := ctx.Err(); err != nil {
+			return amqp091.Delivery{}, nil, err
+		}
+
+		d, ok, err := channel.Get(c.config.DeadLetterQueue, false)
+		if err != nil {
+			return amqp091.Delivery{}, nil, fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		if letter := newDeadLetter(d); letter.ID == id {
+			return d, letter, nil
+		}
+	}
+
+	return amqp091.Delivery{}, nil, ErrDeadLetterNotFound
+}
+
+// PurgeDeadLetters drops every message from the dead-letter queue.
+func (c *Client) PurgeDeadLetters(ctx context.Context) (int, error) {
+	channel, err := c.deadLetterChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer channel.Close()
+
+	count, err := channel.QueuePurge(c.config.DeadLetterQueue, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead-letter queue: %w", err)
+	}
+
+	return count, nil
+}
+
+// deadLetterChannel opens a dedicated channel so that closing it releases the
+// messages fetched for inspection without touching the publishing channel.
+func (c *Client) deadLetterChannel() (*amqp091.Channel, error) {
+	if !c.IsConnected() {
+		return nil, fmt.Errorf("connection is closed")
+	}
+
+	channel, err := c.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	return channel, nil
+}
+
+func newDeadLetter(d amqp091.Delivery) *DeadLetter {
+	letter := &DeadLetter{
+		ID:   d.MessageId,
+		Body: redactTask(d.Body),
+	}
+
+	if letter.ID == "" {
+		sum := sha256.Sum256(d.Body)
+		letter.ID = hex.EncodeToString(sum[:16])
+	}
+
+	if queue, ok := d.Headers["x-first-death-queue"].(string); ok {
+		letter.Queue = queue
+	}
+	if reason, ok := d.Headers["x-first-death-reason"].(string); ok {
+		letter.Reason = reason
+	}
+	if deaths, ok := d.Headers["x-death"].([]interface{}); ok && len(deaths) > 0 {
+		if death, ok := deaths[0].(amqp091.Table); ok {
+			if count, ok := death["count"].(int64); ok {
+				letter.DeathCount = count
+			}
+			if at, ok := death["time"].(time.Time); ok {
+				letter.DeadLetteredAt = &at
+			}
+		}
+	}
+
+	var ids struct {
+		JobID      string `json:"job_id"`
+		BatchJobID string `json:"batch_job_id"`
+	}
+	if err := json.Unmarshal(d.Body, &ids); err == nil {
+		letter.JobID = ids.JobID
+		letter.BatchJobID = ids.BatchJobID
+	}
+
+	return letter
+}
+
+func redactTask(body []byte) json.RawMessage {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return json.RawMessage(fmt.Sprintf("%q", string(body)))
+	}
+
+	redactFields(fields)
+
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// redactFields hides credentials at any depth: version 1 tasks carry MinIO
+// keys at the top level, version 2 tasks presigned URLs in nested objects.
+func redactFields(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, field := range redactedTaskFields {
+			if _, ok := v[field]; ok {
+				v[field] = "[REDACTED]"
+			}
+		}
+		for _, nested := range v {
+			redactFields(nested)
+		}
+	case []interface{}:
+		for _, nested := range v {
+			redactFields(nested)
+		}
+	}
+}
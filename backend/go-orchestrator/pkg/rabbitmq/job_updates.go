@@ -0,0 +1,161 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+type JobUpdateHandler func(update *JobUpdate)
+
+// JobUpdatesRoutingKey returns the binding key matching every update of a job.
+func JobUpdatesRoutingKey(jobID string) string {
+	return "job." + jobID + ".*"
+}
+
+// JobUpdatesSubscriber consumes the job updates exchange through an exclusive
+// queue of this replica. Only jobs that somebody watches are bound, so the
+// queue does not receive log lines nobody is interested in.
+type JobUpdatesSubscriber struct {
+	client *Client
+
+	mu       sync.Mutex
+	channel  *amqp091.Channel
+	queue    string
+	bindings map[string]struct{}
+}
+
+func (c *Client) NewJobUpdatesSubscriber() *JobUpdatesSubscriber {
+	return &JobUpdatesSubscriber{
+		client:   c,
+		bindings: make(map[string]struct{}),
+	}
+}
+
+// Bind starts routing updates of jobID to this replica. Bindings survive
+// reconnects and are restored when the queue is redeclared.
+func (s *JobUpdatesSubscriber) Bind(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bindings[jobID] = struct{}{}
+	if s.channel == nil {
+		return nil
+	}
+
+	if err := s.channel.QueueBind(s.queue, JobUpdatesRoutingKey(jobID), s.client.config.JobUpdatesExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind job updates for %s: %w", jobID, err)
+	}
+
+	return nil
+}
+
+func (s *JobUpdatesSubscriber) Unbind(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.bindings, jobID)
+	if s.channel == nil {
+		return nil
+	}
+
+	if err := s.channel.QueueUnbind(s.queue, JobUpdatesRoutingKey(jobID), s.client.config.JobUpdatesExchange, nil); err != nil {
+		return fmt.Errorf("failed to unbind job updates for %s: %w", jobID, err)
+	}
+
+	return nil
+}
+
+// Consume blocks until ctx is cancelled, passing every received update to
+// handler and reopening the queue after connection failures.
+func (s *JobUpdatesSubscriber) Consume(ctx context.Context, handler JobUpdateHandler) error {
+	for {
+		err := s.consume(ctx, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		s.client.logger.Error("Job updates consumer stopped, restarting", err, map[string]interface{}{
+			"exchange": s.client.config.JobUpdatesExchange,
+			"retry_in": s.client.config.ReconnectDelay,
+		})
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.client.config.ReconnectDelay):
+		}
+	}
+}
+
+func (s *JobUpdatesSubscriber) consume(ctx context.Context, handler JobUpdateHandler) error {
+	if !s.client.IsConnected() {
+		return fmt.Errorf("connection is closed")
+	}
+
+	channel, err := s.client.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open job updates channel: %w", err)
+	}
+	defer func() {
+		s.mu.Lock()
+		s.channel = nil
+		s.mu.Unlock()
+		channel.Close()
+	}()
+
+	if err := channel.ExchangeDeclare(s.client.config.JobUpdatesExchange, "topic", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare job updates exchange: %w", err)
+	}
+
+	queue, err := channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to declare job updates queue: %w", err)
+	}
+
+	s.mu.Lock()
+	for jobID := range s.bindings {
+		if err := channel.QueueBind(queue.Name, JobUpdatesRoutingKey(jobID), s.client.config.JobUpdatesExchange, false, nil); err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("failed to restore binding for %s: %w", jobID, err)
+		}
+	}
+	s.channel = channel
+	s.queue = queue.Name
+	s.mu.Unlock()
+
+	deliveries, err := channel.Consume(queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming job updates: %w", err)
+	}
+
+	s.client.logger.Info("Job updates consumer started", map[string]interface{}{
+		"exchange": s.client.config.JobUpdatesExchange,
+		"queue":    queue.Name,
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				return fmt.Errorf("delivery channel closed")
+			}
+
+			var update JobUpdate
+			if err := json.Unmarshal(d.Body, &update); err != nil {
+				s.client.logger.Error("Failed to unmarshal job update", err, map[string]interface{}{
+					"routing_key": d.RoutingKey,
+				})
+				continue
+			}
+
+			handler(&update)
+		}
+	}
+}
@@ -2,33 +2,90 @@ package rabbitmq
 
 import "time"
 
+// TaskSchemaVersion is the version of the task message layout. Version 1
+// messages carried the MinIO endpoint and root credentials; since version 2
+// workers reach storage only through the presigned URLs of the task objects.
+const TaskSchemaVersion = 2
+
+// TaskObject is a storage object a task reads or writes. URL is the public
+// address stored on the job; the presigned URLs are added by the outbox relay
+// at publish time and expire after MINIO_TASK_URL_EXPIRY.
+type TaskObject struct {
+	Bucket    string `json:"bucket"`
+	Object    string `json:"object"`
+	URL       string `json:"url"`
+	GetURL    string `json:"get_url,omitempty"`
+	PutURL    string `json:"put_url,omitempty"`
+	DeleteURL string `json:"delete_url,omitempty"`
+}
+
 type HeightmapTask struct {
-	JobID          string    `json:"job_id"`
-	UserID         string    `json:"user_id"`
-	ImageURL       string    `json:"image_url"`
-	OutputBucket   string    `json:"output_bucket"`
-	MinioEndpoint  string    `json:"minio_endpoint"`
-	MinioPublicURL string    `json:"minio_public_url"`
-	MinioAccessKey string    `json:"minio_access_key"`
-	MinioSecretKey string    `json:"minio_secret_key"`
-	MinioUseSSL    bool      `json:"minio_use_ssl"`
-	CreatedAt      time.Time `json:"created_at"`
-	Priority       int       `json:"priority"`
+	SchemaVersion int        `json:"schema_version"`
+	JobID         string     `json:"job_id"`
+	UserID        string     `json:"user_id"`
+	ImageURL      string     `json:"image_url"`
+	Input         TaskObject `json:"input"`
+	Output        TaskObject `json:"output"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Priority      int        `json:"priority"`
 }
 
+// BatchHeightmapTask carries one presigned output per generated product;
+// Heightmap is nil for orthophoto-only jobs and Orthophoto for heightmap-only
+// ones.
 type BatchHeightmapTask struct {
-	BatchJobID     string    `json:"batch_job_id"`
-	UserID         string    `json:"user_id"`
-	ImageURLs      []string  `json:"image_urls"`
-	OutputBucket   string    `json:"output_bucket"`
-	MinioEndpoint  string    `json:"minio_endpoint"`
-	MinioPublicURL string    `json:"minio_public_url"`
-	MinioAccessKey string    `json:"minio_access_key"`
-	MinioSecretKey string    `json:"minio_secret_key"`
-	MinioUseSSL    bool      `json:"minio_use_ssl"`
-	MergeMethod    string    `json:"merge_method"`
-	FastMode       bool      `json:"fast_mode"`
-	GenerationMode string    `json:"generation_mode"`
-	CreatedAt      time.Time `json:"created_at"`
-	Priority       int       `json:"priority"`
+	SchemaVersion  int          `json:"schema_version"`
+	BatchJobID     string       `json:"batch_job_id"`
+	UserID         string       `json:"user_id"`
+	ImageURLs      []string     `json:"image_urls"`
+	Inputs         []TaskObject `json:"inputs"`
+	Heightmap      *TaskObject  `json:"heightmap,omitempty"`
+	Orthophoto     *TaskObject  `json:"orthophoto,omitempty"`
+	MergeMethod    string       `json:"merge_method"`
+	FastMode       bool         `json:"fast_mode"`
+	GenerationMode string       `json:"generation_mode"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Priority       int          `json:"priority"`
+}
+
+const (
+	JobEventStatus   = "status"
+	JobEventProgress = "progress"
+	JobEventResult   = "result"
+	JobEventError    = "error"
+)
+
+// JobResultEvent is published by the workers to the results queue. Exactly one
+// of JobID and BatchJobID is set.
+type JobResultEvent struct {
+	Type           string    `json:"type"`
+	JobID          string    `json:"job_id,omitempty"`
+	BatchJobID     string    `json:"batch_job_id,omitempty"`
+	Status         string    `json:"status,omitempty"`
+	ProcessedCount *int32    `json:"processed_count,omitempty"`
+	ResultURL      *string   `json:"result_url,omitempty"`
+	OrthophotoURL  *string   `json:"orthophoto_url,omitempty"`
+	Width          *int32    `json:"width,omitempty"`
+	Height         *int32    `json:"height,omitempty"`
+	ProcessingTime *float64  `json:"processing_time,omitempty"`
+	ErrorMessage   *string   `json:"error_message,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+const (
+	JobUpdateStatus   = "status"
+	JobUpdateProgress = "progress"
+	JobUpdateLog      = "log"
+)
+
+// JobUpdate is published by workers to the job updates exchange with the
+// routing key job.<job_id>.<type>.
+type JobUpdate struct {
+	Type      string    `json:"type"`
+	JobID     string    `json:"job_id"`
+	Status    string    `json:"status,omitempty"`
+	Progress  *float64  `json:"progress,omitempty"`
+	Level     string    `json:"level,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
 }
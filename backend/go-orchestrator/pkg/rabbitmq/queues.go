@@ -0,0 +1,98 @@
+package rabbitmq
+
+import (
+	"fmt"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/skr1ms/dev2gis/config"
+)
+
+// BatchQueueName returns the queue consumed by the batch workers.
+func BatchQueueName(cfg *config.RabbitMQConfig) string {
+	return cfg.QueueName + "_batch"
+}
+
+// resultsRetryQueueName holds results waiting for another attempt. Its
+// messages expire after RabbitMQ.ResultRetryDelay and go back to the results
+// queue.
+func resultsRetryQueueName(cfg *config.RabbitMQConfig) string {
+	return cfg.ResultsQueueName + ".retry"
+}
+
+// resultsDeadQueueName holds results that could not be applied in
+// RabbitMQ.ResultMaxAttempts attempts, for an operator to inspect.
+func resultsDeadQueueName(cfg *config.RabbitMQConfig) string {
+	return cfg.ResultsQueueName + ".dead"
+}
+
+// taskQueueArgs routes rejected task messages to the dead-letter exchange and
+// enables message priorities. The workers declare the task queues with the
+// same arguments. Queue arguments cannot be changed on an existing queue, so
+// the queues of releases without them are not reused: the default queue name
+// moved to heightmap.tasks.v2, see the upgrade notes in README.md.
+func taskQueueArgs(cfg *config.RabbitMQConfig) amqp091.Table {
+	return amqp091.Table{
+		"x-dead-letter-exchange": cfg.DeadLetterExchange,
+		"x-max-priority":         int32(cfg.MaxPriority),
+	}
+}
+
+// messagePriority clamps a task priority to the range the queues accept.
+func messagePriority(cfg *config.RabbitMQConfig, priority int) uint8 {
+	switch {
+	case priority < 0:
+		return 0
+	case priority > cfg.MaxPriority:
+		return uint8(cfg.MaxPriority)
+	default:
+		return uint8(priority)
+	}
+}
+
+// declareTaskQueues declares the single and batch task queues together with
+// the fanout dead-letter exchange and the queue that collects their rejects.
+func declareTaskQueues(channel *amqp091.Channel, cfg *config.RabbitMQConfig) error {
+	if err := channel.ExchangeDeclare(cfg.DeadLetterExchange, "fanout", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+
+	if _, err := channel.QueueDeclare(cfg.DeadLetterQueue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+
+	if err := channel.QueueBind(cfg.DeadLetterQueue, "", cfg.DeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
+
+	for _, queue := range []string{cfg.QueueName, BatchQueueName(cfg)} {
+		if _, err := channel.QueueDeclare(queue, cfg.DurableQueue, cfg.AutoDelete, false, false, taskQueueArgs(cfg)); err != nil {
+			return fmt.Errorf("failed to declare queue %s: %w", queue, err)
+		}
+	}
+
+	return nil
+}
+
+// declareResultQueues declares the results queue with its retry and dead
+// queues. The results queue keeps no arguments, so it is declared the same as
+// by the workers and by earlier releases; retries are republished by the
+// consumer instead of being dead-lettered by the broker.
+func declareResultQueues(channel *amqp091.Channel, cfg *config.RabbitMQConfig) error {
+	if _, err := channel.QueueDeclare(cfg.ResultsQueueName, cfg.DurableQueue, cfg.AutoDelete, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare results queue: %w", err)
+	}
+
+	retryArgs := amqp091.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": cfg.ResultsQueueName,
+	}
+	if _, err := channel.QueueDeclare(resultsRetryQueueName(cfg), true, false, false, false, retryArgs); err != nil {
+		return fmt.Errorf("failed to declare results retry queue: %w", err)
+	}
+
+	if _, err := channel.QueueDeclare(resultsDeadQueueName(cfg), true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare results dead queue: %w", err)
+	}
+
+	return nil
+}
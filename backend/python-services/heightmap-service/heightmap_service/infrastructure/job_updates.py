@@ -0,0 +1,73 @@
+import json
+import logging
+import threading
+from datetime import datetime, timezone
+import pika
+
+
+logger = logging.getLogger(__name__)
+
+
+class JobUpdatesPublisher:
+    """Publishes live status, progress and log lines of a job to the topic
+    exchange with the routing key job.<job_id>.<type>. Updates are transient:
+    nobody may be watching, and the results queue stays the source of truth."""
+
+    def __init__(self, channel, exchange: str, job_id: str):
+        self.channel = channel
+        self.exchange = exchange
+        self.job_id = job_id
+
+    def publish_status(self, status: str):
+        self._publish('status', {'status': status})
+
+    def publish_progress(self, percent: float):
+        self._publish('progress', {'progress': round(percent, 2)})
+
+    def publish_log(self, level: str, message: str):
+        self._publish('log', {'level': level, 'message': message})
+
+    def _publish(self, update_type: str, update: dict):
+        update['type'] = update_type
+        update['job_id'] = self.job_id
+        update['timestamp'] = datetime.now(timezone.utc).isoformat()
+
+        try:
+            self.channel.basic_publish(
+                exchange=self.exchange,
+                routing_key=f"job.{self.job_id}.{update_type}",
+                body=json.dumps(update),
+                properties=pika.BasicProperties(
+                    content_type='application/json'
+                )
+            )
+        except Exception as e:
+            # Logged to stderr only: going through the job log handler again
+            # would recurse.
+            logging.getLogger('pika').debug(f"Failed to publish job update: {e}")
+
+
+class JobLogHandler(logging.Handler):
+    """Forwards log records of the processing thread to the job updates
+    exchange. Records from other threads are ignored because the pika channel
+    is not thread safe."""
+
+    def __init__(self, updates: JobUpdatesPublisher, level=logging.INFO):
+        super().__init__(level)
+        self.updates = updates
+        self.thread_id = threading.get_ident()
+        self._emitting = False
+
+    def emit(self, record: logging.LogRecord):
+        if record.thread != self.thread_id or self._emitting:
+            return
+        if record.name.startswith('pika'):
+            return
+
+        self._emitting = True
+        try:
+            self.updates.publish_log(record.levelname.lower(), self.format(record))
+        except Exception:
+            self.handleError(record)
+        finally:
+            self._emitting = False
@@ -0,0 +1,86 @@
+import json
+import logging
+from datetime import datetime, timezone
+from typing import Optional
+import pika
+
+from heightmap_service.infrastructure.job_updates import JobUpdatesPublisher
+
+
+logger = logging.getLogger(__name__)
+
+
+class ResultPublishError(Exception):
+    """The broker did not confirm a result or error event. The task must not be
+    acked then, or the outcome of the job is lost."""
+
+
+class ResultPublisher:
+    """Reports job status, progress and results to the gateway through the
+    results queue. The gateway is the only writer of the job tables.
+
+    The channel must be in confirm mode. Result and error events raise
+    ResultPublishError unless the broker confirms them; status and progress
+    events are best effort."""
+
+    def __init__(self, channel, queue_name: str, job_key: str, job_id: str,
+                 updates: Optional[JobUpdatesPublisher] = None):
+        self.channel = channel
+        self.queue_name = queue_name
+        self.job_key = job_key
+        self.job_id = job_id
+        self.updates = updates
+
+    def publish_status(self, status: str):
+        self._publish({'type': 'status', 'status': status})
+        if self.updates:
+            self.updates.publish_status(status)
+
+    def publish_progress(self, processed_count: int, total_count: Optional[int] = None):
+        self._publish({'type': 'progress', 'processed_count': processed_count})
+        if self.updates and total_count:
+            self.updates.publish_progress(processed_count * 100.0 / total_count)
+
+    def publish_result(self, result_url: Optional[str], width: Optional[int], height: Optional[int],
+                       processing_time: float, orthophoto_url: Optional[str] = None):
+        event = {
+            'type': 'result',
+            'result_url': result_url,
+            'width': width,
+            'height': height,
+            'processing_time': processing_time,
+        }
+        if orthophoto_url:
+            event['orthophoto_url'] = orthophoto_url
+        self._publish(event, required=True)
+        if self.updates:
+            self.updates.publish_progress(100)
+            self.updates.publish_status('completed')
+
+    def publish_error(self, error_message: str):
+        self._publish({'type': 'error', 'error_message': error_message}, required=True)
+        if self.updates:
+            self.updates.publish_status('failed')
+
+    def _publish(self, event: dict, required: bool = False):
+        event[self.job_key] = self.job_id
+        event['timestamp'] = datetime.now(timezone.utc).isoformat()
+
+        try:
+            self.channel.basic_publish(
+                exchange='',
+                routing_key=self.queue_name,
+                body=json.dumps(event),
+                properties=pika.BasicProperties(
+                    content_type='application/json',
+                    delivery_mode=2
+                ),
+                mandatory=True
+            )
+            logger.info(
+                f"Published {event['type']} event for {self.job_key}={self.job_id}")
+        except Exception as e:
+            logger.error(f"Failed to publish {event['type']} event: {e}")
+            if required:
+                raise ResultPublishError(
+                    f"{event['type']} event for {self.job_key}={self.job_id} was not confirmed") from e
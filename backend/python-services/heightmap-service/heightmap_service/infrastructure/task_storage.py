@@ -0,0 +1,152 @@
+import logging
+from typing import List, Optional, Tuple
+
+import requests
+
+from heightmap_service.infrastructure.minio_client import MinioClient
+
+
+logger = logging.getLogger(__name__)
+
+# Task message layout versions. Version 1 carried the MinIO root credentials;
+# version 2 carries presigned URLs for each object the task may touch.
+SCHEMA_LEGACY = 1
+SCHEMA_PRESIGNED = 2
+
+KNOWN_BUCKETS = ['uav-data', 'uav-models']
+TRANSFER_TIMEOUT = 600
+
+
+class PresignedStorage:
+    """Accesses task objects only through the presigned URLs in the message."""
+
+    def download(self, obj: dict, local_path: str) -> bool:
+        try:
+            logger.info(f"Downloading {obj['object']} from bucket {obj['bucket']}")
+            with requests.get(obj['get_url'], stream=True, timeout=TRANSFER_TIMEOUT) as response:
+                response.raise_for_status()
+                with open(local_path, 'wb') as f:
+                    for chunk in response.iter_content(chunk_size=1024 * 1024):
+                        f.write(chunk)
+            logger.info(f"Successfully downloaded to {local_path}")
+            return True
+        except requests.RequestException as e:
+            logger.error(f"Failed to download file: {e}")
+            return False
+
+    def upload(self, local_path: str, obj: dict) -> Optional[str]:
+        try:
+            logger.info(f"Uploading {local_path} to bucket {obj['bucket']} as {obj['object']}")
+            headers = {
+                'Content-Type': content_type_for(obj['object']),
+                'Content-Disposition': 'inline',
+            }
+            with open(local_path, 'rb') as f:
+                response = requests.put(
+                    obj['put_url'], data=f, headers=headers, timeout=TRANSFER_TIMEOUT)
+            response.raise_for_status()
+            logger.info(f"Successfully uploaded {obj['object']}")
+            return obj['url']
+        except requests.RequestException as e:
+            logger.error(f"Failed to upload file: {e}")
+            return None
+
+    def delete(self, obj: dict) -> bool:
+        try:
+            logger.info(f"Deleting {obj['object']} from bucket {obj['bucket']}")
+            response = requests.delete(obj['delete_url'], timeout=60)
+            response.raise_for_status()
+            return True
+        except requests.RequestException as e:
+            logger.error(f"Failed to delete file: {e}")
+            return False
+
+
+class LegacyStorage:
+    """Handles version 1 messages still queued from before the upgrade."""
+
+    def __init__(self, task: dict):
+        self.client = MinioClient(
+            endpoint=task['minio_endpoint'],
+            access_key=task['minio_access_key'],
+            secret_key=task['minio_secret_key'],
+            use_ssl=task['minio_use_ssl']
+        )
+
+    def download(self, obj: dict, local_path: str) -> bool:
+        return self.client.download_file(obj['bucket'], obj['object'], local_path)
+
+    def upload(self, local_path: str, obj: dict) -> Optional[str]:
+        if self.client.upload_file(local_path, obj['bucket'], obj['object']) is None:
+            return None
+        return obj['url']
+
+    def delete(self, obj: dict) -> bool:
+        return self.client.delete_file(obj['bucket'], obj['object'])
+
+
+def content_type_for(object_name: str) -> str:
+    if object_name.endswith('.png'):
+        return 'image/png'
+    if object_name.endswith('.jpg') or object_name.endswith('.jpeg'):
+        return 'image/jpeg'
+    if object_name.endswith('.tif') or object_name.endswith('.tiff'):
+        return 'image/tiff'
+    return 'application/octet-stream'
+
+
+def schema_version(task: dict) -> int:
+    return int(task.get('schema_version', SCHEMA_LEGACY))
+
+
+def single_task_objects(task: dict) -> Tuple[object, dict, dict]:
+    """Returns the storage, input object and output object of a single task."""
+    if schema_version(task) >= SCHEMA_PRESIGNED:
+        return PresignedStorage(), task['input'], task['output']
+
+    output_name = f"heightmaps/{task['job_id']}_heightmap.png"
+    return (
+        LegacyStorage(task),
+        _legacy_input(task, task['image_url']),
+        _legacy_output(task, task['output_bucket'], output_name),
+    )
+
+
+def batch_task_objects(task: dict) -> Tuple[object, List[dict], Optional[dict], Optional[dict]]:
+    """Returns the storage, input objects and heightmap and orthophoto outputs
+    of a batch task. An output is None when the generation mode skips it."""
+    if schema_version(task) >= SCHEMA_PRESIGNED:
+        return PresignedStorage(), task['inputs'], task.get('heightmap'), task.get('orthophoto')
+
+    batch_job_id = task['batch_job_id']
+    return (
+        LegacyStorage(task),
+        [_legacy_input(task, url) for url in task['image_urls']],
+        _legacy_output(task, task['output_bucket'], f"batch-heightmaps/{batch_job_id}_heightmap.png"),
+        _legacy_output(task, 'uav-photoplanes', f"orthophotos/{batch_job_id}_orthophoto.tif"),
+    )
+
+
+def _legacy_input(task: dict, image_url: str) -> dict:
+    parts = image_url.split('/')
+    bucket_index = -1
+    for i, part in enumerate(parts):
+        if part in KNOWN_BUCKETS:
+            bucket_index = i
+            break
+
+    if bucket_index != -1:
+        bucket_name = parts[bucket_index]
+        object_name = '/'.join(parts[bucket_index + 1:])
+    else:
+        bucket_name = task['output_bucket']
+        object_name = parts[-1]
+
+    return {'bucket': bucket_name, 'object': object_name, 'url': image_url}
+
+
+def _legacy_output(task: dict, bucket: str, object_name: str) -> dict:
+    endpoint_public = task.get('minio_public_url') or task.get('minio_endpoint')
+    if not endpoint_public.startswith('http://') and not endpoint_public.startswith('https://'):
+        endpoint_public = f"http://{endpoint_public}"
+    return {'bucket': bucket, 'object': object_name, 'url': f"{endpoint_public}/{bucket}/{object_name}"}
@@ -7,11 +7,12 @@ import signal
 import cv2
 import numpy as np
 import pika
-import psycopg2
 from typing import Optional
 from osgeo import gdal
 from heightmap_service.config import config
-from heightmap_service.infrastructure.minio_client import MinioClient
+from heightmap_service.infrastructure.task_storage import batch_task_objects
+from heightmap_service.infrastructure.result_publisher import ResultPublisher, ResultPublishError
+from heightmap_service.infrastructure.job_updates import JobUpdatesPublisher, JobLogHandler
 from heightmap_service.services.image_service import ImageProcessor
 from heightmap_service.services.heightmap_service import HeightmapGenerator
 from heightmap_service.services.batch_service import NodeODMClient
@@ -32,6 +33,7 @@ class BatchHeightmapWorker:
         self.heightmap_generator = HeightmapGenerator()
         self.connection: Optional[pika.BlockingConnection] = None
         self.channel: Optional[pika.channel.Channel] = None
+        self.results_channel: Optional[pika.channel.Channel] = None
         self.should_stop = False
 
         signal.signal(signal.SIGINT, self._signal_handler)
@@ -53,9 +55,28 @@ class BatchHeightmapWorker:
 
         self.connection = pika.BlockingConnection(parameters)
         self.channel = self.connection.channel()
+        # Results go through their own channel in confirm mode, so a task is
+        # only acked once the broker has its result.
+        self.results_channel = self.connection.channel()
+        self.results_channel.confirm_delivery()
 
         self.channel.queue_declare(
             queue=queue_name,
+            durable=True,
+            arguments={
+                'x-dead-letter-exchange': self.config.rabbitmq_dead_letter_exchange,
+                'x-max-priority': self.config.rabbitmq_max_priority,
+            }
+        )
+
+        self.channel.queue_declare(
+            queue=self.config.rabbitmq_results_queue_name,
+            durable=True
+        )
+
+        self.channel.exchange_declare(
+            exchange=self.config.rabbitmq_job_updates_exchange,
+            exchange_type='topic',
             durable=True
         )
 
@@ -64,68 +85,6 @@ class BatchHeightmapWorker:
         logger.info(
             f"Successfully connected to RabbitMQ (batch queue: {queue_name})")
 
-    def connect_database(self):
-        try:
-            conn = psycopg2.connect(self.config.database_url)
-            return conn
-        except Exception as e:
-            logger.error(f"Failed to connect to database: {e}")
-            raise
-
-    def update_batch_job_status(self, batch_job_id: str, status: str, error_message: Optional[str] = None):
-        try:
-            conn = self.connect_database()
-            cur = conn.cursor()
-
-            if error_message:
-                cur.execute(
-                    "UPDATE batch_heightmap_jobs SET status = %s, error_message = %s, updated_at = CURRENT_TIMESTAMP WHERE id = %s",
-                    (status, error_message, batch_job_id)
-                )
-            else:
-                cur.execute(
-                    "UPDATE batch_heightmap_jobs SET status = %s, updated_at = CURRENT_TIMESTAMP WHERE id = %s",
-                    (status, batch_job_id)
-                )
-
-            conn.commit()
-            cur.close()
-            conn.close()
-            logger.info(f"Updated batch job {batch_job_id} status to {status}")
-        except Exception as e:
-            logger.error(f"Failed to update batch job status: {e}")
-
-    def update_batch_job_result(self, batch_job_id: str, result_url: str, width: int, height: int, processing_time: float, orthophoto_url: str = None):
-        try:
-            conn = self.connect_database()
-            cur = conn.cursor()
-
-            if orthophoto_url:
-                cur.execute(
-                    """UPDATE batch_heightmap_jobs 
-                       SET status = %s, result_url = %s, orthophoto_url = %s, width = %s, height = %s, 
-                           processing_time = %s, updated_at = CURRENT_TIMESTAMP 
-                       WHERE id = %s""",
-                    ('completed', result_url, orthophoto_url, width,
-                     height, processing_time, batch_job_id)
-                )
-            else:
-                cur.execute(
-                    """UPDATE batch_heightmap_jobs 
-                       SET status = %s, result_url = %s, width = %s, height = %s, 
-                           processing_time = %s, updated_at = CURRENT_TIMESTAMP 
-                       WHERE id = %s""",
-                    ('completed', result_url, width,
-                     height, processing_time, batch_job_id)
-                )
-
-            conn.commit()
-            cur.close()
-            conn.close()
-            logger.info(f"Updated batch job {batch_job_id} with results")
-        except Exception as e:
-            logger.error(f"Failed to update batch job result: {e}")
-
     def process_batch_task(self, task: dict):
         batch_job_id = task['batch_job_id']
         logger.info(
@@ -135,45 +94,33 @@ class BatchHeightmapWorker:
         input_paths = []
         output_path = None
         dem_output = None
+        updates = JobUpdatesPublisher(
+            self.channel, self.config.rabbitmq_job_updates_exchange, batch_job_id)
+        publisher = ResultPublisher(
+            self.results_channel, self.config.rabbitmq_results_queue_name, 'batch_job_id', batch_job_id, updates)
+        log_handler = JobLogHandler(updates)
+        log_handler.setFormatter(logging.Formatter('%(name)s: %(message)s'))
+        logging.getLogger().addHandler(log_handler)
 
         try:
-            self.update_batch_job_status(batch_job_id, 'processing')
+            publisher.publish_status('processing')
 
-            s3_client = MinioClient(
-                endpoint=task['minio_endpoint'],
-                access_key=task['minio_access_key'],
-                secret_key=task['minio_secret_key'],
-                use_ssl=task['minio_use_ssl']
-            )
+            storage, inputs, heightmap_obj, orthophoto_obj = batch_task_objects(task)
 
-            source_objects = []
-            for idx, image_url in enumerate(task['image_urls']):
+            for idx, input_obj in enumerate(inputs):
                 logger.info(
-                    f"Downloading image {idx + 1}/{len(task['image_urls'])}")
-
-                image_url_parts = image_url.split('/')
-                bucket_index = -1
-                for i, part in enumerate(image_url_parts):
-                    if part in ['uav-data', 'uav-models']:
-                        bucket_index = i
-                        break
-
-                if bucket_index != -1:
-                    bucket_name = image_url_parts[bucket_index]
-                    object_name = '/'.join(image_url_parts[bucket_index + 1:])
-                else:
-                    bucket_name = task['output_bucket']
-                    object_name = image_url_parts[-1]
+                    f"Downloading image {idx + 1}/{len(inputs)}")
 
                 input_path = os.path.join(
                     self.config.temp_dir, f"{batch_job_id}_input_{idx}.jpg")
                 input_paths.append(input_path)
-                source_objects.append((bucket_name, object_name))
 
-                if not s3_client.download_file(bucket_name, object_name, input_path):
+                if not storage.download(input_obj, input_path):
                     raise Exception(
                         f"Failed to download image {idx + 1} from MinIO")
 
+                publisher.publish_progress(idx + 1, len(inputs))
+
             logger.info(
                 f"All {len(input_paths)} images downloaded")
 
@@ -264,7 +211,7 @@ class BatchHeightmapWorker:
             width = None
             height = None
 
-            if generation_mode in ['heightmap', 'both']:
+            if generation_mode in ['heightmap', 'both'] and heightmap_obj is not None:
                 logger.info("Converting DEM to heightmap PNG")
 
                 dataset = gdal.Open(dem_output)
@@ -374,33 +321,24 @@ class BatchHeightmapWorker:
                 file_size = os.path.getsize(output_path)
                 record_file_size(file_size)
 
-                result_object_name = f"batch-heightmaps/{batch_job_id}_heightmap.png"
-                uploaded_name = s3_client.upload_file(
-                    output_path, task['output_bucket'], result_object_name)
+                result_url = storage.upload(output_path, heightmap_obj)
 
-                if uploaded_name is None:
+                if result_url is None:
                     raise Exception(
                         "Failed to upload batch heightmap to MinIO")
 
-                result_url = s3_client.generate_url(
-                    task['output_bucket'], result_object_name, task.get('minio_public_url', task.get('minio_endpoint')))
-
             orthophoto_url = None
-            if generation_mode in ['orthophoto', 'both']:
+            if generation_mode in ['orthophoto', 'both'] and orthophoto_obj is not None:
                 logger.info("Extracting orthophoto from NodeODM results")
                 orthophoto_output = os.path.join(
                     self.config.temp_dir, f"{batch_job_id}_orthophoto.tif")
 
                 if odm_client.extract_orthophoto(task_uuid, orthophoto_output):
                     logger.info("Uploading orthophoto to MinIO")
-                    orthophoto_object_name = f"orthophotos/{batch_job_id}_orthophoto.tif"
+                    orthophoto_url = storage.upload(
+                        orthophoto_output, orthophoto_obj)
 
-                    uploaded_orthophoto = s3_client.upload_file(
-                        orthophoto_output, 'uav-photoplanes', orthophoto_object_name)
-
-                    if uploaded_orthophoto:
-                        orthophoto_url = s3_client.generate_url(
-                            'uav-photoplanes', orthophoto_object_name, task.get('minio_public_url', task.get('minio_endpoint')))
+                    if orthophoto_url:
                         logger.info(f"Orthophoto uploaded: {orthophoto_url}")
                     else:
                         logger.warning("Failed to upload orthophoto to MinIO")
@@ -417,34 +355,37 @@ class BatchHeightmapWorker:
 
             processing_time = time.time() - start_time
 
-            self.update_batch_job_result(
-                batch_job_id, result_url, width, height, processing_time, orthophoto_url)
+            publisher.publish_result(
+                result_url, width, height, processing_time, orthophoto_url)
 
             deleted_count = 0
-            for bucket_name, object_name in source_objects:
+            for input_obj in inputs:
                 try:
-                    logger.info(
-                        f"Deleting source image: bucket={bucket_name}, object={object_name}")
-                    s3_client.delete_file(bucket_name, object_name)
-                    deleted_count += 1
+                    if storage.delete(input_obj):
+                        deleted_count += 1
                 except Exception as e:
                     logger.warning(
-                        f"Failed to delete source image {object_name}: {e}")
+                        f"Failed to delete source image {input_obj['object']}: {e}")
             logger.info(
-                f"Deleted {deleted_count}/{len(source_objects)} source images")
+                f"Deleted {deleted_count}/{len(inputs)} source images")
 
             logger.info(
                 f"Batch heightmap generation completed: batch_job_id={batch_job_id}, time={processing_time:.2f}s, images={len(input_paths)}")
 
+        except ResultPublishError:
+            raise
+
         except Exception as e:
             logger.error(
                 f"Error processing batch task for job {batch_job_id}: {e}")
             logger.error(traceback.format_exc())
             heightmap_errors_total.labels(error_type=type(e).__name__).inc()
-            self.update_batch_job_status(batch_job_id, 'failed', str(e))
+            publisher.publish_error(str(e))
             raise
 
         finally:
+            logging.getLogger().removeHandler(log_handler)
+
             for input_path in input_paths:
                 if os.path.exists(input_path):
                     try:
@@ -474,6 +415,13 @@ class BatchHeightmapWorker:
             ch.basic_ack(delivery_tag=method.delivery_tag)
             logger.info(f"Batch task {task['batch_job_id']} acknowledged")
 
+        except ResultPublishError as e:
+            # The job itself may be fine: retry it once, then leave it to the
+            # dead-letter queue, from where it can be redriven.
+            logger.error(f"Error in batch callback: {e}")
+            ch.basic_nack(delivery_tag=method.delivery_tag,
+                          requeue=not method.redelivered)
+
         except Exception as e:
             logger.error(f"Error in batch callback: {e}")
             logger.error(traceback.format_exc())
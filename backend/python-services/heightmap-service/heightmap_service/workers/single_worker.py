@@ -7,10 +7,11 @@ import signal
 import cv2
 import numpy as np
 import pika
-import psycopg2
 from typing import Optional
 from heightmap_service.config import config
-from heightmap_service.infrastructure.minio_client import MinioClient
+from heightmap_service.infrastructure.task_storage import single_task_objects
+from heightmap_service.infrastructure.result_publisher import ResultPublisher, ResultPublishError
+from heightmap_service.infrastructure.job_updates import JobUpdatesPublisher, JobLogHandler
 from heightmap_service.services.image_service import ImageProcessor
 from heightmap_service.services.heightmap_service import HeightmapGenerator
 from heightmap_service.core.metrics import (
@@ -30,6 +31,7 @@ class HeightmapWorker:
         self.heightmap_generator = HeightmapGenerator()
         self.connection: Optional[pika.BlockingConnection] = None
         self.channel: Optional[pika.channel.Channel] = None
+        self.results_channel: Optional[pika.channel.Channel] = None
         self.should_stop = False
 
         signal.signal(signal.SIGINT, self._signal_handler)
@@ -49,9 +51,28 @@ class HeightmapWorker:
 
         self.connection = pika.BlockingConnection(parameters)
         self.channel = self.connection.channel()
+        # Results go through their own channel in confirm mode, so a task is
+        # only acked once the broker has its result.
+        self.results_channel = self.connection.channel()
+        self.results_channel.confirm_delivery()
 
         self.channel.queue_declare(
             queue=self.config.rabbitmq_queue_name,
+            durable=True,
+            arguments={
+                'x-dead-letter-exchange': self.config.rabbitmq_dead_letter_exchange,
+                'x-max-priority': self.config.rabbitmq_max_priority,
+            }
+        )
+
+        self.channel.queue_declare(
+            queue=self.config.rabbitmq_results_queue_name,
+            durable=True
+        )
+
+        self.channel.exchange_declare(
+            exchange=self.config.rabbitmq_job_updates_exchange,
+            exchange_type='topic',
             durable=True
         )
 
@@ -60,57 +81,6 @@ class HeightmapWorker:
 
         logger.info("Successfully connected to RabbitMQ")
 
-    def connect_database(self):
-        try:
-            conn = psycopg2.connect(self.config.database_url)
-            return conn
-        except Exception as e:
-            logger.error(f"Failed to connect to database: {e}")
-            raise
-
-    def update_job_status(self, job_id: str, status: str, error_message: Optional[str] = None):
-        try:
-            conn = self.connect_database()
-            cur = conn.cursor()
-
-            if error_message:
-                cur.execute(
-                    "UPDATE heightmap_jobs SET status = %s, error_message = %s, updated_at = CURRENT_TIMESTAMP WHERE id = %s",
-                    (status, error_message, job_id)
-                )
-            else:
-                cur.execute(
-                    "UPDATE heightmap_jobs SET status = %s, updated_at = CURRENT_TIMESTAMP WHERE id = %s",
-                    (status, job_id)
-                )
-
-            conn.commit()
-            cur.close()
-            conn.close()
-            logger.info(f"Updated job {job_id} status to {status}")
-        except Exception as e:
-            logger.error(f"Failed to update job status: {e}")
-
-    def update_job_result(self, job_id: str, result_url: str, width: int, height: int, processing_time: float):
-        try:
-            conn = self.connect_database()
-            cur = conn.cursor()
-
-            cur.execute(
-                """UPDATE heightmap_jobs 
-                   SET status = %s, result_url = %s, width = %s, height = %s, 
-                       processing_time = %s, updated_at = CURRENT_TIMESTAMP 
-                   WHERE id = %s""",
-                ('completed', result_url, width, height, processing_time, job_id)
-            )
-
-            conn.commit()
-            cur.close()
-            conn.close()
-            logger.info(f"Updated job {job_id} with results")
-        except Exception as e:
-            logger.error(f"Failed to update job result: {e}")
-
     def process_task(self, task: dict):
         job_id = task['job_id']
         logger.info(f"Processing task: job_id={job_id}")
@@ -118,38 +88,26 @@ class HeightmapWorker:
         start_time = time.time()
         input_path = None
         output_path = None
+        updates = JobUpdatesPublisher(
+            self.channel, self.config.rabbitmq_job_updates_exchange, job_id)
+        publisher = ResultPublisher(
+            self.results_channel, self.config.rabbitmq_results_queue_name, 'job_id', job_id, updates)
+        log_handler = JobLogHandler(updates)
+        log_handler.setFormatter(logging.Formatter('%(name)s: %(message)s'))
+        logging.getLogger().addHandler(log_handler)
 
         try:
-            self.update_job_status(job_id, 'processing')
-
-            s3_client = MinioClient(
-                endpoint=task['minio_endpoint'],
-                access_key=task['minio_access_key'],
-                secret_key=task['minio_secret_key'],
-                use_ssl=task['minio_use_ssl']
-            )
-
-            image_url_parts = task['image_url'].split('/')
-            bucket_index = -1
-            for i, part in enumerate(image_url_parts):
-                if part in ['uav-data', 'uav-models']:
-                    bucket_index = i
-                    break
-
-            if bucket_index != -1:
-                bucket_name = image_url_parts[bucket_index]
-                object_name = '/'.join(image_url_parts[bucket_index + 1:])
-            else:
-                bucket_name = task['output_bucket']
-                object_name = image_url_parts[-1]
+            publisher.publish_status('processing')
+
+            storage, input_obj, output_obj = single_task_objects(task)
 
             logger.info(
-                f"Downloading image: bucket={bucket_name}, object={object_name}")
+                f"Downloading image: bucket={input_obj['bucket']}, object={input_obj['object']}")
 
             input_path = os.path.join(
                 self.config.temp_dir, f"{job_id}_input.jpg")
 
-            if not s3_client.download_file(bucket_name, object_name, input_path):
+            if not storage.download(input_obj, input_path):
                 raise Exception("Failed to download image from MinIO")
 
             image = self.image_processor.load_image(input_path)
@@ -215,46 +173,39 @@ class HeightmapWorker:
             file_size = os.path.getsize(output_path)
             record_file_size(file_size)
 
-            result_object_name = f"heightmaps/{job_id}_heightmap.png"
-            uploaded_name = s3_client.upload_file(
-                output_path, task['output_bucket'], result_object_name)
+            result_url = storage.upload(output_path, output_obj)
 
-            if uploaded_name is None:
+            if result_url is None:
                 raise Exception("Failed to upload heightmap to MinIO")
 
-            minio_public = task.get(
-                'minio_public_url', task.get('minio_endpoint'))
-            logger.info(
-                f"Generating URL with minio_public_url: {minio_public}")
-
-            result_url = s3_client.generate_url(
-                task['output_bucket'], result_object_name, minio_public)
-
             logger.info(f"Generated result_url: {result_url}")
             processing_time = time.time() - start_time
 
-            self.update_job_result(
-                job_id, result_url, width, height, processing_time)
+            publisher.publish_result(
+                result_url, width, height, processing_time)
 
             try:
-                logger.info(
-                    f"Deleting source image: bucket={bucket_name}, object={object_name}")
-                s3_client.delete_file(bucket_name, object_name)
-                logger.info("Source image deleted successfully")
+                if storage.delete(input_obj):
+                    logger.info("Source image deleted successfully")
             except Exception as e:
                 logger.warning(f"Failed to delete source image: {e}")
 
             logger.info(
                 f"Heightmap generation completed: job_id={job_id}, time={processing_time:.2f}s")
 
+        except ResultPublishError:
+            raise
+
         except Exception as e:
             logger.error(f"Error processing task for job {job_id}: {e}")
             logger.error(traceback.format_exc())
             heightmap_errors_total.labels(error_type=type(e).__name__).inc()
-            self.update_job_status(job_id, 'failed', str(e))
+            publisher.publish_error(str(e))
             raise
 
         finally:
+            logging.getLogger().removeHandler(log_handler)
+
             if input_path and os.path.exists(input_path):
                 try:
                     os.remove(input_path)
@@ -277,6 +228,13 @@ class HeightmapWorker:
             ch.basic_ack(delivery_tag=method.delivery_tag)
             logger.info(f"Task {task['job_id']} acknowledged")
 
+        except ResultPublishError as e:
+            # The job itself may be fine: retry it once, then leave it to the
+            # dead-letter queue, from where it can be redriven.
+            logger.error(f"Error in callback: {e}")
+            ch.basic_nack(delivery_tag=method.delivery_tag,
+                          requeue=not method.redelivered)
+
         except Exception as e:
             logger.error(f"Error in callback: {e}")
             logger.error(traceback.format_exc())
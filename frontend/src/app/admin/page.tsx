@@ -48,8 +48,9 @@ export default function AdminPage() {
     try {
       setLoading(true);
       
-      const singleMaps = await api.get('/api/heightmaps?limit=100');
-      const batchMaps = await api.get('/api/heightmaps/batch?limit=100');
+      const singleMaps = await api.get('/api/admin/heightmaps?limit=100');
+      const batchMaps = await api.get('/api/admin/heightmaps/batch?limit=100');
+      const users = await api.get('/api/admin/users?limit=100');
       
       const allMaps = [
         ...(Array.isArray(singleMaps) ? singleMaps : singleMaps.heightmaps || []),
@@ -57,7 +58,7 @@ export default function AdminPage() {
       ];
       
       const statsData: AdminStats = {
-        totalUsers: 1,
+        totalUsers: (users.users || []).length,
         totalHeightmaps: (Array.isArray(singleMaps) ? singleMaps : singleMaps.heightmaps || []).length,
         totalBatchHeightmaps: (Array.isArray(batchMaps) ? batchMaps : batchMaps.batch_heightmaps || []).length,
         statusCounts: {